@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/config"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// hasCommand reports whether a one-shot maintenance command was requested instead of serving
+func hasCommand() bool {
+	return *splitByType != "" || *splitByNamespace != "" || *mergeDBs != "" || flag.Arg(0) == "import" || flag.Arg(0) == "export"
+}
+
+// runCommand executes the requested one-shot maintenance command and prints its report as JSON to stdout.
+// The export command prints the export instead.
+func runCommand(logger *slog.Logger) error {
+	ctx := context.Background()
+	dbLogger := logger.With(slog.String("component", "database"))
+
+	if flag.Arg(0) == "export" {
+		if err := runExport(ctx, flag.Args()[1:], dbLogger); err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+		return nil
+	}
+
+	var report any
+	switch {
+	case *splitByType != "" || *splitByNamespace != "":
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		// The source database is never modified
+		src, err := database.NewReadOnlyDB(cfg.DBPath, dbLogger)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		var results []database.PartitionResult
+		if *splitByType != "" {
+			results, err = src.SplitByEntityType(ctx, *splitByType)
+		} else {
+			results, err = src.SplitByGraph(ctx, *splitByNamespace)
+		}
+		if err != nil {
+			return fmt.Errorf("split failed: %w", err)
+		}
+		report = results
+
+	case *mergeDBs != "":
+		if *mergeInto == "" {
+			return fmt.Errorf("-merge-dbs requires -into <merged.db>")
+		}
+		sources := strings.Split(*mergeDBs, ",")
+		merge := database.MergeDatabases
+		if *mergeAsGraphs {
+			merge = database.MergeDatabasesAsGraphs
+		}
+		result, err := merge(ctx, sources, *mergeInto, dbLogger)
+		if err != nil {
+			return fmt.Errorf("merge failed: %w", err)
+		}
+		report = result
+
+	case flag.Arg(0) == "import":
+		summary, err := runImport(ctx, flag.Args()[1:], dbLogger)
+		if err != nil {
+			return fmt.Errorf("import failed: %w", err)
+		}
+		report = summary
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// runImport merges the file named by the import command's flags in args into the
+// database at MEMORY_DB_PATH
+func runImport(ctx context.Context, args []string, logger *slog.Logger) (*database.ImportSummary, error) {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "memoryjson", "Format of the file: memoryjson, the memory.json of @modelcontextprotocol/server-memory, or jsonl, the export format")
+	file := flags.String("file", "", "File to import")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *file == "" {
+		return nil, fmt.Errorf("import requires -file <path>")
+	}
+
+	var importFile func(*database.DB, context.Context, io.Reader) (*database.ImportSummary, error)
+	switch *format {
+	case "memoryjson":
+		importFile = (*database.DB).ImportMemoryJSON
+	case "jsonl":
+		importFile = (*database.DB).ImportJSONL
+	default:
+		return nil, fmt.Errorf("unknown format %q: use memoryjson or jsonl", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DBDriver != "sqlite" {
+		return nil, fmt.Errorf("import writes to the SQLite database at MEMORY_DB_PATH, not the %s driver", cfg.DBDriver)
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := database.NewDBWithLogger(cfg.DBPath, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return importFile(db, ctx, f)
+}
+
+// runExport writes the database at MEMORY_DB_PATH, or the part the export command's
+// flags in args select, to stdout or the named file
+func runExport(ctx context.Context, args []string, logger *slog.Logger) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", database.ViewDOT, "Format: dot (Graphviz), graphml, or json, the portable document import_graph reads")
+	file := flags.String("file", "", "File to write instead of stdout")
+	root := flags.String("root", "", "dot and graphml: export only the entities within -depth relations of this one")
+	depth := flags.Int("depth", 1, "dot and graphml: relations to follow from -root")
+	maxNodes := flags.Int("max-nodes", 0, "dot and graphml: fail rather than export more entities than this (0 for no limit)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var export func(*database.DB, io.Writer) error
+	switch *format {
+	case "json":
+		if *root != "" || *maxNodes != 0 {
+			return fmt.Errorf("-root and -max-nodes apply only to the dot and graphml formats")
+		}
+		export = func(db *database.DB, w io.Writer) error { return db.Export(ctx, w) }
+	case database.ViewDOT, database.ViewGraphML:
+		opts := database.ViewOptions{Root: *root, Depth: *depth, MaxNodes: *maxNodes}
+		export = func(db *database.DB, w io.Writer) error { return db.ExportView(ctx, w, *format, opts) }
+	default:
+		return fmt.Errorf("unknown format %q: use dot, graphml or json", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.DBDriver != "sqlite" {
+		return fmt.Errorf("export reads the SQLite database at MEMORY_DB_PATH, not the %s driver", cfg.DBDriver)
+	}
+	// The database is never modified
+	db, err := database.NewReadOnlyDB(cfg.DBPath, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *file == "" {
+		return export(db, os.Stdout)
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := export(db, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
@@ -14,9 +16,12 @@ import (
 
 	"github.com/jamesprial/mcp-memory-rewrite/internal/config"
 	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/maintenance"
 	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/postgres"
 	"github.com/jamesprial/mcp-memory-rewrite/pkg/router"
 	"github.com/jamesprial/mcp-memory-rewrite/pkg/server"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/store"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -35,6 +40,13 @@ var (
 	httpAddr = flag.String("http", "", "HTTP address to listen on (e.g., :8080). If not set, uses stdio")
 	sseMode  = flag.Bool("sse", false, "Use SSE (Server-Sent Events) for HTTP mode")
 	portFile = flag.String("portfile", "", "If set with -http, write the actual bound TCP port to this file")
+	readOnly = flag.Bool("read-only", false, "Serve only the tools that read the graph (same as MEMORY_READ_ONLY=true)")
+
+	splitByType      = flag.String("split-by-type", "", "Write each entity type of MEMORY_DB_PATH to its own database file in this directory and exit")
+	splitByNamespace = flag.String("split-by-namespace", "", "Write each graph of MEMORY_DB_PATH to its own database file in this directory and exit")
+	mergeDBs         = flag.String("merge-dbs", "", "Comma-separated database files to merge (requires -into) and exit")
+	mergeInto        = flag.String("into", "", "Destination database file for -merge-dbs; must not exist")
+	mergeAsGraphs    = flag.Bool("as-graphs", false, "With -merge-dbs, merge each file's default graph into a graph named after the file, reversing -split-by-namespace")
 )
 
 func main() {
@@ -44,6 +56,14 @@ func main() {
 	logger := logging.NewLogger(MCP_NAME, logLevel)
 	slog.SetDefault(logger)
 
+	if hasCommand() {
+		if err := runCommand(logger); err != nil {
+			logger.Error("command failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(logger); err != nil {
 		logger.Error("application exited with error", slog.String("error", err.Error()))
 		os.Exit(1)
@@ -69,25 +89,206 @@ func run(logger *slog.Logger) error {
 		)
 		return err
 	}
+	cfg.ReadOnly = cfg.ReadOnly || *readOnly
+
+	// Mask secrets in observation/entity content before it reaches log output
+	redactor, err := logging.NewRedactor(cfg.RedactPatterns)
+	if err != nil {
+		logger.Error("invalid redaction configuration",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	logger = logging.WithRedaction(logger, redactor)
 
 	logger.Info("configuration loaded",
+		slog.String("db_driver", cfg.DBDriver),
 		slog.String("db_path", cfg.DBPath),
+		slog.Int("redact_patterns", len(cfg.RedactPatterns)),
+		slog.Bool("read_only", cfg.ReadOnly),
+		slog.Bool("compact_json", cfg.CompactJSON),
 	)
 
-	// Initialize database with logging
-	dbLogger := logger.With(slog.String("component", "database"))
-	db, err := database.NewDBWithLogger(cfg.DBPath, dbLogger)
+	constraints, err := loadRelationConstraints(cfg.RelationConstraintsFile)
 	if err != nil {
-		logger.Error("failed to initialize database",
+		logger.Error("invalid relation constraints",
 			slog.String("error", err.Error()),
-			slog.String("path", cfg.DBPath),
+			slog.String("path", cfg.RelationConstraintsFile),
 		)
 		return err
 	}
 
+	retention, err := loadRetentionPolicy(cfg.RetentionPolicyFile)
+	if err != nil {
+		logger.Error("invalid retention policy",
+			slog.String("error", err.Error()),
+			slog.String("path", cfg.RetentionPolicyFile),
+		)
+		return err
+	}
+
+	// Initialize the store; db is nil unless it is SQLite, and everything set up
+	// on db below is skipped without it
+	var st store.Store
+	var db *database.DB
+	switch cfg.DBDriver {
+	case "memory":
+		logger.Warn("using the in-memory backend: the graph is lost when the server exits and only the core tools are available")
+		st = store.NewMemory()
+	case "postgres":
+		// The DSN may hold a password, so it is never logged
+		st, err = postgres.NewDB(ctx, cfg.DBDSN, logger.With(slog.String("component", "database")))
+		if err != nil {
+			logger.Error("failed to initialize database",
+				slog.String("error", err.Error()),
+				slog.String("driver", cfg.DBDriver),
+			)
+			return err
+		}
+		logger.Info("using the postgres backend: only the core tools are available")
+	default:
+		dbLogger := logger.With(slog.String("component", "database"))
+		db, err = database.NewDBWithLogger(cfg.DBPath, dbLogger)
+		if err != nil {
+			logger.Error("failed to initialize database",
+				slog.String("error", err.Error()),
+				slog.String("path", cfg.DBPath),
+			)
+			return err
+		}
+		if cfg.MaxObservationsPerEntity >= 0 {
+			db.SetObservationLimit(cfg.MaxObservationsPerEntity)
+		}
+		db.SetObservationCap(cfg.MaxStoredObservationsPerEntity, cfg.ObservationEviction)
+		db.SetSoftDelete(cfg.SoftDelete)
+		db.SetAuditRedactor(redactor)
+		db.SetSnapshotLimits(cfg.MaxSnapshots, int64(cfg.MaxSnapshotMB)<<20)
+		db.SetRelationConstraints(constraints)
+		db.SetRetentionPolicy(retention)
+		if cfg.AdjacencyCache {
+			db.SetAdjacencyCache(int64(cfg.AdjacencyCacheMaxMB) << 20)
+		}
+		if cfg.WriteAttempts > 0 {
+			db.SetWriteAttempts(cfg.WriteAttempts)
+		}
+		st = db
+	}
+
+	// Lowered length limits may leave stored data that tools can no longer rewrite
+	limits, err := server.SetLimits(server.Limits{
+		EntityName:   cfg.MaxEntityNameLength,
+		EntityType:   cfg.MaxEntityTypeLength,
+		RelationType: cfg.MaxRelationTypeLength,
+		Observation:  cfg.MaxObservationLength,
+		BatchSize:    cfg.MaxBatchSize,
+	})
+	if err != nil {
+		logger.Error("invalid validation limits",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	if db != nil && cfg.PolicyCheck != server.PolicyCheckOff {
+		report, err := server.CheckStoredData(ctx, db)
+		if err != nil {
+			logger.Error("failed to check stored data against validation policy",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+		if !report.Compatible {
+			attrs := []any{
+				slog.String("findings", report.Summary()),
+				slog.Int("max_observation_length", limits.Observation),
+				slog.Int("max_entity_name_length", limits.EntityName),
+			}
+			if cfg.PolicyCheck == server.PolicyCheckRefuse {
+				logger.Error("stored data breaks the validation policy; run migrate_to_policy with MEMORY_POLICY_CHECK=warn", attrs...)
+				db.Close()
+				return fmt.Errorf("stored data breaks the validation policy: %s", report.Summary())
+			}
+			logger.Warn("stored data breaks the validation policy; run migrate_to_policy to fix it", attrs...)
+		}
+	}
+
+	// Reads are served from a snapshot while long operations hold the database
+	var snapshots *database.SnapshotReads
+	if db != nil && cfg.SnapshotReads {
+		snapshots = database.NewSnapshotReads(db, "")
+	}
+
+	// Background maintenance runs off-hours, one job at a time
+	var scheduler *maintenance.Scheduler
+	if db != nil && cfg.MaintenanceSchedule != "" {
+		schedule, err := maintenance.ParseSchedule(cfg.MaintenanceSchedule)
+		if err != nil {
+			logger.Error("invalid maintenance configuration",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+		scheduler = maintenance.NewScheduler(schedule, db, logger.With(slog.String("component", "maintenance")))
+		scheduler.Register(maintenance.Job{Name: "expire_imports", Run: func(ctx context.Context) error {
+			_, err := db.ExpireImports(ctx, database.DefaultImportTTL)
+			return err
+		}})
+		if retention != nil {
+			scheduler.Register(maintenance.Job{Name: "retention", Run: func(ctx context.Context) error {
+				_, err := db.ApplyRetention(ctx, time.Now(), false)
+				return err
+			}})
+		}
+		if cfg.AuditRetention > 0 {
+			scheduler.Register(maintenance.Job{Name: "prune_audit_log", Run: func(ctx context.Context) error {
+				_, err := db.PruneAuditLog(ctx, cfg.AuditRetention)
+				return err
+			}})
+		}
+		scheduler.Register(maintenance.Job{Name: "optimize", Run: db.Optimize})
+		scheduler.Register(maintenance.Job{Name: "wal_checkpoint", Run: db.Checkpoint})
+		if snapshots != nil {
+			scheduler.SetWindowWrapper(func(ctx context.Context, window func(context.Context) error) error {
+				return snapshots.Run(ctx, "maintenance", window)
+			})
+		}
+	}
+
+	// Backups run on their own interval so a long maintenance window doesn't delay them
+	var backups *maintenance.Scheduler
+	if db != nil && cfg.BackupInterval > 0 {
+		backups = maintenance.NewScheduler(maintenance.Every(cfg.BackupInterval), db, logger.With(slog.String("component", "backup")))
+		backups.Register(maintenance.Job{Name: "backup", Run: func(ctx context.Context) error {
+			_, err := db.Backup(ctx, cfg.BackupDir, cfg.BackupKeep)
+			return err
+		}})
+	}
+
+	// Expired observations are hidden at once and deleted on their own interval
+	var sweeper *maintenance.Scheduler
+	if db != nil && cfg.ExpirySweepInterval > 0 {
+		sweeper = maintenance.NewScheduler(maintenance.Every(cfg.ExpirySweepInterval), db, logger.With(slog.String("component", "expiry")))
+		sweeper.Register(maintenance.Job{Name: "purge_expired_observations", Run: func(ctx context.Context) error {
+			_, err := db.PurgeExpiredObservations(ctx)
+			return err
+		}})
+	}
+
+	if db != nil && retention != nil && scheduler == nil {
+		logger.Warn("retention policy is not applied: MEMORY_MAINTENANCE_SCHEDULE is not set")
+	}
+
 	// Create the server with logger
 	srvLogger := logger.With(slog.String("component", "server"))
-	srv := server.NewServerWithLogger(db, srvLogger)
+	srv := server.NewServerWithOptions(st, srvLogger, server.Options{
+		ResultLinkThreshold: cfg.ResultLinkThreshold,
+		ResultTTL:           cfg.ResultTTL,
+		Maintenance:         scheduler,
+		Locale:              cfg.Locale,
+		SyncInterval:        cfg.SyncMinInterval,
+		SnapshotReads:       snapshots,
+		ReadOnly:            cfg.ReadOnly,
+		CompactJSON:         cfg.CompactJSON,
+	})
 
 	// Create MCP server with instructions about session management
 	instructions := `MCP Memory Server - Knowledge Graph with SQLite
@@ -96,23 +297,85 @@ This server provides a persistent knowledge graph with entities, relations, and
 
 Available tools:
 - create_entities: Create new entities with observations
-- create_relations: Create relations between entities
-- add_observations: Add observations to existing entities
-- delete_entities: Remove entities and their relations
+- create_relations: Create relations between entities, optionally with a confidence (0 to 1) and a note
+  on where they were learned; relations naming a missing entity are listed in skipped with the
+  reason, or fail the whole call with strict set; set updateExisting to update existing relations' properties
+- add_observations: Add observations to existing entities; skippedObservations lists those already known;
+  pass ttlSeconds or expiresAt for short-lived facts, which are hidden once they expire
+- delete_entities: Remove entities and their relations, optionally moving the relations to a successor entity
 - delete_observations: Remove specific observations
 - delete_relations: Remove specific relations
-- read_graph: Read the entire knowledge graph
-- search_nodes: Full-text search across entities and observations
-- open_nodes: Retrieve specific entities by name`
+  (pass dryRun to any delete tool to see what it would remove without removing it;
+  the result counts what was deleted and lists names that matched nothing)
+- list_deleted, restore_entities, purge_deleted: When the server keeps deleted entities in a trash,
+  list them, bring them back with their observations and relations, or delete them for good
+- get_history: List the changes made to the graph, oldest first, optionally for one entity or time range
+- create_snapshot, list_snapshots, diff_snapshot: Save the graph under a label at a milestone,
+  and later see the entities, observations and relations added and removed since
+- find_orphans: List the entities with no relations, optionally only those without observations
+  or not updated for olderThanDays; pass deleteOrphans to delete them in the same call
+- find_duplicates: Find clusters of entities that are likely the same thing, such as "VS Code" and "VSCode",
+  with their types and relation counts; pass threshold to also cluster similar names
+- pin_entities, unpin_entities: Pin foundation entities, such as "User Preferences", so delete_entities,
+  find_orphans and clear_graph keep them and list them in protected unless called with force
+- add_alias: Give an entity other names, such as "Bob" for "Robert Smith"; create_relations,
+  add_observations, open_nodes and search_nodes then accept the alias, and includeAliases lists them
+- read_graph: Read the entire knowledge graph, or page through it with limit and nextCursor when it is large;
+  pass detail "summary" to get only names, types and observation counts, and compact for unindented JSON
+  (both also on search_nodes and open_nodes)
+- search_nodes: Full-text search across entities and observations; pass limit to page through broad queries, or mode "exact" or "prefix" to look up entities by name
+- open_nodes: Retrieve specific entities by name; pass includeExternalRelations "incoming" to also see who points at them
+- add_tags, remove_tags: Label entities with tags such as "important" or "source:slack";
+  pass tags to search_nodes or open_nodes to return only the entities carrying all of them
+- update_entities: Set structured attributes, such as URLs, scores or external IDs, on entities
+  instead of writing them as observations; pass searchAttributes to search_nodes to search them
+- get_entity: Get one entity with its observations and relations, or found: false when it doesn't exist
+- recent_entities: List the entities updated most recently, e.g. to see what was learned lately
+- get_observations: Page through an entity's observations when totalObservations exceeds those returned
+- get_inbound_relations, get_outbound_relations: Page through the relations pointing to or from one entity, optionally of one type
+- get_relations: Page through the relations matching any of from, to and relationType
+- find_path: Find the shortest chain of relations connecting two entities, optionally following relations only forwards
+- get_neighbors: Explore outward from entities, up to 3 relations deep, getting the subgraph around them
+- get_maintenance_status: Show the maintenance schedule and last job results
+- erase_subject: Permanently erase everything mentioning a person (run with dryRun first)
+- clear_graph: Delete the whole memory; only when the user explicitly asks, with confirm set to "DELETE EVERYTHING"
+- migrate_to_policy: Split, clean and rename stored values that break the active length limits or validation rules (run with dryRun first)
+- preview_retention: Show what the retention policy would purge or archive now, and the last time maintenance applied it
+- import_begin, import_chunk, import_commit, import_abort: Import a JSONL graph too large for one request in sequenced chunks
+- export_graph: Export the whole graph as one portable JSON document, e.g. to back it up or move it to another server, or as Graphviz DOT or GraphML, optionally only around a root entity, to view it
+- import_graph: Import an export_graph document, or part of one, skipping, merging or replacing entities that already exist
+- list_entity_types: List the entity types in use with their entity counts, optionally by prefix
+- list_relation_types: List the relation types in use with their relation counts, optionally only those used at least minCount times
+- set_type_metadata, get_type_metadata: Set and read per entity type metadata, such as color and group hints for graph exports
+- get_relation_constraints: List the rules create_relations enforces, such as no self-relations or at most one relation of a type per entity
+- list_sessions, rollback_session: List the session labels passed to write tools and undo everything written under one
+- sync_memory: Make all writes so far durable before you persist state that depends on them
+- memory_hygiene_report: Find empty, stale, duplicate and oversized entities to clean up, with suggested follow-up calls
+- get_validation_stats: Count calls rejected by input validation, by rule
+- check_integrity: Check that the database is healthy, e.g. after an unclean shutdown; slow on a large database
+- graph_stats: Count entities, relations, observations and types and report the database size, e.g. before calling read_graph
+- graph_hotspots: List the most connected entities of the graph, ranked by relations to them, from them or both
+- get_capabilities: Show which optional features and limits this server supports
+- list_graphs: List the named graphs; pass graph to the core and entity tools to keep each project's memory
+  in its own graph, where the same entity name can be reused`
+
+	if cfg.ReadOnly {
+		instructions += `
+
+This server is read-only: only the tools that read the graph are available, and
+nothing you learn can be stored.`
+	}
 
 	// Add HTTP-specific instructions when running in HTTP mode
 	if *httpAddr != "" {
 		instructions += `
 
 HTTP Transport Endpoints:
-- GET /: Server info and available endpoints
+- GET /: Server info, available endpoints and capabilities
 - GET /healthz: Health check
-- GET /readyz: Readiness check
+- GET /readyz: Readiness check; 503 when the database doesn't answer
+- GET /status: Maintenance schedule, last job results and runtime stats
+- GET /openapi.json: OpenAPI description of the HTTP endpoints
 - POST /mcp/stream: MCP Streamable HTTP (this endpoint)`
 
 		if *sseMode {
@@ -127,6 +390,14 @@ Session Management (HTTP):
 2. Include Mcp-Session-Id header in ALL subsequent requests
 3. Send "notifications/initialized" to complete initialization
 4. Tool calls require completed initialization and session ID`
+
+		if cfg.NamespaceHeader != "" {
+			instructions += `
+
+This server may bind a connection to one graph. Your memory is then kept in that
+graph without passing the graph argument, and tools that work on the whole database
+fail with graph_unsupported.`
+		}
 	}
 
 	mcpOptions := &mcp.ServerOptions{
@@ -144,6 +415,18 @@ Session Management (HTTP):
 	// Register all tools
 	srv.RegisterTools(mcpServer)
 
+	maintenanceCtx, stopMaintenance := context.WithCancel(ctx)
+	defer stopMaintenance()
+	if scheduler != nil {
+		scheduler.Start(maintenanceCtx)
+	}
+	if backups != nil {
+		backups.Start(maintenanceCtx)
+	}
+	if sweeper != nil {
+		sweeper.Start(maintenanceCtx)
+	}
+
 	// Channel to listen for interrupt signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -155,7 +438,7 @@ Session Management (HTTP):
 	// Start the appropriate server based on flags
 	if *httpAddr != "" {
 		var err error
-		httpServer, err = startHTTPServer(logger, mcpServer, done)
+		httpServer, err = startHTTPServer(logger, mcpServer, srv, db, scheduler, cfg, done)
 		if err != nil {
 			return err
 		}
@@ -176,6 +459,18 @@ Session Management (HTTP):
 		)
 	}
 
+	// Stop maintenance before the database closes; running jobs see the cancellation
+	stopMaintenance()
+	if scheduler != nil {
+		scheduler.Wait()
+	}
+	if backups != nil {
+		backups.Wait()
+	}
+	if sweeper != nil {
+		sweeper.Wait()
+	}
+
 	// Perform graceful shutdown
 	shutdown(logger, httpServer, srv)
 
@@ -200,12 +495,60 @@ func shutdown(logger *slog.Logger, httpServer *http.Server, srv *server.Server)
 
 }
 
-func startHTTPServer(logger *slog.Logger, mcpServer *mcp.Server, done chan<- error) (*http.Server, error) {
+func startHTTPServer(logger *slog.Logger, mcpServer *mcp.Server, srv *server.Server, db *database.DB, scheduler *maintenance.Scheduler, cfg *config.Config, done chan<- error) (*http.Server, error) {
 	routerCfg := &router.RouterConfig{
 		EnableSSE:    *sseMode,
 		EnableStream: true, // Always enable stream endpoint in HTTP mode
 		McpName:      MCP_NAME,
 		McpVersion:   VERSION,
+		// Each SSE message is one event; results are sized to fit it
+		SSEContext: func(ctx context.Context) context.Context {
+			return server.WithEventLimit(ctx, cfg.SSEMaxEventBytes)
+		},
+		Status: func(ctx context.Context) (any, error) {
+			status, err := scheduler.Status(ctx)
+			return map[string]any{
+				"maintenance": status,
+				"validation":  srv.ValidationStats(),
+				"runtime":     router.ReadRuntimeStats(),
+			}, err
+		},
+		Capabilities: func(ctx context.Context) any {
+			return srv.Capabilities()
+		},
+		APIToken:        cfg.APIToken,
+		CompareResponse: database.CompareResult{},
+		EnablePprof:     cfg.EnablePprof,
+		ReadOnly:        cfg.ReadOnly,
+	}
+	if cfg.NamespaceHeader != "" {
+		routerCfg.Namespace = router.HeaderNamespace(cfg.NamespaceHeader)
+	}
+	// Stats, /compare and the exports read the SQLite database
+	if db != nil {
+		routerCfg.Ready = db.Ready
+		routerCfg.Stats = func(ctx context.Context) (any, error) {
+			return db.Stats(ctx)
+		}
+		routerCfg.Compare = func(ctx context.Context, snapshot io.Reader, opts router.CompareOptions) (any, error) {
+			result, err := db.CompareSnapshot(ctx, snapshot, database.CompareOptions{
+				DiffOptions: database.DiffOptions{
+					IgnoreObservationOrder: opts.IgnoreObservationOrder,
+					IgnoreEntityTypes:      opts.IgnoreEntityTypes,
+				},
+				FailOnMissingEntities: opts.FailOnMissingEntities,
+			})
+			var lineErr *database.ImportLineError
+			if errors.As(err, &lineErr) {
+				return nil, fmt.Errorf("%w: %w", router.ErrInvalidSnapshot, err)
+			}
+			return result, err
+		}
+		routerCfg.ExportDOT = db.ExportDOT
+		routerCfg.ExportJSONL = db.ExportJSONL
+	}
+	if cfg.EnablePprof && cfg.APIToken == "" {
+		logger.Warn("MEMORY_ENABLE_PPROF ignored: the profiling endpoints require MEMORY_API_TOKEN")
 	}
 	handler := router.NewRouter(mcpServer, logger, routerCfg)
 	httpServer := &http.Server{Addr: *httpAddr, Handler: handler}
@@ -245,3 +588,27 @@ func startStdioServer(ctx context.Context, logger *slog.Logger, mcpServer *mcp.S
 		}
 	}()
 }
+
+// loadRetentionPolicy reads the retention policy file, if any
+func loadRetentionPolicy(path string) (*database.RetentionPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return database.ParseRetentionPolicy(data)
+}
+
+// loadRelationConstraints reads the relation constraints file, if any
+func loadRelationConstraints(path string) (database.RelationConstraints, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return database.ParseRelationConstraints(data)
+}
@@ -1,18 +1,137 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
 )
 
 type Config struct {
-	DBPath string
+	// DBDriver is where the graph is kept: "sqlite" (default), in DBPath;
+	// "postgres", at DBDSN; or "memory", which loses it when the server exits
+	DBDriver string
+	DBPath   string
+	// DBDSN is the connection string of the postgres driver
+	DBDSN string
+	// RedactPatterns are extra regular expressions masked in log output,
+	// in addition to logging.DefaultRedactPatterns
+	RedactPatterns []string
+	// ResultLinkThreshold is the result size in bytes above which large tool results
+	// are returned as resource links (0 disables)
+	ResultLinkThreshold int
+	// ResultTTL is how long linked results stay readable (0 uses the server default)
+	ResultTTL time.Duration
+	// MaxObservationsPerEntity caps the observations read paths return per entity
+	// (0 = unlimited, -1 = use the database default)
+	MaxObservationsPerEntity int
+	// MaxStoredObservationsPerEntity caps the observations an entity may store (0 =
+	// unlimited), and ObservationEviction is what add_observations does when adding
+	// would exceed it: "reject" (default) or delete the "oldest"
+	MaxStoredObservationsPerEntity int
+	ObservationEviction            string
+	// SoftDelete makes delete_entities move entities to a trash they can be restored
+	// from until purged, instead of deleting them at once
+	SoftDelete bool
+	// MaxSnapshots is how many labeled snapshots create_snapshot keeps per graph, and
+	// MaxSnapshotMB the largest compressed snapshot it stores
+	MaxSnapshots  int
+	MaxSnapshotMB int
+	// MaintenanceSchedule is when background maintenance runs, e.g. "03:00" or
+	// "every 6h" (empty disables maintenance)
+	MaintenanceSchedule string
+	// Locale is the default language for messages sent to clients
+	Locale string
+	// APIToken is the bearer token for authenticated HTTP endpoints such as
+	// /compare (empty disables them)
+	APIToken string
+	// NamespaceHeader is the HTTP header, such as X-Memory-Namespace, naming the
+	// graph each HTTP client is bound to (empty binds none)
+	NamespaceHeader string
+	// SyncMinInterval is the minimum time between sync_memory calls (0 uses the
+	// server default)
+	SyncMinInterval time.Duration
+	// SnapshotReads serves reads from a snapshot of the database while maintenance
+	// windows and import commits run
+	SnapshotReads bool
+	// RelationConstraintsFile is a JSON file of rules create_relations enforces per
+	// relation type (empty = no constraints)
+	RelationConstraintsFile string
+	// RetentionPolicyFile is a JSON file of rules after which maintenance purges or
+	// archives old observations (empty = keep everything)
+	RetentionPolicyFile string
+	// AuditRetention is how long maintenance keeps audit log entries (default 90
+	// days, 0 = forever)
+	AuditRetention time.Duration
+	// ExpirySweepInterval is how often observations past their expiry are deleted
+	// (default 5 minutes, 0 = never; they stay hidden from reads)
+	ExpirySweepInterval time.Duration
+	// AdjacencyCache keeps the relations in memory for find_path and get_neighbors
+	AdjacencyCache bool
+	// AdjacencyCacheMaxMB is the estimated size above which the adjacency cache is
+	// not built and traversals query the database
+	AdjacencyCacheMaxMB int
+	// WriteAttempts is how many times a write is tried while the database is busy
+	// (0 uses the database default)
+	WriteAttempts int
+	// MaxEntityNameLength, MaxEntityTypeLength, MaxRelationTypeLength and
+	// MaxObservationLength set the validation length limits, and MaxBatchSize the
+	// most entities, relations or names per request (0 keeps the default)
+	MaxEntityNameLength   int
+	MaxEntityTypeLength   int
+	MaxRelationTypeLength int
+	MaxObservationLength  int
+	MaxBatchSize          int
+	// PolicyCheck is what happens at startup when stored data breaks the validation
+	// limits: "warn" (default), "refuse" to start, or "off"
+	PolicyCheck string
+	// SSEMaxEventBytes is the largest event sent on the SSE endpoint (0 = no limit)
+	SSEMaxEventBytes int
+	// BackupInterval is how often a copy of the database is written to BackupDir
+	// (0 disables backups)
+	BackupInterval time.Duration
+	// BackupDir is where backups are written (default: "backups" next to DBPath)
+	BackupDir string
+	// BackupKeep is how many backups are kept, oldest deleted first (0 = all)
+	BackupKeep int
+	// EnablePprof mounts the net/http/pprof handlers in HTTP mode, behind APIToken
+	EnablePprof bool
+	// ReadOnly serves only the tools that read the graph, hiding those that change it
+	ReadOnly bool
+	// CompactJSON encodes tool results without indentation
+	CompactJSON bool
 }
 
 // Load loads configuration from environment variables with defaults
 func Load() (*Config, error) {
 	cfg := &Config{}
 
+	// Storage backend; MEMORY_BACKEND is its deprecated name, read when
+	// MEMORY_DB_DRIVER is unset
+	cfg.DBDriver = "sqlite"
+	driverVar := "MEMORY_DB_DRIVER"
+	v := strings.TrimSpace(os.Getenv(driverVar))
+	if v == "" {
+		driverVar = "MEMORY_BACKEND"
+		v = strings.TrimSpace(os.Getenv(driverVar))
+	}
+	if v != "" {
+		switch v = strings.ToLower(v); v {
+		case "sqlite", "postgres", "memory":
+			cfg.DBDriver = v
+		default:
+			return nil, fmt.Errorf("invalid %s %q: must be sqlite, postgres or memory", driverVar, v)
+		}
+	}
+	cfg.DBDSN = strings.TrimSpace(os.Getenv("MEMORY_DB_DSN"))
+	if cfg.DBDriver == "postgres" && cfg.DBDSN == "" {
+		return nil, fmt.Errorf("%s postgres needs MEMORY_DB_DSN", driverVar)
+	}
+
 	// Database path configuration
 	cfg.DBPath = os.Getenv("MEMORY_DB_PATH")
 	if cfg.DBPath == "" {
@@ -23,5 +142,208 @@ func Load() (*Config, error) {
 		cfg.DBPath = filepath.Join(homeDir, ".mcp-memory", "memory.db")
 	}
 
+	// Log redaction patterns, separated by semicolons
+	cfg.RedactPatterns = splitList(os.Getenv("MEMORY_REDACT_PATTERNS"), ";")
+
+	// Resource links for large results
+	var err error
+	if cfg.ResultLinkThreshold, err = intEnv("MEMORY_RESULT_LINK_THRESHOLD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ResultTTL, err = durationEnv("MEMORY_RESULT_TTL", 0); err != nil {
+		return nil, err
+	}
+
+	// Observation cap on read paths
+	if cfg.MaxObservationsPerEntity, err = intEnv("MEMORY_MAX_OBSERVATIONS_PER_ENTITY", -1); err != nil {
+		return nil, err
+	}
+
+	// Observation cap on storage
+	if cfg.MaxStoredObservationsPerEntity, err = intEnv("MEMORY_MAX_STORED_OBSERVATIONS_PER_ENTITY", 0); err != nil {
+		return nil, err
+	}
+	cfg.ObservationEviction = "reject"
+	if v := strings.TrimSpace(os.Getenv("MEMORY_OBSERVATION_EVICTION")); v != "" {
+		switch v = strings.ToLower(v); v {
+		case "reject", "oldest":
+			cfg.ObservationEviction = v
+		default:
+			return nil, fmt.Errorf("invalid MEMORY_OBSERVATION_EVICTION %q: must be reject or oldest", v)
+		}
+	}
+
+	// Maintenance window
+	cfg.MaintenanceSchedule = strings.TrimSpace(os.Getenv("MEMORY_MAINTENANCE_SCHEDULE"))
+
+	// Message locale
+	cfg.Locale = i18n.DefaultLocale
+	if v := os.Getenv("MEMORY_LOCALE"); v != "" {
+		if cfg.Locale = i18n.Match(v); cfg.Locale == "" {
+			return nil, fmt.Errorf("unsupported MEMORY_LOCALE %q", v)
+		}
+	}
+
+	// Token for authenticated HTTP endpoints
+	cfg.APIToken = strings.TrimSpace(os.Getenv("MEMORY_API_TOKEN"))
+
+	// Header binding HTTP clients to a graph
+	cfg.NamespaceHeader = strings.TrimSpace(os.Getenv("MEMORY_NAMESPACE_HEADER"))
+
+	// Rate limit of sync_memory
+	if cfg.SyncMinInterval, err = durationEnv("MEMORY_SYNC_MIN_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+
+	// Structural rules for relations
+	cfg.RelationConstraintsFile = strings.TrimSpace(os.Getenv("MEMORY_RELATION_CONSTRAINTS"))
+
+	// Retention rules applied by maintenance
+	cfg.RetentionPolicyFile = strings.TrimSpace(os.Getenv("MEMORY_RETENTION_POLICY"))
+	if cfg.AuditRetention, err = durationEnv("MEMORY_AUDIT_RETENTION", 90*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.ExpirySweepInterval, err = durationEnv("MEMORY_EXPIRY_SWEEP_INTERVAL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+
+	// Reads from a snapshot during long operations
+	if cfg.SnapshotReads, err = boolEnv("MEMORY_SNAPSHOT_READS", false); err != nil {
+		return nil, err
+	}
+
+	// In-memory relations for traversals
+	if cfg.AdjacencyCache, err = boolEnv("MEMORY_ADJACENCY_CACHE", false); err != nil {
+		return nil, err
+	}
+	if cfg.AdjacencyCacheMaxMB, err = intEnv("MEMORY_ADJACENCY_CACHE_MAX_MB", 256); err != nil {
+		return nil, err
+	}
+
+	// Retries of writes that find the database busy
+	if cfg.WriteAttempts, err = intEnv("MEMORY_WRITE_ATTEMPTS", 0); err != nil {
+		return nil, err
+	}
+
+	// Validation limits and the startup check of stored data against them
+	for _, limit := range []struct {
+		key   string
+		value *int
+	}{
+		{"MEMORY_MAX_ENTITY_NAME_LENGTH", &cfg.MaxEntityNameLength},
+		{"MEMORY_MAX_ENTITY_TYPE_LENGTH", &cfg.MaxEntityTypeLength},
+		{"MEMORY_MAX_RELATION_TYPE_LENGTH", &cfg.MaxRelationTypeLength},
+		{"MEMORY_MAX_OBSERVATION_LENGTH", &cfg.MaxObservationLength},
+		{"MEMORY_MAX_BATCH_SIZE", &cfg.MaxBatchSize},
+	} {
+		if *limit.value, err = intEnv(limit.key, 0); err != nil {
+			return nil, err
+		}
+	}
+	cfg.PolicyCheck = "warn"
+	if v := strings.TrimSpace(os.Getenv("MEMORY_POLICY_CHECK")); v != "" {
+		switch v = strings.ToLower(v); v {
+		case "warn", "refuse", "off":
+			cfg.PolicyCheck = v
+		default:
+			return nil, fmt.Errorf("invalid MEMORY_POLICY_CHECK %q: must be warn, refuse or off", v)
+		}
+	}
+
+	// Size of one SSE event, which some EventSource clients cut at 1 MiB
+	if cfg.SSEMaxEventBytes, err = intEnv("MEMORY_SSE_MAX_EVENT_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+
+	// Scheduled backups
+	if cfg.BackupInterval, err = durationEnv("MEMORY_BACKUP_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	cfg.BackupDir = strings.TrimSpace(os.Getenv("MEMORY_BACKUP_DIR"))
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = filepath.Join(filepath.Dir(cfg.DBPath), "backups")
+	}
+	if cfg.BackupKeep, err = intEnv("MEMORY_BACKUP_KEEP", 7); err != nil {
+		return nil, err
+	}
+
+	// Profiling endpoints
+	if cfg.EnablePprof, err = boolEnv("MEMORY_ENABLE_PPROF", false); err != nil {
+		return nil, err
+	}
+
+	// Soft delete
+	if cfg.SoftDelete, err = boolEnv("MEMORY_SOFT_DELETE", false); err != nil {
+		return nil, err
+	}
+
+	// Labeled graph snapshots
+	if cfg.MaxSnapshots, err = intEnv("MEMORY_MAX_SNAPSHOTS", 20); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSnapshotMB, err = intEnv("MEMORY_MAX_SNAPSHOT_MB", 32); err != nil {
+		return nil, err
+	}
+
+	// Read-only mode
+	if cfg.ReadOnly, err = boolEnv("MEMORY_READ_ONLY", false); err != nil {
+		return nil, err
+	}
+
+	// Result encoding
+	if cfg.CompactJSON, err = boolEnv("MEMORY_COMPACT_JSON", false); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// intEnv reads a non-negative integer env var, returning def when unset
+func intEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", key, value)
+	}
+	return n, nil
+}
+
+// durationEnv reads a non-negative Go duration env var (e.g. "10m"), returning def when unset
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative duration", key, value)
+	}
+	return d, nil
+}
+
+// boolEnv reads a boolean env var such as "true" or "0", returning def when unset
+func boolEnv(key string, def bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", key, value)
+	}
+	return b, nil
+}
+
+// splitList splits a separated env value, dropping empty entries
+func splitList(value, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(value, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +21,403 @@ func TestLoad(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "/tmp/test.db", cfg.DBPath)
 }
+
+func TestLoad_RedactPatterns(t *testing.T) {
+	os.Unsetenv("MEMORY_REDACT_PATTERNS")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.RedactPatterns)
+
+	os.Setenv("MEMORY_REDACT_PATTERNS", `internal-[0-9]+; ;corp_[a-z]{4}`)
+	defer os.Unsetenv("MEMORY_REDACT_PATTERNS")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`internal-[0-9]+`, `corp_[a-z]{4}`}, cfg.RedactPatterns)
+}
+
+func TestLoad_ResultLinks(t *testing.T) {
+	os.Setenv("MEMORY_RESULT_LINK_THRESHOLD", "65536")
+	os.Setenv("MEMORY_RESULT_TTL", "2m")
+	defer os.Unsetenv("MEMORY_RESULT_LINK_THRESHOLD")
+	defer os.Unsetenv("MEMORY_RESULT_TTL")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 65536, cfg.ResultLinkThreshold)
+	assert.Equal(t, 2*time.Minute, cfg.ResultTTL)
+
+	os.Setenv("MEMORY_RESULT_TTL", "soon")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_MaxObservationsPerEntity(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, -1, cfg.MaxObservationsPerEntity)
+
+	os.Setenv("MEMORY_MAX_OBSERVATIONS_PER_ENTITY", "0")
+	defer os.Unsetenv("MEMORY_MAX_OBSERVATIONS_PER_ENTITY")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cfg.MaxObservationsPerEntity)
+}
+
+func TestLoad_Locale(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "en", cfg.Locale)
+
+	os.Setenv("MEMORY_LOCALE", "es-AR")
+	defer os.Unsetenv("MEMORY_LOCALE")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "es", cfg.Locale)
+
+	os.Setenv("MEMORY_LOCALE", "tlh")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_SyncMinInterval(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.SyncMinInterval)
+
+	os.Setenv("MEMORY_SYNC_MIN_INTERVAL", "30s")
+	defer os.Unsetenv("MEMORY_SYNC_MIN_INTERVAL")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.SyncMinInterval)
+
+	os.Setenv("MEMORY_SYNC_MIN_INTERVAL", "-1s")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_SnapshotReads(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.SnapshotReads)
+
+	os.Setenv("MEMORY_SNAPSHOT_READS", "true")
+	defer os.Unsetenv("MEMORY_SNAPSHOT_READS")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.SnapshotReads)
+
+	os.Setenv("MEMORY_SNAPSHOT_READS", "sometimes")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_AdjacencyCache(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.AdjacencyCache)
+	assert.Equal(t, 256, cfg.AdjacencyCacheMaxMB)
+
+	os.Setenv("MEMORY_ADJACENCY_CACHE", "true")
+	defer os.Unsetenv("MEMORY_ADJACENCY_CACHE")
+	os.Setenv("MEMORY_ADJACENCY_CACHE_MAX_MB", "64")
+	defer os.Unsetenv("MEMORY_ADJACENCY_CACHE_MAX_MB")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.AdjacencyCache)
+	assert.Equal(t, 64, cfg.AdjacencyCacheMaxMB)
+
+	os.Setenv("MEMORY_ADJACENCY_CACHE_MAX_MB", "-1")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_WriteAttempts(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.WriteAttempts)
+
+	os.Setenv("MEMORY_WRITE_ATTEMPTS", "8")
+	defer os.Unsetenv("MEMORY_WRITE_ATTEMPTS")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 8, cfg.WriteAttempts)
+
+	os.Setenv("MEMORY_WRITE_ATTEMPTS", "-1")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_ValidationPolicy(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.MaxObservationLength)
+	assert.Equal(t, "warn", cfg.PolicyCheck)
+
+	os.Setenv("MEMORY_MAX_OBSERVATION_LENGTH", "2000")
+	defer os.Unsetenv("MEMORY_MAX_OBSERVATION_LENGTH")
+	os.Setenv("MEMORY_MAX_ENTITY_NAME_LENGTH", "100")
+	defer os.Unsetenv("MEMORY_MAX_ENTITY_NAME_LENGTH")
+	os.Setenv("MEMORY_MAX_BATCH_SIZE", "50")
+	defer os.Unsetenv("MEMORY_MAX_BATCH_SIZE")
+	os.Setenv("MEMORY_POLICY_CHECK", "Refuse")
+	defer os.Unsetenv("MEMORY_POLICY_CHECK")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, cfg.MaxObservationLength)
+	assert.Equal(t, 50, cfg.MaxBatchSize)
+	assert.Equal(t, 100, cfg.MaxEntityNameLength)
+	assert.Zero(t, cfg.MaxEntityTypeLength)
+	assert.Equal(t, "refuse", cfg.PolicyCheck)
+
+	os.Setenv("MEMORY_POLICY_CHECK", "ignore")
+	_, err = Load()
+	assert.Error(t, err)
+
+	os.Setenv("MEMORY_POLICY_CHECK", "off")
+	os.Setenv("MEMORY_MAX_OBSERVATION_LENGTH", "short")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_SSEMaxEventBytes(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 1<<20, cfg.SSEMaxEventBytes)
+
+	os.Setenv("MEMORY_SSE_MAX_EVENT_BYTES", "0")
+	defer os.Unsetenv("MEMORY_SSE_MAX_EVENT_BYTES")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.SSEMaxEventBytes)
+
+	os.Setenv("MEMORY_SSE_MAX_EVENT_BYTES", "1MB")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_EnablePprof(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.EnablePprof)
+
+	os.Setenv("MEMORY_ENABLE_PPROF", "true")
+	defer os.Unsetenv("MEMORY_ENABLE_PPROF")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.EnablePprof)
+
+	os.Setenv("MEMORY_ENABLE_PPROF", "sometimes")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_NamespaceHeader(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.NamespaceHeader)
+
+	os.Setenv("MEMORY_NAMESPACE_HEADER", " X-Memory-Namespace ")
+	defer os.Unsetenv("MEMORY_NAMESPACE_HEADER")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "X-Memory-Namespace", cfg.NamespaceHeader)
+}
+
+func TestLoad_ReadOnly(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.ReadOnly)
+
+	os.Setenv("MEMORY_READ_ONLY", "true")
+	defer os.Unsetenv("MEMORY_READ_ONLY")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.ReadOnly)
+
+	os.Setenv("MEMORY_READ_ONLY", "maybe")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_CompactJSON(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.CompactJSON)
+
+	os.Setenv("MEMORY_COMPACT_JSON", "true")
+	defer os.Unsetenv("MEMORY_COMPACT_JSON")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.CompactJSON)
+
+	os.Setenv("MEMORY_COMPACT_JSON", "yes please")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_SoftDelete(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.False(t, cfg.SoftDelete, "hard deletes by default")
+
+	os.Setenv("MEMORY_SOFT_DELETE", "true")
+	defer os.Unsetenv("MEMORY_SOFT_DELETE")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.True(t, cfg.SoftDelete)
+}
+
+func TestLoad_SnapshotLimits(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 20, cfg.MaxSnapshots)
+	assert.Equal(t, 32, cfg.MaxSnapshotMB)
+
+	os.Setenv("MEMORY_MAX_SNAPSHOTS", "5")
+	defer os.Unsetenv("MEMORY_MAX_SNAPSHOTS")
+	os.Setenv("MEMORY_MAX_SNAPSHOT_MB", "8")
+	defer os.Unsetenv("MEMORY_MAX_SNAPSHOT_MB")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.MaxSnapshots)
+	assert.Equal(t, 8, cfg.MaxSnapshotMB)
+
+	os.Setenv("MEMORY_MAX_SNAPSHOTS", "many")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_AuditRetention(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, cfg.AuditRetention)
+
+	os.Setenv("MEMORY_AUDIT_RETENTION", "0")
+	defer os.Unsetenv("MEMORY_AUDIT_RETENTION")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.AuditRetention, "0 keeps the audit log forever")
+
+	os.Setenv("MEMORY_AUDIT_RETENTION", "-1h")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_ObservationCap(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.MaxStoredObservationsPerEntity, "unlimited by default")
+	assert.Equal(t, "reject", cfg.ObservationEviction)
+
+	os.Setenv("MEMORY_MAX_STORED_OBSERVATIONS_PER_ENTITY", "500")
+	defer os.Unsetenv("MEMORY_MAX_STORED_OBSERVATIONS_PER_ENTITY")
+	os.Setenv("MEMORY_OBSERVATION_EVICTION", " Oldest ")
+	defer os.Unsetenv("MEMORY_OBSERVATION_EVICTION")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 500, cfg.MaxStoredObservationsPerEntity)
+	assert.Equal(t, "oldest", cfg.ObservationEviction)
+
+	os.Setenv("MEMORY_OBSERVATION_EVICTION", "newest")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_RetentionPolicyFile(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.RetentionPolicyFile)
+
+	os.Setenv("MEMORY_RETENTION_POLICY", " /etc/memory/retention.json ")
+	defer os.Unsetenv("MEMORY_RETENTION_POLICY")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/memory/retention.json", cfg.RetentionPolicyFile)
+}
+
+func TestLoad_DBDriver(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", cfg.DBDriver)
+
+	os.Setenv("MEMORY_DB_DRIVER", "Memory")
+	defer os.Unsetenv("MEMORY_DB_DRIVER")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "memory", cfg.DBDriver)
+
+	os.Setenv("MEMORY_DB_DRIVER", "postgres")
+	_, err = Load()
+	assert.Error(t, err, "postgres needs a DSN")
+	os.Setenv("MEMORY_DB_DSN", "postgres://memory@db/memory")
+	defer os.Unsetenv("MEMORY_DB_DSN")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.DBDriver)
+	assert.Equal(t, "postgres://memory@db/memory", cfg.DBDSN)
+
+	os.Setenv("MEMORY_DB_DRIVER", "mysql")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_DeprecatedBackend(t *testing.T) {
+	os.Setenv("MEMORY_BACKEND", "Memory")
+	defer os.Unsetenv("MEMORY_BACKEND")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "memory", cfg.DBDriver, "MEMORY_BACKEND still selects the driver")
+
+	os.Setenv("MEMORY_DB_DRIVER", "sqlite")
+	defer os.Unsetenv("MEMORY_DB_DRIVER")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", cfg.DBDriver, "MEMORY_DB_DRIVER wins")
+
+	os.Unsetenv("MEMORY_DB_DRIVER")
+	os.Setenv("MEMORY_BACKEND", "mysql")
+	_, err = Load()
+	assert.ErrorContains(t, err, "MEMORY_BACKEND")
+}
+
+func TestLoad_ExpirySweepInterval(t *testing.T) {
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cfg.ExpirySweepInterval)
+
+	os.Setenv("MEMORY_EXPIRY_SWEEP_INTERVAL", "30s")
+	defer os.Unsetenv("MEMORY_EXPIRY_SWEEP_INTERVAL")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.ExpirySweepInterval)
+
+	os.Setenv("MEMORY_EXPIRY_SWEEP_INTERVAL", "0")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.ExpirySweepInterval, "0 disables the sweeper")
+}
+
+func TestLoad_Backup(t *testing.T) {
+	os.Setenv("MEMORY_DB_PATH", "/var/lib/memory/memory.db")
+	defer os.Unsetenv("MEMORY_DB_PATH")
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.BackupInterval)
+	assert.Equal(t, "/var/lib/memory/backups", cfg.BackupDir)
+	assert.Equal(t, 7, cfg.BackupKeep)
+
+	os.Setenv("MEMORY_BACKUP_INTERVAL", "6h")
+	defer os.Unsetenv("MEMORY_BACKUP_INTERVAL")
+	os.Setenv("MEMORY_BACKUP_DIR", "/backups/memory")
+	defer os.Unsetenv("MEMORY_BACKUP_DIR")
+	os.Setenv("MEMORY_BACKUP_KEEP", "3")
+	defer os.Unsetenv("MEMORY_BACKUP_KEEP")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 6*time.Hour, cfg.BackupInterval)
+	assert.Equal(t, "/backups/memory", cfg.BackupDir)
+	assert.Equal(t, 3, cfg.BackupKeep)
+
+	os.Setenv("MEMORY_BACKUP_KEEP", "-1")
+	_, err = Load()
+	assert.Error(t, err)
+}
@@ -0,0 +1,640 @@
+package i18n
+
+// Message IDs. These are part of the API: clients match on them, so never rename one.
+const (
+	// Tool results
+	MsgEntitiesDeleted     = "entities_deleted"
+	MsgObservationsDeleted = "observations_deleted"
+	MsgRelationsDeleted    = "relations_deleted"
+	MsgResultTooLarge      = "result_too_large"
+	MsgImportAborted       = "import_aborted"
+	MsgServedFromSnapshot  = "served_from_snapshot"
+
+	// Relation constraint violations, listed in ErrRelationConstraint
+	MsgConstraintAllowSelf   = "constraint_allow_self"
+	MsgConstraintMaxOutgoing = "constraint_max_outgoing"
+	MsgConstraintMaxIncoming = "constraint_max_incoming"
+
+	// Missing relation endpoints, listed in ErrRelationEndpointsMissing
+	MsgRelationEndpointMissing = "relation_endpoint_missing"
+
+	// Tool errors
+	ErrValidation           = "validation_error"
+	ErrOperationCancelled   = "operation_cancelled"
+	ErrCreateEntities       = "create_entities_failed"
+	ErrCreateRelations      = "create_relations_failed"
+	ErrAddObservations      = "add_observations_failed"
+	ErrDeleteEntities       = "delete_entities_failed"
+	ErrDeleteObservations   = "delete_observations_failed"
+	ErrDeleteRelations      = "delete_relations_failed"
+	ErrReadGraph            = "read_graph_failed"
+	ErrSearchNodes          = "search_nodes_failed"
+	ErrOpenNodes            = "open_nodes_failed"
+	ErrGetObservations      = "get_observations_failed"
+	ErrFindPath             = "find_path_failed"
+	ErrGetMaintenanceStatus = "get_maintenance_status_failed"
+	ErrStoreResult          = "store_result_failed"
+	ErrEncodeResult         = "encode_result_failed"
+	ErrEraseSubject         = "erase_subject_failed"
+	ErrImportBegin          = "import_begin_failed"
+	ErrImportChunk          = "import_chunk_failed"
+	ErrImportCommit         = "import_commit_failed"
+	ErrImportAbort          = "import_abort_failed"
+	ErrSetTypeMetadata      = "set_type_metadata_failed"
+	ErrGetTypeMetadata      = "get_type_metadata_failed"
+	ErrSyncMemory           = "sync_memory_failed"
+	ErrSyncRateLimited      = "sync_rate_limited"
+	ErrHygieneReport        = "memory_hygiene_report_failed"
+	ErrRelationConstraint   = "relation_constraint_violated"
+	ErrListSessions         = "list_sessions_failed"
+	ErrRollbackSession      = "rollback_session_failed"
+	ErrMigrateToPolicy      = "migrate_to_policy_failed"
+	ErrEventTooLarge        = "result_exceeds_event_limit"
+	ErrPreviewRetention     = "preview_retention_failed"
+	ErrGetRelations         = "get_relations_failed"
+	ErrGetEntity            = "get_entity_failed"
+	ErrListEntityTypes      = "list_entity_types_failed"
+	ErrListRelationTypes    = "list_relation_types_failed"
+	ErrGetNeighbors         = "get_neighbors_failed"
+	ErrClearGraph           = "clear_graph_failed"
+	ErrGraphStats           = "graph_stats_failed"
+	ErrRecentEntities       = "recent_entities_failed"
+	ErrExportGraph          = "export_graph_failed"
+	ErrImportGraph          = "import_graph_failed"
+	ErrViewTooLarge         = "view_too_large"
+	ErrRootNotFound         = "root_not_found"
+	ErrCheckIntegrity       = "check_integrity_failed"
+	ErrEntityNotFound       = "entity_not_found"
+	ErrImportNotFound       = "import_not_found"
+	ErrImportOutOfOrder     = "import_out_of_order"
+	ErrImportDuplicateChunk = "import_duplicate_chunk"
+	ErrImportInvalidLine    = "import_invalid_line"
+
+	// Write rejections with details to act on
+	ErrRelationEndpointsMissing = "relation_endpoints_missing"
+	ErrObservationCapExceeded   = "observation_cap_exceeded"
+
+	// Validation
+	ErrEntityNameEmpty          = "entity_name_empty"
+	ErrEntityNameInvalidUTF8    = "entity_name_invalid_utf8"
+	ErrEntityNameTooLong        = "entity_name_too_long"
+	ErrEntityNameControlChars   = "entity_name_control_chars"
+	ErrEntityTypeEmpty          = "entity_type_empty"
+	ErrEntityTypeInvalidUTF8    = "entity_type_invalid_utf8"
+	ErrEntityTypeTooLong        = "entity_type_too_long"
+	ErrRelationTypeEmpty        = "relation_type_empty"
+	ErrRelationTypeInvalidUTF8  = "relation_type_invalid_utf8"
+	ErrRelationTypeTooLong      = "relation_type_too_long"
+	ErrObservationEmpty         = "observation_empty"
+	ErrObservationInvalidUTF8   = "observation_invalid_utf8"
+	ErrObservationTooLong       = "observation_too_long"
+	ErrSearchQueryInvalidUTF8   = "search_query_invalid_utf8"
+	ErrSearchQueryTooLong       = "search_query_too_long"
+	ErrNoEntities               = "no_entities"
+	ErrTooManyEntities          = "too_many_entities"
+	ErrInvalidOnDuplicate       = "invalid_on_duplicate"
+	ErrInvalidOnConflict        = "invalid_on_conflict"
+	ErrConflictingOnDuplicate   = "conflicting_on_duplicate"
+	ErrTooManyObservations      = "too_many_observations"
+	ErrNoRelations              = "no_relations"
+	ErrTooManyRelations         = "too_many_relations"
+	ErrNoObservations           = "no_observations"
+	ErrNoContents               = "no_contents"
+	ErrInvalidSimilarity        = "invalid_similarity"
+	ErrNoEntityNames            = "no_entity_names"
+	ErrTooManyEntitiesToDelete  = "too_many_entities_to_delete"
+	ErrTooManyNodes             = "too_many_nodes"
+	ErrInvalidPageLimit         = "invalid_page_limit"
+	ErrNegativeOffset           = "negative_offset"
+	ErrInvalidOrderBy           = "invalid_order_by"
+	ErrNoNames                  = "no_names"
+	ErrTooManyNames             = "too_many_names"
+	ErrEraseNameTooShort        = "erase_name_too_short"
+	ErrImportIDEmpty            = "import_id_empty"
+	ErrInvalidSequence          = "invalid_sequence"
+	ErrInvalidEncoding          = "invalid_encoding"
+	ErrImportChunkTooLarge      = "import_chunk_too_large"
+	ErrInvalidBase64            = "invalid_base64"
+	ErrNoTypeMetadata           = "no_type_metadata"
+	ErrTooManyTypeMetadataKeys  = "too_many_type_metadata_keys"
+	ErrTypeMetadataKeyEmpty     = "type_metadata_key_empty"
+	ErrTypeMetadataKeyTooLong   = "type_metadata_key_too_long"
+	ErrTypeMetadataKeyInvalid   = "type_metadata_key_invalid"
+	ErrTypeMetadataValueTooLong = "type_metadata_value_too_long"
+	ErrTypeMetadataValueInvalid = "type_metadata_value_invalid"
+	ErrTooManyEntityTypes       = "too_many_entity_types"
+	ErrInvalidStaleAfterDays    = "invalid_stale_after_days"
+	ErrSessionEmpty             = "session_empty"
+	ErrSessionTooLong           = "session_too_long"
+	ErrSessionInvalid           = "session_invalid"
+	ErrInvalidPathDepth         = "invalid_path_depth"
+	ErrReassignToSelf           = "reassign_to_self"
+	ErrNegativeMinCount         = "negative_min_count"
+	ErrInvalidNeighborDepth     = "invalid_neighbor_depth"
+	ErrInvalidDirection         = "invalid_direction"
+	ErrClearNotConfirmed        = "clear_not_confirmed"
+	ErrInvalidSearchMode        = "invalid_search_mode"
+	ErrInvalidSearchSyntax      = "invalid_search_syntax"
+	ErrSearchSyntaxMode         = "search_syntax_mode"
+	ErrFTSUnavailable           = "fts_unavailable"
+	ErrInvalidFTSQuery          = "invalid_fts_query"
+	ErrNeedsSQLite              = "needs_sqlite"
+	ErrInvalidImportStrategy    = "invalid_import_strategy"
+	ErrInvalidDocument          = "invalid_document"
+	ErrDocumentVersion          = "unsupported_document_version"
+	ErrInvalidExportFormat      = "invalid_export_format"
+	ErrInvalidMaxNodes          = "invalid_max_nodes"
+	ErrViewOptionsFormat        = "view_options_format"
+
+	// Named graphs
+	ErrInvalidGraph     = "invalid_graph"
+	ErrGraphUnsupported = "graph_unsupported"
+	ErrListGraphs       = "list_graphs_failed"
+	ErrGraphNamespace   = "graph_namespace"
+
+	// Entity tags
+	ErrTagEmpty    = "tag_empty"
+	ErrTagTooLong  = "tag_too_long"
+	ErrTagInvalid  = "tag_invalid"
+	ErrNoTags      = "no_tags"
+	ErrTooManyTags = "too_many_tags"
+	ErrAddTags     = "add_tags_failed"
+	ErrRemoveTags  = "remove_tags_failed"
+
+	// Entity attributes
+	ErrAttributesTooLarge  = "attributes_too_large"
+	ErrNoEntityChanges     = "no_entity_changes"
+	ErrAttributeSearchMode = "attribute_search_mode"
+	ErrUpdateEntities      = "update_entities_failed"
+
+	// Relation properties
+	ErrInvalidConfidence   = "invalid_confidence"
+	ErrRelationNoteTooLong = "relation_note_too_long"
+	ErrRelationNoteInvalid = "relation_note_invalid"
+
+	// Soft delete
+	ErrListDeleted     = "list_deleted_failed"
+	ErrRestoreEntities = "restore_entities_failed"
+	ErrPurgeDeleted    = "purge_deleted_failed"
+	ErrInvalidPurgeAge = "invalid_purge_age"
+
+	// History
+	ErrGetHistory      = "get_history_failed"
+	ErrInvalidTime     = "invalid_time"
+	ErrNegativeAfterID = "negative_after_id"
+
+	// Snapshots
+	ErrCreateSnapshot       = "create_snapshot_failed"
+	ErrListSnapshots        = "list_snapshots_failed"
+	ErrDiffSnapshot         = "diff_snapshot_failed"
+	ErrInvalidSnapshotLabel = "invalid_snapshot_label"
+	ErrSnapshotNotFound     = "snapshot_not_found"
+	ErrSnapshotExists       = "snapshot_exists"
+	ErrSnapshotTooLarge     = "snapshot_too_large"
+
+	// Orphans
+	ErrFindOrphans = "find_orphans_failed"
+
+	// Hotspots
+	ErrGraphHotspots       = "graph_hotspots_failed"
+	ErrInvalidHotspotOrder = "invalid_hotspot_order"
+
+	// External relations
+	ErrInvalidExternalRelations = "invalid_external_relations"
+
+	// Duplicates
+	ErrFindDuplicates            = "find_duplicates_failed"
+	ErrInvalidDuplicateThreshold = "invalid_duplicate_threshold"
+
+	// Pins
+	ErrPinEntities   = "pin_entities_failed"
+	ErrUnpinEntities = "unpin_entities_failed"
+
+	// Observation expiry
+	ErrConflictingExpiry = "conflicting_expiry"
+	ErrInvalidTTL        = "invalid_ttl"
+	ErrExpiryInPast      = "expiry_in_past"
+
+	// Aliases
+	ErrAddAlias          = "add_alias_failed"
+	ErrNoAliases         = "no_aliases"
+	ErrAliasIsEntityName = "alias_is_entity_name"
+	ErrAliasTaken        = "alias_taken"
+
+	// Summary reads
+	ErrInvalidDetail = "invalid_detail"
+	ErrSummaryOption = "summary_option"
+)
+
+var catalogs = map[string]map[string]string{
+	"en": english,
+	"es": spanish,
+}
+
+var english = map[string]string{
+	MsgEntitiesDeleted:     "Entities deleted successfully",
+	MsgObservationsDeleted: "Observations deleted successfully",
+	MsgRelationsDeleted:    "Relations deleted successfully",
+	MsgImportAborted:       "Import aborted; nothing was imported",
+	MsgServedFromSnapshot:  "Served from a snapshot taken at %s while maintenance or an import runs; writes since then are not included.",
+	MsgResultTooLarge: "Result too large to return inline (%d bytes): %d entities, %d relations. " +
+		"Read the linked resource %s in pages of %d items using ?offset=N (and optionally &limit=M); it expires in %s.",
+	MsgConstraintAllowSelf:   "relations[%d]: %s cannot have a %s relation to itself",
+	MsgConstraintMaxOutgoing: "relations[%d]: %s already has the maximum of %d outgoing %s relations",
+	MsgConstraintMaxIncoming: "relations[%d]: %s already has the maximum of %d incoming %s relations",
+
+	MsgRelationEndpointMissing: "relations[%d]: entity %s does not exist",
+
+	ErrValidation:           "validation error",
+	ErrOperationCancelled:   "operation cancelled",
+	ErrCreateEntities:       "failed to create entities",
+	ErrCreateRelations:      "failed to create relations",
+	ErrAddObservations:      "failed to add observations",
+	ErrDeleteEntities:       "failed to delete entities",
+	ErrDeleteObservations:   "failed to delete observations",
+	ErrDeleteRelations:      "failed to delete relations",
+	ErrReadGraph:            "failed to read graph",
+	ErrSearchNodes:          "failed to search nodes",
+	ErrOpenNodes:            "failed to open nodes",
+	ErrGetObservations:      "failed to get observations",
+	ErrFindPath:             "failed to find path",
+	ErrGetMaintenanceStatus: "failed to get maintenance status",
+	ErrStoreResult:          "failed to store result",
+	ErrEncodeResult:         "failed to encode result",
+	ErrEraseSubject:         "failed to erase subject",
+	ErrImportBegin:          "failed to begin import",
+	ErrImportChunk:          "failed to apply import chunk",
+	ErrImportCommit:         "failed to commit import",
+	ErrImportAbort:          "failed to abort import",
+	ErrSetTypeMetadata:      "failed to set type metadata",
+	ErrGetTypeMetadata:      "failed to get type metadata",
+	ErrSyncMemory:           "failed to sync memory",
+	ErrSyncRateLimited:      "sync_memory was called too recently; retry in %v",
+	ErrHygieneReport:        "failed to build hygiene report",
+	ErrRelationConstraint:   "%d relations break relation constraints, so none were created: %s",
+	ErrListSessions:         "failed to list sessions",
+	ErrRollbackSession:      "failed to roll back session",
+	ErrMigrateToPolicy:      "failed to migrate stored data to the validation policy",
+	ErrEventTooLarge:        "the result (%d bytes) exceeds the %d bytes this connection delivers in one event; request less data or use the streamable HTTP endpoint",
+	ErrPreviewRetention:     "failed to preview the retention policy",
+	ErrGetRelations:         "failed to get relations",
+	ErrGetEntity:            "failed to get entity",
+	ErrListEntityTypes:      "failed to list entity types",
+	ErrListRelationTypes:    "failed to list relation types",
+	ErrGetNeighbors:         "failed to get neighbors",
+	ErrClearGraph:           "failed to clear the graph",
+	ErrGraphStats:           "failed to read graph statistics",
+	ErrRecentEntities:       "failed to list recently updated entities",
+	ErrExportGraph:          "failed to export the graph",
+	ErrImportGraph:          "failed to import the graph",
+	ErrViewTooLarge:         "more than %d entities to export: pass a root entity and depth, or raise maxNodes",
+	ErrRootNotFound:         "root entity %q not found",
+	ErrCheckIntegrity:       "failed to check database integrity",
+	ErrEntityNotFound:       "entity %q not found",
+	ErrImportNotFound:       "import not found; it may have been committed, aborted or expired",
+	ErrImportOutOfOrder:     "chunk %d is out of order; expected chunk %d",
+	ErrImportDuplicateChunk: "chunk %d was already applied with different data; expected chunk %d",
+	ErrImportInvalidLine:    "invalid import line %d: %v",
+
+	ErrRelationEndpointsMissing: "%d relations name entities that don't exist, so none were created: %s",
+	ErrObservationCapExceeded:   "%s would have %d observations, over the limit of %d per entity, so none were added; delete outdated ones with delete_observations",
+
+	ErrEntityNameEmpty:          "entity name cannot be empty",
+	ErrEntityNameInvalidUTF8:    "entity name contains invalid UTF-8 characters",
+	ErrEntityNameTooLong:        "entity name exceeds maximum length of %d characters",
+	ErrEntityNameControlChars:   "entity name contains control characters",
+	ErrEntityTypeEmpty:          "entity type cannot be empty",
+	ErrEntityTypeInvalidUTF8:    "entity type contains invalid UTF-8 characters",
+	ErrEntityTypeTooLong:        "entity type exceeds maximum length of %d characters",
+	ErrRelationTypeEmpty:        "relation type cannot be empty",
+	ErrRelationTypeInvalidUTF8:  "relation type contains invalid UTF-8 characters",
+	ErrRelationTypeTooLong:      "relation type exceeds maximum length of %d characters",
+	ErrObservationEmpty:         "observation cannot be empty",
+	ErrObservationInvalidUTF8:   "observation contains invalid UTF-8 characters",
+	ErrObservationTooLong:       "observation exceeds maximum length of %d characters",
+	ErrSearchQueryInvalidUTF8:   "search query contains invalid UTF-8 characters",
+	ErrSearchQueryTooLong:       "search query exceeds maximum length of %d characters",
+	ErrNoEntities:               "no entities provided",
+	ErrTooManyEntities:          "too many entities in request: %d (max %d)",
+	ErrInvalidOnDuplicate:       "onDuplicate must be %q, %q or %q",
+	ErrInvalidOnConflict:        "onConflict must be %q or %q",
+	ErrConflictingOnDuplicate:   "give onConflict or onDuplicate, not both",
+	ErrTooManyObservations:      "too many observations: %d (max %d)",
+	ErrNoRelations:              "no relations provided",
+	ErrTooManyRelations:         "too many relations in request: %d (max %d)",
+	ErrNoObservations:           "no observations provided",
+	ErrNoContents:               "no contents provided",
+	ErrInvalidSimilarity:        "ifAbsentSimilar must be between 0 and 1",
+	ErrNoEntityNames:            "no entity names provided",
+	ErrTooManyEntitiesToDelete:  "too many entities to delete: %d (max %d)",
+	ErrTooManyNodes:             "too many nodes to open: %d (max %d)",
+	ErrInvalidPageLimit:         "limit must be between 1 and %d",
+	ErrNegativeOffset:           "offset cannot be negative",
+	ErrInvalidOrderBy:           "orderBy must be %q or %q",
+	ErrNoNames:                  "no names provided",
+	ErrTooManyNames:             "too many names: %d (max %d)",
+	ErrEraseNameTooShort:        "name must be at least %d characters to erase",
+	ErrImportIDEmpty:            "importId cannot be empty",
+	ErrInvalidSequence:          "sequence must be at least %d",
+	ErrInvalidEncoding:          "encoding must be %q or %q",
+	ErrImportChunkTooLarge:      "chunk exceeds maximum size of %d bytes",
+	ErrInvalidBase64:            "data is not valid base64",
+	ErrNoTypeMetadata:           "no metadata provided",
+	ErrTooManyTypeMetadataKeys:  "too many metadata keys: %d (max %d)",
+	ErrTypeMetadataKeyEmpty:     "metadata key cannot be empty",
+	ErrTypeMetadataKeyTooLong:   "metadata key exceeds maximum length of %d characters",
+	ErrTypeMetadataKeyInvalid:   "metadata key contains invalid UTF-8 or control characters",
+	ErrTypeMetadataValueTooLong: "metadata value exceeds maximum length of %d characters",
+	ErrTypeMetadataValueInvalid: "metadata value contains invalid UTF-8 characters",
+	ErrTooManyEntityTypes:       "too many entity types: %d (max %d)",
+	ErrInvalidStaleAfterDays:    "staleAfterDays must be between 1 and %d",
+	ErrSessionEmpty:             "session label cannot be empty",
+	ErrSessionTooLong:           "session label exceeds maximum length of %d characters",
+	ErrSessionInvalid:           "session label contains invalid UTF-8 or control characters",
+	ErrInvalidPathDepth:         "maxDepth must be between 1 and %d",
+	ErrReassignToSelf:           "an entity's relations cannot be reassigned to the entity itself",
+	ErrNegativeMinCount:         "minCount cannot be negative",
+	ErrInvalidNeighborDepth:     "depth must be between 1 and %d",
+	ErrInvalidDirection:         "direction must be %q, %q or %q",
+	ErrClearNotConfirmed:        "confirm must be exactly %q to delete the whole graph",
+	ErrInvalidSearchMode:        "mode must be %q, %q or %q",
+	ErrInvalidSearchSyntax:      "syntax must be %q or %q",
+	ErrSearchSyntaxMode:         "syntax %q only applies to mode %q",
+	ErrFTSUnavailable:           "syntax %q needs FTS5, which this server does not have",
+	ErrInvalidFTSQuery:          "invalid FTS5 query: %v",
+	ErrNeedsSQLite:              "%s needs the SQLite backend, which this server does not use",
+	ErrInvalidImportStrategy:    "strategy must be %q, %q or %q",
+	ErrInvalidDocument:          "document is not a valid graph document: %s",
+	ErrDocumentVersion:          "unsupported document version %d (max %d)",
+	ErrInvalidExportFormat:      "format must be %q, %q or %q",
+	ErrInvalidMaxNodes:          "maxNodes must be between 1 and %d",
+	ErrViewOptionsFormat:        "root, depth and maxNodes apply only to the %q and %q formats",
+
+	ErrInvalidGraph:     "graph %q is invalid: use at most %d letters, digits, '.', '_' and '-', starting with a letter or digit",
+	ErrGraphUnsupported: "%s works on the whole database and only takes the graph %q",
+	ErrListGraphs:       "failed to list graphs",
+	ErrGraphNamespace:   "this client is bound to the graph %q and can't name the graph %q",
+
+	ErrTagEmpty:    "tag cannot be empty",
+	ErrTagTooLong:  "tag exceeds maximum length of %d bytes",
+	ErrTagInvalid:  "tag contains invalid UTF-8, control characters or leading or trailing spaces",
+	ErrNoTags:      "no tags provided",
+	ErrTooManyTags: "too many tags: %d (max %d)",
+	ErrAddTags:     "failed to add tags",
+	ErrRemoveTags:  "failed to remove tags",
+
+	ErrAttributesTooLarge:  "attributes are %d bytes as JSON, over the maximum of %d",
+	ErrNoEntityChanges:     "no changes given for the entity",
+	ErrAttributeSearchMode: "searchAttributes only applies to mode %q with syntax %q",
+	ErrUpdateEntities:      "failed to update entities",
+
+	ErrInvalidConfidence:   "confidence must be between 0 and 1",
+	ErrRelationNoteTooLong: "relation note exceeds maximum length of %d bytes",
+	ErrRelationNoteInvalid: "relation note contains invalid UTF-8",
+
+	ErrListDeleted:     "failed to list deleted entities",
+	ErrRestoreEntities: "failed to restore entities",
+	ErrPurgeDeleted:    "failed to purge deleted entities",
+	ErrInvalidPurgeAge: "olderThanDays must be 0 or more",
+
+	ErrGetHistory:      "failed to get the change history",
+	ErrInvalidTime:     "%s must be a time in RFC 3339 format, such as 2025-01-31T09:00:00Z",
+	ErrNegativeAfterID: "afterId cannot be negative",
+
+	ErrCreateSnapshot:       "failed to create the snapshot",
+	ErrListSnapshots:        "failed to list snapshots",
+	ErrDiffSnapshot:         "failed to diff the snapshot",
+	ErrInvalidSnapshotLabel: "snapshot label must be 1 to %d bytes of UTF-8 without control characters",
+	ErrSnapshotNotFound:     "no snapshot labeled %q",
+	ErrSnapshotExists:       "a snapshot labeled %q already exists",
+	ErrSnapshotTooLarge:     "the snapshot would take %d bytes compressed, more than the limit of %d",
+
+	ErrFindOrphans: "failed to find orphan entities",
+
+	ErrGraphHotspots:       "failed to rank the most connected entities",
+	ErrInvalidHotspotOrder: "by must be %q, %q or %q",
+
+	ErrInvalidExternalRelations: "includeExternalRelations must be %q, %q, %q or %q",
+
+	ErrFindDuplicates:            "failed to find duplicate entities",
+	ErrInvalidDuplicateThreshold: "threshold must be between 0 and 1",
+
+	ErrPinEntities:   "failed to pin entities",
+	ErrUnpinEntities: "failed to unpin entities",
+
+	ErrConflictingExpiry: "give expiresAt or ttlSeconds, not both",
+	ErrInvalidTTL:        "ttlSeconds must be between 1 and %d",
+	ErrExpiryInPast:      "expiresAt must be in the future",
+
+	ErrAddAlias:          "failed to add aliases",
+	ErrNoAliases:         "no aliases provided",
+	ErrAliasIsEntityName: "alias %q is already the name of an entity",
+	ErrAliasTaken:        "alias %q already names entity %q",
+
+	ErrInvalidDetail: "detail must be %q or %q",
+	ErrSummaryOption: "%s can't be used with detail %q, which leaves out what it adds",
+}
+
+var spanish = map[string]string{
+	MsgEntitiesDeleted:     "Entidades eliminadas correctamente",
+	MsgObservationsDeleted: "Observaciones eliminadas correctamente",
+	MsgRelationsDeleted:    "Relaciones eliminadas correctamente",
+	MsgImportAborted:       "Importación cancelada; no se importó nada",
+	MsgServedFromSnapshot:  "Servido desde una instantánea tomada a las %s mientras se ejecuta un mantenimiento o una importación; no incluye las escrituras posteriores.",
+	MsgResultTooLarge: "Resultado demasiado grande para devolverlo en línea (%d bytes): %d entidades, %d relaciones. " +
+		"Lea el recurso enlazado %s en páginas de %d elementos con ?offset=N (y opcionalmente &limit=M); caduca en %s.",
+	MsgConstraintAllowSelf:   "relations[%d]: %s no puede tener una relación %s consigo mismo",
+	MsgConstraintMaxOutgoing: "relations[%d]: %s ya tiene el máximo de %d relaciones %s salientes",
+	MsgConstraintMaxIncoming: "relations[%d]: %s ya tiene el máximo de %d relaciones %s entrantes",
+
+	MsgRelationEndpointMissing: "relations[%d]: la entidad %s no existe",
+
+	ErrValidation:           "error de validación",
+	ErrOperationCancelled:   "operación cancelada",
+	ErrCreateEntities:       "no se pudieron crear las entidades",
+	ErrCreateRelations:      "no se pudieron crear las relaciones",
+	ErrAddObservations:      "no se pudieron añadir las observaciones",
+	ErrDeleteEntities:       "no se pudieron eliminar las entidades",
+	ErrDeleteObservations:   "no se pudieron eliminar las observaciones",
+	ErrDeleteRelations:      "no se pudieron eliminar las relaciones",
+	ErrReadGraph:            "no se pudo leer el grafo",
+	ErrSearchNodes:          "no se pudieron buscar los nodos",
+	ErrOpenNodes:            "no se pudieron abrir los nodos",
+	ErrGetObservations:      "no se pudieron obtener las observaciones",
+	ErrFindPath:             "no se pudo buscar la ruta",
+	ErrGetMaintenanceStatus: "no se pudo obtener el estado del mantenimiento",
+	ErrStoreResult:          "no se pudo guardar el resultado",
+	ErrEncodeResult:         "no se pudo codificar el resultado",
+	ErrEraseSubject:         "no se pudo borrar el sujeto",
+	ErrImportBegin:          "no se pudo iniciar la importación",
+	ErrImportChunk:          "no se pudo aplicar el fragmento de importación",
+	ErrImportCommit:         "no se pudo confirmar la importación",
+	ErrImportAbort:          "no se pudo cancelar la importación",
+	ErrSetTypeMetadata:      "no se pudieron guardar los metadatos del tipo",
+	ErrGetTypeMetadata:      "no se pudieron obtener los metadatos del tipo",
+	ErrSyncMemory:           "no se pudo sincronizar la memoria",
+	ErrSyncRateLimited:      "sync_memory se llamó hace muy poco; reintente en %v",
+	ErrHygieneReport:        "no se pudo generar el informe de higiene",
+	ErrRelationConstraint:   "%d relaciones incumplen las restricciones de relación, así que no se creó ninguna: %s",
+	ErrListSessions:         "no se pudieron listar las sesiones",
+	ErrRollbackSession:      "no se pudo revertir la sesión",
+	ErrMigrateToPolicy:      "no se pudieron adaptar los datos guardados a la política de validación",
+	ErrEventTooLarge:        "el resultado (%d bytes) supera los %d bytes que esta conexión entrega en un evento; solicite menos datos o use el endpoint HTTP streamable",
+	ErrPreviewRetention:     "no se pudo calcular la vista previa de la política de retención",
+	ErrGetRelations:         "no se pudieron obtener las relaciones",
+	ErrGetEntity:            "no se pudo obtener la entidad",
+	ErrListEntityTypes:      "no se pudieron listar los tipos de entidad",
+	ErrListRelationTypes:    "no se pudieron listar los tipos de relación",
+	ErrGetNeighbors:         "no se pudieron obtener los vecinos",
+	ErrClearGraph:           "no se pudo vaciar el grafo",
+	ErrGraphStats:           "no se pudieron leer las estadísticas del grafo",
+	ErrRecentEntities:       "no se pudieron listar las entidades actualizadas recientemente",
+	ErrExportGraph:          "no se pudo exportar el grafo",
+	ErrImportGraph:          "no se pudo importar el grafo",
+	ErrViewTooLarge:         "más de %d entidades para exportar: indica una entidad raíz y una profundidad, o aumenta maxNodes",
+	ErrRootNotFound:         "no se encontró la entidad raíz %q",
+	ErrCheckIntegrity:       "no se pudo comprobar la integridad de la base de datos",
+	ErrEntityNotFound:       "no se encontró la entidad %q",
+	ErrImportNotFound:       "importación no encontrada; puede que ya se haya confirmado, cancelado o caducado",
+	ErrImportOutOfOrder:     "el fragmento %d está fuera de orden; se esperaba el fragmento %d",
+	ErrImportDuplicateChunk: "el fragmento %d ya se aplicó con otros datos; se esperaba el fragmento %d",
+	ErrImportInvalidLine:    "línea de importación %d no válida: %v",
+
+	ErrRelationEndpointsMissing: "%d relaciones nombran entidades que no existen, así que no se creó ninguna: %s",
+	ErrObservationCapExceeded:   "%s tendría %d observaciones, más del límite de %d por entidad, así que no se añadió ninguna; elimine las obsoletas con delete_observations",
+
+	ErrEntityNameEmpty:          "el nombre de la entidad no puede estar vacío",
+	ErrEntityNameInvalidUTF8:    "el nombre de la entidad contiene caracteres UTF-8 no válidos",
+	ErrEntityNameTooLong:        "el nombre de la entidad supera la longitud máxima de %d caracteres",
+	ErrEntityNameControlChars:   "el nombre de la entidad contiene caracteres de control",
+	ErrEntityTypeEmpty:          "el tipo de entidad no puede estar vacío",
+	ErrEntityTypeInvalidUTF8:    "el tipo de entidad contiene caracteres UTF-8 no válidos",
+	ErrEntityTypeTooLong:        "el tipo de entidad supera la longitud máxima de %d caracteres",
+	ErrRelationTypeEmpty:        "el tipo de relación no puede estar vacío",
+	ErrRelationTypeInvalidUTF8:  "el tipo de relación contiene caracteres UTF-8 no válidos",
+	ErrRelationTypeTooLong:      "el tipo de relación supera la longitud máxima de %d caracteres",
+	ErrObservationEmpty:         "la observación no puede estar vacía",
+	ErrObservationInvalidUTF8:   "la observación contiene caracteres UTF-8 no válidos",
+	ErrObservationTooLong:       "la observación supera la longitud máxima de %d caracteres",
+	ErrSearchQueryInvalidUTF8:   "la consulta de búsqueda contiene caracteres UTF-8 no válidos",
+	ErrSearchQueryTooLong:       "la consulta de búsqueda supera la longitud máxima de %d caracteres",
+	ErrNoEntities:               "no se proporcionaron entidades",
+	ErrTooManyEntities:          "demasiadas entidades en la solicitud: %d (máximo %d)",
+	ErrInvalidOnDuplicate:       "onDuplicate debe ser %q, %q o %q",
+	ErrInvalidOnConflict:        "onConflict debe ser %q o %q",
+	ErrConflictingOnDuplicate:   "indique onConflict u onDuplicate, no ambos",
+	ErrTooManyObservations:      "demasiadas observaciones: %d (máximo %d)",
+	ErrNoRelations:              "no se proporcionaron relaciones",
+	ErrTooManyRelations:         "demasiadas relaciones en la solicitud: %d (máximo %d)",
+	ErrNoObservations:           "no se proporcionaron observaciones",
+	ErrNoContents:               "no se proporcionó contenido",
+	ErrInvalidSimilarity:        "ifAbsentSimilar debe estar entre 0 y 1",
+	ErrNoEntityNames:            "no se proporcionaron nombres de entidades",
+	ErrTooManyEntitiesToDelete:  "demasiadas entidades para eliminar: %d (máximo %d)",
+	ErrTooManyNodes:             "demasiados nodos para abrir: %d (máximo %d)",
+	ErrInvalidPageLimit:         "limit debe estar entre 1 y %d",
+	ErrNegativeOffset:           "offset no puede ser negativo",
+	ErrInvalidOrderBy:           "orderBy debe ser %q o %q",
+	ErrNoNames:                  "no se proporcionaron nombres",
+	ErrTooManyNames:             "demasiados nombres: %d (máximo %d)",
+	ErrEraseNameTooShort:        "el nombre debe tener al menos %d caracteres para borrarlo",
+	ErrImportIDEmpty:            "importId no puede estar vacío",
+	ErrInvalidSequence:          "sequence debe ser al menos %d",
+	ErrInvalidEncoding:          "encoding debe ser %q o %q",
+	ErrImportChunkTooLarge:      "el fragmento supera el tamaño máximo de %d bytes",
+	ErrInvalidBase64:            "data no es base64 válido",
+	ErrNoTypeMetadata:           "no se proporcionaron metadatos",
+	ErrTooManyTypeMetadataKeys:  "demasiadas claves de metadatos: %d (máximo %d)",
+	ErrTypeMetadataKeyEmpty:     "la clave de metadatos no puede estar vacía",
+	ErrTypeMetadataKeyTooLong:   "la clave de metadatos supera la longitud máxima de %d caracteres",
+	ErrTypeMetadataKeyInvalid:   "la clave de metadatos contiene UTF-8 no válido o caracteres de control",
+	ErrTypeMetadataValueTooLong: "el valor de metadatos supera la longitud máxima de %d caracteres",
+	ErrTypeMetadataValueInvalid: "el valor de metadatos contiene caracteres UTF-8 no válidos",
+	ErrTooManyEntityTypes:       "demasiados tipos de entidad: %d (máximo %d)",
+	ErrInvalidStaleAfterDays:    "staleAfterDays debe estar entre 1 y %d",
+	ErrSessionEmpty:             "la etiqueta de sesión no puede estar vacía",
+	ErrSessionTooLong:           "la etiqueta de sesión supera la longitud máxima de %d caracteres",
+	ErrSessionInvalid:           "la etiqueta de sesión contiene UTF-8 no válido o caracteres de control",
+	ErrInvalidPathDepth:         "maxDepth debe estar entre 1 y %d",
+	ErrReassignToSelf:           "las relaciones de una entidad no pueden reasignarse a la propia entidad",
+	ErrNegativeMinCount:         "minCount no puede ser negativo",
+	ErrInvalidNeighborDepth:     "depth debe estar entre 1 y %d",
+	ErrInvalidDirection:         "direction debe ser %q, %q o %q",
+	ErrClearNotConfirmed:        "confirm debe ser exactamente %q para borrar todo el grafo",
+	ErrInvalidSearchMode:        "mode debe ser %q, %q o %q",
+	ErrInvalidSearchSyntax:      "syntax debe ser %q o %q",
+	ErrSearchSyntaxMode:         "syntax %q solo se aplica al modo %q",
+	ErrFTSUnavailable:           "syntax %q necesita FTS5, que este servidor no tiene",
+	ErrInvalidFTSQuery:          "consulta FTS5 no válida: %v",
+	ErrNeedsSQLite:              "%s necesita el almacenamiento SQLite, que este servidor no usa",
+	ErrInvalidImportStrategy:    "strategy debe ser %q, %q o %q",
+	ErrInvalidDocument:          "document no es un documento de grafo válido: %s",
+	ErrDocumentVersion:          "versión de documento no admitida %d (máximo %d)",
+	ErrInvalidExportFormat:      "format debe ser %q, %q o %q",
+	ErrInvalidMaxNodes:          "maxNodes debe estar entre 1 y %d",
+	ErrViewOptionsFormat:        "root, depth y maxNodes solo se aplican a los formatos %q y %q",
+
+	ErrInvalidGraph:     "graph %q no es válido: use como máximo %d letras, dígitos, '.', '_' y '-', empezando por una letra o un dígito",
+	ErrGraphUnsupported: "%s trabaja con toda la base de datos y solo admite el grafo %q",
+	ErrListGraphs:       "no se pudieron listar los grafos",
+	ErrGraphNamespace:   "este cliente está vinculado al grafo %q y no puede indicar el grafo %q",
+
+	ErrTagEmpty:    "la etiqueta no puede estar vacía",
+	ErrTagTooLong:  "la etiqueta supera la longitud máxima de %d bytes",
+	ErrTagInvalid:  "la etiqueta contiene UTF-8 no válido, caracteres de control o espacios al principio o al final",
+	ErrNoTags:      "no se proporcionaron etiquetas",
+	ErrTooManyTags: "demasiadas etiquetas: %d (máximo %d)",
+	ErrAddTags:     "no se pudieron añadir las etiquetas",
+	ErrRemoveTags:  "no se pudieron quitar las etiquetas",
+
+	ErrAttributesTooLarge:  "los atributos ocupan %d bytes en JSON, más del máximo de %d",
+	ErrNoEntityChanges:     "no se indicaron cambios para la entidad",
+	ErrAttributeSearchMode: "searchAttributes solo se aplica al modo %q con la sintaxis %q",
+	ErrUpdateEntities:      "no se pudieron actualizar las entidades",
+
+	ErrInvalidConfidence:   "confidence debe estar entre 0 y 1",
+	ErrRelationNoteTooLong: "la nota de la relación supera la longitud máxima de %d bytes",
+	ErrRelationNoteInvalid: "la nota de la relación contiene UTF-8 no válido",
+
+	ErrListDeleted:     "no se pudieron listar las entidades eliminadas",
+	ErrRestoreEntities: "no se pudieron restaurar las entidades",
+	ErrPurgeDeleted:    "no se pudieron purgar las entidades eliminadas",
+	ErrInvalidPurgeAge: "olderThanDays debe ser 0 o más",
+
+	ErrGetHistory:      "no se pudo obtener el historial de cambios",
+	ErrInvalidTime:     "%s debe ser una hora en formato RFC 3339, como 2025-01-31T09:00:00Z",
+	ErrNegativeAfterID: "afterId no puede ser negativo",
+
+	ErrCreateSnapshot:       "no se pudo crear la instantánea",
+	ErrListSnapshots:        "no se pudieron listar las instantáneas",
+	ErrDiffSnapshot:         "no se pudo comparar la instantánea",
+	ErrInvalidSnapshotLabel: "la etiqueta de la instantánea debe tener de 1 a %d bytes de UTF-8 sin caracteres de control",
+	ErrSnapshotNotFound:     "no hay ninguna instantánea con la etiqueta %q",
+	ErrSnapshotExists:       "ya existe una instantánea con la etiqueta %q",
+	ErrSnapshotTooLarge:     "la instantánea ocuparía %d bytes comprimida, más que el límite de %d",
+
+	ErrFindOrphans: "no se pudieron buscar las entidades huérfanas",
+
+	ErrGraphHotspots:       "no se pudieron clasificar las entidades más conectadas",
+	ErrInvalidHotspotOrder: "by debe ser %q, %q o %q",
+
+	ErrInvalidExternalRelations: "includeExternalRelations debe ser %q, %q, %q o %q",
+
+	ErrFindDuplicates:            "no se pudieron buscar las entidades duplicadas",
+	ErrInvalidDuplicateThreshold: "threshold debe estar entre 0 y 1",
+
+	ErrPinEntities:   "no se pudieron fijar las entidades",
+	ErrUnpinEntities: "no se pudieron desfijar las entidades",
+
+	ErrConflictingExpiry: "indique expiresAt o ttlSeconds, no ambos",
+	ErrInvalidTTL:        "ttlSeconds debe estar entre 1 y %d",
+	ErrExpiryInPast:      "expiresAt debe estar en el futuro",
+
+	ErrAddAlias:          "no se pudieron añadir los alias",
+	ErrNoAliases:         "no se proporcionaron alias",
+	ErrAliasIsEntityName: "el alias %q ya es el nombre de una entidad",
+	ErrAliasTaken:        "el alias %q ya nombra a la entidad %q",
+
+	ErrInvalidDetail: "detail debe ser %q o %q",
+	ErrSummaryOption: "%s no se puede usar con detail %q, que omite lo que añade",
+}
@@ -0,0 +1,114 @@
+// Package i18n renders the server's human-readable messages in the caller's language.
+// Messages are identified by stable IDs that clients may match on; only the rendered
+// text depends on the locale.
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultLocale is used when neither the request nor the configuration selects a supported locale
+const DefaultLocale = "en"
+
+type contextKey struct{}
+
+// WithLocale returns a context carrying locale for message rendering
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// LocaleFrom returns the locale carried by ctx, or DefaultLocale
+func LocaleFrom(ctx context.Context) string {
+	if locale, ok := ctx.Value(contextKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// Supported reports whether a catalog exists for locale
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Match picks the first supported locale from an Accept-Language style hint such as
+// "es-MX,es;q=0.9,en;q=0.8". Region subtags fall back to their base language.
+// Quality values are not weighed; entries are taken in the order given.
+// It returns "" when nothing in the hint is supported.
+func Match(hint string) string {
+	for _, part := range strings.Split(hint, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if Supported(tag) {
+			return tag
+		}
+		if base, _, ok := strings.Cut(tag, "-"); ok && Supported(base) {
+			return base
+		}
+	}
+	return ""
+}
+
+// Translate renders message id in locale, falling back to English and then to the id itself
+func Translate(locale, id string, args ...any) string {
+	format, ok := catalogs[locale][id]
+	if !ok {
+		if format, ok = catalogs[DefaultLocale][id]; !ok {
+			return id
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// T renders message id in the locale carried by ctx
+func T(ctx context.Context, id string, args ...any) string {
+	return Translate(LocaleFrom(ctx), id, args...)
+}
+
+// Error is an error identified by a stable message ID. Error renders it in English;
+// Localize renders it in another locale.
+type Error struct {
+	ID   string
+	Args []any
+}
+
+// NewError returns an Error for message id
+func NewError(id string, args ...any) *Error {
+	return &Error{ID: id, Args: args}
+}
+
+func (e *Error) Error() string {
+	return Translate(DefaultLocale, e.ID, e.Args...)
+}
+
+// Code returns the message ID of the innermost Error in err's chain, or "" if there is none
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.ID
+	}
+	return ""
+}
+
+// Localize renders err in locale. The Error in its chain is translated; text added by
+// wrapping it with fmt.Errorf("...: %w", err), such as field paths, is kept as is.
+func Localize(locale string, err error) string {
+	var e *Error
+	if !errors.As(err, &e) {
+		return err.Error()
+	}
+	text := err.Error()
+	english := e.Error()
+	if !strings.HasSuffix(text, english) {
+		return text
+	}
+	return strings.TrimSuffix(text, english) + Translate(locale, e.ID, e.Args...)
+}
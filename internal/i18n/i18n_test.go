@@ -0,0 +1,52 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	cases := map[string]string{
+		"es":                      "es",
+		"ES-mx":                   "es",
+		"fr-CA,es;q=0.9,en;q=0.8": "es",
+		"*, en":                   "en",
+		"fr":                      "",
+		"":                        "",
+	}
+	for hint, want := range cases {
+		assert.Equal(t, want, Match(hint), hint)
+	}
+}
+
+func TestTranslate_FallsBack(t *testing.T) {
+	assert.Equal(t, "too many nodes to open: 3 (max 2)", Translate("en", ErrTooManyNodes, 3, 2))
+	assert.Equal(t, "demasiados nodos para abrir: 3 (máximo 2)", Translate("es", ErrTooManyNodes, 3, 2))
+	assert.Equal(t, "entity name cannot be empty", Translate("fr", ErrEntityNameEmpty))
+	assert.Equal(t, "no_such_message", Translate("es", "no_such_message"))
+	assert.Equal(t, "operación cancelada", T(WithLocale(context.Background(), "es"), ErrOperationCancelled))
+	assert.Equal(t, "operation cancelled", T(context.Background(), ErrOperationCancelled))
+}
+
+func TestCatalogs_Complete(t *testing.T) {
+	for id := range english {
+		assert.Contains(t, spanish, id)
+	}
+	for id := range spanish {
+		assert.Contains(t, english, id)
+	}
+}
+
+func TestLocalize_KeepsWrappingAndCode(t *testing.T) {
+	err := fmt.Errorf("entity[2].name: %w", NewError(ErrEntityNameTooLong, 256))
+	assert.Equal(t, "entity[2].name: entity name exceeds maximum length of 256 characters", err.Error())
+	assert.Equal(t, "entity[2].name: el nombre de la entidad supera la longitud máxima de 256 caracteres", Localize("es", err))
+	assert.Equal(t, ErrEntityNameTooLong, Code(err))
+
+	plain := fmt.Errorf("disk full")
+	assert.Equal(t, "disk full", Localize("es", plain))
+	assert.Equal(t, "", Code(plain))
+}
@@ -13,6 +13,9 @@ type contextKey string
 const (
 	RequestIDKey contextKey = "request_id"
 	UserIDKey    contextKey = "user_id"
+	// NamespaceKey holds the namespace the transport bound a request to, such as
+	// the graph of an HTTP client
+	NamespaceKey contextKey = "namespace"
 )
 
 // NewLogger creates a new structured logger with the specified service name and level
@@ -58,11 +61,30 @@ func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, RequestIDKey, requestID)
 }
 
+// RequestIDFromContext returns the request ID set by WithRequestID, or "" if there
+// is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
+
 // WithUserID adds a user ID to the context
 func WithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, UserIDKey, userID)
 }
 
+// WithNamespace adds the namespace a request is bound to to the context
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, NamespaceKey, namespace)
+}
+
+// NamespaceFromContext returns the namespace set by WithNamespace, or "" if there
+// is none
+func NamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(NamespaceKey).(string)
+	return namespace
+}
+
 // LoggerWithContext enriches the logger with context values
 func LoggerWithContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
 	if ctx == nil {
@@ -78,6 +100,10 @@ func LoggerWithContext(ctx context.Context, logger *slog.Logger) *slog.Logger {
 	if userID, ok := ctx.Value(UserIDKey).(string); ok && userID != "" {
 		attrs = append(attrs, slog.String("user_id", userID))
 	}
+
+	if namespace := NamespaceFromContext(ctx); namespace != "" {
+		attrs = append(attrs, slog.String("namespace", namespace))
+	}
 	
 	if len(attrs) > 0 {
 		args := make([]any, 0, len(attrs)*2)
@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+)
+
+// RedactedPlaceholder replaces any substring matched by a redaction pattern
+const RedactedPlaceholder = "[REDACTED]"
+
+// DefaultRedactPatterns match common API key and credential formats that agents
+// tend to paste into observations
+var DefaultRedactPatterns = []string{
+	`sk-[A-Za-z0-9_\-]{16,}`,                 // OpenAI/Anthropic style secret keys
+	`AKIA[0-9A-Z]{16}`,                       // AWS access key IDs
+	`gh[pousr]_[A-Za-z0-9]{36,}`,             // GitHub tokens
+	`xox[abprs]-[A-Za-z0-9\-]{10,}`,          // Slack tokens
+	`(?i)bearer\s+[A-Za-z0-9\-._~+/]{16,}=*`, // Bearer tokens in pasted headers
+	`(?i)(api[_\-]?key|secret|password|passwd|token)\s*[:=]\s*[^\s,;]{6,}`, // key=value credentials
+}
+
+// Redactor masks sensitive substrings before they reach log output.
+// It never modifies stored data; it is applied only to log records.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor compiles the default patterns plus any extra patterns supplied by configuration
+func NewRedactor(extra []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range append(append([]string{}, DefaultRedactPatterns...), extra...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact returns s with every pattern match replaced by RedactedPlaceholder
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, RedactedPlaceholder)
+	}
+	return s
+}
+
+// redactAttr redacts string-like attribute values, recursing into groups and into the
+// maps, structs, slices and pointers of KindAny values
+func (r *Redactor) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.Redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = r.redactAttr(ga)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(r.Redact(x.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(r.Redact(x.String()))
+		case []string:
+			out := make([]string, len(x))
+			for i, s := range x {
+				out[i] = r.Redact(s)
+			}
+			a.Value = slog.AnyValue(out)
+		case []byte:
+			a.Value = slog.StringValue(r.Redact(string(x)))
+		default:
+			a.Value = r.redactValue(x)
+		}
+	}
+	return a
+}
+
+// redactValue redacts a KindAny value that is neither an error nor a Stringer. A
+// composite value is replaced by its JSON form with every string in it redacted, keys
+// included; one that can't be encoded is replaced by RedactedPlaceholder, since what
+// it holds can't be checked.
+func (r *Redactor) redactValue(x any) slog.Value {
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.String:
+		return slog.StringValue(r.Redact(rv.String()))
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+	default:
+		return slog.AnyValue(x)
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return slog.StringValue(RedactedPlaceholder)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded any
+	if err := dec.Decode(&decoded); err != nil {
+		return slog.StringValue(RedactedPlaceholder)
+	}
+	return slog.AnyValue(r.redactJSON(decoded))
+}
+
+// redactJSON returns v, a decoded JSON value, with its strings redacted
+func (r *Redactor) redactJSON(v any) any {
+	switch x := v.(type) {
+	case string:
+		return r.Redact(x)
+	case []any:
+		for i, e := range x {
+			x[i] = r.redactJSON(e)
+		}
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[r.Redact(k)] = r.redactJSON(e)
+		}
+		return out
+	}
+	return v
+}
+
+// redactingHandler wraps another slog.Handler and masks sensitive content in
+// the message and all attributes before delegating
+type redactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+// NewRedactingHandler returns a handler that redacts records before passing them to next
+func NewRedactingHandler(next slog.Handler, redactor *Redactor) slog.Handler {
+	return &redactingHandler{next: next, redactor: redactor}
+}
+
+// WithRedaction returns a logger whose output is passed through the redactor
+func WithRedaction(logger *slog.Logger, redactor *Redactor) *slog.Logger {
+	if redactor == nil {
+		return logger
+	}
+	return slog.New(NewRedactingHandler(logger.Handler(), redactor))
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactor.redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
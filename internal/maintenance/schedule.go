@@ -0,0 +1,76 @@
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule decides when the next maintenance window starts
+type Schedule interface {
+	// Next returns the first window start strictly after t
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// dailySchedule runs once a day at a fixed local time
+type dailySchedule struct {
+	hour, minute int
+}
+
+func (d dailySchedule) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.hour, d.minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (d dailySchedule) String() string {
+	return fmt.Sprintf("daily at %02d:%02d", d.hour, d.minute)
+}
+
+// intervalSchedule runs at a fixed interval
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (i intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(i.every)
+}
+
+func (i intervalSchedule) String() string {
+	return "every " + i.every.String()
+}
+
+// Every returns a schedule that runs every d, starting d after the scheduler starts
+func Every(d time.Duration) Schedule {
+	return intervalSchedule{every: d}
+}
+
+// ParseSchedule parses a schedule spec: "HH:MM" or "daily HH:MM" for a daily
+// window in local time, or "every <duration>" (e.g. "every 6h") for a fixed interval.
+func ParseSchedule(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	switch {
+	case len(fields) == 1:
+		return parseDaily(fields[0])
+	case len(fields) == 2 && fields[0] == "daily":
+		return parseDaily(fields[1])
+	case len(fields) == 2 && fields[0] == "every":
+		every, err := time.ParseDuration(fields[1])
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("invalid maintenance schedule %q: interval must be a duration of at least 1m", spec)
+		}
+		return intervalSchedule{every: every}, nil
+	}
+	return nil, fmt.Errorf("invalid maintenance schedule %q: use \"HH:MM\", \"daily HH:MM\" or \"every <duration>\"", spec)
+}
+
+func parseDaily(hhmm string) (Schedule, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance time %q: use HH:MM", hhmm)
+	}
+	return dailySchedule{hour: t.Hour(), minute: t.Minute()}, nil
+}
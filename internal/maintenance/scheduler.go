@@ -0,0 +1,296 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job outcomes recorded in JobStatus.LastStatus
+const (
+	StatusOK        = "ok"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+)
+
+// metaKeyPrefix namespaces job status rows in the meta table
+const metaKeyPrefix = "maintenance.job."
+
+// Job is a unit of maintenance work run during a maintenance window
+type Job struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Store persists job status; *database.DB implements it with the meta table
+type Store interface {
+	GetMeta(ctx context.Context, key string) (string, bool, error)
+	SetMeta(ctx context.Context, key, value string) error
+}
+
+// Clock abstracts time so tests can trigger windows deterministically
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// JobStatus is the last recorded outcome of a job
+type JobStatus struct {
+	Name         string    `json:"name"`
+	LastStatus   string    `json:"lastStatus,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastStarted  time.Time `json:"lastStarted"`
+	LastFinished time.Time `json:"lastFinished"`
+	Runs         int       `json:"runs"`
+	// Skipped counts windows that came up while a previous window was still running
+	Skipped int `json:"skipped"`
+}
+
+// Status describes the scheduler and the last results of its jobs
+type Status struct {
+	Enabled  bool        `json:"enabled"`
+	Schedule string      `json:"schedule,omitempty"`
+	NextRun  *time.Time  `json:"nextRun,omitempty"`
+	Running  bool        `json:"running"`
+	Jobs     []JobStatus `json:"jobs"`
+}
+
+// Scheduler runs registered maintenance jobs one at a time in windows given by
+// its schedule. A window that comes up while the previous one is still running is
+// skipped. Every job receives the scheduler's context and must return promptly once
+// it is cancelled.
+type Scheduler struct {
+	schedule Schedule
+	store    Store
+	logger   *slog.Logger
+	clock    Clock
+
+	mu      sync.Mutex
+	jobs    []Job
+	wrap    func(ctx context.Context, window func(context.Context) error) error
+	running bool
+	nextRun time.Time
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler; jobs are added with Register before Start
+func NewScheduler(schedule Schedule, store Store, logger *slog.Logger) *Scheduler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Scheduler{
+		schedule: schedule,
+		store:    store,
+		logger:   logger,
+		clock:    realClock{},
+	}
+}
+
+// Register adds a job; jobs run in registration order
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+}
+
+// SetWindowWrapper makes every maintenance window run inside wrap, which must call
+// window once, e.g. to serve reads from a snapshot while the jobs hold the database
+func (s *Scheduler) SetWindowWrapper(wrap func(ctx context.Context, window func(context.Context) error) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wrap = wrap
+}
+
+// Start runs the scheduling loop until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx)
+	}()
+}
+
+// Wait blocks until the loop and any running window have stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	s.logger.Info("maintenance scheduler started",
+		slog.String("schedule", s.schedule.String()),
+	)
+	for {
+		now := s.clock.Now()
+		next := s.schedule.Next(now)
+		s.mu.Lock()
+		s.nextRun = next
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("maintenance scheduler stopped")
+			return
+		case <-s.clock.After(next.Sub(now)):
+		}
+		s.trigger(ctx)
+	}
+}
+
+// trigger starts a maintenance window unless one is already running
+func (s *Scheduler) trigger(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]Job(nil), s.jobs...)
+	wrap := s.wrap
+	if s.running {
+		s.mu.Unlock()
+		s.logger.Warn("previous maintenance window still running, skipping")
+		for _, job := range jobs {
+			s.update(ctx, job.Name, func(st *JobStatus) { st.Skipped++ })
+		}
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			s.running = false
+			s.mu.Unlock()
+		}()
+		if wrap == nil {
+			s.runJobs(ctx, jobs)
+			return
+		}
+		err := wrap(ctx, func(ctx context.Context) error {
+			s.runJobs(ctx, jobs)
+			return nil
+		})
+		if err != nil {
+			s.logger.Warn("maintenance window failed", slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// runJobs runs each job in turn, recording its outcome
+func (s *Scheduler) runJobs(ctx context.Context, jobs []Job) {
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		started := s.clock.Now()
+		s.logger.Info("maintenance job started", slog.String("job", job.Name))
+		err := job.Run(ctx)
+		finished := s.clock.Now()
+
+		status := StatusOK
+		switch {
+		case err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()):
+			status = StatusCancelled
+		case err != nil:
+			status = StatusError
+		}
+		s.update(ctx, job.Name, func(st *JobStatus) {
+			st.LastStatus = status
+			st.LastError = ""
+			if err != nil {
+				st.LastError = err.Error()
+			}
+			st.LastStarted = started
+			st.LastFinished = finished
+			st.Runs++
+		})
+
+		logAttrs := []any{
+			slog.String("job", job.Name),
+			slog.String("status", status),
+			slog.Duration("duration", finished.Sub(started)),
+		}
+		if err != nil {
+			s.logger.Warn("maintenance job failed", append(logAttrs, slog.String("error", err.Error()))...)
+		} else {
+			s.logger.Info("maintenance job finished", logAttrs...)
+		}
+	}
+}
+
+// update applies fn to a job's stored status and saves it. Status is written even
+// after cancellation so a job interrupted by shutdown is recorded as such.
+func (s *Scheduler) update(ctx context.Context, name string, fn func(*JobStatus)) {
+	ctx = context.WithoutCancel(ctx)
+	st, err := s.load(ctx, name)
+	if err != nil {
+		s.logger.Error("failed to load maintenance status",
+			slog.String("job", name),
+			slog.String("error", err.Error()),
+		)
+		st = JobStatus{Name: name}
+	}
+	fn(&st)
+
+	data, err := json.Marshal(st)
+	if err == nil {
+		err = s.store.SetMeta(ctx, metaKeyPrefix+name, string(data))
+	}
+	if err != nil {
+		s.logger.Error("failed to record maintenance status",
+			slog.String("job", name),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (s *Scheduler) load(ctx context.Context, name string) (JobStatus, error) {
+	st := JobStatus{Name: name}
+	value, ok, err := s.store.GetMeta(ctx, metaKeyPrefix+name)
+	if err != nil || !ok {
+		return st, err
+	}
+	if err := json.Unmarshal([]byte(value), &st); err != nil {
+		return JobStatus{Name: name}, fmt.Errorf("corrupt status for job %s: %w", name, err)
+	}
+	return st, nil
+}
+
+// Status returns the schedule and the last recorded result of each job.
+// A nil scheduler reports maintenance as disabled.
+func (s *Scheduler) Status(ctx context.Context) (Status, error) {
+	if s == nil {
+		return Status{Jobs: []JobStatus{}}, nil
+	}
+
+	s.mu.Lock()
+	status := Status{
+		Enabled:  true,
+		Schedule: s.schedule.String(),
+		Running:  s.running,
+		Jobs:     make([]JobStatus, 0, len(s.jobs)),
+	}
+	if !s.nextRun.IsZero() {
+		next := s.nextRun
+		status.NextRun = &next
+	}
+	jobs := append([]Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		st, err := s.load(ctx, job.Name)
+		if err != nil {
+			return Status{}, err
+		}
+		status.Jobs = append(status.Jobs, st)
+	}
+	return status, nil
+}
@@ -0,0 +1,291 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires every waiter that is due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) pendingWaiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+type memStore struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (m *memStore) GetMeta(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.data[key]
+	return v, ok, nil
+}
+
+func (m *memStore) SetMeta(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func newTestScheduler(t *testing.T, spec string) (*Scheduler, *fakeClock) {
+	t.Helper()
+	schedule, err := ParseSchedule(spec)
+	assert.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewScheduler(schedule, &memStore{data: map[string]string{}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.clock = clock
+	return s, clock
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func jobStatus(t *testing.T, s *Scheduler, name string) JobStatus {
+	t.Helper()
+	status, err := s.Status(context.Background())
+	assert.NoError(t, err)
+	for _, st := range status.Jobs {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("no status for job %s", name)
+	return JobStatus{}
+}
+
+func TestParseSchedule(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"03:00", time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"daily 13:30", time.Date(2024, 1, 1, 13, 30, 0, 0, time.UTC)},
+		{"12:00", time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)},
+		{"every 6h", at.Add(6 * time.Hour)},
+	}
+	for _, tc := range cases {
+		schedule, err := ParseSchedule(tc.spec)
+		assert.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.next, schedule.Next(at), tc.spec)
+	}
+
+	for _, spec := range []string{"", "25:00", "every", "every 1s", "weekly 03:00", "noon"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduler_SerializesJobsSkipsOverlapAndRecordsStatus(t *testing.T) {
+	s, clock := newTestScheduler(t, "every 1h")
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	enter := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		active++
+		maxActive = max(maxActive, active)
+	}
+	leave := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		active--
+	}
+
+	release := make(chan struct{})
+	slowStarted := make(chan struct{}, 1)
+	s.Register(Job{Name: "slow", Run: func(ctx context.Context) error {
+		enter()
+		defer leave()
+		slowStarted <- struct{}{}
+		<-release
+		return nil
+	}})
+	s.Register(Job{Name: "failing", Run: func(ctx context.Context) error {
+		enter()
+		defer leave()
+		return errors.New("disk full")
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	// First window: slow runs, failing waits its turn
+	waitFor(t, func() bool { return clock.pendingWaiters() == 1 })
+	clock.Advance(time.Hour)
+	<-slowStarted
+	status, err := s.Status(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, status.Running)
+	assert.Equal(t, "every 1h0m0s", status.Schedule)
+	assert.Equal(t, 0, jobStatus(t, s, "failing").Runs)
+
+	// Second window comes up while the first is still running and is skipped
+	waitFor(t, func() bool { return clock.pendingWaiters() == 1 })
+	clock.Advance(time.Hour)
+	waitFor(t, func() bool { return jobStatus(t, s, "slow").Skipped == 1 })
+
+	close(release)
+	waitFor(t, func() bool { return jobStatus(t, s, "failing").Runs == 1 })
+	waitFor(t, func() bool {
+		status, _ := s.Status(context.Background())
+		return !status.Running
+	})
+
+	slow := jobStatus(t, s, "slow")
+	assert.Equal(t, StatusOK, slow.LastStatus)
+	assert.Equal(t, 1, slow.Runs)
+	failing := jobStatus(t, s, "failing")
+	assert.Equal(t, StatusError, failing.LastStatus)
+	assert.Equal(t, "disk full", failing.LastError)
+	assert.Equal(t, 1, failing.Skipped)
+
+	mu.Lock()
+	assert.Equal(t, 1, maxActive)
+	mu.Unlock()
+
+	cancel()
+	s.Wait()
+}
+
+func TestScheduler_ShutdownCancelsRunningJob(t *testing.T) {
+	s, clock := newTestScheduler(t, "every 1h")
+
+	started := make(chan struct{})
+	laterRan := false
+	s.Register(Job{Name: "long", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Register(Job{Name: "later", Run: func(ctx context.Context) error {
+		laterRan = true
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, func() bool { return clock.pendingWaiters() == 1 })
+	clock.Advance(time.Hour)
+	<-started
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop after cancellation")
+	}
+
+	assert.Equal(t, StatusCancelled, jobStatus(t, s, "long").LastStatus)
+	assert.False(t, laterRan)
+	assert.Equal(t, 0, jobStatus(t, s, "later").Runs)
+}
+
+func TestScheduler_WindowWrapper(t *testing.T) {
+	s, clock := newTestScheduler(t, "every 1h")
+
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	s.Register(Job{Name: "a", Run: func(ctx context.Context) error { record("a"); return nil }})
+	s.Register(Job{Name: "b", Run: func(ctx context.Context) error { record("b"); return nil }})
+	s.SetWindowWrapper(func(ctx context.Context, window func(context.Context) error) error {
+		record("before")
+		err := window(ctx)
+		record("after")
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, func() bool { return clock.pendingWaiters() == 1 })
+	clock.Advance(time.Hour)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 4
+	})
+	cancel()
+	s.Wait()
+
+	assert.Equal(t, []string{"before", "a", "b", "after"}, events)
+}
+
+func TestScheduler_NilReportsDisabled(t *testing.T) {
+	var s *Scheduler
+	status, err := s.Status(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.Empty(t, status.Jobs)
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// relationsGenerationKey is the meta key the relations_generation triggers bump
+const relationsGenerationKey = "relations_generation"
+
+// adjacencyBytesPerRelation estimates the memory one relation takes in the adjacency
+// cache: an edge at each end (32 bytes, doubled for slice growth) and, at worst, a
+// map entry for each of its entities
+const adjacencyBytesPerRelation = 2*2*32 + 2*48
+
+// neighborEdge is a relation as seen from one of its entities
+type neighborEdge struct {
+	neighbor     int64
+	relationType string
+	outgoing     bool // the relation points from the entity to neighbor
+}
+
+// adjacency is an in-memory copy of the relations for traversals, valid while the
+// relations generation equals generation
+type adjacency struct {
+	generation int64
+	// edges lists each entity's relations in creation order; nil when the relations
+	// exceed the budget and traversals query the database
+	edges map[int64][]neighborEdge
+	bytes int64 // estimated size
+}
+
+// SetAdjacencyCache enables the in-memory relation index FindPath and GetNeighbors
+// traverse instead of querying the database level by level, with an estimated size
+// of at most maxBytes (0 disables it). It is built on the first traversal and rebuilt on the
+// first one after relations change; a graph over the budget is traversed in SQL.
+func (db *DB) SetAdjacencyCache(maxBytes int64) {
+	db.adjacencyBudget = maxBytes
+	db.adjacency.Store(nil)
+}
+
+// relationsGeneration returns the count of changes made to relations
+func relationsGeneration(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}) (int64, error) {
+	var value string
+	err := q.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = ?", relationsGenerationKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// cachedAdjacency returns the adjacency cache, rebuilt if the relations changed
+// since it was built, or nil when traversals should query the database: the cache
+// is disabled, the relations exceed its budget, or another traversal is rebuilding
+// it. Rebuilds happen aside and are swapped in whole, so no traversal waits for one.
+func (db *DB) cachedAdjacency(ctx context.Context) (*adjacency, error) {
+	if db.adjacencyBudget <= 0 {
+		return nil, nil
+	}
+	generation, err := relationsGeneration(ctx, db.conn)
+	if err != nil {
+		return nil, err
+	}
+	if current := db.adjacency.Load(); current != nil && current.generation == generation {
+		return current.usable(), nil
+	}
+
+	if !db.adjacencyBuild.TryLock() {
+		return nil, nil
+	}
+	defer db.adjacencyBuild.Unlock()
+	next, err := db.buildAdjacency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db.adjacency.Store(next)
+	return next.usable(), nil
+}
+
+func (a *adjacency) usable() *adjacency {
+	if a.edges == nil {
+		return nil
+	}
+	return a
+}
+
+// buildAdjacency reads every relation into a new cache, or returns one without
+// edges if they exceed the budget
+func (db *DB) buildAdjacency(ctx context.Context) (*adjacency, error) {
+	start := time.Now()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next := &adjacency{}
+	if next.generation, err = relationsGeneration(ctx, tx); err != nil {
+		return nil, err
+	}
+	var relations int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM relations").Scan(&relations); err != nil {
+		return nil, err
+	}
+	next.bytes = relations * adjacencyBytesPerRelation
+	if next.bytes > db.adjacencyBudget {
+		// Only warn when the cache stops fitting, not on every rebuild after that
+		level := slog.LevelWarn
+		if previous := db.adjacency.Load(); previous != nil && previous.edges == nil {
+			level = slog.LevelDebug
+		}
+		db.logger.Log(ctx, level, "relations exceed the adjacency cache budget; traversals query the database",
+			slog.Int64("relations", relations),
+			slog.Int64("estimated_bytes", next.bytes),
+			slog.Int64("budget_bytes", db.adjacencyBudget),
+		)
+		return next, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT from_entity_id, to_entity_id, relation_type FROM relations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	next.edges = make(map[int64][]neighborEdge)
+	types := interner{}
+	for rows.Next() {
+		var from, to int64
+		var relationType string
+		if err := rows.Scan(&from, &to, &relationType); err != nil {
+			return nil, err
+		}
+		relationType = types.intern(relationType)
+		next.edges[from] = append(next.edges[from], neighborEdge{neighbor: to, relationType: relationType, outgoing: true})
+		next.edges[to] = append(next.edges[to], neighborEdge{neighbor: from, relationType: relationType})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("adjacency cache built",
+		slog.Int64("relations", relations),
+		slog.Int("entities", len(next.edges)),
+		slog.Int64("estimated_bytes", next.bytes),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return next, nil
+}
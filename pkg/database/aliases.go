@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// aliasesColumn selects the aliases of an entity aliased as e as a JSON array in name
+// order, read back with splitAliases. It is NULL for an entity without aliases.
+const aliasesColumn = `(SELECT json_group_array(alias ORDER BY alias) FROM entity_aliases WHERE entity_id = e.id HAVING COUNT(*) > 0) AS aliases`
+
+// splitAliases parses the JSON array of aliases aliasesColumn selects, returning nil
+// for an entity without aliases
+func splitAliases(aliasesJSON sql.NullString) ([]string, error) {
+	return splitTags(aliasesJSON)
+}
+
+// AliasResult reports what AliasEntity changed
+type AliasResult struct {
+	EntityName string `json:"entityName"`
+	// Added lists the aliases given to the entity
+	Added []string `json:"added"`
+	// Unchanged lists the aliases the entity already had
+	Unchanged []string `json:"unchanged"`
+}
+
+// AliasConflictError reports an alias that is already the name of an entity, or an
+// alias of another entity
+type AliasConflictError struct {
+	Alias string
+	// Entity is the entity named, or aliased, Alias
+	Entity string
+	// IsName is set when Alias is the name of Entity rather than one of its aliases
+	IsName bool
+}
+
+func (e *AliasConflictError) Error() string {
+	if e.IsName {
+		return fmt.Sprintf("alias %s is the name of an entity", e.Alias)
+	}
+	return fmt.Sprintf("alias %s already names entity %s", e.Alias, e.Entity)
+}
+
+// AliasEntity gives an entity other names it can be referred to by: CreateRelations,
+// AddObservations and OpenNodes resolve an alias to the entity when no entity has the
+// name, and SearchNodes matches it. It fails with an EntityNotFoundError if the entity
+// doesn't exist, or an *AliasConflictError if an alias is the name of an entity or
+// an alias of another, adding none of them.
+func (db *DB) AliasEntity(ctx context.Context, name string, aliases []string) (*AliasResult, error) {
+	return retryWriteResult(ctx, db, func() (*AliasResult, error) {
+		return db.aliasEntity(ctx, name, aliases)
+	})
+}
+
+// aliasEntity makes one attempt at AliasEntity
+func (db *DB) aliasEntity(ctx context.Context, name string, aliases []string) (*AliasResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ?", graph, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, &EntityNotFoundError{Name: name}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AliasResult{EntityName: name, Added: []string{}, Unchanged: []string{}}
+	for _, alias := range dedupe(aliases) {
+		var taken bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM entities WHERE graph_id = ? AND name = ?)", graph, alias,
+		).Scan(&taken); err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, &AliasConflictError{Alias: alias, Entity: alias, IsName: true}
+		}
+
+		var ownerID int64
+		var owner string
+		err := tx.QueryRowContext(ctx, `
+			SELECT e.id, e.name FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+			WHERE a.graph_id = ? AND a.alias = ?`, graph, alias,
+		).Scan(&ownerID, &owner)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return nil, err
+		case ownerID == id:
+			result.Unchanged = append(result.Unchanged, alias)
+			continue
+		default:
+			return nil, &AliasConflictError{Alias: alias, Entity: owner}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO entity_aliases (graph_id, alias, entity_id) VALUES (?, ?, ?)", graph, alias, id,
+		); err != nil {
+			return nil, err
+		}
+		result.Added = append(result.Added, alias)
+	}
+
+	if len(result.Added) > 0 {
+		summary := fmt.Sprintf("aliased %s as %s", name, auditQuoted(result.Added))
+		if err := db.auditTx(ctx, tx, graph, "add_alias", []string{name}, summary); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("entity aliases added",
+		slog.String("graph", graphFrom(ctx)),
+		slog.Int("added", len(result.Added)),
+	)
+	return result, nil
+}
+
+// addImportedAliasTx gives alias to the entity entityID of graph, as an import does,
+// and reports whether it was skipped because it names another entity or is already
+// an alias of one
+func addImportedAliasTx(ctx context.Context, tx *sql.Tx, graph, entityID int64, alias string) (bool, error) {
+	result, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO entity_aliases (graph_id, alias, entity_id)
+		SELECT ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM entities WHERE graph_id = ? AND name = ?)`,
+		graph, alias, entityID, graph, alias,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return false, err
+	}
+	var owned bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM entity_aliases WHERE graph_id = ? AND alias = ? AND entity_id = ?)",
+		graph, alias, entityID,
+	).Scan(&owned)
+	return !owned, err
+}
+
+// aliasedEntity is the entity an alias resolves to
+type aliasedEntity struct {
+	id   int64
+	name string
+}
+
+// resolveAliases returns the entities of graph that the names among names which are
+// aliases resolve to, by alias. A name that is the name of an entity is not resolved,
+// so an entity always wins over an alias.
+func resolveAliases(ctx context.Context, q relationQuerier, graph int64, names []string) (map[string]aliasedEntity, error) {
+	resolved := map[string]aliasedEntity{}
+	for _, chunk := range chunks(names, maxListValues-1) {
+		list, args := stringList(chunk)
+		rows, err := q.QueryContext(ctx, `
+			SELECT a.alias, e.id, e.name
+			FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+			WHERE a.graph_id = ? AND a.alias IN `+list+`
+				AND NOT EXISTS (SELECT 1 FROM entities x WHERE x.graph_id = a.graph_id AND x.name = a.alias)`,
+			append([]any{graph}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var alias string
+			var entity aliasedEntity
+			if err := rows.Scan(&alias, &entity.id, &entity.name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			resolved[alias] = entity
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// AddAliases fills in the aliases of the entities of graph. Entities no longer in the
+// database are left without them.
+func (db *DB) AddAliases(ctx context.Context, graph *KnowledgeGraph) error {
+	names := make([]string, len(graph.Entities))
+	byName := make(map[string]*EntityWithObservations, len(graph.Entities))
+	for i := range graph.Entities {
+		names[i] = graph.Entities[i].Name
+		byName[names[i]] = &graph.Entities[i]
+	}
+	scope, err := graphID(ctx, db.reader)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks(names, maxListValues-1) {
+		list, args := stringList(chunk)
+		rows, err := db.reader.QueryContext(ctx, `
+			SELECT e.name, a.alias
+			FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+			WHERE e.graph_id = ? AND e.name IN `+list+`
+			ORDER BY a.alias`, append([]any{scope}, args...)...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name, alias string
+			if err := rows.Scan(&name, &alias); err != nil {
+				rows.Close()
+				return err
+			}
+			byName[name].Aliases = append(byName[name].Aliases, alias)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aliasMatches selects the ids of the entities with an alias containing any of
+// words, the words of a term of an FTS search query
+func aliasMatches(words []string) (string, []any) {
+	condition, args := wordsCondition("alias", words)
+	return "SELECT entity_id AS id FROM entity_aliases WHERE " + condition, args
+}
+
+// wordsCondition matches column against any of the non-empty words as a substring,
+// or nothing if there are none
+func wordsCondition(column string, words []string) (string, []any) {
+	var conds []string
+	var args []any
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		conds = append(conds, column+` LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(word)+"%")
+	}
+	if len(conds) == 0 {
+		return "0", nil
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// canonicalNames returns names with the aliases among them replaced by the names of
+// the entities they resolve to, without repeats
+func canonicalNames(names []string, resolved map[string]aliasedEntity) []string {
+	canonical := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if entity, ok := resolved[name]; ok {
+			name = entity.name
+		}
+		if !seen[name] {
+			seen[name] = true
+			canonical = append(canonical, name)
+		}
+	}
+	return canonical
+}
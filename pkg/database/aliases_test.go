@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedAliases creates Robert Smith, known as Bob and Robert, and Acme
+func seedAliases(t *testing.T, db *DB) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Robert Smith", EntityType: "person", Observations: []string{"likes chess"}},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	result, err := db.AliasEntity(ctx, "Robert Smith", []string{"Bob", "Robert", "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bob", "Robert"}, result.Added)
+	assert.Empty(t, result.Unchanged)
+}
+
+func TestAliasEntity(t *testing.T) {
+	db := newImportTestDB(t)
+	seedAliases(t, db)
+	ctx := context.Background()
+
+	result, err := db.AliasEntity(ctx, "Robert Smith", []string{"Robert", "Bobby"})
+	assert.NoError(t, err)
+	assert.Equal(t, &AliasResult{EntityName: "Robert Smith", Added: []string{"Bobby"}, Unchanged: []string{"Robert"}}, result)
+
+	// An alias can't be an entity's name, its own included, or another's alias, and a
+	// conflict adds none of the aliases
+	var conflict *AliasConflictError
+	_, err = db.AliasEntity(ctx, "Robert Smith", []string{"Rob", "Acme"})
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, AliasConflictError{Alias: "Acme", Entity: "Acme", IsName: true}, *conflict)
+	_, err = db.AliasEntity(ctx, "Robert Smith", []string{"Robert Smith"})
+	assert.ErrorAs(t, err, &conflict)
+	assert.True(t, conflict.IsName)
+	_, err = db.AliasEntity(ctx, "Acme", []string{"Bob"})
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, AliasConflictError{Alias: "Bob", Entity: "Robert Smith"}, *conflict)
+
+	_, err = db.AliasEntity(ctx, "Missing", []string{"Ghost"})
+	var notFound *EntityNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	graph, err := db.OpenNodes(ctx, []string{"Robert Smith", "Acme"})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddAliases(ctx, graph))
+	assert.Equal(t, []string{"Bob", "Bobby", "Robert"}, graph.Entities[1].Aliases)
+	assert.Empty(t, graph.Entities[0].Aliases)
+
+	page, err := db.History(ctx, HistoryFilter{EntityName: "Robert Smith"})
+	assert.NoError(t, err)
+	last := page.Entries[len(page.Entries)-1]
+	assert.Equal(t, "add_alias", last.Operation)
+	assert.Equal(t, `aliased Robert Smith as "Bobby"`, last.Summary)
+}
+
+func TestAliasResolution(t *testing.T) {
+	db := newImportTestDB(t)
+	seedAliases(t, db)
+	ctx := context.Background()
+
+	// A relation naming an alias attaches to the entity
+	result, err := db.CreateRelations(ctx, []RelationDTO{
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Robert", To: "Acme", RelationType: "works_at"},
+		{From: "Bobby", To: "Acme", RelationType: "works_at"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{{From: "Robert Smith", To: "Acme", RelationType: "works_at"}}, result.Relations)
+	assert.Len(t, result.Skipped, 2)
+	assert.Equal(t, SkipDuplicate, result.Skipped[0].Reason)
+	assert.Equal(t, SkipMissingFrom, result.Skipped[1].Reason)
+
+	added, err := db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Bob", Contents: []string{"plays piano"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Robert Smith", added[0].EntityName)
+	assert.Equal(t, []string{"plays piano"}, added[0].AddedObservations)
+
+	// An alias and the name open the entity once
+	graph, err := db.OpenNodes(ctx, []string{"Robert", "Robert Smith", "Acme", "Nobody"})
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2)
+	assert.Equal(t, "Robert Smith", graph.Entities[1].Name)
+	assert.Equal(t, []string{"likes chess", "plays piano"}, graph.Entities[1].Observations)
+	assert.Equal(t, []RelationDTO{{From: "Robert Smith", To: "Acme", RelationType: "works_at"}}, graph.Relations)
+
+	for name, search := range map[string]func() (*SearchResult, error){
+		"like":   func() (*SearchResult, error) { return db.SearchNodes(ctx, "bob", 0, 0) },
+		"fts":    func() (*SearchResult, error) { return db.SearchNodesFTS(ctx, "bob", 0, 0) },
+		"ranked": func() (*SearchResult, error) { return db.SearchNodesRanked(ctx, "bob", 0, 0) },
+		"exact":  func() (*SearchResult, error) { return db.MatchNodes(ctx, "Bob", SearchExact, 0, 0) },
+	} {
+		found, err := search()
+		assert.NoError(t, err, name)
+		assert.Len(t, found.Entities, 1, name)
+		assert.Equal(t, "Robert Smith", found.Entities[0].Name, name)
+	}
+
+	// An entity named like an alias wins over it
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Bob", EntityType: "dog"}})
+	assert.NoError(t, err)
+	graph, err = db.OpenNodes(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "dog", graph.Entities[0].EntityType)
+}
+
+func TestAliasesGoWithEntity(t *testing.T) {
+	db := newImportTestDB(t)
+	seedAliases(t, db)
+	db.SetSoftDelete(true)
+	ctx := context.Background()
+
+	_, err := db.DeleteEntities(ctx, []string{"Robert Smith"})
+	assert.NoError(t, err)
+	graph, err := db.OpenNodes(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+
+	// A trashed entity's aliases are free again
+	_, err = db.AliasEntity(ctx, "Acme", []string{"Bob"})
+	assert.NoError(t, err)
+
+	report, err := db.EraseSubject(ctx, []string{"bob"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Aliases, "the aliases in the trash are erased too")
+	var n int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM entity_aliases WHERE alias = 'Bob'").Scan(&n))
+	assert.Zero(t, n)
+}
+
+func TestAliasesRestoredFromTrash(t *testing.T) {
+	db := newImportTestDB(t)
+	seedAliases(t, db)
+	db.SetSoftDelete(true)
+	ctx := context.Background()
+
+	_, err := db.DeleteEntities(ctx, []string{"Robert Smith"})
+	assert.NoError(t, err)
+	_, err = db.AliasEntity(ctx, "Acme", []string{"Robert"})
+	assert.NoError(t, err)
+
+	restored, err := db.RestoreEntities(ctx, []string{"Robert Smith"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Robert Smith"}, restored.Restored)
+	assert.Equal(t, 1, restored.AliasesDropped, "Robert was taken while it was in the trash")
+
+	graph, err := db.OpenNodes(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Robert Smith", graph.Entities[0].Name, "found by its alias again")
+	}
+	graph, err = db.OpenNodes(ctx, []string{"Robert"})
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Acme", graph.Entities[0].Name)
+	}
+
+	// Deleting it again takes over the alias of the copy deleted earlier
+	_, err = db.DeleteEntities(ctx, []string{"Robert Smith"})
+	assert.NoError(t, err)
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Bobby", EntityType: "person"}})
+	assert.NoError(t, err)
+	_, err = db.AliasEntity(ctx, "Bobby", []string{"Bob"})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(ctx, []string{"Bobby"})
+	assert.NoError(t, err)
+	restored, err = db.RestoreEntities(ctx, []string{"Robert Smith", "Bobby"})
+	assert.NoError(t, err)
+	assert.Len(t, restored.Restored, 2)
+	graph, err = db.OpenNodes(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Bobby", graph.Entities[0].Name)
+	}
+}
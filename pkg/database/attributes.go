@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxAttributesBytes is the largest attributes object, as compact JSON, an entity
+// may store
+const MaxAttributesBytes = 4096
+
+// attributesColumn selects the attributes of an entity aliased as e, read back with
+// splitAttributes. Attributes are kept in the metadata column as a JSON object, NULL
+// for an entity without them.
+const attributesColumn = "e.metadata"
+
+// splitAttributes parses the JSON object attributesColumn selects, returning nil for
+// an entity without attributes
+func splitAttributes(attributesJSON sql.NullString) (map[string]any, error) {
+	if !attributesJSON.Valid {
+		return nil, nil
+	}
+	var attributes map[string]any
+	if err := json.Unmarshal([]byte(attributesJSON.String), &attributes); err != nil {
+		return nil, fmt.Errorf("failed to parse entity attributes: %w", err)
+	}
+	return attributes, nil
+}
+
+// AttributesSizeError reports attributes that would exceed MaxAttributesBytes once
+// merged into an entity's
+type AttributesSizeError struct {
+	Entity string
+	Size   int
+}
+
+func (e *AttributesSizeError) Error() string {
+	return fmt.Sprintf("attributes of entity %s would be %d bytes, over the maximum of %d", e.Entity, e.Size, MaxAttributesBytes)
+}
+
+// EntityUpdate names changes to make to an existing entity
+type EntityUpdate struct {
+	Name string `json:"name"`
+	// Attributes is merged into the entity's attributes as a JSON merge patch
+	// (RFC 7396): keys are set, replacing what was there, and keys set to null
+	// are removed
+	Attributes map[string]any `json:"attributes"`
+}
+
+// UpdatedEntity is an entity as UpdateEntities left it
+type UpdatedEntity struct {
+	Name       string         `json:"name"`
+	EntityType string         `json:"entityType"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// UpdateEntities applies updates to existing entities in one transaction. It fails
+// with an EntityNotFoundError, or an AttributesSizeError, changing nothing, if an
+// entity doesn't exist or its attributes would grow too large.
+func (db *DB) UpdateEntities(ctx context.Context, updates []EntityUpdate) ([]UpdatedEntity, error) {
+	return retryWriteResult(ctx, db, func() ([]UpdatedEntity, error) {
+		return db.updateEntities(ctx, updates)
+	})
+}
+
+func (db *DB) updateEntities(ctx context.Context, updates []EntityUpdate) ([]UpdatedEntity, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]UpdatedEntity, 0, len(updates))
+	for i, update := range updates {
+		if err := checkCancelled(ctx, "update_entities", i, len(updates)); err != nil {
+			return nil, err
+		}
+
+		var entityID int64
+		result := UpdatedEntity{Name: update.Name}
+		err := tx.QueryRowContext(ctx,
+			"SELECT id, entity_type FROM entities WHERE graph_id = ? AND name = ?", graph, update.Name,
+		).Scan(&entityID, &result.EntityType)
+		if err == sql.ErrNoRows {
+			return nil, &EntityNotFoundError{Name: update.Name}
+		}
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "update_entities", i, len(updates))
+		}
+
+		if result.Attributes, err = patchAttributesTx(ctx, tx, entityID, update.Name, update.Attributes); err != nil {
+			return nil, cancelledOr(ctx, err, "update_entities", i, len(updates))
+		}
+		results = append(results, result)
+	}
+
+	names := make([]string, len(updates))
+	changes := make([]string, len(updates))
+	for i, update := range updates {
+		names[i] = update.Name
+		patch, err := json.Marshal(update.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		changes[i] = fmt.Sprintf("%s: %s", update.Name, patch)
+	}
+	if err := db.auditTx(ctx, tx, graph, "update_entities", names, "updated attributes of "+strings.Join(changes, "; ")); err != nil {
+		return nil, err
+	}
+	return results, tx.Commit()
+}
+
+// patchAttributesTx merges patch into the attributes of an entity as a JSON merge
+// patch, moving its update time when they changed, and returns the attributes it
+// was left with. The entity is left without attributes when none remain.
+func patchAttributesTx(ctx context.Context, tx *sql.Tx, entityID int64, name string, patch map[string]any) (map[string]any, error) {
+	var current sql.NullString
+	if len(patch) == 0 {
+		if err := tx.QueryRowContext(ctx, "SELECT metadata FROM entities WHERE id = ?", entityID).Scan(&current); err != nil {
+			return nil, err
+		}
+		return splitAttributes(current)
+	}
+
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	var patched sql.NullString
+	if err := tx.QueryRowContext(ctx,
+		"SELECT metadata, NULLIF(json_patch(COALESCE(metadata, '{}'), ?), '{}') FROM entities WHERE id = ?",
+		string(patchJSON), entityID,
+	).Scan(&current, &patched); err != nil {
+		return nil, err
+	}
+	if len(patched.String) > MaxAttributesBytes {
+		return nil, &AttributesSizeError{Entity: name, Size: len(patched.String)}
+	}
+	if patched != current {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE entities SET metadata = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", patched, entityID,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return splitAttributes(patched)
+}
+
+type attributeSearchKey struct{}
+
+// WithAttributeSearch returns ctx under which searches also match the entities whose
+// attributes hold every whitespace-separated term of query in a string or number
+// value, at any depth
+func WithAttributeSearch(ctx context.Context, query string) context.Context {
+	return context.WithValue(ctx, attributeSearchKey{}, query)
+}
+
+// attributeCondition returns the condition matching an entity aliased as e by its
+// attribute values for the query set by WithAttributeSearch, or "" if there is none.
+// % and _ in the terms match themselves, as in SearchNodes.
+func attributeCondition(ctx context.Context) (string, []any) {
+	query, ok := ctx.Value(attributeSearchKey{}).(string)
+	if !ok {
+		return "", nil
+	}
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		terms = []string{query}
+	}
+
+	groups := make([]string, len(terms))
+	args := make([]any, len(terms))
+	for i, term := range terms {
+		groups[i] = `EXISTS (SELECT 1 FROM json_tree(e.metadata) a
+				WHERE a.type IN ('text', 'integer', 'real') AND CAST(a.atom AS TEXT) LIKE ? ESCAPE '\')`
+		args[i] = "%" + escapeLike(term) + "%"
+	}
+	return strings.Join(groups, " AND "), args
+}
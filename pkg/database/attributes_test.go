@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributes(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	nested := map[string]any{
+		"url":   "https://acme.example",
+		"score": 0.8,
+		"ids":   map[string]any{"crm": "CRM-4711", "legacy": []any{1.0, 2.0}},
+	}
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Acme", EntityType: "company", Attributes: nested},
+		{Name: "Globex", EntityType: "company", Observations: []string{"a rival of Acme"}},
+	})
+	assert.NoError(t, err)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, nested, graph.Entities[0].Attributes, "nested values round-trip")
+	assert.Nil(t, graph.Entities[1].Attributes)
+	entity, err := db.GetEntity(ctx, "Acme")
+	assert.NoError(t, err)
+	assert.Equal(t, nested, entity.Attributes)
+
+	// Updates merge: keys are set, nested objects merged and null keys removed
+	updated, err := db.UpdateEntities(ctx, []EntityUpdate{
+		{Name: "Acme", Attributes: map[string]any{"score": 0.9, "url": nil, "ids": map[string]any{"erp": "E-1"}}},
+		{Name: "Globex", Attributes: map[string]any{"ticker": "GLX"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []UpdatedEntity{
+		{Name: "Acme", EntityType: "company", Attributes: map[string]any{
+			"score": 0.9,
+			"ids":   map[string]any{"crm": "CRM-4711", "legacy": []any{1.0, 2.0}, "erp": "E-1"},
+		}},
+		{Name: "Globex", EntityType: "company", Attributes: map[string]any{"ticker": "GLX"}},
+	}, updated)
+
+	// A missing entity, or attributes grown too large, change nothing
+	_, err = db.UpdateEntities(ctx, []EntityUpdate{{Name: "Globex", Attributes: map[string]any{"ticker": nil}}, {Name: "Nobody", Attributes: map[string]any{"x": 1}}})
+	var notFound *EntityNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	_, err = db.UpdateEntities(ctx, []EntityUpdate{{Name: "Globex", Attributes: map[string]any{"notes": strings.Repeat("x", MaxAttributesBytes)}}})
+	var sizeErr *AttributesSizeError
+	if assert.ErrorAs(t, err, &sizeErr) {
+		assert.Equal(t, "Globex", sizeErr.Entity)
+	}
+	nodes, err := db.OpenNodes(ctx, []string{"Globex"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"ticker": "GLX"}, nodes.Entities[0].Attributes)
+
+	// Removing every key leaves the entity without attributes
+	updated, err = db.UpdateEntities(ctx, []EntityUpdate{{Name: "Globex", Attributes: map[string]any{"ticker": nil}}})
+	assert.NoError(t, err)
+	assert.Nil(t, updated[0].Attributes)
+	var stored *string
+	assert.NoError(t, db.conn.QueryRow("SELECT metadata FROM entities WHERE name = 'Globex'").Scan(&stored))
+	assert.Nil(t, stored)
+
+	// Searches match attribute values, at any depth, only when asked to
+	found, err := db.SearchNodes(ctx, "crm-4711", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Entities)
+	for _, search := range []func(context.Context, string, int, int) (*SearchResult, error){db.SearchNodes, db.SearchNodesFTS, db.SearchNodesRanked} {
+		found, err = search(WithAttributeSearch(ctx, "crm-4711"), "crm-4711", 0, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, found.Entities, 1) {
+			assert.Equal(t, "Acme", found.Entities[0].Name)
+		}
+	}
+	found, err = db.SearchNodes(WithAttributeSearch(ctx, "Acme"), "Acme", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 2, "attribute matches join the others")
+	found, err = db.SearchNodes(WithAttributeSearch(ctx, "0.9"), "0.9", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 1, "numbers match too")
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+)
+
+// MaxAuditSummaryLength is the longest summary, in bytes, an audit log entry keeps;
+// longer ones are cut and end in "..."
+const MaxAuditSummaryLength = 500
+
+// DefaultHistoryLimit is how many entries History returns when no limit is given
+const DefaultHistoryLimit = 100
+
+// MaxHistoryLimit is the most entries History returns at once
+const MaxHistoryLimit = 1000
+
+type toolCallKey struct{}
+
+type toolCall struct {
+	tool, requestID string
+}
+
+// WithToolCall returns ctx carrying the tool and request id of the call making the
+// changes run with it, recorded with them in the audit log
+func WithToolCall(ctx context.Context, tool, requestID string) context.Context {
+	return context.WithValue(ctx, toolCallKey{}, toolCall{tool: tool, requestID: requestID})
+}
+
+// HistoryEntry is one change recorded in the audit log
+type HistoryEntry struct {
+	ID int64 `json:"id"`
+	// At is when the change was made (RFC 3339, UTC)
+	At string `json:"at"`
+	// Operation names the change, such as create_entities or delete_relations
+	Operation string `json:"operation"`
+	// Tool is the tool call that made the change, empty for changes made outside one
+	Tool      string `json:"tool,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	WrittenBy string `json:"writtenBy,omitempty"`
+	Session   string `json:"session,omitempty"`
+	// Entities are the entities the change affected, in name order
+	Entities []string `json:"entities"`
+	Summary  string   `json:"summary"`
+}
+
+// HistoryFilter narrows what History returns
+type HistoryFilter struct {
+	// EntityName keeps the changes that affected the entity
+	EntityName string
+	// Since and Until keep the changes made at or after, and before, them when set
+	Since, Until time.Time
+	// AfterID keeps the changes recorded after the entry with the ID, to read on
+	// from the last entry of a page
+	AfterID int64
+	// Limit is how many entries to return (0 = DefaultHistoryLimit)
+	Limit int
+}
+
+// HistoryPage is a page of the audit log, oldest change first
+type HistoryPage struct {
+	Entries []HistoryEntry `json:"entries"`
+	// HasMore is set when more changes match after the last entry; ask again with
+	// AfterID set to its ID
+	HasMore bool `json:"hasMore"`
+}
+
+// SetAuditRedactor sets the redactor masking secrets, such as API keys pasted into
+// observations, in the summaries recorded in the audit log (nil = none). Stored
+// observations are not changed.
+func (db *DB) SetAuditRedactor(redactor *logging.Redactor) {
+	db.auditRedactor = redactor
+}
+
+// auditTx records a change in the audit log in the transaction making it. operation
+// names the change, entities are the names of those it affected in the graph, and
+// summary says what it did; summaries are redacted, and cut when longer than
+// MaxAuditSummaryLength.
+func (db *DB) auditTx(ctx context.Context, tx *sql.Tx, graph int64, operation string, entities []string, summary string) error {
+	call, _ := ctx.Value(toolCallKey{}).(toolCall)
+	summary = db.auditRedactor.Redact(summary)
+	if len(summary) > MaxAuditSummaryLength {
+		cut := MaxAuditSummaryLength - len("...")
+		for cut > 0 && !utf8.RuneStart(summary[cut]) {
+			cut--
+		}
+		summary = summary[:cut] + "..."
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO audit_log (graph_id, operation, tool, request_id, written_by, session, summary)
+		VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?)
+		RETURNING id`,
+		graph, operation, call.tool, call.requestID, writerFrom(ctx), sessionFrom(ctx), summary,
+	).Scan(&id); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks(dedupe(entities), maxListValues/2) {
+		values := make([]string, len(chunk))
+		args := make([]any, 0, 2*len(chunk))
+		for i, name := range chunk {
+			values[i] = "(?, ?)"
+			args = append(args, name, id)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO audit_log_entities (entity_name, audit_id) VALUES "+strings.Join(values, ", "), args...,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditNames lists up to a few names for a summary, counting the rest
+func auditNames(names []string) string {
+	const shown = 5
+	if len(names) <= shown {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:shown], ", "), len(names)-shown)
+}
+
+// auditRelations lists up to a few relations for a summary, counting the rest
+func auditRelations(relations []RelationDTO) string {
+	names := make([]string, len(relations))
+	for i, rel := range relations {
+		names[i] = rel.From + " " + rel.RelationType + " " + rel.To
+	}
+	return auditNames(names)
+}
+
+// auditQuoted quotes contents, such as observations, for a summary
+func auditQuoted(contents []string) string {
+	quoted := make([]string, len(contents))
+	for i, content := range contents {
+		quoted[i] = strconv.Quote(content)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// relationEntities returns the names of the entities at either end of relations
+func relationEntities(relations []RelationDTO) []string {
+	names := make([]string, 0, 2*len(relations))
+	for _, rel := range relations {
+		names = append(names, rel.From, rel.To)
+	}
+	return names
+}
+
+// History returns the changes recorded in the audit log of the graph that match
+// filter, oldest first
+func (db *DB) History(ctx context.Context, filter HistoryFilter) (*HistoryPage, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	cond, args := "a.graph_id = ?", []any{graph}
+	if filter.EntityName != "" {
+		cond += " AND a.id IN (SELECT audit_id FROM audit_log_entities WHERE entity_name = ?)"
+		args = append(args, filter.EntityName)
+	}
+	if filter.AfterID > 0 {
+		cond += " AND a.id > ?"
+		args = append(args, filter.AfterID)
+	}
+	if !filter.Since.IsZero() {
+		cond += " AND a.created_at >= ?"
+		args = append(args, filter.Since.UTC().Format(sqliteTimeLayout))
+	}
+	if !filter.Until.IsZero() {
+		cond += " AND a.created_at < ?"
+		args = append(args, filter.Until.UTC().Format(sqliteTimeLayout))
+	}
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT
+			a.id,
+			`+rfc3339Column("a.created_at")+`,
+			a.operation,
+			COALESCE(a.tool, ''),
+			COALESCE(a.request_id, ''),
+			COALESCE(a.written_by, ''),
+			COALESCE(a.session, ''),
+			(SELECT json_group_array(entity_name ORDER BY entity_name) FROM audit_log_entities WHERE audit_id = a.id),
+			a.summary
+		FROM audit_log a
+		WHERE `+cond+`
+		ORDER BY a.id
+		LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &HistoryPage{Entries: []HistoryEntry{}}
+	for rows.Next() {
+		var entry HistoryEntry
+		var entities string
+		if err := rows.Scan(&entry.ID, &entry.At, &entry.Operation, &entry.Tool, &entry.RequestID,
+			&entry.WrittenBy, &entry.Session, &entities, &entry.Summary); err != nil {
+			return nil, err
+		}
+		if entry.Entities, err = splitObservations(entities); err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Entries) > limit {
+		page.Entries, page.HasMore = page.Entries[:limit], true
+	}
+	return page, nil
+}
+
+// PruneAuditLog deletes the audit log entries older than maxAge and returns how many
+// were removed
+func (db *DB) PruneAuditLog(ctx context.Context, maxAge time.Duration) (int, error) {
+	return retryWriteResult(ctx, db, func() (int, error) {
+		cutoff := time.Now().UTC().Add(-maxAge).Format(sqliteTimeLayout)
+		result, err := db.conn.ExecContext(ctx, "DELETE FROM audit_log WHERE created_at < ?", cutoff)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			db.logger.Info("pruned audit log", slog.Int64("entries", n))
+		}
+		return int(n), nil
+	})
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := WithToolCall(WithWriter(context.Background(), "tester"), "create_entities", "req-1")
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	ctx = WithToolCall(ctx, "create_relations", "req-2")
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Bob", Contents: []string{"likes tea"}}})
+	assert.NoError(t, err)
+	_, err = db.AddTags(ctx, []EntityTags{{EntityName: "Alice", Tags: []string{"friend"}}})
+	assert.NoError(t, err)
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Alice", Observations: []string{"likes Go"}}})
+	assert.NoError(t, err)
+	_, err = db.DeleteRelations(ctx, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(ctx, []string{"Alice"})
+	assert.NoError(t, err)
+
+	page, err := db.History(ctx, HistoryFilter{EntityName: "Alice"})
+	assert.NoError(t, err)
+	operations := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		operations[i] = entry.Operation
+	}
+	assert.Equal(t, []string{"create_entities", "create_relations", "add_tags", "delete_observations", "delete_relations", "delete_entities"}, operations,
+		"the changes to Alice, in order, without those to Bob alone")
+	assert.False(t, page.HasMore)
+	first := page.Entries[0]
+	assert.Equal(t, "create_entities", first.Tool)
+	assert.Equal(t, "req-1", first.RequestID)
+	assert.Equal(t, "tester", first.WrittenBy)
+	assert.Equal(t, []string{"Alice", "Bob"}, first.Entities)
+	assert.Contains(t, first.Summary, "created 2 entities")
+	assert.Contains(t, page.Entries[3].Summary, `"likes Go"`)
+	assert.Equal(t, "req-2", page.Entries[4].RequestID)
+
+	// Pages follow on from the last entry
+	all, err := db.History(ctx, HistoryFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, all.Entries, 7)
+	first2, err := db.History(ctx, HistoryFilter{Limit: 2})
+	assert.NoError(t, err)
+	assert.True(t, first2.HasMore)
+	rest, err := db.History(ctx, HistoryFilter{AfterID: first2.Entries[1].ID})
+	assert.NoError(t, err)
+	assert.Equal(t, all.Entries[2:], rest.Entries)
+
+	// Time range
+	future, err := db.History(ctx, HistoryFilter{Since: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, future.Entries)
+	past, err := db.History(ctx, HistoryFilter{Until: time.Now().Add(-time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, past.Entries)
+
+	// Graphs keep their history apart
+	other, err := db.History(WithGraph(ctx, "work"), HistoryFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, other.Entries)
+
+	// Entries can't be changed, only pruned
+	_, err = db.conn.Exec("UPDATE audit_log SET summary = 'rewritten'")
+	assert.Error(t, err)
+	pruned, err := db.PruneAuditLog(ctx, time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, pruned)
+	pruned, err = db.PruneAuditLog(ctx, -time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, pruned)
+	var names int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM audit_log_entities").Scan(&names))
+	assert.Zero(t, names)
+}
+
+func TestHistory_LongSummary(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{strings.Repeat("ü", MaxAuditSummaryLength)}},
+	})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Alice", Contents: []string{strings.Repeat("é", MaxAuditSummaryLength)}}})
+	assert.NoError(t, err)
+
+	page, err := db.History(ctx, HistoryFilter{})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Entries, 2) {
+		summary := page.Entries[1].Summary
+		assert.LessOrEqual(t, len(summary), MaxAuditSummaryLength)
+		assert.True(t, strings.HasSuffix(summary, "..."))
+		assert.True(t, utf8.ValidString(summary))
+		assert.Empty(t, page.Entries[1].Tool, "changes outside a tool call have no tool")
+	}
+}
+
+func TestHistory_Redacted(t *testing.T) {
+	db := newImportTestDB(t)
+	redactor, err := logging.NewRedactor(nil)
+	assert.NoError(t, err)
+	db.SetAuditRedactor(redactor)
+	db.SetObservationCap(1, EvictOldest)
+	ctx := context.Background()
+
+	const secret = "sk-abcdefghijklmnopqrstuvwxyz"
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Deploy", EntityType: "service"}})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Deploy", Contents: []string{"key " + secret}}})
+	assert.NoError(t, err)
+	// The observation with the key is evicted by the next one
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Deploy", Contents: []string{"rotated"}}})
+	assert.NoError(t, err)
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Deploy", Observations: []string{"rotated"}}})
+	assert.NoError(t, err)
+
+	page, err := db.History(ctx, HistoryFilter{EntityName: "Deploy"})
+	assert.NoError(t, err)
+	var summaries []string
+	for _, entry := range page.Entries {
+		summaries = append(summaries, entry.Summary)
+	}
+	assert.Equal(t, []string{
+		`created 1 entities: Deploy`,
+		`added Deploy: "key ` + logging.RedactedPlaceholder + `"`,
+		`added Deploy: "rotated"; Deploy evicted: "key ` + logging.RedactedPlaceholder + `"`,
+		`deleted Deploy: "rotated"`,
+	}, summaries)
+	var stored int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM audit_log WHERE summary LIKE '%' || ? || '%'", secret).Scan(&stored))
+	assert.Zero(t, stored)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backupPattern names backup files; the timestamp in them sorts by age
+const (
+	backupPattern    = "backup-*.db"
+	backupTimeLayout = "20060102T150405.000Z"
+)
+
+// Backup writes a consistent copy of the database to a timestamped file in dir,
+// creating dir if needed, then deletes the oldest backups there beyond keep
+// (0 keeps all of them). It returns the path of the new backup. A failed copy
+// leaves no file behind and prunes nothing.
+func (db *DB) Backup(ctx context.Context, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := "backup-" + time.Now().UTC().Format(backupTimeLayout) + ".db"
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("backup %s already exists", path)
+	}
+
+	// Copy under a name the pattern doesn't match, so pruning never counts a
+	// partial backup
+	start := time.Now()
+	tmp := path + ".tmp"
+	if err := db.SnapshotTo(ctx, tmp); err != nil {
+		return "", errors.Join(fmt.Errorf("failed to back up database: %w", err), removeSnapshot(tmp))
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", errors.Join(fmt.Errorf("failed to back up database: %w", err), removeSnapshot(tmp))
+	}
+
+	removed, err := pruneBackups(dir, keep)
+	db.logger.Info("database backed up",
+		slog.String("path", path),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("pruned", len(removed)),
+	)
+	if err != nil {
+		return path, fmt.Errorf("failed to prune old backups: %w", err)
+	}
+	return path, nil
+}
+
+// pruneBackups deletes all but the newest keep backups in dir, returning the paths
+// removed
+func pruneBackups(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, backupPattern))
+	if err != nil || len(paths) <= keep {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var removed []string
+	var errs []error
+	for _, path := range paths[:len(paths)-keep] {
+		if err := removeSnapshot(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+	return removed, errors.Join(errs...)
+}
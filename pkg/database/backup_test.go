@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/maintenance"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup_ScheduledRotation(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}}})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	scheduler := maintenance.NewScheduler(maintenance.Every(20*time.Millisecond), db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	scheduler.Register(maintenance.Job{Name: "backup", Run: func(ctx context.Context) error {
+		_, err := db.Backup(ctx, dir, 2)
+		return err
+	}})
+	runCtx, stop := context.WithCancel(ctx)
+	scheduler.Start(runCtx)
+	assert.Eventually(t, func() bool {
+		status, err := scheduler.Status(ctx)
+		return err == nil && status.Jobs[0].Runs >= 4
+	}, 10*time.Second, 10*time.Millisecond)
+	stop()
+	scheduler.Wait()
+
+	status, err := scheduler.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, maintenance.StatusOK, status.Jobs[0].LastStatus)
+
+	// Only the newest two are kept, and each is a complete database
+	backups, err := filepath.Glob(filepath.Join(dir, backupPattern))
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "no partial copies are left")
+	for _, path := range backups {
+		backup, err := NewReadOnlyDB(path, db.logger)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		graph, err := backup.ReadGraph(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, graph.Entities, 1)
+		backup.Close()
+	}
+}
+
+func TestBackup_KeepAll(t *testing.T) {
+	db := newImportTestDB(t)
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 3; i++ {
+		path, err := db.Backup(context.Background(), dir, 0)
+		assert.NoError(t, err)
+		paths = append(paths, path)
+		time.Sleep(2 * time.Millisecond)
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, backupPattern))
+	assert.NoError(t, err)
+	assert.Equal(t, paths, backups, "names sort oldest first")
+
+	removed, err := pruneBackups(dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, paths[:2], removed)
+}
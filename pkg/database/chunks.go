@@ -0,0 +1,15 @@
+package database
+
+// maxListValues bounds the values one statement binds in IN lists and multi-row
+// inserts. SQLite before 3.32 allows 999 variables per statement; the rest are left
+// for the statement's other parameters.
+const maxListValues = 900
+
+// chunks splits values into consecutive slices of at most size values
+func chunks[T any](values []T, size int) [][]T {
+	out := make([][]T, 0, (len(values)+size-1)/size)
+	for start := 0; start < len(values); start += size {
+		out = append(out, values[start:min(start+size, len(values))])
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// limitVariables lowers the bound variables db's connections allow to the 999 of
+// SQLite builds before 3.32, keeping the read pool to the one connection it limits.
+// It skips the test when the driver can't set connection limits.
+func limitVariables(t *testing.T, db *DB) {
+	t.Helper()
+	db.reader.SetMaxOpenConns(1)
+	for _, pool := range []*sql.DB{db.conn, db.reader} {
+		conn, err := pool.Conn(context.Background())
+		assert.NoError(t, err)
+		limited := false
+		assert.NoError(t, conn.Raw(func(driverConn any) error {
+			limiter, ok := driverConn.(interface{ SetLimit(id, newVal int) int })
+			if ok {
+				limiter.SetLimit(sqliteLimitVariableNumber, 999)
+			}
+			limited = ok
+			return nil
+		}))
+		conn.Close()
+		if !limited {
+			t.Skipf("the %s driver can't lower connection limits", SQL_DRIVER)
+		}
+	}
+}
+
+func TestChunks(t *testing.T) {
+	assert.Empty(t, chunks([]int{}, 2))
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, chunks([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2}}, chunks([]int{1, 2}, 2))
+}
+
+func TestLargeInputs_WithinVariableLimit(t *testing.T) {
+	db := newImportTestDB(t)
+	limitVariables(t, db)
+	ctx := context.Background()
+
+	const count = 1500
+	entities := make([]EntityWithObservations, count)
+	names := make([]string, count)
+	relations := make([]RelationDTO, 0, count)
+	for i := range entities {
+		names[i] = fmt.Sprintf("entity_%04d", i)
+		entities[i] = EntityWithObservations{Name: names[i], EntityType: "bulk", Observations: []string{fmt.Sprintf("note %d", i)}}
+		if i > 0 {
+			relations = append(relations, RelationDTO{From: names[i-1], To: names[i], RelationType: "next"})
+		}
+	}
+	created, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	assert.Len(t, created, count)
+	created, err = db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	assert.Empty(t, created)
+	createdRelations, err := db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+	assert.Len(t, createdRelations.Relations, count-1)
+
+	graph, err := db.OpenNodes(ctx, append(names, names[0]))
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, count) {
+		assert.Equal(t, names[0], graph.Entities[0].Name)
+		assert.Equal(t, names[count-1], graph.Entities[count-1].Name, "in name order across chunks")
+	}
+	if assert.Len(t, graph.Relations, count-1) {
+		assert.Equal(t, relations[0], graph.Relations[0])
+		assert.Equal(t, relations[count-2], graph.Relations[count-2])
+	}
+
+	found, err := db.SearchNodes(ctx, "entity", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, count)
+	assert.Len(t, found.Relations, count-1)
+
+	metadata, err := db.EntityMetadata(ctx, names)
+	assert.NoError(t, err)
+	assert.Len(t, metadata, count)
+
+	_, err = db.DeleteEntities(ctx, names)
+	assert.NoError(t, err)
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+	assert.Empty(t, graph.Relations)
+}
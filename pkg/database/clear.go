@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// ClearReport counts the rows ClearGraph removed
+type ClearReport struct {
+	Entities             int64 `json:"entities"`
+	Observations         int64 `json:"observations"`
+	Relations            int64 `json:"relations"`
+	ArchivedObservations int64 `json:"archivedObservations"`
+	// Protected lists the pinned entities kept, in name order
+	Protected []string `json:"protected,omitempty"`
+}
+
+// ClearGraph deletes every entity, observation, relation and archived observation of
+// the graph and its trash, in one transaction. Other graphs, entity type metadata,
+// settings, imports in progress and the audit log are kept. Pinned entities are kept
+// too, with their observations, archived observations and the relations between
+// them, unless ctx is from WithForceDelete.
+func (db *DB) ClearGraph(ctx context.Context) (*ClearReport, error) {
+	return retryWriteResult(ctx, db, func() (*ClearReport, error) {
+		return db.clearGraph(ctx)
+	})
+}
+
+// clearGraph makes one attempt at ClearGraph
+func (db *DB) clearGraph(ctx context.Context) (*ClearReport, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &ClearReport{}
+	graph, err := graphID(ctx, tx)
+	if err != nil || graph == 0 {
+		return report, err
+	}
+	trash, err := trashGraphID(ctx, tx, graph)
+	if err != nil {
+		return nil, err
+	}
+	if !forceDelete(ctx) {
+		if report.Protected, err = pinnedNames(ctx, tx, graph); err != nil {
+			return nil, err
+		}
+	}
+
+	// ?1 is the graph and ?2 its trash. With pinned entities, each step keeps their
+	// rows; otherwise it deletes all the rows of both.
+	const (
+		cleared = "(SELECT id FROM entities WHERE graph_id IN (?1, ?2))"
+		pinned  = "(SELECT id FROM entities WHERE graph_id = ?1 AND pinned = 1)"
+	)
+	if len(report.Protected) == 0 && db.ftsEnabled {
+		// When no other graph has entities, the FTS indexes go first: the delete
+		// triggers then look up each removed row in an empty index rather than
+		// scanning a full one
+		var alone bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT NOT EXISTS (SELECT 1 FROM entities WHERE graph_id NOT IN (?1, ?2))", graph, trash,
+		).Scan(&alone); err != nil {
+			return nil, err
+		}
+		if alone {
+			for _, table := range []string{"entities_fts", "observations_fts"} {
+				if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, step := range []struct {
+		stmt  string
+		kept  string
+		count *int64
+	}{
+		{"DELETE FROM relations WHERE from_entity_id IN " + cleared,
+			" AND (from_entity_id NOT IN " + pinned + " OR to_entity_id NOT IN " + pinned + ")", &report.Relations},
+		{"DELETE FROM observations WHERE entity_id IN " + cleared, " AND entity_id NOT IN " + pinned, &report.Observations},
+		{"DELETE FROM entities WHERE graph_id IN (?1, ?2)", " AND id NOT IN " + pinned, &report.Entities},
+		{"DELETE FROM archived_observations WHERE graph_id IN (?1, ?2)",
+			" AND entity_name NOT IN (SELECT name FROM entities WHERE id IN " + pinned + ")", &report.ArchivedObservations},
+	} {
+		stmt := step.stmt
+		if len(report.Protected) > 0 {
+			stmt += step.kept
+		}
+		res, err := tx.ExecContext(ctx, stmt, graph, trash)
+		if err != nil {
+			return nil, err
+		}
+		if *step.count, err = res.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	summary := fmt.Sprintf("cleared %d entities, %d observations and %d relations",
+		report.Entities, report.Observations, report.Relations)
+	if len(report.Protected) > 0 {
+		summary += ", keeping pinned " + auditNames(report.Protected)
+	}
+	if err := db.auditTx(ctx, tx, graph, "clear_graph", nil, summary); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Warn("graph cleared",
+		slog.Int64("entities", report.Entities),
+		slog.Int64("observations", report.Observations),
+		slog.Int64("relations", report.Relations),
+		slog.Int64("archived_observations", report.ArchivedObservations),
+		slog.Int("protected", len(report.Protected)),
+	)
+	return report, nil
+}
+
+// pinnedNames returns the names of the pinned entities of graph, outside its trash,
+// in name order
+func pinnedNames(ctx context.Context, tx *sql.Tx, graph int64) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM entities WHERE graph_id = ? AND pinned = 1 ORDER BY name", graph)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearGraph(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking", "works remotely"}},
+		{Name: "Outage", EntityType: "ticket", Observations: []string{"old outage note"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Outage", RelationType: "reported"}})
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetTypeMetadata(ctx, "person", map[string]string{"color": "blue"}))
+	_, err = db.conn.ExecContext(ctx, "UPDATE observations SET created_at = '2000-01-01 00:00:00'")
+	assert.NoError(t, err)
+	db.SetRetentionPolicy(&RetentionPolicy{Rules: []RetentionRule{
+		{EntityType: "ticket", MaxAge: RetentionAge(time.Hour), Action: RetentionArchive},
+	}})
+	_, err = db.ApplyRetention(ctx, time.Now(), false)
+	assert.NoError(t, err)
+
+	report, err := db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &ClearReport{Entities: 2, Observations: 2, Relations: 1, ArchivedObservations: 1}, report)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+	assert.Empty(t, graph.Relations)
+	if db.IsFTSEnabled() {
+		for _, table := range []string{"entities_fts", "observations_fts"} {
+			var rows int
+			assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&rows))
+			assert.Zero(t, rows, table)
+		}
+	}
+	found, err := db.SearchNodes(ctx, "hiking", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Entities)
+
+	// Type metadata is kept, and the graph can be filled again
+	meta, err := db.GetTypeMetadata(ctx, []string{"person"})
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", meta["person"]["color"])
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking"}}})
+	assert.NoError(t, err)
+	found, err = db.SearchNodes(ctx, "hiking", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 1)
+
+	report, err = db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &ClearReport{Entities: 1, Observations: 1}, report)
+}
+
+func TestClearGraph_OtherGraphs(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	other := WithGraph(ctx, "other")
+	for _, ctx := range []context.Context{ctx, other} {
+		_, err := db.CreateEntities(ctx, []EntityWithObservations{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking"}},
+			{Name: "Acme", EntityType: "company"},
+			{Name: "Prefs", EntityType: "settings"},
+		})
+		assert.NoError(t, err)
+		_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+		assert.NoError(t, err)
+		_, err = db.PinEntities(ctx, []string{"Prefs"})
+		assert.NoError(t, err)
+	}
+	db.SetSoftDelete(true)
+	_, err := db.DeleteEntities(ctx, []string{"Acme"})
+	assert.NoError(t, err)
+
+	report, err := db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &ClearReport{Entities: 2, Observations: 1, Protected: []string{"Prefs"}}, report,
+		"the entity in the trash is cleared with the graph, and its relation with it")
+	deleted, err := db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	graph, err := db.ReadGraph(other)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 3, "other graphs are kept")
+	assert.Len(t, graph.Relations, 1)
+	found, err := db.SearchNodes(other, "hiking", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 1)
+	if db.IsFTSEnabled() {
+		found, err = db.SearchNodesFTS(other, "hiking", 0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, found.Entities, 1, "other graphs stay indexed")
+	}
+}
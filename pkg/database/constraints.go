@@ -0,0 +1,192 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// Relation constraint rules, reported in violations
+const (
+	RuleAllowSelf            = "allowSelf"
+	RuleMaxOutgoingPerEntity = "maxOutgoingPerEntity"
+	RuleMaxIncomingPerEntity = "maxIncomingPerEntity"
+)
+
+// RelationConstraint restricts the relations of one type. The zero value allows
+// every relation.
+type RelationConstraint struct {
+	// AllowSelf permits relations from an entity to itself (nil = allowed)
+	AllowSelf *bool `json:"allowSelf,omitempty"`
+	// MaxOutgoingPerEntity bounds the relations of the type from one entity (0 = unlimited)
+	MaxOutgoingPerEntity int `json:"maxOutgoingPerEntity,omitempty"`
+	// MaxIncomingPerEntity bounds the relations of the type to one entity (0 = unlimited)
+	MaxIncomingPerEntity int `json:"maxIncomingPerEntity,omitempty"`
+}
+
+// SelfAllowed reports whether an entity may relate to itself
+func (c RelationConstraint) SelfAllowed() bool {
+	return c.AllowSelf == nil || *c.AllowSelf
+}
+
+// RelationConstraints maps relation types to their constraints
+type RelationConstraints map[string]RelationConstraint
+
+// ParseRelationConstraints reads constraints from JSON such as
+// {"parent_of": {"allowSelf": false}, "reports_to": {"maxOutgoingPerEntity": 1}}
+func ParseRelationConstraints(data []byte) (RelationConstraints, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var constraints RelationConstraints
+	if err := dec.Decode(&constraints); err != nil {
+		return nil, fmt.Errorf("invalid relation constraints: %w", err)
+	}
+	for relationType, c := range constraints {
+		if strings.TrimSpace(relationType) == "" {
+			return nil, fmt.Errorf("invalid relation constraints: empty relation type")
+		}
+		if c.MaxOutgoingPerEntity < 0 || c.MaxIncomingPerEntity < 0 {
+			return nil, fmt.Errorf("invalid relation constraints for %q: limits must not be negative", relationType)
+		}
+	}
+	return constraints, nil
+}
+
+// SetRelationConstraints sets the constraints CreateRelations enforces (nil = none)
+func (db *DB) SetRelationConstraints(constraints RelationConstraints) {
+	db.relationConstraints = maps.Clone(constraints)
+}
+
+// RelationConstraints returns the constraints CreateRelations enforces
+func (db *DB) RelationConstraints() RelationConstraints {
+	return maps.Clone(db.relationConstraints)
+}
+
+// ConstraintViolation is a relation of a create_relations call rejected by a constraint
+type ConstraintViolation struct {
+	// Index is the position of the relation in the call
+	Index    int         `json:"index"`
+	Relation RelationDTO `json:"relation"`
+	Rule     string      `json:"rule"`
+	// Limit is the cap of a cardinality rule
+	Limit int `json:"limit,omitempty"`
+}
+
+// RelationConstraintError rejects a CreateRelations call in which any relation
+// breaks a constraint; nothing is created
+type RelationConstraintError struct {
+	Violations []ConstraintViolation
+}
+
+func (e *RelationConstraintError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = fmt.Sprintf("relations[%d] %s -%s-> %s breaks %s", v.Index, v.Relation.From, v.Relation.RelationType, v.Relation.To, v.Rule)
+	}
+	return "relation constraints violated: " + strings.Join(parts, "; ")
+}
+
+// checkRelationConstraint returns the rule a new relation breaks and its limit, or
+// "" if it breaks none. Counts include the relations created earlier in tx, so a
+// batch cannot exceed a cap.
+func checkRelationConstraint(ctx context.Context, tx *sql.Tx, c RelationConstraint, fromID, toID int64, relationType string) (string, int, error) {
+	if fromID == toID && !c.SelfAllowed() {
+		return RuleAllowSelf, 0, nil
+	}
+	for _, check := range []struct {
+		rule, column string
+		id           int64
+		limit        int
+	}{
+		{RuleMaxOutgoingPerEntity, "from_entity_id", fromID, c.MaxOutgoingPerEntity},
+		{RuleMaxIncomingPerEntity, "to_entity_id", toID, c.MaxIncomingPerEntity},
+	} {
+		if check.limit == 0 {
+			continue
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM relations WHERE "+check.column+" = ? AND relation_type = ?",
+			check.id, relationType,
+		).Scan(&n); err != nil {
+			return "", 0, err
+		}
+		if n >= check.limit {
+			return check.rule, check.limit, nil
+		}
+	}
+	return "", 0, nil
+}
+
+// ConstraintBreach is existing data that breaks a relation constraint, such as data
+// created before the constraint was added
+type ConstraintBreach struct {
+	RelationType string `json:"relationType"`
+	Rule         string `json:"rule"`
+	// Entity relates to itself, or has Count relations of the type over Limit
+	Entity string `json:"entity"`
+	Count  int    `json:"count"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// constraintBreaches lists the existing data breaking the configured constraints,
+// by relation type, rule and entity
+func (db *DB) constraintBreaches(ctx context.Context) ([]ConstraintBreach, error) {
+	types := make([]string, 0, len(db.relationConstraints))
+	for relationType := range db.relationConstraints {
+		types = append(types, relationType)
+	}
+	sort.Strings(types)
+
+	breaches := []ConstraintBreach{}
+	for _, relationType := range types {
+		c := db.relationConstraints[relationType]
+		if !c.SelfAllowed() {
+			if err := db.collectBreaches(ctx, &breaches, relationType, RuleAllowSelf, 0, `
+				SELECT e.name, COUNT(*) FROM relations r JOIN entities e ON e.id = r.from_entity_id
+				WHERE r.relation_type = ? AND r.from_entity_id = r.to_entity_id
+				GROUP BY e.id ORDER BY e.name`, relationType); err != nil {
+				return nil, err
+			}
+		}
+		for _, check := range []struct {
+			rule, column string
+			limit        int
+		}{
+			{RuleMaxOutgoingPerEntity, "from_entity_id", c.MaxOutgoingPerEntity},
+			{RuleMaxIncomingPerEntity, "to_entity_id", c.MaxIncomingPerEntity},
+		} {
+			if check.limit == 0 {
+				continue
+			}
+			if err := db.collectBreaches(ctx, &breaches, relationType, check.rule, check.limit, `
+				SELECT e.name, COUNT(*) FROM relations r JOIN entities e ON e.id = r.`+check.column+`
+				WHERE r.relation_type = ? GROUP BY r.`+check.column+` HAVING COUNT(*) > ? ORDER BY e.name`,
+				relationType, check.limit); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return breaches, nil
+}
+
+func (db *DB) collectBreaches(ctx context.Context, breaches *[]ConstraintBreach, relationType, rule string, limit int, query string, args ...any) error {
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		breach := ConstraintBreach{RelationType: relationType, Rule: rule, Limit: limit}
+		if err := rows.Scan(&breach.Entity, &breach.Count); err != nil {
+			return err
+		}
+		*breaches = append(*breaches, breach)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRelationConstraints(t *testing.T) {
+	constraints, err := ParseRelationConstraints([]byte(`{
+		"parent_of": {"allowSelf": false},
+		"reports_to": {"maxOutgoingPerEntity": 1},
+		"mentors": {"allowSelf": true, "maxIncomingPerEntity": 3}
+	}`))
+	assert.NoError(t, err)
+	assert.False(t, constraints["parent_of"].SelfAllowed())
+	assert.True(t, constraints["reports_to"].SelfAllowed())
+	assert.Equal(t, 1, constraints["reports_to"].MaxOutgoingPerEntity)
+	assert.True(t, constraints["mentors"].SelfAllowed())
+	assert.Equal(t, 3, constraints["mentors"].MaxIncomingPerEntity)
+
+	for name, data := range map[string]string{
+		"unknown field":  `{"parent_of": {"allowSelfs": false}}`,
+		"negative limit": `{"reports_to": {"maxOutgoingPerEntity": -1}}`,
+		"empty type":     `{"": {"allowSelf": false}}`,
+		"not an object":  `[]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRelationConstraints([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func newConstraintTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	_, err := db.CreateEntities(context.Background(), []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Carol", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	return db
+}
+
+func TestCreateRelations_Constraints(t *testing.T) {
+	no := false
+	ctx := context.Background()
+
+	t.Run("self edge", func(t *testing.T) {
+		db := newConstraintTestDB(t)
+		db.SetRelationConstraints(RelationConstraints{"parent_of": {AllowSelf: &no}})
+
+		_, err := db.CreateRelations(ctx, []RelationDTO{
+			{From: "Alice", To: "Bob", RelationType: "parent_of"},
+			{From: "Alice", To: "Alice", RelationType: "parent_of"},
+		})
+		var constraintErr *RelationConstraintError
+		assert.ErrorAs(t, err, &constraintErr)
+		assert.Equal(t, []ConstraintViolation{{
+			Index: 1, Relation: RelationDTO{From: "Alice", To: "Alice", RelationType: "parent_of"}, Rule: RuleAllowSelf,
+		}}, constraintErr.Violations)
+
+		// Nothing was created, and other types may still relate an entity to itself
+		graph, err := db.ReadGraph(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, graph.Relations)
+		created, err := db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Alice", RelationType: "knows"}})
+		assert.NoError(t, err)
+		assert.Len(t, created.Relations, 1)
+	})
+
+	t.Run("cardinality within a batch", func(t *testing.T) {
+		db := newConstraintTestDB(t)
+		db.SetRelationConstraints(RelationConstraints{
+			"reports_to": {MaxOutgoingPerEntity: 1},
+			"mentors":    {MaxIncomingPerEntity: 2},
+		})
+
+		// The second reports_to from Alice exceeds the cap set by the first
+		_, err := db.CreateRelations(ctx, []RelationDTO{
+			{From: "Alice", To: "Bob", RelationType: "reports_to"},
+			{From: "Alice", To: "Carol", RelationType: "reports_to"},
+			{From: "Bob", To: "Carol", RelationType: "reports_to"},
+			{From: "Alice", To: "Carol", RelationType: "mentors"},
+			{From: "Bob", To: "Carol", RelationType: "mentors"},
+			{From: "Carol", To: "Carol", RelationType: "mentors"},
+		})
+		var constraintErr *RelationConstraintError
+		assert.ErrorAs(t, err, &constraintErr)
+		assert.Equal(t, []ConstraintViolation{
+			{Index: 1, Relation: RelationDTO{From: "Alice", To: "Carol", RelationType: "reports_to"}, Rule: RuleMaxOutgoingPerEntity, Limit: 1},
+			{Index: 5, Relation: RelationDTO{From: "Carol", To: "Carol", RelationType: "mentors"}, Rule: RuleMaxIncomingPerEntity, Limit: 2},
+		}, constraintErr.Violations)
+
+		graph, err := db.ReadGraph(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, graph.Relations)
+
+		// Within the caps the batch goes through, and a resent relation is not counted twice
+		created, err := db.CreateRelations(ctx, []RelationDTO{
+			{From: "Alice", To: "Bob", RelationType: "reports_to"},
+			{From: "Alice", To: "Bob", RelationType: "reports_to"},
+			{From: "Bob", To: "Carol", RelationType: "reports_to"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, created.Relations, 2)
+		_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Carol", RelationType: "reports_to"}})
+		assert.ErrorAs(t, err, &constraintErr)
+	})
+
+	t.Run("no constraints", func(t *testing.T) {
+		db := newConstraintTestDB(t)
+		created, err := db.CreateRelations(ctx, []RelationDTO{
+			{From: "Alice", To: "Alice", RelationType: "parent_of"},
+			{From: "Alice", To: "Bob", RelationType: "reports_to"},
+			{From: "Alice", To: "Carol", RelationType: "reports_to"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, created.Relations, 3)
+	})
+}
+
+func TestHygiene_ConstraintBreaches(t *testing.T) {
+	db := newConstraintTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Alice", RelationType: "parent_of"},
+		{From: "Alice", To: "Bob", RelationType: "reports_to"},
+		{From: "Alice", To: "Carol", RelationType: "reports_to"},
+		{From: "Bob", To: "Carol", RelationType: "reports_to"},
+	})
+	assert.NoError(t, err)
+
+	// Constraints added later report the data that breaks them without blocking reads
+	no := false
+	db.SetRelationConstraints(RelationConstraints{
+		"parent_of":  {AllowSelf: &no},
+		"reports_to": {MaxOutgoingPerEntity: 1, MaxIncomingPerEntity: 1},
+	})
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Relations, 4)
+
+	report, err := db.Hygiene(ctx, HygieneOptions{StaleAfter: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, []ConstraintBreach{
+		{RelationType: "parent_of", Rule: RuleAllowSelf, Entity: "Alice", Count: 1},
+		{RelationType: "reports_to", Rule: RuleMaxOutgoingPerEntity, Entity: "Alice", Count: 2, Limit: 1},
+		{RelationType: "reports_to", Rule: RuleMaxIncomingPerEntity, Entity: "Carol", Count: 2, Limit: 1},
+	}, report.ConstraintBreaches)
+}
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// DeletionPreview describes what a delete would remove, without removing it
+type DeletionPreview struct {
+	// Entities that exist and would be deleted, by name
+	Entities []string `json:"entities"`
+	// Observations counts the observations that would be deleted
+	Observations int `json:"observations"`
+	// Relations that exist and would be deleted, including those removed along with
+	// a deleted entity
+	Relations []RelationDTO `json:"relations"`
+	// Protected lists the pinned entities that would be kept
+	Protected []string `json:"protected,omitempty"`
+}
+
+// EntityDeletionResult reports what DeleteEntities removed
+type EntityDeletionResult struct {
+	DeletedEntities int `json:"deletedEntities"`
+	// NotFound lists the names given that matched no entity
+	NotFound []string `json:"notFound"`
+	// Protected lists the pinned entities given, which were kept
+	Protected []string `json:"protected,omitempty"`
+}
+
+// ObservationDeletionResult reports what DeleteObservations removed
+type ObservationDeletionResult struct {
+	DeletedObservations int `json:"deletedObservations"`
+	// NotFound lists the entities given that don't exist
+	NotFound []string `json:"notFound"`
+	// MissingObservations lists, per existing entity, the observations given that it
+	// didn't have
+	MissingObservations []ObservationDeletionInput `json:"missingObservations"`
+}
+
+// RelationDeletionResult reports what DeleteRelations removed
+type RelationDeletionResult struct {
+	DeletedRelations int `json:"deletedRelations"`
+	// NotFound lists the relations given that didn't exist
+	NotFound []RelationDTO `json:"notFound"`
+}
+
+// NewEntityDeletionResult returns the result of deleting names when the entities in
+// deleted existed: each name is counted or reported missing once
+func NewEntityDeletionResult(names []string, deleted map[string]bool) *EntityDeletionResult {
+	result := &EntityDeletionResult{NotFound: []string{}}
+	for _, name := range dedupe(names) {
+		if deleted[name] {
+			result.DeletedEntities++
+		} else {
+			result.NotFound = append(result.NotFound, name)
+		}
+	}
+	return result
+}
+
+// NewObservationDeletionResult returns an empty ObservationDeletionResult
+func NewObservationDeletionResult() *ObservationDeletionResult {
+	return &ObservationDeletionResult{NotFound: []string{}, MissingObservations: []ObservationDeletionInput{}}
+}
+
+// AddMissing records that entity didn't have content
+func (r *ObservationDeletionResult) AddMissing(entity, content string) {
+	if n := len(r.MissingObservations); n > 0 && r.MissingObservations[n-1].EntityName == entity {
+		r.MissingObservations[n-1].Observations = append(r.MissingObservations[n-1].Observations, content)
+		return
+	}
+	r.MissingObservations = append(r.MissingObservations, ObservationDeletionInput{EntityName: entity, Observations: []string{content}})
+}
+
+// NewRelationDeletionResult returns an empty RelationDeletionResult
+func NewRelationDeletionResult() *RelationDeletionResult {
+	return &RelationDeletionResult{NotFound: []RelationDTO{}}
+}
+
+func newDeletionPreview() *DeletionPreview {
+	return &DeletionPreview{Entities: []string{}, Relations: []RelationDTO{}}
+}
+
+// PreviewDeleteEntities reports what DeleteEntities would remove: the named entities
+// that exist, all their observations and every relation from or to them, and the
+// pinned ones it would keep. It reads one snapshot and changes nothing.
+func (db *DB) PreviewDeleteEntities(ctx context.Context, entityNames []string) (*DeletionPreview, error) {
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := newDeletionPreview()
+	if preview.Protected, err = protectedAmong(ctx, tx, graph, entityNames); err != nil {
+		return nil, err
+	}
+	entityNames = withoutNames(entityNames, preview.Protected)
+	relations := map[int64]RelationDTO{}
+	for _, chunk := range chunks(dedupe(entityNames), maxListValues/2-1) {
+		list, args := stringList(chunk)
+		args = append([]any{graph}, args...)
+		rows, err := tx.QueryContext(ctx, "SELECT name FROM entities WHERE graph_id = ? AND name IN "+list, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			preview.Entities = append(preview.Entities, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM observations
+			WHERE entity_id IN (SELECT id FROM entities WHERE graph_id = ? AND name IN `+list+`)`, args...,
+		).Scan(&n); err != nil {
+			return nil, err
+		}
+		preview.Observations += n
+
+		// A relation between entities of different chunks is found twice
+		rows, err = tx.QueryContext(ctx, `
+			SELECT r.id, f.name, t.name, r.relation_type
+			FROM relations r
+			JOIN entities f ON f.id = r.from_entity_id
+			JOIN entities t ON t.id = r.to_entity_id
+			WHERE f.graph_id = ? AND (f.name IN `+list+` OR t.name IN `+list+`)`, append(args, args[1:]...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var rel RelationDTO
+			if err := rows.Scan(&id, &rel.From, &rel.To, &rel.RelationType); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			relations[id] = rel
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(preview.Entities)
+	preview.Relations = sortedRelations(relations)
+	return preview, nil
+}
+
+// PreviewDeleteObservations reports how many observations DeleteObservations would
+// remove: those given that exist on their entity, each counted once. It reads one
+// snapshot and changes nothing.
+func (db *DB) PreviewDeleteObservations(ctx context.Context, deletions []ObservationDeletionInput) (*DeletionPreview, error) {
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := newDeletionPreview()
+	seen := map[[2]string]bool{}
+	for _, del := range deletions {
+		for _, obs := range del.Observations {
+			key := [2]string{del.EntityName, obs}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			var n int
+			if err := tx.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM observations o
+				JOIN entities e ON e.id = o.entity_id
+				WHERE e.graph_id = ? AND e.name = ? AND o.content = ?`, graph, del.EntityName, obs,
+			).Scan(&n); err != nil {
+				return nil, err
+			}
+			preview.Observations += n
+		}
+	}
+	return preview, nil
+}
+
+// PreviewDeleteRelations reports which of the given relations exist, and so would
+// be removed by DeleteRelations. It reads one snapshot and changes nothing.
+func (db *DB) PreviewDeleteRelations(ctx context.Context, relations []RelationDTO) (*DeletionPreview, error) {
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := newDeletionPreview()
+	found := map[int64]RelationDTO{}
+	for _, rel := range relations {
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT r.id FROM relations r
+			JOIN entities f ON f.id = r.from_entity_id
+			JOIN entities t ON t.id = r.to_entity_id
+			WHERE f.graph_id = ? AND f.name = ? AND t.name = ? AND r.relation_type = ?`,
+			graph, rel.From, rel.To, rel.RelationType,
+		).Scan(&id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found[id] = rel
+	}
+	preview.Relations = sortedRelations(found)
+	return preview, nil
+}
+
+// sortedRelations returns the relations ordered by id, oldest first
+func sortedRelations(byID map[int64]RelationDTO) []RelationDTO {
+	ids := make([]int64, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	out := make([]RelationDTO, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, byID[id])
+	}
+	return out
+}
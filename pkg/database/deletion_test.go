@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deletionTestDB returns a database of three people, two of them related to Acme
+func deletionTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Lisbon"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"likes Rust"}},
+		{Name: "Carol", EntityType: "person"},
+		{Name: "Acme", EntityType: "org", Observations: []string{"founded 1999"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+	return db
+}
+
+// graphCounts returns the numbers of entities, observations and relations
+func graphCounts(t *testing.T, db *DB) [3]int {
+	t.Helper()
+	var counts [3]int
+	for i, table := range []string{"entities", "observations", "relations"} {
+		assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&counts[i]))
+	}
+	return counts
+}
+
+func TestPreviewDeleteEntities(t *testing.T) {
+	db := deletionTestDB(t)
+	ctx := context.Background()
+	before := graphCounts(t, db)
+
+	names := []string{"Alice", "Bob", "Alice", "Missing"}
+	preview, err := db.PreviewDeleteEntities(ctx, names)
+	assert.NoError(t, err)
+	assert.Equal(t, &DeletionPreview{
+		Entities:     []string{"Alice", "Bob"},
+		Observations: 3,
+		Relations: []RelationDTO{
+			{From: "Alice", To: "Acme", RelationType: "works_at"},
+			{From: "Bob", To: "Acme", RelationType: "works_at"},
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+		},
+	}, preview)
+	assert.Equal(t, before, graphCounts(t, db), "a preview changes nothing")
+
+	result, err := db.DeleteEntities(ctx, names)
+	assert.NoError(t, err)
+	assert.Equal(t, &EntityDeletionResult{DeletedEntities: 2, NotFound: []string{"Missing"}}, result)
+	after := graphCounts(t, db)
+	assert.Equal(t, [3]int{
+		before[0] - len(preview.Entities),
+		before[1] - preview.Observations,
+		before[2] - len(preview.Relations),
+	}, after)
+
+	preview, err = db.PreviewDeleteEntities(ctx, []string{"Missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, newDeletionPreview(), preview)
+}
+
+func TestPreviewDeleteObservations(t *testing.T) {
+	db := deletionTestDB(t)
+	ctx := context.Background()
+	before := graphCounts(t, db)
+
+	deletions := []ObservationDeletionInput{
+		{EntityName: "Alice", Observations: []string{"likes Go", "likes Go", "likes Python"}},
+		{EntityName: "Acme", Observations: []string{"founded 1999"}},
+		{EntityName: "Missing", Observations: []string{"anything"}},
+	}
+	preview, err := db.PreviewDeleteObservations(ctx, deletions)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, preview.Observations)
+	assert.Empty(t, preview.Entities)
+	assert.Empty(t, preview.Relations)
+	assert.Equal(t, before, graphCounts(t, db))
+
+	result, err := db.DeleteObservations(ctx, deletions)
+	assert.NoError(t, err)
+	assert.Equal(t, preview.Observations, result.DeletedObservations)
+	assert.Equal(t, []string{"Missing"}, result.NotFound)
+	assert.Equal(t, []ObservationDeletionInput{{EntityName: "Alice", Observations: []string{"likes Python"}}}, result.MissingObservations)
+	assert.Equal(t, before[1]-preview.Observations, graphCounts(t, db)[1])
+}
+
+func TestPreviewDeleteRelations(t *testing.T) {
+	db := deletionTestDB(t)
+	ctx := context.Background()
+	before := graphCounts(t, db)
+
+	relations := []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Bob", To: "Alice", RelationType: "knows"},
+		{From: "Carol", To: "Missing", RelationType: "knows"},
+	}
+	preview, err := db.PreviewDeleteRelations(ctx, relations)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}}, preview.Relations)
+	assert.Equal(t, before, graphCounts(t, db))
+
+	result, err := db.DeleteRelations(ctx, relations)
+	assert.NoError(t, err)
+	assert.Equal(t, len(preview.Relations), result.DeletedRelations)
+	assert.Equal(t, relations[2:], result.NotFound)
+	assert.Equal(t, before[2]-len(preview.Relations), graphCounts(t, db)[2])
+}
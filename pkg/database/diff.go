@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// EntityTypeChange is an entity whose type differs from the expected graph
+type EntityTypeChange struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// ObservationDiff lists how an entity's observations differ from the expected graph
+type ObservationDiff struct {
+	Name    string   `json:"name"`
+	Missing []string `json:"missing,omitempty"`
+	Extra   []string `json:"extra,omitempty"`
+	// OrderChanged is true when the observations both graphs share are in a different order
+	OrderChanged bool `json:"orderChanged,omitempty"`
+}
+
+// GraphDiff describes how an actual graph differs from an expected one. Missing items
+// are expected but absent; extra items are present but not expected.
+type GraphDiff struct {
+	MissingEntities    []string           `json:"missingEntities"`
+	ExtraEntities      []string           `json:"extraEntities"`
+	TypeChanges        []EntityTypeChange `json:"typeChanges"`
+	ObservationChanges []ObservationDiff  `json:"observationChanges"`
+	MissingRelations   []RelationDTO      `json:"missingRelations"`
+	ExtraRelations     []RelationDTO      `json:"extraRelations"`
+}
+
+// Empty reports whether the graphs matched
+func (d *GraphDiff) Empty() bool {
+	return len(d.MissingEntities) == 0 && len(d.ExtraEntities) == 0 && len(d.TypeChanges) == 0 &&
+		len(d.ObservationChanges) == 0 && len(d.MissingRelations) == 0 && len(d.ExtraRelations) == 0
+}
+
+// DiffOptions controls what DiffGraphs treats as a difference
+type DiffOptions struct {
+	// IgnoreObservationOrder compares each entity's observations as a set
+	IgnoreObservationOrder bool `json:"ignoreObservationOrder"`
+	// IgnoreEntityTypes excludes entities of these types, and relations touching
+	// them, from both graphs
+	IgnoreEntityTypes []string `json:"ignoreEntityTypes"`
+}
+
+// CompareOptions are the assertions checked by CompareSnapshot
+type CompareOptions struct {
+	DiffOptions
+	// FailOnMissingEntities fails the comparison when expected entities are absent.
+	// Otherwise they, and expected relations touching them, are reported but allowed.
+	FailOnMissingEntities bool `json:"failOnMissingEntities"`
+}
+
+// CompareResult is the verdict of a snapshot comparison
+type CompareResult struct {
+	Pass bool `json:"pass"`
+	// Failures explains each kind of difference that failed the comparison
+	Failures []string   `json:"failures"`
+	Diff     *GraphDiff `json:"diff"`
+}
+
+// DiffGraphs compares actual against expected. Entities appearing more than once in a
+// graph are combined, as a merge would.
+func DiffGraphs(expected, actual *KnowledgeGraph, opts DiffOptions) *GraphDiff {
+	ignored := make(map[string]bool, len(opts.IgnoreEntityTypes))
+	for _, t := range opts.IgnoreEntityTypes {
+		ignored[t] = true
+	}
+	want, wantSkipped := indexEntities(expected, ignored)
+	got, gotSkipped := indexEntities(actual, ignored)
+
+	diff := &GraphDiff{
+		MissingEntities:    []string{},
+		ExtraEntities:      []string{},
+		TypeChanges:        []EntityTypeChange{},
+		ObservationChanges: []ObservationDiff{},
+		MissingRelations:   []RelationDTO{},
+		ExtraRelations:     []RelationDTO{},
+	}
+	for _, name := range sortedKeys(want) {
+		w := want[name]
+		g, ok := got[name]
+		if !ok {
+			diff.MissingEntities = append(diff.MissingEntities, name)
+			continue
+		}
+		if w.EntityType != g.EntityType {
+			diff.TypeChanges = append(diff.TypeChanges, EntityTypeChange{Name: name, Expected: w.EntityType, Actual: g.EntityType})
+		}
+		if obs := diffObservations(w.Observations, g.Observations, opts.IgnoreObservationOrder); obs != nil {
+			obs.Name = name
+			diff.ObservationChanges = append(diff.ObservationChanges, *obs)
+		}
+	}
+	for _, name := range sortedKeys(got) {
+		if _, ok := want[name]; !ok {
+			diff.ExtraEntities = append(diff.ExtraEntities, name)
+		}
+	}
+
+	skipped := func(r RelationDTO) bool {
+		return wantSkipped[r.From] || wantSkipped[r.To] || gotSkipped[r.From] || gotSkipped[r.To]
+	}
+	wantRels := relationSet(expected.Relations, skipped)
+	gotRels := relationSet(actual.Relations, skipped)
+	for _, r := range wantRels.sorted() {
+		if !gotRels[r] {
+			diff.MissingRelations = append(diff.MissingRelations, r)
+		}
+	}
+	for _, r := range gotRels.sorted() {
+		if !wantRels[r] {
+			diff.ExtraRelations = append(diff.ExtraRelations, r)
+		}
+	}
+	return diff
+}
+
+// Diff compares the database's graph, with all observations, against expected
+func (db *DB) Diff(ctx context.Context, expected *KnowledgeGraph, opts DiffOptions) (*GraphDiff, error) {
+	actual, err := db.readGraph(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	return DiffGraphs(expected, actual, opts), nil
+}
+
+// CompareSnapshot diffs the database against a JSONL snapshot, in the import format,
+// and checks the result against opts
+func (db *DB) CompareSnapshot(ctx context.Context, snapshot io.Reader, opts CompareOptions) (*CompareResult, error) {
+	expected, err := DecodeGraphJSONL(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := db.Diff(ctx, expected, opts.DiffOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CompareResult{Failures: []string{}, Diff: diff}
+	fail := func(n int, what string) {
+		if n > 0 {
+			result.Failures = append(result.Failures, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	missingRelations := len(diff.MissingRelations)
+	if opts.FailOnMissingEntities {
+		fail(len(diff.MissingEntities), "expected entities missing")
+	} else {
+		missing := make(map[string]bool, len(diff.MissingEntities))
+		for _, name := range diff.MissingEntities {
+			missing[name] = true
+		}
+		for _, r := range diff.MissingRelations {
+			if missing[r.From] || missing[r.To] {
+				missingRelations--
+			}
+		}
+	}
+	fail(len(diff.ExtraEntities), "unexpected entities")
+	fail(len(diff.TypeChanges), "entity types changed")
+	fail(len(diff.ObservationChanges), "entities with changed observations")
+	fail(missingRelations, "expected relations missing")
+	fail(len(diff.ExtraRelations), "unexpected relations")
+	result.Pass = len(result.Failures) == 0
+	return result, nil
+}
+
+// DecodeGraphJSONL reads the entities and relations of a graph in the import format
+// line by line, so only the decoded graph, not the raw input, is held in memory.
+// Parse failures are reported as an *ImportLineError; warnings are discarded.
+func DecodeGraphJSONL(r io.Reader) (*KnowledgeGraph, error) {
+	records, _, err := readGraphRecords(r, parseGraphLine, &importWarnings{})
+	if err != nil {
+		return nil, err
+	}
+	return recordsToGraph(records), nil
+}
+
+// indexEntities maps entity names to entities, combining repeated names and leaving out
+// entities of ignored types, which are returned separately by name
+func indexEntities(graph *KnowledgeGraph, ignored map[string]bool) (map[string]*EntityWithObservations, map[string]bool) {
+	index := make(map[string]*EntityWithObservations, len(graph.Entities))
+	skipped := make(map[string]bool)
+	for _, e := range graph.Entities {
+		if ignored[e.EntityType] {
+			skipped[e.Name] = true
+			continue
+		}
+		if existing, ok := index[e.Name]; ok {
+			existing.Observations = appendMissing(existing.Observations, e.Observations)
+			continue
+		}
+		entity := e
+		entity.Observations = appendMissing(nil, e.Observations)
+		index[e.Name] = &entity
+	}
+	for name := range skipped {
+		delete(index, name)
+	}
+	return index, skipped
+}
+
+// appendMissing appends the values not already in dst, keeping their order
+func appendMissing(dst, values []string) []string {
+	seen := make(map[string]bool, len(dst))
+	for _, v := range dst {
+		seen[v] = true
+	}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			dst = append(dst, v)
+		}
+	}
+	return dst
+}
+
+// diffObservations returns nil when the observation lists match
+func diffObservations(want, got []string, ignoreOrder bool) *ObservationDiff {
+	wantSet := make(map[string]bool, len(want))
+	for _, o := range want {
+		wantSet[o] = true
+	}
+	gotSet := make(map[string]bool, len(got))
+	for _, o := range got {
+		gotSet[o] = true
+	}
+
+	diff := &ObservationDiff{}
+	var wantShared, gotShared []string
+	for _, o := range want {
+		if gotSet[o] {
+			wantShared = append(wantShared, o)
+		} else {
+			diff.Missing = append(diff.Missing, o)
+		}
+	}
+	for _, o := range got {
+		if wantSet[o] {
+			gotShared = append(gotShared, o)
+		} else {
+			diff.Extra = append(diff.Extra, o)
+		}
+	}
+	if !ignoreOrder {
+		for i := range wantShared {
+			if wantShared[i] != gotShared[i] {
+				diff.OrderChanged = true
+				break
+			}
+		}
+	}
+	if len(diff.Missing) == 0 && len(diff.Extra) == 0 && !diff.OrderChanged {
+		return nil
+	}
+	return diff
+}
+
+type relationKeySet map[RelationDTO]bool
+
+func relationSet(relations []RelationDTO, skip func(RelationDTO) bool) relationKeySet {
+	set := make(relationKeySet, len(relations))
+	for _, r := range relations {
+		if !skip(r) {
+			set[r.key()] = true
+		}
+	}
+	return set
+}
+
+func (s relationKeySet) sorted() []RelationDTO {
+	out := make([]RelationDTO, 0, len(s))
+	for r := range s {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		if out[i].To != out[j].To {
+			return out[i].To < out[j].To
+		}
+		return out[i].RelationType < out[j].RelationType
+	})
+	return out
+}
+
+func sortedKeys(m map[string]*EntityWithObservations) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffGraphs(t *testing.T) {
+	expected, err := DecodeGraphJSONL(strings.NewReader(importFixture))
+	assert.NoError(t, err)
+
+	// A graph matches itself, including its repeated entity line
+	assert.True(t, DiffGraphs(expected, expected, DiffOptions{}).Empty())
+
+	actual := &KnowledgeGraph{
+		Entities: []EntityWithObservations{
+			{Name: "Alice", EntityType: "person", Observations: []string{"likes go", "engineer", "on call"}},
+			{Name: "Acme", EntityType: "company", Observations: []string{"founded 1999"}},
+			{Name: "Scratch", EntityType: "note", Observations: []string{}},
+		},
+		Relations: []RelationDTO{
+			{From: "Alice", To: "Acme", RelationType: "works_at"},
+			{From: "Scratch", To: "Alice", RelationType: "mentions"},
+		},
+	}
+	diff := DiffGraphs(expected, actual, DiffOptions{IgnoreEntityTypes: []string{"note"}})
+	assert.Equal(t, []string{"Bob"}, diff.MissingEntities)
+	assert.Empty(t, diff.ExtraEntities)
+	assert.Equal(t, []EntityTypeChange{{Name: "Acme", Expected: "org", Actual: "company"}}, diff.TypeChanges)
+	assert.Equal(t, []ObservationDiff{{Name: "Alice", Extra: []string{"on call"}, OrderChanged: true}}, diff.ObservationChanges)
+	assert.Equal(t, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "reports_to"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+	}, diff.MissingRelations)
+	assert.Empty(t, diff.ExtraRelations)
+
+	diff = DiffGraphs(expected, actual, DiffOptions{IgnoreObservationOrder: true})
+	assert.False(t, diff.ObservationChanges[0].OrderChanged)
+	assert.Equal(t, []string{"Scratch"}, diff.ExtraEntities)
+
+	_, err = DecodeGraphJSONL(strings.NewReader("{\"type\":\"entity\",\"name\":\"A\",\"entityType\":\"t\"}\n\n{\"type\":\"bogus\"}\n"))
+	var lineErr *ImportLineError
+	assert.ErrorAs(t, err, &lineErr)
+	assert.Equal(t, 3, lineErr.Line)
+}
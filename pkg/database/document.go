@@ -0,0 +1,466 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// GraphDocumentVersion is the version of the document Export writes. Import reads
+// documents up to it.
+const GraphDocumentVersion = 2
+
+// GraphDocument is the portable JSON document Export writes and Import reads. Export
+// streams it rather than building one, so the type describes the format.
+type GraphDocument struct {
+	Version    int              `json:"version"`
+	ExportedAt string           `json:"exportedAt"`
+	Entities   []DocumentEntity `json:"entities"`
+	Relations  []RelationDTO    `json:"relations"`
+}
+
+// DocumentEntity is an entity of a GraphDocument, its observations oldest first. Version
+// 2 added its tags, attributes, pin and aliases, and the confidence and note of
+// relations.
+type DocumentEntity struct {
+	Name         string         `json:"name"`
+	Type         string         `json:"type"`
+	Observations []string       `json:"observations"`
+	Tags         []string       `json:"tags,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Pinned       bool           `json:"pinned,omitempty"`
+	Aliases      []string       `json:"aliases,omitempty"`
+	// CreatedAt is in RFC 3339 UTC
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// Export writes the graph named by ctx as a GraphDocument from one read snapshot. Entities
+// are written one at a time as they are read, so memory use does not grow with the
+// graph. Import reads the document back.
+func (db *DB) Export(ctx context.Context, w io.Writer) error {
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	header, err := json.Marshal(time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(bw, `{"version":%d,"exportedAt":%s,"entities":[`, GraphDocumentVersion, header)
+
+	if err := exportDocumentEntities(ctx, tx, bw, graph); err != nil {
+		return err
+	}
+	bw.WriteString(`],"relations":[`)
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT f.name, t.name, r.relation_type, COALESCE(r.confidence, 0), COALESCE(r.note, '')
+		FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE f.graph_id = ?
+		ORDER BY r.id`, graph)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		var rel RelationDTO
+		if err := rows.Scan(&rel.From, &rel.To, &rel.RelationType, &rel.Confidence, &rel.Note); err != nil {
+			return err
+		}
+		if err := writeDocumentItem(bw, i, rel); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// exportDocumentEntities writes the entities of graph as those of a GraphDocument,
+// reading entities and observations in a single ordered query so only one entity is
+// held in memory
+func exportDocumentEntities(ctx context.Context, tx *sql.Tx, bw *bufio.Writer, graph int64) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type, strftime('%Y-%m-%dT%H:%M:%SZ', e.created_at),
+			`+tagsColumn+`, `+attributesColumn+`, `+pinnedColumn+`, `+aliasesColumn+`, o.content
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id AND `+liveObservation("o")+`
+		WHERE e.graph_id = ? AND e.deleted_at IS NULL
+		ORDER BY e.id, o.created_at, o.id`, graph)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *DocumentEntity
+	var currentID int64
+	written := 0
+	for rows.Next() {
+		var id int64
+		var name, entityType string
+		var pinned bool
+		var createdAt, tags, attributes, aliases, content sql.NullString
+		if err := rows.Scan(&id, &name, &entityType, &createdAt, &tags, &attributes, &pinned, &aliases, &content); err != nil {
+			return err
+		}
+		if current == nil || id != currentID {
+			if current != nil {
+				if err := writeDocumentItem(bw, written, current); err != nil {
+					return err
+				}
+				written++
+			}
+			current = &DocumentEntity{Name: name, Type: entityType, Observations: []string{}, Pinned: pinned, CreatedAt: createdAt.String}
+			if current.Tags, current.Attributes, current.Aliases, err = splitEntityColumns(tags, attributes, aliases); err != nil {
+				return err
+			}
+			currentID = id
+		}
+		if content.Valid {
+			current.Observations = append(current.Observations, content.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return writeDocumentItem(bw, written, current)
+	}
+	return nil
+}
+
+// writeDocumentItem writes v as element i of a JSON array
+func writeDocumentItem(bw *bufio.Writer, i int, v any) error {
+	if i > 0 {
+		bw.WriteByte(',')
+	}
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	// Encode ends the value with a newline, which is whitespace between elements
+	return enc.Encode(v)
+}
+
+// Strategies of Import for entities of the document that already exist
+const (
+	// ImportSkip leaves existing entities untouched
+	ImportSkip = "skip"
+	// ImportMerge adds the document's missing observations to existing entities
+	ImportMerge = "merge"
+	// ImportReplace deletes existing entities, with their observations and
+	// relations, and creates them again from the document
+	ImportReplace = "replace"
+)
+
+// ImportReport counts what Import did. EntitiesCreated excludes replaced entities.
+type ImportReport struct {
+	Strategy string `json:"strategy"`
+	MergeReport
+	EntitiesSkipped  int `json:"entitiesSkipped"`
+	EntitiesReplaced int `json:"entitiesReplaced"`
+}
+
+// Import reads a GraphDocument, decoding it one entity or relation at a time, and
+// imports it as ImportDocument does
+func (db *DB) Import(ctx context.Context, r io.Reader, strategy string) (*ImportReport, error) {
+	records, createdAt, err := readGraphDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	return db.importRecords(ctx, records, createdAt, strategy)
+}
+
+// ImportDocument imports doc, all of it or a part such as only some entities, in
+// one transaction. Entities that already exist are handled by strategy, ImportMerge
+// when empty; the entities it creates keep the document's createdAt. Observations
+// already stored are skipped, as are relations already stored or between entities
+// that exist neither in the database nor in the document. Relations may come
+// before the entities they link.
+func (db *DB) ImportDocument(ctx context.Context, doc *GraphDocument, strategy string) (*ImportReport, error) {
+	if doc.Version > GraphDocumentVersion {
+		return nil, fmt.Errorf("unsupported document version %d: this server reads up to %d", doc.Version, GraphDocumentVersion)
+	}
+	records := make([]graphRecord, 0, len(doc.Entities)+len(doc.Relations))
+	createdAt := make(map[string]string)
+	for _, entity := range doc.Entities {
+		rec, at, err := entityRecord(entity)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+		if at != "" {
+			createdAt[entity.Name] = at
+		}
+	}
+	for _, rel := range doc.Relations {
+		records = append(records, relationRecord(rel))
+	}
+	return db.importRecords(ctx, records, createdAt, strategy)
+}
+
+// importRecords merges records, with the creation times of their entities, under
+// strategy
+func (db *DB) importRecords(ctx context.Context, records []graphRecord, createdAt map[string]string, strategy string) (*ImportReport, error) {
+	switch strategy {
+	case "":
+		strategy = ImportMerge
+	case ImportSkip, ImportMerge, ImportReplace:
+	default:
+		return nil, fmt.Errorf("invalid import strategy %q", strategy)
+	}
+	start := time.Now()
+
+	// The input is read up front, so only the transaction is retried
+	report, err := retryWriteResult(ctx, db, func() (*ImportReport, error) {
+		tx, err := db.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		// Entity ids are never reused, so the entities the merge creates are the
+		// ones above the current largest id
+		var lastID int64
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM entities").Scan(&lastID); err != nil {
+			return nil, err
+		}
+
+		report := &ImportReport{Strategy: strategy}
+		pending := records
+		if strategy != ImportMerge {
+			if pending, err = applyImportStrategyTx(ctx, tx, records, report); err != nil {
+				return nil, err
+			}
+		}
+		merged, err := db.mergeRecordsTx(ctx, tx, pending)
+		if err != nil {
+			return nil, err
+		}
+		report.MergeReport = *merged
+		report.EntitiesCreated -= report.EntitiesReplaced
+
+		for name, at := range createdAt {
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE entities SET created_at = ? WHERE name = ? AND id > ?",
+				at, name, lastID,
+			); err != nil {
+				return nil, err
+			}
+		}
+		return report, tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("graph document import finished",
+		slog.String("strategy", strategy),
+		slog.Int("entities_created", report.EntitiesCreated),
+		slog.Int("entities_skipped", report.EntitiesSkipped),
+		slog.Int("entities_replaced", report.EntitiesReplaced),
+		slog.Int("relations_created", report.RelationsCreated),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return report, nil
+}
+
+// applyImportStrategyTx prepares records for the merge under report.Strategy:
+// ImportSkip drops the entities that already exist and ImportReplace deletes them,
+// so the merge creates them anew. Each name is counted once, however often the
+// records repeat it.
+func applyImportStrategyTx(ctx context.Context, tx *sql.Tx, records []graphRecord, report *ImportReport) ([]graphRecord, error) {
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]graphRecord, 0, len(records))
+	existed := make(map[string]bool)
+	for i, rec := range records {
+		if err := checkCancelled(ctx, "import_graph", i, len(records)); err != nil {
+			return nil, err
+		}
+		if rec.Kind != RecordEntity {
+			kept = append(kept, rec)
+			continue
+		}
+
+		exists, seen := existed[rec.Name]
+		if !seen {
+			var id int64
+			err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ? AND deleted_at IS NULL", graph, rec.Name).Scan(&id)
+			switch {
+			case err == sql.ErrNoRows:
+			case err != nil:
+				return nil, err
+			default:
+				exists = true
+				if report.Strategy == ImportSkip {
+					report.EntitiesSkipped++
+					break
+				}
+				// Observations first, so their delete triggers run as for DeleteEntities
+				if _, err := tx.ExecContext(ctx, "DELETE FROM observations WHERE entity_id = ?", id); err != nil {
+					return nil, err
+				}
+				if _, err := tx.ExecContext(ctx, "DELETE FROM entities WHERE id = ?", id); err != nil {
+					return nil, err
+				}
+				report.EntitiesReplaced++
+			}
+			existed[rec.Name] = exists
+		}
+		if exists && report.Strategy == ImportSkip {
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	return kept, nil
+}
+
+// entityRecord returns entity as a merge record, with its creation time in the
+// format of CURRENT_TIMESTAMP
+func entityRecord(entity DocumentEntity) (graphRecord, string, error) {
+	if entity.Name == "" {
+		return graphRecord{}, "", errors.New("document entity without a name")
+	}
+	rec := graphRecord{
+		Kind:       RecordEntity,
+		Name:       entity.Name,
+		EntityType: entity.Type,
+		Tags:       entity.Tags,
+		Attributes: entity.Attributes,
+		Pinned:     entity.Pinned,
+		Aliases:    entity.Aliases,
+	}
+	for _, content := range entity.Observations {
+		rec.Observations = append(rec.Observations, recordObservation{Content: content})
+	}
+	if entity.CreatedAt == "" {
+		return rec, "", nil
+	}
+	t, err := time.Parse(time.RFC3339, entity.CreatedAt)
+	if err != nil {
+		return graphRecord{}, "", fmt.Errorf("entity %q: invalid createdAt: %w", entity.Name, err)
+	}
+	return rec, t.UTC().Format(sqliteTimeLayout), nil
+}
+
+// relationRecord returns rel as a merge record
+func relationRecord(rel RelationDTO) graphRecord {
+	return graphRecord{
+		Kind:         RecordRelation,
+		From:         rel.From,
+		To:           rel.To,
+		RelationType: rel.RelationType,
+		Confidence:   rel.Confidence,
+		Note:         rel.Note,
+	}
+}
+
+// readGraphDocument decodes a GraphDocument into merge records, and the creation
+// time of each entity that has one in the format of CURRENT_TIMESTAMP. A missing
+// version is read as the current one.
+func readGraphDocument(r io.Reader) ([]graphRecord, map[string]string, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, nil, err
+	}
+
+	var records []graphRecord
+	createdAt := make(map[string]string)
+	version := 0
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := token.(string)
+		switch key {
+		case "version":
+			if err := dec.Decode(&version); err != nil {
+				return nil, nil, fmt.Errorf("invalid document version: %w", err)
+			}
+			if version < 1 || version > GraphDocumentVersion {
+				return nil, nil, fmt.Errorf("unsupported document version %d: this server reads up to %d", version, GraphDocumentVersion)
+			}
+		case "entities":
+			err = decodeDocumentArray(dec, func() error {
+				var entity DocumentEntity
+				if err := dec.Decode(&entity); err != nil {
+					return err
+				}
+				rec, at, err := entityRecord(entity)
+				if err != nil {
+					return err
+				}
+				records = append(records, rec)
+				if at != "" {
+					createdAt[entity.Name] = at
+				}
+				return nil
+			})
+		case "relations":
+			err = decodeDocumentArray(dec, func() error {
+				var rel RelationDTO
+				if err := dec.Decode(&rel); err != nil {
+					return err
+				}
+				records = append(records, relationRecord(rel))
+				return nil
+			})
+		default:
+			// exportedAt and unknown keys carry nothing to import
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid graph document: %s: %w", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, nil, err
+	}
+	return records, createdAt, nil
+}
+
+// decodeDocumentArray calls decode for each element of the JSON array dec is at
+func decodeDocumentArray(dec *json.Decoder, decode func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := decode(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token of dec, which must be delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid graph document: %w", err)
+	}
+	if token != delim {
+		return fmt.Errorf("invalid graph document: expected %s, found %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exportDocument returns db's graph document with exportedAt blanked, so exports
+// taken at different times compare equal
+func exportDocument(t *testing.T, db *DB) string {
+	t.Helper()
+	var buf bytes.Buffer
+	assert.NoError(t, db.Export(context.Background(), &buf))
+	var doc GraphDocument
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	doc.ExportedAt = ""
+	out, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Plan", EntityType: "doc", Observations: []string{"drafted", `quotes " and <html>`}},
+		{Name: "Acme", EntityType: "org", Observations: []string{"founded 1999"}},
+		{Name: "Empty", EntityType: "doc"},
+	})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Plan", Contents: []string{"reviewed"}}})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Plan", To: "Acme", RelationType: "owned_by"},
+		{From: "Empty", To: "Plan", RelationType: "blocks"},
+	})
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "UPDATE entities SET created_at = '2024-03-01 09:30:00' WHERE name = 'Plan'")
+	assert.NoError(t, err)
+
+	var exported bytes.Buffer
+	assert.NoError(t, db.Export(ctx, &exported))
+	var doc GraphDocument
+	assert.NoError(t, json.Unmarshal(exported.Bytes(), &doc))
+	assert.Equal(t, GraphDocumentVersion, doc.Version)
+	assert.NotEmpty(t, doc.ExportedAt)
+	if assert.Len(t, doc.Entities, 3) {
+		assert.Equal(t, DocumentEntity{
+			Name:         "Plan",
+			Type:         "doc",
+			Observations: []string{"drafted", `quotes " and <html>`, "reviewed"},
+			CreatedAt:    "2024-03-01T09:30:00Z",
+		}, doc.Entities[0])
+		assert.Equal(t, []string{}, doc.Entities[2].Observations)
+	}
+	assert.Equal(t, []RelationDTO{
+		{From: "Plan", To: "Acme", RelationType: "owned_by"},
+		{From: "Empty", To: "Plan", RelationType: "blocks"},
+	}, doc.Relations)
+
+	before, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	document := exportDocument(t, db)
+
+	// Wipe and re-import
+	_, err = db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	report, err := db.Import(ctx, bytes.NewReader(exported.Bytes()), ImportMerge)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.EntitiesCreated)
+	assert.Equal(t, 4, report.ObservationsAdded)
+	assert.Equal(t, 2, report.RelationsCreated)
+
+	after, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+	assert.Equal(t, document, exportDocument(t, db), "createdAt is restored")
+
+	// Importing again changes nothing
+	report, err = db.Import(ctx, bytes.NewReader(exported.Bytes()), ImportMerge)
+	assert.NoError(t, err)
+	assert.Zero(t, report.EntitiesCreated)
+	assert.Zero(t, report.ObservationsAdded)
+	assert.Zero(t, report.RelationsCreated)
+	assert.Equal(t, document, exportDocument(t, db))
+
+	// Into another database
+	dst := newImportTestDB(t)
+	_, err = dst.Import(ctx, bytes.NewReader(exported.Bytes()), ImportMerge)
+	assert.NoError(t, err)
+	assert.Equal(t, document, exportDocument(t, dst))
+}
+
+func TestExport_RoundTripsProperties(t *testing.T) {
+	src := newPropertiesTestDB(t)
+	ctx := context.Background()
+
+	document := exportDocument(t, src)
+	assert.Contains(t, document, `"tags":["q3","roadmap"],"attributes":{"score":0.5,"url":"https://example.com/plan"}`)
+	assert.Contains(t, document, `"pinned":true,"aliases":["ACME Corp","Acme Inc"]`)
+	assert.Contains(t, document, `"relationType":"owned_by","confidence":0.75,"note":"from the kickoff"`)
+
+	dst := newImportTestDB(t)
+	_, err := dst.Import(ctx, strings.NewReader(document), ImportMerge)
+	assert.NoError(t, err)
+	assertProperties(t, dst)
+	assert.Equal(t, document, exportDocument(t, dst))
+
+	// Replacing an entity restores its properties rather than keeping the old ones
+	_, err = dst.conn.ExecContext(ctx, "UPDATE entities SET pinned = 0, metadata = NULL")
+	assert.NoError(t, err)
+	_, err = dst.Import(ctx, strings.NewReader(document), ImportReplace)
+	assert.NoError(t, err)
+	assertProperties(t, dst)
+}
+
+func TestExport_Empty(t *testing.T) {
+	db := newImportTestDB(t)
+	var buf bytes.Buffer
+	assert.NoError(t, db.Export(context.Background(), &buf))
+	var doc GraphDocument
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, []DocumentEntity{}, doc.Entities)
+	assert.Equal(t, []RelationDTO{}, doc.Relations)
+}
+
+func TestImport_Invalid(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	for name, tc := range map[string]struct {
+		doc  string
+		want string
+	}{
+		"not an object":   {`[]`, "expected {"},
+		"newer version":   {`{"version":3,"entities":[]}`, "unsupported document version 3"},
+		"unnamed entity":  {`{"version":1,"entities":[{"type":"person"}]}`, "without a name"},
+		"bad createdAt":   {`{"version":1,"entities":[{"name":"Alice","createdAt":"yesterday"}]}`, "invalid createdAt"},
+		"truncated":       {`{"version":1,"entities":[{"name":"Alice"}`, "invalid graph document"},
+		"entities object": {`{"version":1,"entities":{}}`, "expected ["},
+	} {
+		_, err := db.Import(ctx, strings.NewReader(tc.doc), ImportMerge)
+		assert.ErrorContains(t, err, tc.want, name)
+	}
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities, "nothing is merged from an invalid document")
+
+	_, err = db.Import(ctx, strings.NewReader(`{"entities":[]}`), "overwrite")
+	assert.ErrorContains(t, err, "invalid import strategy")
+
+	// Unknown keys are skipped, and a part of a document without a version imports
+	report, err := db.Import(ctx, strings.NewReader(`{"source":{"host":"a"},"entities":[{"name":"Alice","type":"person"}]}`), ImportMerge)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.EntitiesCreated)
+}
+
+func TestImportDocument_Strategies(t *testing.T) {
+	ctx := context.Background()
+	doc := &GraphDocument{
+		Version: GraphDocumentVersion,
+		// Alice's relations come before Dave, whom only the document creates
+		Relations: []RelationDTO{
+			{From: "Alice", To: "Dave", RelationType: "mentors"},
+			{From: "Alice", To: "Carol", RelationType: "reports_to"},
+		},
+		Entities: []DocumentEntity{
+			{Name: "Alice", Type: "engineer", Observations: []string{"likes Go", "likes Go", "joined 2021"}, CreatedAt: "2021-04-01T08:00:00Z"},
+			{Name: "Carol", Type: "person", Observations: []string{"manager"}},
+			{Name: "Dave", Type: "person"},
+		},
+	}
+
+	for _, tc := range []struct {
+		strategy     string
+		want         ImportReport
+		aliceType    string
+		aliceObs     []string
+		aliceCreated string
+		relations    int
+	}{
+		{
+			strategy:  ImportSkip,
+			want:      ImportReport{Strategy: ImportSkip, MergeReport: MergeReport{EntitiesCreated: 2, ObservationsAdded: 1, RelationsCreated: 2}, EntitiesSkipped: 1},
+			aliceType: "person",
+			aliceObs:  []string{"likes Go", "writes docs"},
+			relations: 3,
+		},
+		{
+			strategy:  ImportMerge,
+			want:      ImportReport{Strategy: ImportMerge, MergeReport: MergeReport{EntitiesCreated: 2, EntitiesMerged: 1, ObservationsAdded: 2, RelationsCreated: 2}},
+			aliceType: "person",
+			aliceObs:  []string{"likes Go", "writes docs", "joined 2021"},
+			relations: 3,
+		},
+		{
+			strategy:     ImportReplace,
+			want:         ImportReport{Strategy: ImportReplace, MergeReport: MergeReport{EntitiesCreated: 2, ObservationsAdded: 3, RelationsCreated: 2}, EntitiesReplaced: 1},
+			aliceType:    "engineer",
+			aliceObs:     []string{"likes Go", "joined 2021"},
+			aliceCreated: "2021-04-01T08:00:00Z",
+			relations:    2,
+		},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			db := newImportTestDB(t)
+			_, err := db.CreateEntities(ctx, []EntityWithObservations{
+				{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "writes docs"}},
+				{Name: "Bob", EntityType: "person"},
+			})
+			assert.NoError(t, err)
+			_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Bob", To: "Alice", RelationType: "knows"}})
+			assert.NoError(t, err)
+
+			report, err := db.ImportDocument(ctx, doc, tc.strategy)
+			assert.NoError(t, err)
+			report.Conflicts = nil
+			assert.Equal(t, tc.want, *report)
+
+			graph, err := db.OpenNodes(ctx, []string{"Alice"})
+			assert.NoError(t, err)
+			if assert.Len(t, graph.Entities, 1) {
+				assert.Equal(t, tc.aliceType, graph.Entities[0].EntityType)
+				assert.Equal(t, tc.aliceObs, graph.Entities[0].Observations)
+			}
+			var created string
+			assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT strftime('%Y-%m-%dT%H:%M:%SZ', created_at) FROM entities WHERE name = 'Alice'").Scan(&created))
+			if tc.aliceCreated != "" {
+				assert.Equal(t, tc.aliceCreated, created)
+			} else {
+				assert.NotEqual(t, "2021-04-01T08:00:00Z", created, "an existing entity keeps its createdAt")
+			}
+
+			all, err := db.ReadGraph(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, all.Entities, 4)
+			assert.Len(t, all.Relations, tc.relations, "replace drops Bob's relation to the old Alice")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ExportDOT writes the whole graph in Graphviz DOT format, with each entity type's
+// metadata as attributes of its nodes
+func (db *DB) ExportDOT(ctx context.Context, w io.Writer) error {
+	return db.ExportView(ctx, w, ViewDOT, ViewOptions{})
+}
+
+// WriteDOT writes graph as a DOT digraph. Nodes are labelled with the entity name and
+// carry entityType plus every metadata key of their type, so Graphviz applies hints
+// such as color and group and other consumers can read their own keys. Relations
+// become edges labelled with their type.
+func WriteDOT(w io.Writer, graph *KnowledgeGraph, meta TypeMetadata) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph memory {\n")
+	for _, e := range graph.Entities {
+		attrs := [][2]string{{"label", e.Name}, {"entityType", e.EntityType}}
+		hints := meta[e.EntityType]
+		keys := make([]string, 0, len(hints))
+		for k := range hints {
+			if k != "label" && k != "entityType" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			attrs = append(attrs, [2]string{k, hints[k]})
+		}
+
+		bw.WriteString("  " + dotQuote(e.Name) + " [")
+		for i, a := range attrs {
+			if i > 0 {
+				bw.WriteString(", ")
+			}
+			bw.WriteString(dotID(a[0]) + "=" + dotQuote(a[1]))
+		}
+		bw.WriteString("];\n")
+	}
+	for _, r := range graph.Relations {
+		bw.WriteString("  " + dotQuote(r.From) + " -> " + dotQuote(r.To) + " [label=" + dotQuote(r.RelationType) + "];\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+// dotID returns s unquoted when it is a plain DOT ID, and quoted otherwise
+func dotID(s string) string {
+	if s == "" {
+		return dotQuote(s)
+	}
+	for i, r := range s {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return dotQuote(s)
+		}
+	}
+	return s
+}
+
+// dotQuote returns s as a quoted DOT ID
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"strings"
+)
+
+// The SQLite driver, github.com/mattn/go-sqlite3, which needs cgo, is kept behind
+// driver_mattn.go: SQL_DRIVER, openReadPool and errorCode. The rest of the package
+// goes through them and the driver-neutral checks here.
+
+// Primary result codes of SQLite errors, the same whichever driver reports them
+const (
+	sqliteBusy   = 5 // SQLITE_BUSY
+	sqliteLocked = 6 // SQLITE_LOCKED
+)
+
+// sqliteLimitVariableNumber is SQLITE_LIMIT_VARIABLE_NUMBER, the limit on the bound
+// variables of one statement
+const sqliteLimitVariableNumber = 9
+
+// isMissingFTS5 reports whether err is SQLite lacking the FTS5 module, which the
+// driver only reports in the message. It has the module only with the sqlite_fts5
+// build tag.
+func isMissingFTS5(err error) bool {
+	return strings.Contains(err.Error(), "no such module: fts5")
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	SQL_DRIVER = "sqlite3"
+	// READ_SQL_DRIVER opens the connections of the read pool, each set up with
+	// readPragmas as it connects
+	READ_SQL_DRIVER = "sqlite3_read"
+)
+
+func init() {
+	sql.Register(READ_SQL_DRIVER, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			for _, pragma := range readPragmas {
+				if _, err := conn.Exec(pragma, nil); err != nil {
+					return fmt.Errorf("failed to execute %s: %w", pragma, err)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// openReadPool opens the read pool of the database file at path
+func openReadPool(path string) (*sql.DB, error) {
+	return sql.Open(READ_SQL_DRIVER, path)
+}
+
+// errorCode returns the primary result code of err when it is a SQLite error
+func errorCode(err error) (int, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, false
+	}
+	return int(sqliteErr.Code), true
+}
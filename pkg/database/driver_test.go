@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// busyError returns the error the driver reports for a write to a database another
+// connection holds locked
+func busyError(t *testing.T) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "locked.db")
+	var conns []*sql.DB
+	for i := 0; i < 2; i++ {
+		conn, err := sql.Open(SQL_DRIVER, path)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		conn.SetMaxOpenConns(1)
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+	}
+	_, err := conns[1].Exec("PRAGMA busy_timeout = 0")
+	assert.NoError(t, err)
+	_, err = conns[0].Exec("BEGIN IMMEDIATE")
+	assert.NoError(t, err)
+	defer conns[0].Exec("ROLLBACK")
+
+	_, err = conns[1].Exec("CREATE TABLE locked (id INTEGER)")
+	assert.Error(t, err)
+	return err
+}
+
+func TestIsBusy(t *testing.T) {
+	busy := busyError(t)
+	assert.True(t, isBusy(busy), busy)
+	assert.True(t, isBusy(fmt.Errorf("insert: %w", busy)), "wrapped")
+	assert.False(t, isBusy(errors.New("database is locked")), "not a SQLite error")
+
+	db := setupTestDB(t)
+	defer db.Close()
+	_, err := db.conn.Exec("INSERT INTO entities (name) VALUES ('Alice')")
+	if assert.Error(t, err) {
+		assert.False(t, isBusy(err), "a constraint error")
+	}
+}
+
+// pragmaValue returns the value of pragma on conn
+func pragmaValue(t *testing.T, conn *sql.Conn, pragma string) string {
+	t.Helper()
+	var value string
+	assert.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA "+pragma).Scan(&value))
+	return value
+}
+
+func TestPragmas(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	conn, err := db.conn.Conn(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "wal", pragmaValue(t, conn, "journal_mode"))
+	assert.Equal(t, "1", pragmaValue(t, conn, "foreign_keys"))
+	assert.Equal(t, "5000", pragmaValue(t, conn, "busy_timeout"))
+	assert.Equal(t, "0", pragmaValue(t, conn, "query_only"))
+	conn.Close()
+
+	// Every connection of the read pool is set up, not only the first
+	var readers []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.reader.Conn(ctx)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		readers = append(readers, conn)
+	}
+	for i, conn := range readers {
+		assert.Equal(t, "1", pragmaValue(t, conn, "query_only"), "reader %d", i)
+		assert.Equal(t, "1", pragmaValue(t, conn, "foreign_keys"), "reader %d", i)
+		assert.Equal(t, "5000", pragmaValue(t, conn, "busy_timeout"), "reader %d", i)
+		assert.Equal(t, "-64000", pragmaValue(t, conn, "cache_size"), "reader %d", i)
+	}
+}
+
+func TestFTSDetection(t *testing.T) {
+	assert.True(t, isMissingFTS5(errors.New("no such module: fts5")))
+	assert.True(t, isMissingFTS5(errors.New("SQL logic error: no such module: fts5 (1)")))
+	assert.False(t, isMissingFTS5(errors.New("no such table: entities_fts")))
+
+	db := setupTestDB(t)
+	defer db.Close()
+	var compiled bool
+	assert.NoError(t, db.conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&compiled))
+	assert.Equal(t, compiled, db.IsFTSEnabled(), "FTS is enabled exactly when the driver's SQLite has FTS5")
+}
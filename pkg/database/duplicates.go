@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultDuplicateLimit is how many clusters FindDuplicates returns when no limit is given
+const DefaultDuplicateLimit = 50
+
+// MaxDuplicateLimit is the most clusters FindDuplicates returns at once
+const MaxDuplicateLimit = 500
+
+// DuplicateOptions configures FindDuplicates
+type DuplicateOptions struct {
+	// EntityType keeps only the entities of the type when set
+	EntityType string
+	// Threshold is the edit ratio of normalized names, from 0 to 1, from which two
+	// entities of the same type are clustered as similar (0 = only names equal
+	// ignoring case, spacing and punctuation)
+	Threshold float64
+	// Limit is how many clusters to return (0 = DefaultDuplicateLimit)
+	Limit int
+}
+
+// DuplicateCandidate is an entity of a DuplicateCluster
+type DuplicateCandidate struct {
+	Name         string `json:"name"`
+	EntityType   string `json:"entityType"`
+	Observations int    `json:"observations"`
+	Relations    int    `json:"relations"`
+}
+
+// DuplicateCluster is a set of entities that may be the same thing, in name order
+type DuplicateCluster struct {
+	Entities []DuplicateCandidate `json:"entities"`
+	// Reason is DuplicateCase or DuplicatePunctuation when every name is the same
+	// normalized, and DuplicateSimilar when some were only similar
+	Reason string `json:"reason"`
+	// Similarity is the lowest edit ratio linking a DuplicateSimilar cluster
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// DuplicateReport lists the clusters of likely duplicate entities, by first name
+type DuplicateReport struct {
+	Clusters []DuplicateCluster `json:"clusters"`
+	// HasMore is set when more clusters were found than the limit
+	HasMore bool `json:"hasMore"`
+	// SkippedTypes have too many entities to compare for similar names
+	SkippedTypes []string `json:"skippedTypes,omitempty"`
+}
+
+// FindDuplicates clusters the entities of the graph whose names are equal ignoring
+// case, spacing and punctuation, and with opts.Threshold, those of one type whose
+// normalized names are at least that similar. Clusters are linked transitively, so
+// "VS Code", "VSCode" and a name similar to either form one.
+func (db *DB) FindDuplicates(ctx context.Context, opts DuplicateOptions) (*DuplicateReport, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultDuplicateLimit
+	}
+	limit = min(limit, MaxDuplicateLimit)
+
+	cond, args := "", []any{graph}
+	if opts.EntityType != "" {
+		cond = " AND entity_type = ?"
+		args = append(args, opts.EntityType)
+	}
+	rows, err := db.reader.QueryContext(ctx,
+		"SELECT id, name, entity_type FROM entities WHERE graph_id = ?"+cond+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var candidates []DuplicateCandidate
+	for rows.Next() {
+		var id int64
+		var candidate DuplicateCandidate
+		if err := rows.Scan(&id, &candidate.Name, &candidate.EntityType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		candidates = append(candidates, candidate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{Clusters: []DuplicateCluster{}}
+	clusters := clusterDuplicates(candidates, opts.Threshold, report)
+	if len(clusters) > limit {
+		clusters, report.HasMore = clusters[:limit], true
+	}
+
+	// Only the entities listed are counted
+	index := map[int64]*DuplicateCandidate{}
+	var listed []int64
+	for _, cluster := range clusters {
+		for _, i := range cluster.members {
+			index[ids[i]] = &candidates[i]
+			listed = append(listed, ids[i])
+		}
+	}
+	for _, chunk := range chunks(listed, maxListValues) {
+		list, args := inList(chunk)
+		rows, err := db.reader.QueryContext(ctx, `
+			SELECT e.id,
+				(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id AND `+liveObservation("o")+`),
+				(SELECT COUNT(*) FROM relations r WHERE r.from_entity_id = e.id OR r.to_entity_id = e.id)
+			FROM entities e
+			WHERE e.id IN `+list, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var observations, relations int
+			if err := rows.Scan(&id, &observations, &relations); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			index[id].Observations, index[id].Relations = observations, relations
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cluster := range clusters {
+		found := DuplicateCluster{Reason: cluster.reason, Similarity: cluster.similarity}
+		for _, i := range cluster.members {
+			found.Entities = append(found.Entities, candidates[i])
+		}
+		report.Clusters = append(report.Clusters, found)
+	}
+	return report, nil
+}
+
+// duplicateCluster is a cluster of candidates by index, in name order
+type duplicateCluster struct {
+	members    []int
+	reason     string
+	similarity float64
+}
+
+// clusterDuplicates links the candidates, in name order, with equal name keys or,
+// within a type, names at least threshold similar, and returns the clusters of two or
+// more. Types too large to compare are added to report.SkippedTypes.
+func clusterDuplicates(candidates []DuplicateCandidate, threshold float64, report *DuplicateReport) []duplicateCluster {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		ra, rb := find(a), find(b)
+		// The earlier name, in name order, is the root
+		if ra < rb {
+			parent[rb] = ra
+		} else if rb < ra {
+			parent[ra] = rb
+		}
+	}
+
+	keys := make([]string, len(candidates))
+	byKey := map[string]int{}
+	byType := map[string][]int{}
+	for i, candidate := range candidates {
+		keys[i] = nameKey(candidate.Name)
+		if first, ok := byKey[keys[i]]; ok {
+			union(first, i)
+		} else {
+			byKey[keys[i]] = i
+		}
+		byType[candidate.EntityType] = append(byType[candidate.EntityType], i)
+	}
+
+	// lowest is the lowest similarity linking each cluster, by root, once known
+	lowest := map[int]float64{}
+	if threshold > 0 {
+		type link struct {
+			a, b  int
+			score float64
+		}
+		var links []link
+		types := make([]string, 0, len(byType))
+		for t := range byType {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			members := byType[t]
+			if len(members) > maxFuzzyTypeSize {
+				report.SkippedTypes = append(report.SkippedTypes, t)
+				continue
+			}
+			for x, a := range members {
+				for _, b := range members[x+1:] {
+					ka, kb := keys[a], keys[b]
+					if ka == kb || stripDigits(ka) == stripDigits(kb) {
+						continue // linked above, or numbered siblings such as "Server 1" and "Server 2"
+					}
+					if score := editRatio(ka, kb); score >= threshold {
+						links = append(links, link{a, b, score})
+						union(a, b)
+					}
+				}
+			}
+		}
+		for _, l := range links {
+			root := find(l.a)
+			if score, ok := lowest[root]; !ok || l.score < score {
+				lowest[root] = l.score
+			}
+		}
+	}
+
+	byRoot := map[int]*duplicateCluster{}
+	var clusters []*duplicateCluster
+	for i := range candidates {
+		root := find(i)
+		cluster, ok := byRoot[root]
+		if !ok {
+			cluster = &duplicateCluster{}
+			byRoot[root] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.members = append(cluster.members, i)
+	}
+
+	var found []duplicateCluster
+	for _, cluster := range clusters {
+		if len(cluster.members) < 2 {
+			continue
+		}
+		first := candidates[cluster.members[0]].Name
+		cluster.reason = DuplicateCase
+		for _, i := range cluster.members[1:] {
+			if !strings.EqualFold(candidates[i].Name, first) {
+				cluster.reason = DuplicatePunctuation
+			}
+		}
+		if score, ok := lowest[find(cluster.members[0])]; ok {
+			cluster.reason, cluster.similarity = DuplicateSimilar, math.Round(score*1000)/1000
+		}
+		found = append(found, *cluster)
+	}
+	return found
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func clusterNames(report *DuplicateReport) [][]string {
+	names := [][]string{}
+	for _, cluster := range report.Clusters {
+		var members []string
+		for _, entity := range cluster.Entities {
+			members = append(members, entity.Name)
+		}
+		names = append(names, members)
+	}
+	return names
+}
+
+func TestFindDuplicates(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "VS Code", EntityType: "tool", Observations: []string{"editor"}},
+		{Name: "VSCode", EntityType: "tool"},
+		{Name: "vs-code", EntityType: "tool"},
+		{Name: "Visual Studio Code", EntityType: "tool"},
+		{Name: "Kubernetes", EntityType: "tool"},
+		{Name: "Kubernets", EntityType: "tool"},
+		{Name: "kubernetes", EntityType: "project"},
+		{Name: "Alice", EntityType: "person"},
+		{Name: "alice", EntityType: "person"},
+		{Name: "Server 1", EntityType: "host"},
+		{Name: "Server 2", EntityType: "host"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "VS Code", RelationType: "uses"}})
+	assert.NoError(t, err)
+
+	// Normalized names only, across types
+	report, err := db.FindDuplicates(ctx, DuplicateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Alice", "alice"},
+		{"Kubernetes", "kubernetes"},
+		{"VS Code", "VSCode", "vs-code"},
+	}, clusterNames(report))
+	assert.Equal(t, DuplicateCase, report.Clusters[0].Reason)
+	assert.Equal(t, DuplicatePunctuation, report.Clusters[2].Reason)
+	assert.Equal(t, DuplicateCandidate{Name: "VS Code", EntityType: "tool", Observations: 1, Relations: 1}, report.Clusters[2].Entities[0])
+	assert.Equal(t, "project", report.Clusters[1].Entities[1].EntityType, "types may differ")
+	assert.False(t, report.HasMore)
+
+	// A threshold adds similar names of one type, linking clusters transitively
+	report, err = db.FindDuplicates(ctx, DuplicateOptions{Threshold: 0.85})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Alice", "alice"},
+		{"Kubernetes", "Kubernets", "kubernetes"},
+		{"VS Code", "VSCode", "vs-code"},
+	}, clusterNames(report), "numbered siblings are not duplicates")
+	assert.Equal(t, DuplicateSimilar, report.Clusters[1].Reason)
+	assert.Equal(t, 0.947, report.Clusters[1].Similarity)
+
+	// A low threshold catches more, such as the spelled out name
+	report, err = db.FindDuplicates(ctx, DuplicateOptions{Threshold: 0.5, EntityType: "tool"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Kubernetes", "Kubernets"},
+		{"VS Code", "VSCode", "Visual Studio Code", "vs-code"},
+	}, clusterNames(report))
+
+	report, err = db.FindDuplicates(ctx, DuplicateOptions{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Alice", "alice"}}, clusterNames(report))
+	assert.True(t, report.HasMore)
+}
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Directions of the relations GetRelations returns, seen from the entity
+const (
+	RelationsInbound  = "inbound"
+	RelationsOutbound = "outbound"
+)
+
+// Relations to entities outside the set OpenNodes returns alongside those among it,
+// as chosen with WithExternalRelations
+const (
+	ExternalRelationsNone     = "none"
+	ExternalRelationsIncoming = "incoming"
+	ExternalRelationsOutgoing = "outgoing"
+	ExternalRelationsBoth     = "both"
+)
+
+type externalRelationsKey struct{}
+
+// WithExternalRelations returns ctx under which OpenNodes also returns the relations
+// between the entities opened and others: those pointing to them (incoming), from them
+// (outgoing) or both
+func WithExternalRelations(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, externalRelationsKey{}, mode)
+}
+
+// externalRelationsFrom returns the mode set by WithExternalRelations, or
+// ExternalRelationsNone
+func externalRelationsFrom(ctx context.Context) string {
+	mode, _ := ctx.Value(externalRelationsKey{}).(string)
+	if mode == "" {
+		return ExternalRelationsNone
+	}
+	return mode
+}
+
+// relationsAcross returns the relations between the entities with IDs in names and
+// entities outside them, pointing in for ExternalRelationsIncoming, out for
+// ExternalRelationsOutgoing, or both for ExternalRelationsBoth
+func relationsAcross(ctx context.Context, q relationQuerier, names map[int64]string, mode string, types interner) ([]RelationDTO, error) {
+	var sides [][2]string // the column of the entity opened, then of the other
+	if mode == ExternalRelationsIncoming || mode == ExternalRelationsBoth {
+		sides = append(sides, [2]string{"to_entity_id", "from_entity_id"})
+	}
+	if mode == ExternalRelationsOutgoing || mode == ExternalRelationsBoth {
+		sides = append(sides, [2]string{"from_entity_id", "to_entity_id"})
+	}
+	ids := make([]int64, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+
+	relations := []RelationDTO{}
+	for _, side := range sides {
+		own, other := side[0], side[1]
+		for _, chunk := range chunks(ids, maxListValues) {
+			list, args := inList(chunk)
+			rows, err := q.QueryContext(ctx, fmt.Sprintf(`
+				SELECT r.%[1]s, r.%[2]s, e.name, r.relation_type, COALESCE(r.confidence, 0), COALESCE(r.note, '')
+				FROM relations r
+				JOIN entities e ON e.id = r.%[2]s
+				WHERE r.%[1]s IN %[3]s`, own, other, list), args...)
+			if err != nil {
+				return nil, err
+			}
+			for rows.Next() {
+				var ownID, otherID int64
+				var otherName string
+				rel := RelationDTO{}
+				if err := rows.Scan(&ownID, &otherID, &otherName, &rel.RelationType, &rel.Confidence, &rel.Note); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				if _, among := names[otherID]; among {
+					continue
+				}
+				rel.RelationType = types.intern(rel.RelationType)
+				if own == "to_entity_id" {
+					rel.From, rel.To = otherName, names[ownID]
+				} else {
+					rel.From, rel.To = names[ownID], otherName
+				}
+				relations = append(relations, rel)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return relations, nil
+}
+
+// EntityRelation is a relation with the types of the entities at both ends
+type EntityRelation struct {
+	From           string `json:"from"`
+	FromEntityType string `json:"fromEntityType"`
+	To             string `json:"to"`
+	ToEntityType   string `json:"toEntityType"`
+	RelationType   string `json:"relationType"`
+	// Confidence and Note are the relation's properties, as in RelationDTO
+	Confidence float64 `json:"confidence,omitempty"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// RelationPage is one page of the relations to or from an entity
+type RelationPage struct {
+	EntityName   string `json:"entityName"`
+	Direction    string `json:"direction"`
+	RelationType string `json:"relationType,omitempty"`
+	// Relations are ordered by relation type, then by when they were created
+	Relations      []EntityRelation `json:"relations"`
+	TotalRelations int              `json:"totalRelations"`
+	Offset         int              `json:"offset"`
+	NextOffset     *int             `json:"nextOffset,omitempty"`
+}
+
+// GetRelations returns one page of the relations pointing to (inbound) or from
+// (outbound) an entity, optionally of one type. It reads through the
+// (to_entity_id, relation_type) or (from_entity_id, relation_type) index, in index
+// order, so each page costs O(offset + limit) whatever else the database holds.
+func (db *DB) GetRelations(ctx context.Context, entityName, direction, relationType string, limit, offset int) (*RelationPage, error) {
+	if direction != RelationsInbound && direction != RelationsOutbound {
+		return nil, fmt.Errorf("invalid direction %q: must be %q or %q", direction, RelationsInbound, RelationsOutbound)
+	}
+
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	var entityID int64
+	var entityType string
+	err = db.reader.QueryRowContext(ctx,
+		"SELECT id, entity_type FROM entities WHERE graph_id = ? AND name = ?", graph, entityName,
+	).Scan(&entityID, &entityType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &EntityNotFoundError{Name: entityName}
+		}
+		return nil, err
+	}
+
+	page := &RelationPage{
+		EntityName:   entityName,
+		Direction:    direction,
+		RelationType: relationType,
+		Offset:       offset,
+	}
+	if err := readRelationPage(ctx, db.conn, page, entityID, entityType, limit); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// relationQuerier is a *sql.DB or a *sql.Tx
+type relationQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readRelationPage fills in page, whose entity, direction, type filter and offset are
+// set, for the entity with the given id and type
+func readRelationPage(ctx context.Context, q relationQuerier, page *RelationPage, entityID int64, entityType string, limit int) error {
+	own, other := "to_entity_id", "from_entity_id"
+	if page.Direction == RelationsOutbound {
+		own, other = other, own
+	}
+	where := "r." + own + " = ?"
+	args := []any{entityID}
+	if page.RelationType != "" {
+		where += " AND r.relation_type = ?"
+		args = append(args, page.RelationType)
+	}
+	if err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM relations r WHERE "+where, args...,
+	).Scan(&page.TotalRelations); err != nil {
+		return err
+	}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`
+		SELECT e.name, e.entity_type, r.relation_type, COALESCE(r.confidence, 0), COALESCE(r.note, '')
+		FROM relations r
+		JOIN entities e ON e.id = r.%s
+		WHERE %s
+		ORDER BY r.relation_type, r.id
+		LIMIT ? OFFSET ?
+	`, other, where), append(args, limit, page.Offset)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	page.Relations = []EntityRelation{}
+	for rows.Next() {
+		var name, otherType string
+		rel := EntityRelation{}
+		if err := rows.Scan(&name, &otherType, &rel.RelationType, &rel.Confidence, &rel.Note); err != nil {
+			return err
+		}
+		if page.Direction == RelationsInbound {
+			rel.From, rel.FromEntityType, rel.To, rel.ToEntityType = name, otherType, page.EntityName, entityType
+		} else {
+			rel.From, rel.FromEntityType, rel.To, rel.ToEntityType = page.EntityName, entityType, name, otherType
+		}
+		page.Relations = append(page.Relations, rel)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if next := page.Offset + len(page.Relations); next < page.TotalRelations {
+		page.NextOffset = &next
+	}
+	return nil
+}
+
+// RelationFilter narrows the relations FindRelations returns; empty fields match
+// any relation
+type RelationFilter struct {
+	From         string
+	To           string
+	RelationType string
+}
+
+// RelationMatches is one page of the relations matching a RelationFilter
+type RelationMatches struct {
+	// Relations are ordered by source, target and type
+	Relations      []RelationDTO `json:"relations"`
+	TotalRelations int           `json:"totalRelations"`
+	Offset         int           `json:"offset"`
+	NextOffset     *int          `json:"nextOffset,omitempty"`
+}
+
+// FindRelations returns one page of the relations of the graph matching filter. Names
+// no entity has match nothing rather than failing.
+func (db *DB) FindRelations(ctx context.Context, filter RelationFilter, limit, offset int) (*RelationMatches, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	where, args := "f.graph_id = ?", []any{graph}
+	if filter.From != "" {
+		where += " AND f.name = ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where += " AND t.name = ?"
+		args = append(args, filter.To)
+	}
+	if filter.RelationType != "" {
+		where += " AND r.relation_type = ?"
+		args = append(args, filter.RelationType)
+	}
+	from := `
+		FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE ` + where
+
+	// The count and the page are read from one snapshot
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	page := &RelationMatches{Relations: []RelationDTO{}, Offset: offset}
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&page.TotalRelations); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT f.name, t.name, r.relation_type, COALESCE(r.confidence, 0), COALESCE(r.note, '')`+from+`
+		ORDER BY f.name, t.name, r.relation_type
+		LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rel RelationDTO
+		if err := rows.Scan(&rel.From, &rel.To, &rel.RelationType, &rel.Confidence, &rel.Note); err != nil {
+			return nil, err
+		}
+		page.Relations = append(page.Relations, rel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if next := offset + len(page.Relations); next < page.TotalRelations {
+		page.NextOffset = &next
+	}
+	return page, tx.Commit()
+}
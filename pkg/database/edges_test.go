@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRelations(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Loner", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Acme", RelationType: "invests_in"},
+		{From: "Acme", To: "Bob", RelationType: "employs"},
+	})
+	assert.NoError(t, err)
+
+	page, err := db.GetRelations(ctx, "Acme", RelationsInbound, "", 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &RelationPage{
+		EntityName: "Acme",
+		Direction:  RelationsInbound,
+		Relations: []EntityRelation{
+			{From: "Alice", FromEntityType: "person", To: "Acme", ToEntityType: "company", RelationType: "invests_in"},
+			{From: "Alice", FromEntityType: "person", To: "Acme", ToEntityType: "company", RelationType: "works_at"},
+			{From: "Bob", FromEntityType: "person", To: "Acme", ToEntityType: "company", RelationType: "works_at"},
+		},
+		TotalRelations: 3,
+	}, page)
+
+	// Filtered by type and paged
+	page, err = db.GetRelations(ctx, "Acme", RelationsInbound, "works_at", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalRelations)
+	assert.Equal(t, "Alice", page.Relations[0].From)
+	if assert.NotNil(t, page.NextOffset) {
+		page, err = db.GetRelations(ctx, "Acme", RelationsInbound, "works_at", 1, *page.NextOffset)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bob", page.Relations[0].From)
+		assert.Nil(t, page.NextOffset)
+	}
+
+	page, err = db.GetRelations(ctx, "Acme", RelationsOutbound, "", 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityRelation{
+		{From: "Acme", FromEntityType: "company", To: "Bob", ToEntityType: "person", RelationType: "employs"},
+	}, page.Relations)
+
+	// No relations, or none of the type, is an empty page
+	for _, tc := range []struct{ name, direction, relationType string }{
+		{"Loner", RelationsInbound, ""},
+		{"Loner", RelationsOutbound, ""},
+		{"Acme", RelationsInbound, "owns"},
+	} {
+		page, err := db.GetRelations(ctx, tc.name, tc.direction, tc.relationType, 10, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, page.Relations)
+		assert.Zero(t, page.TotalRelations)
+		assert.Nil(t, page.NextOffset)
+	}
+
+	_, err = db.GetRelations(ctx, "Nobody", RelationsInbound, "", 10, 0)
+	assert.ErrorContains(t, err, "not found")
+	_, err = db.GetRelations(ctx, "Acme", "sideways", "", 10, 0)
+	assert.Error(t, err)
+}
+
+func TestGetRelations_Hub(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	const followers = 3000
+	entities := []EntityWithObservations{{Name: "Hub", EntityType: "account"}}
+	relations := make([]RelationDTO, 0, followers)
+	for i := range followers {
+		name := fmt.Sprintf("Follower %04d", i)
+		entities = append(entities, EntityWithObservations{Name: name, EntityType: "account"})
+		relationType := "follows"
+		if i%3 == 0 {
+			relationType = "mentions"
+		}
+		relations = append(relations, RelationDTO{From: name, To: "Hub", RelationType: relationType})
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+
+	page, err := db.GetRelations(ctx, "Hub", RelationsInbound, "", 100, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page.Relations, 100)
+	assert.Equal(t, followers, page.TotalRelations)
+	assert.Equal(t, 100, *page.NextOffset)
+
+	page, err = db.GetRelations(ctx, "Hub", RelationsInbound, "mentions", 100, 900)
+	assert.NoError(t, err)
+	assert.Len(t, page.Relations, 100)
+	assert.Equal(t, followers/3, page.TotalRelations)
+	assert.Nil(t, page.NextOffset)
+	for _, rel := range page.Relations {
+		assert.Equal(t, "mentions", rel.RelationType)
+	}
+
+	// The pages walk the (to_entity_id, relation_type) index in order, without sorting
+	rows, err := db.conn.QueryContext(ctx, `EXPLAIN QUERY PLAN
+		SELECT e.name FROM relations r JOIN entities e ON e.id = r.from_entity_id
+		WHERE r.to_entity_id = ? AND r.relation_type = ? ORDER BY r.relation_type, r.id LIMIT 100`, 1, "mentions")
+	assert.NoError(t, err)
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		assert.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	joined := strings.Join(plan, "\n")
+	assert.Contains(t, joined, "idx_relations_to_type (to_entity_id=? AND relation_type=?)")
+	assert.NotContains(t, joined, "TEMP B-TREE")
+}
+
+func TestOpenNodes_ExternalRelations(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Target", EntityType: "project"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Target", RelationType: "works_on"},
+		{From: "Bob", To: "Target", RelationType: "works_on", Confidence: 0.5},
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Acme", To: "Alice", RelationType: "employs"},
+	})
+	assert.NoError(t, err)
+
+	// Target only has incoming relations, hidden by default
+	for _, mode := range []string{"", ExternalRelationsNone, ExternalRelationsOutgoing} {
+		graph, err := db.OpenNodes(WithExternalRelations(ctx, mode), []string{"Target"})
+		assert.NoError(t, err)
+		assert.Empty(t, graph.Relations, mode)
+	}
+	graph, err := db.OpenNodes(WithExternalRelations(ctx, ExternalRelationsIncoming), []string{"Target"})
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{
+		{From: "Alice", To: "Target", RelationType: "works_on"},
+		{From: "Bob", To: "Target", RelationType: "works_on", Confidence: 0.5},
+	}, graph.Relations)
+	assert.Len(t, graph.Entities, 1, "the other ends are not opened")
+
+	// Relations among the set are listed once
+	graph, err = db.OpenNodes(WithExternalRelations(ctx, ExternalRelationsBoth), []string{"Alice", "Acme"})
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{
+		{From: "Acme", To: "Alice", RelationType: "employs"},
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Target", RelationType: "works_on"},
+	}, graph.Relations)
+	graph, err = db.OpenNodes(WithExternalRelations(ctx, ExternalRelationsIncoming), []string{"Alice", "Acme"})
+	assert.NoError(t, err)
+	assert.Len(t, graph.Relations, 2)
+}
+
+func TestFindRelations(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at", Note: "since 2020"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Acme", To: "Bob", RelationType: "employs"},
+	})
+	assert.NoError(t, err)
+
+	page, err := db.FindRelations(ctx, RelationFilter{}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &RelationMatches{
+		Relations: []RelationDTO{
+			{From: "Acme", To: "Bob", RelationType: "employs"},
+			{From: "Alice", To: "Acme", RelationType: "works_at", Note: "since 2020"},
+			{From: "Alice", To: "Bob", RelationType: "knows"},
+			{From: "Bob", To: "Acme", RelationType: "works_at"},
+		},
+		TotalRelations: 4,
+	}, page)
+
+	for _, tc := range []struct {
+		filter RelationFilter
+		want   int
+	}{
+		{RelationFilter{From: "Alice"}, 2},
+		{RelationFilter{To: "Acme"}, 2},
+		{RelationFilter{RelationType: "works_at"}, 2},
+		{RelationFilter{From: "Alice", To: "Acme", RelationType: "works_at"}, 1},
+		{RelationFilter{From: "Nobody"}, 0},
+		{RelationFilter{RelationType: "owns"}, 0},
+	} {
+		page, err := db.FindRelations(ctx, tc.filter, 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, page.Relations, tc.want, tc.filter)
+		assert.Equal(t, tc.want, page.TotalRelations, tc.filter)
+	}
+
+	page, err = db.FindRelations(ctx, RelationFilter{}, 3, 0)
+	assert.NoError(t, err)
+	if assert.NotNil(t, page.NextOffset) {
+		page, err = db.FindRelations(ctx, RelationFilter{}, 3, *page.NextOffset)
+		assert.NoError(t, err)
+		assert.Equal(t, []RelationDTO{{From: "Bob", To: "Acme", RelationType: "works_at"}}, page.Relations)
+		assert.Nil(t, page.NextOffset)
+	}
+}
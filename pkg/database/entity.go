@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// EntityRelationLimit is the most relations GetEntity returns in each direction
+const EntityRelationLimit = 100
+
+// EntityDetail is one entity with its observations and the relations to and from it
+type EntityDetail struct {
+	EntityWithObservations
+	// Incoming and Outgoing hold up to EntityRelationLimit relations each, ordered
+	// by relation type; the totals count all of them, and the rest can be paged
+	// with GetRelations
+	Incoming      []EntityRelation `json:"incoming"`
+	TotalIncoming int              `json:"totalIncoming"`
+	Outgoing      []EntityRelation `json:"outgoing"`
+	TotalOutgoing int              `json:"totalOutgoing"`
+}
+
+// GetEntity returns the named entity with its observations, capped like OpenNodes,
+// and its relations in both directions, read in one transaction. It returns nil
+// without an error when the entity doesn't exist.
+func (db *DB) GetEntity(ctx context.Context, name string) (*EntityDetail, error) {
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	var observations string
+	var tags, attributes sql.NullString
+	detail := &EntityDetail{}
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT e.id, e.name, e.entity_type, %s, %s, %s, %s
+		FROM entities e
+		WHERE e.graph_id = ? AND e.name = ?
+	`, observationColumns(db.observationLimit), tagsColumn, attributesColumn, pinnedColumn), graph, name).Scan(
+		&id, &detail.Name, &detail.EntityType, &detail.TotalObservations, &observations, &tags, &attributes, &detail.Pinned,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if detail.Observations, err = splitObservations(observations); err != nil {
+		return nil, err
+	}
+	if detail.Tags, err = splitTags(tags); err != nil {
+		return nil, err
+	}
+	if detail.Attributes, err = splitAttributes(attributes); err != nil {
+		return nil, err
+	}
+
+	for _, direction := range []string{RelationsInbound, RelationsOutbound} {
+		page := &RelationPage{EntityName: detail.Name, Direction: direction}
+		if err := readRelationPage(ctx, tx, page, id, detail.EntityType, EntityRelationLimit); err != nil {
+			return nil, err
+		}
+		if direction == RelationsInbound {
+			detail.Incoming, detail.TotalIncoming = page.Relations, page.TotalRelations
+		} else {
+			detail.Outgoing, detail.TotalOutgoing = page.Relations, page.TotalRelations
+		}
+	}
+	return detail, tx.Commit()
+}
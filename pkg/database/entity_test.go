@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEntity(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer", "likes go"}},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Alice", RelationType: "manages"},
+	})
+	assert.NoError(t, err)
+
+	detail, err := db.GetEntity(ctx, "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &EntityDetail{
+		EntityWithObservations: EntityWithObservations{
+			Name: "Alice", EntityType: "person", Observations: []string{"engineer", "likes go"}, TotalObservations: 2,
+		},
+		Incoming: []EntityRelation{
+			{From: "Bob", FromEntityType: "person", To: "Alice", ToEntityType: "person", RelationType: "manages"},
+		},
+		TotalIncoming: 1,
+		Outgoing: []EntityRelation{
+			{From: "Alice", FromEntityType: "person", To: "Acme", ToEntityType: "company", RelationType: "works_at"},
+		},
+		TotalOutgoing: 1,
+	}, detail)
+
+	// Only incoming relations, and no observations
+	detail, err = db.GetEntity(ctx, "Acme")
+	assert.NoError(t, err)
+	assert.Empty(t, detail.Observations)
+	assert.Len(t, detail.Incoming, 1)
+	assert.Equal(t, []EntityRelation{}, detail.Outgoing)
+	assert.Zero(t, detail.TotalOutgoing)
+
+	detail, err = db.GetEntity(ctx, "Nobody")
+	assert.NoError(t, err)
+	assert.Nil(t, detail)
+}
+
+func TestGetEntity_CapsRelations(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	entities := []EntityWithObservations{{Name: "Hub", EntityType: "account"}}
+	var relations []RelationDTO
+	for i := range EntityRelationLimit + 20 {
+		name := fmt.Sprintf("Follower %03d", i)
+		entities = append(entities, EntityWithObservations{Name: name, EntityType: "account"})
+		relations = append(relations, RelationDTO{From: name, To: "Hub", RelationType: "follows"})
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+
+	detail, err := db.GetEntity(ctx, "Hub")
+	assert.NoError(t, err)
+	assert.Len(t, detail.Incoming, EntityRelationLimit)
+	assert.Equal(t, EntityRelationLimit+20, detail.TotalIncoming)
+}
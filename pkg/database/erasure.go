@@ -0,0 +1,557 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// ErasedObservation is a matching observation on an entity that is otherwise kept
+type ErasedObservation struct {
+	EntityName string `json:"entityName"`
+	Content    string `json:"content"`
+}
+
+// ResidualMatch counts the rows of a table that still contain an erased term
+type ResidualMatch struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+// ErasureVerification is the result of scanning the whole database for the erased terms
+type ErasureVerification struct {
+	// Clean is true when no table, FTS shadow table or FTS index contains any term
+	Clean     bool            `json:"clean"`
+	Remaining []ResidualMatch `json:"remaining"`
+	// TablesScanned lists every table that was searched
+	TablesScanned []string `json:"tablesScanned"`
+	Checkpointed  bool     `json:"checkpointed"`
+	// Vacuumed is false when the database doesn't use incremental auto-vacuum
+	// (databases created before it was enabled); free pages are zeroed by secure_delete either way
+	Vacuumed bool `json:"vacuumed"`
+}
+
+// ErasureReport describes everything matched, and on a real run removed, by EraseSubject
+type ErasureReport struct {
+	Terms  []string `json:"terms"`
+	DryRun bool     `json:"dryRun"`
+	// Entities whose name or type contains a term; they are deleted with all their
+	// observations and relations
+	Entities           []string `json:"entities"`
+	EntityObservations int      `json:"entityObservations"`
+	// Relations removed, either with an erased entity or because their type contains a term
+	Relations []RelationDTO `json:"relations"`
+	// Observations containing a term on entities that are kept
+	Observations []ErasedObservation `json:"observations"`
+	// ArchivedObservations counts observations archived by a retention policy whose
+	// entity name, entity type or content contains a term
+	ArchivedObservations int `json:"archivedObservations"`
+	// AuditEntries counts the audit log entries whose summary or affected entity
+	// names contain a term
+	AuditEntries int `json:"auditEntries"`
+	// Snapshots counts the labeled snapshots whose label or graph contains a term
+	Snapshots int `json:"snapshots"`
+	// Aliases counts the aliases containing a term of entities that are kept
+	Aliases int `json:"aliases"`
+	// TrashRelations counts the relations in the trash, deleted with an entity, whose
+	// type contains a term while both their entities are kept
+	TrashRelations int `json:"trashRelations"`
+	// Imports counts the chunked imports in progress abandoned because a staged row
+	// or the pending partial line contains a term
+	Imports      int                 `json:"imports"`
+	Verification ErasureVerification `json:"verification"`
+}
+
+// erasureTargets holds the row ids matched for erasure
+type erasureTargets struct {
+	entityIDs      []int64
+	relationIDs    []int64
+	observationIDs []int64
+	archivedIDs    []int64
+	auditIDs       []int64
+	snapshotIDs    []int64
+	aliasIDs       []int64
+	trashRelIDs    []int64
+	// importRowIDs are rowids, imports having text ids
+	importRowIDs []int64
+}
+
+// EraseSubject permanently removes every trace of the given terms (names and aliases
+// of a person): entities whose name or type contains a term, their observations and
+// relations, relations whose type contains a term, matching observations on any
+// other entity, aliases containing a term, relations in the trash whose type
+// contains a term, chunked imports in progress staging a term, and the audit log
+// entries and snapshots naming any of them. Matching
+// is case-insensitive substring search, extended by FTS when available. Deleted
+// content is overwritten on disk (secure_delete), the FTS indexes are compacted, the
+// WAL is checkpointed and free pages are vacuumed. The report ends with a scan of
+// every table for the terms.
+//
+// With dryRun nothing is changed and the verification shows where the terms occur.
+// The terms themselves are never logged.
+func (db *DB) EraseSubject(ctx context.Context, terms []string, dryRun bool) (*ErasureReport, error) {
+	report := &ErasureReport{
+		Terms:        terms,
+		DryRun:       dryRun,
+		Entities:     []string{},
+		Relations:    []RelationDTO{},
+		Observations: []ErasedObservation{},
+	}
+
+	// Everything runs on one connection so the per-connection secure_delete applies
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if !dryRun {
+		if _, err := conn.ExecContext(ctx, "PRAGMA secure_delete = ON"); err != nil {
+			return nil, fmt.Errorf("failed to enable secure delete: %w", err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA secure_delete = OFF")
+	}
+
+	// Finding and deleting the targets is retried as one transaction, each attempt
+	// reporting from scratch
+	empty := *report
+	err = db.retryWrite(ctx, func() error {
+		*report = empty
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		targets, err := db.findErasureTargets(ctx, tx, terms, report)
+		if err != nil {
+			return cancelledOr(ctx, err, "erase subject", 0, len(terms))
+		}
+		if dryRun {
+			return tx.Rollback()
+		}
+		if err := deleteErasureTargets(ctx, tx, targets); err != nil {
+			return cancelledOr(ctx, err, "erase subject", 0, len(terms))
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := db.compactAfterErasure(ctx, conn, &report.Verification); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.verifyErasure(ctx, conn, terms, &report.Verification); err != nil {
+		return nil, fmt.Errorf("failed to verify erasure: %w", err)
+	}
+
+	db.logger.Info("subject erasure finished",
+		slog.Bool("dry_run", dryRun),
+		slog.Int("terms", len(terms)),
+		slog.Int("entities", len(report.Entities)),
+		slog.Int("relations", len(report.Relations)),
+		slog.Int("observations", len(report.Observations)+report.EntityObservations+report.ArchivedObservations),
+		slog.Bool("clean", report.Verification.Clean),
+	)
+	return report, nil
+}
+
+// findErasureTargets collects the rows matching terms and fills in the report
+func (db *DB) findErasureTargets(ctx context.Context, tx *sql.Tx, terms []string, report *ErasureReport) (*erasureTargets, error) {
+	targets := &erasureTargets{}
+
+	cond, args := containsAny("e.name", terms)
+	typeCond, typeArgs := containsAny("e.entity_type", terms)
+	cond += " OR " + typeCond
+	args = append(args, typeArgs...)
+	if db.ftsEnabled {
+		ftsCond, ftsArgs := ftsMatchAny("e.id", "entity_id", "entities_fts", terms)
+		cond += ftsCond
+		args = append(args, ftsArgs...)
+	}
+	// The erased entities are selected again as a subquery rather than listed, so
+	// matching any number of them binds no more parameters
+	erased, erasedArgs := "(SELECT e.id FROM entities e WHERE "+cond+")", args
+	rows, err := tx.QueryContext(ctx, "SELECT e.id, e.name FROM entities e WHERE "+cond+" ORDER BY e.name", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		targets.entityIDs = append(targets.entityIDs, id)
+		report.Entities = append(report.Entities, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(targets.entityIDs) > 0 {
+		err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM observations WHERE entity_id IN "+erased, erasedArgs...,
+		).Scan(&report.EntityObservations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cond, args = containsAny("r.relation_type", terms)
+	if len(targets.entityIDs) > 0 {
+		cond += " OR r.from_entity_id IN " + erased + " OR r.to_entity_id IN " + erased
+		args = append(append(args, erasedArgs...), erasedArgs...)
+	}
+	rows, err = tx.QueryContext(ctx, `
+		SELECT r.id, f.name, t.name, r.relation_type
+		FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE `+cond+`
+		ORDER BY f.name, t.name, r.relation_type`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var rel RelationDTO
+		if err := rows.Scan(&id, &rel.From, &rel.To, &rel.RelationType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		targets.relationIDs = append(targets.relationIDs, id)
+		report.Relations = append(report.Relations, rel)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cond, args = containsAny("o.content", terms)
+	if db.ftsEnabled {
+		ftsCond, ftsArgs := ftsMatchAny("o.id", "observation_id", "observations_fts", terms)
+		cond += ftsCond
+		args = append(args, ftsArgs...)
+	}
+	query := "SELECT o.id, e.name, o.content FROM observations o JOIN entities e ON e.id = o.entity_id WHERE (" + cond + ")"
+	if len(targets.entityIDs) > 0 {
+		query += " AND o.entity_id NOT IN " + erased
+		args = append(args, erasedArgs...)
+	}
+	rows, err = tx.QueryContext(ctx, query+" ORDER BY e.name, o.created_at, o.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var obs ErasedObservation
+		if err := rows.Scan(&id, &obs.EntityName, &obs.Content); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		targets.observationIDs = append(targets.observationIDs, id)
+		report.Observations = append(report.Observations, obs)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cond, args = containsAny("entity_name", terms)
+	for _, column := range []string{"entity_type", "content"} {
+		columnCond, columnArgs := containsAny(column, terms)
+		cond += " OR " + columnCond
+		args = append(args, columnArgs...)
+	}
+	if targets.archivedIDs, err = selectIDs(ctx, tx, "SELECT id FROM archived_observations WHERE "+cond, args); err != nil {
+		return nil, err
+	}
+	report.ArchivedObservations = len(targets.archivedIDs)
+
+	// The audit log names what changed, so entries mentioning a term go too; the
+	// erasure itself is not recorded
+	cond, args = containsAny("summary", terms)
+	nameCond, nameArgs := containsAny("entity_name", terms)
+	cond += " OR id IN (SELECT audit_id FROM audit_log_entities WHERE " + nameCond + ")"
+	args = append(args, nameArgs...)
+	if targets.auditIDs, err = selectIDs(ctx, tx, "SELECT id FROM audit_log WHERE "+cond, args); err != nil {
+		return nil, err
+	}
+	report.AuditEntries = len(targets.auditIDs)
+
+	if targets.snapshotIDs, err = snapshotsContaining(ctx, tx, terms); err != nil {
+		return nil, err
+	}
+	report.Snapshots = len(targets.snapshotIDs)
+
+	// The aliases of erased entities go by cascade
+	cond, args = containsAny("alias", terms)
+	query = "SELECT id FROM entity_aliases WHERE " + cond
+	if len(targets.entityIDs) > 0 {
+		query += " AND entity_id NOT IN " + erased
+		args = append(args, erasedArgs...)
+	}
+	if targets.aliasIDs, err = selectIDs(ctx, tx, query, args); err != nil {
+		return nil, err
+	}
+	report.Aliases = len(targets.aliasIDs)
+
+	// Relations wait in trash_relations until their entities are restored; those of
+	// erased entities go by cascade
+	cond, args = containsAny("relation_type", terms)
+	query = "SELECT id FROM trash_relations WHERE (" + cond + ")"
+	if len(targets.entityIDs) > 0 {
+		query += " AND from_entity_id NOT IN " + erased + " AND to_entity_id NOT IN " + erased
+		args = append(append(args, erasedArgs...), erasedArgs...)
+	}
+	if targets.trashRelIDs, err = selectIDs(ctx, tx, query, args); err != nil {
+		return nil, err
+	}
+	report.TrashRelations = len(targets.trashRelIDs)
+
+	// A chunked import staging a term is abandoned whole, its rows going by cascade,
+	// rather than committed later with rows missing
+	cond, args = containsAny("pending", terms)
+	payloadCond, payloadArgs := containsAny("payload", terms)
+	cond += " OR id IN (SELECT import_id FROM import_rows WHERE " + payloadCond + ")"
+	args = append(args, payloadArgs...)
+	if targets.importRowIDs, err = selectIDs(ctx, tx, "SELECT rowid FROM imports WHERE "+cond, args); err != nil {
+		return nil, err
+	}
+	report.Imports = len(targets.importRowIDs)
+	return targets, nil
+}
+
+// selectIDs returns the ids a query selects
+func selectIDs(ctx context.Context, tx *sql.Tx, query string, args []any) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteErasureTargets removes the matched rows. Observations and relations of erased
+// entities, and the staged rows of imports, go by cascade and FTS rows by trigger.
+func deleteErasureTargets(ctx context.Context, tx *sql.Tx, targets *erasureTargets) error {
+	for _, del := range []struct {
+		table string
+		key   string
+		ids   []int64
+	}{
+		{"observations", "id", targets.observationIDs},
+		{"archived_observations", "id", targets.archivedIDs},
+		{"relations", "id", targets.relationIDs},
+		{"entities", "id", targets.entityIDs},
+		{"audit_log", "id", targets.auditIDs},
+		{"snapshots", "id", targets.snapshotIDs},
+		{"entity_aliases", "id", targets.aliasIDs},
+		{"trash_relations", "id", targets.trashRelIDs},
+		{"imports", "rowid", targets.importRowIDs},
+	} {
+		if len(del.ids) == 0 {
+			continue
+		}
+		for _, chunk := range chunks(del.ids, maxListValues) {
+			list, args := inList(chunk)
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+del.table+" WHERE "+del.key+" IN "+list, args...); err != nil {
+				return fmt.Errorf("failed to delete from %s: %w", del.table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compactAfterErasure rewrites the FTS indexes without the deleted rows, flushes the
+// WAL into the database file and truncates it, and releases free pages
+func (db *DB) compactAfterErasure(ctx context.Context, conn *sql.Conn, v *ErasureVerification) error {
+	if db.ftsEnabled {
+		// Deleting from FTS5 only records tombstones; optimize merges the index
+		// segments so the deleted tokens are physically gone
+		for _, table := range []string{"entities_fts", "observations_fts"} {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s(%s) VALUES('optimize')", table, table)); err != nil {
+				return fmt.Errorf("failed to optimize %s: %w", table, err)
+			}
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	v.Checkpointed = true
+
+	var autoVacuum int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return err
+	}
+	if autoVacuum == 2 { // INCREMENTAL
+		if _, err := conn.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+			return fmt.Errorf("failed to vacuum: %w", err)
+		}
+		v.Vacuumed = true
+	}
+	return nil
+}
+
+// verifyErasure searches every column of every table, including FTS shadow tables,
+// for the terms, and queries the FTS indexes themselves
+func (db *DB) verifyErasure(ctx context.Context, conn *sql.Conn, terms []string, v *ErasureVerification) error {
+	v.Remaining = []ResidualMatch{}
+	v.TablesScanned = []string{}
+
+	rows, err := conn.QueryContext(ctx,
+		"SELECT name, sql LIKE 'CREATE VIRTUAL TABLE%' FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return err
+	}
+	type table struct {
+		name    string
+		virtual bool
+	}
+	var tables []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.virtual); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		columns, err := tableColumns(ctx, conn, t.name)
+		if err != nil {
+			return err
+		}
+		var conds []string
+		var args []any
+		for _, col := range columns {
+			cond, colArgs := containsAny(fmt.Sprintf("CAST(%s AS TEXT)", quoteIdent(col)), terms)
+			conds = append(conds, cond)
+			args = append(args, colArgs...)
+		}
+		if len(conds) > 0 {
+			var n int
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdent(t.name), strings.Join(conds, " OR "))
+			if err := conn.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+				return fmt.Errorf("failed to scan %s: %w", t.name, err)
+			}
+			if n > 0 {
+				v.Remaining = append(v.Remaining, ResidualMatch{Table: t.name, Rows: n})
+			}
+		}
+		v.TablesScanned = append(v.TablesScanned, t.name)
+
+		// Query the FTS index too: it matches tokens even where the stored text doesn't
+		if t.virtual && db.ftsEnabled {
+			var n int
+			for _, term := range terms {
+				if !hasWordChar(term) {
+					continue
+				}
+				var count int
+				query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s MATCH ?", quoteIdent(t.name), quoteIdent(t.name))
+				if err := conn.QueryRowContext(ctx, query, ftsPhrase(term)).Scan(&count); err != nil {
+					return fmt.Errorf("failed to query %s: %w", t.name, err)
+				}
+				n += count
+			}
+			if n > 0 {
+				v.Remaining = append(v.Remaining, ResidualMatch{Table: t.name + " (index)", Rows: n})
+			}
+		}
+	}
+
+	v.Clean = len(v.Remaining) == 0
+	return nil
+}
+
+func tableColumns(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// containsAny builds a case-insensitive substring condition on expr for each term
+func containsAny(expr string, terms []string) (string, []any) {
+	conds := make([]string, len(terms))
+	args := make([]any, len(terms))
+	for i, term := range terms {
+		conds[i] = fmt.Sprintf("instr(lower(%s), lower(?)) > 0", expr)
+		args[i] = term
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// ftsMatchAny builds " OR idExpr IN (...)" conditions matching each term as a phrase in an
+// FTS table, which also catches case and diacritic variants that lower() misses
+func ftsMatchAny(idExpr, ftsIDColumn, ftsTable string, terms []string) (string, []any) {
+	var cond strings.Builder
+	var args []any
+	for _, term := range terms {
+		if !hasWordChar(term) {
+			continue
+		}
+		fmt.Fprintf(&cond, " OR %s IN (SELECT %s FROM %s WHERE %s MATCH ?)", idExpr, ftsIDColumn, ftsTable, ftsTable)
+		args = append(args, ftsPhrase(term))
+	}
+	return cond.String(), args
+}
+
+// ftsPhrase quotes term as an FTS5 phrase
+func ftsPhrase(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+// hasWordChar reports whether the FTS tokenizer would produce any token from s
+func hasWordChar(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+func inList(ids []int64) (string, []any) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return "(" + strings.Join(placeholders, ",") + ")", args
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seedErasureFixture(t *testing.T, db *DB) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Zelda Quartermain", EntityType: "person", Observations: []string{"born in Oslo", "likes chess"}},
+		{Name: "ZQ", EntityType: "alias", Observations: []string{"online handle"}},
+		{Name: "Acme", EntityType: "org", Observations: []string{"founded 1999", "CEO is zelda quartermain"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Zelda Quartermain", To: "Acme", RelationType: "runs"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "ZQ", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestEraseSubject_DryRunChangesNothing(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	seedErasureFixture(t, db)
+	ctx := context.Background()
+
+	report, err := db.EraseSubject(ctx, []string{"Zelda Quartermain", "ZQ"}, true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"ZQ", "Zelda Quartermain"}, report.Entities)
+	assert.Equal(t, 3, report.EntityObservations)
+	assert.ElementsMatch(t, []RelationDTO{
+		{From: "Zelda Quartermain", To: "Acme", RelationType: "runs"},
+		{From: "Bob", To: "ZQ", RelationType: "knows"},
+	}, report.Relations)
+	assert.Equal(t, []ErasedObservation{{EntityName: "Acme", Content: "CEO is zelda quartermain"}}, report.Observations)
+	assert.False(t, report.Verification.Clean)
+	assert.Contains(t, report.Verification.Remaining, ResidualMatch{Table: "entities", Rows: 2})
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 4)
+	assert.Len(t, graph.Relations, 3)
+}
+
+func TestEraseSubject_LeavesNoTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	seedErasureFixture(t, db)
+	ctx := context.Background()
+	_, err = db.CreateSnapshot(ctx, "before erasure")
+	assert.NoError(t, err)
+
+	report, err := db.EraseSubject(ctx, []string{"Zelda Quartermain", "ZQ"}, false)
+	assert.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Len(t, report.Entities, 2)
+	assert.Len(t, report.Observations, 1)
+	assert.NotZero(t, report.AuditEntries, "the changes that created the subject are in the audit log")
+	assert.Equal(t, 1, report.Snapshots)
+
+	v := report.Verification
+	assert.True(t, v.Clean, "remaining: %v", v.Remaining)
+	assert.Empty(t, v.Remaining)
+	assert.True(t, v.Checkpointed)
+	assert.True(t, v.Vacuumed)
+	for _, table := range []string{"entities", "observations", "relations", "meta", "audit_log", "audit_log_entities"} {
+		assert.Contains(t, v.TablesScanned, table)
+	}
+	if db.IsFTSEnabled() {
+		for _, table := range []string{"entities_fts", "entities_fts_data", "entities_fts_content", "observations_fts", "observations_fts_data", "observations_fts_content"} {
+			assert.Contains(t, v.TablesScanned, table)
+		}
+	}
+
+	// Unrelated data survives
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, e := range graph.Entities {
+		names = append(names, e.Name)
+	}
+	assert.ElementsMatch(t, []string{"Acme", "Bob"}, names)
+	assert.Equal(t, []RelationDTO{{From: "Bob", To: "Acme", RelationType: "works_at"}}, graph.Relations)
+
+	// A second verification from scratch agrees
+	again, err := db.EraseSubject(ctx, []string{"Zelda Quartermain", "ZQ"}, true)
+	assert.NoError(t, err)
+	assert.True(t, again.Verification.Clean)
+	assert.Empty(t, again.Entities)
+
+	// Nor is any trace left in the files on disk
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(path + suffix)
+		if os.IsNotExist(err) {
+			continue
+		}
+		assert.NoError(t, err)
+		assert.False(t, bytes.Contains(bytes.ToLower(data), []byte("quartermain")), "found in %s", path+suffix)
+	}
+}
+
+func TestEraseSubject_TrashAndStagedImports(t *testing.T) {
+	db := newImportTestDB(t)
+	seedErasureFixture(t, db)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Carol", EntityType: "person"},
+		{Name: "Dave", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Carol", To: "Dave", RelationType: "zq_contact"}})
+	assert.NoError(t, err)
+	db.SetSoftDelete(true)
+	_, err = db.DeleteEntities(ctx, []string{"Carol"})
+	assert.NoError(t, err)
+
+	staged, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	_, err = db.ApplyImportChunk(ctx, staged, ImportFirstSequence,
+		[]byte(`{"type":"entity","name":"Zelda Quartermain","entityType":"person"}`+"\n"+`{"type":"entity","name":"Z`))
+	assert.NoError(t, err)
+	unrelated, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	_, err = db.ApplyImportChunk(ctx, unrelated, ImportFirstSequence, []byte(`{"type":"entity","name":"Eve","entityType":"person"}`+"\n"))
+	assert.NoError(t, err)
+
+	report, err := db.EraseSubject(ctx, []string{"Zelda Quartermain", "ZQ"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.TrashRelations)
+	assert.Equal(t, 1, report.Imports)
+	assert.False(t, report.Verification.Clean)
+
+	report, err = db.EraseSubject(ctx, []string{"Zelda Quartermain", "ZQ"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.TrashRelations)
+	assert.Equal(t, 1, report.Imports)
+	assert.True(t, report.Verification.Clean, "remaining: %v", report.Verification.Remaining)
+
+	restored, err := db.RestoreEntities(ctx, []string{"Carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Carol"}, restored.Restored)
+	assert.Zero(t, restored.RestoredRelations, "the erased relation doesn't come back")
+	_, err = db.CommitImport(ctx, staged)
+	assert.Error(t, err, "the import staging the subject is gone")
+	summary, err := db.CommitImport(ctx, unrelated)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Lines, "other imports are kept")
+}
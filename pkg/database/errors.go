@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// CancelledError reports that an operation was abandoned because its context was
+// cancelled, and how far it got. Write operations run in a transaction, so nothing
+// from a cancelled write is committed.
+type CancelledError struct {
+	Operation string
+	Processed int
+	Total     int
+	Err       error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("%s cancelled after %d of %d items; no changes were committed: %v",
+		e.Operation, e.Processed, e.Total, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// EntityNotFoundError reports an operation on an entity that doesn't exist
+type EntityNotFoundError struct {
+	Name string
+}
+
+func (e *EntityNotFoundError) Error() string {
+	return fmt.Sprintf("entity with name %s not found", e.Name)
+}
+
+// checkCancelled returns a CancelledError if ctx is done, otherwise nil.
+// Long-running loops call it between items so cancellation takes effect promptly.
+func checkCancelled(ctx context.Context, op string, processed, total int) error {
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Operation: op, Processed: processed, Total: total, Err: err}
+	}
+	return nil
+}
+
+// cancelledOr converts err into a CancelledError when it was caused by ctx being
+// cancelled (the driver reports an interrupted statement rather than the context error)
+func cancelledOr(ctx context.Context, err error, op string, processed, total int) error {
+	if cerr := checkCancelled(ctx, op, processed, total); cerr != nil {
+		return cerr
+	}
+	return err
+}
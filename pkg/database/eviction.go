@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// What AddObservations does when adding would leave an entity with more stored
+// observations than the cap
+const (
+	// EvictReject fails the call, adding nothing
+	EvictReject = "reject"
+	// EvictOldest deletes the entity's oldest observations to make room
+	EvictOldest = "oldest"
+)
+
+// ObservationCapError rejects an AddObservations call that would leave an entity
+// with more observations than the cap; nothing is added
+type ObservationCapError struct {
+	Entity string
+	// Count is how many observations the entity would have had
+	Count int
+	Cap   int
+}
+
+func (e *ObservationCapError) Error() string {
+	return fmt.Sprintf("entity %s would have %d observations, over the cap of %d", e.Entity, e.Count, e.Cap)
+}
+
+// SetObservationCap sets how many observations an entity may store (0 = unlimited)
+// and what AddObservations does when adding would exceed it: EvictReject or
+// EvictOldest
+func (db *DB) SetObservationCap(limit int, eviction string) {
+	if limit < 0 {
+		limit = 0
+	}
+	if eviction != EvictOldest {
+		eviction = EvictReject
+	}
+	db.observationCap, db.observationEviction = limit, eviction
+}
+
+// ObservationCap returns how many observations an entity may store (0 = unlimited)
+// and the eviction policy applied when adding would exceed it
+func (db *DB) ObservationCap() (int, string) {
+	if db.observationEviction == "" {
+		return db.observationCap, EvictReject
+	}
+	return db.observationCap, db.observationEviction
+}
+
+// enforceObservationCapTx applies the observation cap to an entity that has just had
+// observations added in tx. Under EvictOldest it deletes the oldest observations,
+// by creation time then id, until the entity is within the cap and returns their
+// contents; otherwise it returns an *ObservationCapError.
+func (db *DB) enforceObservationCapTx(ctx context.Context, tx *sql.Tx, entityID int64, entity string) ([]string, error) {
+	limit, eviction := db.ObservationCap()
+	if limit == 0 {
+		return nil, nil
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM observations WHERE entity_id = ?", entityID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count <= limit {
+		return nil, nil
+	}
+	if eviction != EvictOldest {
+		return nil, &ObservationCapError{Entity: entity, Count: count, Cap: limit}
+	}
+
+	const oldest = "SELECT %s FROM observations WHERE entity_id = ? ORDER BY created_at, id LIMIT ?"
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(oldest, "content"), entityID, count-limit)
+	if err != nil {
+		return nil, err
+	}
+	evicted := []string{}
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		evicted = append(evicted, content)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM observations WHERE id IN ("+fmt.Sprintf(oldest, "id")+")",
+		entityID, count-limit,
+	); err != nil {
+		return nil, err
+	}
+	return evicted, nil
+}
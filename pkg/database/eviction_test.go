@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// capTestDB returns a database with an entity Log holding the observations a, b and c
+func capTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	_, err := db.CreateEntities(context.Background(), []EntityWithObservations{
+		{Name: "Log", EntityType: "log", Observations: []string{"a", "b", "c"}},
+	})
+	assert.NoError(t, err)
+	return db
+}
+
+// logObservations returns the stored observations of Log
+func logObservations(t *testing.T, db *DB) []string {
+	t.Helper()
+	graph, err := db.OpenNodes(context.Background(), []string{"Log"})
+	assert.NoError(t, err)
+	return graph.Entities[0].Observations
+}
+
+func TestObservationCap_Unlimited(t *testing.T) {
+	db := capTestDB(t)
+	limit, eviction := db.ObservationCap()
+	assert.Zero(t, limit)
+	assert.Equal(t, EvictReject, eviction)
+
+	added, err := db.AddObservations(context.Background(), []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"d", "e"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, added[0].AddedObservations)
+	assert.Empty(t, added[0].EvictedObservations)
+	assert.Len(t, logObservations(t, db), 5)
+}
+
+func TestObservationCap_Reject(t *testing.T) {
+	db := capTestDB(t)
+	db.SetObservationCap(4, EvictReject)
+	ctx := context.Background()
+
+	added, err := db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"d"}}})
+	assert.NoError(t, err, "reaching the cap is allowed")
+	assert.Empty(t, added[0].EvictedObservations)
+
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"e"}}})
+	var capErr *ObservationCapError
+	if assert.ErrorAs(t, err, &capErr) {
+		assert.Equal(t, ObservationCapError{Entity: "Log", Count: 5, Cap: 4}, *capErr)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, logObservations(t, db), "nothing is added")
+
+	// Adding only duplicates changes nothing, so it isn't rejected
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"a"}}})
+	assert.NoError(t, err)
+}
+
+func TestObservationCap_EvictOldest(t *testing.T) {
+	db := capTestDB(t)
+	db.SetObservationCap(3, EvictOldest)
+	ctx := context.Background()
+
+	added, err := db.AddObservationsIfAbsentSimilar(ctx, []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"d", "e"}}}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, added[0].AddedObservations)
+	assert.Equal(t, []string{"a", "b"}, added[0].EvictedObservations)
+	assert.ElementsMatch(t, []string{"c", "d", "e"}, logObservations(t, db))
+
+	// A cap lowered below what an entity holds is applied on its next addition
+	db.SetObservationCap(1, EvictOldest)
+	added, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Log", Contents: []string{"f"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d", "e"}, added[0].EvictedObservations)
+	assert.Equal(t, []string{"f"}, logObservations(t, db))
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// expiredObservationIDs selects the ids of the observations past their expiry that
+// haven't been purged yet. Observations without an expiry never match.
+const expiredObservationIDs = "(SELECT id FROM observations WHERE expires_at <= datetime('now'))"
+
+// liveObservation is the condition that an observation aliased as alias hasn't
+// expired. Expired observations are hidden from reads until PurgeExpiredObservations
+// deletes them.
+func liveObservation(alias string) string {
+	return "(" + alias + ".expires_at IS NULL OR " + alias + ".expires_at > datetime('now'))"
+}
+
+// expiryValue returns expiresAt as stored, or "" for an observation that doesn't
+// expire
+func expiryValue(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	return expiresAt.UTC().Format(sqliteTimeLayout)
+}
+
+// purgeEntityExpiredTx deletes an entity's expired observations, so contents they
+// hold can be added again
+func purgeEntityExpiredTx(ctx context.Context, tx *sql.Tx, entityID int64) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM observations WHERE entity_id = ? AND expires_at <= datetime('now')", entityID)
+	return err
+}
+
+// PurgeExpiredObservations deletes the observations of every graph past their expiry,
+// in batches, and returns how many were removed
+func (db *DB) PurgeExpiredObservations(ctx context.Context) (int, error) {
+	n, err := db.deleteInBatches(ctx, "observations", "expires_at <= datetime('now')")
+	if n > 0 {
+		db.logger.Info("purged expired observations", slog.Int("observations", n))
+	}
+	return n, err
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObservationExpiry(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "User", EntityType: "person", Observations: []string{"prefers Go"}},
+	})
+	assert.NoError(t, err)
+	results, err := db.AddObservations(ctx, []ObservationAdditionInput{
+		{EntityName: "User", Contents: []string{"on call this week"}, ExpiresAt: time.Now().Add(time.Hour)},
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, results[0].ExpiresAt)
+	// An expiry in the past stands in for a short TTL that has run out
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{
+		{EntityName: "User", Contents: []string{"debugging issue 42"}, ExpiresAt: time.Now().Add(-time.Minute)},
+	})
+	assert.NoError(t, err)
+
+	graph, err := db.OpenNodes(ctx, []string{"User"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prefers Go", "on call this week"}, graph.Entities[0].Observations)
+
+	page, err := db.GetObservations(ctx, "User", 0, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalObservations)
+
+	for _, search := range []func(context.Context, string, int, int) (*SearchResult, error){db.SearchNodes, db.SearchNodesFTS} {
+		found, err := search(ctx, "debugging", 0, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, found.Entities, "expired observations don't match")
+	}
+
+	stats, err := db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Observations)
+	assert.Equal(t, 1, stats.ExpiredObservations)
+
+	// An expired content can be added again before it is purged
+	results, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "User", Contents: []string{"debugging issue 42"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"debugging issue 42"}, results[0].AddedObservations)
+	assert.Empty(t, results[0].ExpiresAt)
+
+	_, err = db.conn.Exec("UPDATE observations SET expires_at = datetime('now', '-1 minute') WHERE content = 'on call this week'")
+	assert.NoError(t, err)
+	purged, err := db.PurgeExpiredObservations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	stats, err = db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Observations)
+	assert.Zero(t, stats.ExpiredObservations)
+
+	purged, err = db.PurgeExpiredObservations(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+}
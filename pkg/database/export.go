@@ -0,0 +1,205 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// sqliteTimeLayout is the format of CURRENT_TIMESTAMP
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// ExportJSONL writes the graph named by ctx as versioned JSONL records: the metadata
+// of each entity type, then every entity with its observations, their writers and
+// creation times, its tags, attributes, pin and aliases, then every relation with its
+// confidence and note. ImportJSONL restores it into another database.
+func (db *DB) ExportJSONL(ctx context.Context, w io.Writer) error {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	meta, err := db.GetTypeMetadata(ctx, nil)
+	if err != nil {
+		return err
+	}
+	types := make([]string, 0, len(meta))
+	for t := range meta {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if err := enc.Encode(graphRecord{V: GraphRecordVersion, Kind: RecordTypeMetadata, EntityType: t, Metadata: meta[t]}); err != nil {
+			return err
+		}
+	}
+
+	if err := db.exportEntities(ctx, enc, graph); err != nil {
+		return err
+	}
+
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT f.name, t.name, r.relation_type, COALESCE(r.confidence, 0), COALESCE(r.note, '')
+		FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE f.graph_id = ?
+		ORDER BY r.id`, graph)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rec := graphRecord{V: GraphRecordVersion, Kind: RecordRelation}
+		if err := rows.Scan(&rec.From, &rec.To, &rec.RelationType, &rec.Confidence, &rec.Note); err != nil {
+			return err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// exportEntities writes one record per entity of graph, reading entities and
+// observations in a single ordered query so only one entity is held in memory
+func (db *DB) exportEntities(ctx context.Context, enc *json.Encoder, graph int64) error {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type, `+tagsColumn+`, `+attributesColumn+`, `+pinnedColumn+`, `+aliasesColumn+`,
+			o.content, o.written_by, strftime('%Y-%m-%dT%H:%M:%SZ', o.created_at)
+		FROM entities e
+		LEFT JOIN observations o ON o.entity_id = e.id AND `+liveObservation("o")+`
+		WHERE e.graph_id = ? AND e.deleted_at IS NULL
+		ORDER BY e.id, o.created_at, o.id`, graph)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *graphRecord
+	var currentID int64
+	for rows.Next() {
+		var id int64
+		var name, entityType string
+		var pinned bool
+		var tags, attributes, aliases, content, writtenBy, createdAt sql.NullString
+		if err := rows.Scan(&id, &name, &entityType, &tags, &attributes, &pinned, &aliases, &content, &writtenBy, &createdAt); err != nil {
+			return err
+		}
+		if current == nil || id != currentID {
+			if current != nil {
+				if err := enc.Encode(current); err != nil {
+					return err
+				}
+			}
+			current = &graphRecord{V: GraphRecordVersion, Kind: RecordEntity, Name: name, EntityType: entityType, Pinned: pinned}
+			if current.Tags, current.Attributes, current.Aliases, err = splitEntityColumns(tags, attributes, aliases); err != nil {
+				return err
+			}
+			currentID = id
+		}
+		if content.Valid {
+			current.Observations = append(current.Observations, recordObservation{
+				Content:   content.String,
+				WrittenBy: writtenBy.String,
+				CreatedAt: createdAt.String,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return enc.Encode(current)
+	}
+	return nil
+}
+
+// splitEntityColumns parses the tags, attributes and aliases of an exported entity, as
+// selected by tagsColumn, attributesColumn and aliasesColumn
+func splitEntityColumns(tags, attributes, aliases sql.NullString) ([]string, map[string]any, []string, error) {
+	tagList, err := splitTags(tags)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	attributeMap, err := splitAttributes(attributes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aliasList, err := splitAliases(aliases)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tagList, attributeMap, aliasList, nil
+}
+
+// ImportJSONL merges a JSONL graph, as written by ExportJSONL or in the reference
+// format, into the database in one transaction. Entities, observations and relations
+// merge as in MergeGraph, and type metadata keys are set. Unknown fields and record
+// kinds are ignored and reported in the summary's warnings.
+func (db *DB) ImportJSONL(ctx context.Context, r io.Reader) (*ImportSummary, error) {
+	return db.importLines(ctx, r, "JSONL", parseGraphLine)
+}
+
+// importLines merges the records parse reads from the lines of r, named format in
+// the log, into the database in one transaction
+func (db *DB) importLines(ctx context.Context, r io.Reader, format string, parse func(string) (*graphRecord, []string, error)) (*ImportSummary, error) {
+	start := time.Now()
+
+	counter := &countingReader{r: r}
+	var warnings importWarnings
+	records, lines, err := readGraphRecords(counter, parse, &warnings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The input is read up front, so only the transaction is retried
+	report, err := retryWriteResult(ctx, db, func() (*MergeReport, error) {
+		tx, err := db.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		report, err := db.mergeRecordsTx(ctx, tx, records)
+		if err != nil {
+			return nil, err
+		}
+		return report, tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.logger.Info(format+" import finished",
+		slog.Int("lines", lines),
+		slog.Int("entities_created", report.EntitiesCreated),
+		slog.Int("relations_created", report.RelationsCreated),
+		slog.Int("warnings", len(warnings.order)),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return &ImportSummary{Lines: lines, Bytes: counter.n, MergeReport: *report, Warnings: warnings.list()}, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
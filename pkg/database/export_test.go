@@ -0,0 +1,210 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportJSONL_RoundTrip(t *testing.T) {
+	src := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := src.CreateEntities(WithWriter(ctx, "planner-agent"), []EntityWithObservations{
+		{Name: "Plan", EntityType: "doc", Observations: []string{"drafted", `quotes " and <html>`}},
+		{Name: "Acme", EntityType: "org", Observations: []string{"founded 1999"}},
+		{Name: "Empty", EntityType: "doc"},
+	})
+	assert.NoError(t, err)
+	_, err = src.AddObservations(WithWriter(ctx, "reviewer-agent"), []ObservationAdditionInput{{EntityName: "Plan", Contents: []string{"reviewed"}}})
+	assert.NoError(t, err)
+	_, err = src.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Acme", Contents: []string{"no writer"}}})
+	assert.NoError(t, err)
+	_, err = src.conn.ExecContext(ctx, "UPDATE observations SET created_at = '2024-03-01 09:30:00' WHERE content IN ('drafted', 'founded 1999')")
+	assert.NoError(t, err)
+	_, err = src.CreateRelations(ctx, []RelationDTO{
+		{From: "Plan", To: "Acme", RelationType: "owned_by"},
+		{From: "Empty", To: "Plan", RelationType: "blocks"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, src.SetTypeMetadata(ctx, "doc", map[string]string{"color": "blue", "x-owner": "docs team"}))
+	assert.NoError(t, src.SetTypeMetadata(ctx, "org", map[string]string{"shape": "box"}))
+
+	var exported bytes.Buffer
+	assert.NoError(t, src.ExportJSONL(ctx, &exported))
+
+	dst := newImportTestDB(t)
+	summary, err := dst.ImportJSONL(ctx, bytes.NewReader(exported.Bytes()))
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Warnings)
+	assert.Equal(t, 3, summary.EntitiesCreated)
+	assert.Equal(t, 2, summary.RelationsCreated)
+	assert.Equal(t, 2, summary.TypeMetadataSet)
+	assert.Equal(t, int64(exported.Len()), summary.Bytes)
+
+	var reexported bytes.Buffer
+	assert.NoError(t, dst.ExportJSONL(ctx, &reexported))
+	assert.Equal(t, exported.String(), reexported.String())
+
+	srcGraph, err := src.ReadGraph(ctx)
+	assert.NoError(t, err)
+	dstGraph, err := dst.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, srcGraph, dstGraph)
+
+	srcTypes, err := src.GetTypeMetadata(ctx, nil)
+	assert.NoError(t, err)
+	dstTypes, err := dst.GetTypeMetadata(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, srcTypes, dstTypes)
+
+	names := []string{"Plan", "Acme", "Empty"}
+	srcMeta, err := src.EntityMetadata(ctx, names)
+	assert.NoError(t, err)
+	dstMeta, err := dst.EntityMetadata(ctx, names)
+	assert.NoError(t, err)
+	assert.Equal(t, srcMeta, dstMeta)
+	assert.Equal(t, 2, dstMeta["Plan"].Contributors)
+
+	// Importing again changes nothing
+	summary, err = dst.ImportJSONL(ctx, bytes.NewReader(exported.Bytes()))
+	assert.NoError(t, err)
+	assert.Zero(t, summary.EntitiesCreated)
+	assert.Zero(t, summary.ObservationsAdded)
+	assert.Zero(t, summary.RelationsCreated)
+	reexported.Reset()
+	assert.NoError(t, dst.ExportJSONL(ctx, &reexported))
+	assert.Equal(t, exported.String(), reexported.String())
+}
+
+// newPropertiesTestDB returns a database whose entities carry tags, attributes, a pin
+// and aliases, and whose relation has a confidence and note
+func newPropertiesTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Plan", EntityType: "doc", Observations: []string{"drafted"}, Tags: []string{"q3", "roadmap"}, Attributes: map[string]any{"url": "https://example.com/plan", "score": 0.5}},
+		{Name: "Acme", EntityType: "org"},
+	})
+	assert.NoError(t, err)
+	_, err = db.PinEntities(ctx, []string{"Acme"})
+	assert.NoError(t, err)
+	_, err = db.AliasEntity(ctx, "Acme", []string{"ACME Corp", "Acme Inc"})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Plan", To: "Acme", RelationType: "owned_by", Confidence: 0.75, Note: "from the kickoff"}})
+	assert.NoError(t, err)
+	return db
+}
+
+// assertProperties checks that db holds the properties newPropertiesTestDB sets
+func assertProperties(t *testing.T, db *DB) {
+	t.Helper()
+	ctx := context.Background()
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddAliases(ctx, graph))
+	if !assert.Len(t, graph.Entities, 2) || !assert.Len(t, graph.Relations, 1) {
+		return
+	}
+	byName := map[string]EntityWithObservations{}
+	for _, e := range graph.Entities {
+		byName[e.Name] = e
+	}
+	plan, acme := byName["Plan"], byName["Acme"]
+	assert.Equal(t, []string{"q3", "roadmap"}, plan.Tags)
+	assert.Equal(t, map[string]any{"url": "https://example.com/plan", "score": 0.5}, plan.Attributes)
+	assert.False(t, plan.Pinned)
+	assert.True(t, acme.Pinned)
+	assert.Equal(t, []string{"ACME Corp", "Acme Inc"}, acme.Aliases)
+	assert.Equal(t, 0.75, graph.Relations[0].Confidence)
+	assert.Equal(t, "from the kickoff", graph.Relations[0].Note)
+}
+
+func TestExportJSONL_RoundTripsProperties(t *testing.T) {
+	src := newPropertiesTestDB(t)
+	ctx := context.Background()
+
+	var exported bytes.Buffer
+	assert.NoError(t, src.ExportJSONL(ctx, &exported))
+	assert.Contains(t, exported.String(), `"tags":["q3","roadmap"],"attributes":{"score":0.5,"url":"https://example.com/plan"}`)
+	assert.Contains(t, exported.String(), `"pinned":true,"aliases":["ACME Corp","Acme Inc"]`)
+	assert.Contains(t, exported.String(), `"relationType":"owned_by","confidence":0.75,"note":"from the kickoff"`)
+
+	dst := newImportTestDB(t)
+	summary, err := dst.ImportJSONL(ctx, bytes.NewReader(exported.Bytes()))
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Warnings)
+	assertProperties(t, dst)
+
+	var reexported bytes.Buffer
+	assert.NoError(t, dst.ExportJSONL(ctx, &reexported))
+	assert.Equal(t, exported.String(), reexported.String())
+
+	// An alias naming another entity of the target is skipped
+	taken := newImportTestDB(t)
+	_, err = taken.CreateEntities(ctx, []EntityWithObservations{{Name: "Acme Inc", EntityType: "org"}})
+	assert.NoError(t, err)
+	summary, err = taken.ImportJSONL(ctx, bytes.NewReader(exported.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.AliasesSkipped)
+	graph, err := taken.OpenNodes(ctx, []string{"Acme"})
+	assert.NoError(t, err)
+	assert.NoError(t, taken.AddAliases(ctx, graph))
+	assert.Equal(t, []string{"ACME Corp"}, graph.Entities[0].Aliases)
+}
+
+func TestImportJSONL_ReferenceFormat(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	summary, err := db.ImportJSONL(ctx, strings.NewReader(importFixture))
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Warnings)
+	assert.Equal(t, 8, summary.Lines)
+	assert.Equal(t, 3, summary.EntitiesCreated)
+	assert.Equal(t, 3, summary.RelationsCreated)
+
+	var out bytes.Buffer
+	assert.NoError(t, db.ExportJSONL(ctx, &out))
+	assert.Contains(t, out.String(), `{"v":2,"kind":"entity","name":"Alice","entityType":"person","observations":[{"content":"engineer","createdAt":`)
+	assert.Contains(t, out.String(), `{"v":2,"kind":"relation","from":"Alice","to":"Bob","relationType":"reports_to"}`)
+
+	// The reference format still rejects unknown types
+	_, err = db.ImportJSONL(ctx, strings.NewReader(`{"type":"alias","name":"A"}`))
+	var lineErr *ImportLineError
+	assert.ErrorAs(t, err, &lineErr)
+	assert.Equal(t, 1, lineErr.Line)
+}
+
+func TestImportJSONL_ForwardTolerant(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	input := `{"v":3,"kind":"entity","name":"Plan","entityType":"doc","embedding":[0.5],"observations":[{"content":"drafted","pinned":true}]}
+{"v":3,"kind":"alias","name":"P","target":"Plan"}
+{"v":2,"kind":"entity","name":"Acme","entityType":"org","embedding":[0.25]}
+{"v":2,"kind":"typeMetadata","entityType":"doc","metadata":{"color":"blue"}}`
+	summary, err := db.ImportJSONL(ctx, strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"line 1: record version 3 is newer than 2; only known fields are read (and 1 more lines)",
+		`line 1: unknown fields ignored: "embedding", "observations.pinned"`,
+		`line 2: unknown record kind "alias" skipped`,
+		`line 3: unknown fields ignored: "embedding"`,
+	}, summary.Warnings)
+	assert.Equal(t, 2, summary.EntitiesCreated)
+	assert.Equal(t, 1, summary.TypeMetadataSet)
+
+	graph, err := db.OpenNodes(ctx, []string{"Plan"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"drafted"}, graph.Entities[0].Observations)
+
+	// Malformed known fields are still errors
+	_, err = db.ImportJSONL(ctx, strings.NewReader(`{"v":1,"kind":"entity","name":"X","entityType":"t","observations":[{"content":"a","createdAt":"yesterday"}]}`))
+	assert.ErrorContains(t, err, "observations[0].createdAt")
+}
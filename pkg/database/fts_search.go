@@ -2,24 +2,15 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"unicode"
 )
 
-// SearchNodesFTS performs full-text search using FTS5 tables for better performance
-func (db *DB) SearchNodesFTS(ctx context.Context, query string) (*KnowledgeGraph, error) {
-	graph := &KnowledgeGraph{
-		Entities:  []EntityWithObservations{},
-		Relations: []RelationDTO{},
-	}
-
-	// Escape special FTS5 characters in the query
-	ftsQuery := escapeFTS5(query)
-	
-	// Use FTS5 MATCH for efficient full-text search
-	// This query finds entities that match in either their name/type or observations
-	rows, err := db.conn.QueryContext(ctx, `
-		WITH matched_entities AS (
+// ftsMatchedEntities selects the ids of the entities whose name, type or unexpired
+// observations match the FTS5 expression bound to both of its parameters
+const ftsMatchedEntities = `
 			-- Match entities by name or type
 			SELECT DISTINCT entity_id as id
 			FROM entities_fts 
@@ -28,271 +19,307 @@ func (db *DB) SearchNodesFTS(ctx context.Context, query string) (*KnowledgeGraph
 			-- Match entities by their observations
 			SELECT DISTINCT entity_id as id
 			FROM observations_fts 
-			WHERE observations_fts MATCH ?
-		)
-		SELECT 
-			e.id,
-			e.name,
-			e.entity_type,
-			COALESCE(GROUP_CONCAT(o.content, '|||'), '') as observations
-		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		WHERE e.id IN (SELECT id FROM matched_entities)
-		GROUP BY e.id, e.name, e.entity_type
-		ORDER BY e.name
-	`, ftsQuery, ftsQuery)
+			WHERE observations_fts MATCH ? AND observation_id NOT IN ` + expiredObservationIDs + `
+`
+
+// SearchNodesFTS performs full-text search using FTS5 tables for better performance,
+// returning limit of the matches (0 = all) after skipping offset, in name order. Like
+// SearchNodes, an entity matches when each term of the query, see parseFTSQuery,
+// matches its name or type, one of its observations, an alias or the type of one of
+// its relations; aliases and relation types contain the words of the term.
+func (db *DB) SearchNodesFTS(ctx context.Context, query string, limit, offset int) (*SearchResult, error) {
+	matched, args := ftsTermMatches(query)
+	result, err := db.searchEntities(ctx, matched, args, false, limit, offset)
 	
-	if err != nil {
+	if err != nil && ctx.Err() == nil {
 		// Fallback to LIKE search if FTS5 is not available or query fails
-		return db.SearchNodes(ctx, query)
+		return db.SearchNodes(ctx, query, limit, offset)
 	}
-	defer rows.Close()
+	return result, err
+}
 
-	entityIDs := []int64{}
-	entityMap := make(map[int64]string)
-	
-	for rows.Next() {
-		var id int64
-		var entity EntityWithObservations
-		var observationsStr string
-		
-		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &observationsStr); err != nil {
-			return nil, err
-		}
-		
-		entityIDs = append(entityIDs, id)
-		entityMap[id] = entity.Name
-		
-		// Parse observations from concatenated string
-		if observationsStr != "" {
-			entity.Observations = strings.Split(observationsStr, "|||")
-		} else {
-			entity.Observations = []string{}
-		}
-		
-		graph.Entities = append(graph.Entities, entity)
-	}
+// FTSQueryError reports an FTS5 expression that SQLite rejected
+type FTSQueryError struct {
+	Query string
+	Err   error
+}
 
-	// Get relations between matched entities with optimized query
-	if len(entityIDs) > 0 {
-		placeholders := make([]string, len(entityIDs))
-		args := make([]any, 0, len(entityIDs)*2)
-		
-		for i, id := range entityIDs {
-			placeholders[i] = "?"
-			args = append(args, id)
-		}
-		
-		// Duplicate the args for both IN clauses
-		args = append(args, args[:len(entityIDs)]...)
-		
-		relQuery := fmt.Sprintf(`
-			SELECT 
-				e1.name as from_name,
-				e2.name as to_name,
-				r.relation_type
-			FROM relations r
-			JOIN entities e1 ON r.from_entity_id = e1.id
-			JOIN entities e2 ON r.to_entity_id = e2.id
-			WHERE r.from_entity_id IN (%s) AND r.to_entity_id IN (%s)
-			ORDER BY e1.name, e2.name, r.relation_type
-		`, strings.Join(placeholders, ","), strings.Join(placeholders, ","))
-		
-		relRows, err := db.conn.QueryContext(ctx, relQuery, args...)
-		if err != nil {
-			return nil, err
-		}
-		defer relRows.Close()
+func (e *FTSQueryError) Error() string {
+	return fmt.Sprintf("invalid FTS5 query %q: %v", e.Query, e.Err)
+}
+
+func (e *FTSQueryError) Unwrap() error {
+	return e.Err
+}
 
-		for relRows.Next() {
-			var rel RelationDTO
-			if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
+// SearchNodesFTSQuery is SearchNodesFTS for an expression already in FTS5 query
+// syntax, which is passed to MATCH as is; aliases are not matched. It never falls back to LIKE: an expression
+// SQLite can't parse, or FTS5 not being available, is an *FTSQueryError.
+func (db *DB) SearchNodesFTSQuery(ctx context.Context, expr string, limit, offset int) (*SearchResult, error) {
+	// Matching one row of each table parses the expression for both, so syntax
+	// errors, and column filters only one table has, are told apart from failures
+	// of the search itself
+	for _, table := range []string{"entities_fts", "observations_fts"} {
+		var found int
+		err := db.reader.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT 1 FROM %s WHERE %s MATCH ? LIMIT 1", table, table), expr,
+		).Scan(&found)
+		if err != nil && err != sql.ErrNoRows {
+			if ctx.Err() != nil {
 				return nil, err
 			}
-			graph.Relations = append(graph.Relations, rel)
+			return nil, &FTSQueryError{Query: expr, Err: err}
 		}
 	}
-
-	return graph, nil
+	return db.searchEntities(ctx, ftsMatchedEntities, []any{expr, expr}, false, limit, offset)
 }
 
-// SearchNodesRanked performs FTS5 search with relevance ranking
-func (db *DB) SearchNodesRanked(ctx context.Context, query string) (*KnowledgeGraph, error) {
-	graph := &KnowledgeGraph{
-		Entities:  []EntityWithObservations{},
-		Relations: []RelationDTO{},
-	}
+// Scores SearchNodesRanked gives an entity for where the query matched it
+const (
+	ScoreNameMatch        = 1.0
+	ScoreObservationMatch = 0.5
+)
 
-	// Escape special FTS5 characters
-	ftsQuery := escapeFTS5(query)
+// SearchNodesRanked performs FTS5 search with relevance ranking: of the entities
+// SearchNodesFTS matches, those with a term matching their name, type or an alias score
+// ScoreNameMatch and rank above those matching only in observations or relation types,
+// which score ScoreObservationMatch. Entities are ordered by score, then name, and
+// carry their score. It falls back to SearchNodesFTS, without scores, when the ranked
+// query fails.
+func (db *DB) SearchNodesRanked(ctx context.Context, query string, limit, offset int) (*SearchResult, error) {
+	matched, matchedArgs := ftsTermMatches(query)
+	terms, excluded := parseFTSQuery(query)
 	
 	// Search with ranking - entities matching in name/type rank higher than observation matches
-	rows, err := db.conn.QueryContext(ctx, `
-		WITH ranked_matches AS (
-			-- Direct entity matches (higher rank)
-			SELECT e.id, 1.0 as rank
-			FROM entities e
-			WHERE e.id IN (
-				SELECT rowid FROM entities_fts 
+	scores := make([]string, len(terms))
+	var args []any
+	for i, term := range terms {
+		if !term.indexed() {
+			matches, matchArgs := likeTermMatches(term)
+			scores[i] = "SELECT id, ? AS score FROM (" + matches + ")"
+			args = append(append(args, ScoreObservationMatch), matchArgs...)
+			continue
+		}
+		expr := ftsExpression([]ftsTerm{term}, excluded)
+		aliased, aliasArgs := aliasMatches(term.words)
+		related, relationArgs := relationTypeMatches(term.words)
+		scores[i] = `
+				-- Direct entity matches (higher rank)
+				SELECT entity_id AS id, ? AS score
+				FROM entities_fts 
 				WHERE entities_fts MATCH ?
-				ORDER BY rank
-			)
-			UNION ALL
-			-- Observation matches (lower rank) 
-			SELECT e.id, 0.5 as rank
-			FROM entities e
-			JOIN observations o ON e.id = o.entity_id
-			WHERE o.id IN (
-				SELECT rowid FROM observations_fts 
-				WHERE observations_fts MATCH ?
-				ORDER BY rank
+				UNION ALL
+				-- Observation matches (lower rank) 
+				SELECT entity_id AS id, ? AS score
+				FROM observations_fts 
+				WHERE observations_fts MATCH ? AND observation_id NOT IN ` + expiredObservationIDs + `
+				UNION ALL
+				-- Alias matches rank as name matches, relation type matches as observation matches
+				SELECT id, ? AS score FROM (` + aliased + `)
+				UNION ALL
+				SELECT id, ? AS score FROM (` + related + `)`
+		args = append(args, ScoreNameMatch, expr, ScoreObservationMatch, expr, ScoreNameMatch)
+		args = append(args, aliasArgs...)
+		args = append(args, ScoreObservationMatch)
+		args = append(args, relationArgs...)
+	}
+	if len(terms) == 0 {
+		// Nothing to match, so nothing scores
+		scores = []string{"SELECT NULL AS id, NULL AS score WHERE 0"}
+	}
+	result, err := db.searchEntities(ctx, `
+			SELECT id, MAX(score) AS score
+			FROM (`+strings.Join(scores, `
+				UNION ALL`)+`
 			)
-		),
-		matched_entities AS (
-			SELECT id, MAX(rank) as max_rank
-			FROM ranked_matches
+			WHERE id IN (`+matched+`)
 			GROUP BY id
-		)
-		SELECT 
-			e.id,
-			e.name,
-			e.entity_type,
-			COALESCE(GROUP_CONCAT(o.content, '|||'), '') as observations,
-			m.max_rank
-		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		JOIN matched_entities m ON e.id = m.id
-		GROUP BY e.id, e.name, e.entity_type, m.max_rank
-		ORDER BY m.max_rank DESC, e.name
-	`, ftsQuery, ftsQuery)
+	`, append(args, matchedArgs...), true, limit, offset)
 	
-	if err != nil {
+	if err != nil && ctx.Err() == nil {
 		// Fallback to regular search
-		return db.SearchNodesFTS(ctx, query)
-	}
-	defer rows.Close()
-
-	entityIDs := []int64{}
-	entityMap := make(map[int64]string)
-	
-	for rows.Next() {
-		var id int64
-		var entity EntityWithObservations
-		var observationsStr string
-		var rank float64
-		
-		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &observationsStr, &rank); err != nil {
-			return nil, err
-		}
-		
-		entityIDs = append(entityIDs, id)
-		entityMap[id] = entity.Name
-		
-		// Parse observations
-		if observationsStr != "" {
-			entity.Observations = strings.Split(observationsStr, "|||")
-		} else {
-			entity.Observations = []string{}
-		}
-		
-		graph.Entities = append(graph.Entities, entity)
+		return db.SearchNodesFTS(ctx, query, limit, offset)
 	}
+	return result, err
+}
 
-	// Get relations (same as before)
-	if len(entityIDs) > 0 {
-		placeholders := make([]string, len(entityIDs))
-		args := make([]any, 0, len(entityIDs)*2)
-		
-		for i, id := range entityIDs {
-			placeholders[i] = "?"
-			args = append(args, id)
-		}
-		
-		args = append(args, args[:len(entityIDs)]...)
-		
-		relQuery := fmt.Sprintf(`
-			SELECT 
-				e1.name as from_name,
-				e2.name as to_name,
-				r.relation_type
-			FROM relations r
-			JOIN entities e1 ON r.from_entity_id = e1.id
-			JOIN entities e2 ON r.to_entity_id = e2.id
-			WHERE r.from_entity_id IN (%s) AND r.to_entity_id IN (%s)
-			ORDER BY e1.name, e2.name, r.relation_type
-		`, strings.Join(placeholders, ","), strings.Join(placeholders, ","))
-		
-		relRows, err := db.conn.QueryContext(ctx, relQuery, args...)
-		if err != nil {
-			return nil, err
-		}
-		defer relRows.Close()
+// ftsTerm is one term of a search_nodes query: an FTS5 expression and the words of
+// it, without operators, that aliases and relation types are matched by
+type ftsTerm struct {
+	expr  string
+	words []string
+	// compound is set when expr joins words with operators
+	compound bool
+}
 
-		for relRows.Next() {
-			var rel RelationDTO
-			if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
-				return nil, err
-			}
-			graph.Relations = append(graph.Relations, rel)
-		}
-	}
+// indexed reports whether the term has a word FTS5 indexes: the tokenizer drops
+// punctuation and symbols, so a word of only those, such as an emoji, matches nothing
+func (t ftsTerm) indexed() bool {
+	return t.compound || strings.IndexFunc(strings.Join(t.words, ""), func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) >= 0
+}
 
-	return graph, nil
+// likeTermMatches selects the ids of the entities matching a term FTS5 can't, as
+// SearchNodes matches it
+func likeTermMatches(t ftsTerm) (string, []any) {
+	condition, args := likeSearchCondition(strings.Join(t.words, " "))
+	return "SELECT e.id AS id FROM entities e WHERE " + condition, args
 }
 
-// escapeFTS5 escapes special characters in FTS5 queries
-func escapeFTS5(query string) string {
-	// Trim whitespace
+// parseFTSQuery splits a search_nodes query into the terms an entity must each match,
+// and the quoted words excluded from every match. Each whitespace-separated word
+// becomes a quoted term, so punctuation and FTS5 syntax in it are literal. OR, AND and
+// NOT between two plain words are kept as operators, joining them into one term;
+// +word terms are required like plain ones and -word terms excluded. A query wrapped
+// in double quotes is one phrase.
+func parseFTSQuery(query string) ([]ftsTerm, []string) {
 	query = strings.TrimSpace(query)
 	
-	// Check if user wants phrase search (wrapped in quotes)
-	if strings.HasPrefix(query, "\"") && strings.HasSuffix(query, "\"") {
-		// User explicitly wants phrase search, keep the quotes
-		return query
+	// User explicitly wants phrase search
+	if len(query) >= 2 && strings.HasPrefix(query, "\"") && strings.HasSuffix(query, "\"") {
+		phrase := query[1 : len(query)-1]
+		return []ftsTerm{{expr: quoteFTS5(phrase), words: []string{phrase}}}, nil
 	}
 	
-	// Split query into words and escape each
 	words := strings.Fields(query)
-	escapedWords := make([]string, 0, len(words))
-	
-	for _, word := range words {
-		// Skip empty words
-		if word == "" {
-			continue
+	var terms []ftsTerm
+	var excluded []string
+	joining := false
+	for i, word := range words {
+		switch {
+		case isFTS5Operator(word) && i > 0 && isPlainFTS5Word(words[i-1]) && i+1 < len(words) && isPlainFTS5Word(words[i+1]):
+			// The previous word was plain, so it ended the last term
+			last := &terms[len(terms)-1]
+			last.expr += " " + word + " "
+			last.compound = true
+			joining = true
+		case len(word) > 1 && word[0] == '+':
+			terms = append(terms, ftsTerm{expr: quoteFTS5(word[1:]), words: []string{word[1:]}})
+		case len(word) > 1 && word[0] == '-':
+			excluded = append(excluded, quoteFTS5(word[1:]))
+		case joining:
+			last := &terms[len(terms)-1]
+			last.expr += quoteFTS5(word)
+			last.words = append(last.words, word)
+			joining = false
+		default:
+			terms = append(terms, ftsTerm{expr: quoteFTS5(word), words: []string{word}})
 		}
-		
-		// Check for special operators (don't escape these)
-		if word == "OR" || word == "AND" || word == "NOT" {
-			escapedWords = append(escapedWords, word)
-			continue
+	}
+	return terms, excluded
+}
+
+// ftsExpression joins terms with AND and excludes the excluded words from the
+// result. FTS5 can only exclude from other matches, so without terms to match it is
+// the empty phrase, which matches nothing.
+func ftsExpression(terms []ftsTerm, excluded []string) string {
+	if len(terms) == 0 {
+		return "\"\""
+	}
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term.expr
+		if term.compound && len(terms) > 1 {
+			parts[i] = "(" + term.expr + ")"
 		}
-		
-		// Check for prefixed operators (+required, -excluded)
-		if strings.HasPrefix(word, "+") || strings.HasPrefix(word, "-") {
-			// Keep the operator, escape the word part if needed
-			op := word[0:1]
-			term := word[1:]
-			if term != "" {
-				escapedWords = append(escapedWords, op+"\""+term+"\"")
-			}
+	}
+	expr := strings.Join(parts, " AND ")
+	// NOT binds tighter than AND and OR, so it has to apply to the whole expression
+	if len(excluded) > 0 {
+		expr = "(" + expr + ") NOT " + strings.Join(excluded, " NOT ")
+	}
+	return expr
+}
+
+// escapeFTS5 turns a search_nodes query into an FTS5 expression matching a row that
+// matches every term of it, see parseFTSQuery
+func escapeFTS5(query string) string {
+	return ftsExpression(parseFTSQuery(query))
+}
+
+// escapeFTS5Any turns a search_nodes query into an FTS5 expression matching a row
+// that matches any term of it, as snippets of the observations of an entity matching
+// across several rows need
+func escapeFTS5Any(query string) string {
+	terms, excluded := parseFTSQuery(query)
+	if len(terms) < 2 {
+		return ftsExpression(terms, excluded)
+	}
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = "(" + ftsExpression([]ftsTerm{term}, excluded) + ")"
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// ftsTermMatches selects the ids of the entities matching every term of a
+// search_nodes query in their name or type, an unexpired observation, an alias or a
+// relation type. Each term is matched on its own, so the terms of a match may be
+// spread over several rows.
+func ftsTermMatches(query string) (string, []any) {
+	terms, excluded := parseFTSQuery(query)
+	if len(terms) == 0 {
+		empty := ftsExpression(nil, nil)
+		return ftsMatchedEntities, []any{empty, empty}
+	}
+	selects := make([]string, len(terms))
+	var args []any
+	for i, term := range terms {
+		if !term.indexed() {
+			matches, matchArgs := likeTermMatches(term)
+			selects[i] = "SELECT id FROM (" + matches + ")"
+			args = append(args, matchArgs...)
 			continue
 		}
-		
-		// Regular word - wrap in quotes to handle special chars
-		escapedWords = append(escapedWords, "\""+word+"\"")
+		expr := ftsExpression([]ftsTerm{term}, excluded)
+		// Aliases and relation types aren't indexed, so they are matched by the words of the term
+		aliased, aliasArgs := aliasMatches(term.words)
+		related, relationArgs := relationTypeMatches(term.words)
+		selects[i] = "SELECT id FROM (" + ftsMatchedEntities + "UNION " + aliased + " UNION " + related + ")"
+		args = append(args, expr, expr)
+		args = append(args, aliasArgs...)
+		args = append(args, relationArgs...)
 	}
-	
-	// Join with OR for default "any word" matching
-	if len(escapedWords) == 0 {
-		return "\"\""
+	return strings.Join(selects, "\nINTERSECT "), args
+}
+
+// relationTypeMatches selects the ids of the entities with a relation, to or from
+// them, whose type contains any of words, the words of a term of an FTS search query
+func relationTypeMatches(words []string) (string, []any) {
+	condition, args := wordsCondition("relation_type", words)
+	return "SELECT from_entity_id AS id FROM relations WHERE " + condition +
+		" UNION SELECT to_entity_id FROM relations WHERE " + condition, append(args, args...)
+}
+
+// quoteFTS5 returns s as an FTS5 string, doubling the quotes in it
+func quoteFTS5(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
+}
+
+// isFTS5Operator reports whether word is an FTS5 boolean operator
+func isFTS5Operator(word string) bool {
+	return word == "OR" || word == "AND" || word == "NOT"
+}
+
+// isPlainFTS5Word reports whether word is a term without a + or - prefix that
+// parseFTSQuery can join with an operator
+func isPlainFTS5Word(word string) bool {
+	if isFTS5Operator(word) {
+		return false
 	}
-	
-	// Join words with OR operator for inclusive search
-	return strings.Join(escapedWords, " OR ")
+	return len(word) < 2 || (word[0] != '+' && word[0] != '-')
 }
 
 // RebuildFTSIndex rebuilds the FTS index (useful after bulk imports)
 func (db *DB) RebuildFTSIndex(ctx context.Context) error {
+	return db.retryWrite(ctx, func() error {
+		return db.rebuildFTSIndex(ctx)
+	})
+}
+
+// rebuildFTSIndex makes one attempt at RebuildFTSIndex
+func (db *DB) rebuildFTSIndex(ctx context.Context) error {
 	statements := []string{
 		// Rebuild entities FTS
 		`DELETE FROM entities_fts`,
@@ -0,0 +1,424 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSearchNodes_FTSParity runs the same queries through the FTS and LIKE engines.
+// Both match an entity when every term matches it somewhere, so on whole-word terms,
+// however they are spread over names, types, observations, aliases and relation
+// types, the engines must agree.
+func TestSearchNodes_FTSParity(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+
+	ctx := context.Background()
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Apple", EntityType: "Fruit", Observations: []string{"Red and tasty"}},
+		{Name: "Banana", EntityType: "Fruit", Observations: []string{"Yellow and sweet"}},
+		{Name: "Carrot", EntityType: "Vegetable", Observations: []string{"Orange and crunchy"}},
+	})
+	assert.NoError(t, err)
+
+	names := func(g *KnowledgeGraph) []string {
+		out := []string{}
+		for _, e := range g.Entities {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+	_, err = db.AliasEntity(ctx, "Banana", []string{"Plantain"})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Apple", To: "Carrot", RelationType: "pairs_with"}})
+	assert.NoError(t, err)
+
+	for _, q := range []string{
+		"fruit", "orange", "zebra", "apple tasty", "banana sweet", "yellow sweet", "crunchy vegetable",
+		// Terms that match different entities, or different fields of one
+		"apple fruit", "fruit sweet", "red sweet", "tasty crunchy", "fruit zebra",
+		// Aliases and relation types
+		"plantain", "plantain yellow", "pairs_with", "pairs_with vegetable", "pairs_with fruit sweet",
+	} {
+		fts, err := db.SearchNodesFTS(ctx, q, 0, 0)
+		assert.NoError(t, err)
+		like, err := db.SearchNodes(ctx, q, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, names(&fts.KnowledgeGraph), names(&like.KnowledgeGraph), "query %q", q)
+	}
+
+	// Spot-check that the engines agree on the intended results
+	for q, want := range map[string][]string{
+		"apple fruit":          {"Apple"},
+		"fruit sweet":          {"Banana"},
+		"red sweet":            {},
+		"plantain yellow":      {"Banana"},
+		"pairs_with":           {"Apple", "Carrot"},
+		"pairs_with vegetable": {"Carrot"},
+	} {
+		fts, err := db.SearchNodesFTS(ctx, q, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, want, names(&fts.KnowledgeGraph), "query %q", q)
+	}
+}
+
+func TestSearchNodes_Paging(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alpha", EntityType: "project"},
+		{Name: "Beta", EntityType: "project"},
+		{Name: "Gamma", EntityType: "project"},
+		{Name: "Delta", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alpha", To: "Beta", RelationType: "depends_on"},
+		{From: "Beta", To: "Gamma", RelationType: "depends_on"},
+	})
+	assert.NoError(t, err)
+
+	engines := map[string]func(ctx context.Context, query string, limit, offset int) (*SearchResult, error){
+		"like": db.SearchNodes,
+	}
+	if db.IsFTSEnabled() {
+		engines["fts"] = db.SearchNodesFTS
+	}
+	for name, search := range engines {
+		t.Run(name, func(t *testing.T) {
+			all, err := search(ctx, "project", 0, 0)
+			assert.NoError(t, err)
+			assert.Len(t, all.Entities, 3)
+			assert.Equal(t, 3, all.TotalMatches)
+			assert.Nil(t, all.NextOffset)
+
+			first, err := search(ctx, "project", 2, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, "Alpha", first.Entities[0].Name)
+			assert.Equal(t, "Beta", first.Entities[1].Name)
+			assert.Equal(t, 3, first.TotalMatches)
+			if assert.NotNil(t, first.NextOffset) {
+				assert.Equal(t, 2, *first.NextOffset)
+			}
+			// Only relations among the page: Beta -> Gamma is left out
+			assert.Equal(t, []RelationDTO{{From: "Alpha", To: "Beta", RelationType: "depends_on"}}, first.Relations)
+
+			last, err := search(ctx, "project", 2, 2)
+			assert.NoError(t, err)
+			if assert.Len(t, last.Entities, 1) {
+				assert.Equal(t, "Gamma", last.Entities[0].Name)
+			}
+			assert.Empty(t, last.Relations)
+			assert.Nil(t, last.NextOffset)
+
+			past, err := search(ctx, "project", 2, 10)
+			assert.NoError(t, err)
+			assert.Empty(t, past.Entities)
+			assert.Equal(t, 3, past.TotalMatches)
+		})
+	}
+}
+
+func TestMatchNodes(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Project Atlas", EntityType: "project", Observations: []string{"Project Atlas launched"}},
+		{Name: "Project Atlas v2", EntityType: "project"},
+		{Name: "100% done", EntityType: "milestone"},
+		{Name: "1000 users", EntityType: "milestone"},
+		{Name: "a_b", EntityType: "note"},
+		{Name: "axb", EntityType: "note"},
+	})
+	assert.NoError(t, err)
+
+	names := func(mode, query string) []string {
+		t.Helper()
+		result, err := db.MatchNodes(ctx, query, mode, 0, 0)
+		assert.NoError(t, err)
+		out := []string{}
+		for _, e := range result.Entities {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"Project Atlas"}, names(SearchExact, "Project Atlas"))
+	assert.Equal(t, []string{}, names(SearchExact, "project atlas"), "exact is case sensitive")
+	assert.Equal(t, []string{}, names(SearchExact, "Atlas"))
+	assert.Equal(t, []string{"a_b", "axb"}, names(SearchExact, "note"), "types match too")
+
+	assert.Equal(t, []string{"Project Atlas", "Project Atlas v2"}, names(SearchPrefix, "project atlas"))
+	assert.Equal(t, []string{}, names(SearchPrefix, "Atlas"), "observations are not searched")
+	// Wildcards in the query are literal
+	assert.Equal(t, []string{"100% done"}, names(SearchPrefix, "100%"))
+	assert.Equal(t, []string{"a_b"}, names(SearchPrefix, "a_"))
+
+	page, err := db.MatchNodes(ctx, "project", SearchPrefix, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.TotalMatches)
+	assert.Len(t, page.Entities, 1)
+
+	_, err = db.MatchNodes(ctx, "x", SearchSubstring, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestSearchNodes_LiteralWildcards(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "100%_done", EntityType: "milestone"},
+		{Name: "1000 done", EntityType: "milestone"},
+		{Name: "snake_case", EntityType: "style"},
+		{Name: "snakeXcase", EntityType: "style"},
+		{Name: `C:\temp`, EntityType: "path"},
+		{Name: "Budget", EntityType: "plan", Observations: []string{"cut by 20% this year"}},
+		{Name: "Forecast", EntityType: "plan", Observations: []string{"cut by 200 next year"}},
+	})
+	assert.NoError(t, err)
+
+	for query, want := range map[string][]string{
+		"100%_done": {"100%_done"},
+		"%":         {"100%_done", "Budget"},
+		"_":         {"100%_done", "snake_case"},
+		"e_c":       {"snake_case"},
+		"20%":       {"Budget"},
+		`\t`:        {`C:\temp`},
+		`\`:         {`C:\temp`},
+	} {
+		result, err := db.SearchNodes(ctx, query, 0, 0)
+		assert.NoError(t, err)
+		got := []string{}
+		for _, e := range result.Entities {
+			got = append(got, e.Name)
+		}
+		assert.ElementsMatch(t, want, got, "query %q", query)
+	}
+}
+
+func TestEscapeFTS5(t *testing.T) {
+	for query, want := range map[string]string{
+		"":               `""`,
+		"coordinator":    `"coordinator"`,
+		"band notes":     `"band" AND "notes"`,
+		"mcp-memory":     `"mcp-memory"`,
+		`"exact phrase"`: `"exact phrase"`,
+		`said "hi`:       `"said" AND """hi"`,
+		`"`:              `""""`,
+		"a AND b":        `"a" AND "b"`,
+		"a OR b NOT c":   `"a" OR "b" NOT "c"`,
+		"AND a":          `"AND" AND "a"`,
+		"a AND":          `"a" AND "AND"`,
+		"a AND +b":       `"a" AND "AND" AND "b"`,
+		"a OR b c":       `("a" OR "b") AND "c"`,
+		"+must -not":     `("must") NOT "not"`,
+		"a b -c -d":      `("a" AND "b") NOT "c" NOT "d"`,
+		"-only":          `""`,
+		"- +":            `"-" AND "+"`,
+		`+"quoted" word`: `"""quoted""" AND "word"`,
+	} {
+		assert.Equal(t, want, escapeFTS5(query), "query %q", query)
+	}
+}
+
+// TestSearchNodesFTS_Escaping checks that queries with operator substrings,
+// punctuation and quotes reach FTS5 as valid expressions matching the seeded entities
+func TestSearchNodesFTS_Escaping(t *testing.T) {
+	db := newImportTestDB(t)
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Coordinator", EntityType: "role"},
+		{Name: "Band", EntityType: "group"},
+		{Name: "Notes", EntityType: "document"},
+		{Name: "mcp-memory", EntityType: "repository"},
+		{Name: "Speech", EntityType: "event", Observations: []string{`He said "hello" twice`}},
+		{Name: "Greeting", EntityType: "event", Observations: []string{"hello there"}},
+	})
+	assert.NoError(t, err)
+
+	for query, want := range map[string][]string{
+		"coordinator":       {"Coordinator"},
+		"band":              {"Band"},
+		"notes":             {"Notes"},
+		"mcp-memory":        {"mcp-memory"},
+		`said "hello`:       {"Speech"},
+		"hello":             {"Greeting", "Speech"},
+		`"said "hello""`:    {"Speech"},
+		"hello -twice":      {"Greeting"},
+		"+hello +twice":     {"Speech"},
+		"band AND notes":    {},
+		"band OR notes":     {"Band", "Notes"},
+		"coordinator -band": {"Coordinator"},
+	} {
+		// FTS5 errors would fall back to LIKE, so check the expression is valid first
+		expr := escapeFTS5(query)
+		var n int
+		assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM entities_fts WHERE entities_fts MATCH ?", expr).Scan(&n), "query %q as %s", query, expr)
+
+		result, err := db.SearchNodesFTS(ctx, query, 0, 0)
+		assert.NoError(t, err)
+		got := []string{}
+		for _, e := range result.Entities {
+			got = append(got, e.Name)
+		}
+		assert.ElementsMatch(t, want, got, "query %q as %s", query, expr)
+	}
+}
+
+func TestSearchNodesFTSQuery(t *testing.T) {
+	db := newImportTestDB(t)
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"project written in golang"}},
+		{Name: "Engine", EntityType: "service", Observations: []string{"project written in rust", "archived project in rust"}},
+		{Name: "Website", EntityType: "service", Observations: []string{"project written in typescript"}},
+		{Name: "Gopher", EntityType: "mascot", Observations: []string{"golang mascot"}},
+	})
+	assert.NoError(t, err)
+
+	for expr, want := range map[string][]string{
+		"project AND (golang OR rust)": {"Gateway", "Engine"},
+		// Names and types, and each observation, are matched separately: Engine has
+		// an observation without "archived", and no row holds both a type and a word
+		"project AND (golang OR rust) NOT archived": {"Gateway", "Engine"},
+		"archived AND rust":                         {"Engine"},
+		"service AND golang":                        {},
+		`"written in rust"`:                         {"Engine"},
+		`"in golang"`:                               {"Gateway"},
+		"golang NOT project":                        {"Gopher"},
+		"gol*":                                      {"Gateway", "Gopher"},
+		"entity_type:mascot":                        nil,
+		"NEAR(written typescript, 2)":               {"Website"},
+		"project AND NOT":                           nil,
+		`"unterminated`:                             nil,
+		"(golang":                                   nil,
+	} {
+		result, err := db.SearchNodesFTSQuery(ctx, expr, 0, 0)
+		if want == nil {
+			var queryErr *FTSQueryError
+			assert.ErrorAs(t, err, &queryErr, "expr %q", expr)
+			continue
+		}
+		assert.NoError(t, err, "expr %q", expr)
+		if result == nil {
+			continue
+		}
+		got := []string{}
+		for _, e := range result.Entities {
+			got = append(got, e.Name)
+		}
+		assert.ElementsMatch(t, want, got, "expr %q", expr)
+	}
+}
+
+func TestSearchNodesRanked(t *testing.T) {
+	db := newImportTestDB(t)
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alpha", EntityType: "team", Observations: []string{"works on the kubernetes migration"}},
+		{Name: "Kubernetes", EntityType: "platform", Observations: []string{"runs every service"}},
+		{Name: "Beta", EntityType: "team", Observations: []string{"kubernetes on-call"}},
+		{Name: "Cluster", EntityType: "kubernetes", Observations: []string{"kubernetes 1.30"}},
+		{Name: "Gamma", EntityType: "team"},
+	})
+	assert.NoError(t, err)
+
+	result, err := db.SearchNodesRanked(ctx, "kubernetes", 0, 0)
+	assert.NoError(t, err)
+	type ranked struct {
+		Name  string
+		Score float64
+	}
+	got := []ranked{}
+	for _, e := range result.Entities {
+		got = append(got, ranked{e.Name, e.Score})
+	}
+	// Name and type matches come first, each group in name order
+	assert.Equal(t, []ranked{
+		{"Cluster", ScoreNameMatch},
+		{"Kubernetes", ScoreNameMatch},
+		{"Alpha", ScoreObservationMatch},
+		{"Beta", ScoreObservationMatch},
+	}, got)
+	assert.Equal(t, 4, result.TotalMatches)
+
+	page, err := db.SearchNodesRanked(ctx, "kubernetes", 2, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, page.Entities, 2) {
+		assert.Equal(t, "Kubernetes", page.Entities[0].Name)
+		assert.Equal(t, "Alpha", page.Entities[1].Name)
+	}
+	assert.Equal(t, 4, page.TotalMatches)
+
+	// The unranked searches leave the score unset
+	plain, err := db.SearchNodesFTS(ctx, "kubernetes", 0, 0)
+	assert.NoError(t, err)
+	for _, e := range plain.Entities {
+		assert.Zero(t, e.Score, e.Name)
+	}
+}
+
+// TestDeleteEntities_NoStaleFTSRows checks that deleting an entity leaves no rows in
+// the FTS tables, whether its observations are deleted first, as DeleteEntities does,
+// or removed by ON DELETE CASCADE, as replacing and merging entities do. SQLite fires
+// the observations delete trigger for cascaded rows too, without recursive_triggers.
+func TestDeleteEntities_NoStaleFTSRows(t *testing.T) {
+	db := newImportTestDB(t)
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Ghost", EntityType: "spirit", Observations: []string{"haunts the attic", "rattles chains"}},
+		{Name: "Phantom", EntityType: "spirit", Observations: []string{"haunts the opera"}},
+		{Name: "Keeper", EntityType: "person", Observations: []string{"locks the attic"}},
+	})
+	assert.NoError(t, err)
+
+	ftsRows := func(table string) int {
+		var n int
+		assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n))
+		return n
+	}
+
+	_, err = db.DeleteEntities(ctx, []string{"Ghost"})
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "DELETE FROM entities WHERE name = 'Phantom'")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, ftsRows("entities_fts"))
+	assert.Equal(t, 1, ftsRows("observations_fts"))
+	for _, q := range []string{"haunts", "chains", "spirit"} {
+		result, err := db.SearchNodesFTS(ctx, q, 0, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, result.Entities, q)
+	}
+	result, err := db.SearchNodesFTS(ctx, "attic", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Entities, 1) {
+		assert.Equal(t, "Keeper", result.Entities[0].Name)
+	}
+
+	report, err := db.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.OK)
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteGraphML writes graph as a directed GraphML graph. Nodes have the entity name
+// as id and label and carry entityType plus every metadata key of their type;
+// relations become edges labelled with their type. Names are escaped as XML, with
+// characters XML cannot hold replaced.
+func WriteGraphML(w io.Writer, graph *KnowledgeGraph, meta TypeMetadata) error {
+	// Each metadata key used by the exported types is declared once, after the
+	// fixed keys
+	used := make(map[string]bool)
+	for _, e := range graph.Entities {
+		for k := range meta[e.EntityType] {
+			if k != "label" && k != "entityType" {
+				used[k] = true
+			}
+		}
+	}
+	metaKeys := make([]string, 0, len(used))
+	for k := range used {
+		metaKeys = append(metaKeys, k)
+	}
+	sort.Strings(metaKeys)
+	keyIDs := make(map[string]string, len(metaKeys))
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	bw.WriteString(`  <key id="label" for="all" attr.name="label" attr.type="string"/>` + "\n")
+	bw.WriteString(`  <key id="entityType" for="node" attr.name="entityType" attr.type="string"/>` + "\n")
+	bw.WriteString(`  <key id="relationType" for="edge" attr.name="relationType" attr.type="string"/>` + "\n")
+	for i, k := range metaKeys {
+		keyIDs[k] = "m" + strconv.Itoa(i)
+		bw.WriteString(`  <key id="` + keyIDs[k] + `" for="node" attr.name="` + xmlEscape(k) + `" attr.type="string"/>` + "\n")
+	}
+	bw.WriteString(`  <graph id="memory" edgedefault="directed">` + "\n")
+
+	for _, e := range graph.Entities {
+		bw.WriteString(`    <node id="` + xmlEscape(e.Name) + `">`)
+		writeGraphMLData(bw, "label", e.Name)
+		writeGraphMLData(bw, "entityType", e.EntityType)
+		hints := meta[e.EntityType]
+		for _, k := range metaKeys {
+			if v, ok := hints[k]; ok {
+				writeGraphMLData(bw, keyIDs[k], v)
+			}
+		}
+		bw.WriteString("</node>\n")
+	}
+	for _, r := range graph.Relations {
+		bw.WriteString(`    <edge source="` + xmlEscape(r.From) + `" target="` + xmlEscape(r.To) + `">`)
+		writeGraphMLData(bw, "label", r.RelationType)
+		writeGraphMLData(bw, "relationType", r.RelationType)
+		bw.WriteString("</edge>\n")
+	}
+	bw.WriteString("  </graph>\n</graphml>\n")
+	return bw.Flush()
+}
+
+func writeGraphMLData(bw *bufio.Writer, key, value string) {
+	bw.WriteString(`<data key="` + key + `">` + xmlEscape(value) + `</data>`)
+}
+
+// xmlEscape returns s escaped for XML text and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
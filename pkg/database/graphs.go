@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+)
+
+// DefaultGraph is the graph entities belong to unless the context names another,
+// and the one databases created before graphs existed were migrated into
+const DefaultGraph = "default"
+
+// defaultGraphID is the id the graphs migration gives DefaultGraph
+const defaultGraphID = 1
+
+// MaxGraphNameLength is the longest graph name ValidGraphName accepts
+const MaxGraphNameLength = 64
+
+// graphNamePattern is what a graph name may look like: a letter or digit followed by
+// letters, digits, '.', '_' and '-'
+var graphNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidGraphName reports whether name can name a graph
+func ValidGraphName(name string) bool {
+	return len(name) <= MaxGraphNameLength && graphNamePattern.MatchString(name)
+}
+
+type graphKey struct{}
+
+// WithGraph returns ctx carrying the name of the graph the operations run with it
+// work on. Entity names are unique within a graph, so the same name can name a
+// different entity in each.
+func WithGraph(ctx context.Context, graph string) context.Context {
+	return context.WithValue(ctx, graphKey{}, graph)
+}
+
+// graphFrom returns the graph set by WithGraph, or DefaultGraph if there is none
+func graphFrom(ctx context.Context) string {
+	if graph, _ := ctx.Value(graphKey{}).(string); graph != "" {
+		return graph
+	}
+	return DefaultGraph
+}
+
+// graphID returns the id of the graph named by ctx, or 0, which no entity has, if it
+// doesn't exist yet
+func graphID(ctx context.Context, q relationQuerier) (int64, error) {
+	graph := graphFrom(ctx)
+	if graph == DefaultGraph {
+		return defaultGraphID, nil
+	}
+	var id int64
+	err := q.QueryRowContext(ctx, "SELECT id FROM graphs WHERE name = ?", graph).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// createGraphTx returns the id of the graph named by ctx, creating it if it doesn't
+// exist yet
+func createGraphTx(ctx context.Context, tx *sql.Tx) (int64, error) {
+	graph := graphFrom(ctx)
+	if graph == DefaultGraph {
+		return defaultGraphID, nil
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO graphs (name) VALUES (?) ON CONFLICT(name) DO NOTHING", graph); err != nil {
+		return 0, err
+	}
+	return graphID(ctx, tx)
+}
+
+// GraphSummary counts what one graph holds
+type GraphSummary struct {
+	Name         string `json:"name"`
+	Entities     int    `json:"entities"`
+	Observations int    `json:"observations"`
+	Relations    int    `json:"relations"`
+	// CreatedAt is when the graph was created (RFC 3339, UTC)
+	CreatedAt string `json:"createdAt"`
+}
+
+// ListGraphs summarizes every graph, in name order. A graph is created with its first
+// entity and stays listed when its entities are deleted; the default graph is always
+// listed. The trash of a graph is not.
+func (db *DB) ListGraphs(ctx context.Context) ([]GraphSummary, error) {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT
+			g.name,
+			(SELECT COUNT(*) FROM entities WHERE graph_id = g.id),
+			(SELECT COUNT(*) FROM observations o JOIN entities e ON e.id = o.entity_id WHERE e.graph_id = g.id AND `+liveObservation("o")+`),
+			(SELECT COUNT(*) FROM relations r JOIN entities e ON e.id = r.from_entity_id WHERE e.graph_id = g.id),
+			`+rfc3339Column("g.created_at")+`
+		FROM graphs g
+		WHERE g.name NOT LIKE '%' || ?
+		ORDER BY g.name`, trashGraphSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	graphs := []GraphSummary{}
+	for rows.Next() {
+		var graph GraphSummary
+		var createdAt sql.NullString
+		if err := rows.Scan(&graph.Name, &graph.Entities, &graph.Observations, &graph.Relations, &createdAt); err != nil {
+			return nil, err
+		}
+		graph.CreatedAt = createdAt.String
+		graphs = append(graphs, graph)
+	}
+	return graphs, rows.Err()
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidGraphName(t *testing.T) {
+	for _, name := range []string{"default", "project-a", "Project_B.2", "7"} {
+		assert.True(t, ValidGraphName(name), name)
+	}
+	for _, name := range []string{"", "-a", ".hidden", "a b", "a/b", "ä", string(make([]byte, MaxGraphNameLength+1))} {
+		assert.False(t, ValidGraphName(name), name)
+	}
+}
+
+func TestGraphs_Scoped(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	projectA, projectB := WithGraph(ctx, "project-a"), WithGraph(ctx, "project-b")
+
+	// The same names in two graphs, and in the default one
+	for i, gctx := range []context.Context{projectA, projectB, ctx} {
+		created, err := db.CreateEntities(gctx, []EntityWithObservations{
+			{Name: "Alice", EntityType: "person", Observations: []string{[]string{"in A", "in B", "in default"}[i]}},
+			{Name: "Acme", EntityType: "company"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, created, 2, "names are unique per graph")
+		result, err := db.CreateRelations(gctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+		assert.NoError(t, err)
+		assert.Len(t, result.Relations, 1)
+	}
+	_, err := db.AddObservations(projectA, []ObservationAdditionInput{{EntityName: "Alice", Contents: []string{"likes Go"}}})
+	assert.NoError(t, err)
+
+	graph, err := db.ReadGraph(projectA)
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 2) {
+		assert.Equal(t, []string{"in A", "likes Go"}, graph.Entities[1].Observations)
+	}
+	assert.Len(t, graph.Relations, 1)
+
+	found, err := db.SearchNodes(projectB, "in", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, found.Entities, 1) {
+		assert.Equal(t, []string{"in B"}, found.Entities[0].Observations)
+	}
+
+	// Deleting from one graph leaves the others alone
+	deleted, err := db.DeleteEntities(projectB, []string{"Alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted.DeletedEntities)
+	nodes, err := db.OpenNodes(projectB, []string{"Alice", "Acme"})
+	assert.NoError(t, err)
+	assert.Len(t, nodes.Entities, 1)
+	assert.Empty(t, nodes.Relations)
+	for _, gctx := range []context.Context{projectA, ctx} {
+		nodes, err := db.OpenNodes(gctx, []string{"Alice", "Acme"})
+		assert.NoError(t, err)
+		assert.Len(t, nodes.Entities, 2)
+		assert.Len(t, nodes.Relations, 1)
+	}
+
+	// A graph that doesn't exist is empty
+	graph, err = db.ReadGraph(WithGraph(ctx, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+
+	graphs, err := db.ListGraphs(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, graphs, 3) {
+		for i, want := range []GraphSummary{
+			{Name: "default", Entities: 2, Observations: 1, Relations: 1},
+			{Name: "project-a", Entities: 2, Observations: 2, Relations: 1},
+			{Name: "project-b", Entities: 1, Observations: 0, Relations: 0},
+		} {
+			assert.NotEmpty(t, graphs[i].CreatedAt)
+			graphs[i].CreatedAt = ""
+			assert.Equal(t, want, graphs[i])
+		}
+	}
+}
+
+func TestGraphs_ExportScoped(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	projectA := WithGraph(ctx, "project-a")
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person", Observations: []string{"in default"}}})
+	assert.NoError(t, err)
+	_, err = db.CreateEntities(projectA, []EntityWithObservations{
+		{Name: "Alice", EntityType: "robot", Observations: []string{"in A"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(projectA, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+	// A trashed entity is left out of its graph's export
+	db.SetSoftDelete(true)
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Gone", EntityType: "thing"}})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(ctx, []string{"Gone"})
+	assert.NoError(t, err)
+
+	for _, gctx := range []context.Context{ctx, projectA} {
+		want, err := db.ReadGraph(gctx)
+		assert.NoError(t, err)
+
+		// Each format holds only the graph exported, and imports back as it was, into
+		// another graph too
+		var jsonl, document bytes.Buffer
+		assert.NoError(t, db.ExportJSONL(gctx, &jsonl))
+		assert.NoError(t, db.Export(gctx, &document))
+
+		target := newImportTestDB(t)
+		_, err = target.ImportJSONL(ctx, &jsonl)
+		assert.NoError(t, err)
+		_, err = target.Import(WithGraph(ctx, "copy"), &document, ImportReplace)
+		assert.NoError(t, err)
+		for _, tctx := range []context.Context{ctx, WithGraph(ctx, "copy")} {
+			got, err := target.ReadGraph(tctx)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, graphFrom(gctx))
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// DefaultMaxSnapshots is how many labeled snapshots a graph keeps when no limit is set
+const DefaultMaxSnapshots = 20
+
+// DefaultMaxSnapshotBytes is the largest compressed snapshot stored when no limit is set
+const DefaultMaxSnapshotBytes = 32 << 20
+
+// MaxSnapshotLabelLength is the longest label, in bytes, a snapshot may have
+const MaxSnapshotLabelLength = 100
+
+// ErrSnapshotNotFound reports a snapshot label the graph has no snapshot under
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrSnapshotExists reports a snapshot label the graph already has a snapshot under
+var ErrSnapshotExists = errors.New("snapshot label already taken")
+
+// SnapshotTooLargeError reports a snapshot that would exceed the size limit
+type SnapshotTooLargeError struct {
+	Bytes, Max int64
+}
+
+func (e *SnapshotTooLargeError) Error() string {
+	return fmt.Sprintf("snapshot is %d bytes compressed, more than the limit of %d", e.Bytes, e.Max)
+}
+
+// SetSnapshotLimits sets how many labeled snapshots a graph keeps and the largest
+// compressed snapshot stored (0 = the defaults)
+func (db *DB) SetSnapshotLimits(maxCount int, maxBytes int64) {
+	db.maxSnapshots, db.maxSnapshotBytes = max(maxCount, 0), max(maxBytes, 0)
+}
+
+// SnapshotLimits returns how many labeled snapshots a graph keeps and the largest
+// compressed snapshot stored
+func (db *DB) SnapshotLimits() (int, int64) {
+	maxCount, maxBytes := db.maxSnapshots, db.maxSnapshotBytes
+	if maxCount == 0 {
+		maxCount = DefaultMaxSnapshots
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxSnapshotBytes
+	}
+	return maxCount, maxBytes
+}
+
+// GraphSnapshot describes a labeled snapshot of a graph
+type GraphSnapshot struct {
+	Label string `json:"label"`
+	// TakenAt is when the snapshot was taken (RFC 3339, UTC)
+	TakenAt      string `json:"takenAt"`
+	Entities     int    `json:"entities"`
+	Observations int    `json:"observations"`
+	Relations    int    `json:"relations"`
+	// Bytes is the compressed size of the snapshot
+	Bytes int64 `json:"bytes"`
+}
+
+// CreatedSnapshot is the result of CreateSnapshot
+type CreatedSnapshot struct {
+	GraphSnapshot
+	// Evicted lists the labels of the oldest snapshots deleted to stay within the
+	// snapshot count
+	Evicted []string `json:"evicted"`
+}
+
+// ObservationChange lists the observations of an entity added and removed since a
+// snapshot
+type ObservationChange struct {
+	Name    string   `json:"name"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// TypeChange is an entity whose type changed since a snapshot
+type TypeChange struct {
+	Name   string `json:"name"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// SnapshotDiff describes how a graph changed since a snapshot of it
+type SnapshotDiff struct {
+	Label              string              `json:"label"`
+	TakenAt            string              `json:"takenAt"`
+	EntitiesAdded      []string            `json:"entitiesAdded"`
+	EntitiesRemoved    []string            `json:"entitiesRemoved"`
+	TypeChanges        []TypeChange        `json:"typeChanges"`
+	ObservationChanges []ObservationChange `json:"observationChanges"`
+	RelationsAdded     []RelationDTO       `json:"relationsAdded"`
+	RelationsRemoved   []RelationDTO       `json:"relationsRemoved"`
+}
+
+// CreateSnapshot stores the graph, with all its observations, as a compressed JSON
+// export under label. When the graph then holds more snapshots than the limit, the
+// oldest are deleted.
+func (db *DB) CreateSnapshot(ctx context.Context, label string) (*CreatedSnapshot, error) {
+	graph, err := db.readGraph(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	data, err := compressSnapshot(graph)
+	if err != nil {
+		return nil, err
+	}
+	maxCount, maxBytes := db.SnapshotLimits()
+	if int64(len(data)) > maxBytes {
+		return nil, &SnapshotTooLargeError{Bytes: int64(len(data)), Max: maxBytes}
+	}
+
+	created := &CreatedSnapshot{GraphSnapshot: GraphSnapshot{
+		Label:     label,
+		Entities:  len(graph.Entities),
+		Relations: len(graph.Relations),
+		Bytes:     int64(len(data)),
+	}}
+	for _, entity := range graph.Entities {
+		created.Observations += len(entity.Observations)
+	}
+
+	err = db.retryWrite(ctx, func() error {
+		tx, err := db.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		id, err := createGraphTx(ctx, tx)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO snapshots (graph_id, label, data, entities, observations, relations)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(graph_id, label) DO NOTHING
+			RETURNING `+rfc3339Column("created_at"),
+			id, label, data, created.Entities, created.Observations, created.Relations,
+		).Scan(&created.TakenAt)
+		if err == sql.ErrNoRows {
+			return ErrSnapshotExists
+		}
+		if err != nil {
+			return err
+		}
+
+		created.Evicted = []string{}
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM snapshots
+			WHERE id IN (SELECT id FROM snapshots WHERE graph_id = ? ORDER BY id DESC LIMIT -1 OFFSET ?)
+			RETURNING label`, id, maxCount)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var evicted string
+			if err := rows.Scan(&evicted); err != nil {
+				rows.Close()
+				return err
+			}
+			created.Evicted = append(created.Evicted, evicted)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("snapshot created",
+		slog.String("graph", graphFrom(ctx)),
+		slog.Int("entities", created.Entities),
+		slog.Int64("bytes", created.Bytes),
+		slog.Int("evicted", len(created.Evicted)),
+	)
+	return created, nil
+}
+
+// ListSnapshots lists the snapshots of the graph, newest first
+func (db *DB) ListSnapshots(ctx context.Context) ([]GraphSnapshot, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT label, `+rfc3339Column("created_at")+`, entities, observations, relations, length(data)
+		FROM snapshots
+		WHERE graph_id = ?
+		ORDER BY id DESC`, graph)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []GraphSnapshot{}
+	for rows.Next() {
+		var s GraphSnapshot
+		if err := rows.Scan(&s.Label, &s.TakenAt, &s.Entities, &s.Observations, &s.Relations, &s.Bytes); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// DiffSnapshot compares the graph with its snapshot under label, reporting what was
+// added and removed since. Observations are compared as sets, and relations by their
+// ends and type.
+func (db *DB) DiffSnapshot(ctx context.Context, label string) (*SnapshotDiff, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	diff := &SnapshotDiff{Label: label}
+	err = db.reader.QueryRowContext(ctx,
+		"SELECT data, "+rfc3339Column("created_at")+" FROM snapshots WHERE graph_id = ? AND label = ?", graph, label,
+	).Scan(&data, &diff.TakenAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	before, err := decompressSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", label, err)
+	}
+	after, err := db.readGraph(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := DiffGraphs(before, after, DiffOptions{IgnoreObservationOrder: true})
+	diff.EntitiesAdded = changes.ExtraEntities
+	diff.EntitiesRemoved = changes.MissingEntities
+	diff.TypeChanges = make([]TypeChange, len(changes.TypeChanges))
+	for i, change := range changes.TypeChanges {
+		diff.TypeChanges[i] = TypeChange{Name: change.Name, Before: change.Expected, After: change.Actual}
+	}
+	diff.ObservationChanges = make([]ObservationChange, len(changes.ObservationChanges))
+	for i, change := range changes.ObservationChanges {
+		diff.ObservationChanges[i] = ObservationChange{Name: change.Name, Added: change.Extra, Removed: change.Missing}
+	}
+	diff.RelationsAdded = changes.ExtraRelations
+	diff.RelationsRemoved = changes.MissingRelations
+	return diff, nil
+}
+
+// compressSnapshot encodes graph as gzipped JSON
+func compressSnapshot(graph *KnowledgeGraph) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(graph); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressSnapshot decodes a graph encoded by compressSnapshot
+func decompressSnapshot(data []byte) (*KnowledgeGraph, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	graph := &KnowledgeGraph{}
+	if err := json.NewDecoder(zr).Decode(graph); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// snapshotsContaining returns the ids of the snapshots whose label or graph contains
+// any of terms, case-insensitively. Snapshots are compressed, so each is read in
+// turn rather than searched by SQL.
+func snapshotsContaining(ctx context.Context, tx *sql.Tx, terms []string) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, label, data FROM snapshots")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lowered := make([]string, len(terms))
+	for i, term := range terms {
+		lowered[i] = strings.ToLower(term)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var label string
+		var data []byte
+		if err := rows.Scan(&id, &label, &data); err != nil {
+			return nil, err
+		}
+		graph, err := decompressSnapshot(data)
+		if err != nil {
+			return nil, err
+		}
+		text := []byte(label)
+		for _, entity := range graph.Entities {
+			text = fmt.Appendf(text, "\n%s\n%s\n%s\n%s", entity.Name, entity.EntityType,
+				strings.Join(entity.Observations, "\n"), strings.Join(entity.Tags, "\n"))
+			if len(entity.Attributes) > 0 {
+				attributes, err := json.Marshal(entity.Attributes)
+				if err != nil {
+					return nil, err
+				}
+				text = append(append(text, '\n'), attributes...)
+			}
+		}
+		for _, rel := range graph.Relations {
+			text = fmt.Appendf(text, "\n%s\n%s", rel.RelationType, rel.Note)
+		}
+		lower := strings.ToLower(string(text))
+		for _, term := range lowered {
+			if strings.Contains(lower, term) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, rows.Err()
+}
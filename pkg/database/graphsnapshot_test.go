@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphSnapshots(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+
+	created, err := db.CreateSnapshot(ctx, "before refactor")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created.Entities)
+	assert.Equal(t, 2, created.Observations)
+	assert.Equal(t, 2, created.Relations)
+	assert.NotEmpty(t, created.TakenAt)
+	assert.Positive(t, created.Bytes)
+	assert.Empty(t, created.Evicted)
+	_, err = db.CreateSnapshot(ctx, "before refactor")
+	assert.ErrorIs(t, err, ErrSnapshotExists)
+
+	diff, err := db.DiffSnapshot(ctx, "before refactor")
+	assert.NoError(t, err)
+	assert.Equal(t, &SnapshotDiff{
+		Label: "before refactor", TakenAt: created.TakenAt,
+		EntitiesAdded: []string{}, EntitiesRemoved: []string{}, TypeChanges: []TypeChange{},
+		ObservationChanges: []ObservationChange{}, RelationsAdded: []RelationDTO{}, RelationsRemoved: []RelationDTO{},
+	}, diff, "nothing changed yet")
+
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Carol", EntityType: "person"}})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Alice", Contents: []string{"moved to Paris"}}})
+	assert.NoError(t, err)
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Alice", Observations: []string{"lives in Berlin"}}})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Carol", To: "Acme", RelationType: "works_at"}})
+	assert.NoError(t, err)
+
+	diff, err = db.DiffSnapshot(ctx, "before refactor")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Carol"}, diff.EntitiesAdded)
+	assert.Equal(t, []string{"Bob"}, diff.EntitiesRemoved)
+	assert.Empty(t, diff.TypeChanges)
+	assert.Equal(t, []ObservationChange{{Name: "Alice", Added: []string{"moved to Paris"}, Removed: []string{"lives in Berlin"}}}, diff.ObservationChanges)
+	assert.Equal(t, []RelationDTO{{From: "Carol", To: "Acme", RelationType: "works_at"}}, diff.RelationsAdded)
+	assert.Equal(t, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}}, diff.RelationsRemoved)
+
+	_, err = db.DiffSnapshot(ctx, "nonexistent")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+
+	// The oldest snapshots go beyond the count
+	db.SetSnapshotLimits(2, 0)
+	_, err = db.CreateSnapshot(ctx, "second")
+	assert.NoError(t, err)
+	created, err = db.CreateSnapshot(ctx, "third")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"before refactor"}, created.Evicted)
+	snapshots, err := db.ListSnapshots(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, snapshots, 2) {
+		assert.Equal(t, "third", snapshots[0].Label)
+		assert.Equal(t, "second", snapshots[1].Label)
+		assert.Equal(t, 3, snapshots[0].Entities)
+	}
+
+	// Too large a snapshot is refused
+	db.SetSnapshotLimits(0, 10)
+	_, err = db.CreateSnapshot(ctx, "huge")
+	var sizeErr *SnapshotTooLargeError
+	if assert.True(t, errors.As(err, &sizeErr)) {
+		assert.Equal(t, int64(10), sizeErr.Max)
+	}
+
+	// Graphs keep their snapshots apart
+	other := WithGraph(ctx, "work")
+	snapshots, err = db.ListSnapshots(other)
+	assert.NoError(t, err)
+	assert.Empty(t, snapshots)
+	_, err = db.DiffSnapshot(other, "second")
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+}
@@ -0,0 +1,94 @@
+package database
+
+import "context"
+
+// DefaultHotspotLimit is how many entities Hotspots returns when no limit is given
+const DefaultHotspotLimit = 10
+
+// MaxHotspotLimit is the most entities Hotspots returns at once
+const MaxHotspotLimit = 100
+
+// Degrees Hotspots ranks entities by: relations to them, from them, or both
+const (
+	DegreeTotal = "total"
+	DegreeIn    = "in"
+	DegreeOut   = "out"
+)
+
+// hotspotOrder is the column Hotspots sorts by for each degree
+var hotspotOrder = map[string]string{
+	DegreeTotal: "total",
+	DegreeIn:    "inbound",
+	DegreeOut:   "outbound",
+}
+
+// HotspotOptions selects how Hotspots ranks entities
+type HotspotOptions struct {
+	// By is the degree to rank by (default DegreeTotal)
+	By string
+	// EntityType keeps only the entities of the type when set
+	EntityType string
+	// Limit is how many entities to return (0 = DefaultHotspotLimit)
+	Limit int
+}
+
+// Hotspot is an entity with the number of relations to and from it
+type Hotspot struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType"`
+	In         int    `json:"in"`
+	Out        int    `json:"out"`
+	// Total counts a relation from the entity to itself twice
+	Total int `json:"total"`
+}
+
+// Hotspots returns the most connected entities of the graph, highest degree first and
+// then by name. Entities without relations are never listed.
+func (db *DB) Hotspots(ctx context.Context, opts HotspotOptions) ([]Hotspot, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	order, ok := hotspotOrder[opts.By]
+	if !ok {
+		order = hotspotOrder[DegreeTotal]
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultHotspotLimit
+	}
+	limit = min(limit, MaxHotspotLimit)
+
+	cond, args := "", []any{graph}
+	if opts.EntityType != "" {
+		cond = " AND e.entity_type = ?"
+		args = append(args, opts.EntityType)
+	}
+	rows, err := db.reader.QueryContext(ctx, `
+		WITH ends(entity_id, inbound, outbound) AS (
+			SELECT to_entity_id, 1, 0 FROM relations
+			UNION ALL
+			SELECT from_entity_id, 0, 1 FROM relations
+		)
+		SELECT e.name, e.entity_type, SUM(ends.inbound) AS inbound, SUM(ends.outbound) AS outbound, COUNT(*) AS total
+		FROM ends
+		JOIN entities e ON e.id = ends.entity_id
+		WHERE e.graph_id = ?`+cond+`
+		GROUP BY e.id
+		ORDER BY `+order+` DESC, e.name
+		LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hotspots := []Hotspot{}
+	for rows.Next() {
+		var h Hotspot
+		if err := rows.Scan(&h.Name, &h.EntityType, &h.In, &h.Out, &h.Total); err != nil {
+			return nil, err
+		}
+		hotspots = append(hotspots, h)
+	}
+	return hotspots, rows.Err()
+}
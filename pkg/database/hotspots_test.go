@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotspots(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	// A star around Hub, with Alice also following Bob
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Hub", EntityType: "project"},
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Carol", EntityType: "person"},
+		{Name: "Lonely", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Hub", RelationType: "works_on"},
+		{From: "Bob", To: "Hub", RelationType: "works_on"},
+		{From: "Carol", To: "Hub", RelationType: "works_on"},
+		{From: "Hub", To: "Alice", RelationType: "led_by"},
+		{From: "Alice", To: "Bob", RelationType: "follows"},
+	})
+	assert.NoError(t, err)
+
+	hotspots, err := db.Hotspots(ctx, HotspotOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Hotspot{
+		{Name: "Hub", EntityType: "project", In: 3, Out: 1, Total: 4},
+		{Name: "Alice", EntityType: "person", In: 1, Out: 2, Total: 3},
+		{Name: "Bob", EntityType: "person", In: 1, Out: 1, Total: 2},
+		{Name: "Carol", EntityType: "person", Out: 1, Total: 1},
+	}, hotspots, "entities without relations are not listed")
+
+	hotspots, err = db.Hotspots(ctx, HotspotOptions{By: DegreeOut, Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, hotspots, 2) {
+		assert.Equal(t, "Alice", hotspots[0].Name)
+		assert.Equal(t, "Bob", hotspots[1].Name, "ties are broken by name")
+	}
+
+	hotspots, err = db.Hotspots(ctx, HotspotOptions{By: DegreeIn, EntityType: "person", Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, hotspots, 1) {
+		assert.Equal(t, "Alice", hotspots[0].Name)
+	}
+
+	hotspots, err = db.Hotspots(ctx, HotspotOptions{EntityType: "company"})
+	assert.NoError(t, err)
+	assert.Empty(t, hotspots)
+}
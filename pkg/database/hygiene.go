@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Duplicate name reasons
+const (
+	DuplicateCase        = "case"        // names equal ignoring case
+	DuplicatePunctuation = "punctuation" // names equal ignoring case, spacing and punctuation
+	DuplicateSimilar     = "similar"     // names of the same type within a small edit distance
+)
+
+const (
+	// DuplicateNameSimilarity is the edit ratio of normalized names from which two
+	// entities of the same type are reported as similar
+	DuplicateNameSimilarity = 0.85
+	// maxFuzzyTypeSize bounds the entities of one type compared pairwise for similar
+	// names; larger types are listed in HygieneReport.FuzzySkippedTypes
+	maxFuzzyTypeSize = 2000
+)
+
+// HygieneOptions configures a Hygiene report
+type HygieneOptions struct {
+	// StaleAfter is how long an entity must go without writes to be stale
+	StaleAfter time.Duration
+	// OversizedAt is the observation count from which an entity is oversized
+	// (0 = 90% of the observation limit; no check when there is no limit)
+	OversizedAt int
+}
+
+// HygieneReport lists the parts of the graph that may need cleaning up
+type HygieneReport struct {
+	Entities     int `json:"entities"`
+	Observations int `json:"observations"`
+	Relations    int `json:"relations"`
+	// EmptyEntities have no observations
+	EmptyEntities []string `json:"emptyEntities"`
+	// StaleEntities have had no observations or relations written since the cutoff,
+	// oldest first
+	StaleEntities []StaleEntity `json:"staleEntities"`
+	// DuplicateNames are groups of entities that may be the same thing
+	DuplicateNames []DuplicateNameGroup `json:"duplicateNames"`
+	// FuzzySkippedTypes have too many entities to compare for similar names
+	FuzzySkippedTypes []string `json:"fuzzySkippedTypes,omitempty"`
+	// OversizedAt is the observation count from which an entity is oversized (0 = not checked)
+	OversizedAt       int               `json:"oversizedAt"`
+	OversizedEntities []OversizedEntity `json:"oversizedEntities"`
+	// RareRelationTypes are used by a single relation, often a misspelling of another type
+	RareRelationTypes []RareRelationType `json:"rareRelationTypes"`
+	// ConstraintBreaches is existing data breaking the relation constraints
+	ConstraintBreaches []ConstraintBreach `json:"constraintBreaches"`
+	// FTS is nil when full-text search is not available
+	FTS *FTSDrift `json:"fts,omitempty"`
+}
+
+// StaleEntity is an entity without recent writes
+type StaleEntity struct {
+	Name string `json:"name"`
+	// LastWriteAt is when it, an observation or a relation of it was last written (RFC 3339, UTC)
+	LastWriteAt string `json:"lastWriteAt"`
+}
+
+// DuplicateNameGroup is a set of entity names that may refer to the same thing
+type DuplicateNameGroup struct {
+	Names  []string `json:"names"`
+	Reason string   `json:"reason"`
+	// Similarity is the edit ratio of a DuplicateSimilar pair
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// OversizedEntity is an entity near or over the observation limit of read paths
+type OversizedEntity struct {
+	Name         string `json:"name"`
+	Observations int    `json:"observations"`
+}
+
+// RareRelationType is a relation type used by a single relation
+type RareRelationType struct {
+	RelationType string      `json:"relationType"`
+	Relation     RelationDTO `json:"relation"`
+}
+
+// FTSDrift counts rows missing from or left behind in the full-text indexes
+type FTSDrift struct {
+	MissingEntities      int `json:"missingEntities"`
+	OrphanedEntities     int `json:"orphanedEntities"`
+	MissingObservations  int `json:"missingObservations"`
+	OrphanedObservations int `json:"orphanedObservations"`
+}
+
+// Drifted reports whether the indexes differ from the tables
+func (d *FTSDrift) Drifted() bool {
+	return d != nil && d.MissingEntities+d.OrphanedEntities+d.MissingObservations+d.OrphanedObservations > 0
+}
+
+// Hygiene reports empty, stale, duplicate and oversized entities, relation types used
+// once, relations breaking constraints and full-text index drift. Every list is complete; callers sample them.
+func (db *DB) Hygiene(ctx context.Context, opts HygieneOptions) (*HygieneReport, error) {
+	report := &HygieneReport{
+		EmptyEntities:     []string{},
+		StaleEntities:     []StaleEntity{},
+		DuplicateNames:    []DuplicateNameGroup{},
+		OversizedEntities: []OversizedEntity{},
+		RareRelationTypes: []RareRelationType{},
+	}
+
+	if err := db.reader.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM entities), (SELECT COUNT(*) FROM observations), (SELECT COUNT(*) FROM relations)`,
+	).Scan(&report.Entities, &report.Observations, &report.Relations); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if report.EmptyEntities, err = db.queryNames(ctx, `
+		SELECT e.name FROM entities e
+		WHERE NOT EXISTS (SELECT 1 FROM observations o WHERE o.entity_id = e.id)
+		ORDER BY e.name`); err != nil {
+		return nil, fmt.Errorf("empty entities: %w", err)
+	}
+	if err := db.staleEntities(ctx, report, time.Now().Add(-opts.StaleAfter)); err != nil {
+		return nil, fmt.Errorf("stale entities: %w", err)
+	}
+	if err := db.duplicateNames(ctx, report); err != nil {
+		return nil, fmt.Errorf("duplicate names: %w", err)
+	}
+
+	report.OversizedAt = opts.OversizedAt
+	if report.OversizedAt <= 0 {
+		report.OversizedAt = db.observationLimit * 9 / 10
+	}
+	if report.OversizedAt > 0 {
+		if err := db.oversizedEntities(ctx, report); err != nil {
+			return nil, fmt.Errorf("oversized entities: %w", err)
+		}
+	}
+
+	if err := db.rareRelationTypes(ctx, report); err != nil {
+		return nil, fmt.Errorf("rare relation types: %w", err)
+	}
+	if report.ConstraintBreaches, err = db.constraintBreaches(ctx); err != nil {
+		return nil, fmt.Errorf("relation constraints: %w", err)
+	}
+
+	if db.ftsEnabled {
+		// NOT IN builds a temporary index of the subquery, where a correlated lookup
+		// would scan the FTS table, whose id columns are unindexed, once per row
+		report.FTS = &FTSDrift{}
+		if err := db.reader.QueryRowContext(ctx, `
+			SELECT
+				(SELECT COUNT(*) FROM entities WHERE id NOT IN (SELECT entity_id FROM entities_fts)),
+				(SELECT COUNT(*) FROM entities_fts WHERE entity_id NOT IN (SELECT id FROM entities)),
+				(SELECT COUNT(*) FROM observations WHERE id NOT IN (SELECT observation_id FROM observations_fts)),
+				(SELECT COUNT(*) FROM observations_fts WHERE observation_id NOT IN (SELECT id FROM observations))`,
+		).Scan(&report.FTS.MissingEntities, &report.FTS.OrphanedEntities, &report.FTS.MissingObservations, &report.FTS.OrphanedObservations); err != nil {
+			return nil, fmt.Errorf("fts drift: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (db *DB) queryNames(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (db *DB) staleEntities(ctx context.Context, report *HygieneReport, cutoff time.Time) error {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT name, strftime('%Y-%m-%dT%H:%M:%SZ', last_write) FROM (
+			SELECT e.name, MAX(
+				e.created_at,
+				COALESCE((SELECT MAX(created_at) FROM observations WHERE entity_id = e.id), e.created_at),
+				COALESCE((SELECT MAX(created_at) FROM relations WHERE from_entity_id = e.id), e.created_at),
+				COALESCE((SELECT MAX(created_at) FROM relations WHERE to_entity_id = e.id), e.created_at)
+			) AS last_write
+			FROM entities e
+		)
+		WHERE last_write < ?
+		ORDER BY last_write, name`, cutoff.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stale StaleEntity
+		if err := rows.Scan(&stale.Name, &stale.LastWriteAt); err != nil {
+			return err
+		}
+		report.StaleEntities = append(report.StaleEntities, stale)
+	}
+	return rows.Err()
+}
+
+// duplicateNames groups names equal ignoring case, then ignoring punctuation and
+// spacing, and pairs names of the same type with a DuplicateNameSimilarity edit ratio
+// that differ in more than their numbers
+func (db *DB) duplicateNames(ctx context.Context, report *HygieneReport) error {
+	rows, err := db.reader.QueryContext(ctx, "SELECT name, entity_type FROM entities ORDER BY name")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byKey := map[string][]string{}
+	var keys []string
+	byType := map[string][]string{}
+	for rows.Next() {
+		var name, entityType string
+		if err := rows.Scan(&name, &entityType); err != nil {
+			return err
+		}
+		key := nameKey(name)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], name)
+		byType[entityType] = append(byType[entityType], name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		names := byKey[key]
+		if len(names) < 2 {
+			continue
+		}
+		reason := DuplicateCase
+		for _, name := range names[1:] {
+			if !strings.EqualFold(name, names[0]) {
+				reason = DuplicatePunctuation
+			}
+		}
+		report.DuplicateNames = append(report.DuplicateNames, DuplicateNameGroup{Names: names, Reason: reason})
+	}
+
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		names := byType[t]
+		if len(names) > maxFuzzyTypeSize {
+			report.FuzzySkippedTypes = append(report.FuzzySkippedTypes, t)
+			continue
+		}
+		for i, a := range names {
+			ka := nameKey(a)
+			for _, b := range names[i+1:] {
+				kb := nameKey(b)
+				if ka == kb {
+					continue // grouped above
+				}
+				if stripDigits(ka) == stripDigits(kb) {
+					continue // numbered siblings such as "Server 1" and "Server 2"
+				}
+				if score := editRatio(ka, kb); score >= DuplicateNameSimilarity {
+					report.DuplicateNames = append(report.DuplicateNames, DuplicateNameGroup{
+						Names:      []string{a, b},
+						Reason:     DuplicateSimilar,
+						Similarity: math.Round(score*1000) / 1000,
+					})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// nameKey lower-cases name and drops everything but letters and digits
+func nameKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// stripDigits drops the digits of a name key
+func stripDigits(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+		return r
+	}, key)
+}
+
+func (db *DB) oversizedEntities(ctx context.Context, report *HygieneReport) error {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT e.name, COUNT(*) AS n
+		FROM entities e JOIN observations o ON o.entity_id = e.id
+		GROUP BY e.id
+		HAVING n >= ?
+		ORDER BY n DESC, e.name`, report.OversizedAt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entity OversizedEntity
+		if err := rows.Scan(&entity.Name, &entity.Observations); err != nil {
+			return err
+		}
+		report.OversizedEntities = append(report.OversizedEntities, entity)
+	}
+	return rows.Err()
+}
+
+func (db *DB) rareRelationTypes(ctx context.Context, report *HygieneReport) error {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT r.relation_type, f.name, t.name
+		FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE r.relation_type IN (SELECT relation_type FROM relations GROUP BY relation_type HAVING COUNT(*) = 1)
+		ORDER BY r.relation_type`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rare RareRelationType
+		if err := rows.Scan(&rare.RelationType, &rare.Relation.From, &rare.Relation.To); err != nil {
+			return err
+		}
+		rare.Relation.RelationType = rare.RelationType
+		report.RareRelationTypes = append(report.RareRelationTypes, rare)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newHygieneFixture returns a database with one or more cases of every hygiene finding
+func newHygieneFixture(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	db.SetObservationLimit(10)
+	ctx := context.Background()
+
+	log := make([]string, 9)
+	for i := range log {
+		log[i] = "entry " + string(rune('a'+i))
+	}
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "alice", EntityType: "person", Observations: []string{"likes go"}},
+		{Name: "Jon Smith", EntityType: "person", Observations: []string{"manager"}},
+		{Name: "John Smith", EntityType: "person", Observations: []string{"director"}},
+		{Name: "Acme Corp", EntityType: "org", Observations: []string{"founded 1999"}},
+		{Name: "acme-corp", EntityType: "company", Observations: []string{"customer"}},
+		{Name: "Placeholder", EntityType: "note"},
+		{Name: "Old Project", EntityType: "project", Observations: []string{"shipped"}},
+		{Name: "Build Log", EntityType: "log", Observations: log},
+		{Name: "Server 1", EntityType: "host", Observations: []string{"primary"}},
+		{Name: "Server 2", EntityType: "host", Observations: []string{"replica"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme Corp", RelationType: "works_at"},
+		{From: "Jon Smith", To: "Acme Corp", RelationType: "works_at"},
+		{From: "John Smith", To: "Acme Corp", RelationType: "wrks_at"},
+	})
+	assert.NoError(t, err)
+
+	for _, stmt := range []string{
+		"UPDATE entities SET created_at = '2020-01-02 03:04:05' WHERE name = 'Old Project'",
+		"UPDATE observations SET created_at = '2020-01-02 03:04:05' WHERE content = 'shipped'",
+	} {
+		_, err := db.conn.ExecContext(ctx, stmt)
+		assert.NoError(t, err)
+	}
+	if db.IsFTSEnabled() {
+		for _, stmt := range []string{
+			"DELETE FROM entities_fts WHERE name = 'Placeholder'",
+			"DELETE FROM observations_fts WHERE content = 'customer'",
+			"INSERT INTO observations_fts(observation_id, entity_id, content) VALUES (9999, 9999, 'orphan')",
+		} {
+			_, err := db.conn.ExecContext(ctx, stmt)
+			assert.NoError(t, err)
+		}
+	}
+	return db
+}
+
+func TestHygiene(t *testing.T) {
+	db := newHygieneFixture(t)
+
+	report, err := db.Hygiene(context.Background(), HygieneOptions{StaleAfter: 90 * 24 * time.Hour})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 11, report.Entities)
+	assert.Equal(t, 18, report.Observations)
+	assert.Equal(t, 3, report.Relations)
+	assert.Equal(t, []string{"Placeholder"}, report.EmptyEntities)
+	assert.Equal(t, []StaleEntity{{Name: "Old Project", LastWriteAt: "2020-01-02T03:04:05Z"}}, report.StaleEntities)
+	assert.Equal(t, []DuplicateNameGroup{
+		{Names: []string{"Acme Corp", "acme-corp"}, Reason: DuplicatePunctuation},
+		{Names: []string{"Alice", "alice"}, Reason: DuplicateCase},
+		{Names: []string{"John Smith", "Jon Smith"}, Reason: DuplicateSimilar, Similarity: 0.941},
+	}, report.DuplicateNames)
+	assert.Empty(t, report.FuzzySkippedTypes)
+	assert.Equal(t, 9, report.OversizedAt)
+	assert.Equal(t, []OversizedEntity{{Name: "Build Log", Observations: 9}}, report.OversizedEntities)
+	assert.Equal(t, []RareRelationType{{
+		RelationType: "wrks_at",
+		Relation:     RelationDTO{From: "John Smith", To: "Acme Corp", RelationType: "wrks_at"},
+	}}, report.RareRelationTypes)
+
+	if db.IsFTSEnabled() {
+		assert.Equal(t, &FTSDrift{MissingEntities: 1, MissingObservations: 1, OrphanedObservations: 1}, report.FTS)
+		assert.True(t, report.FTS.Drifted())
+	}
+}
+
+func TestHygiene_CleanGraph(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "reports_to"},
+		{From: "Bob", To: "Alice", RelationType: "reports_to"},
+	})
+	assert.NoError(t, err)
+
+	report, err := db.Hygiene(ctx, HygieneOptions{StaleAfter: time.Hour})
+	assert.NoError(t, err)
+	assert.Empty(t, report.EmptyEntities)
+	assert.Empty(t, report.StaleEntities)
+	assert.Empty(t, report.DuplicateNames)
+	assert.Empty(t, report.OversizedEntities)
+	assert.Empty(t, report.RareRelationTypes)
+	assert.False(t, report.FTS.Drifted())
+}
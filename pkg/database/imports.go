@@ -0,0 +1,367 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// ImportFormat is the format import chunks are read in: one JSON object per line,
+	// {"type":"entity","name":...,"entityType":...,"observations":[...]} or
+	// {"type":"relation","from":...,"to":...,"relationType":...}, or the versioned
+	// records written by ExportJSONL
+	ImportFormat = "jsonl"
+	// ImportFirstSequence is the sequence number of an import's first chunk
+	ImportFirstSequence = 1
+	// DefaultImportTTL is how long an import may sit without a new chunk before maintenance expires it
+	DefaultImportTTL = 24 * time.Hour
+	// MaxImportLineBytes bounds a single line, which may span chunks
+	MaxImportLineBytes = 8 << 20
+)
+
+// ErrImportNotFound is returned for an unknown, committed, aborted or expired import
+var ErrImportNotFound = errors.New("import not found")
+
+// ImportSequenceError reports a chunk that arrived out of order, or a sequence number
+// that was already used for different data
+type ImportSequenceError struct {
+	Expected  int
+	Got       int
+	Duplicate bool
+}
+
+func (e *ImportSequenceError) Error() string {
+	if e.Duplicate {
+		return fmt.Sprintf("chunk %d was already applied with different data; expected chunk %d", e.Got, e.Expected)
+	}
+	return fmt.Sprintf("chunk %d is out of order; expected chunk %d", e.Got, e.Expected)
+}
+
+// ImportLineError reports a line of an import that could not be parsed
+type ImportLineError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportLineError) Unwrap() error {
+	return e.Err
+}
+
+// ImportChunkResult acknowledges an applied chunk
+type ImportChunkResult struct {
+	ImportID     string `json:"importId"`
+	Sequence     int    `json:"sequence"`
+	NextSequence int    `json:"nextSequence"`
+	// Lines is the number of complete lines in the chunk; a trailing partial line
+	// is carried over to the next chunk
+	Lines int `json:"lines"`
+	// Duplicates counts lines identical to one already staged, which are ignored
+	Duplicates int `json:"duplicates"`
+	// Resent is true when the chunk repeats the last applied chunk and was ignored
+	Resent bool `json:"resent,omitempty"`
+	// Warnings reports unknown fields and record kinds that were ignored
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ImportSummary describes a committed import
+type ImportSummary struct {
+	ImportID string `json:"importId"`
+	Chunks   int    `json:"chunks"`
+	Lines    int    `json:"lines"`
+	Bytes    int64  `json:"bytes"`
+	MergeReport
+	// Warnings reports unknown fields and record kinds that were ignored
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// BeginImport starts a chunked import and returns its id
+func (db *DB) BeginImport(ctx context.Context) (string, error) {
+	return retryWriteResult(ctx, db, func() (string, error) {
+		return db.beginImport(ctx)
+	})
+}
+
+// beginImport makes one attempt at BeginImport
+func (db *DB) beginImport(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	if _, err := db.conn.ExecContext(ctx,
+		"INSERT INTO imports (id, next_seq) VALUES (?, ?)", id, ImportFirstSequence,
+	); err != nil {
+		return "", err
+	}
+	db.logger.Info("import started", slog.String("import_id", id))
+	return id, nil
+}
+
+// ApplyImportChunk stages the lines of the chunk with sequence number seq. Chunks must
+// arrive in order; resending the last applied chunk is acknowledged without effect so
+// a client can retry after a lost response. Identical lines are staged once. Nothing is
+// staged if any line fails to parse.
+func (db *DB) ApplyImportChunk(ctx context.Context, id string, seq int, data []byte) (*ImportChunkResult, error) {
+	return retryWriteResult(ctx, db, func() (*ImportChunkResult, error) {
+		return db.applyImportChunk(ctx, id, seq, data)
+	})
+}
+
+// applyImportChunk makes one attempt at ApplyImportChunk
+func (db *DB) applyImportChunk(ctx context.Context, id string, seq int, data []byte) (*ImportChunkResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var nextSeq, lines int
+	var lastHash, pending string
+	err = tx.QueryRowContext(ctx,
+		"SELECT next_seq, last_chunk_hash, pending, lines FROM imports WHERE id = ?", id,
+	).Scan(&nextSeq, &lastHash, &pending, &lines)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	switch {
+	case seq == nextSeq-1 && hash == lastHash:
+		return &ImportChunkResult{ImportID: id, Sequence: seq, NextSequence: nextSeq, Resent: true}, nil
+	case seq < nextSeq:
+		return nil, &ImportSequenceError{Expected: nextSeq, Got: seq, Duplicate: true}
+	case seq > nextSeq:
+		return nil, &ImportSequenceError{Expected: nextSeq, Got: seq}
+	}
+
+	// Only complete lines are staged; the remainder waits for the next chunk
+	text := pending + string(data)
+	complete, rest := "", text
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		complete, rest = text[:i+1], text[i+1:]
+	}
+	if len(rest) > MaxImportLineBytes {
+		return nil, &ImportLineError{Line: lines + 1, Err: fmt.Errorf("line exceeds %d bytes", MaxImportLineBytes)}
+	}
+
+	result := &ImportChunkResult{ImportID: id, Sequence: seq, NextSequence: seq + 1}
+	var warnings importWarnings
+	for _, line := range strings.SplitAfter(complete, "\n") {
+		if line == "" {
+			continue
+		}
+		lines++
+		result.Lines++
+		staged, err := stageImportLine(ctx, tx, id, lines, line, &warnings)
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "import chunk", result.Lines, 0)
+		}
+		if !staged {
+			result.Duplicates++
+		}
+	}
+	result.Warnings = warnings.list()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE imports
+		SET next_seq = ?, last_chunk_hash = ?, pending = ?, lines = ?, bytes = bytes + ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		seq+1, hash, rest, lines, len(data), id,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// stageImportLine parses a line and stages it, reporting false for a duplicate line.
+// Blank lines and records of unknown kinds are skipped.
+func stageImportLine(ctx context.Context, tx *sql.Tx, id string, lineNo int, line string, warnings *importWarnings) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true, nil
+	}
+
+	rec, msgs, err := parseGraphLine(line)
+	if err != nil {
+		return false, &ImportLineError{Line: lineNo, Err: err}
+	}
+	warnings.add(lineNo, msgs)
+	if rec == nil {
+		return true, nil
+	}
+
+	encoded, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO import_rows (import_id, kind, payload) VALUES (?, ?, ?)",
+		id, rec.Kind, string(encoded),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CommitImport merges the staged rows into the graph as MergeGraph would and removes
+// the import, in one transaction. A trailing line without a newline is included.
+func (db *DB) CommitImport(ctx context.Context, id string) (*ImportSummary, error) {
+	return retryWriteResult(ctx, db, func() (*ImportSummary, error) {
+		return db.commitImport(ctx, id)
+	})
+}
+
+// commitImport makes one attempt at CommitImport
+func (db *DB) commitImport(ctx context.Context, id string) (*ImportSummary, error) {
+	start := time.Now()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary := &ImportSummary{ImportID: id}
+	var nextSeq int
+	var pending string
+	err = tx.QueryRowContext(ctx,
+		"SELECT next_seq, pending, lines, bytes FROM imports WHERE id = ?", id,
+	).Scan(&nextSeq, &pending, &summary.Lines, &summary.Bytes)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	summary.Chunks = nextSeq - ImportFirstSequence
+
+	if strings.TrimSpace(pending) != "" {
+		summary.Lines++
+		var warnings importWarnings
+		if _, err := stageImportLine(ctx, tx, id, summary.Lines, pending, &warnings); err != nil {
+			return nil, err
+		}
+		summary.Warnings = warnings.list()
+	}
+
+	records, err := stagedRecords(ctx, tx, id)
+	if err != nil {
+		return nil, cancelledOr(ctx, err, "import commit", 0, 0)
+	}
+	report, err := db.mergeRecordsTx(ctx, tx, records)
+	if err != nil {
+		return nil, err
+	}
+	summary.MergeReport = *report
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM imports WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("import committed",
+		slog.String("import_id", id),
+		slog.Int("chunks", summary.Chunks),
+		slog.Int("lines", summary.Lines),
+		slog.Int("entities_created", report.EntitiesCreated),
+		slog.Int("relations_created", report.RelationsCreated),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return summary, nil
+}
+
+// stagedRecords reads an import's staged records in the order they arrived
+func stagedRecords(ctx context.Context, tx *sql.Tx, id string) ([]graphRecord, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT kind, payload FROM import_rows WHERE import_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []graphRecord
+	for rows.Next() {
+		var kind, payload string
+		if err := rows.Scan(&kind, &payload); err != nil {
+			return nil, err
+		}
+		var rec graphRecord
+		if err := json.Unmarshal([]byte(payload), &rec); err != nil {
+			return nil, err
+		}
+		rec.Kind = kind
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// AbortImport discards an import and its staged rows
+func (db *DB) AbortImport(ctx context.Context, id string) error {
+	return db.retryWrite(ctx, func() error {
+		return db.abortImport(ctx, id)
+	})
+}
+
+// abortImport makes one attempt at AbortImport
+func (db *DB) abortImport(ctx context.Context, id string) error {
+	result, err := db.conn.ExecContext(ctx, "DELETE FROM imports WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrImportNotFound
+	}
+	db.logger.Info("import aborted", slog.String("import_id", id))
+	return nil
+}
+
+// ExpireImports discards imports that have not received a chunk for longer than maxAge
+// and returns how many were removed
+func (db *DB) ExpireImports(ctx context.Context, maxAge time.Duration) (int, error) {
+	return retryWriteResult(ctx, db, func() (int, error) {
+		return db.expireImports(ctx, maxAge)
+	})
+}
+
+// expireImports makes one attempt at ExpireImports
+func (db *DB) expireImports(ctx context.Context, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().UTC().Add(-maxAge).Format(sqliteTimeLayout)
+	result, err := db.conn.ExecContext(ctx, "DELETE FROM imports WHERE updated_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		db.logger.Info("expired abandoned imports", slog.Int64("imports", n))
+	}
+	return int(n), nil
+}
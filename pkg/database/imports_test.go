@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const importFixture = `{"type":"entity","name":"Alice","entityType":"person","observations":["engineer","likes go"]}
+{"type":"entity","name":"Bob","entityType":"person","observations":["manager"]}
+
+{"type":"entity","name":"Acme","entityType":"org","observations":["founded 1999"]}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+{"type":"relation","from":"Bob","to":"Acme","relationType":"works_at"}
+{"type":"entity","name":"Bob","entityType":"person","observations":["manager"]}
+{"type":"relation","from":"Alice","to":"Bob","relationType":"reports_to"}`
+
+func newImportTestDB(t *testing.T) *DB {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// importChunks imports data split into chunks of at most size bytes
+func importChunks(t *testing.T, db *DB, data string, size int) *ImportSummary {
+	t.Helper()
+	ctx := context.Background()
+	id, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	seq := ImportFirstSequence
+	for len(data) > 0 {
+		n := min(size, len(data))
+		res, err := db.ApplyImportChunk(ctx, id, seq, []byte(data[:n]))
+		assert.NoError(t, err)
+		assert.Equal(t, seq+1, res.NextSequence)
+		data = data[n:]
+		seq++
+	}
+	summary, err := db.CommitImport(ctx, id)
+	assert.NoError(t, err)
+	return summary
+}
+
+func TestImport_ChunkedEqualsSingleShot(t *testing.T) {
+	single := newImportTestDB(t)
+	chunked := newImportTestDB(t)
+
+	one := importChunks(t, single, importFixture, len(importFixture))
+	many := importChunks(t, chunked, importFixture, 37)
+
+	assert.Equal(t, 1, one.Chunks)
+	assert.Greater(t, many.Chunks, 10)
+	assert.Equal(t, 8, many.Lines)
+	assert.Equal(t, one.MergeReport, many.MergeReport)
+	assert.Equal(t, 3, many.EntitiesCreated)
+	assert.Equal(t, 3, many.RelationsCreated)
+
+	ctx := context.Background()
+	want, err := single.ReadGraph(ctx)
+	assert.NoError(t, err)
+	got, err := chunked.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Nothing stays staged
+	var staged int
+	assert.NoError(t, chunked.conn.QueryRow("SELECT COUNT(*) FROM import_rows").Scan(&staged))
+	assert.Equal(t, 0, staged)
+}
+
+func TestImport_SequenceErrors(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	id, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+
+	lines := strings.SplitAfter(importFixture, "\n")
+
+	_, err = db.ApplyImportChunk(ctx, id, 2, []byte(lines[0]))
+	var seqErr *ImportSequenceError
+	assert.ErrorAs(t, err, &seqErr)
+	assert.Equal(t, &ImportSequenceError{Expected: 1, Got: 2}, seqErr)
+
+	res, err := db.ApplyImportChunk(ctx, id, 1, []byte(lines[0]+lines[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.Lines)
+
+	// Resending the last chunk is harmless; reusing its number for other data is not
+	res, err = db.ApplyImportChunk(ctx, id, 1, []byte(lines[0]+lines[1]))
+	assert.NoError(t, err)
+	assert.True(t, res.Resent)
+	assert.Equal(t, 2, res.NextSequence)
+	_, err = db.ApplyImportChunk(ctx, id, 1, []byte(lines[2]))
+	assert.ErrorAs(t, err, &seqErr)
+	assert.True(t, seqErr.Duplicate)
+
+	// Duplicate lines are staged once
+	res, err = db.ApplyImportChunk(ctx, id, 2, []byte(lines[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Duplicates)
+
+	// A bad line rejects the whole chunk, which can then be corrected and resent
+	_, err = db.ApplyImportChunk(ctx, id, 3, []byte(lines[3]+"{\"type\":\"planet\"}\n"))
+	var lineErr *ImportLineError
+	assert.ErrorAs(t, err, &lineErr)
+	assert.Equal(t, 5, lineErr.Line)
+	_, err = db.ApplyImportChunk(ctx, id, 3, []byte(lines[3]))
+	assert.NoError(t, err)
+
+	_, err = db.ApplyImportChunk(ctx, "unknown", 1, []byte(lines[0]))
+	assert.ErrorIs(t, err, ErrImportNotFound)
+}
+
+func TestImport_AbortAndExpire(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	_, err = db.ApplyImportChunk(ctx, id, 1, []byte(importFixture))
+	assert.NoError(t, err)
+	assert.NoError(t, db.AbortImport(ctx, id))
+	_, err = db.CommitImport(ctx, id)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+	assert.ErrorIs(t, db.AbortImport(ctx, id), ErrImportNotFound)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+
+	stale, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	fresh, err := db.BeginImport(ctx)
+	assert.NoError(t, err)
+	_, err = db.ApplyImportChunk(ctx, stale, 1, []byte(importFixture))
+	assert.NoError(t, err)
+	_, err = db.conn.Exec("UPDATE imports SET updated_at = datetime('now', '-2 days') WHERE id = ?", stale)
+	assert.NoError(t, err)
+
+	n, err := db.ExpireImports(ctx, DefaultImportTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = db.ApplyImportChunk(ctx, stale, 2, []byte("\n"))
+	assert.ErrorIs(t, err, ErrImportNotFound)
+	_, err = db.ApplyImportChunk(ctx, fresh, 1, []byte("\n"))
+	assert.NoError(t, err)
+
+	var staged int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM import_rows").Scan(&staged))
+	assert.Equal(t, 0, staged)
+}
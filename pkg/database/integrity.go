@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// IntegrityReport is the result of CheckIntegrity
+type IntegrityReport struct {
+	// OK is true when no check found a problem
+	OK bool `json:"ok"`
+	// Errors are the problems PRAGMA integrity_check reported
+	Errors []string `json:"errors"`
+	// ForeignKeyViolations are the rows PRAGMA foreign_key_check reported
+	ForeignKeyViolations []ForeignKeyViolation `json:"foreignKeyViolations"`
+	// FTS compares the full-text tables with the tables they index; nil when
+	// full-text search is disabled
+	FTS *FTSConsistency `json:"fts,omitempty"`
+}
+
+// ForeignKeyViolation is a row whose parent row doesn't exist
+type ForeignKeyViolation struct {
+	Table  string `json:"table"`
+	RowID  int64  `json:"rowid"`
+	Parent string `json:"parent"`
+}
+
+// FTSConsistency compares row counts of the indexed tables and their FTS tables
+type FTSConsistency struct {
+	Consistent          bool `json:"consistent"`
+	Entities            int  `json:"entities"`
+	EntitiesIndexed     int  `json:"entitiesIndexed"`
+	Observations        int  `json:"observations"`
+	ObservationsIndexed int  `json:"observationsIndexed"`
+}
+
+// CheckIntegrity runs PRAGMA integrity_check and PRAGMA foreign_key_check and, when
+// full-text search is enabled, compares the row counts of entities and
+// observations with their FTS tables. It reads the whole database, so it takes
+// time on a large one; Ready is the cheap check.
+func (db *DB) CheckIntegrity(ctx context.Context) (*IntegrityReport, error) {
+	report := &IntegrityReport{Errors: []string{}, ForeignKeyViolations: []ForeignKeyViolation{}}
+
+	rows, err := db.reader.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+		if msg != "ok" {
+			report.Errors = append(report.Errors, msg)
+		}
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+
+	rows, err = db.reader.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, fmt.Errorf("foreign key check failed: %w", err)
+	}
+	for rows.Next() {
+		var v ForeignKeyViolation
+		var rowID sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&v.Table, &rowID, &v.Parent, &fkid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("foreign key check failed: %w", err)
+		}
+		v.RowID = rowID.Int64
+		report.ForeignKeyViolations = append(report.ForeignKeyViolations, v)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, fmt.Errorf("foreign key check failed: %w", err)
+	}
+
+	if db.ftsEnabled {
+		fts := &FTSConsistency{}
+		err := db.reader.QueryRowContext(ctx, `
+			SELECT
+				(SELECT COUNT(*) FROM entities),
+				(SELECT COUNT(*) FROM entities_fts),
+				(SELECT COUNT(*) FROM observations),
+				(SELECT COUNT(*) FROM observations_fts)`,
+		).Scan(&fts.Entities, &fts.EntitiesIndexed, &fts.Observations, &fts.ObservationsIndexed)
+		if err != nil {
+			return nil, fmt.Errorf("full-text consistency check failed: %w", err)
+		}
+		fts.Consistent = fts.Entities == fts.EntitiesIndexed && fts.Observations == fts.ObservationsIndexed
+		report.FTS = fts
+	}
+
+	report.OK = len(report.Errors) == 0 && len(report.ForeignKeyViolations) == 0 &&
+		(report.FTS == nil || report.FTS.Consistent)
+	return report, nil
+}
+
+// Ready reports whether the database answers queries, by reading at most one row
+func (db *DB) Ready(ctx context.Context) error {
+	var id int64
+	err := db.reader.QueryRowContext(ctx, "SELECT id FROM entities LIMIT 1").Scan(&id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
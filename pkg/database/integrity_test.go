@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	assert.NoError(t, db.Ready(ctx), "an empty database is ready")
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}}})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Ready(ctx))
+
+	report, err := db.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.OK)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.ForeignKeyViolations)
+	if db.IsFTSEnabled() {
+		assert.Equal(t, &FTSConsistency{Consistent: true, Entities: 1, EntitiesIndexed: 1, Observations: 1, ObservationsIndexed: 1}, report.FTS)
+	} else {
+		assert.Nil(t, report.FTS)
+	}
+
+	// An observation of a missing entity, written with enforcement off
+	_, err = db.conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "INSERT INTO observations (entity_id, content) VALUES (999, 'orphan')")
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	assert.NoError(t, err)
+
+	report, err = db.CheckIntegrity(ctx)
+	assert.NoError(t, err)
+	assert.False(t, report.OK)
+	if assert.Len(t, report.ForeignKeyViolations, 1) {
+		assert.Equal(t, "observations", report.ForeignKeyViolations[0].Table)
+		assert.Equal(t, "entities", report.ForeignKeyViolations[0].Parent)
+	}
+
+	if db.IsFTSEnabled() {
+		_, err = db.conn.ExecContext(ctx, "DELETE FROM entities_fts")
+		assert.NoError(t, err)
+		report, err = db.CheckIntegrity(ctx)
+		assert.NoError(t, err)
+		assert.False(t, report.FTS.Consistent)
+		assert.Zero(t, report.FTS.EntitiesIndexed)
+	}
+}
@@ -0,0 +1,22 @@
+package database
+
+// maxInternedStrings bounds an interner, so a graph with unusually many distinct
+// types costs no more than reading without one
+const maxInternedStrings = 4096
+
+// interner returns one canonical string per distinct value. Large reads pass entity
+// and relation types through it: a graph has few distinct types, and the driver
+// returns a fresh copy of each for every row, so without it a read of n entities
+// keeps n copies of the same few strings alive for as long as the result.
+type interner map[string]string
+
+// intern returns the first string seen equal to s, or s if it is new
+func (in interner) intern(s string) string {
+	if canonical, ok := in[s]; ok {
+		return canonical
+	}
+	if len(in) < maxInternedStrings {
+		in[s] = s
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GetMeta returns the value stored under key in the meta table and whether it exists
+func (db *DB) GetMeta(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := db.conn.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// SetMeta stores value under key in the meta table, replacing any previous value
+func (db *DB) SetMeta(ctx context.Context, key, value string) error {
+	return db.retryWrite(ctx, func() error {
+		return db.setMeta(ctx, key, value)
+	})
+}
+
+// setMeta makes one attempt at SetMeta
+func (db *DB) setMeta(ctx context.Context, key, value string) error {
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO meta (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value,
+	)
+	return err
+}
+
+// Optimize lets SQLite refresh query planner statistics where they are stale
+func (db *DB) Optimize(ctx context.Context) error {
+	_, err := db.conn.ExecContext(ctx, "PRAGMA optimize")
+	return err
+}
+
+// Checkpoint copies the write-ahead log into the database file and truncates it
+func (db *DB) Checkpoint(ctx context.Context) error {
+	_, err := db.conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ImportMemoryJSON merges a memory.json file of the reference TypeScript server,
+// @modelcontextprotocol/server-memory, into the database in one transaction. Each
+// line is an entity, {"type":"entity","name":...,"entityType":...,"observations":[...]},
+// or a relation, {"type":"relation","from":...,"to":...,"relationType":...}; lines
+// of other types are skipped and reported in the summary's warnings. Entities,
+// observations and relations merge as in MergeGraph, so importing the same file
+// again adds nothing.
+func (db *DB) ImportMemoryJSON(ctx context.Context, r io.Reader) (*ImportSummary, error) {
+	return db.importLines(ctx, r, "memory.json", parseMemoryJSONLine)
+}
+
+// parseMemoryJSONLine decodes one line of a memory.json file, skipping records of a
+// type the reference server's format doesn't define
+func parseMemoryJSONLine(line string) (*graphRecord, []string, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(line), &head); err != nil {
+		return nil, nil, err
+	}
+	switch head.Type {
+	case RecordEntity, RecordRelation:
+		return parseGraphLine(line)
+	}
+	return nil, []string{fmt.Sprintf("unknown record type %q skipped", head.Type)}, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryJSON is a memory.json file as the reference server writes it, plus a record
+// of a type it doesn't define
+const memoryJSON = `{"type":"entity","name":"Alice","entityType":"person","observations":["likes Go","lives in Lisbon"]}
+{"type":"entity","name":"Acme","entityType":"organization","observations":[]}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+{"type":"relation","from":"Acme","to":"Bob","relationType":"employs"}
+{"type":"entity","name":"Bob","entityType":"person","observations":["likes Go"]}
+{"type":"session","id":"abc"}
+`
+
+func TestImportMemoryJSON(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	summary, err := db.ImportMemoryJSON(ctx, strings.NewReader(memoryJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, summary.Lines)
+	assert.Equal(t, 3, summary.EntitiesCreated)
+	assert.Equal(t, 3, summary.ObservationsAdded)
+	assert.Equal(t, 2, summary.RelationsCreated, "a relation may come before its target")
+	assert.Equal(t, []string{`line 6: unknown record type "session" skipped`}, summary.Warnings)
+
+	graph, err := db.OpenNodes(ctx, []string{"Alice"})
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "person", graph.Entities[0].EntityType)
+		assert.Equal(t, []string{"likes Go", "lives in Lisbon"}, graph.Entities[0].Observations)
+	}
+
+	// Re-running adds nothing
+	before, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	summary, err = db.ImportMemoryJSON(ctx, strings.NewReader(memoryJSON))
+	assert.NoError(t, err)
+	assert.Zero(t, summary.EntitiesCreated)
+	assert.Zero(t, summary.ObservationsAdded)
+	assert.Zero(t, summary.RelationsCreated)
+	assert.Equal(t, 3, summary.EntitiesMerged)
+	after, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestImportMemoryJSON_Invalid(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	for _, input := range []string{
+		"{\"type\":\"entity\",\"name\":\"Alice\",\"entityType\":\"person\"}\nnot json\n",
+		`{"type":"entity","name":"Alice"}`,
+		`{"type":"relation","from":"Alice","to":"Bob"}`,
+	} {
+		_, err := db.ImportMemoryJSON(ctx, strings.NewReader(input))
+		var lineErr *ImportLineError
+		assert.ErrorAs(t, err, &lineErr, input)
+	}
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities, "a file with an invalid line imports nothing")
+}
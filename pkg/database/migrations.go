@@ -0,0 +1,541 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// schemaMigration is one versioned change to the schema
+type schemaMigration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx) error
+	// rebuildsTables is set on migrations that recreate a table other tables
+	// reference, which must run with foreign keys off so dropping the old table
+	// doesn't cascade to them
+	rebuildsTables bool
+}
+
+// schemaMigrations take a database from empty to the current schema, in order. Add
+// changes as new migrations at the end; applied ones must stay as they are.
+var schemaMigrations = []schemaMigration{
+	{1, "initial schema", migrateInitialSchema, false},
+	{2, "entity update times follow relations", migrateRelationTouch, false},
+	{3, "named graphs", migrateGraphs, true},
+	{4, "entity tags", migrateEntityTags, false},
+	{5, "entity attributes", migrateEntityAttributes, false},
+	{6, "relation properties", migrateRelationProperties, false},
+	{7, "soft delete", migrateSoftDelete, false},
+	{8, "audit log", migrateAuditLog, false},
+	{9, "graph snapshots", migrateGraphSnapshots, false},
+	{10, "pinned entities", migratePinnedEntities, false},
+	{11, "observation expiry", migrateObservationExpiry, false},
+	{12, "entity aliases", migrateEntityAliases, false},
+	{13, "archived observation graphs", migrateArchivedObservationGraphs, false},
+}
+
+// schemaVersion returns the latest migration applied to the database, 0 for none
+func schemaVersion(ctx context.Context, q relationQuerier) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// applyMigrations applies the migrations the database lacks, each in a transaction
+// with the schema_migrations row recording it, so a failed migration leaves the
+// database at the version before it
+func (db *DB) applyMigrations(ctx context.Context) error {
+	if _, err := db.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`); err != nil {
+		return err
+	}
+	version, err := schemaVersion(ctx, db.conn)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		var legacy bool
+		if err := db.conn.QueryRowContext(ctx,
+			"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'entities'",
+		).Scan(&legacy); err != nil {
+			return err
+		}
+		if legacy {
+			db.logger.Info("database predates schema versioning, stamping it at version 1")
+		}
+	}
+
+	for _, migration := range schemaMigrations {
+		if migration.version <= version {
+			continue
+		}
+		// Another process opening the database may apply the migration first, in
+		// which case the retry finds it applied
+		if err := db.retryWrite(ctx, func() error { return db.applyMigration(ctx, migration) }); err != nil {
+			return fmt.Errorf("schema migration %d (%s): %w", migration.version, migration.description, err)
+		}
+	}
+
+	if version, err = schemaVersion(ctx, db.conn); err != nil {
+		return err
+	}
+	if latest := schemaMigrations[len(schemaMigrations)-1].version; version > latest {
+		db.logger.Warn("database schema is newer than this server",
+			slog.Int("version", version),
+			slog.Int("supported_version", latest),
+		)
+	}
+	db.logger.Info("database schema ready", slog.Int("version", version))
+	return nil
+}
+
+// applyMigration applies migration unless the database already has it
+func (db *DB) applyMigration(ctx context.Context, migration schemaMigration) error {
+	// The pragmas only apply to the connection they run on, and foreign_keys can't
+	// change inside a transaction
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if migration.rebuildsTables {
+		// legacy_alter_table keeps renaming the rebuilt table from checking triggers
+		// that refer to it while it is gone
+		for _, pragma := range []string{"foreign_keys = OFF", "legacy_alter_table = ON"} {
+			if _, err := conn.ExecContext(ctx, "PRAGMA "+pragma); err != nil {
+				return err
+			}
+		}
+		defer conn.ExecContext(context.Background(), "PRAGMA legacy_alter_table = OFF")
+		defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM schema_migrations WHERE version = ?", migration.version,
+	).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+	if err := migration.apply(tx); err != nil {
+		return err
+	}
+	if migration.rebuildsTables {
+		rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+		if err != nil {
+			return err
+		}
+		broken := rows.Next()
+		rows.Close()
+		if broken {
+			return fmt.Errorf("foreign key check failed")
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, description) VALUES (?, ?)", migration.version, migration.description,
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	db.logger.Info("applied schema migration",
+		slog.Int("version", migration.version),
+		slog.String("description", migration.description),
+	)
+	return nil
+}
+
+// migrateInitialSchema creates the schema as it stood when migrations started to be
+// versioned. Every statement only creates what is missing, so it also completes
+// databases created by any earlier version, which are stamped at version 1 by it.
+func migrateInitialSchema(tx *sql.Tx) error {
+	// Core table creation and indexes
+	coreStatements := []string{
+		`CREATE TABLE IF NOT EXISTS entities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			entity_type TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS observations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			entity_id INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			written_by TEXT,
+			FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+			UNIQUE(entity_id, content)
+		);`,
+		`CREATE TABLE IF NOT EXISTS relations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			from_entity_id INTEGER NOT NULL,
+			to_entity_id INTEGER NOT NULL,
+			relation_type TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (from_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+			FOREIGN KEY (to_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+			UNIQUE(from_entity_id, to_entity_id, relation_type)
+		);`,
+		`CREATE TABLE IF NOT EXISTS meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		// Chunked imports in progress; rows are staged until the import is committed
+		`CREATE TABLE IF NOT EXISTS imports (
+			id TEXT PRIMARY KEY,
+			next_seq INTEGER NOT NULL,
+			last_chunk_hash TEXT NOT NULL DEFAULT '',
+			pending TEXT NOT NULL DEFAULT '',
+			lines INTEGER NOT NULL DEFAULT 0,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		// Per entity type metadata, such as presentation hints for exporters
+		`CREATE TABLE IF NOT EXISTS entity_type_meta (
+			entity_type TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity_type, key)
+		);`,
+		`CREATE TABLE IF NOT EXISTS import_rows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			import_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			FOREIGN KEY (import_id) REFERENCES imports(id) ON DELETE CASCADE,
+			UNIQUE(import_id, kind, payload)
+		);`,
+		// Observations moved out by the archive action of a retention policy, see
+		// retention.go; they keep the names of their entity in case it goes later
+		`CREATE TABLE IF NOT EXISTS archived_observations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			entity_name TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP,
+			written_by TEXT,
+			session TEXT,
+			archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_entities_name ON entities(name);`,
+		`CREATE INDEX IF NOT EXISTS idx_entities_type ON entities(entity_type);`,
+		`CREATE INDEX IF NOT EXISTS idx_observations_entity ON observations(entity_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_observations_content ON observations(content);`, // For text search
+		// For paging large observation sets
+		`CREATE INDEX IF NOT EXISTS idx_observations_entity_created ON observations(entity_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_from ON relations(from_entity_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_to ON relations(to_entity_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_type ON relations(relation_type);`, // For filtering by relation type
+		// For paging the relations of one entity in each direction, see edges.go
+		`CREATE INDEX IF NOT EXISTS idx_relations_from_type ON relations(from_entity_id, relation_type);`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_to_type ON relations(to_entity_id, relation_type);`,
+	}
+
+	// Execute core statements
+	for _, stmt := range coreStatements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// Observation provenance, added after the first release
+	if err := addColumnIfMissing(tx, "observations", "written_by", "TEXT"); err != nil {
+		return err
+	}
+
+	// Session labels, see sessions.go; most rows have none, so only labeled rows are indexed
+	for _, table := range []string{"entities", "observations", "relations"} {
+		if err := addColumnIfMissing(tx, table, "session", "TEXT"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_%s_session ON %s(session) WHERE session IS NOT NULL;", table, table,
+		)); err != nil {
+			return err
+		}
+	}
+
+	// Relation generation, bumped on every change to relations, including cascading
+	// deletes, so caches of them (see adjacency.go) can tell when they are stale
+	for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+		if _, err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS relations_generation_%s AFTER %s ON relations BEGIN
+			INSERT INTO meta (key, value) VALUES ('%s', '1')
+			ON CONFLICT(key) DO UPDATE SET value = CAST(value AS INTEGER) + 1;
+		END;`, strings.ToLower(event), event, relationsGenerationKey)); err != nil {
+			return err
+		}
+	}
+
+	// An entity's updated_at follows changes to its observations
+	for event, row := range map[string]string{"INSERT": "new", "DELETE": "old"} {
+		if _, err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS observations_touch_%s AFTER %s ON observations BEGIN
+			UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE id = %s.entity_id;
+		END;`, strings.ToLower(event), event, row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateRelationTouch moves an entity's updated_at when a relation from or to it is
+// created or deleted, as it moves for its observations, and indexes updated_at for
+// recent_entities
+func migrateRelationTouch(tx *sql.Tx) error {
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_entities_updated ON entities(updated_at);"); err != nil {
+		return err
+	}
+	for event, row := range map[string]string{"INSERT": "new", "DELETE": "old"} {
+		if _, err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS relations_touch_%s AFTER %s ON relations BEGIN
+			UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE id IN (%s.from_entity_id, %s.to_entity_id);
+		END;`, strings.ToLower(event), event, row, row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateGraphs adds named graphs: a graphs table, holding the default graph every
+// existing entity is moved into, and a graph_id on entities, whose names become unique
+// per graph. SQLite can't change a table's constraints, so entities is rebuilt, keeping
+// its ids and its AUTOINCREMENT sequence.
+func migrateGraphs(tx *sql.Tx) error {
+	var seq sql.NullInt64
+	if err := tx.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'entities'").Scan(&seq); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS graphs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		fmt.Sprintf("INSERT OR IGNORE INTO graphs (id, name) VALUES (%d, '%s');", defaultGraphID, DefaultGraph),
+		fmt.Sprintf(`CREATE TABLE entities_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			graph_id INTEGER NOT NULL DEFAULT %d REFERENCES graphs(id),
+			name TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			session TEXT,
+			UNIQUE(graph_id, name)
+		);`, defaultGraphID),
+		`INSERT INTO entities_new (id, name, entity_type, created_at, updated_at, session)
+			SELECT id, name, entity_type, created_at, updated_at, session FROM entities;`,
+		"DROP TABLE entities;",
+		"ALTER TABLE entities_new RENAME TO entities;",
+		// Dropped with the old table; the full-text triggers are recreated on open
+		`CREATE INDEX IF NOT EXISTS idx_entities_name ON entities(name);`,
+		`CREATE INDEX IF NOT EXISTS idx_entities_type ON entities(entity_type);`,
+		`CREATE INDEX IF NOT EXISTS idx_entities_updated ON entities(updated_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_entities_session ON entities(session) WHERE session IS NOT NULL;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if seq.Valid {
+		if _, err := tx.Exec("UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = 'entities'", seq.Int64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateEntityTags adds free-form tags on entities, deleted with them
+func migrateEntityTags(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS entity_tags (
+			entity_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity_id, tag),
+			FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE
+		) WITHOUT ROWID;`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_tags_tag ON entity_tags(tag);`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateEntityAttributes adds the metadata column holding the attributes of an
+// entity as a JSON object
+func migrateEntityAttributes(tx *sql.Tx) error {
+	_, err := tx.Exec("ALTER TABLE entities ADD COLUMN metadata TEXT;")
+	return err
+}
+
+// migrateRelationProperties adds the optional confidence and note of a relation
+func migrateRelationProperties(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE relations ADD COLUMN confidence REAL;",
+		"ALTER TABLE relations ADD COLUMN note TEXT;",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSoftDelete adds when an entity was moved to the trash, see trash.go, and the
+// table holding the relations deleted with it, which go when either end is purged
+func migrateSoftDelete(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE entities ADD COLUMN deleted_at TIMESTAMP;",
+		`CREATE TABLE IF NOT EXISTS trash_relations (
+			id INTEGER PRIMARY KEY,
+			from_entity_id INTEGER NOT NULL,
+			to_entity_id INTEGER NOT NULL,
+			relation_type TEXT NOT NULL,
+			created_at TIMESTAMP,
+			session TEXT,
+			confidence REAL,
+			note TEXT,
+			FOREIGN KEY (from_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+			FOREIGN KEY (to_entity_id) REFERENCES entities(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_trash_relations_from ON trash_relations(from_entity_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_trash_relations_to ON trash_relations(to_entity_id);`,
+		// Most entities aren't in the trash, so only those are indexed
+		`CREATE INDEX IF NOT EXISTS idx_entities_deleted ON entities(deleted_at) WHERE deleted_at IS NOT NULL;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateAuditLog adds the audit log of changes, see audit.go, with the names of the
+// entities each affected. Entries are only ever added, or pruned by age.
+func migrateAuditLog(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			graph_id INTEGER NOT NULL,
+			operation TEXT NOT NULL,
+			tool TEXT,
+			request_id TEXT,
+			written_by TEXT,
+			session TEXT,
+			summary TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS audit_log_entities (
+			entity_name TEXT NOT NULL,
+			audit_id INTEGER NOT NULL,
+			PRIMARY KEY (entity_name, audit_id),
+			FOREIGN KEY (audit_id) REFERENCES audit_log(id) ON DELETE CASCADE
+		) WITHOUT ROWID;`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_entities_audit ON audit_log_entities(audit_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_append_only BEFORE UPDATE ON audit_log BEGIN
+			SELECT RAISE(ABORT, 'the audit log is append-only');
+		END;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateGraphSnapshots adds the labeled snapshots of graphs, see graphsnapshot.go,
+// each a compressed JSON export
+func migrateGraphSnapshots(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		graph_id INTEGER NOT NULL REFERENCES graphs(id),
+		label TEXT NOT NULL,
+		data BLOB NOT NULL,
+		entities INTEGER NOT NULL,
+		observations INTEGER NOT NULL,
+		relations INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (graph_id, label)
+	);`)
+	return err
+}
+
+// migratePinnedEntities adds whether an entity is pinned, see pins.go, protecting it
+// from deletion
+func migratePinnedEntities(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE entities ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;",
+		// Few entities are pinned, so only those are indexed
+		`CREATE INDEX IF NOT EXISTS idx_entities_pinned ON entities(graph_id, name) WHERE pinned = 1;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateObservationExpiry adds when an observation expires, see expiry.go; NULL
+// for those that don't
+func migrateObservationExpiry(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE observations ADD COLUMN expires_at TIMESTAMP;",
+		// Few observations expire, so only those are indexed
+		`CREATE INDEX IF NOT EXISTS idx_observations_expires_at ON observations(expires_at) WHERE expires_at IS NOT NULL;`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateEntityAliases adds the other names entities are known by, see aliases.go.
+// An alias names one entity of a graph and goes with it when the entity is deleted.
+func migrateEntityAliases(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS entity_aliases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			graph_id INTEGER NOT NULL REFERENCES graphs(id),
+			alias TEXT NOT NULL,
+			entity_id INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (graph_id, alias)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_aliases_entity ON entity_aliases(entity_id);`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateArchivedObservationGraphs adds the graph of the entity an archived
+// observation came from, so clearing a graph leaves the archives of the others.
+// Observations archived before it are counted in the default graph.
+func migrateArchivedObservationGraphs(tx *sql.Tx) error {
+	_, err := tx.Exec(fmt.Sprintf("ALTER TABLE archived_observations ADD COLUMN graph_id INTEGER NOT NULL DEFAULT %d;", defaultGraphID))
+	return err
+}
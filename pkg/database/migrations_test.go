@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// appliedMigrations returns the versions recorded in schema_migrations, in order
+func appliedMigrations(t *testing.T, db *DB) []int {
+	t.Helper()
+	rows, err := db.conn.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer rows.Close()
+	versions := []int{}
+	for rows.Next() {
+		var version int
+		assert.NoError(t, rows.Scan(&version))
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// allMigrations returns the versions of schemaMigrations
+func allMigrations() []int {
+	versions := make([]int, len(schemaMigrations))
+	for i, migration := range schemaMigrations {
+		versions[i] = migration.version
+	}
+	return versions
+}
+
+// schemaObjectExists reports whether the database has a table, index or trigger named name
+func schemaObjectExists(t *testing.T, db *DB, name string) bool {
+	t.Helper()
+	var exists bool
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) > 0 FROM sqlite_master WHERE name = ?", name).Scan(&exists))
+	return exists
+}
+
+func TestMigrations_Ordered(t *testing.T) {
+	for i, migration := range schemaMigrations {
+		assert.Equal(t, i+1, migration.version, migration.description)
+	}
+}
+
+func TestMigrate_NewDatabase(t *testing.T) {
+	db := newImportTestDB(t)
+	assert.Equal(t, allMigrations(), appliedMigrations(t, db))
+	version, err := schemaVersion(context.Background(), db.conn)
+	assert.NoError(t, err)
+	assert.Equal(t, len(schemaMigrations), version)
+	assert.True(t, schemaObjectExists(t, db, "idx_entities_updated"))
+	assert.True(t, schemaObjectExists(t, db, "relations_touch_insert"))
+}
+
+func TestMigrate_UnversionedDatabases(t *testing.T) {
+	for name, create := range map[string]func(tx *sql.Tx) error{
+		// The schema of the last release before migrations were versioned
+		"version 1": migrateInitialSchema,
+		// The first release, before provenance and session columns
+		"first release": func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				`CREATE TABLE entities (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT UNIQUE NOT NULL,
+					entity_type TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				)`,
+				`CREATE TABLE observations (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					entity_id INTEGER NOT NULL,
+					content TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+					UNIQUE(entity_id, content)
+				)`,
+				`CREATE TABLE relations (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					from_entity_id INTEGER NOT NULL,
+					to_entity_id INTEGER NOT NULL,
+					relation_type TEXT NOT NULL,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (from_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+					FOREIGN KEY (to_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
+					UNIQUE(from_entity_id, to_entity_id, relation_type)
+				)`,
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "memory.db")
+			conn, err := sql.Open(SQL_DRIVER, path)
+			assert.NoError(t, err)
+			tx, err := conn.Begin()
+			assert.NoError(t, err)
+			assert.NoError(t, create(tx))
+			for _, stmt := range []string{
+				"INSERT INTO entities (name, entity_type, updated_at) VALUES ('Alice', 'person', '2019-01-01 00:00:00'), ('Acme', 'company', '2019-01-01 00:00:00')",
+				"INSERT INTO observations (entity_id, content) VALUES (1, 'likes hiking')",
+				"INSERT INTO relations (from_entity_id, to_entity_id, relation_type) VALUES (1, 2, 'works_at')",
+			} {
+				_, err := tx.Exec(stmt)
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, tx.Commit())
+			assert.NoError(t, conn.Close())
+
+			logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+			db, err := NewDBWithLogger(path, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer db.Close()
+			assert.Equal(t, allMigrations(), appliedMigrations(t, db))
+
+			// The data is intact and the migrated schema works with it
+			ctx := context.Background()
+			graph, err := db.OpenNodes(ctx, []string{"Alice", "Acme"})
+			assert.NoError(t, err)
+			assert.Len(t, graph.Entities, 2)
+			assert.Equal(t, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}}, graph.Relations)
+			graphs, err := db.ListGraphs(ctx)
+			assert.NoError(t, err)
+			if assert.Len(t, graphs, 1, "existing entities are moved into the default graph") {
+				assert.Equal(t, DefaultGraph, graphs[0].Name)
+				assert.Equal(t, 2, graphs[0].Entities)
+			}
+			_, err = db.AddObservations(WithSession(ctx, "migrated"), []ObservationAdditionInput{
+				{EntityName: "Acme", Contents: []string{"founded 1999"}},
+			})
+			assert.NoError(t, err)
+			_, err = db.DeleteRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+			assert.NoError(t, err)
+			recent, err := db.RecentEntities(ctx, 2)
+			assert.NoError(t, err)
+			for _, entity := range recent {
+				assert.NotEqual(t, "2019-01-01T00:00:00Z", entity.UpdatedAt, entity.Name)
+			}
+
+			// Opening it again applies nothing
+			assert.NoError(t, db.Close())
+			db, err = NewDBWithLogger(path, logger)
+			assert.NoError(t, err)
+			assert.Equal(t, allMigrations(), appliedMigrations(t, db))
+		})
+	}
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	failing := errors.New("migration failed")
+	saved := schemaMigrations
+	t.Cleanup(func() { schemaMigrations = saved })
+	schemaMigrations = append(saved[:len(saved):len(saved)], schemaMigration{
+		version:     len(saved) + 1,
+		description: "half done",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER PRIMARY KEY)"); err != nil {
+				return err
+			}
+			return failing
+		},
+	})
+	_, err = NewDBWithLogger(path, logger)
+	assert.ErrorIs(t, err, failing)
+
+	schemaMigrations = saved
+	db, err = NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, allMigrations(), appliedMigrations(t, db))
+	assert.False(t, schemaObjectExists(t, db, "half_done"), "the failed migration was rolled back")
+}
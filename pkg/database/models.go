@@ -31,12 +31,78 @@ type EntityWithObservations struct {
 	Name         string   `json:"name"`
 	EntityType   string   `json:"entityType"`
 	Observations []string `json:"observations"`
+	// Tags are free-form labels, such as "important" or "source:slack", in tag
+	// order; create_entities stores them on the entities it creates or appends to
+	Tags []string `json:"tags,omitempty"`
+	// Attributes holds structured values, such as URLs, scores or external IDs, as
+	// a JSON object; create_entities and UpdateEntities merge them into the
+	// entity's
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// Pinned is set by read paths for entities protected from deletion, see
+	// PinEntities
+	Pinned bool `json:"pinned,omitempty"`
+	// Aliases is set by AddAliases to the other names the entity is known by, in
+	// name order
+	Aliases []string `json:"aliases,omitempty"`
+	// TotalObservations is set by read paths; it exceeds len(Observations) when the
+	// observations were capped and the rest must be fetched with GetObservations
+	TotalObservations int `json:"totalObservations,omitempty"`
+	// CreatedAt, UpdatedAt and ObservationsCreatedAt are set by AddTimestamps, in
+	// RFC 3339 UTC; ObservationsCreatedAt[i] is when Observations[i] was stored
+	CreatedAt             string   `json:"createdAt,omitempty"`
+	UpdatedAt             string   `json:"updatedAt,omitempty"`
+	ObservationsCreatedAt []string `json:"observationsCreatedAt,omitempty"`
+	// Score is set by SearchNodesRanked: higher is more relevant
+	Score float64 `json:"score,omitempty"`
+	// Matches is set by AddSnippets and AddMatches to the observations, or
+	// fragments of them, that matched a search
+	Matches []string `json:"matches,omitempty"`
+}
+
+// onDuplicate modes for CreateEntitiesWithMode
+const (
+	DuplicateSkip               = "skip"
+	DuplicateAppendObservations = "appendObservations"
+	DuplicateError              = "error"
+	// DuplicateMergeObservations is onConflict's name for DuplicateAppendObservations
+	DuplicateMergeObservations = "mergeObservations"
+)
+
+// Per-entity outcomes reported by CreateEntitiesWithMode
+const (
+	OutcomeCreated              = "created"
+	OutcomeObservationsAppended = "observationsAppended"
+	OutcomeSkipped              = "skipped"
+)
+
+// EntityCreateResult is the outcome of creating one entity. Observations lists the
+// observations stored by this call: all of them for a new entity, only the new ones
+// when appending to an existing entity, and none when skipped.
+type EntityCreateResult struct {
+	Name         string   `json:"name"`
+	EntityType   string   `json:"entityType"`
+	Outcome      string   `json:"outcome"`
+	Observations []string `json:"observations"`
+	// SkippedObservations lists, when appending, the observations given that the
+	// entity already had
+	SkippedObservations []string `json:"skippedObservations,omitempty"`
 }
 
 type RelationDTO struct {
 	From         string `json:"from"`
 	To           string `json:"to"`
 	RelationType string `json:"relationType"`
+	// Confidence, from 0 to 1, and Note are optional properties of the relation,
+	// such as how sure its writer was and where it was learned; 0 and "" are none
+	Confidence float64 `json:"confidence,omitempty"`
+	Note       string  `json:"note,omitempty"`
+	// CreatedAt is set by AddTimestamps, in RFC 3339 UTC
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// key returns the relation without its properties, which identifies it
+func (r RelationDTO) key() RelationDTO {
+	return RelationDTO{From: r.From, To: r.To, RelationType: r.RelationType}
 }
 
 type KnowledgeGraph struct {
@@ -48,11 +114,50 @@ type KnowledgeGraph struct {
 type ObservationAdditionInput struct {
     EntityName string   `json:"entityName"`
     Contents   []string `json:"contents"`
+    // ExpiresAt, when set, is when the contents added expire: they are hidden from
+    // reads then, and deleted by PurgeExpiredObservations
+    ExpiresAt time.Time `json:"-"`
 }
 
 type ObservationAdditionResult struct {
     EntityName        string   `json:"entityName"`
     AddedObservations []string `json:"addedObservations"`
+    // SkippedObservations lists the contents the entity already had before the
+    // call; a content repeated within the call is only added
+    SkippedObservations []string `json:"skippedObservations"`
+    // SkippedAsSimilar is set by AddObservationsIfAbsentSimilar
+    SkippedAsSimilar []SimilarObservation `json:"skippedAsSimilar,omitempty"`
+    // EvictedObservations lists the oldest observations deleted to keep the entity
+    // within the observation cap
+    EvictedObservations []string `json:"evictedObservations,omitempty"`
+    // ExpiresAt is when the observations added expire (RFC 3339, UTC), empty when
+    // they don't
+    ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// ObservationPage is one page of an entity's observations
+type ObservationPage struct {
+    EntityName        string   `json:"entityName"`
+    Observations      []string `json:"observations"`
+    TotalObservations int      `json:"totalObservations"`
+    Offset            int      `json:"offset"`
+    NextOffset        *int     `json:"nextOffset,omitempty"`
+}
+
+// GraphPage is one page of the graph's entities, by name, with the relations among them
+type GraphPage struct {
+    KnowledgeGraph
+    // NextCursor is the afterName of the next page, empty on the last one
+    NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// SearchResult is one page of the entities matching a search, with the relations among them
+type SearchResult struct {
+    KnowledgeGraph
+    // TotalMatches counts every matching entity, not only those on the page
+    TotalMatches int  `json:"totalMatches"`
+    Offset       int  `json:"offset"`
+    NextOffset   *int `json:"nextOffset,omitempty"`
 }
 
 type ObservationDeletionInput struct {
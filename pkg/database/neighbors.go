@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Directions GetNeighbors follows relations in, seen from each entity it expands
+const (
+	NeighborsOut  = "out"
+	NeighborsIn   = "in"
+	NeighborsBoth = "both"
+)
+
+// Neighborhood is the subgraph around some entities
+type Neighborhood struct {
+	KnowledgeGraph
+	// NotFound lists the start entities that don't exist
+	NotFound []string `json:"notFound,omitempty"`
+	// Truncated is set when more entities were reachable within the depth than
+	// the node cap allowed
+	Truncated bool `json:"truncated"`
+}
+
+// GetNeighbors returns the entities reachable from the named ones in at most depth
+// relations, following relations in direction, with every relation among them as
+// OpenNodes returns it. It expands breadth-first, so nearer entities come in before
+// farther ones, and stops at maxNodes entities, start entities included, setting
+// Truncated. Each entity is expanded once, so cycles end the walk rather than
+// repeat it.
+//
+// Like FindPath, each level is expanded from the adjacency cache when it is enabled
+// and current, otherwise with a query per level.
+func (db *DB) GetNeighbors(ctx context.Context, names []string, depth int, direction string, maxNodes int) (*Neighborhood, error) {
+	if direction != NeighborsOut && direction != NeighborsIn && direction != NeighborsBoth {
+		return nil, fmt.Errorf("invalid direction %q: must be %q, %q or %q", direction, NeighborsOut, NeighborsIn, NeighborsBoth)
+	}
+	result := &Neighborhood{KnowledgeGraph: KnowledgeGraph{
+		Entities:  []EntityWithObservations{},
+		Relations: []RelationDTO{},
+	}}
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	ids, err := db.entityIDs(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	reached := map[int64]bool{}
+	var order []int64
+	for _, name := range names {
+		id, ok := ids[name]
+		if !ok {
+			result.NotFound = append(result.NotFound, name)
+			continue
+		}
+		if reached[id] {
+			continue
+		}
+		if len(order) >= maxNodes {
+			result.Truncated = true
+			continue
+		}
+		reached[id] = true
+		order = append(order, id)
+	}
+	frontier := order
+
+	cache, err := db.cachedAdjacency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expand := db.expandSQL
+	if cache != nil {
+		expand = func(context.Context, []int64) (map[int64][]neighborEdge, error) {
+			return cache.edges, nil
+		}
+	}
+
+expansion:
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		edges, err := expand(ctx, frontier)
+		if err != nil {
+			return nil, err
+		}
+		var next []int64
+		for _, id := range frontier {
+			for _, edge := range edges[id] {
+				if (direction == NeighborsOut && !edge.outgoing) || (direction == NeighborsIn && edge.outgoing) {
+					continue
+				}
+				if reached[edge.neighbor] {
+					continue
+				}
+				if len(order) >= maxNodes {
+					result.Truncated = true
+					break expansion
+				}
+				reached[edge.neighbor] = true
+				order = append(order, edge.neighbor)
+				next = append(next, edge.neighbor)
+			}
+		}
+		frontier = next
+	}
+	if len(order) == 0 {
+		return result, nil
+	}
+
+	byID, err := db.entityNames(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	reachedNames := make([]string, 0, len(order))
+	for _, id := range order {
+		reachedNames = append(reachedNames, byID[id])
+	}
+	graph, err := db.OpenNodes(ctx, reachedNames)
+	if err != nil {
+		return nil, err
+	}
+	result.KnowledgeGraph = *graph
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// neighborNames returns the names of the entities in a neighborhood
+func neighborNames(n *Neighborhood) []string {
+	names := []string{}
+	for _, e := range n.Entities {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestGetNeighbors(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "A", EntityType: "node", Observations: []string{"first"}},
+		{Name: "B", EntityType: "node"},
+		{Name: "C", EntityType: "node"},
+		{Name: "D", EntityType: "node"},
+		{Name: "E", EntityType: "node"},
+	})
+	assert.NoError(t, err)
+	// A -> B -> A is a cycle, B -> C -> D a chain, and E points in at A
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "A", To: "B", RelationType: "links"},
+		{From: "B", To: "A", RelationType: "links"},
+		{From: "A", To: "A", RelationType: "self"},
+		{From: "B", To: "C", RelationType: "links"},
+		{From: "C", To: "D", RelationType: "links"},
+		{From: "E", To: "A", RelationType: "links"},
+	})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		names     []string
+		depth     int
+		direction string
+		want      []string
+	}{
+		{[]string{"A"}, 1, NeighborsOut, []string{"A", "B"}},
+		{[]string{"A"}, 1, NeighborsIn, []string{"A", "B", "E"}},
+		{[]string{"A"}, 1, NeighborsBoth, []string{"A", "B", "E"}},
+		{[]string{"A"}, 2, NeighborsOut, []string{"A", "B", "C"}},
+		{[]string{"A"}, 3, NeighborsOut, []string{"A", "B", "C", "D"}},
+		{[]string{"A"}, 3, NeighborsIn, []string{"A", "B", "E"}},
+		{[]string{"D"}, 3, NeighborsIn, []string{"A", "B", "C", "D"}},
+		{[]string{"D"}, 1, NeighborsOut, []string{"D"}},
+		{[]string{"E", "D"}, 1, NeighborsBoth, []string{"A", "C", "D", "E"}},
+	} {
+		n, err := db.GetNeighbors(ctx, tc.names, tc.depth, tc.direction, 500)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, neighborNames(n), "%v depth %d %s", tc.names, tc.depth, tc.direction)
+		assert.False(t, n.Truncated)
+	}
+
+	// The subgraph carries the observations and the relations among its entities
+	n, err := db.GetNeighbors(ctx, []string{"A"}, 1, NeighborsOut, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first"}, n.Entities[0].Observations)
+	assert.ElementsMatch(t, []RelationDTO{
+		{From: "A", To: "B", RelationType: "links"},
+		{From: "B", To: "A", RelationType: "links"},
+		{From: "A", To: "A", RelationType: "self"},
+	}, n.Relations)
+
+	n, err = db.GetNeighbors(ctx, []string{"Nobody", "D"}, 1, NeighborsBoth, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Nobody"}, n.NotFound)
+	assert.Equal(t, []string{"C", "D"}, neighborNames(n))
+
+	n, err = db.GetNeighbors(ctx, []string{"Nobody"}, 1, NeighborsBoth, 500)
+	assert.NoError(t, err)
+	assert.Empty(t, n.Entities)
+	assert.NotNil(t, n.Relations)
+
+	_, err = db.GetNeighbors(ctx, []string{"A"}, 1, "sideways", 500)
+	assert.Error(t, err)
+}
+
+func TestGetNeighbors_Cap(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	spokes := make([]RelationDTO, 20)
+	entities := []EntityWithObservations{{Name: "Hub", EntityType: "hub"}}
+	for i := range spokes {
+		name := fmt.Sprintf("spoke_%02d", i)
+		entities = append(entities, EntityWithObservations{Name: name, EntityType: "spoke"})
+		spokes[i] = RelationDTO{From: "Hub", To: name, RelationType: "has"}
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, spokes)
+	assert.NoError(t, err)
+
+	// The hub and the first spokes, in creation order, fill the cap
+	n, err := db.GetNeighbors(ctx, []string{"Hub"}, 1, NeighborsOut, 5)
+	assert.NoError(t, err)
+	assert.True(t, n.Truncated)
+	assert.Equal(t, []string{"Hub", "spoke_00", "spoke_01", "spoke_02", "spoke_03"}, neighborNames(n))
+	assert.Len(t, n.Relations, 4)
+
+	n, err = db.GetNeighbors(ctx, []string{"Hub"}, 1, NeighborsOut, 21)
+	assert.NoError(t, err)
+	assert.False(t, n.Truncated)
+	assert.Len(t, n.Entities, 21)
+
+	n, err = db.GetNeighbors(ctx, []string{"spoke_00", "spoke_01", "spoke_02"}, 1, NeighborsIn, 2)
+	assert.NoError(t, err)
+	assert.True(t, n.Truncated)
+	assert.Equal(t, []string{"spoke_00", "spoke_01"}, neighborNames(n))
+}
+
+func TestGetNeighbors_CacheMatchesSQL(t *testing.T) {
+	uncached := newImportTestDB(t)
+	cached := newImportTestDB(t)
+	cached.SetAdjacencyCache(1 << 30)
+	for _, db := range []*DB{uncached, cached} {
+		seedRandomGraph(t, db, 300, 600, 3)
+	}
+	ctx := context.Background()
+
+	rng := rand.New(rand.NewSource(4))
+	directions := []string{NeighborsOut, NeighborsIn, NeighborsBoth}
+	for i := 0; i < 50; i++ {
+		start := []string{fmt.Sprintf("node_%d", rng.Intn(300))}
+		direction := directions[i%len(directions)]
+		depth := 1 + rng.Intn(3)
+		want, err := uncached.GetNeighbors(ctx, start, depth, direction, 100)
+		assert.NoError(t, err)
+		got, err := cached.GetNeighbors(ctx, start, depth, direction, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "%v depth %d %s", start, depth, direction)
+	}
+}
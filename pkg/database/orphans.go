@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultOrphanLimit is how many orphans FindOrphans returns when no limit is given
+const DefaultOrphanLimit = 100
+
+// MaxOrphanLimit is the most orphans FindOrphans returns, or deletes, at once
+const MaxOrphanLimit = 1000
+
+// OrphanOptions narrows the orphans FindOrphans returns
+type OrphanOptions struct {
+	// NoObservations keeps only the orphans without observations
+	NoObservations bool
+	// OlderThanDays keeps only the orphans last updated at least this many days ago
+	OlderThanDays int
+	// Limit is how many orphans to return (0 = DefaultOrphanLimit)
+	Limit int
+	// Delete deletes the orphans returned, as DeleteEntities does
+	Delete bool
+}
+
+// OrphanEntity is an entity without relations
+type OrphanEntity struct {
+	Name         string `json:"name"`
+	EntityType   string `json:"entityType"`
+	Observations int    `json:"observations"`
+	// UpdatedAt is when the entity was last updated (RFC 3339, UTC)
+	UpdatedAt string `json:"updatedAt"`
+	// Pinned entities are not deleted with the others unless it is forced
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// OrphanReport lists the orphans found, least recently updated first
+type OrphanReport struct {
+	Orphans []OrphanEntity `json:"orphans"`
+	// HasMore is set when more entities matched than the limit
+	HasMore bool `json:"hasMore"`
+	// Deleted is set when the orphans listed were deleted
+	Deleted bool `json:"deleted"`
+	// Protected lists the pinned orphans kept when deleting them
+	Protected []string `json:"protected,omitempty"`
+}
+
+// FindOrphans lists the entities of the graph with no relations from or to them,
+// least recently updated first, so they can be reviewed and cleaned up. With
+// opts.Delete the orphans listed are deleted in the same transaction, or moved to the
+// trash when soft delete is on; pinned ones are kept unless ctx is from
+// WithForceDelete.
+func (db *DB) FindOrphans(ctx context.Context, opts OrphanOptions) (*OrphanReport, error) {
+	if !opts.Delete {
+		report, _, err := findOrphans(ctx, db.reader, opts)
+		return report, err
+	}
+	return retryWriteResult(ctx, db, func() (*OrphanReport, error) {
+		return db.deleteOrphans(ctx, opts)
+	})
+}
+
+// deleteOrphans makes one attempt at FindOrphans with opts.Delete
+func (db *DB) deleteOrphans(ctx context.Context, opts OrphanOptions) (*OrphanReport, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report, found, err := findOrphans(ctx, tx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var names []string
+	for i, orphan := range report.Orphans {
+		if orphan.Pinned && !forceDelete(ctx) {
+			report.Protected = append(report.Protected, orphan.Name)
+			continue
+		}
+		ids = append(ids, found[i])
+		names = append(names, orphan.Name)
+	}
+	if len(ids) == 0 {
+		return report, nil
+	}
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if db.softDelete {
+		for _, id := range ids {
+			if err := trashEntityTx(ctx, tx, graph, id); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for _, chunk := range chunks(ids, maxListValues) {
+			list, args := inList(chunk)
+			if _, err := tx.ExecContext(ctx, "DELETE FROM observations WHERE entity_id IN "+list, args...); err != nil {
+				return nil, err
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM entities WHERE id IN "+list, args...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := db.auditTx(ctx, tx, graph, "delete_orphans", names, "deleted orphans "+auditNames(names)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	report.Deleted = true
+
+	db.logger.Info("orphan entities deleted",
+		slog.String("graph", graphFrom(ctx)),
+		slog.Int("entities", len(ids)),
+		slog.Bool("soft_delete", db.softDelete),
+	)
+	return report, nil
+}
+
+// findOrphans returns the orphans matching opts, with their ids
+func findOrphans(ctx context.Context, q relationQuerier, opts OrphanOptions) (*OrphanReport, []int64, error) {
+	graph, err := graphID(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultOrphanLimit
+	}
+	limit = min(limit, MaxOrphanLimit)
+
+	cond, args := "", []any{graph}
+	if opts.NoObservations {
+		cond += " AND NOT EXISTS (SELECT 1 FROM observations o WHERE o.entity_id = e.id AND " + liveObservation("o") + ")"
+	}
+	if opts.OlderThanDays > 0 {
+		cond += " AND e.updated_at <= datetime('now', ?)"
+		args = append(args, fmt.Sprintf("-%d days", opts.OlderThanDays))
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type,
+			(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id AND `+liveObservation("o")+`),
+			`+rfc3339Column("e.updated_at")+`,
+			`+pinnedColumn+`
+		FROM entities e
+		WHERE e.graph_id = ?
+			AND NOT EXISTS (SELECT 1 FROM relations r WHERE r.from_entity_id = e.id)
+			AND NOT EXISTS (SELECT 1 FROM relations r WHERE r.to_entity_id = e.id)`+cond+`
+		ORDER BY e.updated_at, e.name
+		LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	report := &OrphanReport{Orphans: []OrphanEntity{}}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var orphan OrphanEntity
+		if err := rows.Scan(&id, &orphan.Name, &orphan.EntityType, &orphan.Observations, &orphan.UpdatedAt, &orphan.Pinned); err != nil {
+			return nil, nil, err
+		}
+		if len(ids) == limit {
+			report.HasMore = true
+			break
+		}
+		ids = append(ids, id)
+		report.Orphans = append(report.Orphans, orphan)
+	}
+	return report, ids, rows.Err()
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedOrphans creates a graph with connected, isolated and observation-only entities
+func seedOrphans(t *testing.T, db *DB) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Loner", EntityType: "person"},
+		{Name: "Notes", EntityType: "topic", Observations: []string{"draft", "todo"}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+	assert.NoError(t, err)
+	// Loner was last updated long ago
+	_, err = db.conn.Exec("UPDATE entities SET updated_at = datetime('now', '-30 days') WHERE name = 'Loner'")
+	assert.NoError(t, err)
+}
+
+func orphanNames(report *OrphanReport) []string {
+	names := []string{}
+	for _, orphan := range report.Orphans {
+		names = append(names, orphan.Name)
+	}
+	return names
+}
+
+func TestFindOrphans(t *testing.T) {
+	db := newImportTestDB(t)
+	seedOrphans(t, db)
+	ctx := context.Background()
+
+	report, err := db.FindOrphans(ctx, OrphanOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner", "Notes"}, orphanNames(report), "least recently updated first")
+	assert.False(t, report.HasMore)
+	assert.False(t, report.Deleted)
+	assert.Equal(t, 2, report.Orphans[1].Observations)
+	assert.NotEmpty(t, report.Orphans[0].UpdatedAt)
+
+	report, err = db.FindOrphans(ctx, OrphanOptions{NoObservations: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, orphanNames(report))
+
+	report, err = db.FindOrphans(ctx, OrphanOptions{OlderThanDays: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, orphanNames(report))
+
+	report, err = db.FindOrphans(ctx, OrphanOptions{OlderThanDays: 60})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+
+	report, err = db.FindOrphans(ctx, OrphanOptions{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, orphanNames(report))
+	assert.True(t, report.HasMore)
+
+	// Finding orphans changes nothing
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 4)
+}
+
+func TestFindOrphans_Delete(t *testing.T) {
+	db := newImportTestDB(t)
+	seedOrphans(t, db)
+	ctx := context.Background()
+
+	report, err := db.FindOrphans(ctx, OrphanOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner", "Notes"}, orphanNames(report))
+	assert.True(t, report.Deleted)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2)
+	assert.Len(t, graph.Relations, 1)
+	found, err := db.SearchNodesFTS(ctx, "draft", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Entities)
+
+	history, err := db.History(ctx, HistoryFilter{EntityName: "Notes"})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, history.Entries) {
+		assert.Equal(t, "delete_orphans", history.Entries[len(history.Entries)-1].Operation)
+	}
+
+	// With nothing left to delete, the report is empty
+	report, err = db.FindOrphans(ctx, OrphanOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+	assert.False(t, report.Deleted)
+}
+
+func TestFindOrphans_SoftDelete(t *testing.T) {
+	db := newImportTestDB(t)
+	db.SetSoftDelete(true)
+	seedOrphans(t, db)
+	ctx := context.Background()
+
+	report, err := db.FindOrphans(ctx, OrphanOptions{NoObservations: true, Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, orphanNames(report))
+	assert.True(t, report.Deleted)
+
+	trash, err := db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, "Loner", trash[0].Name)
+	}
+	report, err = db.FindOrphans(ctx, OrphanOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Notes"}, orphanNames(report), "trashed entities are not orphans")
+}
@@ -0,0 +1,601 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MergeConflict describes an entity whose type differs between the target and an incoming graph.
+// The existing type is kept; observations are still unioned.
+type MergeConflict struct {
+	Name         string `json:"name"`
+	ExistingType string `json:"existingType"`
+	IncomingType string `json:"incomingType"`
+	Source       string `json:"source,omitempty"`
+	// Graph is the graph of the entity, unless it is the default one
+	Graph string `json:"graph,omitempty"`
+}
+
+// MergeReport summarizes the outcome of merging one or more graphs into a database
+type MergeReport struct {
+	EntitiesCreated   int             `json:"entitiesCreated"`
+	EntitiesMerged    int             `json:"entitiesMerged"`
+	ObservationsAdded int             `json:"observationsAdded"`
+	RelationsCreated  int             `json:"relationsCreated"`
+	RelationsSkipped  int             `json:"relationsSkipped"`
+	Conflicts         []MergeConflict `json:"conflicts"`
+	// TypeMetadataSet counts the entity types whose metadata was imported
+	TypeMetadataSet int `json:"typeMetadataSet,omitempty"`
+	// AliasesSkipped counts the aliases not added because they name another entity
+	// or are already an alias of one
+	AliasesSkipped int `json:"aliasesSkipped,omitempty"`
+}
+
+func (r *MergeReport) add(other *MergeReport) {
+	r.EntitiesCreated += other.EntitiesCreated
+	r.EntitiesMerged += other.EntitiesMerged
+	r.ObservationsAdded += other.ObservationsAdded
+	r.RelationsCreated += other.RelationsCreated
+	r.RelationsSkipped += other.RelationsSkipped
+	r.Conflicts = append(r.Conflicts, other.Conflicts...)
+	r.TypeMetadataSet += other.TypeMetadataSet
+	r.AliasesSkipped += other.AliasesSkipped
+}
+
+// PartitionResult describes one database file written by a split operation: the
+// entity type of SplitByEntityType or the graph of SplitByGraph it holds
+type PartitionResult struct {
+	EntityType   string `json:"entityType,omitempty"`
+	Graph        string `json:"graph,omitempty"`
+	Path         string `json:"path"`
+	Entities     int    `json:"entities"`
+	StubEntities int    `json:"stubEntities"`
+	Relations    int    `json:"relations"`
+	Skipped      bool   `json:"skipped"`
+}
+
+// partialSuffix marks output files that are still being written
+const partialSuffix = ".partial"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._\-]+`)
+
+// NewReadOnlyDB opens an existing database file without migrating or otherwise writing to it
+func NewReadOnlyDB(dbPath string, logger *slog.Logger) (*DB, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	logger.Info("opening read-only database connection",
+		slog.String("path", dbPath),
+	)
+
+	conn, err := sql.Open(SQL_DRIVER, "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn.SetMaxOpenConns(MAX_OPEN_CONNECTIONS)
+	conn.SetMaxIdleConns(MAX_IDLE_CONNECTIONS)
+	conn.SetConnMaxLifetime(MAX_CONNECTION_LIFETIME)
+
+	for _, pragma := range []string{"PRAGMA query_only = ON", "PRAGMA busy_timeout = 5000"} {
+		if _, err := conn.Exec(pragma); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to execute %s: %w", pragma, err)
+		}
+	}
+
+	db := &DB{conn: conn, reader: conn, logger: logger, observationLimit: DefaultObservationLimit, writeAttempts: 1, readOnly: true}
+
+	var ftsTables int
+	if err := conn.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('entities_fts', 'observations_fts')",
+	).Scan(&ftsTables); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	db.ftsEnabled = ftsTables == 2
+
+	return db, nil
+}
+
+// MergeGraph merges a graph into the database in a single transaction.
+// New entities are created; existing entities gain any missing observations
+// (a differing entity type is reported as a conflict and the existing type is kept);
+// relations are added unless they already exist or an endpoint is missing.
+func (db *DB) MergeGraph(ctx context.Context, graph *KnowledgeGraph) (*MergeReport, error) {
+	return retryWriteResult(ctx, db, func() (*MergeReport, error) {
+		return db.mergeGraph(ctx, graph)
+	})
+}
+
+// mergeGraph makes one attempt at MergeGraph
+func (db *DB) mergeGraph(ctx context.Context, graph *KnowledgeGraph) (*MergeReport, error) {
+	start := time.Now()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report, err := db.mergeGraphTx(ctx, tx, graph)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("graph merged successfully",
+		slog.Int("entities_created", report.EntitiesCreated),
+		slog.Int("entities_merged", report.EntitiesMerged),
+		slog.Int("relations_created", report.RelationsCreated),
+		slog.Int("conflicts", len(report.Conflicts)),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return report, nil
+}
+
+// mergeGraphTx merges graph within tx; see MergeGraph
+func (db *DB) mergeGraphTx(ctx context.Context, tx *sql.Tx, graph *KnowledgeGraph) (*MergeReport, error) {
+	return db.mergeRecordsTx(ctx, tx, graphToRecords(graph))
+}
+
+// mergeRecordsTx merges records within tx as MergeGraph does, applying type metadata
+// first and relations last so they may precede their entities. Observations of
+// versioned records keep their writer and creation time; those of reference-format
+// records are attributed to the writer in ctx. Tags and aliases are added, attributes
+// merged and pins set on the entities, and new relations keep their confidence and
+// note; aliases follow all entities so they never take the name of one.
+func (db *DB) mergeRecordsTx(ctx context.Context, tx *sql.Tx, records []graphRecord) (*MergeReport, error) {
+	report := &MergeReport{Conflicts: []MergeConflict{}}
+
+	var entities, relations []graphRecord
+	for i, rec := range records {
+		if err := checkCancelled(ctx, "merge_graph", i, len(records)); err != nil {
+			return nil, err
+		}
+		switch rec.Kind {
+		case RecordEntity:
+			entities = append(entities, rec)
+		case RecordRelation:
+			relations = append(relations, rec)
+		case RecordTypeMetadata:
+			if err := setTypeMetadataTx(ctx, tx, rec.EntityType, rec.Metadata); err != nil {
+				return nil, err
+			}
+			report.TypeMetadataSet++
+		}
+	}
+
+	total := len(entities) + len(relations)
+	if total == 0 {
+		return report, nil
+	}
+	graph, err := createGraphTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	var aliased []aliasedEntity
+	var aliases [][]string
+	for i, entity := range entities {
+		if err := checkCancelled(ctx, "merge_graph", i, total); err != nil {
+			return nil, err
+		}
+
+		var entityID int64
+		var existingType string
+		err := tx.QueryRowContext(ctx, "SELECT id, entity_type FROM entities WHERE graph_id = ? AND name = ?", graph, entity.Name).Scan(&entityID, &existingType)
+		switch {
+		case err == sql.ErrNoRows:
+			result, err := tx.ExecContext(ctx,
+				"INSERT INTO entities (graph_id, name, entity_type) VALUES (?, ?, ?)",
+				graph, entity.Name, entity.EntityType,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if entityID, err = result.LastInsertId(); err != nil {
+				return nil, err
+			}
+			report.EntitiesCreated++
+		case err != nil:
+			return nil, err
+		default:
+			report.EntitiesMerged++
+			if existingType != entity.EntityType {
+				report.Conflicts = append(report.Conflicts, MergeConflict{
+					Name:         entity.Name,
+					ExistingType: existingType,
+					IncomingType: entity.EntityType,
+				})
+			}
+		}
+
+		for _, obs := range entity.Observations {
+			writer, createdAt := writerFrom(ctx), any(nil)
+			if entity.V > 0 {
+				writer = obs.WrittenBy
+				if obs.CreatedAt != "" {
+					t, err := time.Parse(time.RFC3339, obs.CreatedAt)
+					if err != nil {
+						return nil, err
+					}
+					createdAt = t.UTC().Format(sqliteTimeLayout)
+				}
+			}
+			result, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO observations (entity_id, content, written_by, created_at) VALUES (?, ?, NULLIF(?, ''), COALESCE(?, CURRENT_TIMESTAMP))",
+				entityID, obs.Content, writer, createdAt,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				report.ObservationsAdded += int(n)
+			}
+		}
+
+		if err := addTagsTx(ctx, tx, entityID, entity.Tags); err != nil {
+			return nil, err
+		}
+		if _, err := patchAttributesTx(ctx, tx, entityID, entity.Name, entity.Attributes); err != nil {
+			return nil, err
+		}
+		if entity.Pinned {
+			if _, err := tx.ExecContext(ctx, "UPDATE entities SET pinned = 1 WHERE id = ?", entityID); err != nil {
+				return nil, err
+			}
+		}
+		if len(entity.Aliases) > 0 {
+			aliased = append(aliased, aliasedEntity{id: entityID, name: entity.Name})
+			aliases = append(aliases, entity.Aliases)
+		}
+	}
+
+	for i, entity := range aliased {
+		for _, alias := range aliases[i] {
+			skipped, err := addImportedAliasTx(ctx, tx, graph, entity.id, alias)
+			if err != nil {
+				return nil, err
+			}
+			if skipped {
+				report.AliasesSkipped++
+			}
+		}
+	}
+
+	for i, rel := range relations {
+		if err := checkCancelled(ctx, "merge_graph", len(entities)+i, total); err != nil {
+			return nil, err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO relations (from_entity_id, to_entity_id, relation_type, confidence, note)
+			SELECT f.id, t.id, ?, NULLIF(?, 0), NULLIF(?, '')
+			FROM entities f, entities t
+			WHERE f.graph_id = ? AND f.name = ? AND t.graph_id = ? AND t.name = ?`,
+			rel.RelationType, rel.Confidence, rel.Note, graph, rel.From, graph, rel.To,
+		)
+		if err != nil {
+			return nil, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			report.RelationsCreated++
+		} else {
+			report.RelationsSkipped++
+		}
+	}
+
+	names := make([]string, len(entities))
+	for i, entity := range entities {
+		names[i] = entity.Name
+	}
+	summary := fmt.Sprintf("imported %d entities (%d created) and %d relations (%d created)",
+		len(entities), report.EntitiesCreated, len(relations), report.RelationsCreated)
+	if err := db.auditTx(ctx, tx, graph, "import", names, summary); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// graphPart is a graph, or part of one, and the graph of a database it is written to
+type graphPart struct {
+	graph string
+	*KnowledgeGraph
+}
+
+// graphParts reads every graph of the database, in name order, as the part written
+// to the graph of the same name. The trash is left out.
+func (db *DB) graphParts(ctx context.Context) ([]graphPart, error) {
+	graphs, err := db.ListGraphs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]graphPart, 0, len(graphs))
+	for _, g := range graphs {
+		graph, err := db.readGraph(WithGraph(ctx, g.Name), 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read graph %q: %w", g.Name, err)
+		}
+		if err := db.AddAliases(WithGraph(ctx, g.Name), graph); err != nil {
+			return nil, fmt.Errorf("failed to read graph %q: %w", g.Name, err)
+		}
+		parts = append(parts, graphPart{graph: g.Name, KnowledgeGraph: graph})
+	}
+	return parts, nil
+}
+
+// splitByType splits graph by entity type. A relation is kept with its source entity,
+// along with a stub of a target of another type, which the returned counts count.
+func splitByType(graph *KnowledgeGraph) (map[string]*KnowledgeGraph, map[string]int) {
+	typeOf := make(map[string]string, len(graph.Entities))
+	partitions := make(map[string]*KnowledgeGraph)
+	stubs := make(map[string]map[string]bool)
+	for _, entity := range graph.Entities {
+		typeOf[entity.Name] = entity.EntityType
+		part, ok := partitions[entity.EntityType]
+		if !ok {
+			part = &KnowledgeGraph{Entities: []EntityWithObservations{}, Relations: []RelationDTO{}}
+			partitions[entity.EntityType] = part
+			stubs[entity.EntityType] = make(map[string]bool)
+		}
+		part.Entities = append(part.Entities, entity)
+	}
+
+	stubCounts := make(map[string]int)
+	for _, rel := range graph.Relations {
+		fromType, toType := typeOf[rel.From], typeOf[rel.To]
+		part := partitions[fromType]
+		part.Relations = append(part.Relations, rel)
+		if toType != fromType && !stubs[fromType][rel.To] {
+			stubs[fromType][rel.To] = true
+			part.Entities = append(part.Entities, EntityWithObservations{
+				Name:         rel.To,
+				EntityType:   toType,
+				Observations: []string{},
+			})
+			stubCounts[fromType]++
+		}
+	}
+	return partitions, stubCounts
+}
+
+// SplitByEntityType writes each entity type of the database into its own database file in outDir.
+// Each graph's entities of the type are written to the graph of the same name in the file.
+// A relation is stored with its source entity; when the target belongs to another
+// partition, a stub of the target (name and type, no observations) is written alongside
+// so merging the partitions back reproduces the original graphs.
+// Existing output files are left untouched and reported as skipped, so an interrupted
+// split can be resumed; each file is written under a temporary name and renamed when complete.
+func (db *DB) SplitByEntityType(ctx context.Context, outDir string) ([]PartitionResult, error) {
+	if err := os.MkdirAll(outDir, DB_PERMS); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	graphs, err := db.graphParts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[string][]graphPart)
+	counts := make(map[string]*PartitionResult)
+	for _, graph := range graphs {
+		parts, stubCounts := splitByType(graph.KnowledgeGraph)
+		for entityType, part := range parts {
+			partitions[entityType] = append(partitions[entityType], graphPart{graph: graph.graph, KnowledgeGraph: part})
+			count, ok := counts[entityType]
+			if !ok {
+				count = &PartitionResult{EntityType: entityType}
+				counts[entityType] = count
+			}
+			count.Entities += len(part.Entities) - stubCounts[entityType]
+			count.StubEntities += stubCounts[entityType]
+			count.Relations += len(part.Relations)
+		}
+	}
+
+	types := make([]string, 0, len(partitions))
+	for entityType := range partitions {
+		types = append(types, entityType)
+	}
+	sort.Strings(types)
+
+	usedNames := make(map[string]int)
+	results := make([]PartitionResult, 0, len(types))
+	for _, entityType := range types {
+		base := unsafeFileChars.ReplaceAllString(entityType, "_")
+		if n := usedNames[base]; n > 0 {
+			usedNames[base] = n + 1
+			base = fmt.Sprintf("%s-%d", base, n+1)
+		} else {
+			usedNames[base] = 1
+		}
+
+		result := *counts[entityType]
+		result.Path = filepath.Join(outDir, base+".db")
+		if db.partitionExists(result.Path, slog.String("entity_type", entityType)) {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		if _, err := writeGraphFile(ctx, result.Path, db.logger, partitions[entityType]...); err != nil {
+			return results, fmt.Errorf("failed to write partition %q: %w", entityType, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// SplitByGraph writes each graph of the database into the default graph of its own
+// database file in outDir, named after the graph, so each can be served on its own.
+// MergeDatabasesAsGraphs puts the files back together. Existing output files are
+// skipped, and each is written atomically, as by SplitByEntityType.
+func (db *DB) SplitByGraph(ctx context.Context, outDir string) ([]PartitionResult, error) {
+	if err := os.MkdirAll(outDir, DB_PERMS); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	graphs, err := db.graphParts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PartitionResult, 0, len(graphs))
+	for _, graph := range graphs {
+		// Graph names are safe file names
+		result := PartitionResult{
+			Graph:     graph.graph,
+			Path:      filepath.Join(outDir, graph.graph+".db"),
+			Entities:  len(graph.Entities),
+			Relations: len(graph.Relations),
+		}
+		if db.partitionExists(result.Path, slog.String("graph", graph.graph)) {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		if _, err := writeGraphFile(ctx, result.Path, db.logger, graphPart{graph: DefaultGraph, KnowledgeGraph: graph.KnowledgeGraph}); err != nil {
+			return results, fmt.Errorf("failed to write graph %q: %w", graph.graph, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// partitionExists reports whether the output file at path was written already,
+// logging that it is skipped along with attr
+func (db *DB) partitionExists(path string, attr slog.Attr) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	db.logger.Info("partition already exists, skipping",
+		attr,
+		slog.String("path", path),
+	)
+	return true
+}
+
+// MergeDatabases merges the graphs of several database files into a new database file,
+// each graph into the graph of the same name.
+// Sources are opened read-only; destPath must not exist yet and only appears once the merge completes.
+func MergeDatabases(ctx context.Context, sources []string, destPath string, logger *slog.Logger) (*MergeReport, error) {
+	return mergeDatabases(ctx, sources, destPath, logger, false)
+}
+
+// MergeDatabasesAsGraphs merges database files as MergeDatabases does, except that the
+// default graph of each is merged into a graph named after the file without its
+// extension, such as "project-a" for project-a.db, which reverses SplitByGraph. A file
+// named default.db is merged into the default graph.
+func MergeDatabasesAsGraphs(ctx context.Context, sources []string, destPath string, logger *slog.Logger) (*MergeReport, error) {
+	return mergeDatabases(ctx, sources, destPath, logger, true)
+}
+
+func mergeDatabases(ctx context.Context, sources []string, destPath string, logger *slog.Logger, asGraphs bool) (*MergeReport, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return nil, fmt.Errorf("destination %s already exists", destPath)
+	}
+
+	var parts []graphPart
+	var partSources []string
+	for _, src := range sources {
+		name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+		if asGraphs && !ValidGraphName(name) {
+			return nil, fmt.Errorf("%s: %q is not a valid graph name", src, name)
+		}
+		srcDB, err := NewReadOnlyDB(src, logger)
+		if err != nil {
+			return nil, err
+		}
+		graphs, err := srcDB.graphParts(ctx)
+		srcDB.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", src, err)
+		}
+		for _, graph := range graphs {
+			if asGraphs && graph.graph == DefaultGraph {
+				graph.graph = name
+			}
+			parts = append(parts, graph)
+			partSources = append(partSources, src)
+		}
+	}
+
+	reports, err := writeGraphFile(ctx, destPath, logger, parts...)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MergeReport{Conflicts: []MergeConflict{}}
+	for i, partReport := range reports {
+		for j := range partReport.Conflicts {
+			partReport.Conflicts[j].Source = partSources[i]
+			if parts[i].graph != DefaultGraph {
+				partReport.Conflicts[j].Graph = parts[i].graph
+			}
+		}
+		report.add(partReport)
+	}
+	return report, nil
+}
+
+// writeGraphFile merges parts into their graphs of a fresh database at path, writing
+// under a temporary name first so the final file only exists when complete. It returns
+// one report per part.
+func writeGraphFile(ctx context.Context, path string, logger *slog.Logger, parts ...graphPart) ([]*MergeReport, error) {
+	tmpPath := path + partialSuffix
+	cleanup := func() {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			_ = os.Remove(tmpPath + suffix)
+		}
+	}
+	cleanup()
+
+	dest, err := NewDBWithLogger(tmpPath, logger)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	reports := make([]*MergeReport, 0, len(parts))
+	for _, part := range parts {
+		report, err := dest.MergeGraph(WithGraph(ctx, part.graph), part.KnowledgeGraph)
+		if err != nil {
+			dest.Close()
+			cleanup()
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	if err := dest.Close(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return reports, nil
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seedPartitionFixture(t *testing.T, path string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer", "likes go"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+		{Name: "Acme", EntityType: "org", Observations: []string{"founded 1999"}},
+		{Name: "Apollo", EntityType: "project/2024", Observations: []string{}},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "reports_to"},
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Acme", To: "Apollo", RelationType: "owns"},
+	})
+	assert.NoError(t, err)
+}
+
+func readGraphFile(t *testing.T, path string) *KnowledgeGraph {
+	t.Helper()
+	return readNamedGraphFile(t, path, DefaultGraph)
+}
+
+func readNamedGraphFile(t *testing.T, path, graph string) *KnowledgeGraph {
+	t.Helper()
+	db, err := NewReadOnlyDB(path, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	assert.NoError(t, err)
+	defer db.Close()
+	g, err := db.ReadGraph(WithGraph(context.Background(), graph))
+	assert.NoError(t, err)
+	for i := range g.Entities {
+		sort.Strings(g.Entities[i].Observations)
+	}
+	return g
+}
+
+func fileHash(t *testing.T, path string) [32]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return sha256.Sum256(data)
+}
+
+func TestSplitByEntityType_MergeRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "memory.db")
+	seedPartitionFixture(t, srcPath)
+	srcHash := fileHash(t, srcPath)
+
+	src, err := NewReadOnlyDB(srcPath, nil)
+	assert.NoError(t, err)
+	outDir := filepath.Join(dir, "parts")
+	results, err := src.SplitByEntityType(context.Background(), outDir)
+	assert.NoError(t, err)
+	assert.NoError(t, src.Close())
+
+	assert.Len(t, results, 3)
+	byType := map[string]PartitionResult{}
+	for _, r := range results {
+		byType[r.EntityType] = r
+		assert.FileExists(t, r.Path)
+		assert.NoFileExists(t, r.Path+partialSuffix)
+	}
+	assert.Equal(t, 2, byType["person"].Entities)
+	assert.Equal(t, 1, byType["person"].StubEntities) // Acme
+	assert.Equal(t, 3, byType["person"].Relations)
+	assert.Equal(t, filepath.Join(outDir, "project_2024.db"), byType["project/2024"].Path)
+
+	// Partitions carry only their own observations
+	person := readGraphFile(t, byType["person"].Path)
+	for _, e := range person.Entities {
+		if e.Name == "Acme" {
+			assert.Empty(t, e.Observations)
+		}
+	}
+
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Path
+	}
+	mergedPath := filepath.Join(dir, "merged.db")
+	report, err := MergeDatabases(context.Background(), paths, mergedPath, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Conflicts)
+	assert.Equal(t, 4, report.RelationsCreated)
+
+	assert.Equal(t, readGraphFile(t, srcPath), readGraphFile(t, mergedPath))
+	assert.Equal(t, srcHash, fileHash(t, srcPath), "source database must not be modified")
+
+	// Existing outputs are skipped so a split can be resumed
+	src, err = NewReadOnlyDB(srcPath, nil)
+	assert.NoError(t, err)
+	defer src.Close()
+	results, err = src.SplitByEntityType(context.Background(), outDir)
+	assert.NoError(t, err)
+	for _, r := range results {
+		assert.True(t, r.Skipped)
+	}
+}
+
+func TestMergeDatabases_ConflictsAndExistingDestination(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	ctx := context.Background()
+
+	a := filepath.Join(dir, "a.db")
+	b := filepath.Join(dir, "b.db")
+	for path, entityType := range map[string]string{a: "person", b: "robot"} {
+		db, err := NewDBWithLogger(path, logger)
+		assert.NoError(t, err)
+		_, err = db.CreateEntities(ctx, []EntityWithObservations{
+			{Name: "Marvin", EntityType: entityType, Observations: []string{"from " + entityType, "shared"}},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, db.Close())
+	}
+
+	merged := filepath.Join(dir, "merged.db")
+	report, err := MergeDatabases(ctx, []string{a, b}, merged, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.EntitiesCreated)
+	assert.Equal(t, 1, report.EntitiesMerged)
+	assert.Equal(t, 3, report.ObservationsAdded)
+	assert.Equal(t, []MergeConflict{{Name: "Marvin", ExistingType: "person", IncomingType: "robot", Source: b}}, report.Conflicts)
+
+	g := readGraphFile(t, merged)
+	assert.Equal(t, []string{"from person", "from robot", "shared"}, g.Entities[0].Observations)
+
+	_, err = MergeDatabases(ctx, []string{a}, merged, logger)
+	assert.Error(t, err)
+}
+
+func TestSplitAndMerge_NamedGraphs(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "memory.db")
+	seedPartitionFixture(t, srcPath)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	ctx := context.Background()
+
+	// Alice of the fixture and another Alice in project-a
+	db, err := NewDBWithLogger(srcPath, logger)
+	assert.NoError(t, err)
+	projectA := WithGraph(ctx, "project-a")
+	_, err = db.CreateEntities(projectA, []EntityWithObservations{
+		{Name: "Alice", EntityType: "robot", Observations: []string{"beeps"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(projectA, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	assertGraphs := func(name, path string) {
+		t.Helper()
+		for _, graph := range []string{DefaultGraph, "project-a"} {
+			assert.Equal(t, readNamedGraphFile(t, srcPath, graph), readNamedGraphFile(t, path, graph), "%s: %s", name, graph)
+		}
+	}
+
+	src, err := NewReadOnlyDB(srcPath, logger)
+	assert.NoError(t, err)
+	defer src.Close()
+
+	// A split by type keeps each graph's entities apart
+	results, err := src.SplitByEntityType(ctx, filepath.Join(dir, "types"))
+	assert.NoError(t, err)
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Path
+		if r.EntityType == "person" {
+			assert.Equal(t, 3, r.Entities)
+		}
+	}
+	mergedPath := filepath.Join(dir, "merged.db")
+	report, err := MergeDatabases(ctx, paths, mergedPath, logger)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Conflicts)
+	assertGraphs("by type", mergedPath)
+
+	// A split by graph writes each graph to the default graph of its file, and merging
+	// the files as graphs restores them
+	results, err = src.SplitByGraph(ctx, filepath.Join(dir, "graphs"))
+	assert.NoError(t, err)
+	assert.Equal(t, []PartitionResult{
+		{Graph: DefaultGraph, Path: filepath.Join(dir, "graphs", "default.db"), Entities: 4, Relations: 4},
+		{Graph: "project-a", Path: filepath.Join(dir, "graphs", "project-a.db"), Entities: 2, Relations: 1},
+	}, results)
+	assert.Equal(t, readNamedGraphFile(t, srcPath, "project-a"), readGraphFile(t, results[1].Path))
+	paths = []string{results[0].Path, results[1].Path}
+	mergedPath = filepath.Join(dir, "merged-graphs.db")
+	_, err = MergeDatabasesAsGraphs(ctx, paths, mergedPath, logger)
+	assert.NoError(t, err)
+	assertGraphs("by graph", mergedPath)
+
+	results, err = src.SplitByGraph(ctx, filepath.Join(dir, "graphs"))
+	assert.NoError(t, err)
+	for _, r := range results {
+		assert.True(t, r.Skipped)
+	}
+
+	// Merged plainly, both files land in the default graph and the Alices conflict
+	report, err = MergeDatabases(ctx, paths, filepath.Join(dir, "flat.db"), logger)
+	assert.NoError(t, err)
+	assert.Equal(t, []MergeConflict{{Name: "Alice", ExistingType: "person", IncomingType: "robot", Source: paths[1]}}, report.Conflicts)
+}
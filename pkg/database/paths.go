@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// pathQueryChunk bounds the entities one query of a SQL traversal expands; expandSQL
+// binds each of them twice
+const pathQueryChunk = maxListValues / 2
+
+// FindPath returns the shortest chain of at most maxDepth relations connecting two
+// entities. Relations are followed in either direction, each keeping its own, or,
+// when directed, only from their from entity to their to entity. found is false
+// when either entity doesn't exist or they aren't connected within maxDepth. Among
+// paths of equal length, the one through the earliest created relations wins.
+//
+// The search expands one level at a time, from the adjacency cache when it is
+// enabled and current, otherwise with a query per level.
+func (db *DB) FindPath(ctx context.Context, from, to string, maxDepth int, directed bool) (path []RelationDTO, found bool, err error) {
+	ids, err := db.entityIDs(ctx, []string{from, to})
+	if err != nil {
+		return nil, false, err
+	}
+	fromID, okFrom := ids[from]
+	toID, okTo := ids[to]
+	if !okFrom || !okTo {
+		return nil, false, nil
+	}
+	if fromID == toID {
+		return []RelationDTO{}, true, nil
+	}
+
+	cache, err := db.cachedAdjacency(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	expand := db.expandSQL
+	if cache != nil {
+		expand = func(context.Context, []int64) (map[int64][]neighborEdge, error) {
+			return cache.edges, nil
+		}
+	}
+
+	// parents maps each reached entity to the entity and edge it was reached by
+	type step struct {
+		prev int64
+		edge neighborEdge
+	}
+	parents := map[int64]step{fromID: {}}
+	frontier := []int64{fromID}
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		edges, err := expand(ctx, frontier)
+		if err != nil {
+			return nil, false, err
+		}
+		var next []int64
+		for _, id := range frontier {
+			for _, edge := range edges[id] {
+				if directed && !edge.outgoing {
+					continue
+				}
+				if _, seen := parents[edge.neighbor]; seen {
+					continue
+				}
+				parents[edge.neighbor] = step{prev: id, edge: edge}
+				if edge.neighbor != toID {
+					next = append(next, edge.neighbor)
+					continue
+				}
+
+				// Walk back to the start, then name the entities on the way
+				var steps []step
+				for at := toID; at != fromID; at = parents[at].prev {
+					steps = append(steps, parents[at])
+				}
+				onPath := make([]int64, 0, len(steps)+1)
+				for _, s := range steps {
+					onPath = append(onPath, s.prev, s.edge.neighbor)
+				}
+				names, err := db.entityNames(ctx, onPath)
+				if err != nil {
+					return nil, false, err
+				}
+				path = make([]RelationDTO, len(steps))
+				for i, s := range steps {
+					rel := RelationDTO{From: names[s.prev], To: names[s.edge.neighbor], RelationType: s.edge.relationType}
+					if !s.edge.outgoing {
+						rel.From, rel.To = rel.To, rel.From
+					}
+					path[len(steps)-1-i] = rel
+				}
+				return path, true, nil
+			}
+		}
+		frontier = next
+	}
+	return nil, false, nil
+}
+
+// expandSQL returns the relations of the frontier entities, per entity in creation order
+func (db *DB) expandSQL(ctx context.Context, frontier []int64) (map[int64][]neighborEdge, error) {
+	edges := make(map[int64][]neighborEdge, len(frontier))
+	for start := 0; start < len(frontier); start += pathQueryChunk {
+		chunk := frontier[start:min(start+pathQueryChunk, len(frontier))]
+		inChunk := make(map[int64]bool, len(chunk))
+		placeholders := make([]string, len(chunk))
+		args := make([]any, 0, 2*len(chunk))
+		for i, id := range chunk {
+			inChunk[id] = true
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		args = append(args, args...)
+		in := strings.Join(placeholders, ",")
+
+		rows, err := db.reader.QueryContext(ctx, fmt.Sprintf(`
+			SELECT from_entity_id, to_entity_id, relation_type FROM relations
+			WHERE from_entity_id IN (%s) OR to_entity_id IN (%s)
+			ORDER BY id`, in, in), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var from, to int64
+			var relationType string
+			if err := rows.Scan(&from, &to, &relationType); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			// Entities of other chunks get the relation from their own query
+			if inChunk[from] {
+				edges[from] = append(edges[from], neighborEdge{neighbor: to, relationType: relationType, outgoing: true})
+			}
+			if inChunk[to] {
+				edges[to] = append(edges[to], neighborEdge{neighbor: from, relationType: relationType})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return edges, nil
+}
+
+// entityIDs returns the IDs of the named entities of the graph named by ctx that
+// exist, by name
+func (db *DB) entityIDs(ctx context.Context, names []string) (map[string]int64, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int64, len(names))
+	for _, chunk := range chunks(names, maxListValues-1) {
+		list, args := stringList(chunk)
+		rows, err := db.reader.QueryContext(ctx, "SELECT id, name FROM entities WHERE graph_id = ? AND name IN "+list, append([]any{graph}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids[name] = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// entityNames returns the names of the entities with the given IDs, by ID
+func (db *DB) entityNames(ctx context.Context, ids []int64) (map[int64]string, error) {
+	names := make(map[int64]string, len(ids))
+	for _, chunk := range chunks(ids, maxListValues) {
+		list, args := inList(chunk)
+		rows, err := db.reader.QueryContext(ctx, "SELECT id, name FROM entities WHERE id IN "+list, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			names[id] = name
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
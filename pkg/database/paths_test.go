@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedRandomGraph inserts entities node_0..node_{entities-1} and about relations
+// random relations between them, directly and in one transaction
+func seedRandomGraph(tb testing.TB, db *DB, entities, relations int, seed int64) {
+	tb.Helper()
+	ctx := context.Background()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+	for i := 0; i < entities; i++ {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO entities (id, name, entity_type) VALUES (?, ?, 'node')", i+1, fmt.Sprintf("node_%d", i)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	types := []string{"links", "depends_on", "mentions"}
+	for i := 0; i < relations; i++ {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO relations (from_entity_id, to_entity_id, relation_type) VALUES (?, ?, ?)",
+			rng.Intn(entities)+1, rng.Intn(entities)+1, types[rng.Intn(len(types))],
+		); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestFindPath_CacheMatchesSQL(t *testing.T) {
+	uncached := newImportTestDB(t)
+	cached := newImportTestDB(t)
+	cached.SetAdjacencyCache(1 << 30)
+	for _, db := range []*DB{uncached, cached} {
+		seedRandomGraph(t, db, 400, 700, 1)
+	}
+	ctx := context.Background()
+
+	rng := rand.New(rand.NewSource(2))
+	found := 0
+	for i := 0; i < 200; i++ {
+		from, to := fmt.Sprintf("node_%d", rng.Intn(400)), fmt.Sprintf("node_%d", rng.Intn(400))
+		directed := i%4 == 3
+		want, wantFound, err := uncached.FindPath(ctx, from, to, 6, directed)
+		assert.NoError(t, err)
+		got, gotFound, err := cached.FindPath(ctx, from, to, 6, directed)
+		assert.NoError(t, err)
+		assert.Equal(t, wantFound, gotFound, "%s -> %s", from, to)
+		assert.Equal(t, want, got, "%s -> %s", from, to)
+		if wantFound {
+			found++
+			assert.LessOrEqual(t, len(want), 6)
+		}
+	}
+	assert.Greater(t, found, 100)
+	assert.NotNil(t, cached.adjacency.Load().usable())
+	assert.Nil(t, uncached.adjacency.Load())
+}
+
+func TestFindPath(t *testing.T) {
+	db := newImportTestDB(t)
+	db.SetAdjacencyCache(1 << 20)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Carol", EntityType: "person"},
+		{Name: "Dave", EntityType: "person"},
+		{Name: "Loner", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Carol", To: "Bob", RelationType: "manages"},
+		{From: "Carol", To: "Dave", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+
+	// Relations are followed backwards but reported as created
+	path, found, err := db.FindPath(ctx, "Alice", "Dave", 5, false)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Carol", To: "Bob", RelationType: "manages"},
+		{From: "Carol", To: "Dave", RelationType: "knows"},
+	}, path)
+	built := db.adjacency.Load()
+	assert.NotNil(t, built.usable())
+
+	_, found, err = db.FindPath(ctx, "Alice", "Dave", 2, false)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	for _, to := range []string{"Loner", "Missing"} {
+		_, found, err = db.FindPath(ctx, "Alice", to, 5, false)
+		assert.NoError(t, err)
+		assert.False(t, found)
+	}
+	path, found, err = db.FindPath(ctx, "Alice", "Alice", 5, false)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Empty(t, path)
+
+	// Reads keep the cache; a new relation replaces it
+	_, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	_, _, err = db.FindPath(ctx, "Alice", "Dave", 5, false)
+	assert.NoError(t, err)
+	assert.Same(t, built, db.adjacency.Load())
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Dave", To: "Alice", RelationType: "mentors"}})
+	assert.NoError(t, err)
+	path, _, err = db.FindPath(ctx, "Alice", "Dave", 5, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{{From: "Dave", To: "Alice", RelationType: "mentors"}}, path)
+	assert.NotSame(t, built, db.adjacency.Load())
+
+	// Relations removed by deleting an entity are gone from the cache too
+	_, err = db.DeleteEntities(ctx, []string{"Dave"})
+	assert.NoError(t, err)
+	_, found, err = db.FindPath(ctx, "Alice", "Carol", 5, false)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	_, err = db.DeleteEntities(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	_, found, err = db.FindPath(ctx, "Alice", "Carol", 5, false)
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestFindPath_Directed(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Auth", EntityType: "service"},
+		{Name: "Billing", EntityType: "service"},
+		{Name: "Ledger", EntityType: "database"},
+		{Name: "Audit", EntityType: "database"},
+	})
+	assert.NoError(t, err)
+	// Two equally short ways from Gateway to Ledger, and one edge pointing back
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Gateway", To: "Billing", RelationType: "calls"},
+		{From: "Gateway", To: "Auth", RelationType: "calls"},
+		{From: "Auth", To: "Ledger", RelationType: "reads"},
+		{From: "Billing", To: "Ledger", RelationType: "writes_to"},
+		{From: "Audit", To: "Gateway", RelationType: "watches"},
+	})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		from, to string
+		directed bool
+		want     []RelationDTO
+	}{
+		// The tie goes to the path through the earliest created relations
+		{"Gateway", "Ledger", true, []RelationDTO{
+			{From: "Gateway", To: "Billing", RelationType: "calls"},
+			{From: "Billing", To: "Ledger", RelationType: "writes_to"},
+		}},
+		{"Gateway", "Auth", true, []RelationDTO{{From: "Gateway", To: "Auth", RelationType: "calls"}}},
+		{"Ledger", "Gateway", true, nil},
+		{"Ledger", "Gateway", false, []RelationDTO{
+			{From: "Auth", To: "Ledger", RelationType: "reads"},
+			{From: "Gateway", To: "Auth", RelationType: "calls"},
+		}},
+		{"Audit", "Ledger", true, []RelationDTO{
+			{From: "Audit", To: "Gateway", RelationType: "watches"},
+			{From: "Gateway", To: "Billing", RelationType: "calls"},
+			{From: "Billing", To: "Ledger", RelationType: "writes_to"},
+		}},
+		{"Billing", "Audit", true, nil},
+	} {
+		path, found, err := db.FindPath(ctx, tc.from, tc.to, 5, tc.directed)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want != nil, found, "%s -> %s", tc.from, tc.to)
+		assert.Equal(t, tc.want, path, "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestFindPath_OverBudget(t *testing.T) {
+	db := newImportTestDB(t)
+	seedRandomGraph(t, db, 50, 100, 3)
+	ctx := context.Background()
+	want, wantFound, err := db.FindPath(ctx, "node_1", "node_2", 6, false)
+	assert.NoError(t, err)
+
+	// A budget below the estimated size keeps traversals in SQL
+	db.SetAdjacencyCache(adjacencyBytesPerRelation)
+	got, gotFound, err := db.FindPath(ctx, "node_1", "node_2", 6, false)
+	assert.NoError(t, err)
+	assert.Equal(t, wantFound, gotFound)
+	assert.Equal(t, want, got)
+	cache := db.adjacency.Load()
+	assert.NotNil(t, cache)
+	assert.Nil(t, cache.edges)
+	assert.Greater(t, cache.bytes, int64(adjacencyBytesPerRelation))
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"slices"
+)
+
+// pinnedColumn selects whether an entity aliased as e is pinned
+const pinnedColumn = "e.pinned"
+
+type forceDeleteKey struct{}
+
+// WithForceDelete returns ctx under which DeleteEntities, DeleteEntityReassigning,
+// FindOrphans and ClearGraph delete pinned entities like any other
+func WithForceDelete(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceDeleteKey{}, true)
+}
+
+// forceDelete reports whether ctx was returned by WithForceDelete
+func forceDelete(ctx context.Context) bool {
+	force, _ := ctx.Value(forceDeleteKey{}).(bool)
+	return force
+}
+
+// PinResult reports what PinEntities or UnpinEntities changed
+type PinResult struct {
+	// Changed lists the entities pinned, or unpinned
+	Changed []string `json:"changed"`
+	// Unchanged lists the entities that already were
+	Unchanged []string `json:"unchanged"`
+}
+
+// PinEntities pins entities, protecting them from deletion unless it is forced with
+// WithForceDelete. It fails with an EntityNotFoundError, pinning nothing, if an
+// entity doesn't exist. Pinning doesn't move an entity's update time.
+func (db *DB) PinEntities(ctx context.Context, names []string) (*PinResult, error) {
+	return retryWriteResult(ctx, db, func() (*PinResult, error) {
+		return db.setPinned(ctx, "pin_entities", names, true)
+	})
+}
+
+// UnpinEntities unpins entities, as PinEntities pins them
+func (db *DB) UnpinEntities(ctx context.Context, names []string) (*PinResult, error) {
+	return retryWriteResult(ctx, db, func() (*PinResult, error) {
+		return db.setPinned(ctx, "unpin_entities", names, false)
+	})
+}
+
+// setPinned makes one attempt at PinEntities or UnpinEntities
+func (db *DB) setPinned(ctx context.Context, operation string, names []string, pinned bool) (*PinResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	result := &PinResult{Changed: []string{}, Unchanged: []string{}}
+	for _, name := range dedupe(names) {
+		var was bool
+		err := tx.QueryRowContext(ctx, "SELECT pinned FROM entities WHERE graph_id = ? AND name = ?", graph, name).Scan(&was)
+		if err == sql.ErrNoRows {
+			return nil, &EntityNotFoundError{Name: name}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if was == pinned {
+			result.Unchanged = append(result.Unchanged, name)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE entities SET pinned = ? WHERE graph_id = ? AND name = ?", pinned, graph, name); err != nil {
+			return nil, err
+		}
+		result.Changed = append(result.Changed, name)
+	}
+
+	if len(result.Changed) > 0 {
+		verb := "pinned "
+		if !pinned {
+			verb = "unpinned "
+		}
+		if err := db.auditTx(ctx, tx, graph, operation, result.Changed, verb+auditNames(result.Changed)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.logger.Info("entity pins changed",
+		slog.String("operation", operation),
+		slog.Int("changed", len(result.Changed)),
+	)
+	return result, nil
+}
+
+// protectedAmong returns the names of the pinned entities of graph among names, in
+// name order, or none when ctx forces deletes
+func protectedAmong(ctx context.Context, q relationQuerier, graph int64, names []string) ([]string, error) {
+	if forceDelete(ctx) {
+		return nil, nil
+	}
+	var protected []string
+	for _, chunk := range chunks(dedupe(names), maxListValues-1) {
+		list, args := stringList(chunk)
+		rows, err := q.QueryContext(ctx,
+			"SELECT name FROM entities WHERE graph_id = ? AND pinned = 1 AND name IN "+list, append([]any{graph}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			protected = append(protected, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(protected)
+	return protected, nil
+}
+
+// withoutNames returns names without those in drop
+func withoutNames(names, drop []string) []string {
+	if len(drop) == 0 {
+		return names
+	}
+	return slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		return slices.Contains(drop, name)
+	})
+}
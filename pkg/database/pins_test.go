@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedPins creates a graph with a pinned entity related to an unpinned one
+func seedPins(t *testing.T, db *DB) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "User Preferences", EntityType: "profile", Observations: []string{"prefers tabs"}},
+		{Name: "Scratch", EntityType: "note", Observations: []string{"temporary"}},
+		{Name: "Loner", EntityType: "note"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Scratch", To: "User Preferences", RelationType: "about"}})
+	assert.NoError(t, err)
+	result, err := db.PinEntities(ctx, []string{"User Preferences"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User Preferences"}, result.Changed)
+}
+
+func TestPinEntities(t *testing.T) {
+	db := newImportTestDB(t)
+	seedPins(t, db)
+	ctx := context.Background()
+
+	result, err := db.PinEntities(ctx, []string{"User Preferences", "Loner"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, result.Changed)
+	assert.Equal(t, []string{"User Preferences"}, result.Unchanged)
+
+	graph, err := db.OpenNodes(ctx, []string{"User Preferences", "Scratch"})
+	assert.NoError(t, err)
+	pinned := map[string]bool{}
+	for _, entity := range graph.Entities {
+		pinned[entity.Name] = entity.Pinned
+	}
+	assert.Equal(t, map[string]bool{"User Preferences": true, "Scratch": false}, pinned)
+
+	result, err = db.UnpinEntities(ctx, []string{"Loner", "Scratch"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, result.Changed)
+	assert.Equal(t, []string{"Scratch"}, result.Unchanged)
+
+	// Nothing is pinned when an entity is missing
+	_, err = db.PinEntities(ctx, []string{"Scratch", "Missing"})
+	var notFound *EntityNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "Missing", notFound.Name)
+	graph, err = db.OpenNodes(ctx, []string{"Scratch"})
+	assert.NoError(t, err)
+	assert.False(t, graph.Entities[0].Pinned)
+
+	page, err := db.History(ctx, HistoryFilter{EntityName: "Loner"})
+	assert.NoError(t, err)
+	var operations []string
+	for _, entry := range page.Entries {
+		operations = append(operations, entry.Operation)
+	}
+	assert.Equal(t, []string{"create_entities", "pin_entities", "unpin_entities"}, operations)
+}
+
+func TestDeleteEntities_Pinned(t *testing.T) {
+	db := newImportTestDB(t)
+	seedPins(t, db)
+	ctx := context.Background()
+
+	preview, err := db.PreviewDeleteEntities(ctx, []string{"User Preferences", "Loner"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User Preferences"}, preview.Protected)
+
+	result, err := db.DeleteEntities(ctx, []string{"User Preferences", "Loner"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.DeletedEntities)
+	assert.Equal(t, []string{"User Preferences"}, result.Protected)
+	assert.Empty(t, result.NotFound)
+
+	reassignment, err := db.DeleteEntityReassigning(ctx, "User Preferences", "Scratch")
+	assert.NoError(t, err)
+	assert.True(t, reassignment.Protected)
+	assert.False(t, reassignment.Deleted)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2)
+	assert.Len(t, graph.Relations, 1)
+
+	result, err = db.DeleteEntities(WithForceDelete(ctx), []string{"User Preferences"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.DeletedEntities)
+	assert.Empty(t, result.Protected)
+}
+
+func TestFindOrphans_Pinned(t *testing.T) {
+	db := newImportTestDB(t)
+	seedPins(t, db)
+	ctx := context.Background()
+	_, err := db.PinEntities(ctx, []string{"Loner"})
+	assert.NoError(t, err)
+
+	report, err := db.FindOrphans(ctx, OrphanOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loner"}, orphanNames(report))
+	assert.True(t, report.Orphans[0].Pinned)
+	assert.Equal(t, []string{"Loner"}, report.Protected)
+	assert.False(t, report.Deleted)
+
+	report, err = db.FindOrphans(WithForceDelete(ctx), OrphanOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Protected)
+	assert.True(t, report.Deleted)
+}
+
+func TestClearGraph_Pinned(t *testing.T) {
+	db := newImportTestDB(t)
+	seedPins(t, db)
+	ctx := context.Background()
+
+	report, err := db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User Preferences"}, report.Protected)
+	assert.EqualValues(t, 2, report.Entities)
+	assert.EqualValues(t, 1, report.Relations)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+	assert.Equal(t, []string{"prefers tabs"}, graph.Entities[0].Observations)
+	assert.True(t, graph.Entities[0].Pinned)
+
+	results, err := db.SearchNodesFTS(ctx, "tabs", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, results.Entities, 1, "the pinned entity is still indexed")
+
+	report, err = db.ClearGraph(WithForceDelete(ctx))
+	assert.NoError(t, err)
+	assert.Empty(t, report.Protected)
+	assert.EqualValues(t, 1, report.Entities)
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// insertObservationSQL stores an observation with its writer, session label and
+// expiry; empty ones are stored as NULL
+const insertObservationSQL = "INSERT INTO observations (entity_id, content, written_by, session, expires_at) VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))"
+
+type writerKey struct{}
+
+// WithWriter returns ctx carrying the identity, such as an MCP client name, recorded
+// as the writer of observations stored with it
+func WithWriter(ctx context.Context, writer string) context.Context {
+	return context.WithValue(ctx, writerKey{}, writer)
+}
+
+// writerFrom returns the writer set by WithWriter, or "" if unknown
+func writerFrom(ctx context.Context) string {
+	writer, _ := ctx.Value(writerKey{}).(string)
+	return writer
+}
+
+// EntityMetadata summarizes who has written an entity's current observations
+type EntityMetadata struct {
+	// Contributors is the number of distinct known writers
+	Contributors int `json:"contributors"`
+	// LastWriter wrote the most recent observation; empty if it was stored without one
+	LastWriter string `json:"lastWriter,omitempty"`
+	// LastWriteAt is when the most recent observation was stored (RFC 3339, UTC)
+	LastWriteAt string `json:"lastWriteAt,omitempty"`
+}
+
+// EntityMetadata returns the writer aggregates of the named entities, keyed by name.
+// They are computed from the observations at query time, so deleting observations
+// is reflected immediately. Unknown names are left out.
+func (db *DB) EntityMetadata(ctx context.Context, names []string) (map[string]EntityMetadata, error) {
+	result := make(map[string]EntityMetadata, len(names))
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	scope, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks(names, maxListValues-1) {
+		list, args := stringList(chunk)
+		rows, err := db.reader.QueryContext(ctx, `
+			SELECT
+				e.name,
+				(SELECT COUNT(DISTINCT written_by) FROM observations WHERE entity_id = e.id),
+				latest.written_by,
+				strftime('%Y-%m-%dT%H:%M:%SZ', latest.created_at)
+			FROM entities e
+			LEFT JOIN observations latest ON latest.id = (
+				SELECT id FROM observations WHERE entity_id = e.id
+				ORDER BY created_at DESC, id DESC LIMIT 1
+			)
+			WHERE e.graph_id = ? AND e.name IN `+list, append([]any{scope}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name string
+			var meta EntityMetadata
+			var lastWriter, lastWriteAt sql.NullString
+			if err := rows.Scan(&name, &meta.Contributors, &lastWriter, &lastWriteAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			meta.LastWriter = lastWriter.String
+			meta.LastWriteAt = lastWriteAt.String
+			result[name] = meta
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// addColumnIfMissing adds a column to a table created by an earlier version
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT 1 FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityMetadata(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	planner := WithWriter(ctx, "planner-agent")
+	reviewer := WithWriter(ctx, "reviewer-agent")
+
+	metadata := func(name string) EntityMetadata {
+		t.Helper()
+		meta, err := db.EntityMetadata(ctx, []string{name})
+		assert.NoError(t, err)
+		return meta[name]
+	}
+	add := func(ctx context.Context, content string) {
+		t.Helper()
+		_, err := db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Plan", Contents: []string{content}}})
+		assert.NoError(t, err)
+	}
+
+	_, err := db.CreateEntities(planner, []EntityWithObservations{
+		{Name: "Plan", EntityType: "doc", Observations: []string{"drafted"}},
+		{Name: "Empty", EntityType: "doc"},
+	})
+	assert.NoError(t, err)
+	meta := metadata("Plan")
+	assert.Equal(t, 1, meta.Contributors)
+	assert.Equal(t, "planner-agent", meta.LastWriter)
+	_, err = time.Parse(time.RFC3339, meta.LastWriteAt)
+	assert.NoError(t, err)
+
+	add(reviewer, "reviewed")
+	add(planner, "revised")
+	meta = metadata("Plan")
+	assert.Equal(t, 2, meta.Contributors)
+	assert.Equal(t, "planner-agent", meta.LastWriter)
+
+	// Deletes are reflected: the last writer falls back to the newest remaining observation
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Plan", Observations: []string{"revised"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "reviewer-agent", metadata("Plan").LastWriter)
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Plan", Observations: []string{"reviewed"}}})
+	assert.NoError(t, err)
+	meta = metadata("Plan")
+	assert.Equal(t, 1, meta.Contributors)
+	assert.Equal(t, "planner-agent", meta.LastWriter)
+
+	// Writes without an identity are not counted as a contributor
+	add(ctx, "anonymous note")
+	meta = metadata("Plan")
+	assert.Equal(t, 1, meta.Contributors)
+	assert.Empty(t, meta.LastWriter)
+
+	all, err := db.EntityMetadata(ctx, []string{"Empty", "Missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]EntityMetadata{"Empty": {}}, all)
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Reassignment reports the relations of a deleted entity handed to its successor
+type Reassignment struct {
+	Entity    string `json:"entity"`
+	Successor string `json:"successor"`
+	// Moved counts the relations now attached to the successor
+	Moved int `json:"moved"`
+	// Dropped counts the relations deleted instead: those duplicating one the
+	// successor already has, and those between the entity and its successor
+	Dropped int `json:"dropped"`
+	// Deleted is false when the entity didn't exist or is protected
+	Deleted bool `json:"deleted"`
+	// Protected is set when the entity is pinned and was kept, with its relations
+	Protected bool `json:"protected,omitempty"`
+}
+
+// DeleteEntityReassigning deletes an entity after pointing its relations at
+// successor, in one transaction. A relation that would duplicate one of the
+// successor's, or connect the successor to itself, is dropped. The successor must
+// exist and differ from the entity; a missing entity is not an error. Under
+// SetSoftDelete the entity goes to the trash. A pinned entity is kept, as by
+// DeleteEntities.
+func (db *DB) DeleteEntityReassigning(ctx context.Context, name, successor string) (*Reassignment, error) {
+	return retryWriteResult(ctx, db, func() (*Reassignment, error) {
+		return db.deleteEntityReassigning(ctx, name, successor, false)
+	})
+}
+
+// PreviewEntityReassigning reports what DeleteEntityReassigning would do, running
+// it in a transaction that is rolled back. Deleted reports whether the entity would
+// be deleted.
+func (db *DB) PreviewEntityReassigning(ctx context.Context, name, successor string) (*Reassignment, error) {
+	return retryWriteResult(ctx, db, func() (*Reassignment, error) {
+		return db.deleteEntityReassigning(ctx, name, successor, true)
+	})
+}
+
+// deleteEntityReassigning makes one attempt at DeleteEntityReassigning, rolling it
+// back when dryRun is set
+func (db *DB) deleteEntityReassigning(ctx context.Context, name, successor string, dryRun bool) (*Reassignment, error) {
+	if name == successor {
+		return nil, fmt.Errorf("cannot reassign the relations of %s to itself", name)
+	}
+	start := time.Now()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &Reassignment{Entity: name, Successor: successor}
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	const lookup = "SELECT id FROM entities WHERE graph_id = ? AND name = ?"
+	var successorID int64
+	err = tx.QueryRowContext(ctx, lookup, graph, successor).Scan(&successorID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("successor entity with name %s not found", successor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, lookup, graph, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	protected, err := protectedAmong(ctx, tx, graph, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(protected) > 0 {
+		result.Protected = true
+		return result, nil
+	}
+
+	countRelations := func() (int, error) {
+		var n int
+		err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM relations WHERE from_entity_id = ? OR to_entity_id = ?", id, id,
+		).Scan(&n)
+		return n, err
+	}
+	total, err := countRelations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Relations with the successor would become self-relations
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM relations
+		WHERE (from_entity_id = ? AND to_entity_id = ?) OR (from_entity_id = ? AND to_entity_id = ?)`,
+		id, successorID, successorID, id)
+	if err != nil {
+		return nil, err
+	}
+	withSuccessor, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	// Ones the successor already has stay behind and go with the entity
+	for _, stmt := range []string{
+		"UPDATE OR IGNORE relations SET from_entity_id = ? WHERE from_entity_id = ?",
+		"UPDATE OR IGNORE relations SET to_entity_id = ? WHERE to_entity_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, successorID, id); err != nil {
+			return nil, err
+		}
+	}
+	duplicates, err := countRelations()
+	if err != nil {
+		return nil, err
+	}
+	result.Dropped = duplicates + int(withSuccessor)
+	result.Moved = total - result.Dropped
+
+	if db.softDelete {
+		// The relations left behind go to the trash with it
+		if err := trashEntityTx(ctx, tx, graph, id); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM observations WHERE entity_id = ?", id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM entities WHERE id = ?", id); err != nil {
+			return nil, err
+		}
+	}
+	summary := fmt.Sprintf("deleted %s, moving %d relations to %s", name, result.Moved, successor)
+	if err := db.auditTx(ctx, tx, graph, "delete_entity_reassigning", []string{name, successor}, summary); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		result.Deleted = true
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Deleted = true
+
+	db.logger.Info("entity deleted with relations reassigned",
+		slog.String("entity", name),
+		slog.String("successor", successor),
+		slog.Int("moved", result.Moved),
+		slog.Int("dropped", result.Dropped),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return result, nil
+}
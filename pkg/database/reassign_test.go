@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteEntityReassigning(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "OldAuthService", EntityType: "service", Observations: []string{"deprecated"}},
+		{Name: "AuthService", EntityType: "service"},
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Billing", EntityType: "service"},
+		{Name: "Ops", EntityType: "team"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Gateway", To: "OldAuthService", RelationType: "calls"},
+		{From: "OldAuthService", To: "Billing", RelationType: "calls"},
+		{From: "Ops", To: "OldAuthService", RelationType: "owns"},
+		// The successor already has these two
+		{From: "Ops", To: "AuthService", RelationType: "owns"},
+		{From: "Billing", To: "AuthService", RelationType: "calls"},
+		{From: "Billing", To: "OldAuthService", RelationType: "calls"},
+		// Would become a self-relation
+		{From: "OldAuthService", To: "AuthService", RelationType: "replaced_by"},
+	})
+	assert.NoError(t, err)
+
+	// The preview matches the real run and changes nothing
+	before, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	preview, err := db.PreviewEntityReassigning(ctx, "OldAuthService", "AuthService")
+	assert.NoError(t, err)
+	after, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	result, err := db.DeleteEntityReassigning(ctx, "OldAuthService", "AuthService")
+	assert.NoError(t, err)
+	assert.Equal(t, preview, result)
+	assert.Equal(t, &Reassignment{Entity: "OldAuthService", Successor: "AuthService", Moved: 2, Dropped: 3, Deleted: true}, result)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []RelationDTO{
+		{From: "Gateway", To: "AuthService", RelationType: "calls"},
+		{From: "AuthService", To: "Billing", RelationType: "calls"},
+		{From: "Ops", To: "AuthService", RelationType: "owns"},
+		{From: "Billing", To: "AuthService", RelationType: "calls"},
+	}, graph.Relations)
+	assert.Len(t, graph.Entities, 4)
+	var observations int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM observations").Scan(&observations))
+	assert.Zero(t, observations)
+
+	// A missing entity is nothing to do; a missing or identical successor is an error
+	result, err = db.DeleteEntityReassigning(ctx, "OldAuthService", "AuthService")
+	assert.NoError(t, err)
+	assert.False(t, result.Deleted)
+	_, err = db.DeleteEntityReassigning(ctx, "Gateway", "Missing")
+	assert.Error(t, err)
+	_, err = db.DeleteEntityReassigning(ctx, "Gateway", "Gateway")
+	assert.Error(t, err)
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 4)
+	assert.Len(t, graph.Relations, 4)
+}
+
+func TestDeleteEntityReassigning_SelfRelation(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Old", EntityType: "node"},
+		{Name: "New", EntityType: "node"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Old", To: "Old", RelationType: "retries"}})
+	assert.NoError(t, err)
+
+	// The entity's own self-relation becomes the successor's
+	result, err := db.DeleteEntityReassigning(ctx, "Old", "New")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Moved)
+	assert.Zero(t, result.Dropped)
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{{From: "New", To: "New", RelationType: "retries"}}, graph.Relations)
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GraphRecordVersion is the version of the records ExportJSONL writes
+const GraphRecordVersion = 2
+
+// Kinds of graph records
+const (
+	RecordEntity       = "entity"
+	RecordRelation     = "relation"
+	RecordTypeMetadata = "typeMetadata"
+)
+
+// graphRecord is one line of the JSONL graph format. Versioned records carry "v" and
+// "kind". Records without them are in the reference format, which names the kind
+// "type" and lists observations as plain strings; they are read as version 0. Version 2
+// added the tags, attributes, pin and aliases of entities and the confidence and note of
+// relations.
+type graphRecord struct {
+	V            int                 `json:"v,omitempty"`
+	Kind         string              `json:"kind,omitempty"`
+	Type         string              `json:"type,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	EntityType   string              `json:"entityType,omitempty"`
+	Observations []recordObservation `json:"observations,omitempty"`
+	Tags         []string            `json:"tags,omitempty"`
+	Attributes   map[string]any      `json:"attributes,omitempty"`
+	Pinned       bool                `json:"pinned,omitempty"`
+	Aliases      []string            `json:"aliases,omitempty"`
+	From         string              `json:"from,omitempty"`
+	To           string              `json:"to,omitempty"`
+	RelationType string              `json:"relationType,omitempty"`
+	Confidence   float64             `json:"confidence,omitempty"`
+	Note         string              `json:"note,omitempty"`
+	Metadata     map[string]string   `json:"metadata,omitempty"`
+}
+
+// recordObservation is an observation of an entity record: a plain string, or an
+// object carrying its provenance
+type recordObservation struct {
+	Content string `json:"content"`
+	// WrittenBy and CreatedAt (RFC 3339) are kept on import of versioned records
+	WrittenBy string `json:"writtenBy,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+
+	unknown []string // fields of the object form not understood
+}
+
+func (o *recordObservation) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*o = recordObservation{}
+		return json.Unmarshal(data, &o.Content)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	type plain recordObservation
+	if err := json.Unmarshal(data, (*plain)(o)); err != nil {
+		return err
+	}
+	o.unknown = unknownFields(fields, observationFields)
+	return nil
+}
+
+// recordFields are the fields understood on each kind of record
+var recordFields = map[string]map[string]bool{
+	RecordEntity:       {"v": true, "kind": true, "type": true, "name": true, "entityType": true, "observations": true, "tags": true, "attributes": true, "pinned": true, "aliases": true},
+	RecordRelation:     {"v": true, "kind": true, "type": true, "from": true, "to": true, "relationType": true, "confidence": true, "note": true},
+	RecordTypeMetadata: {"v": true, "kind": true, "entityType": true, "metadata": true},
+}
+
+var observationFields = map[string]bool{"content": true, "writtenBy": true, "createdAt": true}
+
+// parseGraphLine decodes one non-blank line. Reading is forward-tolerant: records of a
+// newer version are read as far as they are understood, and unknown fields and kinds
+// of versioned records are ignored, each with a warning. A nil record means the line
+// was skipped.
+func parseGraphLine(line string) (*graphRecord, []string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, nil, err
+	}
+	var rec graphRecord
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	if rec.Kind == "" {
+		if rec.V != 0 {
+			return nil, nil, errors.New("versioned record requires kind")
+		}
+		rec.Kind = rec.Type
+	} else if rec.V > GraphRecordVersion {
+		warnings = append(warnings, fmt.Sprintf("record version %d is newer than %d; only known fields are read", rec.V, GraphRecordVersion))
+	}
+	rec.Type = ""
+
+	known, ok := recordFields[rec.Kind]
+	if !ok || (rec.V == 0 && rec.Kind == RecordTypeMetadata) {
+		if rec.V == 0 {
+			return nil, nil, fmt.Errorf("unknown type %q", rec.Kind)
+		}
+		return nil, append(warnings, fmt.Sprintf("unknown record kind %q skipped", rec.Kind)), nil
+	}
+	unknown := unknownFields(fields, known)
+	for _, o := range rec.Observations {
+		for _, f := range o.unknown {
+			unknown = append(unknown, "observations."+f)
+		}
+	}
+	if len(unknown) > 0 {
+		quoted := dedupe(unknown)
+		for i, name := range quoted {
+			quoted[i] = fmt.Sprintf("%q", name)
+		}
+		warnings = append(warnings, "unknown fields ignored: "+strings.Join(quoted, ", "))
+	}
+
+	switch rec.Kind {
+	case RecordEntity:
+		if rec.Name == "" || rec.EntityType == "" {
+			return nil, nil, errors.New("entity requires name and entityType")
+		}
+		for i, o := range rec.Observations {
+			if o.CreatedAt == "" {
+				continue
+			}
+			if _, err := time.Parse(time.RFC3339, o.CreatedAt); err != nil {
+				return nil, nil, fmt.Errorf("observations[%d].createdAt: %w", i, err)
+			}
+		}
+	case RecordRelation:
+		if rec.From == "" || rec.To == "" || rec.RelationType == "" {
+			return nil, nil, errors.New("relation requires from, to and relationType")
+		}
+	case RecordTypeMetadata:
+		if rec.EntityType == "" {
+			return nil, nil, errors.New("typeMetadata requires entityType")
+		}
+	}
+	return &rec, warnings, nil
+}
+
+// unknownFields returns the names in fields that are not known, sorted
+func unknownFields(fields map[string]json.RawMessage, known map[string]bool) []string {
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func dedupe(values []string) []string {
+	return appendMissing(nil, values)
+}
+
+// readGraphRecords reads the records of a JSONL graph line by line with parse, such
+// as parseGraphLine, so only the decoded records, not the raw input, are held in
+// memory. Parse failures are reported as an *ImportLineError.
+func readGraphRecords(r io.Reader, parse func(string) (*graphRecord, []string, error), warnings *importWarnings) ([]graphRecord, int, error) {
+	var records []graphRecord
+	types := interner{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportLineBytes)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rec, msgs, err := parse(line)
+		if err != nil {
+			return nil, lineNo, &ImportLineError{Line: lineNo, Err: err}
+		}
+		warnings.add(lineNo, msgs)
+		if rec != nil {
+			rec.EntityType = types.intern(rec.EntityType)
+			rec.RelationType = types.intern(rec.RelationType)
+			records = append(records, *rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, lineNo, &ImportLineError{Line: lineNo + 1, Err: fmt.Errorf("line exceeds %d bytes", MaxImportLineBytes)}
+		}
+		return nil, lineNo, err
+	}
+	return records, lineNo, nil
+}
+
+// recordsToGraph returns the entities and relations of records
+func recordsToGraph(records []graphRecord) *KnowledgeGraph {
+	graph := &KnowledgeGraph{Entities: []EntityWithObservations{}, Relations: []RelationDTO{}}
+	for _, rec := range records {
+		switch rec.Kind {
+		case RecordEntity:
+			observations := make([]string, len(rec.Observations))
+			for i, o := range rec.Observations {
+				observations[i] = o.Content
+			}
+			graph.Entities = append(graph.Entities, EntityWithObservations{
+				Name:         rec.Name,
+				EntityType:   rec.EntityType,
+				Observations: observations,
+				Tags:         rec.Tags,
+				Attributes:   rec.Attributes,
+				Pinned:       rec.Pinned,
+				Aliases:      rec.Aliases,
+			})
+		case RecordRelation:
+			graph.Relations = append(graph.Relations, RelationDTO{
+				From:         rec.From,
+				To:           rec.To,
+				RelationType: rec.RelationType,
+				Confidence:   rec.Confidence,
+				Note:         rec.Note,
+			})
+		}
+	}
+	return graph
+}
+
+// graphToRecords returns graph as reference-format records, keeping the tags,
+// attributes, pins, aliases and relation properties it carries
+func graphToRecords(graph *KnowledgeGraph) []graphRecord {
+	records := make([]graphRecord, 0, len(graph.Entities)+len(graph.Relations))
+	for _, e := range graph.Entities {
+		observations := make([]recordObservation, len(e.Observations))
+		for i, content := range e.Observations {
+			observations[i] = recordObservation{Content: content}
+		}
+		records = append(records, graphRecord{
+			Kind:         RecordEntity,
+			Name:         e.Name,
+			EntityType:   e.EntityType,
+			Observations: observations,
+			Tags:         e.Tags,
+			Attributes:   e.Attributes,
+			Pinned:       e.Pinned,
+			Aliases:      e.Aliases,
+		})
+	}
+	for _, r := range graph.Relations {
+		records = append(records, graphRecord{
+			Kind:         RecordRelation,
+			From:         r.From,
+			To:           r.To,
+			RelationType: r.RelationType,
+			Confidence:   r.Confidence,
+			Note:         r.Note,
+		})
+	}
+	return records
+}
+
+// maxImportWarnings bounds the distinct warnings kept for one import
+const maxImportWarnings = 100
+
+// importWarnings collects parse warnings. A repeated warning is reported once, at
+// its first line, with the number of further lines it occurred on.
+type importWarnings struct {
+	order []string
+	first map[string]int
+	more  map[string]int
+}
+
+func (w *importWarnings) add(line int, msgs []string) {
+	if w.first == nil {
+		w.first, w.more = map[string]int{}, map[string]int{}
+	}
+	for _, msg := range msgs {
+		if _, ok := w.first[msg]; ok {
+			w.more[msg]++
+			continue
+		}
+		if len(w.order) == maxImportWarnings {
+			continue
+		}
+		w.order = append(w.order, msg)
+		w.first[msg] = line
+	}
+}
+
+// list returns the warnings, or nil if there were none
+func (w *importWarnings) list() []string {
+	var out []string
+	for _, msg := range w.order {
+		s := fmt.Sprintf("line %d: %s", w.first[msg], msg)
+		if n := w.more[msg]; n > 0 {
+			s += fmt.Sprintf(" (and %d more lines)", n)
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reasons CreateRelations gives for a relation it skipped
+const (
+	// SkipMissingFrom: the source entity doesn't exist (reported when neither exists)
+	SkipMissingFrom = "missing_from"
+	// SkipMissingTo: the target entity doesn't exist
+	SkipMissingTo = "missing_to"
+	// SkipDuplicate: the relation exists, or was created earlier in the call
+	SkipDuplicate = "duplicate"
+)
+
+// SkippedRelation is a relation of a create_relations call that wasn't created
+type SkippedRelation struct {
+	// Index is the position of the relation in the call
+	Index    int         `json:"index"`
+	Relation RelationDTO `json:"relation"`
+	Reason   string      `json:"reason"`
+}
+
+// RelationCreationResult reports what CreateRelations created and what it skipped
+type RelationCreationResult struct {
+	Relations []RelationDTO     `json:"relations"`
+	Skipped   []SkippedRelation `json:"skipped"`
+	// Updated lists, with RelationOptions.UpdateExisting, the relations that
+	// already existed and had their properties updated, as they were left
+	Updated []RelationDTO `json:"updated,omitempty"`
+}
+
+// RelationOptions change how CreateRelationsWithOptions treats relations it can't
+// create
+type RelationOptions struct {
+	// Strict fails the call, creating nothing, if any relation names an entity that
+	// doesn't exist, with a *MissingEndpointsError listing every such relation
+	Strict bool
+	// UpdateExisting sets the properties given for a relation that already exists,
+	// keeping those not given, rather than skipping it
+	UpdateExisting bool
+}
+
+// NewRelationCreationResult returns an empty RelationCreationResult
+func NewRelationCreationResult() *RelationCreationResult {
+	return &RelationCreationResult{Relations: []RelationDTO{}, Skipped: []SkippedRelation{}}
+}
+
+// Skip records that the relation at index wasn't created, and why
+func (r *RelationCreationResult) Skip(index int, rel RelationDTO, reason string) {
+	r.Skipped = append(r.Skipped, SkippedRelation{Index: index, Relation: rel, Reason: reason})
+}
+
+// MissingEndpoints returns the skipped relations that name an entity that doesn't
+// exist
+func (r *RelationCreationResult) MissingEndpoints() []SkippedRelation {
+	var missing []SkippedRelation
+	for _, s := range r.Skipped {
+		if s.Reason != SkipDuplicate {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// MissingEndpoint returns the reason to skip a relation given whether its source and
+// target exist, or "" if both do
+func MissingEndpoint(fromExists, toExists bool) string {
+	switch {
+	case !fromExists:
+		return SkipMissingFrom
+	case !toExists:
+		return SkipMissingTo
+	}
+	return ""
+}
+
+// MissingEndpointsError rejects a strict CreateRelations call in which any relation
+// names an entity that doesn't exist; nothing is created
+type MissingEndpointsError struct {
+	Skipped []SkippedRelation
+}
+
+func (e *MissingEndpointsError) Error() string {
+	parts := make([]string, len(e.Skipped))
+	for i, s := range e.Skipped {
+		parts[i] = fmt.Sprintf("relations[%d] %s -%s-> %s: %s", s.Index, s.Relation.From, s.Relation.RelationType, s.Relation.To, s.Reason)
+	}
+	return "relation endpoints missing: " + strings.Join(parts, "; ")
+}
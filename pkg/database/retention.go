@@ -0,0 +1,312 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention actions
+const (
+	// RetentionPurge deletes the expired observations
+	RetentionPurge = "purge"
+	// RetentionArchive moves the expired observations to the archived_observations table
+	RetentionArchive = "archive"
+)
+
+// retentionBatchSize bounds the observations removed per transaction, so a policy
+// catching up on years of data doesn't hold the write lock for long
+const retentionBatchSize = 500
+
+// lastRetentionKey stores the report of the last applied retention run in the meta table
+const lastRetentionKey = "retention.last"
+
+// RetentionAge is the maxAge of a retention rule. In JSON it is a Go duration such as
+// "720h", or a number of days such as "365d".
+type RetentionAge time.Duration
+
+// UnmarshalJSON parses a duration or a number of days
+func (a *RetentionAge) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("maxAge must be a string such as \"365d\" or \"12h\"")
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return fmt.Errorf("invalid maxAge %q", s)
+		}
+		*a = RetentionAge(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid maxAge %q", s)
+	}
+	*a = RetentionAge(d)
+	return nil
+}
+
+// MarshalJSON writes whole days as "<n>d" and other ages as a Go duration
+func (a RetentionAge) MarshalJSON() ([]byte, error) {
+	d := time.Duration(a)
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return json.Marshal(fmt.Sprintf("%dd", d/(24*time.Hour)))
+	}
+	return json.Marshal(d.String())
+}
+
+// RetentionRule expires the observations of entities of one type once they are
+// older than MaxAge
+type RetentionRule struct {
+	EntityType string       `json:"entityType"`
+	MaxAge     RetentionAge `json:"maxAge"`
+	Action     string       `json:"action"`
+}
+
+// RetentionPolicy is the set of retention rules. Observations of entity types no
+// rule names, and of pinned entities, are kept indefinitely.
+type RetentionPolicy struct {
+	Rules []RetentionRule `json:"rules"`
+	// Pinned lists entities exempt from every rule
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+// ParseRetentionPolicy reads a policy from JSON such as
+// {"rules": [{"entityType": "conversation", "maxAge": "365d", "action": "purge"}]}
+func ParseRetentionPolicy(data []byte) (*RetentionPolicy, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var policy RetentionPolicy
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid retention policy: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, rule := range policy.Rules {
+		if strings.TrimSpace(rule.EntityType) == "" {
+			return nil, fmt.Errorf("invalid retention policy: rule without entityType")
+		}
+		if seen[rule.EntityType] {
+			return nil, fmt.Errorf("invalid retention policy: more than one rule for %q", rule.EntityType)
+		}
+		seen[rule.EntityType] = true
+		if rule.MaxAge <= 0 {
+			return nil, fmt.Errorf("invalid retention policy for %q: maxAge must be positive", rule.EntityType)
+		}
+		if rule.Action != RetentionPurge && rule.Action != RetentionArchive {
+			return nil, fmt.Errorf("invalid retention policy for %q: action must be %s or %s", rule.EntityType, RetentionPurge, RetentionArchive)
+		}
+	}
+	return &policy, nil
+}
+
+// SetRetentionPolicy sets the policy ApplyRetention enforces (nil = none)
+func (db *DB) SetRetentionPolicy(policy *RetentionPolicy) {
+	if policy == nil {
+		db.retentionPolicy = nil
+		return
+	}
+	db.retentionPolicy = &RetentionPolicy{Rules: slices.Clone(policy.Rules), Pinned: slices.Clone(policy.Pinned)}
+}
+
+// RetentionPolicy returns the policy ApplyRetention enforces, or nil
+func (db *DB) RetentionPolicy() *RetentionPolicy {
+	if db.retentionPolicy == nil {
+		return nil
+	}
+	return &RetentionPolicy{Rules: slices.Clone(db.retentionPolicy.Rules), Pinned: slices.Clone(db.retentionPolicy.Pinned)}
+}
+
+// RetentionRuleReport is what one rule selected
+type RetentionRuleReport struct {
+	RetentionRule
+	// Cutoff is the creation time before which observations expire
+	Cutoff time.Time `json:"cutoff"`
+	// Observations and Entities count the expired observations and the entities
+	// they belong to: removed on a real run, or that would be in a dry run
+	Observations int `json:"observations"`
+	Entities     int `json:"entities"`
+	// PinnedObservations counts expired observations kept because their entity is pinned
+	PinnedObservations int `json:"pinnedObservations"`
+}
+
+// RetentionReport summarizes a retention run
+type RetentionReport struct {
+	DryRun      bool                  `json:"dryRun"`
+	EvaluatedAt time.Time             `json:"evaluatedAt"`
+	Rules       []RetentionRuleReport `json:"rules"`
+	Purged      int                   `json:"purged"`
+	Archived    int                   `json:"archived"`
+}
+
+// retentionSelect is the condition selecting the expired observations o of entities
+// e of one type. It walks idx_entities_type and the (entity_id, created_at) index,
+// so the observations table is never scanned.
+const retentionSelect = `
+	FROM entities e INDEXED BY idx_entities_type
+	JOIN observations o INDEXED BY idx_observations_entity_created ON o.entity_id = e.id
+	WHERE e.entity_type = ? AND o.created_at < ?`
+
+// ApplyRetention enforces the retention policy as of now. Each rule removes, in
+// batched transactions, the observations of its entity type created before
+// now - maxAge, purging or archiving them; entities the policy lists as pinned, or
+// pinned with PinEntities, are skipped. Entities
+// themselves are kept. With dryRun nothing changes and the report shows what would
+// be removed. The report of a real run is stored and returned by LastRetentionRun.
+func (db *DB) ApplyRetention(ctx context.Context, now time.Time, dryRun bool) (*RetentionReport, error) {
+	start := time.Now()
+	policy := db.RetentionPolicy()
+	report := &RetentionReport{DryRun: dryRun, EvaluatedAt: now.UTC(), Rules: []RetentionRuleReport{}}
+	if policy == nil {
+		return report, nil
+	}
+	pinnedList, pinnedArgs := stringList(policy.Pinned)
+
+	for _, rule := range policy.Rules {
+		ruleReport := RetentionRuleReport{
+			RetentionRule: rule,
+			Cutoff:        now.UTC().Add(-time.Duration(rule.MaxAge)).Truncate(time.Second),
+		}
+		args := []any{rule.EntityType, ruleReport.Cutoff.Format(sqliteTimeLayout)}
+		// Entities pinned with PinEntities are exempt as well as those the policy lists
+		pinned := "e.pinned = 1"
+		if len(policy.Pinned) > 0 {
+			pinned = "(e.pinned = 1 OR e.name IN " + pinnedList + ")"
+			args = append(args, pinnedArgs...)
+		}
+		notPinned := " AND NOT " + pinned
+		err := db.conn.QueryRowContext(ctx,
+			"SELECT COUNT(*)"+retentionSelect+" AND "+pinned, args...,
+		).Scan(&ruleReport.PinnedObservations)
+		if err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			err := db.conn.QueryRowContext(ctx,
+				"SELECT COUNT(*), COUNT(DISTINCT e.id)"+retentionSelect+notPinned, args...,
+			).Scan(&ruleReport.Observations, &ruleReport.Entities)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			entities := map[int64]bool{}
+			for {
+				n, err := retryWriteResult(ctx, db, func() (int, error) {
+					return db.expireBatch(ctx, rule.Action, retentionSelect+notPinned, args, entities)
+				})
+				if err != nil {
+					return nil, cancelledOr(ctx, err, "apply retention", ruleReport.Observations, 0)
+				}
+				ruleReport.Observations += n
+				if n < retentionBatchSize {
+					break
+				}
+			}
+			ruleReport.Entities = len(entities)
+		}
+		if rule.Action == RetentionArchive {
+			report.Archived += ruleReport.Observations
+		} else {
+			report.Purged += ruleReport.Observations
+		}
+		report.Rules = append(report.Rules, ruleReport)
+	}
+
+	if !dryRun {
+		data, err := json.Marshal(report)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.SetMeta(ctx, lastRetentionKey, string(data)); err != nil {
+			return nil, fmt.Errorf("failed to record retention run: %w", err)
+		}
+	}
+	db.logger.Info("retention policy applied",
+		slog.Bool("dry_run", dryRun),
+		slog.Int("rules", len(report.Rules)),
+		slog.Int("purged", report.Purged),
+		slog.Int("archived", report.Archived),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return report, nil
+}
+
+// expireBatch removes up to retentionBatchSize observations matching selection in one
+// transaction, archiving them first for RetentionArchive, and adds their entities
+// to entities
+func (db *DB) expireBatch(ctx context.Context, action, selection string, args []any, entities map[int64]bool) (int, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT o.id, e.id"+selection+fmt.Sprintf(" LIMIT %d", retentionBatchSize), args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id, entityID int64
+		if err := rows.Scan(&id, &entityID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		entities[entityID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	list, idArgs := inList(ids)
+	if action == RetentionArchive {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO archived_observations (graph_id, entity_name, entity_type, content, created_at, written_by, session)
+			SELECT e.graph_id, e.name, e.entity_type, o.content, o.created_at, o.written_by, o.session
+			FROM observations o JOIN entities e ON e.id = o.entity_id
+			WHERE o.id IN `+list+` ORDER BY o.id`, idArgs...); err != nil {
+			return 0, fmt.Errorf("failed to archive observations: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM observations WHERE id IN "+list, idArgs...); err != nil {
+		return 0, err
+	}
+	return len(ids), tx.Commit()
+}
+
+// LastRetentionRun returns the report of the last real ApplyRetention run, or nil
+func (db *DB) LastRetentionRun(ctx context.Context) (*RetentionReport, error) {
+	value, ok, err := db.GetMeta(ctx, lastRetentionKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var report RetentionReport
+	if err := json.Unmarshal([]byte(value), &report); err != nil {
+		return nil, fmt.Errorf("invalid stored retention report: %w", err)
+	}
+	return &report, nil
+}
+
+// stringList returns a "(?,?,...)" placeholder list and its args
+func stringList(values []string) (string, []any) {
+	placeholders := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, v := range values {
+		placeholders[i] = "?"
+		args[i] = v
+	}
+	return "(" + strings.Join(placeholders, ",") + ")", args
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	policy, err := ParseRetentionPolicy([]byte(`{
+		"rules": [
+			{"entityType": "conversation", "maxAge": "365d", "action": "purge"},
+			{"entityType": "ticket", "maxAge": "36h", "action": "archive"}
+		],
+		"pinned": ["Kickoff"]
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, RetentionAge(365*24*time.Hour), policy.Rules[0].MaxAge)
+	assert.Equal(t, RetentionAge(36*time.Hour), policy.Rules[1].MaxAge)
+	assert.Equal(t, []string{"Kickoff"}, policy.Pinned)
+
+	data, err := json.Marshal(policy.Rules)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"entityType": "conversation", "maxAge": "365d", "action": "purge"},
+		{"entityType": "ticket", "maxAge": "36h0m0s", "action": "archive"}
+	]`, string(data))
+
+	for _, invalid := range []string{
+		`{"rules": [{"maxAge": "1d", "action": "purge"}]}`,
+		`{"rules": [{"entityType": "a", "maxAge": "1d", "action": "shred"}]}`,
+		`{"rules": [{"entityType": "a", "maxAge": "0s", "action": "purge"}]}`,
+		`{"rules": [{"entityType": "a", "maxAge": "a year", "action": "purge"}]}`,
+		`{"rules": [{"entityType": "a", "maxAge": 5, "action": "purge"}]}`,
+		`{"rules": [{"entityType": "a", "maxAge": "1d", "action": "purge"}, {"entityType": "a", "maxAge": "2d", "action": "purge"}]}`,
+		`{"rules": [], "tags": ["legal"]}`,
+	} {
+		_, err := ParseRetentionPolicy([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+// newRetentionFixture returns a database whose observations are stamped relative to now
+func newRetentionFixture(t *testing.T, now time.Time) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Standup", EntityType: "conversation", Observations: []string{"old standup note", "recent standup note"}},
+		{Name: "Retro", EntityType: "conversation", Observations: []string{"old retro note"}},
+		{Name: "Kickoff", EntityType: "conversation", Observations: []string{"old kickoff note"}},
+		{Name: "Outage", EntityType: "ticket", Observations: []string{"old outage note", "recent outage note"}},
+		{Name: "Alice", EntityType: "person", Observations: []string{"old alice note"}},
+	})
+	assert.NoError(t, err)
+	stamp := func(age time.Duration) string { return now.Add(-age).UTC().Format(sqliteTimeLayout) }
+	_, err = db.conn.ExecContext(ctx,
+		"UPDATE observations SET created_at = CASE WHEN content LIKE 'old %' THEN ? ELSE ? END",
+		stamp(2*time.Hour), stamp(10*time.Minute))
+	assert.NoError(t, err)
+	db.SetRetentionPolicy(&RetentionPolicy{
+		Rules: []RetentionRule{
+			{EntityType: "conversation", MaxAge: RetentionAge(time.Hour), Action: RetentionPurge},
+			{EntityType: "ticket", MaxAge: RetentionAge(time.Hour), Action: RetentionArchive},
+		},
+		Pinned: []string{"Kickoff"},
+	})
+	return db
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	db := newRetentionFixture(t, now)
+	ctx := context.Background()
+
+	observations := func() map[string][]string {
+		graph, err := db.ReadGraph(ctx)
+		assert.NoError(t, err)
+		byName := map[string][]string{}
+		for _, e := range graph.Entities {
+			byName[e.Name] = e.Observations
+		}
+		return byName
+	}
+	before := observations()
+
+	preview, err := db.ApplyRetention(ctx, now, true)
+	assert.NoError(t, err)
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, 2, preview.Purged)
+	assert.Equal(t, 1, preview.Archived)
+	assert.Equal(t, observations(), before)
+	last, err := db.LastRetentionRun(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, last, "dry runs are not recorded")
+
+	report, err := db.ApplyRetention(ctx, now, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []RetentionRuleReport{
+		{
+			RetentionRule:      RetentionRule{EntityType: "conversation", MaxAge: RetentionAge(time.Hour), Action: RetentionPurge},
+			Cutoff:             now.Add(-time.Hour),
+			Observations:       2,
+			Entities:           2,
+			PinnedObservations: 1,
+		},
+		{
+			RetentionRule: RetentionRule{EntityType: "ticket", MaxAge: RetentionAge(time.Hour), Action: RetentionArchive},
+			Cutoff:        now.Add(-time.Hour),
+			Observations:  1,
+			Entities:      1,
+		},
+	}, report.Rules)
+	assert.Equal(t, preview.Rules, report.Rules, "the preview matches the run")
+	assert.Equal(t, 2, report.Purged)
+	assert.Equal(t, 1, report.Archived)
+
+	// Entities stay; only the expired observations of the selected types go
+	assert.Equal(t, map[string][]string{
+		"Standup": {"recent standup note"},
+		"Retro":   {},
+		"Kickoff": {"old kickoff note"},
+		"Outage":  {"recent outage note"},
+		"Alice":   {"old alice note"},
+	}, observations())
+
+	var name, entityType, content, createdAt string
+	err = db.conn.QueryRowContext(ctx,
+		"SELECT entity_name, entity_type, content, created_at FROM archived_observations").Scan(&name, &entityType, &content, &createdAt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Outage", "ticket", "old outage note"}, []string{name, entityType, content})
+	assert.Contains(t, createdAt, "2030-06-01")
+
+	last, err = db.LastRetentionRun(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, report.Rules, last.Rules)
+	assert.Equal(t, now, last.EvaluatedAt)
+
+	// Nothing is left to expire
+	report, err = db.ApplyRetention(ctx, now, false)
+	assert.NoError(t, err)
+	assert.Zero(t, report.Purged+report.Archived)
+
+	// Archived observations are erased with their subject
+	erasure, err := db.EraseSubject(ctx, []string{"outage"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, erasure.ArchivedObservations)
+	assert.True(t, erasure.Verification.Clean, "%+v", erasure.Verification.Remaining)
+}
+
+func TestApplyRetention_PinnedEntities(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	db := newRetentionFixture(t, now)
+	ctx := context.Background()
+
+	_, err := db.PinEntities(ctx, []string{"Retro", "Outage"})
+	assert.NoError(t, err)
+
+	// Entities pinned with PinEntities are exempt like those the policy lists, and
+	// also without a list
+	for _, listed := range [][]string{{"Kickoff"}, nil} {
+		policy := db.RetentionPolicy()
+		policy.Pinned = listed
+		db.SetRetentionPolicy(policy)
+		preview, err := db.ApplyRetention(ctx, now, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 2-len(listed), preview.Rules[0].Observations, "%v", listed)
+		assert.Equal(t, 1+len(listed), preview.Rules[0].PinnedObservations, "%v", listed)
+		assert.Zero(t, preview.Rules[1].Observations, "%v", listed)
+		assert.Equal(t, 1, preview.Rules[1].PinnedObservations, "%v", listed)
+	}
+
+	report, err := db.ApplyRetention(ctx, now, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Purged)
+	assert.Zero(t, report.Archived)
+	graph, err := db.OpenNodes(ctx, []string{"Retro", "Outage", "Kickoff"})
+	assert.NoError(t, err)
+	for _, entity := range graph.Entities {
+		if entity.Name == "Kickoff" {
+			assert.Empty(t, entity.Observations, "no longer listed")
+			continue
+		}
+		assert.Contains(t, entity.Observations[0], "old ", entity.Name)
+	}
+}
+
+func TestApplyRetention_Batches(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	notes := make([]string, 2*retentionBatchSize+10)
+	for i := range notes {
+		notes[i] = fmt.Sprintf("message %d", i)
+	}
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Chat", EntityType: "conversation", Observations: notes},
+	})
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "UPDATE observations SET created_at = ?", now.AddDate(-2, 0, 0).Format(sqliteTimeLayout))
+	assert.NoError(t, err)
+	db.SetRetentionPolicy(&RetentionPolicy{Rules: []RetentionRule{
+		{EntityType: "conversation", MaxAge: RetentionAge(365 * 24 * time.Hour), Action: RetentionPurge},
+	}})
+
+	report, err := db.ApplyRetention(ctx, now, false)
+	assert.NoError(t, err)
+	assert.Equal(t, len(notes), report.Purged)
+	assert.Equal(t, 1, report.Rules[0].Entities)
+	var left int
+	assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM observations").Scan(&left))
+	assert.Zero(t, left)
+}
+
+func TestApplyRetention_UsesIndexes(t *testing.T) {
+	db := newImportTestDB(t)
+	rows, err := db.conn.Query("EXPLAIN QUERY PLAN SELECT o.id, e.id"+retentionSelect+" AND NOT (e.pinned = 1 OR e.name IN (?))",
+		"conversation", "2030-01-01 00:00:00", "Kickoff")
+	assert.NoError(t, err)
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		assert.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	assert.NoError(t, rows.Err())
+	joined := strings.Join(plan, "\n")
+	assert.Contains(t, joined, "SEARCH e USING INDEX idx_entities_type (entity_type=?)")
+	assert.Contains(t, joined, "INDEX idx_observations_entity_created (entity_id=? AND created_at<?)")
+	assert.NotContains(t, joined, "SCAN")
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultWriteAttempts is how many times a write is tried while SQLite reports the
+// database busy or locked
+const DefaultWriteAttempts = 5
+
+// writeRetryBackoff is the wait before the second attempt of a write; it doubles for
+// each later one, and a random part of up to as much again is added
+const writeRetryBackoff = 10 * time.Millisecond
+
+// SetWriteAttempts sets how many times a write is tried while the database is busy
+// or locked (1 = no retries)
+func (db *DB) SetWriteAttempts(attempts int) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	db.writeAttempts = attempts
+}
+
+// isBusy reports whether err is SQLite reporting the database busy or locked. WAL
+// mode returns these despite busy_timeout when another connection commits while a
+// transaction that has already read goes on to write, and the transaction has to
+// start over to see the new data.
+func isBusy(err error) bool {
+	code, ok := errorCode(err)
+	return ok && (code == sqliteBusy || code == sqliteLocked)
+}
+
+// retryWrite runs write, which must leave nothing behind when it fails, such as one
+// transaction, until it doesn't fail with the database busy or locked or the DB's
+// write attempts are used up, backing off between attempts. It returns the last
+// error, or ctx's error when ctx is done during a backoff.
+func (db *DB) retryWrite(ctx context.Context, write func() error) error {
+	backoff := writeRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || !isBusy(err) || attempt >= db.writeAttempts {
+			return err
+		}
+		wait := backoff + rand.N(backoff)
+		db.logger.Debug("database busy, retrying write",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", wait),
+		)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryWriteResult is retryWrite for writes that return a result
+func retryWriteResult[T any](ctx context.Context, db *DB, write func() (T, error)) (T, error) {
+	var result T
+	err := db.retryWrite(ctx, func() error {
+		var err error
+		result, err = write()
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hammerWrites adds observations to the same entity from two DBs open on one file,
+// as two server processes would, and returns the errors
+func hammerWrites(t *testing.T, attempts int) []error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "memory.db")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	var dbs []*DB
+	for i := 0; i < 2; i++ {
+		db, err := NewDBWithLogger(path, logger)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		t.Cleanup(func() { db.Close() })
+		db.SetWriteAttempts(attempts)
+		dbs = append(dbs, db)
+	}
+	ctx := context.Background()
+	_, err := dbs[0].CreateEntities(ctx, []EntityWithObservations{{Name: "Shared", EntityType: "fixture"}})
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for w, db := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				_, err := db.AddObservations(ctx, []ObservationAdditionInput{
+					{EntityName: "Shared", Contents: []string{fmt.Sprintf("writer %d note %d", w, i)}},
+				})
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestRetryWrite_ConcurrentWriters(t *testing.T) {
+	assert.Empty(t, hammerWrites(t, DefaultWriteAttempts))
+}
+
+func TestRetryWrite_StaleSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	other, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer other.Close()
+	ctx := context.Background()
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Shared", EntityType: "fixture"}})
+	assert.NoError(t, err)
+
+	// The first attempt reads, then the other DB commits before it writes
+	attempts := 0
+	write := func() error {
+		attempts++
+		tx, err := db.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM observations").Scan(&count); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			if _, err := other.AddObservations(ctx, []ObservationAdditionInput{
+				{EntityName: "Shared", Contents: []string{fmt.Sprintf("other write after %d observations", count)}},
+			}); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO observations (entity_id, content) SELECT id, ? FROM entities WHERE name = 'Shared'",
+			fmt.Sprintf("after %d observations", count),
+		); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	db.SetWriteAttempts(1)
+	err = db.retryWrite(ctx, write)
+	assert.True(t, isBusy(err), err)
+
+	attempts = 0
+	db.SetWriteAttempts(DefaultWriteAttempts)
+	assert.NoError(t, db.retryWrite(ctx, write))
+	assert.Equal(t, 2, attempts)
+	page, err := db.GetObservations(ctx, "Shared", 10, 0, ObservationOrderOldest)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"other write after 0 observations", "other write after 1 observations", "after 2 observations",
+	}, page.Observations, "the retry saw the other write")
+}
+
+func TestRetryWrite(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	busy := fmt.Errorf("insert: %w", busyError(t))
+
+	calls := 0
+	err := db.retryWrite(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = db.retryWrite(ctx, func() error {
+		calls++
+		return busy
+	})
+	assert.ErrorIs(t, err, busy)
+	assert.Equal(t, DefaultWriteAttempts, calls)
+
+	calls = 0
+	other := errors.New("constraint failed")
+	assert.Equal(t, other, db.retryWrite(ctx, func() error {
+		calls++
+		return other
+	}))
+	assert.Equal(t, 1, calls, "only busy errors are retried")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, db.retryWrite(cancelled, func() error { return busy }), context.Canceled)
+}
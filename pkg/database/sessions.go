@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type sessionKey struct{}
+
+// WithSession returns ctx carrying a session label, recorded on the entities,
+// observations and relations created with it so RollbackSession can undo them
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// sessionFrom returns the label set by WithSession, or "" if there is none
+func sessionFrom(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// SessionSummary counts the rows currently labeled with a session
+type SessionSummary struct {
+	Session      string `json:"session"`
+	Entities     int    `json:"entities"`
+	Observations int    `json:"observations"`
+	Relations    int    `json:"relations"`
+	// FirstWriteAt and LastWriteAt bound the creation times of the rows (RFC 3339, UTC)
+	FirstWriteAt string `json:"firstWriteAt"`
+	LastWriteAt  string `json:"lastWriteAt"`
+}
+
+// ListSessions summarizes every session label in use, most recently written first
+func (db *DB) ListSessions(ctx context.Context) ([]SessionSummary, error) {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT
+			session,
+			SUM(kind = 'e'), SUM(kind = 'o'), SUM(kind = 'r'),
+			strftime('%Y-%m-%dT%H:%M:%SZ', MIN(created_at)),
+			strftime('%Y-%m-%dT%H:%M:%SZ', MAX(created_at))
+		FROM (
+			SELECT session, 'e' AS kind, created_at FROM entities WHERE session IS NOT NULL
+			UNION ALL
+			SELECT session, 'o', created_at FROM observations WHERE session IS NOT NULL
+			UNION ALL
+			SELECT session, 'r', created_at FROM relations WHERE session IS NOT NULL
+		)
+		GROUP BY session
+		ORDER BY MAX(created_at) DESC, session
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var s SessionSummary
+		if err := rows.Scan(&s.Session, &s.Entities, &s.Observations, &s.Relations, &s.FirstWriteAt, &s.LastWriteAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// SessionRollback reports what RollbackSession deleted
+type SessionRollback struct {
+	Session  string `json:"session"`
+	Entities int    `json:"entities"`
+	// Observations and Relations include those of other sessions, or without one, that
+	// belonged to a deleted entity
+	Observations int `json:"observations"`
+	Relations    int `json:"relations"`
+}
+
+// RollbackSession deletes everything created under a session label: the entities
+// created in it with all their observations and relations, plus the observations and
+// relations it added to entities that existed before, which are kept.
+//
+// Rows are removed in batches of deleteBatchSize, each in its own transaction, so a
+// large session doesn't hold the write lock for the whole rollback. If a batch fails,
+// the rows deleted so far stay deleted; retrying the rollback finishes the job.
+func (db *DB) RollbackSession(ctx context.Context, session string) (*SessionRollback, error) {
+	start := time.Now()
+	report := &SessionRollback{Session: session}
+
+	// Children go first so deleting the entities cascades to nothing
+	steps := []struct {
+		table, match string
+		args         []any
+		count        *int
+	}{
+		{"relations", `session = ?
+			OR from_entity_id IN (SELECT id FROM entities WHERE session = ?)
+			OR to_entity_id IN (SELECT id FROM entities WHERE session = ?)`,
+			[]any{session, session, session}, &report.Relations},
+		{"observations", `session = ?
+			OR entity_id IN (SELECT id FROM entities WHERE session = ?)`,
+			[]any{session, session}, &report.Observations},
+		{"entities", "session = ?", []any{session}, &report.Entities},
+	}
+	for _, step := range steps {
+		n, err := db.deleteInBatches(ctx, step.table, step.match, step.args...)
+		*step.count += n
+		if err != nil {
+			db.logger.Error("session rollback failed",
+				slog.String("session", session),
+				slog.String("table", step.table),
+				slog.String("error", err.Error()),
+			)
+			return nil, err
+		}
+	}
+
+	db.logger.Info("session rolled back",
+		slog.String("session", session),
+		slog.Int("entities", report.Entities),
+		slog.Int("observations", report.Observations),
+		slog.Int("relations", report.Relations),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return report, nil
+}
+
+// deleteInBatches deletes the rows of table matching the condition, at most
+// deleteBatchSize per statement, and returns how many were deleted
+func (db *DB) deleteInBatches(ctx context.Context, table, match string, args ...any) (int, error) {
+	query := fmt.Sprintf("DELETE FROM %[1]s WHERE id IN (SELECT id FROM %[1]s WHERE %[2]s LIMIT ?)", table, match)
+	args = append(args, deleteBatchSize)
+	total := 0
+	for {
+		result, err := retryWriteResult(ctx, db, func() (sql.Result, error) {
+			return db.conn.ExecContext(ctx, query, args...)
+		})
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += int(n)
+		if n < deleteBatchSize {
+			return total, nil
+		}
+	}
+}
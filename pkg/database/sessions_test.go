@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollbackSession(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	a := WithSession(ctx, "session-a")
+	b := WithSession(ctx, "session-b")
+
+	createEntity := func(ctx context.Context, name string, observations ...string) {
+		t.Helper()
+		_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: name, EntityType: "thing", Observations: observations}})
+		assert.NoError(t, err)
+	}
+	addObservation := func(ctx context.Context, name, content string) {
+		t.Helper()
+		_, err := db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: name, Contents: []string{content}}})
+		assert.NoError(t, err)
+	}
+	relate := func(ctx context.Context, from, to string) {
+		t.Helper()
+		_, err := db.CreateRelations(ctx, []RelationDTO{{From: from, To: to, RelationType: "links"}})
+		assert.NoError(t, err)
+	}
+
+	// Writes of the two sessions interleave, on new and pre-existing entities
+	createEntity(ctx, "Base", "base fact")
+	createEntity(a, "Alpha", "alpha fact")
+	addObservation(a, "Base", "a note")
+	createEntity(b, "Beta", "beta fact")
+	addObservation(b, "Base", "b note")
+	addObservation(b, "Alpha", "b on alpha")
+	relate(b, "Beta", "Base")
+	relate(a, "Alpha", "Base")
+	relate(a, "Base", "Beta")
+	addObservation(b, "Base", "b note 2")
+
+	sessions, err := db.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	counts := map[string][3]int{}
+	for _, s := range sessions {
+		counts[s.Session] = [3]int{s.Entities, s.Observations, s.Relations}
+		assert.NotEmpty(t, s.FirstWriteAt)
+		assert.LessOrEqual(t, s.FirstWriteAt, s.LastWriteAt)
+	}
+	assert.Equal(t, map[string][3]int{"session-a": {1, 2, 2}, "session-b": {1, 4, 1}}, counts)
+
+	// Alpha goes with everything on it; Base keeps all but session A's observation
+	report, err := db.RollbackSession(ctx, "session-a")
+	assert.NoError(t, err)
+	assert.Equal(t, &SessionRollback{Session: "session-a", Entities: 1, Observations: 3, Relations: 2}, report)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	observations := map[string][]string{}
+	for _, e := range graph.Entities {
+		observations[e.Name] = e.Observations
+	}
+	assert.Equal(t, map[string][]string{
+		"Base": {"base fact", "b note", "b note 2"},
+		"Beta": {"beta fact"},
+	}, observations)
+	assert.Equal(t, []RelationDTO{{From: "Beta", To: "Base", RelationType: "links"}}, graph.Relations)
+
+	sessions, err = db.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "session-b", sessions[0].Session)
+	assert.Equal(t, [3]int{1, 3, 1}, [3]int{sessions[0].Entities, sessions[0].Observations, sessions[0].Relations})
+
+	// Rolling back again, or an unknown session, deletes nothing
+	report, err = db.RollbackSession(ctx, "session-a")
+	assert.NoError(t, err)
+	assert.Equal(t, &SessionRollback{Session: "session-a"}, report)
+}
+
+func TestRollbackSession_Batches(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Log", EntityType: "doc", Observations: []string{"kept"}}})
+	assert.NoError(t, err)
+
+	total := deleteBatchSize*2 + 5
+	contents := make([]string, total)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("entry %d", i)
+	}
+	_, err = db.AddObservations(WithSession(ctx, "bulk"), []ObservationAdditionInput{{EntityName: "Log", Contents: contents}})
+	assert.NoError(t, err)
+
+	report, err := db.RollbackSession(ctx, "bulk")
+	assert.NoError(t, err)
+	assert.Equal(t, total, report.Observations)
+
+	var remaining int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM observations").Scan(&remaining))
+	assert.Equal(t, 1, remaining)
+}
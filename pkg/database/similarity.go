@@ -0,0 +1,99 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SimilarObservation is an observation that was not added because an existing one
+// was at least as similar as the requested threshold
+type SimilarObservation struct {
+	Content    string  `json:"content"`
+	Existing   string  `json:"existing"`
+	Similarity float64 `json:"similarity"`
+}
+
+// tokenSetRatio scores the similarity of two strings from 0 to 1, ignoring case,
+// punctuation, word order and repeated words. The shared words are compared with
+// each side's full word set, so a string whose words are a subset of the other's
+// scores 1. Strings without words score 0.
+func tokenSetRatio(a, b string) float64 {
+	ta, tb := wordSet(a), wordSet(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	var shared, onlyA, onlyB []string
+	for w := range ta {
+		if tb[w] {
+			shared = append(shared, w)
+		} else {
+			onlyA = append(onlyA, w)
+		}
+	}
+	for w := range tb {
+		if !ta[w] {
+			onlyB = append(onlyB, w)
+		}
+	}
+	sort.Strings(shared)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	base := strings.Join(shared, " ")
+	withA := strings.TrimSpace(base + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(base + " " + strings.Join(onlyB, " "))
+	return max(editRatio(base, withA), editRatio(base, withB), editRatio(withA, withB))
+}
+
+// wordSet splits s into lower-cased runs of letters and digits
+func wordSet(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// editRatio is 1 - d/(len(a)+len(b)), where d is the edit distance between a and b
+// with substitutions costing 2
+func editRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	total := len(ra) + len(rb)
+	if total == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			sub := prev[j-1]
+			if ra[i-1] != rb[j-1] {
+				sub += 2
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, sub)
+		}
+		prev, cur = cur, prev
+	}
+	return float64(total-prev[len(rb)]) / float64(total)
+}
+
+// mostSimilar returns the candidate with the highest tokenSetRatio to content
+func mostSimilar(content string, candidates []string) (string, float64) {
+	best, bestScore := "", 0.0
+	for _, c := range candidates {
+		if score := tokenSetRatio(content, c); score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best, bestScore
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSetRatio(t *testing.T) {
+	assert.Equal(t, 1.0, tokenSetRatio("Build 123 failed", "build #123 FAILED"))
+	assert.Equal(t, 1.0, tokenSetRatio("failed: build 123", "Build 123 failed"))
+	// One side's words are a subset of the other's
+	assert.Equal(t, 1.0, tokenSetRatio("build failed", "Build 123 failed"))
+	assert.InDelta(t, 0.9375, tokenSetRatio("Build 123 failed", "Build 124 failed"), 1e-9)
+	assert.Less(t, tokenSetRatio("Build 123 failed", "Deploy finished"), 0.5)
+	assert.Zero(t, tokenSetRatio("!!!", "!!!"))
+}
+
+func TestAddObservationsIfAbsentSimilar(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "CI", EntityType: "service", Observations: []string{"Build 123 failed"}},
+	})
+	assert.NoError(t, err)
+
+	add := func(threshold float64, contents ...string) ObservationAdditionResult {
+		t.Helper()
+		results, err := db.AddObservationsIfAbsentSimilar(ctx, []ObservationAdditionInput{{EntityName: "CI", Contents: contents}}, threshold)
+		assert.NoError(t, err)
+		return results[0]
+	}
+
+	// Exact duplicates are skipped silently, near-duplicates are reported
+	result := add(0.9, "Build 123 failed", "Build #123 failed")
+	assert.Empty(t, result.AddedObservations)
+	assert.Equal(t, []SimilarObservation{{Content: "Build #123 failed", Existing: "Build 123 failed", Similarity: 1}}, result.SkippedAsSimilar)
+
+	// The threshold decides borderline cases
+	result = add(0.95, "Build 124 failed")
+	assert.Equal(t, []string{"Build 124 failed"}, result.AddedObservations)
+	assert.Empty(t, result.SkippedAsSimilar)
+	result = add(0.9, "Build 125 failed")
+	assert.Empty(t, result.AddedObservations)
+	assert.Equal(t, 0.938, result.SkippedAsSimilar[0].Similarity)
+
+	// Contents added earlier in the same call are compared too
+	result = add(0.9, "Deploy 7 started", "deploy #7 started")
+	assert.Equal(t, []string{"Deploy 7 started"}, result.AddedObservations)
+	assert.Equal(t, "Deploy 7 started", result.SkippedAsSimilar[0].Existing)
+
+	// Without a threshold only exact duplicates are skipped
+	result = add(0, "Build 123 failed", "BUILD 123 FAILED")
+	assert.Equal(t, []string{"BUILD 123 FAILED"}, result.AddedObservations)
+	assert.Nil(t, result.SkippedAsSimilar)
+
+	// The comparison only covers the most recent observations up to the limit
+	db.SetObservationLimit(1)
+	result = add(0.9, "deploy 7 started!")
+	assert.Equal(t, []string{"deploy 7 started!"}, result.AddedObservations)
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshotPattern names snapshot files, created next to the primary database
+const snapshotPattern = "snapshot-*.db"
+
+// SnapshotTo writes a consistent copy of the database to path, which must not exist
+// or be empty. Writers are not blocked while it runs.
+func (db *DB) SnapshotTo(ctx context.Context, path string) error {
+	_, err := db.conn.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// SnapshotReads serves reads from a read-only snapshot while long operations, such
+// as a maintenance window or a large import, hold the primary database. The primary
+// has a single connection, so without it every read queues behind the operation.
+//
+// Run takes the snapshot before the operation starts. Once the last overlapping
+// operation ends, reads return to the primary and the snapshot is deleted by
+// whichever finishes last of that operation and the reads still using it. Reads
+// served from a snapshot miss the writes made after it was taken; Reader returns
+// its time so results can say so.
+type SnapshotReads struct {
+	primary *DB
+	dir     string
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	current *readSnapshot
+	users   int // operations sharing current
+}
+
+type readSnapshot struct {
+	db      *DB
+	path    string
+	takenAt time.Time
+	readers int  // reads not yet released, guarded by SnapshotReads.mu
+	retired bool // reads have returned to the primary
+}
+
+// NewSnapshotReads creates a coordinator for primary that writes snapshots to dir
+// ("" = the directory of the database file, or the temporary directory in memory)
+func NewSnapshotReads(primary *DB, dir string) *SnapshotReads {
+	if dir == "" {
+		dir = os.TempDir()
+		if primary.path != "" {
+			dir = filepath.Dir(primary.path)
+		}
+	}
+	return &SnapshotReads{primary: primary, dir: dir, logger: primary.logger}
+}
+
+// Run runs fn with reads routed to a snapshot taken just before it. If the snapshot
+// fails, fn still runs and reads wait for the primary. A nil SnapshotReads runs fn
+// directly.
+func (c *SnapshotReads) Run(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if c == nil {
+		return fn(ctx)
+	}
+	if err := c.acquire(ctx); err != nil {
+		c.logger.Warn("failed to snapshot database for reads; reads wait for the operation",
+			slog.String("operation", name),
+			slog.String("error", err.Error()),
+		)
+		return fn(ctx)
+	}
+	defer c.release()
+	return fn(ctx)
+}
+
+// Reader returns the database reads should use, when its data was snapshotted (zero
+// for the primary), and a func to call once the read is done
+func (c *SnapshotReads) Reader() (*DB, time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil {
+		return c.primary, time.Time{}, func() {}
+	}
+	snap := c.current
+	snap.readers++
+	var once sync.Once
+	return snap.db, snap.takenAt, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			snap.readers--
+			last := snap.retired && snap.readers == 0
+			c.mu.Unlock()
+			if last {
+				c.remove(snap)
+			}
+		})
+	}
+}
+
+// acquire shares the current snapshot or takes a new one. Reader waits while a
+// snapshot is taken, as the copy holds the primary's connection anyway.
+func (c *SnapshotReads) acquire(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil {
+		snap, err := c.take(ctx)
+		if err != nil {
+			return err
+		}
+		c.current = snap
+	}
+	c.users++
+	return nil
+}
+
+// release swaps reads back to the primary after the last operation. The snapshot is
+// deleted now, or by the last read still using it.
+func (c *SnapshotReads) release() {
+	c.mu.Lock()
+	c.users--
+	if c.users > 0 {
+		c.mu.Unlock()
+		return
+	}
+	snap := c.current
+	c.current = nil
+	snap.retired = true
+	last := snap.readers == 0
+	c.mu.Unlock()
+
+	c.logger.Info("reads returned to the primary database",
+		slog.Duration("snapshot_age", time.Since(snap.takenAt)),
+	)
+	if last {
+		c.remove(snap)
+	}
+}
+
+func (c *SnapshotReads) remove(snap *readSnapshot) {
+	if err := errors.Join(snap.db.Close(), removeSnapshot(snap.path)); err != nil {
+		c.logger.Warn("failed to remove read snapshot",
+			slog.String("path", snap.path),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (c *SnapshotReads) take(ctx context.Context) (*readSnapshot, error) {
+	start := time.Now()
+	f, err := os.CreateTemp(c.dir, snapshotPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	path := f.Name()
+	if err := f.Close(); err != nil {
+		return nil, errors.Join(err, removeSnapshot(path))
+	}
+
+	takenAt := time.Now().UTC()
+	if err := c.primary.SnapshotTo(ctx, path); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to snapshot database: %w", err), removeSnapshot(path))
+	}
+	db, err := NewReadOnlyDB(path, c.logger)
+	if err != nil {
+		return nil, errors.Join(err, removeSnapshot(path))
+	}
+	db.observationLimit = c.primary.observationLimit
+
+	c.logger.Info("reads routed to snapshot",
+		slog.String("path", path),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return &readSnapshot{db: db, path: path, takenAt: takenAt}, nil
+}
+
+// removeSnapshot deletes a snapshot file and any journal SQLite left beside it
+func removeSnapshot(path string) error {
+	var errs []error
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotReads_ServesReadsDuringLongOperation(t *testing.T) {
+	db := newImportTestDB(t)
+	dir := filepath.Dir(db.path)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}}})
+	assert.NoError(t, err)
+
+	snapshots := NewSnapshotReads(db, "")
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- snapshots.Run(ctx, "slow_write", func(ctx context.Context) error {
+			// Hold the primary's only connection inside a write transaction
+			tx, err := db.conn.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if _, err := tx.ExecContext(ctx, "INSERT INTO entities (name, entity_type) VALUES ('Bob', 'person')"); err != nil {
+				return err
+			}
+			close(started)
+			<-finish
+			return tx.Commit()
+		})
+	}()
+	<-started
+
+	// The primary's read pool isn't stuck behind the operation, and doesn't see it
+	primaryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	graph, err := db.ReadGraph(primaryCtx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+
+	// The snapshot answers with the data from before it
+	reader, takenAt, release := snapshots.Reader()
+	assert.NotSame(t, db, reader)
+	assert.False(t, takenAt.IsZero())
+	readCtx, cancelRead := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelRead()
+	graph, err = reader.ReadGraph(readCtx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+	assert.Equal(t, "Alice", graph.Entities[0].Name)
+	files, _ := filepath.Glob(filepath.Join(dir, snapshotPattern))
+	assert.Len(t, files, 1)
+
+	close(finish)
+	// The snapshot outlives the operation until its last read is released
+	assert.NoError(t, <-done)
+	_, err = reader.ReadGraph(readCtx)
+	assert.NoError(t, err)
+	release()
+
+	reader, takenAt, release = snapshots.Reader()
+	defer release()
+	assert.Same(t, db, reader)
+	assert.True(t, takenAt.IsZero())
+	graph, err = reader.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2)
+	files, _ = filepath.Glob(filepath.Join(dir, snapshotPattern))
+	assert.Empty(t, files)
+}
+
+func TestSnapshotReads_OverlappingOperationsShareSnapshot(t *testing.T) {
+	db := newImportTestDB(t)
+	snapshots := NewSnapshotReads(db, t.TempDir())
+	ctx := context.Background()
+
+	var first, second time.Time
+	err := snapshots.Run(ctx, "outer", func(ctx context.Context) error {
+		_, takenAt, release := snapshots.Reader()
+		first = takenAt
+		release()
+		return snapshots.Run(ctx, "inner", func(ctx context.Context) error {
+			_, takenAt, release := snapshots.Reader()
+			second = takenAt
+			release()
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+	assert.False(t, first.IsZero())
+	assert.Equal(t, first, second)
+
+	var nilSnapshots *SnapshotReads
+	ran := false
+	assert.NoError(t, nilSnapshots.Run(ctx, "direct", func(context.Context) error { ran = true; return nil }))
+	assert.True(t, ran)
+}
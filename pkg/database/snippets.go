@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Markers AddSnippets puts around the matched terms, and before or after a
+// fragment cut out of a longer observation
+const (
+	SnippetMatchStart = "**"
+	SnippetMatchEnd   = "**"
+	SnippetEllipsis   = "…"
+)
+
+// snippetTokens is the most tokens of an observation a snippet holds
+const snippetTokens = 16
+
+// AddSnippets sets Matches on the entities of graph to fragments of their
+// observations matching a full-text query, in the order the observations were
+// stored, with the matched terms between SnippetMatchStart and SnippetMatchEnd.
+// query is escaped to match any of the terms SearchNodesFTS matches unless raw, when it
+// is an FTS5 expression as SearchNodesFTSQuery takes. Like SearchNodesFTS, an escaped query falls back to
+// AddMatches when FTS5 fails.
+func (db *DB) AddSnippets(ctx context.Context, graph *KnowledgeGraph, query string, raw bool) error {
+	expr := query
+	if !raw {
+		expr = escapeFTS5Any(query)
+	}
+	err := db.addMatches(ctx, graph, func(chunk string, args []any) (string, []any) {
+		return fmt.Sprintf(`
+			SELECT e.name, snippet(observations_fts, 2, ?, ?, ?, ?)
+			FROM observations_fts JOIN entities e ON e.id = observations_fts.entity_id
+			WHERE observations_fts MATCH ? AND e.graph_id = ? AND e.name IN %s
+				AND observations_fts.observation_id NOT IN %s
+			ORDER BY observations_fts.observation_id`, chunk, expiredObservationIDs),
+			append([]any{SnippetMatchStart, SnippetMatchEnd, SnippetEllipsis, snippetTokens, expr}, args...)
+	})
+	if err != nil && !raw && ctx.Err() == nil {
+		return db.AddMatches(ctx, graph, query)
+	}
+	return err
+}
+
+// AddMatches sets Matches on the entities of graph to their observations that
+// contain one of query's whitespace-separated terms, ignoring ASCII case, in the
+// order they were stored. % and _ in the terms match themselves.
+func (db *DB) AddMatches(ctx context.Context, graph *KnowledgeGraph, query string) error {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	conditions := make([]string, len(terms))
+	patterns := make([]any, len(terms))
+	for i, term := range terms {
+		conditions[i] = `o.content LIKE ? ESCAPE '\'`
+		patterns[i] = "%" + escapeLike(term) + "%"
+	}
+	return db.addMatches(ctx, graph, func(chunk string, args []any) (string, []any) {
+		return fmt.Sprintf(`
+			SELECT e.name, o.content
+			FROM observations o JOIN entities e ON e.id = o.entity_id
+			WHERE e.graph_id = ? AND e.name IN %s AND (%s) AND %s
+			ORDER BY o.id`, chunk, strings.Join(conditions, " OR "), liveObservation("o")),
+			append(args, patterns...)
+	})
+}
+
+// addMatches sets Matches on the entities of graph from the (name, match) rows of
+// the queries built by query, one for each chunk of the entity names. The chunk's
+// arguments start with the id of the graph named by ctx, which the queries match
+// before the names.
+func (db *DB) addMatches(ctx context.Context, graph *KnowledgeGraph, query func(chunk string, args []any) (string, []any)) error {
+	scope, err := graphID(ctx, db.reader)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(graph.Entities))
+	matches := make(map[string][]string, len(graph.Entities))
+	for i, e := range graph.Entities {
+		names[i] = e.Name
+	}
+
+	for start := 0; start < len(names); start += pathQueryChunk {
+		chunk, args := stringList(names[start:min(start+pathQueryChunk, len(names))])
+		q, args := query(chunk, append([]any{scope}, args...))
+		rows, err := db.reader.QueryContext(ctx, q, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name, match string
+			if err := rows.Scan(&name, &match); err != nil {
+				rows.Close()
+				return err
+			}
+			matches[name] = append(matches[name], match)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range graph.Entities {
+		graph.Entities[i].Matches = matches[graph.Entities[i].Name]
+	}
+	return nil
+}
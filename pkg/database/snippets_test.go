@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const longObservation = "the deployment failed because the database migration timed out after running for a very long time on the primary"
+
+func newSnippetFixture(t *testing.T) (*DB, *KnowledgeGraph) {
+	t.Helper()
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	notes := []string{"kickoff on monday"}
+	for i := 0; i < 40; i++ {
+		notes = append(notes, fmt.Sprintf("routine status update %d", i))
+	}
+	notes = append(notes, longObservation)
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Release", EntityType: "project", Observations: notes},
+		{Name: "Migration", EntityType: "task", Observations: []string{"planned for friday"}},
+		{Name: "Ops", EntityType: "team", Observations: []string{"owns the database", "100% uptime goal"}},
+	})
+	assert.NoError(t, err)
+	graph, err := db.OpenNodes(ctx, []string{"Release", "Migration", "Ops"})
+	assert.NoError(t, err)
+	return db, graph
+}
+
+func matchesByName(graph *KnowledgeGraph) map[string][]string {
+	out := map[string][]string{}
+	for _, e := range graph.Entities {
+		if e.Matches != nil {
+			out[e.Name] = e.Matches
+		}
+	}
+	return out
+}
+
+func TestAddSnippets(t *testing.T) {
+	db, graph := newSnippetFixture(t)
+	if !db.IsFTSEnabled() {
+		t.Skip("FTS5 not compiled in (build with -tags sqlite_fts5)")
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, db.AddSnippets(ctx, graph, "migration", false))
+	matches := matchesByName(graph)
+	// Only the matching observation, cut down around the match
+	if assert.Len(t, matches["Release"], 1) {
+		snippet := matches["Release"][0]
+		assert.Contains(t, snippet, "**migration**")
+		assert.True(t, strings.HasSuffix(snippet, SnippetEllipsis), snippet)
+		assert.Less(t, len(snippet), len(longObservation))
+	}
+	// Migration only matches by name
+	assert.NotContains(t, matches, "Migration")
+	assert.NotContains(t, matches, "Ops")
+
+	assert.NoError(t, db.AddSnippets(ctx, graph, "database OR kickoff", true))
+	matches = matchesByName(graph)
+	assert.Equal(t, []string{"owns the **database**"}, matches["Ops"])
+	if assert.Len(t, matches["Release"], 2) {
+		assert.Equal(t, "**kickoff** on monday", matches["Release"][0], "in the order they were stored")
+		assert.Contains(t, matches["Release"][1], "**database**")
+	}
+
+	assert.Error(t, db.AddSnippets(ctx, graph, "database AND (", true))
+}
+
+func TestAddMatches(t *testing.T) {
+	db, graph := newSnippetFixture(t)
+	ctx := context.Background()
+
+	assert.NoError(t, db.AddMatches(ctx, graph, "DATABASE friday"))
+	matches := matchesByName(graph)
+	assert.Equal(t, []string{"owns the database"}, matches["Ops"])
+	assert.Equal(t, []string{"planned for friday"}, matches["Migration"])
+	if assert.Len(t, matches["Release"], 1) {
+		assert.Contains(t, matches["Release"][0], "database migration")
+	}
+
+	assert.NoError(t, db.AddMatches(ctx, graph, "0%"))
+	assert.Equal(t, map[string][]string{"Ops": {"100% uptime goal"}}, matchesByName(graph))
+}
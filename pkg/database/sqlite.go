@@ -3,28 +3,73 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
 )
 
 const (
 	DB_PERMS                = os.FileMode(0755)
-	SQL_DRIVER              = "sqlite3"
 	MAX_OPEN_CONNECTIONS    = 1
 	MAX_IDLE_CONNECTIONS    = 1
 	MAX_CONNECTION_LIFETIME = 0 // Infinite
+	MAX_READ_CONNECTIONS    = 4
+)
+
+// connectionPragmas are the settings SQLite keeps per connection rather than in
+// the database file, so every connection needs them
+var connectionPragmas = []string{
+	"PRAGMA cache_size = -64000",   // 64MB cache (negative = KB)
+	"PRAGMA foreign_keys = ON",     // Enforce foreign key constraints
+	"PRAGMA busy_timeout = 5000",   // 5 second timeout for locks
+	"PRAGMA temp_store = MEMORY",   // Use memory for temporary tables
+	"PRAGMA mmap_size = 268435456", // 256MB memory-mapped I/O
+}
+
+// readPragmas set up each connection of the read pool
+var readPragmas = append([]string{"PRAGMA query_only = ON"}, connectionPragmas...)
+
+const (
+	// DefaultObservationLimit caps the observations returned per entity by read paths.
+	// Callers page through the rest with GetObservations.
+	DefaultObservationLimit = 100
+	// deleteBatchSize bounds how many observations one statement removes when deleting entities
+	deleteBatchSize = 1000
 )
 
 type DB struct {
-	conn       *sql.DB
-	logger     *slog.Logger
-	ftsEnabled bool // Whether FTS5 is available
+	conn             *sql.DB
+	reader           *sql.DB // Pool for read-only queries; conn itself in memory and when read-only
+	logger           *slog.Logger
+	ftsEnabled       bool   // Whether FTS5 is available
+	observationLimit int    // Max observations per entity on read paths (0 = unlimited)
+	writeAttempts    int    // Tries of a write while the database is busy, see retry.go
+	readOnly         bool   // Opened with NewReadOnlyDB
+	path             string // Database file synced by Sync; empty in memory
+
+	relationConstraints RelationConstraints // Enforced by CreateRelations
+	retentionPolicy     *RetentionPolicy    // Enforced by ApplyRetention
+	observationCap      int                 // Max observations stored per entity (0 = unlimited), see eviction.go
+	observationEviction string              // What AddObservations does at the cap
+	softDelete          bool                // DeleteEntities moves entities to the trash, see trash.go
+	maxSnapshots        int                 // Labeled snapshots kept per graph (0 = default), see graphsnapshot.go
+	maxSnapshotBytes    int64               // Largest compressed snapshot stored (0 = default)
+	auditRedactor       *logging.Redactor   // Masks secrets in audit summaries, see audit.go
+
+	adjacencyBudget int64                     // Max estimated bytes of the adjacency cache (0 = disabled)
+	adjacency       atomic.Pointer[adjacency] // Relations cached for FindPath, see adjacency.go
+	adjacencyBuild  sync.Mutex                // Held while the cache is rebuilt
 }
 
 // NewDBWithLogger creates a new database connection with a logger
@@ -43,6 +88,7 @@ func NewDBWithLogger(dbPath string, logger *slog.Logger) (*DB, error) {
 
 	logger.Info("opening database connection",
 		slog.String("path", dbPath),
+		slog.String("driver", SQL_DRIVER),
 	)
 
 	conn, err := sql.Open(SQL_DRIVER, dbPath)
@@ -56,9 +102,13 @@ func NewDBWithLogger(dbPath string, logger *slog.Logger) (*DB, error) {
 	conn.SetConnMaxLifetime(MAX_CONNECTION_LIFETIME) // Connections don't expire
 
 	db := &DB{
-		conn:       conn,
-		logger:     logger,
-		ftsEnabled: false, // Will be set during migration
+		conn:             conn,
+		reader:           conn,
+		logger:           logger,
+		ftsEnabled:       false, // Will be set during migration
+		observationLimit: DefaultObservationLimit,
+		writeAttempts:    DefaultWriteAttempts,
+		path:             databaseFile(dbPath),
 	}
 
 	// Configure SQLite pragmas for better performance
@@ -70,6 +120,26 @@ func NewDBWithLogger(dbPath string, logger *slog.Logger) (*DB, error) {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// WAL lets readers run beside the single writer, so reads get a pool of their own
+	// rather than queueing for the writer's connection. An in-memory database has no
+	// WAL and keeps reading through conn.
+	if db.path != "" {
+		reader, err := openReadPool(dbPath)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		reader.SetMaxOpenConns(MAX_READ_CONNECTIONS)
+		reader.SetMaxIdleConns(MAX_READ_CONNECTIONS)
+		reader.SetConnMaxLifetime(MAX_CONNECTION_LIFETIME)
+		if err := reader.Ping(); err != nil {
+			reader.Close()
+			conn.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		db.reader = reader
+	}
+
 	logger.Info("database initialized successfully")
 	return db, nil
 }
@@ -77,14 +147,13 @@ func NewDBWithLogger(dbPath string, logger *slog.Logger) (*DB, error) {
 // configurePragmas sets SQLite pragmas for optimal performance
 func (db *DB) configurePragmas() error {
 	pragmas := []string{
-		"PRAGMA journal_mode = WAL",    // Write-Ahead Logging for better concurrency
-		"PRAGMA synchronous = NORMAL",  // Good balance of safety and speed
-		"PRAGMA cache_size = -64000",   // 64MB cache (negative = KB)
-		"PRAGMA foreign_keys = ON",     // Enforce foreign key constraints
-		"PRAGMA busy_timeout = 5000",   // 5 second timeout for locks
-		"PRAGMA temp_store = MEMORY",   // Use memory for temporary tables
-		"PRAGMA mmap_size = 268435456", // 256MB memory-mapped I/O
+		// Lets erasure release free pages. Must precede journal_mode and only takes
+		// effect on a new database.
+		"PRAGMA auto_vacuum = INCREMENTAL",
+		"PRAGMA journal_mode = WAL",   // Write-Ahead Logging for better concurrency
+		"PRAGMA synchronous = NORMAL", // Good balance of safety and speed
 	}
+	pragmas = append(pragmas, connectionPragmas...)
 
 	for _, pragma := range pragmas {
 		db.logger.Debug("executing pragma",
@@ -100,6 +169,9 @@ func (db *DB) configurePragmas() error {
 }
 
 func (db *DB) Close() error {
+	if db.reader != db.conn {
+		db.reader.Close()
+	}
 	return db.conn.Close()
 }
 
@@ -108,48 +180,68 @@ func (db *DB) IsFTSEnabled() bool {
 	return db.ftsEnabled
 }
 
-func (db *DB) migrate() error {
-	// Core table creation and indexes
-	coreStatements := []string{
-		`CREATE TABLE IF NOT EXISTS entities (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			entity_type TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE IF NOT EXISTS observations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			entity_id INTEGER NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE,
-			UNIQUE(entity_id, content)
-		);`,
-		`CREATE TABLE IF NOT EXISTS relations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			from_entity_id INTEGER NOT NULL,
-			to_entity_id INTEGER NOT NULL,
-			relation_type TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (from_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
-			FOREIGN KEY (to_entity_id) REFERENCES entities(id) ON DELETE CASCADE,
-			UNIQUE(from_entity_id, to_entity_id, relation_type)
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_entities_name ON entities(name);`,
-		`CREATE INDEX IF NOT EXISTS idx_entities_type ON entities(entity_type);`,
-		`CREATE INDEX IF NOT EXISTS idx_observations_entity ON observations(entity_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_observations_content ON observations(content);`, // For text search
-		`CREATE INDEX IF NOT EXISTS idx_relations_from ON relations(from_entity_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_relations_to ON relations(to_entity_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_relations_type ON relations(relation_type);`, // For filtering by relation type
+// IsReadOnly returns whether the database was opened read-only
+func (db *DB) IsReadOnly() bool {
+	return db.readOnly
+}
+
+// SetObservationLimit sets how many observations per entity read paths return (0 = unlimited)
+func (db *DB) SetObservationLimit(limit int) {
+	if limit < 0 {
+		limit = 0
 	}
+	db.observationLimit = limit
+}
 
-	// Execute core statements
-	for _, stmt := range coreStatements {
-		if _, err := db.conn.Exec(stmt); err != nil {
-			return err
-		}
+// ObservationLimit returns how many observations per entity read paths return (0 = unlimited)
+func (db *DB) ObservationLimit() int {
+	return db.observationLimit
+}
+
+// observationColumns selects an entity's total observation count and up to limit of its
+// oldest observations, as a JSON array ordered by created_at, then id, so observations
+// stored within the same second keep their insertion order. Expired observations are
+// left out. It expects the entities table aliased as e; both
+// subqueries walk the (entity_id, created_at) index, so the cost is bounded by limit
+// rather than by the size of the entity's observation set.
+func observationColumns(limit int) string {
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	return fmt.Sprintf(`(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id AND %[2]s) AS total_observations,
+			(
+				SELECT json_group_array(content ORDER BY created_at, id) FROM (
+					SELECT o.content, o.created_at, o.id FROM observations o WHERE o.entity_id = e.id AND %[2]s
+					ORDER BY o.created_at, o.id LIMIT %[1]d
+				)
+			) AS observations`, limit, liveObservation("o"))
+}
+
+// splitObservations parses the JSON array of observations observationColumns selects.
+// SQLite escapes quotes, backslashes and control characters, so an array without a
+// backslash holds none of them and can be split on "," without decoding, which
+// keeps large reads from allocating per observation.
+func splitObservations(observationsJSON string) ([]string, error) {
+	if observationsJSON == "[]" {
+		return []string{}, nil
+	}
+	if len(observationsJSON) >= 4 && strings.HasPrefix(observationsJSON, `["`) &&
+		strings.HasSuffix(observationsJSON, `"]`) && !strings.Contains(observationsJSON, `\`) {
+		return strings.Split(observationsJSON[2:len(observationsJSON)-2], `","`), nil
+	}
+	observations := []string{}
+	if err := json.Unmarshal([]byte(observationsJSON), &observations); err != nil {
+		return nil, fmt.Errorf("decoding observations: %w", err)
+	}
+	return observations, nil
+}
+
+// migrate applies the schema migrations the database lacks, see migrations.go, then
+// sets up full-text search when this build has FTS5, which depends on the binary
+// rather than the database and so is checked on every start
+func (db *DB) migrate() error {
+	if err := db.applyMigrations(context.Background()); err != nil {
+		return err
 	}
 
 	// Try to create FTS5 tables
@@ -173,7 +265,7 @@ func (db *DB) migrate() error {
 	ftsCreated := true
 	for _, stmt := range ftsStatements {
 		if _, err := db.conn.Exec(stmt); err != nil {
-			if strings.Contains(err.Error(), "no such module: fts5") {
+			if isMissingFTS5(err) {
 				db.logger.Warn("FTS5 not available, skipping full-text search setup")
 				ftsCreated = false
 				break
@@ -185,6 +277,19 @@ func (db *DB) migrate() error {
 	// Only create triggers if FTS5 tables were successfully created
 	if ftsCreated {
 		db.ftsEnabled = true
+		// Earlier versions reindexed entities on every update, including the
+		// updated_at changes of each observation write
+		var oldTrigger bool
+		if err := db.conn.QueryRow(
+			"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'trigger' AND name = 'entities_au' AND sql NOT LIKE '%UPDATE OF%'",
+		).Scan(&oldTrigger); err != nil {
+			return err
+		}
+		if oldTrigger {
+			if _, err := db.conn.Exec("DROP TRIGGER entities_au"); err != nil {
+				return err
+			}
+		}
 		triggerStatements := []string{
 			// Entity triggers
 			`CREATE TRIGGER IF NOT EXISTS entities_ai AFTER INSERT ON entities BEGIN
@@ -194,7 +299,7 @@ func (db *DB) migrate() error {
 			`CREATE TRIGGER IF NOT EXISTS entities_ad AFTER DELETE ON entities BEGIN
 				DELETE FROM entities_fts WHERE entity_id = old.id;
 			END;`,
-			`CREATE TRIGGER IF NOT EXISTS entities_au AFTER UPDATE ON entities BEGIN
+			`CREATE TRIGGER IF NOT EXISTS entities_au AFTER UPDATE OF name, entity_type ON entities BEGIN
 				DELETE FROM entities_fts WHERE entity_id = old.id;
 				INSERT INTO entities_fts(entity_id, name, entity_type) 
 				VALUES (new.id, new.name, new.entity_type);
@@ -230,9 +335,48 @@ func (db *DB) migrate() error {
 }
 
 func (db *DB) CreateEntities(ctx context.Context, entities []EntityWithObservations) ([]EntityWithObservations, error) {
+	results, err := db.CreateEntitiesWithMode(ctx, entities, DuplicateSkip)
+	if err != nil {
+		return nil, err
+	}
+
+	created := []EntityWithObservations{}
+	for i, result := range results {
+		if result.Outcome == OutcomeCreated {
+			created = append(created, entities[i])
+		}
+	}
+	return created, nil
+}
+
+// CreateEntitiesWithMode creates entities in a single transaction. onDuplicate decides what
+// happens when an entity with the same name already exists: DuplicateSkip leaves it alone,
+// DuplicateAppendObservations (or DuplicateMergeObservations) adds any new observations to
+// it, and DuplicateError fails the whole batch. The result reports the outcome for each
+// input entity, in order.
+//
+// New entities and their observations are written with multi-row inserts, so a batch
+// costs a few statements per few hundred entities rather than several per entity.
+func (db *DB) CreateEntitiesWithMode(ctx context.Context, entities []EntityWithObservations, onDuplicate string) ([]EntityCreateResult, error) {
+	return retryWriteResult(ctx, db, func() ([]EntityCreateResult, error) {
+		return db.createEntitiesWithMode(ctx, entities, onDuplicate)
+	})
+}
+
+// createEntitiesWithMode makes one attempt at CreateEntitiesWithMode
+func (db *DB) createEntitiesWithMode(ctx context.Context, entities []EntityWithObservations, onDuplicate string) ([]EntityCreateResult, error) {
+	switch onDuplicate {
+	case DuplicateMergeObservations:
+		onDuplicate = DuplicateAppendObservations
+	case "", DuplicateSkip, DuplicateAppendObservations, DuplicateError:
+	default:
+		return nil, fmt.Errorf("invalid onDuplicate %q", onDuplicate)
+	}
+
 	start := time.Now()
 	db.logger.Debug("creating entities",
 		slog.Int("count", len(entities)),
+		slog.String("on_duplicate", onDuplicate),
 	)
 
 	tx, err := db.conn.BeginTx(ctx, nil)
@@ -244,42 +388,148 @@ func (db *DB) CreateEntities(ctx context.Context, entities []EntityWithObservati
 	}
 	defer tx.Rollback()
 
-	created := []EntityWithObservations{}
+	graph, err := createGraphTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
 
+	// Insert every name once, in chunks; the names an insert returns are the
+	// entities created, and every other entity is a duplicate
+	var names []string
+	types := make(map[string]string, len(entities))
 	for _, entity := range entities {
-		var exists bool
-		err := tx.QueryRowContext(ctx, "SELECT 1 FROM entities WHERE name = ?", entity.Name).Scan(&exists)
-		if err != nil && err != sql.ErrNoRows {
+		if _, ok := types[entity.Name]; !ok {
+			types[entity.Name] = entity.EntityType
+			names = append(names, entity.Name)
+		}
+	}
+	session := sessionFrom(ctx)
+	createdIDs := make(map[string]int64, len(names))
+	inserted := 0
+	for _, chunk := range chunks(names, maxListValues/4) {
+		if err := checkCancelled(ctx, "create_entities", inserted, len(entities)); err != nil {
 			return nil, err
 		}
-		if exists {
+		values := make([]string, len(chunk))
+		args := make([]any, 0, 4*len(chunk))
+		for i, name := range chunk {
+			values[i] = "(?, ?, ?, NULLIF(?, ''))"
+			args = append(args, graph, name, types[name], session)
+		}
+		if err := scanEntityIDs(ctx, tx, createdIDs,
+			"INSERT INTO entities (graph_id, name, entity_type, session) VALUES "+strings.Join(values, ", ")+
+				" ON CONFLICT(graph_id, name) DO NOTHING RETURNING id, name",
+			args...,
+		); err != nil {
+			return nil, cancelledOr(ctx, err, "create_entities", inserted, len(entities))
+		}
+		inserted += len(chunk)
+	}
+
+	results := make([]EntityCreateResult, len(entities))
+	var appendTo []string
+	var observations []Observation
+	claimed := make(map[string]bool, len(createdIDs))
+	created := 0
+	for i, entity := range entities {
+		results[i] = EntityCreateResult{Name: entity.Name, EntityType: entity.EntityType, Observations: []string{}}
+		// Later entities with the name of one created here are duplicates of it
+		if id, ok := createdIDs[entity.Name]; ok && !claimed[entity.Name] {
+			claimed[entity.Name] = true
+			results[i].Outcome = OutcomeCreated
+			if entity.Observations != nil {
+				results[i].Observations = entity.Observations
+			}
+			for _, obs := range entity.Observations {
+				observations = append(observations, Observation{EntityID: id, Content: obs})
+			}
+			err := addTagsTx(ctx, tx, id, entity.Tags)
+			if err == nil {
+				_, err = patchAttributesTx(ctx, tx, id, entity.Name, entity.Attributes)
+			}
+			if err != nil {
+				return nil, cancelledOr(ctx, err, "create_entities", i, len(entities))
+			}
+			created++
 			continue
 		}
+		switch onDuplicate {
+		case DuplicateError:
+			return nil, fmt.Errorf("entity with name %s already exists", entity.Name)
+		case DuplicateAppendObservations:
+			if _, ok := createdIDs[entity.Name]; !ok {
+				appendTo = append(appendTo, entity.Name)
+			}
+		default:
+			results[i].Outcome = OutcomeSkipped
+		}
+	}
 
-		result, err := tx.ExecContext(ctx,
-			"INSERT INTO entities (name, entity_type) VALUES (?, ?)",
-			entity.Name, entity.EntityType,
-		)
-		if err != nil {
+	writer := writerFrom(ctx)
+	for _, chunk := range chunks(observations, maxListValues/4) {
+		if err := checkCancelled(ctx, "create_entities", created, len(entities)); err != nil {
 			return nil, err
 		}
+		values := make([]string, len(chunk))
+		args := make([]any, 0, 4*len(chunk))
+		for i, obs := range chunk {
+			values[i] = "(?, ?, NULLIF(?, ''), NULLIF(?, ''))"
+			args = append(args, obs.EntityID, obs.Content, writer, session)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO observations (entity_id, content, written_by, session) VALUES "+strings.Join(values, ", "),
+			args...,
+		); err != nil {
+			return nil, cancelledOr(ctx, err, "create_entities", created, len(entities))
+		}
+	}
 
-		entityID, err := result.LastInsertId()
+	if onDuplicate == DuplicateAppendObservations {
+		existingIDs, err := entityIDsTx(ctx, tx, graph, appendTo)
 		if err != nil {
-			return nil, err
+			return nil, cancelledOr(ctx, err, "create_entities", created, len(entities))
 		}
-
-		for _, obs := range entity.Observations {
-			_, err := tx.ExecContext(ctx,
-				"INSERT INTO observations (entity_id, content) VALUES (?, ?)",
-				entityID, obs,
-			)
+		for i, entity := range entities {
+			if results[i].Outcome != "" {
+				continue
+			}
+			id, ok := createdIDs[entity.Name]
+			if !ok {
+				id = existingIDs[entity.Name]
+			}
+			added, skipped, err := addObservationsTx(ctx, tx, id, entity.Observations, "")
+			if err == nil {
+				err = addTagsTx(ctx, tx, id, entity.Tags)
+			}
+			if err == nil {
+				_, err = patchAttributesTx(ctx, tx, id, entity.Name, entity.Attributes)
+			}
 			if err != nil {
-				return nil, err
+				return nil, cancelledOr(ctx, err, "create_entities", i, len(entities))
 			}
+			results[i].Outcome = OutcomeObservationsAppended
+			results[i].Observations = added
+			results[i].SkippedObservations = skipped
 		}
+	}
 
-		created = append(created, entity)
+	var createdNames, appendedNames []string
+	for _, result := range results {
+		switch result.Outcome {
+		case OutcomeCreated:
+			createdNames = append(createdNames, result.Name)
+		case OutcomeObservationsAppended:
+			appendedNames = append(appendedNames, result.Name)
+		}
+	}
+	if len(createdNames)+len(appendedNames) > 0 {
+		summary := fmt.Sprintf("created %d entities: %s", len(createdNames), auditNames(createdNames))
+		if len(appendedNames) > 0 {
+			summary += fmt.Sprintf("; appended observations to %s", auditNames(appendedNames))
+		}
+		if err := db.auditTx(ctx, tx, graph, "create_entities", append(createdNames, appendedNames...), summary); err != nil {
+			return nil, err
+		}
 	}
 
 	err = tx.Commit()
@@ -292,261 +542,808 @@ func (db *DB) CreateEntities(ctx context.Context, entities []EntityWithObservati
 
 	db.logger.Info("entities created successfully",
 		slog.Int("requested", len(entities)),
-		slog.Int("created", len(created)),
+		slog.Int("created", created),
 		slog.Duration("duration", time.Since(start)),
 	)
-	return created, nil
+	return results, nil
 }
 
-func (db *DB) CreateRelations(ctx context.Context, relations []RelationDTO) ([]RelationDTO, error) {
-	tx, err := db.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	created := []RelationDTO{}
-
-	for _, rel := range relations {
-		var fromID, toID int64
-		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.From).Scan(&fromID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				continue
-			}
-			return nil, err
-		}
-
-		err = tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.To).Scan(&toID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				continue
-			}
+// entityIDsTx returns the IDs of the named entities of a graph that exist, by name
+func entityIDsTx(ctx context.Context, tx *sql.Tx, graph int64, names []string) (map[string]int64, error) {
+	ids := make(map[string]int64, len(names))
+	for _, chunk := range chunks(names, maxListValues-1) {
+		list, args := stringList(chunk)
+		if err := scanEntityIDs(ctx, tx, ids, "SELECT id, name FROM entities WHERE graph_id = ? AND name IN "+list, append([]any{graph}, args...)...); err != nil {
 			return nil, err
 		}
+	}
+	return ids, nil
+}
 
-		var exists bool
-		err = tx.QueryRowContext(ctx,
-			"SELECT 1 FROM relations WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?",
-			fromID, toID, rel.RelationType,
-		).Scan(&exists)
-		if err != nil && err != sql.ErrNoRows {
-			return nil, err
-		}
-		if exists {
-			continue
+// scanEntityIDs runs query, which returns (id, name) rows, and adds them to ids
+func scanEntityIDs(ctx context.Context, tx *sql.Tx, ids map[string]int64, query string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
 		}
+		ids[name] = id
+	}
+	return rows.Err()
+}
 
-		_, err = tx.ExecContext(ctx,
-			"INSERT INTO relations (from_entity_id, to_entity_id, relation_type) VALUES (?, ?, ?)",
-			fromID, toID, rel.RelationType,
-		)
-		if err != nil {
-			return nil, err
-		}
+// CreateRelations creates the relations that are new and whose entities exist, and
+// reports the others as skipped with the reason. If any breaks a relation constraint,
+// nothing is created and a *RelationConstraintError lists every violation. The
+// entities are looked up together, and relations that already exist are skipped by
+// the insert itself, so each relation costs one statement unless its type is
+// constrained.
+func (db *DB) CreateRelations(ctx context.Context, relations []RelationDTO) (*RelationCreationResult, error) {
+	return db.CreateRelationsWithOptions(ctx, relations, RelationOptions{})
+}
 
-		created = append(created, rel)
-	}
+// CreateRelationsStrict is CreateRelations, except that if any relation names an
+// entity that doesn't exist nothing is created and a *MissingEndpointsError lists
+// every such relation
+func (db *DB) CreateRelationsStrict(ctx context.Context, relations []RelationDTO) (*RelationCreationResult, error) {
+	return db.CreateRelationsWithOptions(ctx, relations, RelationOptions{Strict: true})
+}
 
-	return created, tx.Commit()
+// CreateRelationsWithOptions is CreateRelations with options
+func (db *DB) CreateRelationsWithOptions(ctx context.Context, relations []RelationDTO, options RelationOptions) (*RelationCreationResult, error) {
+	return retryWriteResult(ctx, db, func() (*RelationCreationResult, error) {
+		return db.createRelations(ctx, relations, options)
+	})
 }
 
-func (db *DB) AddObservations(ctx context.Context, observations []ObservationAdditionInput) ([]ObservationAdditionResult, error) {
+// createRelations makes one attempt at CreateRelationsWithOptions
+func (db *DB) createRelations(ctx context.Context, relations []RelationDTO, options RelationOptions) (*RelationCreationResult, error) {
 	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	results := []ObservationAdditionResult{}
-
-	for _, obs := range observations {
-		var entityID int64
-		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", obs.EntityName).Scan(&entityID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("entity with name %s not found", obs.EntityName)
+	var names []string
+	seen := make(map[string]bool, 2*len(relations))
+	for _, rel := range relations {
+		for _, name := range []string{rel.From, rel.To} {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
 			}
+		}
+	}
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := entityIDsTx(ctx, tx, graph, names)
+	if err != nil {
+		return nil, cancelledOr(ctx, err, "create_relations", 0, len(relations))
+	}
+	// Ends naming no entity may be aliases of one
+	var unknown []string
+	for _, name := range names {
+		if _, ok := ids[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	aliased, err := resolveAliases(ctx, tx, graph, unknown)
+	if err != nil {
+		return nil, cancelledOr(ctx, err, "create_relations", 0, len(relations))
+	}
+	for alias, entity := range aliased {
+		ids[alias] = entity.id
+	}
+
+	result := NewRelationCreationResult()
+	var violations []ConstraintViolation
+
+	for i, rel := range relations {
+		if err := checkCancelled(ctx, "create_relations", i, len(relations)); err != nil {
 			return nil, err
 		}
 
-		added := []string{}
-		for _, content := range obs.Contents {
+		fromID, fromOK := ids[rel.From]
+		toID, toOK := ids[rel.To]
+		if reason := MissingEndpoint(fromOK, toOK); reason != "" {
+			result.Skip(i, rel, reason)
+			continue
+		}
+		// The relation is reported between the entities, not the aliases naming them
+		if entity, ok := aliased[rel.From]; ok {
+			rel.From = entity.name
+		}
+		if entity, ok := aliased[rel.To]; ok {
+			rel.To = entity.name
+		}
+
+		if c, ok := db.relationConstraints[rel.RelationType]; ok {
+			// An existing relation is skipped rather than counted against the limits
 			var exists bool
-			err := tx.QueryRowContext(ctx,
-				"SELECT 1 FROM observations WHERE entity_id = ? AND content = ?",
-				entityID, content,
+			err = tx.QueryRowContext(ctx,
+				"SELECT 1 FROM relations WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?",
+				fromID, toID, rel.RelationType,
 			).Scan(&exists)
 			if err != nil && err != sql.ErrNoRows {
-				return nil, err
+				return nil, cancelledOr(ctx, err, "create_relations", i, len(relations))
 			}
 			if exists {
+				if err := updateRelationTx(ctx, tx, result, i, rel, fromID, toID, options); err != nil {
+					return nil, cancelledOr(ctx, err, "create_relations", i, len(relations))
+				}
 				continue
 			}
 
-			_, err = tx.ExecContext(ctx,
-				"INSERT INTO observations (entity_id, content) VALUES (?, ?)",
-				entityID, content,
-			)
+			rule, limit, err := checkRelationConstraint(ctx, tx, c, fromID, toID, rel.RelationType)
 			if err != nil {
-				return nil, err
+				return nil, cancelledOr(ctx, err, "create_relations", i, len(relations))
+			}
+			if rule != "" {
+				violations = append(violations, ConstraintViolation{Index: i, Relation: rel, Rule: rule, Limit: limit})
+				continue
 			}
-			added = append(added, content)
 		}
 
-		results = append(results, ObservationAdditionResult{
-			EntityName:        obs.EntityName,
-			AddedObservations: added,
-		})
+		res, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO relations (from_entity_id, to_entity_id, relation_type, confidence, note, session) VALUES (?, ?, ?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''))",
+			fromID, toID, rel.RelationType, rel.Confidence, rel.Note, sessionFrom(ctx),
+		)
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "create_relations", i, len(relations))
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 0 {
+			if err := updateRelationTx(ctx, tx, result, i, rel, fromID, toID, options); err != nil {
+				return nil, cancelledOr(ctx, err, "create_relations", i, len(relations))
+			}
+			continue
+		}
+
+		result.Relations = append(result.Relations, rel)
 	}
 
-	return results, tx.Commit()
+	if missing := result.MissingEndpoints(); options.Strict && len(missing) > 0 {
+		return nil, &MissingEndpointsError{Skipped: missing}
+	}
+	if len(violations) > 0 {
+		return nil, &RelationConstraintError{Violations: violations}
+	}
+	if changed := append(slices.Clone(result.Relations), result.Updated...); len(changed) > 0 {
+		summary := fmt.Sprintf("created %d relations: %s", len(result.Relations), auditRelations(result.Relations))
+		if len(result.Updated) > 0 {
+			summary += fmt.Sprintf("; updated %s", auditRelations(result.Updated))
+		}
+		if err := db.auditTx(ctx, tx, graph, "create_relations", relationEntities(changed), summary); err != nil {
+			return nil, err
+		}
+	}
+	return result, tx.Commit()
 }
 
-func (db *DB) DeleteEntities(ctx context.Context, entityNames []string) error {
-	if len(entityNames) == 0 {
+// updateRelationTx handles the relation at index of a CreateRelations call, which
+// already exists: with options.UpdateExisting it sets the properties rel gives and
+// records the relation as updated, otherwise, or when rel gives none, it records
+// the relation as skipped
+func updateRelationTx(ctx context.Context, tx *sql.Tx, result *RelationCreationResult, index int, rel RelationDTO, fromID, toID int64, options RelationOptions) error {
+	if !options.UpdateExisting || (rel.Confidence == 0 && rel.Note == "") {
+		result.Skip(index, rel, SkipDuplicate)
 		return nil
 	}
-
-	placeholders := make([]string, len(entityNames))
-	args := make([]any, len(entityNames))
-	for i, name := range entityNames {
-		placeholders[i] = "?"
-		args[i] = name
+	updated := rel.key()
+	var confidence sql.NullFloat64
+	var note sql.NullString
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE relations SET confidence = COALESCE(NULLIF(?, 0), confidence), note = COALESCE(NULLIF(?, ''), note)
+		WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?
+		RETURNING confidence, note`,
+		rel.Confidence, rel.Note, fromID, toID, rel.RelationType,
+	).Scan(&confidence, &note); err != nil {
+		return err
 	}
+	updated.Confidence, updated.Note = confidence.Float64, note.String
+	result.Updated = append(result.Updated, updated)
+	return nil
+}
+
+func (db *DB) AddObservations(ctx context.Context, observations []ObservationAdditionInput) ([]ObservationAdditionResult, error) {
+	return db.AddObservationsIfAbsentSimilar(ctx, observations, 0)
+}
 
-	query := fmt.Sprintf("DELETE FROM entities WHERE name IN (%s)", strings.Join(placeholders, ","))
-	_, err := db.conn.ExecContext(ctx, query, args...)
-	return err
+// AddObservationsIfAbsentSimilar adds observations like AddObservations, but skips a
+// content when one of the entity's observations has a similarity of at least
+// threshold to it, reporting it in SkippedAsSimilar. Exact duplicates are skipped
+// silently as usual. Only the entity's most recent observations, up to the
+// observation limit, and those added earlier in the same call are compared.
+// A threshold of 0 disables the check.
+func (db *DB) AddObservationsIfAbsentSimilar(ctx context.Context, observations []ObservationAdditionInput, threshold float64) ([]ObservationAdditionResult, error) {
+	return retryWriteResult(ctx, db, func() ([]ObservationAdditionResult, error) {
+		return db.addObservationsIfAbsentSimilar(ctx, observations, threshold)
+	})
 }
 
-func (db *DB) DeleteObservations(ctx context.Context, deletions []ObservationDeletionInput) error {
+// addObservationsIfAbsentSimilar makes one attempt at AddObservationsIfAbsentSimilar
+func (db *DB) addObservationsIfAbsentSimilar(ctx context.Context, observations []ObservationAdditionInput, threshold float64) ([]ObservationAdditionResult, error) {
 	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	for _, del := range deletions {
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	results := []ObservationAdditionResult{}
+
+	for i, obs := range observations {
+		if err := checkCancelled(ctx, "add_observations", i, len(observations)); err != nil {
+			return nil, err
+		}
+
 		var entityID int64
-		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", del.EntityName).Scan(&entityID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				continue
+		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ?", graph, obs.EntityName).Scan(&entityID)
+		if err == sql.ErrNoRows {
+			// The name may be an alias of the entity, which is then reported by its name
+			aliased, aliasErr := resolveAliases(ctx, tx, graph, []string{obs.EntityName})
+			if aliasErr != nil {
+				return nil, cancelledOr(ctx, aliasErr, "add_observations", i, len(observations))
 			}
-			return err
+			entity, ok := aliased[obs.EntityName]
+			if !ok {
+				return nil, &EntityNotFoundError{Name: obs.EntityName}
+			}
+			entityID, obs.EntityName, err = entity.id, entity.name, nil
+		}
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "add_observations", i, len(observations))
 		}
 
-		for _, obs := range del.Observations {
-			_, err := tx.ExecContext(ctx,
-				"DELETE FROM observations WHERE entity_id = ? AND content = ?",
-				entityID, obs,
-			)
+		result := ObservationAdditionResult{EntityName: obs.EntityName}
+		expiresAt := expiryValue(obs.ExpiresAt)
+		if threshold > 0 {
+			result.AddedObservations, result.SkippedObservations, result.SkippedAsSimilar, err = db.addDissimilarObservationsTx(ctx, tx, entityID, obs.Contents, threshold, expiresAt)
+		} else {
+			result.AddedObservations, result.SkippedObservations, err = addObservationsTx(ctx, tx, entityID, obs.Contents, expiresAt)
+		}
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "add_observations", i, len(observations))
+		}
+		if len(result.AddedObservations) > 0 {
+			if !obs.ExpiresAt.IsZero() {
+				result.ExpiresAt = obs.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			result.EvictedObservations, err = db.enforceObservationCapTx(ctx, tx, entityID, obs.EntityName)
 			if err != nil {
-				return err
+				return nil, cancelledOr(ctx, err, "add_observations", i, len(observations))
 			}
 		}
+
+		results = append(results, result)
 	}
 
-	return tx.Commit()
+	var names, added []string
+	for _, result := range results {
+		if len(result.AddedObservations) == 0 {
+			continue
+		}
+		names = append(names, result.EntityName)
+		added = append(added, fmt.Sprintf("%s: %s", result.EntityName, auditQuoted(result.AddedObservations)))
+		if len(result.EvictedObservations) > 0 {
+			added = append(added, fmt.Sprintf("%s evicted: %s", result.EntityName, auditQuoted(result.EvictedObservations)))
+		}
+	}
+	if len(names) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "add_observations", names, "added "+strings.Join(added, "; ")); err != nil {
+			return nil, err
+		}
+	}
+	return results, tx.Commit()
 }
 
-func (db *DB) DeleteRelations(ctx context.Context, relations []RelationDTO) error {
-	tx, err := db.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+// DeleteEntities deletes entities along with their observations and relations.
+// Observations are removed first in batches of deleteBatchSize, each in its own
+// transaction, so deleting an entity with a very large observation set doesn't hold
+// the write lock for the whole cascade. If a later batch fails the entity remains
+// with its remaining observations; retrying the delete finishes the job.
+// addObservationsTx adds the contents an entity doesn't already have, expiring at
+// expiresAt as stored (empty = never), and returns them, along with those it already
+// had before the call
+func addObservationsTx(ctx context.Context, tx *sql.Tx, entityID int64, contents []string, expiresAt string) ([]string, []string, error) {
+	if err := purgeEntityExpiredTx(ctx, tx, entityID); err != nil {
+		return nil, nil, err
 	}
-	defer tx.Rollback()
-
-	for _, rel := range relations {
-		var fromID, toID int64
-		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.From).Scan(&fromID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				continue
-			}
-			return err
+	added, existed := []string{}, []string{}
+	seen := map[string]bool{}
+	for _, content := range contents {
+		if seen[content] {
+			continue
 		}
-
-		err = tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.To).Scan(&toID)
+		seen[content] = true
+		exists, err := observationExistsTx(ctx, tx, entityID, content)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				continue
-			}
-			return err
+			return nil, nil, err
+		}
+		if exists {
+			existed = append(existed, content)
+			continue
 		}
 
 		_, err = tx.ExecContext(ctx,
-			"DELETE FROM relations WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?",
-			fromID, toID, rel.RelationType,
+			insertObservationSQL,
+			entityID, content, writerFrom(ctx), sessionFrom(ctx), expiresAt,
 		)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+		added = append(added, content)
 	}
+	return added, existed, nil
+}
 
-	return tx.Commit()
+// observationExistsTx reports whether an entity already has an observation
+func observationExistsTx(ctx context.Context, tx *sql.Tx, entityID int64, content string) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT 1 FROM observations WHERE entity_id = ? AND content = ?",
+		entityID, content,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return exists, err
 }
 
+// addDissimilarObservationsTx adds the contents that are neither exact duplicates nor
+// similar to one of the entity's recent observations, and returns them along with the
+// exact duplicates the entity had before the call and the similar ones. The
+// observations are fetched once, and each added content joins them for the contents
+// after it.
+func (db *DB) addDissimilarObservationsTx(ctx context.Context, tx *sql.Tx, entityID int64, contents []string, threshold float64, expiresAt string) ([]string, []string, []SimilarObservation, error) {
+	if err := purgeEntityExpiredTx(ctx, tx, entityID); err != nil {
+		return nil, nil, nil, err
+	}
+	limit := db.observationLimit
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := tx.QueryContext(ctx,
+		"SELECT content FROM observations WHERE entity_id = ? ORDER BY created_at DESC, id DESC LIMIT ?",
+		entityID, limit,
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	existing := []string{}
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			rows.Close()
+			return nil, nil, nil, err
+		}
+		existing = append(existing, content)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	added, existed := []string{}, []string{}
+	skipped := []SimilarObservation{}
+	seen := map[string]bool{}
+	for _, content := range contents {
+		if seen[content] {
+			continue
+		}
+		seen[content] = true
+		exists, err := observationExistsTx(ctx, tx, entityID, content)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if exists {
+			existed = append(existed, content)
+			continue
+		}
+		if match, score := mostSimilar(content, existing); score >= threshold {
+			skipped = append(skipped, SimilarObservation{Content: content, Existing: match, Similarity: math.Round(score*1000) / 1000})
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			insertObservationSQL,
+			entityID, content, writerFrom(ctx), sessionFrom(ctx), expiresAt,
+		); err != nil {
+			return nil, nil, nil, err
+		}
+		added = append(added, content)
+		existing = append(existing, content)
+	}
+	return added, existed, skipped, nil
+}
+
+// DeleteEntities deletes the named entities with their observations and relations,
+// or moves them to the trash under SetSoftDelete, and reports how many existed.
+// Pinned entities are kept and reported as protected, unless ctx is from
+// WithForceDelete.
+func (db *DB) DeleteEntities(ctx context.Context, entityNames []string) (*EntityDeletionResult, error) {
+	deleted := map[string]bool{}
+	if len(entityNames) == 0 {
+		return NewEntityDeletionResult(nil, deleted), nil
+	}
+	graph, err := graphID(ctx, db.conn)
+	if err != nil {
+		return nil, err
+	}
+	protected, err := protectedAmong(ctx, db.conn, graph, entityNames)
+	if err != nil {
+		return nil, err
+	}
+	entityNames = withoutNames(entityNames, protected)
+
+	deleteChunk := db.deleteEntityChunk
+	if db.softDelete {
+		// The trash keeps the observations
+		deleteChunk = db.trashEntityChunk
+	} else {
+		for _, name := range entityNames {
+			if err := db.deleteObservationsInBatches(ctx, graph, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, chunk := range chunks(dedupe(entityNames), maxListValues-1) {
+		names, err := retryWriteResult(ctx, db, func() ([]string, error) {
+			return deleteChunk(ctx, graph, chunk)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			deleted[name] = true
+		}
+	}
+	result := NewEntityDeletionResult(entityNames, deleted)
+	result.Protected = protected
+	return result, nil
+}
+
+// deleteEntityChunk deletes the named entities of a graph, returning the names of
+// those that existed
+func (db *DB) deleteEntityChunk(ctx context.Context, graph int64, names []string) ([]string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	list, args := stringList(names)
+	rows, err := tx.QueryContext(ctx, "DELETE FROM entities WHERE graph_id = ? AND name IN "+list+" RETURNING name", append([]any{graph}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deleted = append(deleted, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(deleted) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "delete_entities", deleted, "deleted "+auditNames(deleted)); err != nil {
+			return nil, err
+		}
+	}
+	return deleted, tx.Commit()
+}
+
+// deleteObservationsInBatches removes all observations of an entity of a graph, at most deleteBatchSize per statement
+func (db *DB) deleteObservationsInBatches(ctx context.Context, graph int64, entityName string) error {
+	for {
+		result, err := retryWriteResult(ctx, db, func() (sql.Result, error) {
+			return db.conn.ExecContext(ctx, `
+				DELETE FROM observations WHERE id IN (
+					SELECT o.id FROM observations o
+					JOIN entities e ON e.id = o.entity_id
+					WHERE e.graph_id = ? AND e.name = ?
+					LIMIT ?
+				)`, graph, entityName, deleteBatchSize)
+		})
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			db.logger.Debug("deleted observation batch",
+				slog.String("entity", entityName),
+				slog.Int64("deleted", n),
+			)
+		}
+		if n < deleteBatchSize {
+			return nil
+		}
+	}
+}
+
+// DeleteObservations deletes the given observations of each entity, and reports
+// how many existed
+func (db *DB) DeleteObservations(ctx context.Context, deletions []ObservationDeletionInput) (*ObservationDeletionResult, error) {
+	return retryWriteResult(ctx, db, func() (*ObservationDeletionResult, error) {
+		return db.deleteObservations(ctx, deletions)
+	})
+}
+
+// deleteObservations makes one attempt at DeleteObservations
+func (db *DB) deleteObservations(ctx context.Context, deletions []ObservationDeletionInput) (*ObservationDeletionResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	result := NewObservationDeletionResult()
+	missing := map[string]bool{}
+	seen := map[[2]string]bool{}
+	var names []string
+	removed := map[string][]string{}
+	for i, del := range deletions {
+		if err := checkCancelled(ctx, "delete_observations", i, len(deletions)); err != nil {
+			return nil, err
+		}
+
+		var entityID int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ?", graph, del.EntityName).Scan(&entityID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				if !missing[del.EntityName] {
+					missing[del.EntityName] = true
+					result.NotFound = append(result.NotFound, del.EntityName)
+				}
+				continue
+			}
+			return nil, cancelledOr(ctx, err, "delete_observations", i, len(deletions))
+		}
+
+		for _, obs := range del.Observations {
+			res, err := tx.ExecContext(ctx,
+				"DELETE FROM observations WHERE entity_id = ? AND content = ?",
+				entityID, obs,
+			)
+			if err != nil {
+				return nil, cancelledOr(ctx, err, "delete_observations", i, len(deletions))
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			// An observation given twice was deleted the first time
+			key := [2]string{del.EntityName, obs}
+			if n > 0 {
+				result.DeletedObservations += int(n)
+				if removed[del.EntityName] == nil {
+					names = append(names, del.EntityName)
+				}
+				removed[del.EntityName] = append(removed[del.EntityName], obs)
+			} else if !seen[key] {
+				result.AddMissing(del.EntityName, obs)
+			}
+			seen[key] = true
+		}
+	}
+
+	if len(names) > 0 {
+		summaries := make([]string, len(names))
+		for i, name := range names {
+			summaries[i] = fmt.Sprintf("%s: %s", name, auditQuoted(removed[name]))
+		}
+		if err := db.auditTx(ctx, tx, graph, "delete_observations", names, "deleted "+strings.Join(summaries, "; ")); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteRelations deletes the given relations, and reports how many existed
+func (db *DB) DeleteRelations(ctx context.Context, relations []RelationDTO) (*RelationDeletionResult, error) {
+	return retryWriteResult(ctx, db, func() (*RelationDeletionResult, error) {
+		return db.deleteRelations(ctx, relations)
+	})
+}
+
+// deleteRelations makes one attempt at DeleteRelations
+func (db *DB) deleteRelations(ctx context.Context, relations []RelationDTO) (*RelationDeletionResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	result := NewRelationDeletionResult()
+	seen := map[RelationDTO]bool{}
+	var deleted []RelationDTO
+	for i, rel := range relations {
+		if err := checkCancelled(ctx, "delete_relations", i, len(relations)); err != nil {
+			return nil, err
+		}
+		// A relation given twice was deleted or found missing the first time
+		if seen[rel.key()] {
+			continue
+		}
+		seen[rel.key()] = true
+
+		var fromID, toID int64
+		const lookup = "SELECT id FROM entities WHERE graph_id = ? AND name = ?"
+		err := tx.QueryRowContext(ctx, lookup, graph, rel.From).Scan(&fromID)
+		if err == nil {
+			err = tx.QueryRowContext(ctx, lookup, graph, rel.To).Scan(&toID)
+		}
+		if err != nil {
+			if err == sql.ErrNoRows {
+				result.NotFound = append(result.NotFound, rel)
+				continue
+			}
+			return nil, cancelledOr(ctx, err, "delete_relations", i, len(relations))
+		}
+
+		res, err := tx.ExecContext(ctx,
+			"DELETE FROM relations WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?",
+			fromID, toID, rel.RelationType,
+		)
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "delete_relations", i, len(relations))
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			result.NotFound = append(result.NotFound, rel)
+		} else {
+			deleted = append(deleted, rel.key())
+		}
+		result.DeletedRelations += int(n)
+	}
+
+	if len(deleted) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "delete_relations", relationEntities(deleted), "deleted "+auditRelations(deleted)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReadGraph returns the whole graph named by ctx with at most the configured
+// observation limit per entity
 func (db *DB) ReadGraph(ctx context.Context) (*KnowledgeGraph, error) {
+	return db.readGraph(ctx, db.observationLimit)
+}
+
+// readGraph returns the whole graph with at most observationLimit observations per entity (0 = all)
+func (db *DB) readGraph(ctx context.Context, observationLimit int) (*KnowledgeGraph, error) {
 	start := time.Now()
 	db.logger.Debug("reading entire graph")
 
+	// One transaction keeps the entities and relations from the same snapshot, so
+	// a concurrent delete can't leave relations to entities missing from the graph
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	scope, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Counting first sizes the results up front instead of growing them row by row
+	var entityCount, relationCount int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM entities WHERE graph_id = ?),
+			(SELECT COUNT(*) FROM relations r JOIN entities e ON e.id = r.from_entity_id WHERE e.graph_id = ?)`,
+		scope, scope,
+	).Scan(&entityCount, &relationCount); err != nil {
+		return nil, err
+	}
 	graph := &KnowledgeGraph{
-		Entities:  []EntityWithObservations{},
-		Relations: []RelationDTO{},
+		Entities:  make([]EntityWithObservations, 0, entityCount),
+		Relations: make([]RelationDTO, 0, relationCount),
 	}
+	types := interner{}
 
-	// Optimized query using GROUP_CONCAT to avoid N+1 problem
-	rows, err := db.conn.QueryContext(ctx, `
+	// Correlated subqueries fetch each entity's observations in one query, avoiding N+1
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
 		SELECT 
 			e.id, 
 			e.name, 
 			e.entity_type,
-			COALESCE(GROUP_CONCAT(o.content, '|||'), '') as observations
+			%s,
+			%s,
+			%s,
+			%s
 		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		GROUP BY e.id, e.name, e.entity_type
+		WHERE e.graph_id = ?
 		ORDER BY e.name
-	`)
+	`, readObservationColumns(ctx, observationLimit), tagsColumn, attributesColumn, pinnedColumn), scope)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	entityMap := make(map[int64]string)
+	// Scanned into for every row rather than allocated per row; Scan resets them
+	var tagsStr, attributesStr sql.NullString
 	for rows.Next() {
 		var id int64
 		var entity EntityWithObservations
 		var observationsStr string
 
-		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &observationsStr); err != nil {
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &entity.TotalObservations, &observationsStr, &tagsStr, &attributesStr, &entity.Pinned); err != nil {
 			return nil, err
 		}
+		entity.EntityType = types.intern(entity.EntityType)
 
-		entityMap[id] = entity.Name
-
-		// Parse observations from concatenated string
-		if observationsStr != "" {
-			entity.Observations = strings.Split(observationsStr, "|||")
-		} else {
-			entity.Observations = []string{}
+		if entity.Observations, err = splitObservations(observationsStr); err != nil {
+			return nil, err
+		}
+		if entity.Tags, err = splitTags(tagsStr); err != nil {
+			return nil, err
+		}
+		if entity.Attributes, err = splitAttributes(attributesStr); err != nil {
+			return nil, err
 		}
 
 		graph.Entities = append(graph.Entities, entity)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	// Optimized query with JOINs to get relation names directly
-	relRows, err := db.conn.QueryContext(ctx, `
+	relRows, err := tx.QueryContext(ctx, `
         SELECT 
             e1.name as from_name,
             e2.name as to_name,
-            r.relation_type
+            r.relation_type,
+            COALESCE(r.confidence, 0),
+            COALESCE(r.note, '')
         FROM relations r
         JOIN entities e1 ON r.from_entity_id = e1.id
         JOIN entities e2 ON r.to_entity_id = e2.id
+        WHERE e1.graph_id = ?
         ORDER BY e1.name, e2.name, r.relation_type
-    `)
+    `, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -554,11 +1351,18 @@ func (db *DB) ReadGraph(ctx context.Context) (*KnowledgeGraph, error) {
 
 	for relRows.Next() {
 		var rel RelationDTO
-		if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
+		if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType, &rel.Confidence, &rel.Note); err != nil {
 			return nil, err
 		}
+		rel.RelationType = types.intern(rel.RelationType)
 		graph.Relations = append(graph.Relations, rel)
 	}
+	if err := relRows.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	db.logger.Info("graph read successfully",
 		slog.Int("entities", len(graph.Entities)),
@@ -568,110 +1372,309 @@ func (db *DB) ReadGraph(ctx context.Context) (*KnowledgeGraph, error) {
 	return graph, nil
 }
 
-func (db *DB) SearchNodes(ctx context.Context, query string) (*KnowledgeGraph, error) {
-	graph := &KnowledgeGraph{
-		Entities:  []EntityWithObservations{},
-		Relations: []RelationDTO{},
+// ReadGraphPage returns up to limit entities whose names sort after afterName, in name
+// order, with the relations among them as OpenNodes returns them. Relations to entities
+// on other pages are left out. Pass the page's NextCursor as afterName to get the next
+// one; an empty afterName starts from the first entity.
+func (db *DB) ReadGraphPage(ctx context.Context, limit int, afterName string) (*GraphPage, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid page limit %d", limit)
 	}
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.reader.QueryContext(ctx,
+		"SELECT name FROM entities WHERE graph_id = ? AND name > ? ORDER BY name LIMIT ?", graph, afterName, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0, limit+1)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &GraphPage{}
+	if len(names) > limit {
+		names = names[:limit]
+		page.NextCursor = names[limit-1]
+	}
+	nodes, err := db.OpenNodes(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	page.KnowledgeGraph = *nodes
+	return page, nil
+}
 
-	searchPattern := "%" + query + "%"
+// likeSearchCondition builds the SearchNodes filter for an entity aliased as e: the
+// query is split on whitespace and every term must appear in the entity's name, type,
+// one of its observations, one of its aliases or the type of one of its relations. %
+// and _ in the terms match themselves.
+func likeSearchCondition(query string) (string, []any) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		terms = []string{query}
+	}
 
-	// Optimized query using CTE and GROUP_CONCAT to avoid N+1 problem
-	rows, err := db.conn.QueryContext(ctx, `
+	groups := make([]string, len(terms))
+	args := make([]any, 0, len(terms)*5)
+	for i, term := range terms {
+		groups[i] = `(e.name LIKE ? ESCAPE '\' OR e.entity_type LIKE ? ESCAPE '\' OR
+				EXISTS (SELECT 1 FROM observations o WHERE o.entity_id = e.id AND o.content LIKE ? ESCAPE '\' AND ` + liveObservation("o") + `) OR
+				EXISTS (SELECT 1 FROM entity_aliases a WHERE a.entity_id = e.id AND a.alias LIKE ? ESCAPE '\') OR
+				EXISTS (SELECT 1 FROM relations r WHERE (r.from_entity_id = e.id OR r.to_entity_id = e.id) AND r.relation_type LIKE ? ESCAPE '\'))`
+		pattern := "%" + escapeLike(term) + "%"
+		args = append(args, pattern, pattern, pattern, pattern, pattern)
+	}
+	return strings.Join(groups, " AND "), args
+}
+
+// SearchNodes finds entities whose name, type, observations, aliases or relation types
+// contain every whitespace-separated term of query, returning limit of them (0 = all) after skipping
+// offset, in name order
+func (db *DB) SearchNodes(ctx context.Context, query string, limit, offset int) (*SearchResult, error) {
+	condition, args := likeSearchCondition(query)
+	return db.searchEntities(ctx, "SELECT e.id FROM entities e WHERE "+condition, args, false, limit, offset)
+}
+
+// Search modes: SearchNodes and SearchNodesFTS match substrings or words anywhere,
+// MatchNodes matches whole names and types or their beginnings
+const (
+	SearchSubstring = "substring"
+	SearchExact     = "exact"
+	SearchPrefix    = "prefix"
+)
+
+// MatchNodes finds entities whose name, type or an alias equals query (SearchExact,
+// case sensitive) or starts with it (SearchPrefix, ignoring ASCII case), returning
+// limit of them (0 = all) after skipping offset, in name order. Observations are not
+// searched.
+func (db *DB) MatchNodes(ctx context.Context, query, mode string, limit, offset int) (*SearchResult, error) {
+	switch mode {
+	case SearchExact:
+		return db.searchEntities(ctx,
+			"SELECT e.id FROM entities e WHERE e.name = ? OR e.entity_type = ? OR e.id IN (SELECT entity_id FROM entity_aliases WHERE alias = ?)",
+			[]any{query, query, query}, false, limit, offset)
+	case SearchPrefix:
+		pattern := escapeLike(query) + "%"
+		return db.searchEntities(ctx,
+			`SELECT e.id FROM entities e WHERE e.name LIKE ? ESCAPE '\' OR e.entity_type LIKE ? ESCAPE '\'
+				OR e.id IN (SELECT entity_id FROM entity_aliases WHERE alias LIKE ? ESCAPE '\')`,
+			[]any{pattern, pattern, pattern}, false, limit, offset)
+	default:
+		return nil, fmt.Errorf("invalid match mode %q: must be %q or %q", mode, SearchExact, SearchPrefix)
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s for a pattern using ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// searchEntities returns one page of the entities whose ids matched selects, in name
+// order, with the relations among the entities on the page. When ranked, matched also
+// selects a score for each id, which orders the entities, best first, and is set on
+// them.
+func (db *DB) searchEntities(ctx context.Context, matched string, args []any, ranked bool, limit, offset int) (*SearchResult, error) {
+	result := &SearchResult{
+		KnowledgeGraph: KnowledgeGraph{
+			Entities:  []EntityWithObservations{},
+			Relations: []RelationDTO{},
+		},
+		Offset: offset,
+	}
+
+	types := interner{}
+
+	// SQLite reads a negative LIMIT as no limit
+	pageLimit := limit
+	if pageLimit == 0 {
+		pageLimit = -1
+	}
+
+	score, source, order := "", "WHERE e.id IN (SELECT id FROM matched_entities) AND", "e.name"
+	if ranked {
+		score, source, order = ", m.score", "JOIN matched_entities m ON m.id = e.id WHERE", "m.score DESC, e.name"
+	}
+
+	// The page, its count and its relations are read from one snapshot
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Matches are narrowed to the graph and tags here, so the matching queries needn't be
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	scope, scopeArgs := "e.graph_id = ?", []any{graph}
+	if tagged, tagArgs := tagCondition(ctx); tagged != "" {
+		scope, scopeArgs = scope+" AND "+tagged, append(scopeArgs, tagArgs...)
+	}
+	// Entities matching by their attributes join the matches, scoring like an
+	// observation match when ranked
+	if attributed, attributeArgs := attributeCondition(ctx); attributed != "" {
+		if ranked {
+			matched = "SELECT id, MAX(score) AS score FROM (" + matched +
+				" UNION ALL SELECT e.id, ? FROM entities e WHERE " + attributed + ") GROUP BY id"
+			args = slices.Concat(args, []any{ScoreObservationMatch}, attributeArgs)
+		} else {
+			matched += " UNION SELECT e.id FROM entities e WHERE " + attributed
+			args = slices.Concat(args, attributeArgs)
+		}
+	}
+
+	// CTE finds the matches; correlated subqueries fetch their observations without N+1
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
 		WITH matched_entities AS (
-			SELECT DISTINCT e.id
-			FROM entities e
-			LEFT JOIN observations o ON e.id = o.entity_id
-			WHERE 
-				e.name LIKE ? OR
-				e.entity_type LIKE ? OR
-				o.content LIKE ?
+			%s
 		)
 		SELECT 
 			e.id,
 			e.name,
 			e.entity_type,
-			COALESCE(GROUP_CONCAT(o.content, '|||'), '') as observations
+			%s,
+			%s,
+			%s,
+			%s%s
 		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		WHERE e.id IN (SELECT id FROM matched_entities)
-		GROUP BY e.id, e.name, e.entity_type
-		ORDER BY e.name
-	`, searchPattern, searchPattern, searchPattern)
+		%s %s
+		ORDER BY %s
+		LIMIT ? OFFSET ?
+	`, matched, readObservationColumns(ctx, db.observationLimit), tagsColumn, attributesColumn, pinnedColumn, score, source, scope, order),
+		slices.Concat(args, scopeArgs, []any{pageLimit, offset})...)
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	entityIDs := []int64{}
-	entityMap := make(map[int64]string)
+	byID := map[int64]string{}
 
 	for rows.Next() {
 		var id int64
 		var entity EntityWithObservations
 		var observationsStr string
+		var tagsStr, attributesStr sql.NullString
 
-		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &observationsStr); err != nil {
+		dest := []any{&id, &entity.Name, &entity.EntityType, &entity.TotalObservations, &observationsStr, &tagsStr, &attributesStr, &entity.Pinned}
+		if ranked {
+			dest = append(dest, &entity.Score)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
+		entity.EntityType = types.intern(entity.EntityType)
 
-		entityIDs = append(entityIDs, id)
-		entityMap[id] = entity.Name
+		byID[id] = entity.Name
 
-		// Parse observations from concatenated string
-		if observationsStr != "" {
-			entity.Observations = strings.Split(observationsStr, "|||")
-		} else {
-			entity.Observations = []string{}
+		if entity.Observations, err = splitObservations(observationsStr); err != nil {
+			return nil, err
+		}
+		if entity.Tags, err = splitTags(tagsStr); err != nil {
+			return nil, err
+		}
+		if entity.Attributes, err = splitAttributes(attributesStr); err != nil {
+			return nil, err
 		}
 
-		graph.Entities = append(graph.Entities, entity)
+		result.Entities = append(result.Entities, entity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Get relations between matched entities with optimized query
-	if len(entityIDs) > 0 {
-		placeholders := make([]string, len(entityIDs))
-		args := make([]interface{}, 0, len(entityIDs)*2)
-
-		for i, id := range entityIDs {
-			placeholders[i] = "?"
-			args = append(args, id)
+	// Without paging the page holds every match, so there is nothing to count
+	result.TotalMatches = len(result.Entities)
+	if limit > 0 || offset > 0 {
+		if err := tx.QueryRowContext(ctx, fmt.Sprintf(`
+			WITH matched_entities AS (
+				%s
+			)
+			SELECT COUNT(*) FROM entities e WHERE e.id IN (SELECT id FROM matched_entities) AND %s
+		`, matched, scope), slices.Concat(args, scopeArgs)...).Scan(&result.TotalMatches); err != nil {
+			return nil, err
 		}
+	}
+	if next := offset + len(result.Entities); limit > 0 && next < result.TotalMatches {
+		result.NextOffset = &next
+	}
 
-		// Duplicate the args for both IN clauses
-		args = append(args, args[:len(entityIDs)]...)
+	// Get relations between the entities on the page
+	if result.Relations, err = relationsAmong(ctx, tx, byID, types); err != nil {
+		return nil, err
+	}
 
-		relQuery := fmt.Sprintf(`
-			SELECT 
-				e1.name as from_name,
-				e2.name as to_name,
-				r.relation_type
-			FROM relations r
-			JOIN entities e1 ON r.from_entity_id = e1.id
-			JOIN entities e2 ON r.to_entity_id = e2.id
-			WHERE r.from_entity_id IN (%s) AND r.to_entity_id IN (%s)
-			ORDER BY e1.name, e2.name, r.relation_type
-		`, strings.Join(placeholders, ","), strings.Join(placeholders, ","))
-
-		relRows, err := db.conn.QueryContext(ctx, relQuery, args...)
+	return result, tx.Commit()
+}
+
+// relationsAmong returns the relations whose entities both have IDs in names, which
+// maps them to their names, ordered by from and to name, then relation type. It
+// reads the relations from each entity and drops those leading elsewhere, so any
+// number of entities takes one IN list of them per chunk. q should be the
+// transaction the entities were read in, so the relations match them.
+func relationsAmong(ctx context.Context, q relationQuerier, names map[int64]string, types interner) ([]RelationDTO, error) {
+	relations := []RelationDTO{}
+	ids := make([]int64, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	for _, chunk := range chunks(ids, maxListValues) {
+		list, args := inList(chunk)
+		rows, err := q.QueryContext(ctx,
+			"SELECT from_entity_id, to_entity_id, relation_type, COALESCE(confidence, 0), COALESCE(note, '') FROM relations WHERE from_entity_id IN "+list, args...)
 		if err != nil {
 			return nil, err
 		}
-		defer relRows.Close()
-
-		for relRows.Next() {
-			var rel RelationDTO
-			if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
+		for rows.Next() {
+			var from, to int64
+			var relationType, note string
+			var confidence float64
+			if err := rows.Scan(&from, &to, &relationType, &confidence, &note); err != nil {
+				rows.Close()
 				return nil, err
 			}
-			graph.Relations = append(graph.Relations, rel)
+			if toName, ok := names[to]; ok {
+				relations = append(relations, RelationDTO{From: names[from], To: toName, RelationType: types.intern(relationType), Confidence: confidence, Note: note})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
 		}
 	}
+	sortRelations(relations)
+	return relations, nil
+}
 
-	return graph, nil
+// sortRelations orders relations by source, target and type
+func sortRelations(relations []RelationDTO) {
+	sort.Slice(relations, func(i, j int) bool {
+		a, b := relations[i], relations[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.RelationType < b.RelationType
+	})
 }
 
+// OpenNodes returns the named entities with the relations among them. A name no
+// entity has that is an alias opens the entity it names.
 func (db *DB) OpenNodes(ctx context.Context, names []string) (*KnowledgeGraph, error) {
 	graph := &KnowledgeGraph{
 		Entities:  []EntityWithObservations{},
@@ -681,98 +1684,176 @@ func (db *DB) OpenNodes(ctx context.Context, names []string) (*KnowledgeGraph, e
 	if len(names) == 0 {
 		return graph, nil
 	}
+	graph.Entities = make([]EntityWithObservations, 0, len(names))
+	types := interner{}
 
-	placeholders := make([]string, len(names))
-	args := make([]interface{}, len(names))
-	for i, name := range names {
-		placeholders[i] = "?"
-		args[i] = name
+	// Every chunk and the relations are read from one snapshot
+	tx, err := db.reader.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	// Optimized query using GROUP_CONCAT to avoid N+1 problem
-	query := fmt.Sprintf(`
-		SELECT 
-			e.id,
-			e.name,
-			e.entity_type,
-			COALESCE(GROUP_CONCAT(o.content, '|||'), '') as observations
-		FROM entities e
-		LEFT JOIN observations o ON e.id = o.entity_id
-		WHERE e.name IN (%s)
-		GROUP BY e.id, e.name, e.entity_type
-		ORDER BY e.name
-	`, strings.Join(placeholders, ","))
-
-	rows, err := db.conn.QueryContext(ctx, query, args...)
+	scope, err := graphID(ctx, tx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	// Aliases open the entities they name, once however many of its names are given
+	aliased, err := resolveAliases(ctx, tx, scope, names)
+	if err != nil {
+		return nil, err
+	}
+	unique := canonicalNames(names, aliased)
+	tagged, tagArgs := tagCondition(ctx)
+	if tagged != "" {
+		tagged = " AND " + tagged
+	}
 
-	entityIDs := []int64{}
-	entityMap := make(map[int64]string)
+	byID := map[int64]string{}
+	for _, chunk := range chunks(unique, maxListValues-1-len(tagArgs)) {
+		list, args := stringList(chunk)
 
-	for rows.Next() {
-		var id int64
-		var entity EntityWithObservations
-		var observationsStr string
+		// Correlated subqueries fetch each entity's observations in one query, avoiding N+1
+		query := fmt.Sprintf(`
+			SELECT 
+				e.id,
+				e.name,
+				e.entity_type,
+				%s,
+				%s,
+				%s,
+				%s
+			FROM entities e
+			WHERE e.graph_id = ? AND e.name IN %s%s
+			ORDER BY e.name
+		`, readObservationColumns(ctx, db.observationLimit), tagsColumn, attributesColumn, pinnedColumn, list, tagged)
 
-		if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &observationsStr); err != nil {
+		rows, err := tx.QueryContext(ctx, query, slices.Concat([]any{scope}, args, tagArgs)...)
+		if err != nil {
 			return nil, err
 		}
 
-		entityIDs = append(entityIDs, id)
-		entityMap[id] = entity.Name
+		for rows.Next() {
+			var id int64
+			var entity EntityWithObservations
+			var observationsStr string
+			var tagsStr, attributesStr sql.NullString
 
-		// Parse observations from concatenated string
-		if observationsStr != "" {
-			entity.Observations = strings.Split(observationsStr, "|||")
-		} else {
-			entity.Observations = []string{}
-		}
+			if err := rows.Scan(&id, &entity.Name, &entity.EntityType, &entity.TotalObservations, &observationsStr, &tagsStr, &attributesStr, &entity.Pinned); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			entity.EntityType = types.intern(entity.EntityType)
 
-		graph.Entities = append(graph.Entities, entity)
-	}
+			byID[id] = entity.Name
 
-	// Get relations between opened nodes with optimized query
-	if len(entityIDs) > 0 {
-		placeholders := make([]string, len(entityIDs))
-		relArgs := make([]any, 0, len(entityIDs)*2)
+			if entity.Observations, err = splitObservations(observationsStr); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if entity.Tags, err = splitTags(tagsStr); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if entity.Attributes, err = splitAttributes(attributesStr); err != nil {
+				rows.Close()
+				return nil, err
+			}
 
-		for i, id := range entityIDs {
-			placeholders[i] = "?"
-			relArgs = append(relArgs, id)
+			graph.Entities = append(graph.Entities, entity)
 		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(unique) > maxListValues-1-len(tagArgs) {
+		sort.Slice(graph.Entities, func(i, j int) bool { return graph.Entities[i].Name < graph.Entities[j].Name })
+	}
 
-		// Duplicate for both IN clauses
-		relArgs = append(relArgs, relArgs[:len(entityIDs)]...)
-
-		relQuery := fmt.Sprintf(`
-			SELECT 
-				e1.name as from_name,
-				e2.name as to_name,
-				r.relation_type
-			FROM relations r
-			JOIN entities e1 ON r.from_entity_id = e1.id
-			JOIN entities e2 ON r.to_entity_id = e2.id
-			WHERE r.from_entity_id IN (%s) AND r.to_entity_id IN (%s)
-			ORDER BY e1.name, e2.name, r.relation_type
-		`, strings.Join(placeholders, ","), strings.Join(placeholders, ","))
-
-		relRows, err := db.conn.QueryContext(ctx, relQuery, relArgs...)
+	// Get relations between opened nodes
+	if graph.Relations, err = relationsAmong(ctx, tx, byID, types); err != nil {
+		return nil, err
+	}
+	if mode := externalRelationsFrom(ctx); mode != ExternalRelationsNone {
+		external, err := relationsAcross(ctx, tx, byID, mode, types)
 		if err != nil {
 			return nil, err
 		}
-		defer relRows.Close()
+		graph.Relations = append(graph.Relations, external...)
+		sortRelations(graph.Relations)
+	}
 
-		for relRows.Next() {
-			var rel RelationDTO
-			if err := relRows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
-				return nil, err
-			}
-			graph.Relations = append(graph.Relations, rel)
+	return graph, tx.Commit()
+}
+
+// Observation orderings accepted by GetObservations
+const (
+	ObservationOrderOldest = "oldest"
+	ObservationOrderNewest = "newest"
+)
+
+// GetObservations returns one page of an entity's observations ordered by creation time.
+// It reads through the (entity_id, created_at) index, so each page costs O(limit)
+// regardless of how many observations the entity has.
+func (db *DB) GetObservations(ctx context.Context, entityName string, limit, offset int, orderBy string) (*ObservationPage, error) {
+	direction := "ASC"
+	switch orderBy {
+	case "", ObservationOrderOldest:
+	case ObservationOrderNewest:
+		direction = "DESC"
+	default:
+		return nil, fmt.Errorf("invalid order %q: must be %q or %q", orderBy, ObservationOrderOldest, ObservationOrderNewest)
+	}
+
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	var entityID int64
+	err = db.reader.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ?", graph, entityName).Scan(&entityID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &EntityNotFoundError{Name: entityName}
 		}
+		return nil, err
 	}
 
-	return graph, nil
+	page := &ObservationPage{
+		EntityName:   entityName,
+		Observations: []string{},
+		Offset:       offset,
+	}
+	if err := db.reader.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM observations o WHERE o.entity_id = ? AND "+liveObservation("o"), entityID,
+	).Scan(&page.TotalObservations); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.reader.QueryContext(ctx, fmt.Sprintf(`
+		SELECT o.content FROM observations o
+		WHERE o.entity_id = ? AND %[2]s
+		ORDER BY o.created_at %[1]s, o.id %[1]s
+		LIMIT ? OFFSET ?
+	`, direction, liveObservation("o")), entityID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, err
+		}
+		page.Observations = append(page.Observations, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if next := offset + len(page.Observations); next < page.TotalObservations {
+		page.NextOffset = &next
+	}
+	return page, nil
 }
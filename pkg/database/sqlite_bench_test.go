@@ -2,9 +2,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -95,6 +98,37 @@ func BenchmarkReadGraph(b *testing.B) {
 	}
 }
 
+// BenchmarkReadGraphAllocs measures the allocations of reading a large graph and the
+// heap the result keeps alive (live-B)
+func BenchmarkReadGraphAllocs(b *testing.B) {
+	const size = 10000
+	db := setupBenchDB(b, size)
+	defer db.Close()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var graph *KnowledgeGraph
+	for i := 0; i < b.N; i++ {
+		var err error
+		if graph, err = db.ReadGraph(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	var before, after runtime.MemStats
+	graph = nil
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	graph, _ = db.ReadGraph(ctx)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc), "live-B")
+	runtime.KeepAlive(graph)
+}
+
 // BenchmarkSearchNodes measures performance of searching nodes
 func BenchmarkSearchNodes(b *testing.B) {
 	sizes := []int{100, 1000, 5000}
@@ -110,7 +144,7 @@ func BenchmarkSearchNodes(b *testing.B) {
 			
 			for i := 0; i < b.N; i++ {
 				query := queries[i%len(queries)]
-				graph, err := db.SearchNodes(ctx, query)
+				graph, err := db.SearchNodes(ctx, query, 0, 0)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -135,10 +169,10 @@ func BenchmarkSearchNodesFTS(b *testing.B) {
 			
 			for i := 0; i < b.N; i++ {
 				query := queries[i%len(queries)]
-				graph, err := db.SearchNodesFTS(ctx, query)
+				graph, err := db.SearchNodesFTS(ctx, query, 0, 0)
 				if err != nil {
 					// Fallback to regular search if FTS5 not available
-					graph, err = db.SearchNodes(ctx, query)
+					graph, err = db.SearchNodes(ctx, query, 0, 0)
 					if err != nil {
 						b.Fatal(err)
 					}
@@ -151,7 +185,7 @@ func BenchmarkSearchNodesFTS(b *testing.B) {
 
 // BenchmarkCreateEntities measures performance of entity creation
 func BenchmarkCreateEntities(b *testing.B) {
-	batchSizes := []int{1, 10, 100}
+	batchSizes := []int{1, 10, 100, 1000}
 	
 	for _, batchSize := range batchSizes {
 		b.Run(fmt.Sprintf("batch_%d", batchSize), func(b *testing.B) {
@@ -193,6 +227,168 @@ func BenchmarkCreateEntities(b *testing.B) {
 	}
 }
 
+// createEntitiesRowByRow creates entities the way CreateEntities did before it batched
+// its inserts: a lookup and an insert per entity, and an insert per observation
+func createEntitiesRowByRow(ctx context.Context, db *DB, entities []EntityWithObservations) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	for _, entity := range entities {
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", entity.Name).Scan(&id)
+		if err == nil {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, "INSERT INTO entities (name, entity_type, session) VALUES (?, ?, NULLIF(?, ''))", entity.Name, entity.EntityType, "")
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		for _, obs := range entity.Observations {
+			if _, err := tx.ExecContext(ctx, insertObservationSQL, id, obs, "", ""); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// BenchmarkCreateEntities_VsRowByRow compares CreateEntities with the per-row inserts it replaced
+func BenchmarkCreateEntities_VsRowByRow(b *testing.B) {
+	create := map[string]func(context.Context, *DB, []EntityWithObservations) error{
+		"batched": func(ctx context.Context, db *DB, entities []EntityWithObservations) error {
+			_, err := db.CreateEntities(ctx, entities)
+			return err
+		},
+		"row_by_row": createEntitiesRowByRow,
+	}
+	
+	for _, batchSize := range []int{100, 1000} {
+		for _, name := range []string{"batched", "row_by_row"} {
+			b.Run(fmt.Sprintf("%s_%d", name, batchSize), func(b *testing.B) {
+				db, err := NewDBWithLogger(filepath.Join(b.TempDir(), "bench.db"), slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer db.Close()
+				
+				ctx := context.Background()
+				entities := make([]EntityWithObservations, batchSize)
+				b.ResetTimer()
+				
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					for j := range entities {
+						entities[j] = EntityWithObservations{
+							Name:         fmt.Sprintf("entity_%d_%d", i, j),
+							EntityType:   "benchmark_type",
+							Observations: []string{"observation_1", "observation_2", "observation_3"},
+						}
+					}
+					b.StartTimer()
+					
+					if err := create[name](ctx, db, entities); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// createRelationsRowByRow creates relations the way CreateRelations did before it
+// looked up their entities together: two lookups, an existence check and an insert
+// per relation
+func createRelationsRowByRow(ctx context.Context, db *DB, relations []RelationDTO) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	for _, rel := range relations {
+		var fromID, toID int64
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.From).Scan(&fromID); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = ?", rel.To).Scan(&toID); err != nil {
+			return err
+		}
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT 1 FROM relations WHERE from_entity_id = ? AND to_entity_id = ? AND relation_type = ?",
+			fromID, toID, rel.RelationType,
+		).Scan(&exists)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO relations (from_entity_id, to_entity_id, relation_type) VALUES (?, ?, ?)",
+			fromID, toID, rel.RelationType,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// BenchmarkCreateRelations_VsRowByRow compares bulk CreateRelations with the per-relation
+// lookups it replaced
+func BenchmarkCreateRelations_VsRowByRow(b *testing.B) {
+	create := map[string]func(context.Context, *DB, []RelationDTO) error{
+		"batched": func(ctx context.Context, db *DB, relations []RelationDTO) error {
+			_, err := db.CreateRelations(ctx, relations)
+			return err
+		},
+		"row_by_row": createRelationsRowByRow,
+	}
+	
+	for _, count := range []int{100, 1000} {
+		for _, name := range []string{"batched", "row_by_row"} {
+			b.Run(fmt.Sprintf("%s_%d", name, count), func(b *testing.B) {
+				db, err := NewDBWithLogger(filepath.Join(b.TempDir(), "bench.db"), slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer db.Close()
+				
+				ctx := context.Background()
+				entities := make([]EntityWithObservations, count+1)
+				for i := range entities {
+					entities[i] = EntityWithObservations{Name: fmt.Sprintf("entity_%d", i), EntityType: "benchmark_type"}
+				}
+				if _, err := db.CreateEntities(ctx, entities); err != nil {
+					b.Fatal(err)
+				}
+				relations := make([]RelationDTO, count)
+				b.ResetTimer()
+				
+				for i := 0; i < b.N; i++ {
+					for j := range relations {
+						relations[j] = RelationDTO{
+							From:         entities[j].Name,
+							To:           entities[j+1].Name,
+							RelationType: fmt.Sprintf("rel_%d", i),
+						}
+					}
+					
+					if err := create[name](ctx, db, relations); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
 // BenchmarkOpenNodes measures performance of opening specific nodes
 func BenchmarkOpenNodes(b *testing.B) {
 	db := setupBenchDB(b, 1000)
@@ -257,7 +453,7 @@ func BenchmarkSearchComparison(b *testing.B) {
 	
 	b.Run("LIKE_search", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			_, err := db.SearchNodes(ctx, query)
+			_, err := db.SearchNodes(ctx, query, 0, 0)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -266,14 +462,46 @@ func BenchmarkSearchComparison(b *testing.B) {
 	
 	b.Run("FTS5_search", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			_, err := db.SearchNodesFTS(ctx, query)
+			_, err := db.SearchNodesFTS(ctx, query, 0, 0)
 			if err != nil {
 				// Fallback to regular search
-				_, err = db.SearchNodes(ctx, query)
+				_, err = db.SearchNodes(ctx, query, 0, 0)
 				if err != nil {
 					b.Fatal(err)
 				}
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+// BenchmarkFindPath compares shortest-path traversals of a 100k relation graph
+// queried level by level with traversals of the adjacency cache
+func BenchmarkFindPath(b *testing.B) {
+	const entities, relations = 20000, 100000
+	for _, cache := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache_%t", cache), func(b *testing.B) {
+			db, err := NewDBWithLogger(filepath.Join(b.TempDir(), "memory.db"), slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			seedRandomGraph(b, db, entities, relations, 1)
+			if cache {
+				db.SetAdjacencyCache(1 << 30)
+			}
+
+			ctx := context.Background()
+			// The first traversal builds the cache
+			if _, _, err := db.FindPath(ctx, "node_0", "node_1", 6, false); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				from, to := fmt.Sprintf("node_%d", i%entities), fmt.Sprintf("node_%d", (i*7919+1)%entities)
+				if _, _, err := db.FindPath(ctx, from, to, 6, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
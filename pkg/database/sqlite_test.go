@@ -1,12 +1,18 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestDB(t *testing.T) *DB {
@@ -65,13 +71,15 @@ func TestCreateRelations(t *testing.T) {
 
 	created, err := db.CreateRelations(context.Background(), relations)
 	assert.NoError(t, err)
-	assert.Len(t, created, 1)
-	assert.Equal(t, "connects_to", created[0].RelationType)
+	assert.Len(t, created.Relations, 1)
+	assert.Equal(t, "connects_to", created.Relations[0].RelationType)
+	assert.Empty(t, created.Skipped)
 
 	// Test creating duplicate relations
 	created, err = db.CreateRelations(context.Background(), relations)
 	assert.NoError(t, err)
-	assert.Len(t, created, 0, "Should not create duplicate relations")
+	assert.Len(t, created.Relations, 0, "Should not create duplicate relations")
+	assert.Equal(t, []SkippedRelation{{Index: 0, Relation: relations[0], Reason: SkipDuplicate}}, created.Skipped)
 
 	// Test relation to non-existent entity
 	relations = []RelationDTO{
@@ -79,7 +87,8 @@ func TestCreateRelations(t *testing.T) {
 	}
 	created, err = db.CreateRelations(context.Background(), relations)
 	assert.NoError(t, err)
-	assert.Len(t, created, 0, "Should not create relation to non-existent entity")
+	assert.Len(t, created.Relations, 0, "Should not create relation to non-existent entity")
+	assert.Equal(t, []SkippedRelation{{Index: 0, Relation: relations[0], Reason: SkipMissingTo}}, created.Skipped)
 
 	graph, err := db.ReadGraph(context.Background())
 	assert.NoError(t, err)
@@ -118,6 +127,32 @@ func TestAddObservations(t *testing.T) {
 	assert.Len(t, graph.Entities[0].Observations, 3)
 }
 
+func TestAddObservations_SkippedObservations(t *testing.T) {
+	for _, threshold := range []float64{0, 0.9} {
+		t.Run(fmt.Sprintf("threshold %v", threshold), func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+			ctx := context.Background()
+			_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "E1", EntityType: "T", Observations: []string{"known fact"}}})
+			assert.NoError(t, err)
+
+			added, err := db.AddObservationsIfAbsentSimilar(ctx, []ObservationAdditionInput{{
+				EntityName: "E1",
+				Contents:   []string{"new fact", "known fact", "new fact", "other fact", "known fact"},
+			}}, threshold)
+			assert.NoError(t, err)
+			if assert.Len(t, added, 1) {
+				assert.Equal(t, []string{"new fact", "other fact"}, added[0].AddedObservations, "repeats within the call are added once")
+				assert.Equal(t, []string{"known fact"}, added[0].SkippedObservations, "only contents the entity had are skipped, once each")
+			}
+
+			graph, err := db.OpenNodes(ctx, []string{"E1"})
+			assert.NoError(t, err)
+			assert.Len(t, graph.Entities[0].Observations, 3)
+		})
+	}
+}
+
 func TestDeleteEntities(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -129,7 +164,7 @@ func TestDeleteEntities(t *testing.T) {
 	_, err := db.CreateEntities(context.Background(), entities)
 	assert.NoError(t, err)
 
-	err = db.DeleteEntities(context.Background(), []string{"E1"})
+	_, err = db.DeleteEntities(context.Background(), []string{"E1"})
 	assert.NoError(t, err)
 
 	graph, err := db.ReadGraph(context.Background())
@@ -150,7 +185,7 @@ func TestDeleteObservations(t *testing.T) {
 
     deletions := []ObservationDeletionInput{{EntityName: "E1", Observations: []string{"obs1", "obs3"}}}
 	
-	err = db.DeleteObservations(context.Background(), deletions)
+	_, err = db.DeleteObservations(context.Background(), deletions)
 	assert.NoError(t, err)
 
 	graph, err := db.ReadGraph(context.Background())
@@ -176,7 +211,7 @@ func TestDeleteRelations(t *testing.T) {
 	_, err = db.CreateRelations(context.Background(), relations)
 	assert.NoError(t, err)
 
-	err = db.DeleteRelations(context.Background(), relations)
+	_, err = db.DeleteRelations(context.Background(), relations)
 	assert.NoError(t, err)
 
 	graph, err := db.ReadGraph(context.Background())
@@ -197,24 +232,24 @@ func TestSearchNodes(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Search by name
-	graph, err := db.SearchNodes(context.Background(), "Apple")
+	graph, err := db.SearchNodes(context.Background(), "Apple", 0, 0)
 	assert.NoError(t, err)
 	assert.Len(t, graph.Entities, 1)
 	assert.Equal(t, "Apple", graph.Entities[0].Name)
 
 	// Search by type
-	graph, err = db.SearchNodes(context.Background(), "Fruit")
+	graph, err = db.SearchNodes(context.Background(), "Fruit", 0, 0)
 	assert.NoError(t, err)
 	assert.Len(t, graph.Entities, 2)
 
 	// Search by observation content
-	graph, err = db.SearchNodes(context.Background(), "tasty")
+	graph, err = db.SearchNodes(context.Background(), "tasty", 0, 0)
 	assert.NoError(t, err)
 	assert.Len(t, graph.Entities, 1)
 	assert.Equal(t, "Apple", graph.Entities[0].Name)
 
 	// Search with no results
-	graph, err = db.SearchNodes(context.Background(), "Zebra")
+	graph, err = db.SearchNodes(context.Background(), "Zebra", 0, 0)
 	assert.NoError(t, err)
 	assert.Len(t, graph.Entities, 0)
 }
@@ -279,20 +314,28 @@ func TestDB_CreateRelations_Table(t *testing.T) {
     assert.NoError(t, err)
 
     cases := []struct{
-        name    string
-        input   []RelationDTO
-        wantLen int
+        name     string
+        input    []RelationDTO
+        wantLen  int
+        wantSkip string
     }{
         {name: "normal", input: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantLen: 1},
-        {name: "duplicate", input: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantLen: 0},
-        {name: "missing endpoint", input: []RelationDTO{{From: "A", To: "C", RelationType: "rel"}}, wantLen: 0},
+        {name: "duplicate", input: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantLen: 0, wantSkip: SkipDuplicate},
+        {name: "missing endpoint", input: []RelationDTO{{From: "A", To: "C", RelationType: "rel"}}, wantLen: 0, wantSkip: SkipMissingTo},
+        {name: "missing source", input: []RelationDTO{{From: "C", To: "B", RelationType: "rel"}}, wantLen: 0, wantSkip: SkipMissingFrom},
+        {name: "missing both", input: []RelationDTO{{From: "C", To: "D", RelationType: "rel"}}, wantLen: 0, wantSkip: SkipMissingFrom},
         {name: "self relation", input: []RelationDTO{{From: "A", To: "A", RelationType: "self"}}, wantLen: 1},
     }
     for _, tc := range cases {
         t.Run(tc.name, func(t *testing.T) {
             created, err := db.CreateRelations(context.Background(), tc.input)
             assert.NoError(t, err)
-            assert.Len(t, created, tc.wantLen)
+            assert.Len(t, created.Relations, tc.wantLen)
+            if tc.wantSkip == "" {
+                assert.Empty(t, created.Skipped)
+            } else {
+                assert.Equal(t, []SkippedRelation{{Index: 0, Relation: tc.input[0], Reason: tc.wantSkip}}, created.Skipped)
+            }
         })
     }
 }
@@ -338,10 +381,12 @@ func TestDB_DeleteEntities_Table(t *testing.T) {
         delete  []string
         wantEnt []string
         wantRel int
+        want    EntityDeletionResult
     }{
-        {name: "delete A cascades", delete: []string{"A"}, wantEnt: []string{"B"}, wantRel: 0},
-        {name: "delete missing noop", delete: []string{"C"}, wantEnt: []string{"A","B"}, wantRel: 1},
-        {name: "delete none", delete: nil, wantEnt: []string{"A","B"}, wantRel: 1},
+        {name: "delete A cascades", delete: []string{"A"}, wantEnt: []string{"B"}, wantRel: 0, want: EntityDeletionResult{DeletedEntities: 1, NotFound: []string{}}},
+        {name: "delete missing noop", delete: []string{"C"}, wantEnt: []string{"A","B"}, wantRel: 1, want: EntityDeletionResult{NotFound: []string{"C"}}},
+        {name: "delete none", delete: nil, wantEnt: []string{"A","B"}, wantRel: 1, want: EntityDeletionResult{NotFound: []string{}}},
+        {name: "delete twice counts once", delete: []string{"A", "C", "A"}, wantEnt: []string{"B"}, wantRel: 0, want: EntityDeletionResult{DeletedEntities: 1, NotFound: []string{"C"}}},
     }
     for _, tc := range cases {
         t.Run(tc.name, func(t *testing.T) {
@@ -352,8 +397,9 @@ func TestDB_DeleteEntities_Table(t *testing.T) {
             _, err = db.CreateRelations(context.Background(), []RelationDTO{{From: "A", To: "B", RelationType: "rel"}})
             assert.NoError(t, err)
 
-            err = db.DeleteEntities(context.Background(), tc.delete)
+            result, err := db.DeleteEntities(context.Background(), tc.delete)
             assert.NoError(t, err)
+            assert.Equal(t, &tc.want, result)
             g, err := db.ReadGraph(context.Background())
             assert.NoError(t, err)
             names := make([]string, 0, len(g.Entities))
@@ -370,10 +416,16 @@ func TestDB_DeleteObservations_Table(t *testing.T) {
         name    string
         del     []del
         wantObs []string
+        want    ObservationDeletionResult
     }{
-        {name: "delete existing", del: []del{{entity: "A", obs: []string{"o1"}}}, wantObs: []string{"o2"}},
-        {name: "delete unknown observation", del: []del{{entity: "A", obs: []string{"nope"}}}, wantObs: []string{"o1","o2"}},
-        {name: "unknown entity noop", del: []del{{entity: "MISSING", obs: []string{"x"}}}, wantObs: []string{"o1","o2"}},
+        {name: "delete existing", del: []del{{entity: "A", obs: []string{"o1"}}}, wantObs: []string{"o2"},
+            want: ObservationDeletionResult{DeletedObservations: 1, NotFound: []string{}, MissingObservations: []ObservationDeletionInput{}}},
+        {name: "delete unknown observation", del: []del{{entity: "A", obs: []string{"nope"}}}, wantObs: []string{"o1","o2"},
+            want: ObservationDeletionResult{NotFound: []string{}, MissingObservations: []ObservationDeletionInput{{EntityName: "A", Observations: []string{"nope"}}}}},
+        {name: "unknown entity noop", del: []del{{entity: "MISSING", obs: []string{"x"}}}, wantObs: []string{"o1","o2"},
+            want: ObservationDeletionResult{NotFound: []string{"MISSING"}, MissingObservations: []ObservationDeletionInput{}}},
+        {name: "delete twice counts once", del: []del{{entity: "A", obs: []string{"o1", "o1"}}, {entity: "A", obs: []string{"o1", "o2"}}}, wantObs: []string{},
+            want: ObservationDeletionResult{DeletedObservations: 2, NotFound: []string{}, MissingObservations: []ObservationDeletionInput{}}},
     }
     for _, tc := range cases {
         t.Run(tc.name, func(t *testing.T) {
@@ -386,8 +438,9 @@ func TestDB_DeleteObservations_Table(t *testing.T) {
             for i, v := range tc.del {
                 arg[i] = ObservationDeletionInput{EntityName: v.entity, Observations: v.obs}
             }
-            err = db.DeleteObservations(context.Background(), arg)
+            result, err := db.DeleteObservations(context.Background(), arg)
             assert.NoError(t, err)
+            assert.Equal(t, &tc.want, result)
             g, err := db.OpenNodes(context.Background(), []string{"A"})
             assert.NoError(t, err)
             assert.ElementsMatch(t, tc.wantObs, g.Entities[0].Observations)
@@ -400,10 +453,12 @@ func TestDB_DeleteRelations_Table(t *testing.T) {
         name  string
         del   []RelationDTO
         wantR int
+        wantDeleted int
     }{
         {name: "delete missing type", del: []RelationDTO{{From: "A", To: "B", RelationType: "other"}}, wantR: 1},
-        {name: "delete existing", del: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantR: 0},
+        {name: "delete existing", del: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantR: 0, wantDeleted: 1},
         {name: "delete missing entity", del: []RelationDTO{{From: "A", To: "C", RelationType: "rel"}}, wantR: 1},
+        {name: "delete twice counts once", del: []RelationDTO{{From: "A", To: "B", RelationType: "rel"}, {From: "A", To: "B", RelationType: "rel"}}, wantR: 0, wantDeleted: 1},
     }
     for _, tc := range cases {
         t.Run(tc.name, func(t *testing.T) {
@@ -414,8 +469,14 @@ func TestDB_DeleteRelations_Table(t *testing.T) {
             _, err = db.CreateRelations(context.Background(), []RelationDTO{{From: "A", To: "B", RelationType: "rel"}})
             assert.NoError(t, err)
 
-            err = db.DeleteRelations(context.Background(), tc.del)
+            result, err := db.DeleteRelations(context.Background(), tc.del)
             assert.NoError(t, err)
+            assert.Equal(t, tc.wantDeleted, result.DeletedRelations)
+            if tc.wantDeleted == 0 {
+                assert.Equal(t, tc.del, result.NotFound)
+            } else {
+                assert.Empty(t, result.NotFound)
+            }
             g, err := db.ReadGraph(context.Background())
             assert.NoError(t, err)
             assert.Len(t, g.Relations, tc.wantR)
@@ -442,7 +503,7 @@ func TestDB_SearchNodes_Table(t *testing.T) {
     }
     for _, tc := range cases {
         t.Run(tc.name, func(t *testing.T) {
-            g, err := db.SearchNodes(context.Background(), tc.q)
+            g, err := db.SearchNodes(context.Background(), tc.q, 0, 0)
             assert.NoError(t, err)
             assert.Len(t, g.Entities, tc.want)
         })
@@ -491,6 +552,126 @@ func TestCreateEntities_EmptyInput(t *testing.T) {
     assert.Len(t, created, 0)
 }
 
+func TestCreateRelations_Bulk(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	entities := make([]EntityWithObservations, maxListValues+50)
+	for i := range entities {
+		entities[i] = EntityWithObservations{Name: fmt.Sprintf("E%d", i), EntityType: "T"}
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "E0", To: "E1", RelationType: "next"}})
+	assert.NoError(t, err)
+
+	var relations []RelationDTO
+	for i := 0; i+1 < len(entities); i++ {
+		relations = append(relations, RelationDTO{From: entities[i].Name, To: entities[i+1].Name, RelationType: "next"})
+	}
+	relations = append(relations,
+		RelationDTO{From: "E5", To: "E6", RelationType: "next"},
+		RelationDTO{From: "E5", To: "Missing", RelationType: "next"},
+		RelationDTO{From: "Missing", To: "E5", RelationType: "next"},
+	)
+
+	created, err := db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+	// The existing E0 -> E1 and the repeated E5 -> E6 are skipped, as are the missing endpoints
+	assert.Len(t, created.Relations, len(entities)-2)
+	assert.Equal(t, RelationDTO{From: "E1", To: "E2", RelationType: "next"}, created.Relations[0])
+	n := len(relations)
+	assert.Equal(t, []SkippedRelation{
+		{Index: 0, Relation: relations[0], Reason: SkipDuplicate},
+		{Index: n - 3, Relation: relations[n-3], Reason: SkipDuplicate},
+		{Index: n - 2, Relation: relations[n-2], Reason: SkipMissingTo},
+		{Index: n - 1, Relation: relations[n-1], Reason: SkipMissingFrom},
+	}, created.Skipped)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Relations, len(entities)-1)
+}
+
+func TestCreateRelationsStrict(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "A", EntityType: "T"}, {Name: "B", EntityType: "T"}})
+	assert.NoError(t, err)
+
+	relations := []RelationDTO{
+		{From: "A", To: "B", RelationType: "rel"},
+		{From: "A", To: "Missing", RelationType: "rel"},
+		{From: "Missing", To: "B", RelationType: "rel"},
+	}
+	_, err = db.CreateRelationsStrict(ctx, relations)
+	var missingErr *MissingEndpointsError
+	if assert.ErrorAs(t, err, &missingErr) {
+		assert.Equal(t, []SkippedRelation{
+			{Index: 1, Relation: relations[1], Reason: SkipMissingTo},
+			{Index: 2, Relation: relations[2], Reason: SkipMissingFrom},
+		}, missingErr.Skipped)
+	}
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Relations, "nothing is created")
+
+	// Duplicates don't fail a strict call
+	created, err := db.CreateRelationsStrict(ctx, []RelationDTO{relations[0], relations[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{relations[0]}, created.Relations)
+	assert.Equal(t, []SkippedRelation{{Index: 1, Relation: relations[0], Reason: SkipDuplicate}}, created.Skipped)
+}
+
+func TestCreateRelations_Properties(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "A", EntityType: "T"}, {Name: "B", EntityType: "T"}})
+	assert.NoError(t, err)
+
+	rel := RelationDTO{From: "A", To: "B", RelationType: "knows", Confidence: 0.7, Note: "source: meeting 2024-05-01"}
+	created, err := db.CreateRelations(ctx, []RelationDTO{rel, {From: "B", To: "A", RelationType: "knows"}})
+	assert.NoError(t, err)
+	assert.Len(t, created.Relations, 2)
+
+	// Without updateExisting a duplicate is skipped, its properties unchanged
+	changed := RelationDTO{From: "A", To: "B", RelationType: "knows", Confidence: 0.9}
+	created, err = db.CreateRelations(ctx, []RelationDTO{changed})
+	assert.NoError(t, err)
+	assert.Equal(t, []SkippedRelation{{Index: 0, Relation: changed, Reason: SkipDuplicate}}, created.Skipped)
+	assert.Empty(t, created.Updated)
+
+	// With it the properties given are set and the others kept
+	created, err = db.CreateRelationsWithOptions(ctx, []RelationDTO{changed, {From: "B", To: "A", RelationType: "knows"}}, RelationOptions{UpdateExisting: true})
+	assert.NoError(t, err)
+	assert.Empty(t, created.Relations)
+	assert.Equal(t, []RelationDTO{{From: "A", To: "B", RelationType: "knows", Confidence: 0.9, Note: rel.Note}}, created.Updated)
+	assert.Equal(t, []SkippedRelation{{Index: 1, Relation: RelationDTO{From: "B", To: "A", RelationType: "knows"}, Reason: SkipDuplicate}}, created.Skipped, "a duplicate without properties has nothing to update")
+
+	// The properties survive reads
+	want := []RelationDTO{
+		{From: "A", To: "B", RelationType: "knows", Confidence: 0.9, Note: rel.Note},
+		{From: "B", To: "A", RelationType: "knows"},
+	}
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, graph.Relations)
+	nodes, err := db.OpenNodes(ctx, []string{"A", "B"})
+	assert.NoError(t, err)
+	assert.Equal(t, want, nodes.Relations)
+	entity, err := db.GetEntity(ctx, "A")
+	assert.NoError(t, err)
+	if assert.Len(t, entity.Outgoing, 1) {
+		assert.Equal(t, 0.9, entity.Outgoing[0].Confidence)
+		assert.Equal(t, rel.Note, entity.Outgoing[0].Note)
+	}
+
+	// Relations are deleted by their endpoints and type alone
+	deleted, err := db.DeleteRelations(ctx, []RelationDTO{{From: "A", To: "B", RelationType: "knows"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted.DeletedRelations)
+}
+
 func TestCreateRelations_SelfRelationAllowed(t *testing.T) {
     db := setupTestDB(t)
     defer db.Close()
@@ -500,7 +681,7 @@ func TestCreateRelations_SelfRelationAllowed(t *testing.T) {
 
     created, err := db.CreateRelations(context.Background(), []RelationDTO{{From: "NodeA", To: "NodeA", RelationType: "self"}})
     assert.NoError(t, err)
-    assert.Len(t, created, 1)
+    assert.Len(t, created.Relations, 1)
 
     g, err := db.ReadGraph(context.Background())
     assert.NoError(t, err)
@@ -523,7 +704,7 @@ func TestDeleteEntities_CascadesToObservationsAndRelations(t *testing.T) {
     assert.NoError(t, err)
 
     // Delete A and ensure its observations and the relation are gone
-    err = db.DeleteEntities(context.Background(), []string{"A"})
+    _, err = db.DeleteEntities(context.Background(), []string{"A"})
     assert.NoError(t, err)
 
     g, err := db.ReadGraph(context.Background())
@@ -540,7 +721,7 @@ func TestDeleteObservations_NonexistentIsNoop(t *testing.T) {
     _, err := db.CreateEntities(context.Background(), []EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"x"}}})
     assert.NoError(t, err)
 
-    err = db.DeleteObservations(context.Background(), []ObservationDeletionInput{{EntityName: "A", Observations: []string{"does-not-exist"}}})
+    _, err = db.DeleteObservations(context.Background(), []ObservationDeletionInput{{EntityName: "A", Observations: []string{"does-not-exist"}}})
     assert.NoError(t, err)
 
     g, err := db.ReadGraph(context.Background())
@@ -557,7 +738,7 @@ func TestDeleteRelations_MissingIsNoop(t *testing.T) {
     assert.NoError(t, err)
 
     // delete a relation that doesn't exist
-    err = db.DeleteRelations(context.Background(), []RelationDTO{{From: "A", To: "B", RelationType: "missing"}})
+    _, err = db.DeleteRelations(context.Background(), []RelationDTO{{From: "A", To: "B", RelationType: "missing"}})
     assert.NoError(t, err)
 }
 
@@ -574,7 +755,7 @@ func TestSearchNodes_EmptyQueryReturnsAll(t *testing.T) {
     gAll, err := db.ReadGraph(context.Background())
     assert.NoError(t, err)
 
-    gSearch, err := db.SearchNodes(context.Background(), "")
+    gSearch, err := db.SearchNodes(context.Background(), "", 0, 0)
     assert.NoError(t, err)
     assert.Len(t, gSearch.Entities, len(gAll.Entities))
     assert.Len(t, gSearch.Relations, len(gAll.Relations))
@@ -587,12 +768,47 @@ func TestSearchNodes_CaseInsensitivity(t *testing.T) {
     _, err := db.CreateEntities(context.Background(), []EntityWithObservations{{Name: "Apple", EntityType: "Fruit", Observations: []string{"Tasty"}}})
     assert.NoError(t, err)
 
-    g, err := db.SearchNodes(context.Background(), "apple")
+    g, err := db.SearchNodes(context.Background(), "apple", 0, 0)
     assert.NoError(t, err)
     assert.Len(t, g.Entities, 1)
     assert.Equal(t, "Apple", g.Entities[0].Name)
 }
 
+func TestSearchNodes_MultiTerm(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.CreateEntities(context.Background(), []EntityWithObservations{
+		{Name: "Apple", EntityType: "Fruit", Observations: []string{"Red and tasty"}},
+		{Name: "Banana", EntityType: "Fruit", Observations: []string{"Yellow and sweet"}},
+		{Name: "Carrot", EntityType: "Vegetable", Observations: []string{"Orange and crunchy"}},
+	})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		q    string
+		want []string
+	}{
+		// Terms may match different fields of the same entity
+		{q: "apple fruit", want: []string{"Apple"}},
+		{q: "fruit sweet", want: []string{"Banana"}},
+		{q: "  tasty\tred ", want: []string{"Apple"}},
+		// Every term must match
+		{q: "fruit crunchy", want: []string{}},
+		{q: "fruit", want: []string{"Apple", "Banana"}},
+		{q: "and", want: []string{"Apple", "Banana", "Carrot"}},
+	}
+	for _, tc := range cases {
+		g, err := db.SearchNodes(context.Background(), tc.q, 0, 0)
+		assert.NoError(t, err)
+		names := []string{}
+		for _, e := range g.Entities {
+			names = append(names, e.Name)
+		}
+		assert.Equal(t, tc.want, names, "query %q", tc.q)
+	}
+}
+
 func TestOpenNodes_EmptyInput(t *testing.T) {
     db := setupTestDB(t)
     defer db.Close()
@@ -636,3 +852,529 @@ func TestAddObservations_DuplicateWithinSingleCall(t *testing.T) {
     assert.NoError(t, err)
     assert.Equal(t, []string{"dup"}, g.Entities[0].Observations)
 }
+
+func TestLargeObservationSet_LazyLoadingPagingAndBatchedDelete(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := NewDBWithLogger("file::memory:?cache=shared", logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	const total = 10000
+	contents := make([]string, total)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("log line %05d", i)
+	}
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Chat", EntityType: "log"}, {Name: "Small", EntityType: "log", Observations: []string{"one"}}})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Chat", Contents: contents}})
+	assert.NoError(t, err)
+
+	// Read paths return the first DefaultObservationLimit observations plus the total
+	g, err := db.OpenNodes(ctx, []string{"Chat", "Small"})
+	assert.NoError(t, err)
+	assert.Len(t, g.Entities, 2)
+	assert.Equal(t, contents[:DefaultObservationLimit], g.Entities[0].Observations)
+	assert.Equal(t, total, g.Entities[0].TotalObservations)
+	assert.Equal(t, []string{"one"}, g.Entities[1].Observations)
+	assert.Equal(t, 1, g.Entities[1].TotalObservations)
+
+	// Walking pages visits every observation once, in order
+	var walked []string
+	offset, pages := 0, 0
+	for {
+		page, err := db.GetObservations(ctx, "Chat", 999, offset, ObservationOrderOldest)
+		assert.NoError(t, err)
+		assert.Equal(t, total, page.TotalObservations)
+		assert.LessOrEqual(t, len(page.Observations), 999)
+		walked = append(walked, page.Observations...)
+		pages++
+		if page.NextOffset == nil {
+			break
+		}
+		offset = *page.NextOffset
+	}
+	assert.Equal(t, contents, walked)
+	assert.Equal(t, 11, pages)
+
+	newest, err := db.GetObservations(ctx, "Chat", 2, 0, ObservationOrderNewest)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{contents[total-1], contents[total-2]}, newest.Observations)
+
+	_, err = db.GetObservations(ctx, "Chat", 10, 0, "random")
+	assert.Error(t, err)
+	_, err = db.GetObservations(ctx, "Missing", 10, 0, "")
+	assert.Error(t, err)
+
+	// Deleting the entity removes its observations in bounded batches
+	logs.Reset()
+	_, err = db.DeleteEntities(ctx, []string{"Chat"})
+	assert.NoError(t, err)
+
+	batches, deleted := 0, 0
+	dec := json.NewDecoder(&logs)
+	for dec.More() {
+		var rec struct {
+			Msg     string `json:"msg"`
+			Deleted int    `json:"deleted"`
+		}
+		assert.NoError(t, dec.Decode(&rec))
+		if rec.Msg == "deleted observation batch" {
+			batches++
+			deleted += rec.Deleted
+			assert.LessOrEqual(t, rec.Deleted, deleteBatchSize)
+		}
+	}
+	assert.Equal(t, total/deleteBatchSize, batches)
+	assert.Equal(t, total, deleted)
+
+	var remaining int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM observations").Scan(&remaining))
+	assert.Equal(t, 1, remaining)
+	g, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, g.Entities, 1)
+}
+
+func TestMeta_GetSet(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, ok, err := db.GetMeta(ctx, "k")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, db.SetMeta(ctx, "k", "v1"))
+	assert.NoError(t, db.SetMeta(ctx, "k", "v2"))
+	v, ok, err := db.GetMeta(ctx, "k")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", v)
+
+	assert.NoError(t, db.Optimize(ctx))
+}
+
+func TestCreateEntitiesWithMode(t *testing.T) {
+	seed := []EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"a1"}}}
+	batch := []EntityWithObservations{
+		{Name: "A", EntityType: "T", Observations: []string{"a1", "a2"}},
+		{Name: "B", EntityType: "T", Observations: []string{"b1"}},
+	}
+
+	cases := []struct {
+		mode         string
+		wantOutcomes []string
+		wantAppended []string
+		wantAObs     []string
+		wantErr      bool
+	}{
+		{mode: DuplicateSkip, wantOutcomes: []string{OutcomeSkipped, OutcomeCreated}, wantAppended: []string{}, wantAObs: []string{"a1"}},
+		{mode: "", wantOutcomes: []string{OutcomeSkipped, OutcomeCreated}, wantAppended: []string{}, wantAObs: []string{"a1"}},
+		{mode: DuplicateAppendObservations, wantOutcomes: []string{OutcomeObservationsAppended, OutcomeCreated}, wantAppended: []string{"a2"}, wantAObs: []string{"a1", "a2"}},
+		{mode: DuplicateError, wantErr: true, wantAObs: []string{"a1"}},
+	}
+
+	for _, tc := range cases {
+		t.Run("mode "+tc.mode, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+			ctx := context.Background()
+
+			_, err := db.CreateEntities(ctx, seed)
+			assert.NoError(t, err)
+
+			results, err := db.CreateEntitiesWithMode(ctx, batch, tc.mode)
+			if tc.wantErr {
+				assert.Error(t, err)
+				// The whole batch rolls back, including the new entity
+				g, err := db.OpenNodes(ctx, []string{"B"})
+				assert.NoError(t, err)
+				assert.Empty(t, g.Entities)
+			} else {
+				assert.NoError(t, err)
+				if assert.Len(t, results, 2) {
+					assert.Equal(t, tc.wantOutcomes, []string{results[0].Outcome, results[1].Outcome})
+					assert.Equal(t, tc.wantAppended, results[0].Observations)
+					assert.Equal(t, []string{"b1"}, results[1].Observations)
+				}
+			}
+
+			g, err := db.OpenNodes(ctx, []string{"A"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantAObs, g.Entities[0].Observations)
+		})
+	}
+}
+
+func TestCreateEntitiesWithMode_DuplicateWithinBatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	results, err := db.CreateEntitiesWithMode(context.Background(), []EntityWithObservations{
+		{Name: "A", EntityType: "T", Observations: []string{"x"}},
+		{Name: "A", EntityType: "T", Observations: []string{"x", "y"}},
+	}, DuplicateAppendObservations)
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeCreated, results[0].Outcome)
+	assert.Equal(t, OutcomeObservationsAppended, results[1].Outcome)
+	assert.Equal(t, []string{"y"}, results[1].Observations)
+
+	_, err = db.CreateEntitiesWithMode(context.Background(), nil, "merge")
+	assert.Error(t, err)
+}
+
+func TestCreateEntities_SpansInsertChunks(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "entity_7", EntityType: "old", Observations: []string{"kept"}},
+		{Name: "entity_900", EntityType: "old"},
+	})
+	assert.NoError(t, err)
+
+	batch := make([]EntityWithObservations, 1100)
+	for i := range batch {
+		batch[i] = EntityWithObservations{
+			Name:         fmt.Sprintf("entity_%d", i),
+			EntityType:   "new",
+			Observations: []string{fmt.Sprintf("first %d", i), fmt.Sprintf("second %d", i)},
+		}
+	}
+	batch = append(batch, EntityWithObservations{Name: "entity_3", EntityType: "again"})
+
+	created, err := db.CreateEntities(ctx, batch)
+	assert.NoError(t, err)
+	assert.Len(t, created, len(batch)-3)
+	for _, e := range created {
+		assert.NotContains(t, []string{"entity_7", "entity_900"}, e.Name)
+		assert.Equal(t, "new", e.EntityType)
+	}
+
+	graph, err := db.OpenNodes(ctx, []string{"entity_7", "entity_900", "entity_3", "entity_1099"})
+	assert.NoError(t, err)
+	byName := map[string]EntityWithObservations{}
+	for _, e := range graph.Entities {
+		byName[e.Name] = e
+	}
+	assert.Equal(t, []string{"kept"}, byName["entity_7"].Observations)
+	assert.Equal(t, "old", byName["entity_900"].EntityType)
+	assert.Equal(t, "new", byName["entity_3"].EntityType)
+	assert.Equal(t, []string{"first 1099", "second 1099"}, byName["entity_1099"].Observations)
+}
+
+func TestReadGraph_InternsTypes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "A", EntityType: "person", Observations: []string{"a"}},
+		{Name: "B", EntityType: "person"},
+		{Name: "C", EntityType: "org"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "A", To: "C", RelationType: "works_at"},
+		{From: "B", To: "C", RelationType: "works_at"},
+	})
+	assert.NoError(t, err)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	out, err := json.Marshal(graph)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"entities":[
+		{"name":"A","entityType":"person","observations":["a"],"totalObservations":1},
+		{"name":"B","entityType":"person","observations":[]},
+		{"name":"C","entityType":"org","observations":[]}
+	],"relations":[
+		{"from":"A","to":"C","relationType":"works_at"},
+		{"from":"B","to":"C","relationType":"works_at"}
+	]}`, string(out))
+
+	// Equal types share one string
+	assert.Same(t, unsafe.StringData(graph.Entities[0].EntityType), unsafe.StringData(graph.Entities[1].EntityType))
+	assert.Same(t, unsafe.StringData(graph.Relations[0].RelationType), unsafe.StringData(graph.Relations[1].RelationType))
+}
+
+func TestReadGraphPage(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "A", EntityType: "person", Observations: []string{"a"}},
+		{Name: "B", EntityType: "person"},
+		{Name: "C", EntityType: "org"},
+		{Name: "D", EntityType: "org"},
+		{Name: "E", EntityType: "org"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "A", To: "B", RelationType: "knows"},
+		{From: "B", To: "C", RelationType: "works_at"},
+		{From: "C", To: "D", RelationType: "owns"},
+	})
+	assert.NoError(t, err)
+
+	var names []string
+	var relations []RelationDTO
+	var cursors []string
+	cursor := ""
+	for {
+		page, err := db.ReadGraphPage(ctx, 2, cursor)
+		assert.NoError(t, err)
+		for _, e := range page.Entities {
+			names = append(names, e.Name)
+		}
+		relations = append(relations, page.Relations...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+		cursors = append(cursors, cursor)
+	}
+	assert.Equal(t, []string{"A", "B", "C", "D", "E"}, names)
+	assert.Equal(t, []string{"B", "D"}, cursors)
+	// B -> C crosses pages, so it is on neither
+	assert.Equal(t, []RelationDTO{
+		{From: "A", To: "B", RelationType: "knows"},
+		{From: "C", To: "D", RelationType: "owns"},
+	}, relations)
+
+	// A page that ends exactly on the last entity has no cursor
+	page, err := db.ReadGraphPage(ctx, 5, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Entities, 5)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = db.ReadGraphPage(ctx, 2, "E")
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entities)
+	assert.NotNil(t, page.Relations)
+
+	_, err = db.ReadGraphPage(ctx, 0, "")
+	assert.Error(t, err)
+}
+
+func TestObservations_RoundTripIntact(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	observations := []string{
+		"| a ||| b |",
+		"|||",
+		`quotes " and \ backslashes`,
+		"multi\nline",
+		"unicode ✓ 日本",
+		"",
+	}
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Table", EntityType: "markdown", Observations: observations},
+	})
+	assert.NoError(t, err)
+
+	var stored []string
+	rows, err := db.conn.QueryContext(ctx, "SELECT content FROM observations ORDER BY id")
+	assert.NoError(t, err)
+	for rows.Next() {
+		var content string
+		assert.NoError(t, rows.Scan(&content))
+		stored = append(stored, content)
+	}
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, observations, stored)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, stored, graph.Entities[0].Observations, "read_graph")
+
+	graph, err = db.OpenNodes(ctx, []string{"Table"})
+	assert.NoError(t, err)
+	assert.Equal(t, stored, graph.Entities[0].Observations, "open_nodes")
+
+	found, err := db.SearchNodes(ctx, "|||", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, found.Entities, 1) {
+		assert.Equal(t, stored, found.Entities[0].Observations, "search_nodes")
+	}
+
+	if db.IsFTSEnabled() {
+		found, err = db.SearchNodesFTS(ctx, "markdown", 0, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, found.Entities, 1) {
+			assert.Equal(t, stored, found.Entities[0].Observations, "search_nodes with FTS5")
+		}
+	}
+
+	detail, err := db.GetEntity(ctx, "Table")
+	assert.NoError(t, err)
+	assert.Equal(t, stored, detail.Observations, "get_entity")
+}
+
+func TestObservations_StableOrder(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	// Not in sorted order, and added in several calls within the same second
+	want := []string{"zulu", "alpha", "mike", "bravo", "yankee", "charlie"}
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Ordered", EntityType: "fixture", Observations: want[:2]},
+	})
+	assert.NoError(t, err)
+	for _, content := range want[2:] {
+		_, err := db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Ordered", Contents: []string{content}}})
+		assert.NoError(t, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		graph, err := db.ReadGraph(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, want, graph.Entities[0].Observations, "read_graph")
+
+		graph, err = db.OpenNodes(ctx, []string{"Ordered"})
+		assert.NoError(t, err)
+		assert.Equal(t, want, graph.Entities[0].Observations, "open_nodes")
+
+		found, err := db.SearchNodes(ctx, "Ordered", 0, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, found.Entities, 1) {
+			assert.Equal(t, want, found.Entities[0].Observations, "search_nodes")
+		}
+
+		if db.IsFTSEnabled() {
+			found, err = db.SearchNodesFTS(ctx, "fixture", 0, 0)
+			assert.NoError(t, err)
+			if assert.Len(t, found.Entities, 1) {
+				assert.Equal(t, want, found.Entities[0].Observations, "search_nodes with FTS5")
+			}
+		}
+	}
+}
+
+func TestReadPool_ReadsDuringWrite(t *testing.T) {
+	db := newImportTestDB(t)
+	require.NotSame(t, db.conn, db.reader)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{{Name: "Seed", EntityType: "fixture"}})
+	require.NoError(t, err)
+
+	// readPage reads the first page of the graph, bounded by its own timeout so a
+	// read blocked by the writer fails rather than hangs
+	readPage := func() *GraphPage {
+		t.Helper()
+		readCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		page, err := db.ReadGraphPage(readCtx, 10, "")
+		require.NoError(t, err)
+		return page
+	}
+
+	// A write transaction holds the writer's only connection
+	tx, err := db.conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "INSERT INTO entities (name, entity_type) VALUES ('Pending', 'fixture')")
+	require.NoError(t, err)
+	require.Len(t, readPage().Entities, 1, "uncommitted writes aren't visible")
+
+	// Reads keep completing while the transaction writes a batch
+	for i := 0; i < 500; i++ {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO entities (name, entity_type) VALUES (?, 'bulk')", fmt.Sprintf("bulk_%d", i))
+		require.NoError(t, err)
+		if i%100 == 0 {
+			require.Len(t, readPage().Entities, 1, "reads during the batch see only committed entities")
+		}
+	}
+	require.NoError(t, tx.Commit())
+	require.Len(t, readPage().Entities, 10, "the batch is visible once committed")
+}
+
+func TestGraphReads_ConsistentDuringWrites(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	var names []string
+	var relations []RelationDTO
+	for i := 0; i < 20; i++ {
+		from, to := fmt.Sprintf("node_%02d_a", i), fmt.Sprintf("node_%02d_b", i)
+		names = append(names, from, to)
+		relations = append(relations, RelationDTO{From: from, To: to, RelationType: "pairs_with"})
+	}
+	entities := make([]EntityWithObservations, len(names))
+	for i, name := range names {
+		entities[i] = EntityWithObservations{Name: name, EntityType: "node", Observations: []string{"node " + name}}
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+
+	// Deleting and recreating the pairs between a read's entity and relation
+	// queries would return relations to entities it didn't return
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for round := 0; round < 50; round++ {
+			for i, rel := range relations {
+				if _, err := db.DeleteEntities(ctx, []string{rel.From}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.CreateEntities(ctx, entities[2*i:2*i+1]); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.CreateRelations(ctx, []RelationDTO{rel}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+
+	assertConsistent := func(op string, graph KnowledgeGraph) bool {
+		present := make(map[string]bool, len(graph.Entities))
+		for _, entity := range graph.Entities {
+			present[entity.Name] = true
+		}
+		for _, rel := range graph.Relations {
+			if !present[rel.From] || !present[rel.To] {
+				return assert.Fail(t, op+" returned a relation to a missing entity", "%+v", rel)
+			}
+		}
+		return true
+	}
+	reads := 0
+	for reading := true; reading; reads++ {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		graph, err := db.ReadGraph(ctx)
+		if !assert.NoError(t, err) || !assertConsistent("ReadGraph", *graph) {
+			break
+		}
+		found, err := db.SearchNodes(ctx, "node", 0, 0)
+		if !assert.NoError(t, err) || !assertConsistent("SearchNodes", found.KnowledgeGraph) {
+			break
+		}
+		opened, err := db.OpenNodes(ctx, names)
+		if !assert.NoError(t, err) || !assertConsistent("OpenNodes", *opened) {
+			break
+		}
+	}
+	<-done
+	assert.Greater(t, reads, 1)
+}
+
+func TestInterner_Bounded(t *testing.T) {
+	types := interner{}
+	for i := 0; i < maxInternedStrings+10; i++ {
+		assert.Equal(t, fmt.Sprint(i), types.intern(fmt.Sprint(i)))
+	}
+	assert.Len(t, types, maxInternedStrings)
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+)
+
+// GraphStats summarizes the size of the graph and of the database file
+type GraphStats struct {
+	Entities      int  `json:"entities"`
+	Relations     int  `json:"relations"`
+	Observations  int  `json:"observations"`
+	EntityTypes   int  `json:"entityTypes"`
+	RelationTypes int  `json:"relationTypes"`
+	FTSEnabled    bool `json:"ftsEnabled"`
+	// ExpiredObservations counts the observations past their expiry, hidden from
+	// reads and not in Observations, that the sweeper hasn't purged yet
+	ExpiredObservations int `json:"expiredObservations"`
+	// SizeBytes is page_count * page_size of the main database file, which every graph
+	// shares; the WAL is not
+	// included
+	SizeBytes int64 `json:"sizeBytes"`
+}
+
+// Stats counts the rows of the graph, leaving out the entities in its trash and their
+// observations, and expired observations, and measures the whole database. The counts
+// read the graph_id index of entities, so they stay cheap on graphs that are small
+// beside the others.
+func (db *DB) Stats(ctx context.Context) (*GraphStats, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	stats := &GraphStats{FTSEnabled: db.ftsEnabled}
+	const entityIDs = "(SELECT id FROM entities WHERE graph_id = ?1)"
+	err = db.reader.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM entities WHERE graph_id = ?1),
+			(SELECT COUNT(*) FROM relations WHERE from_entity_id IN `+entityIDs+`),
+			(SELECT COUNT(*) FROM observations WHERE entity_id IN `+entityIDs+`),
+			(SELECT COUNT(*) FROM observations WHERE entity_id IN `+entityIDs+` AND id IN `+expiredObservationIDs+`),
+			(SELECT COUNT(DISTINCT entity_type) FROM entities WHERE graph_id = ?1),
+			(SELECT COUNT(DISTINCT relation_type) FROM relations WHERE from_entity_id IN `+entityIDs+`),
+			(SELECT page_count FROM pragma_page_count()) * (SELECT page_size FROM pragma_page_size())`, graph,
+	).Scan(&stats.Entities, &stats.Relations, &stats.Observations, &stats.ExpiredObservations, &stats.EntityTypes, &stats.RelationTypes, &stats.SizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	stats.Observations -= stats.ExpiredObservations
+	return stats, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	stats, err := db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Entities+stats.Relations+stats.Observations+stats.EntityTypes+stats.RelationTypes)
+	assert.Equal(t, db.IsFTSEnabled(), stats.FTSEnabled)
+	empty := stats.SizeBytes
+	assert.Positive(t, empty)
+
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking", "works remotely"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"likes chess"}},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+
+	stats, err = db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &GraphStats{
+		Entities:      3,
+		Relations:     3,
+		Observations:  3,
+		EntityTypes:   2,
+		RelationTypes: 2,
+		FTSEnabled:    db.IsFTSEnabled(),
+		SizeBytes:     stats.SizeBytes,
+	}, stats)
+	assert.GreaterOrEqual(t, stats.SizeBytes, empty)
+
+	// Only the graph is counted, not its trash or other graphs
+	db.SetSoftDelete(true)
+	_, err = db.DeleteEntities(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	other := WithGraph(ctx, "other")
+	_, err = db.CreateEntities(other, []EntityWithObservations{{Name: "Carol", EntityType: "robot", Observations: []string{"beeps"}}})
+	assert.NoError(t, err)
+	stats, err = db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 2, 2, 1}, []int{stats.Entities, stats.Relations, stats.Observations, stats.EntityTypes, stats.RelationTypes})
+	stats, err = db.Stats(other)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 0, 1, 1, 0}, []int{stats.Entities, stats.Relations, stats.Observations, stats.EntityTypes, stats.RelationTypes})
+}
@@ -0,0 +1,62 @@
+package database
+
+import "context"
+
+type summaryKey struct{}
+
+// WithSummary returns ctx under which ReadGraph, ReadGraphPage, OpenNodes and the
+// searches leave out the observations of the entities they return, only counting
+// them in TotalObservations, which saves reading and encoding them
+func WithSummary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, summaryKey{}, true)
+}
+
+// summaryFrom reports whether ctx was returned by WithSummary
+func summaryFrom(ctx context.Context) bool {
+	summary, _ := ctx.Value(summaryKey{}).(bool)
+	return summary
+}
+
+// readObservationColumns is observationColumns for a read under ctx: for a summary
+// only the count is selected, with an empty list of observations
+func readObservationColumns(ctx context.Context, limit int) string {
+	if !summaryFrom(ctx) {
+		return observationColumns(limit)
+	}
+	return `(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id AND ` + liveObservation("o") + `) AS total_observations,
+			'[]' AS observations`
+}
+
+// EntitySummary is an entity without its observations, as summary reads return it
+type EntitySummary struct {
+	Name             string `json:"name"`
+	EntityType       string `json:"entityType"`
+	ObservationCount int    `json:"observationCount"`
+}
+
+// KnowledgeGraphSummary is a knowledge graph with its entities summarized
+type KnowledgeGraphSummary struct {
+	Entities  []EntitySummary `json:"entities"`
+	Relations []RelationDTO   `json:"relations"`
+}
+
+// Summarize returns graph with its entities summarized. An entity's observations are
+// counted by TotalObservations when a read path set it, and by its observations
+// otherwise.
+func Summarize(graph *KnowledgeGraph) *KnowledgeGraphSummary {
+	summary := &KnowledgeGraphSummary{
+		Entities:  make([]EntitySummary, len(graph.Entities)),
+		Relations: graph.Relations,
+	}
+	if summary.Relations == nil {
+		summary.Relations = []RelationDTO{}
+	}
+	for i, entity := range graph.Entities {
+		summary.Entities[i] = EntitySummary{
+			Name:             entity.Name,
+			EntityType:       entity.EntityType,
+			ObservationCount: max(entity.TotalObservations, len(entity.Observations)),
+		}
+	}
+	return summary
+}
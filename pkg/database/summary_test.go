@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryReads(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "writes docs", "reviews code"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{
+		{EntityName: "Alice", Contents: []string{"on call"}, ExpiresAt: time.Now().Add(-time.Minute)},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+	db.SetObservationLimit(2)
+
+	full, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+
+	// A summary read lists no observations but counts every unexpired one
+	summaryCtx := WithSummary(ctx)
+	summary, err := db.ReadGraph(summaryCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, full.Relations, summary.Relations)
+	for i, entity := range summary.Entities {
+		assert.Empty(t, entity.Observations, entity.Name)
+		assert.Equal(t, full.Entities[i].TotalObservations, entity.TotalObservations, entity.Name)
+	}
+
+	want := &KnowledgeGraphSummary{
+		Entities: []EntitySummary{
+			{Name: "Alice", EntityType: "person", ObservationCount: 3},
+			{Name: "Bob", EntityType: "person", ObservationCount: 0},
+		},
+		Relations: []RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}},
+	}
+	assert.Equal(t, want, Summarize(summary))
+	assert.Equal(t, want, Summarize(full))
+
+	opened, err := db.OpenNodes(summaryCtx, []string{"Alice", "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, want, Summarize(opened))
+	found, err := db.SearchNodes(summaryCtx, "person", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, want, Summarize(&found.KnowledgeGraph))
+
+	// Entities a store returned without counts are counted by their observations
+	assert.Equal(t, 1, Summarize(&KnowledgeGraph{Entities: []EntityWithObservations{
+		{Name: "Carol", EntityType: "person", Observations: []string{"new"}},
+	}}).Entities[0].ObservationCount)
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	db, err := NewDBWithLogger(path, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Plan", EntityType: "doc", Observations: []string{"step 1"}}})
+	assert.NoError(t, err)
+
+	result, err := db.Sync(ctx)
+	assert.NoError(t, err)
+	assert.False(t, result.Busy)
+	assert.Positive(t, result.LogFrames)
+	assert.Equal(t, result.LogFrames, result.CheckpointedFrames)
+	assert.True(t, result.FilesSynced)
+}
+
+func TestSync_InMemory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	result, err := db.Sync(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, result.FilesSynced)
+}
+
+func TestDatabaseFile(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"/data/memory.db":              "/data/memory.db",
+		"file:/data/memory.db?mode=rw": "/data/memory.db",
+		":memory:":                     "",
+		"file::memory:?cache=shared":   "",
+		"file:shared?mode=memory":      "",
+	} {
+		assert.Equal(t, want, databaseFile(dsn), dsn)
+	}
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaxTagLength is the longest tag, in bytes, AddTags stores
+const MaxTagLength = 64
+
+// tagsColumn selects the tags of an entity aliased as e as a JSON array in tag order,
+// read back with splitTags. It is NULL for an entity without tags, which most are, so
+// reading them allocates nothing.
+const tagsColumn = `(SELECT json_group_array(tag ORDER BY tag) FROM entity_tags WHERE entity_id = e.id HAVING COUNT(*) > 0) AS tags`
+
+// splitTags parses the JSON array of tags tagsColumn selects, returning nil for an
+// entity without tags
+func splitTags(tagsJSON sql.NullString) ([]string, error) {
+	if !tagsJSON.Valid {
+		return nil, nil
+	}
+	return splitObservations(tagsJSON.String)
+}
+
+// insertTagSQL tags an entity unless it already has the tag
+const insertTagSQL = "INSERT INTO entity_tags (entity_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING"
+
+// EntityTags names tags to add to or remove from an entity
+type EntityTags struct {
+	EntityName string   `json:"entityName"`
+	Tags       []string `json:"tags"`
+}
+
+// TagChangeResult is what AddTags or RemoveTags did to one entity
+type TagChangeResult struct {
+	EntityName string `json:"entityName"`
+	// Changed lists the tags added or removed
+	Changed []string `json:"changed"`
+	// Unchanged lists the tags the entity already had, when adding, or didn't have,
+	// when removing
+	Unchanged []string `json:"unchanged"`
+}
+
+type tagsKey struct{}
+
+// WithTags returns ctx under which searches and OpenNodes only return the entities
+// carrying every one of tags
+func WithTags(ctx context.Context, tags []string) context.Context {
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// tagCondition returns the condition, to be joined with AND, narrowing an entity
+// aliased as e to the tags set by WithTags, or "" if there are none
+func tagCondition(ctx context.Context) (string, []any) {
+	tags, _ := ctx.Value(tagsKey{}).([]string)
+	unique := map[string]bool{}
+	for _, tag := range tags {
+		unique[tag] = true
+	}
+	if len(unique) == 0 {
+		return "", nil
+	}
+	list := make([]string, 0, len(unique))
+	for tag := range unique {
+		list = append(list, tag)
+	}
+	in, args := stringList(list)
+	return "e.id IN (SELECT entity_id FROM entity_tags WHERE tag IN " + in +
+		" GROUP BY entity_id HAVING COUNT(*) = ?)", append(args, len(list))
+}
+
+// AddTags adds tags to entities, skipping those an entity already has. It fails with
+// an EntityNotFoundError, adding nothing, if an entity doesn't exist.
+func (db *DB) AddTags(ctx context.Context, tags []EntityTags) ([]TagChangeResult, error) {
+	return retryWriteResult(ctx, db, func() ([]TagChangeResult, error) {
+		return db.changeTags(ctx, "add_tags", tags, insertTagSQL)
+	})
+}
+
+// RemoveTags removes tags from entities, skipping those an entity doesn't have. It
+// fails with an EntityNotFoundError, removing nothing, if an entity doesn't exist.
+func (db *DB) RemoveTags(ctx context.Context, tags []EntityTags) ([]TagChangeResult, error) {
+	return retryWriteResult(ctx, db, func() ([]TagChangeResult, error) {
+		return db.changeTags(ctx, "remove_tags", tags,
+			"DELETE FROM entity_tags WHERE entity_id = ? AND tag = ?")
+	})
+}
+
+// changeTags runs change, which affects no row when the tag is unchanged, for each
+// tag of each entity in one transaction, and moves the update time of the entities
+// whose tags changed
+func (db *DB) changeTags(ctx context.Context, operation string, tags []EntityTags, change string) ([]TagChangeResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]TagChangeResult, 0, len(tags))
+	for i, entity := range tags {
+		if err := checkCancelled(ctx, operation, i, len(tags)); err != nil {
+			return nil, err
+		}
+
+		var entityID int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM entities WHERE graph_id = ? AND name = ?", graph, entity.EntityName).Scan(&entityID)
+		if err == sql.ErrNoRows {
+			return nil, &EntityNotFoundError{Name: entity.EntityName}
+		}
+		if err != nil {
+			return nil, cancelledOr(ctx, err, operation, i, len(tags))
+		}
+
+		result := TagChangeResult{EntityName: entity.EntityName, Changed: []string{}, Unchanged: []string{}}
+		seen := map[string]bool{}
+		for _, tag := range entity.Tags {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			res, err := tx.ExecContext(ctx, change, entityID, tag)
+			if err != nil {
+				return nil, cancelledOr(ctx, err, operation, i, len(tags))
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				result.Changed = append(result.Changed, tag)
+			} else {
+				result.Unchanged = append(result.Unchanged, tag)
+			}
+		}
+		if len(result.Changed) > 0 {
+			if _, err := tx.ExecContext(ctx, "UPDATE entities SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", entityID); err != nil {
+				return nil, cancelledOr(ctx, err, operation, i, len(tags))
+			}
+		}
+		results = append(results, result)
+	}
+
+	var names, changes []string
+	for _, result := range results {
+		if len(result.Changed) > 0 {
+			names = append(names, result.EntityName)
+			changes = append(changes, fmt.Sprintf("%s: %s", result.EntityName, strings.Join(result.Changed, ", ")))
+		}
+	}
+	if len(names) > 0 {
+		verb := "tagged "
+		if operation == "remove_tags" {
+			verb = "untagged "
+		}
+		if err := db.auditTx(ctx, tx, graph, operation, names, verb+strings.Join(changes, "; ")); err != nil {
+			return nil, err
+		}
+	}
+	return results, tx.Commit()
+}
+
+// addTagsTx tags an entity with the tags it doesn't have yet
+func addTagsTx(ctx context.Context, tx *sql.Tx, entityID int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, insertTagSQL, entityID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
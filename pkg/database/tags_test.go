@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}, Tags: []string{"important"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Carol", EntityType: "person", Observations: []string{"likes Rust"}},
+	})
+	assert.NoError(t, err)
+
+	added, err := db.AddTags(ctx, []EntityTags{
+		{EntityName: "Alice", Tags: []string{"source:slack", "important"}},
+		{EntityName: "Bob", Tags: []string{"source:slack", "source:slack"}},
+		{EntityName: "Carol", Tags: []string{"important"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []TagChangeResult{
+		{EntityName: "Alice", Changed: []string{"source:slack"}, Unchanged: []string{"important"}},
+		{EntityName: "Bob", Changed: []string{"source:slack"}, Unchanged: []string{}},
+		{EntityName: "Carol", Changed: []string{"important"}, Unchanged: []string{}},
+	}, added)
+
+	_, err = db.AddTags(ctx, []EntityTags{{EntityName: "Carol", Tags: []string{"archived"}}, {EntityName: "Nobody", Tags: []string{"x"}}})
+	var notFound *EntityNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	entity, err := db.GetEntity(ctx, "Carol")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"important"}, entity.Tags, "a failed call tags nothing")
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"important", "source:slack"}, graph.Entities[0].Tags)
+	assert.Equal(t, []string{"source:slack"}, graph.Entities[1].Tags)
+
+	// Tags filter searches and lookups, every one of them must match
+	names := func(entities []EntityWithObservations) []string {
+		names := []string{}
+		for _, entity := range entities {
+			names = append(names, entity.Name)
+		}
+		return names
+	}
+	found, err := db.SearchNodes(WithTags(ctx, []string{"source:slack"}), "Go", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice", "Bob"}, names(found.Entities))
+	found, err = db.SearchNodes(WithTags(ctx, []string{"source:slack", "important"}), "likes", 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice"}, names(found.Entities))
+	assert.Equal(t, 1, found.TotalMatches)
+	found, err = db.MatchNodes(WithTags(ctx, []string{"important"}), "person", SearchExact, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice", "Carol"}, names(found.Entities))
+	nodes, err := db.OpenNodes(WithTags(ctx, []string{"important"}), []string{"Alice", "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice"}, names(nodes.Entities))
+
+	removed, err := db.RemoveTags(ctx, []EntityTags{{EntityName: "Alice", Tags: []string{"important", "archived"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []TagChangeResult{{EntityName: "Alice", Changed: []string{"important"}, Unchanged: []string{"archived"}}}, removed)
+	found, err = db.SearchNodes(WithTags(ctx, []string{"important"}), "likes", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Carol"}, names(found.Entities))
+
+	// Deleting an entity deletes its tags
+	_, err = db.DeleteEntities(ctx, []string{"Carol"})
+	assert.NoError(t, err)
+	var tags int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM entity_tags").Scan(&tags))
+	assert.Equal(t, 2, tags)
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Kinds of user-supplied strings stored in the graph
+const (
+	TextEntityName   = "entityName"
+	TextEntityType   = "entityType"
+	TextRelationType = "relationType"
+	TextObservation  = "observation"
+)
+
+// StoredText is a user-supplied string as stored. Entity names the entity an
+// observation belongs to.
+type StoredText struct {
+	Kind   string
+	Value  string
+	Entity string
+}
+
+// EachStoredText calls fn with every entity name, distinct entity type, distinct
+// relation type and observation, one kind after the other. It reads on the
+// database's only connection, so fn must not query the database; an error from fn
+// stops the scan and is returned.
+func (db *DB) EachStoredText(ctx context.Context, fn func(StoredText) error) error {
+	for _, scan := range []struct {
+		kind, query string
+	}{
+		{TextEntityName, "SELECT name, '' FROM entities ORDER BY id"},
+		{TextEntityType, "SELECT DISTINCT entity_type, '' FROM entities ORDER BY entity_type"},
+		{TextRelationType, "SELECT DISTINCT relation_type, '' FROM relations ORDER BY relation_type"},
+		{TextObservation, "SELECT o.content, e.name FROM observations o JOIN entities e ON e.id = o.entity_id ORDER BY o.id"},
+	} {
+		if err := db.eachStoredText(ctx, scan.kind, scan.query, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) eachStoredText(ctx context.Context, kind, query string, fn func(StoredText) error) error {
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		text := StoredText{Kind: kind}
+		if err := rows.Scan(&text.Value, &text.Entity); err != nil {
+			return err
+		}
+		if err := fn(text); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// TextRewrite replaces a stored string. A name or type is renamed everywhere it is
+// used, to To[0]. An observation of Entity is replaced by the contents in To, which
+// keep its writer, creation time and session; with none it is deleted.
+type TextRewrite struct {
+	Kind   string
+	Entity string
+	From   string
+	To     []string
+}
+
+// RewriteTexts applies rewrites in one transaction, in order, so either all of them
+// take effect or none. Renaming an entity to a name in use fails. Renaming a type
+// merges it into an existing one of the new name, dropping relations that then
+// duplicate another.
+func (db *DB) RewriteTexts(ctx context.Context, rewrites []TextRewrite) error {
+	return db.retryWrite(ctx, func() error {
+		return db.rewriteTexts(ctx, rewrites)
+	})
+}
+
+// rewriteTexts makes one attempt at RewriteTexts
+func (db *DB) rewriteTexts(ctx context.Context, rewrites []TextRewrite) error {
+	start := time.Now()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, rw := range rewrites {
+		if err := checkCancelled(ctx, "rewrite_texts", i, len(rewrites)); err != nil {
+			return err
+		}
+		if err := rewriteText(ctx, tx, rw); err != nil {
+			return fmt.Errorf("rewriting %s %q: %w", rw.Kind, rw.From, cancelledOr(ctx, err, "rewrite_texts", i, len(rewrites)))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.logger.Info("stored texts rewritten",
+		slog.Int("rewrites", len(rewrites)),
+		slog.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+func rewriteText(ctx context.Context, tx *sql.Tx, rw TextRewrite) error {
+	if rw.Kind != TextObservation && len(rw.To) != 1 {
+		return fmt.Errorf("a %s is renamed to exactly one value, got %d", rw.Kind, len(rw.To))
+	}
+
+	var statements []string
+	var args [][]any
+	switch rw.Kind {
+	case TextEntityName:
+		statements = []string{"UPDATE entities SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?"}
+		args = [][]any{{rw.To[0], rw.From}}
+	case TextEntityType:
+		statements = []string{
+			"UPDATE entities SET entity_type = ?, updated_at = CURRENT_TIMESTAMP WHERE entity_type = ?",
+			// Metadata already set on the new type wins
+			"UPDATE OR IGNORE entity_type_meta SET entity_type = ? WHERE entity_type = ?",
+			"DELETE FROM entity_type_meta WHERE entity_type = ?",
+		}
+		args = [][]any{{rw.To[0], rw.From}, {rw.To[0], rw.From}, {rw.From}}
+	case TextRelationType:
+		statements = []string{
+			"UPDATE OR IGNORE relations SET relation_type = ? WHERE relation_type = ?",
+			"DELETE FROM relations WHERE relation_type = ?",
+		}
+		args = [][]any{{rw.To[0], rw.From}, {rw.From}}
+	case TextObservation:
+		return rewriteObservation(ctx, tx, rw)
+	default:
+		return fmt.Errorf("unknown kind %q", rw.Kind)
+	}
+
+	for i, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, args[i]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rewriteObservation(ctx context.Context, tx *sql.Tx, rw TextRewrite) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT o.id FROM observations o JOIN entities e ON e.id = o.entity_id
+		WHERE e.name = ? AND o.content = ?`, rw.Entity, rw.From,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	keep := false
+	for _, content := range rw.To {
+		if content == rw.From {
+			keep = true
+			continue
+		}
+		// Parts are copied from the original row; one the entity already has is kept once
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO observations (entity_id, content, written_by, created_at, session)
+			SELECT entity_id, ?, written_by, created_at, session FROM observations WHERE id = ?`,
+			content, id,
+		); err != nil {
+			return err
+		}
+	}
+	if keep {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM observations WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEachStoredText(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes tea"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Bob", To: "Alice", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+
+	var texts []StoredText
+	assert.NoError(t, db.EachStoredText(ctx, func(text StoredText) error {
+		texts = append(texts, text)
+		return nil
+	}))
+	assert.Equal(t, []StoredText{
+		{Kind: TextEntityName, Value: "Alice"},
+		{Kind: TextEntityName, Value: "Bob"},
+		{Kind: TextEntityType, Value: "person"},
+		{Kind: TextRelationType, Value: "knows"},
+		{Kind: TextObservation, Value: "likes tea", Entity: "Alice"},
+	}, texts)
+
+	// An error from fn stops the scan
+	calls := 0
+	err = db.EachStoredText(ctx, func(StoredText) error {
+		calls++
+		return context.Canceled
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRewriteTexts(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(WithSession(WithWriter(ctx, "agent-a"), "task-1"), []EntityWithObservations{
+		{Name: "Alice\n", EntityType: "person ", Observations: []string{"one two three", "kept"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetTypeMetadata(ctx, "person ", map[string]string{"color": "red", "group": "people"}))
+	assert.NoError(t, db.SetTypeMetadata(ctx, "person", map[string]string{"color": "blue"}))
+
+	err = db.RewriteTexts(ctx, []TextRewrite{
+		{Kind: TextObservation, Entity: "Alice\n", From: "one two three", To: []string{"one two", "three", "kept"}},
+		{Kind: TextEntityName, From: "Alice\n", To: []string{"Alice"}},
+		{Kind: TextEntityType, From: "person ", To: []string{"person"}},
+	})
+	assert.NoError(t, err)
+
+	graph, err := db.OpenNodes(ctx, []string{"Alice"})
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+	assert.Equal(t, "person", graph.Entities[0].EntityType)
+	assert.ElementsMatch(t, []string{"one two", "three", "kept"}, graph.Entities[0].Observations)
+
+	// The parts keep the writer and session of the original
+	metadata, err := db.EntityMetadata(ctx, []string{"Alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, metadata["Alice"].Contributors)
+	assert.Equal(t, "agent-a", metadata["Alice"].LastWriter)
+	sessions, err := db.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, 3, sessions[0].Observations)
+
+	// The merged type keeps its own metadata and gains the keys it lacked
+	meta, err := db.GetTypeMetadata(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMetadata{"person": {"color": "blue", "group": "people"}}, meta)
+}
+
+func TestRewriteTexts_MergesRelationTypes(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Alice", To: "Bob", RelationType: "knows "},
+		{From: "Bob", To: "Alice", RelationType: "knows "},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.RewriteTexts(ctx, []TextRewrite{{Kind: TextRelationType, From: "knows ", To: []string{"knows"}}}))
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []RelationDTO{
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Bob", To: "Alice", RelationType: "knows"},
+	}, graph.Relations)
+
+	// A failing rewrite undoes the ones before it
+	err = db.RewriteTexts(ctx, []TextRewrite{
+		{Kind: TextRelationType, From: "knows", To: []string{"met"}},
+		{Kind: TextEntityName, From: "Alice", To: []string{"Bob"}},
+	})
+	assert.Error(t, err)
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "knows", graph.Relations[0].RelationType)
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// rfc3339Column formats a stored timestamp column as RFC 3339 UTC
+func rfc3339Column(column string) string {
+	return "strftime('%Y-%m-%dT%H:%M:%SZ', " + column + ")"
+}
+
+// AddTimestamps fills in when the entities of graph were created and last updated,
+// when each of their listed observations was stored, and when each relation was
+// created. An entity's update time moves whenever it is renamed or retyped or one of
+// its observations, or a relation from or to it, is added or deleted. Entities and relations no longer in the
+// database, and relations from entities outside the graph, are left without
+// timestamps.
+func (db *DB) AddTimestamps(ctx context.Context, graph *KnowledgeGraph) error {
+	names := make([]string, len(graph.Entities))
+	byName := make(map[string]*EntityWithObservations, len(graph.Entities))
+	for i := range graph.Entities {
+		names[i] = graph.Entities[i].Name
+		byName[names[i]] = &graph.Entities[i]
+	}
+	relations := make(map[RelationDTO]int, len(graph.Relations))
+	for i, rel := range graph.Relations {
+		relations[RelationDTO{From: rel.From, To: rel.To, RelationType: rel.RelationType}] = i
+	}
+	scope, err := graphID(ctx, db.reader)
+	if err != nil {
+		return err
+	}
+
+	observations := make(map[string]map[string]string, len(names))
+	for start := 0; start < len(names); start += pathQueryChunk {
+		chunk, args := stringList(names[start:min(start+pathQueryChunk, len(names))])
+		args = append([]any{scope}, args...)
+		rows, err := db.reader.QueryContext(ctx, fmt.Sprintf(
+			"SELECT name, %s, %s FROM entities WHERE graph_id = ? AND name IN %s",
+			rfc3339Column("created_at"), rfc3339Column("updated_at"), chunk), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name string
+			var createdAt, updatedAt sql.NullString
+			if err := rows.Scan(&name, &createdAt, &updatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			byName[name].CreatedAt, byName[name].UpdatedAt = createdAt.String, updatedAt.String
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = db.reader.QueryContext(ctx, fmt.Sprintf(`
+			SELECT e.name, o.content, %s
+			FROM observations o JOIN entities e ON e.id = o.entity_id
+			WHERE e.graph_id = ? AND e.name IN %s AND %s`, rfc3339Column("o.created_at"), chunk, liveObservation("o")), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var name, content string
+			var createdAt sql.NullString
+			if err := rows.Scan(&name, &content, &createdAt); err != nil {
+				rows.Close()
+				return err
+			}
+			if observations[name] == nil {
+				observations[name] = map[string]string{}
+			}
+			observations[name][content] = createdAt.String
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	for i := range graph.Entities {
+		entity := &graph.Entities[i]
+		entity.ObservationsCreatedAt = make([]string, len(entity.Observations))
+		for j, content := range entity.Observations {
+			entity.ObservationsCreatedAt[j] = observations[entity.Name][content]
+		}
+	}
+
+	if len(relations) == 0 {
+		return nil
+	}
+	// Relations are looked up by their from entity, so those starting outside the
+	// graph's entities are left without a timestamp
+	for start := 0; start < len(names); start += pathQueryChunk {
+		chunk, args := stringList(names[start:min(start+pathQueryChunk, len(names))])
+		args = append([]any{scope}, args...)
+		rows, err := db.reader.QueryContext(ctx, fmt.Sprintf(`
+			SELECT e1.name, e2.name, r.relation_type, %s
+			FROM relations r
+			JOIN entities e1 ON e1.id = r.from_entity_id
+			JOIN entities e2 ON e2.id = r.to_entity_id
+			WHERE e1.graph_id = ? AND e1.name IN %s`, rfc3339Column("r.created_at"), chunk), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key RelationDTO
+			var createdAt sql.NullString
+			if err := rows.Scan(&key.From, &key.To, &key.RelationType, &createdAt); err != nil {
+				rows.Close()
+				return err
+			}
+			if i, ok := relations[key]; ok {
+				graph.Relations[i].CreatedAt = createdAt.String
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecentEntities returns the limit entities updated most recently, newest first, with
+// their observations capped like OpenNodes and their creation and update times set
+// as AddTimestamps sets them. Entities updated in the same second are ordered newest
+// created first.
+func (db *DB) RecentEntities(ctx context.Context, limit int) ([]EntityWithObservations, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.reader.QueryContext(ctx, fmt.Sprintf(`
+		SELECT e.name, e.entity_type, %s, %s, %s, %s, %s, %s
+		FROM entities e
+		WHERE e.graph_id = ?
+		ORDER BY e.updated_at DESC, e.id DESC
+		LIMIT ?
+	`, observationColumns(db.observationLimit), tagsColumn, attributesColumn, pinnedColumn, rfc3339Column("e.created_at"), rfc3339Column("e.updated_at")), graph, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []EntityWithObservations{}
+	types := interner{}
+	for rows.Next() {
+		var entity EntityWithObservations
+		var observations string
+		var tags, attributes sql.NullString
+		var createdAt, updatedAt sql.NullString
+		if err := rows.Scan(&entity.Name, &entity.EntityType, &entity.TotalObservations, &observations, &tags, &attributes, &entity.Pinned, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		entity.EntityType = types.intern(entity.EntityType)
+		entity.CreatedAt, entity.UpdatedAt = createdAt.String, updatedAt.String
+		if entity.Observations, err = splitObservations(observations); err != nil {
+			return nil, err
+		}
+		if entity.Tags, err = splitTags(tags); err != nil {
+			return nil, err
+		}
+		if entity.Attributes, err = splitAttributes(attributes); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTimestamps(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking", "works remotely"}},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, `
+		UPDATE observations SET created_at = CASE content WHEN 'likes hiking' THEN '2020-01-02 03:04:05' ELSE '2021-06-07 08:09:10' END`)
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "UPDATE entities SET created_at = '2019-01-01 00:00:00', updated_at = '2019-01-01 00:00:00'")
+	assert.NoError(t, err)
+	_, err = db.conn.ExecContext(ctx, "UPDATE relations SET created_at = '2022-02-02 02:02:02'")
+	assert.NoError(t, err)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddTimestamps(ctx, graph))
+	assert.Equal(t, EntityWithObservations{
+		Name: "Alice", EntityType: "person", Observations: []string{"likes hiking", "works remotely"}, TotalObservations: 2,
+		CreatedAt: "2019-01-01T00:00:00Z", UpdatedAt: "2019-01-01T00:00:00Z",
+		ObservationsCreatedAt: []string{"2020-01-02T03:04:05Z", "2021-06-07T08:09:10Z"},
+	}, graph.Entities[1])
+	assert.Equal(t, []string{}, graph.Entities[0].ObservationsCreatedAt)
+	assert.Equal(t, "2022-02-02T02:02:02Z", graph.Relations[0].CreatedAt)
+	for _, ts := range []string{graph.Entities[0].CreatedAt, graph.Entities[0].UpdatedAt, graph.Relations[0].CreatedAt} {
+		_, err := time.Parse(time.RFC3339, ts)
+		assert.NoError(t, err)
+	}
+
+	// Adding or deleting an observation moves updated_at, other entities keep theirs
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Alice", Contents: []string{"plays chess"}}})
+	assert.NoError(t, err)
+	graph, err = db.OpenNodes(ctx, []string{"Alice", "Acme"})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddTimestamps(ctx, graph))
+	assert.Equal(t, "2019-01-01T00:00:00Z", graph.Entities[0].UpdatedAt)
+	assert.Equal(t, "2019-01-01T00:00:00Z", graph.Entities[1].CreatedAt)
+	updated, err := time.Parse(time.RFC3339, graph.Entities[1].UpdatedAt)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), updated, time.Minute)
+	assert.Len(t, graph.Entities[1].ObservationsCreatedAt, 3)
+
+	_, err = db.conn.ExecContext(ctx, "UPDATE entities SET updated_at = '2019-01-01 00:00:00'")
+	assert.NoError(t, err)
+	_, err = db.DeleteObservations(ctx, []ObservationDeletionInput{{EntityName: "Alice", Observations: []string{"plays chess"}}})
+	assert.NoError(t, err)
+	graph, err = db.OpenNodes(ctx, []string{"Alice"})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddTimestamps(ctx, graph))
+	assert.NotEqual(t, "2019-01-01T00:00:00Z", graph.Entities[0].UpdatedAt)
+
+	// Entities gone since the graph was read are left without timestamps
+	graph = &KnowledgeGraph{Entities: []EntityWithObservations{{Name: "Ghost", Observations: []string{"boo"}}}}
+	assert.NoError(t, db.AddTimestamps(ctx, graph))
+	assert.Empty(t, graph.Entities[0].CreatedAt)
+	assert.Equal(t, []string{""}, graph.Entities[0].ObservationsCreatedAt)
+}
+
+func TestMigrate_EntityUpdateTrigger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	if !db.IsFTSEnabled() {
+		db.Close()
+		t.Skip("FTS5 not available")
+	}
+	// The trigger as earlier versions created it
+	_, err = db.conn.Exec(`DROP TRIGGER entities_au`)
+	assert.NoError(t, err)
+	_, err = db.conn.Exec(`CREATE TRIGGER entities_au AFTER UPDATE ON entities BEGIN
+		DELETE FROM entities_fts WHERE entity_id = old.id;
+		INSERT INTO entities_fts(entity_id, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+	END;`)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	db, err = NewDBWithLogger(path, logger)
+	assert.NoError(t, err)
+	defer db.Close()
+	var sql string
+	assert.NoError(t, db.conn.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'entities_au'").Scan(&sql))
+	assert.Contains(t, sql, "AFTER UPDATE OF name, entity_type")
+
+	// Renames still reach the index
+	ctx := context.Background()
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Alice", EntityType: "person"}})
+	assert.NoError(t, err)
+	_, err = db.conn.Exec("UPDATE entities SET name = 'Alicia' WHERE name = 'Alice'")
+	assert.NoError(t, err)
+	found, err := db.SearchNodesFTS(ctx, "Alicia", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 1)
+}
+
+func TestRecentEntities(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking"}},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	backdate := func() {
+		t.Helper()
+		_, err := db.conn.ExecContext(ctx, `
+			UPDATE entities SET updated_at = CASE name WHEN 'Alice' THEN '2019-01-01 00:00:00' WHEN 'Acme' THEN '2020-01-01 00:00:00' ELSE '2021-01-01 00:00:00' END`)
+		assert.NoError(t, err)
+	}
+	recentNames := func(limit int) []string {
+		t.Helper()
+		entities, err := db.RecentEntities(ctx, limit)
+		assert.NoError(t, err)
+		names := make([]string, len(entities))
+		for i, entity := range entities {
+			names[i] = entity.Name
+		}
+		return names
+	}
+
+	backdate()
+	assert.Equal(t, []string{"Bob", "Acme"}, recentNames(2))
+
+	// Adding an observation moves the entity forward
+	_, err = db.AddObservations(ctx, []ObservationAdditionInput{{EntityName: "Alice", Contents: []string{"plays chess"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Alice", "Bob", "Acme"}, recentNames(10))
+	entities, err := db.RecentEntities(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"likes hiking", "plays chess"}, entities[0].Observations)
+	updated, err := time.Parse(time.RFC3339, entities[0].UpdatedAt)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), updated, time.Minute)
+	assert.NotEmpty(t, entities[0].CreatedAt)
+
+	// So do creating and deleting a relation, at both of its ends
+	backdate()
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", recentNames(3)[2])
+
+	backdate()
+	_, err = db.DeleteRelations(ctx, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", recentNames(3)[2])
+}
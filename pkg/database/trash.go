@@ -0,0 +1,400 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// trashGraphSuffix names the graph holding the entities soft-deleted from another:
+// "default/trash" for the default graph. ValidGraphName rejects '/', so clients can't
+// name it, and ListGraphs leaves it out.
+const trashGraphSuffix = "/trash"
+
+// SetSoftDelete makes DeleteEntities move entities to the trash, from which
+// RestoreEntities brings them back and PurgeDeleted deletes them, instead of deleting
+// them with their observations and relations at once
+func (db *DB) SetSoftDelete(enabled bool) {
+	db.softDelete = enabled
+}
+
+// SoftDelete reports whether DeleteEntities moves entities to the trash
+func (db *DB) SoftDelete() bool {
+	return db.softDelete
+}
+
+// DeletedEntity is an entity in the trash
+type DeletedEntity struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType"`
+	// DeletedAt is when the entity was deleted (RFC 3339, UTC)
+	DeletedAt    string `json:"deletedAt"`
+	Observations int    `json:"observations"`
+	// Relations counts the relations deleted with the entity, restored with it when
+	// the entity at their other end exists
+	Relations int `json:"relations"`
+}
+
+// RestoreResult reports what RestoreEntities brought back
+type RestoreResult struct {
+	Restored []string `json:"restored"`
+	// NotFound lists the names given that matched no entity in the trash
+	NotFound []string `json:"notFound"`
+	// Conflicts lists the entities left in the trash because an entity of the same
+	// name has been created since they were deleted
+	Conflicts []string `json:"conflicts"`
+	// RestoredRelations counts the relations restored with the entities
+	RestoredRelations int `json:"restoredRelations"`
+	// AliasesDropped counts the aliases of the entities not restored because another
+	// entity of the graph has taken them, as an alias or a name, since they were deleted
+	AliasesDropped int `json:"aliasesDropped,omitempty"`
+}
+
+// trashGraphID returns the id of the trash of graph, or 0, which no entity has, if
+// nothing has been deleted from it yet
+func trashGraphID(ctx context.Context, q relationQuerier, graph int64) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx,
+		"SELECT t.id FROM graphs g JOIN graphs t ON t.name = g.name || ? WHERE g.id = ?", trashGraphSuffix, graph,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// createTrashGraphTx returns the id of the trash of graph, creating it if it doesn't
+// exist yet
+func createTrashGraphTx(ctx context.Context, tx *sql.Tx, graph int64) (int64, error) {
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO graphs (name) SELECT name || ? FROM graphs WHERE id = ? ON CONFLICT(name) DO NOTHING", trashGraphSuffix, graph,
+	); err != nil {
+		return 0, err
+	}
+	return trashGraphID(ctx, tx, graph)
+}
+
+// trashEntityChunk moves the named entities of a graph to its trash, returning the
+// names of those that existed
+func (db *DB) trashEntityChunk(ctx context.Context, graph int64, names []string) ([]string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	list, args := stringList(names)
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM entities WHERE graph_id = ? AND name IN "+list, append([]any{graph}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var trashed []string
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		trashed = append(trashed, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := trashEntityTx(ctx, tx, graph, id); err != nil {
+			return nil, err
+		}
+	}
+	if len(trashed) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "delete_entities", trashed, "moved to the trash: "+auditNames(trashed)); err != nil {
+			return nil, err
+		}
+	}
+	return trashed, tx.Commit()
+}
+
+// trashEntityTx moves an entity of graph to its trash with its observations, and its
+// relations to trash_relations. An entity of the same name already in the trash,
+// deleted earlier, is deleted for good. Its aliases go to the trash with it, so they
+// are free for other entities of graph and come back when it is restored; an alias
+// the trash already has is taken from the entity deleted earlier.
+func trashEntityTx(ctx context.Context, tx *sql.Tx, graph, id int64) error {
+	trash, err := createTrashGraphTx(ctx, tx, graph)
+	if err != nil {
+		return err
+	}
+	// Observations first, so their delete triggers run as for DeleteEntities
+	for _, stmt := range []string{
+		"DELETE FROM observations WHERE entity_id IN (SELECT id FROM entities WHERE graph_id = ? AND name = (SELECT name FROM entities WHERE id = ?))",
+		"DELETE FROM entities WHERE graph_id = ? AND name = (SELECT name FROM entities WHERE id = ?)",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, trash, id); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO trash_relations (id, from_entity_id, to_entity_id, relation_type, created_at, session, confidence, note)
+			SELECT id, from_entity_id, to_entity_id, relation_type, created_at, session, confidence, note
+			FROM relations WHERE from_entity_id = ?1 OR to_entity_id = ?1`,
+		"DELETE FROM relations WHERE from_entity_id = ?1 OR to_entity_id = ?1",
+		"UPDATE OR REPLACE entity_aliases SET graph_id = ?2 WHERE entity_id = ?1",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id, trash); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE entities SET graph_id = ?, deleted_at = CURRENT_TIMESTAMP WHERE id = ?", trash, id)
+	return err
+}
+
+// restoreAliasesTx moves the aliases of entity id back from the trash to graph, and
+// drops those another entity of graph has taken since, returning how many
+func restoreAliasesTx(ctx context.Context, tx *sql.Tx, graph, id int64) (int, error) {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE OR IGNORE entity_aliases SET graph_id = ?1
+		WHERE entity_id = ?2 AND alias NOT IN (SELECT name FROM entities WHERE graph_id = ?1)`, graph, id,
+	); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM entity_aliases WHERE entity_id = ? AND graph_id <> ?", id, graph)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ListDeleted lists the entities in the trash of the graph, most recently deleted
+// first
+func (db *DB) ListDeleted(ctx context.Context) ([]DeletedEntity, error) {
+	graph, err := graphID(ctx, db.reader)
+	if err != nil {
+		return nil, err
+	}
+	trash, err := trashGraphID(ctx, db.reader, graph)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT
+			e.name,
+			e.entity_type,
+			`+rfc3339Column("e.deleted_at")+`,
+			(SELECT COUNT(*) FROM observations WHERE entity_id = e.id),
+			(SELECT COUNT(*) FROM trash_relations WHERE from_entity_id = e.id OR to_entity_id = e.id)
+		FROM entities e
+		WHERE e.graph_id = ?
+		ORDER BY e.deleted_at DESC, e.name`, trash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := []DeletedEntity{}
+	for rows.Next() {
+		var entity DeletedEntity
+		var deletedAt sql.NullString
+		if err := rows.Scan(&entity.Name, &entity.EntityType, &deletedAt, &entity.Observations, &entity.Relations); err != nil {
+			return nil, err
+		}
+		entity.DeletedAt = deletedAt.String
+		deleted = append(deleted, entity)
+	}
+	return deleted, rows.Err()
+}
+
+// RestoreEntities moves the named entities out of the trash of the graph with their
+// observations, and restores their relations whose other end exists outside it,
+// including relations between entities restored together
+func (db *DB) RestoreEntities(ctx context.Context, names []string) (*RestoreResult, error) {
+	return retryWriteResult(ctx, db, func() (*RestoreResult, error) {
+		return db.restoreEntities(ctx, names)
+	})
+}
+
+func (db *DB) restoreEntities(ctx context.Context, names []string) (*RestoreResult, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &RestoreResult{Restored: []string{}, NotFound: []string{}, Conflicts: []string{}}
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	trash, err := trashGraphID(ctx, tx, graph)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []int64
+	for i, name := range dedupe(names) {
+		if err := checkCancelled(ctx, "restore_entities", i, len(names)); err != nil {
+			return nil, err
+		}
+		var id int64
+		var taken bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, EXISTS (SELECT 1 FROM entities WHERE graph_id = ? AND name = ?)
+			FROM entities WHERE graph_id = ? AND name = ?`,
+			graph, name, trash, name,
+		).Scan(&id, &taken)
+		switch {
+		case err == sql.ErrNoRows:
+			result.NotFound = append(result.NotFound, name)
+			continue
+		case err != nil:
+			return nil, cancelledOr(ctx, err, "restore_entities", i, len(names))
+		case taken:
+			result.Conflicts = append(result.Conflicts, name)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE entities SET graph_id = ?, deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?", graph, id,
+		); err != nil {
+			return nil, cancelledOr(ctx, err, "restore_entities", i, len(names))
+		}
+		dropped, err := restoreAliasesTx(ctx, tx, graph, id)
+		if err != nil {
+			return nil, cancelledOr(ctx, err, "restore_entities", i, len(names))
+		}
+		result.AliasesDropped += dropped
+		restored = append(restored, id)
+		result.Restored = append(result.Restored, name)
+	}
+
+	// Relations come back once both their ends are out of the trash
+	for _, chunk := range chunks(restored, maxListValues/2) {
+		list, ids := inList(chunk)
+		args := append(append([]any{graph, graph}, ids...), ids...)
+		rows, err := tx.QueryContext(ctx, `
+			SELECT tr.id FROM trash_relations tr
+			JOIN entities f ON f.id = tr.from_entity_id AND f.graph_id = ?
+			JOIN entities t ON t.id = tr.to_entity_id AND t.graph_id = ?
+			WHERE tr.from_entity_id IN `+list+` OR tr.to_entity_id IN `+list, args...)
+		if err != nil {
+			return nil, err
+		}
+		var relationIDs []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			relationIDs = append(relationIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, ids := range chunks(relationIDs, maxListValues) {
+			in, args := inList(ids)
+			res, err := tx.ExecContext(ctx, `
+				INSERT OR IGNORE INTO relations (id, from_entity_id, to_entity_id, relation_type, created_at, session, confidence, note)
+				SELECT id, from_entity_id, to_entity_id, relation_type, created_at, session, confidence, note
+				FROM trash_relations WHERE id IN `+in, args...)
+			if err != nil {
+				return nil, err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			result.RestoredRelations += int(n)
+			if _, err := tx.ExecContext(ctx, "DELETE FROM trash_relations WHERE id IN "+in, args...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(result.Restored) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "restore_entities", result.Restored, "restored from the trash: "+auditNames(result.Restored)); err != nil {
+			return nil, err
+		}
+	}
+	return result, tx.Commit()
+}
+
+// PurgeDeleted deletes entities in the trash of the graph for good, with their
+// observations and relations: those named, or every one if names is empty, deleted
+// at least olderThanDays days ago. It returns the names of those purged.
+func (db *DB) PurgeDeleted(ctx context.Context, names []string, olderThanDays int) ([]string, error) {
+	return retryWriteResult(ctx, db, func() ([]string, error) {
+		return db.purgeDeleted(ctx, names, olderThanDays)
+	})
+}
+
+func (db *DB) purgeDeleted(ctx context.Context, names []string, olderThanDays int) ([]string, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	graph, err := graphID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	trash, err := trashGraphID(ctx, tx, graph)
+	if err != nil {
+		return nil, err
+	}
+	purged := []string{}
+	if trash == 0 {
+		return purged, nil
+	}
+
+	cond, args := "e.graph_id = ? AND e.deleted_at <= datetime('now', ?)", []any{trash, fmt.Sprintf("-%d days", olderThanDays)}
+	if len(names) > 0 {
+		list, nameArgs := stringList(dedupe(names))
+		cond += " AND e.name IN " + list
+		args = append(args, nameArgs...)
+	}
+	// Observations first, so their delete triggers run as for DeleteEntities
+	if _, err := tx.ExecContext(ctx, "DELETE FROM observations WHERE entity_id IN (SELECT e.id FROM entities e WHERE "+cond+")", args...); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, "DELETE FROM entities AS e WHERE "+cond+" RETURNING name", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		purged = append(purged, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(purged) > 0 {
+		if err := db.auditTx(ctx, tx, graph, "purge_deleted", purged, "purged from the trash: "+auditNames(purged)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if len(purged) > 0 {
+		db.logger.Info("purged deleted entities",
+			slog.String("graph", graphFrom(ctx)),
+			slog.Int("purged", len(purged)),
+		)
+	}
+	return purged, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftDelete(t *testing.T) {
+	db := newImportTestDB(t)
+	db.SetSoftDelete(true)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go", "lives in Berlin"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at", Confidence: 0.9},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+
+	deleted, err := db.DeleteEntities(ctx, []string{"Alice", "Bob", "Nobody"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted.DeletedEntities)
+	assert.Equal(t, []string{"Nobody"}, deleted.NotFound)
+
+	// Trashed entities are hidden from reads, searches and the stats
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+	assert.Empty(t, graph.Relations)
+	for _, search := range []func(context.Context, string, int, int) (*SearchResult, error){db.SearchNodes, db.SearchNodesFTS} {
+		found, err := search(ctx, "Go", 0, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, found.Entities)
+	}
+	stats, err := db.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Entities)
+	assert.Equal(t, 0, stats.Observations)
+	graphs, err := db.ListGraphs(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graphs, 1, "the trash is not a graph of its own")
+
+	trash, err := db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 2) {
+		assert.Equal(t, "Alice", trash[0].Name)
+		assert.Equal(t, 2, trash[0].Observations)
+		assert.Equal(t, 2, trash[0].Relations)
+		assert.NotEmpty(t, trash[0].DeletedAt)
+	}
+
+	// A name taken since is a conflict; relations come back when both ends are back
+	_, err = db.CreateEntities(ctx, []EntityWithObservations{{Name: "Bob", EntityType: "person"}})
+	assert.NoError(t, err)
+	restored, err := db.RestoreEntities(ctx, []string{"Alice", "Bob", "Nobody"})
+	assert.NoError(t, err)
+	assert.Equal(t, &RestoreResult{Restored: []string{"Alice"}, NotFound: []string{"Nobody"}, Conflicts: []string{"Bob"}, RestoredRelations: 1}, restored)
+	entity, err := db.GetEntity(ctx, "Alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"likes Go", "lives in Berlin"}, entity.Observations)
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at", Confidence: 0.9}}, graph.Relations)
+	found, err := db.SearchNodesFTS(ctx, "Berlin", 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found.Entities, 1, "restored entities are searchable again")
+
+	// Deleting a name again replaces the one in the trash
+	_, err = db.DeleteEntities(ctx, []string{"Bob"})
+	assert.NoError(t, err)
+	trash, err = db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 1) {
+		assert.Equal(t, 0, trash[0].Observations)
+	}
+
+	// Purging honors the age, then deletes for good
+	purged, err := db.PurgeDeleted(ctx, nil, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, purged)
+	purged, err = db.PurgeDeleted(ctx, []string{"Bob"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bob"}, purged)
+	trash, err = db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+	var trashedRelations int
+	assert.NoError(t, db.conn.QueryRow("SELECT COUNT(*) FROM trash_relations").Scan(&trashedRelations))
+	assert.Equal(t, 0, trashedRelations)
+
+	// Graphs keep their trash apart
+	other := WithGraph(ctx, "work")
+	_, err = db.CreateEntities(other, []EntityWithObservations{{Name: "Alice", EntityType: "person"}})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(other, []string{"Alice"})
+	assert.NoError(t, err)
+	trash, err = db.ListDeleted(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+	trash, err = db.ListDeleted(other)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// TypeMetadata maps entity types to their key-value metadata, such as presentation
+// hints like color and group. Keys have no fixed meaning to the database; they are
+// stored and returned untouched so consumers can define their own.
+type TypeMetadata map[string]map[string]string
+
+// SetTypeMetadata stores values for an entity type, replacing existing values of
+// the same keys. An empty value removes its key. The type need not have entities yet.
+func (db *DB) SetTypeMetadata(ctx context.Context, entityType string, values map[string]string) error {
+	return db.retryWrite(ctx, func() error {
+		return db.setTypeMetadata(ctx, entityType, values)
+	})
+}
+
+// setTypeMetadata makes one attempt at SetTypeMetadata
+func (db *DB) setTypeMetadata(ctx context.Context, entityType string, values map[string]string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setTypeMetadataTx(ctx, tx, entityType, values); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setTypeMetadataTx applies values within tx; see SetTypeMetadata
+func setTypeMetadataTx(ctx context.Context, tx *sql.Tx, entityType string, values map[string]string) error {
+	var err error
+	for key, value := range values {
+		if value == "" {
+			_, err = tx.ExecContext(ctx, "DELETE FROM entity_type_meta WHERE entity_type = ? AND key = ?", entityType, key)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO entity_type_meta (entity_type, key, value) VALUES (?, ?, ?)
+				ON CONFLICT(entity_type, key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+				entityType, key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTypeMetadata returns the metadata of the given entity types, or of every type
+// with metadata when none are given. Types without metadata are left out.
+func (db *DB) GetTypeMetadata(ctx context.Context, entityTypes []string) (TypeMetadata, error) {
+	query := "SELECT entity_type, key, value FROM entity_type_meta"
+	args := make([]any, len(entityTypes))
+	if len(entityTypes) > 0 {
+		for i, t := range entityTypes {
+			args[i] = t
+		}
+		query += " WHERE entity_type IN (?" + strings.Repeat(",?", len(entityTypes)-1) + ")"
+	}
+	rows, err := db.reader.QueryContext(ctx, query+" ORDER BY entity_type, key", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meta := TypeMetadata{}
+	for rows.Next() {
+		var entityType, key, value string
+		if err := rows.Scan(&entityType, &key, &value); err != nil {
+			return nil, err
+		}
+		if meta[entityType] == nil {
+			meta[entityType] = map[string]string{}
+		}
+		meta[entityType][key] = value
+	}
+	return meta, rows.Err()
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeMetadata(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	assert.NoError(t, db.SetTypeMetadata(ctx, "incident", map[string]string{"color": "blue", "icon": "siren"}))
+	assert.NoError(t, db.SetTypeMetadata(ctx, "incident", map[string]string{"color": "red", "icon": ""}))
+	assert.NoError(t, db.SetTypeMetadata(ctx, "service", map[string]string{"group": "infra"}))
+
+	meta, err := db.GetTypeMetadata(ctx, []string{"incident", "person"})
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMetadata{"incident": {"color": "red"}}, meta)
+
+	meta, err = db.GetTypeMetadata(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMetadata{"incident": {"color": "red"}, "service": {"group": "infra"}}, meta)
+}
+
+func TestExportDOT_TypeMetadata(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Outage 42", EntityType: "incident"},
+		{Name: "Outage 43", EntityType: "incident"},
+		{Name: "API", EntityType: "service"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{{From: "Outage 42", To: "API", RelationType: "affected"}})
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetTypeMetadata(ctx, "incident", map[string]string{"color": "red", "x-owner": `on "call"`}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, db.ExportDOT(ctx, &buf))
+	dot := buf.String()
+
+	assert.True(t, strings.HasPrefix(dot, "digraph memory {\n"))
+	assert.Contains(t, dot, `"Outage 42" [label="Outage 42", entityType="incident", color="red", "x-owner"="on \"call\""];`)
+	assert.Contains(t, dot, `"Outage 43" [label="Outage 43", entityType="incident", color="red", "x-owner"="on \"call\""];`)
+	assert.Contains(t, dot, `"API" [label="API", entityType="service"];`)
+	assert.Contains(t, dot, `"Outage 42" -> "API" [label="affected"];`)
+}
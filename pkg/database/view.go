@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Formats of ExportView
+const (
+	ViewDOT     = "dot"
+	ViewGraphML = "graphml"
+)
+
+// ViewOptions keep a graph exported for viewing small enough to look at
+type ViewOptions struct {
+	// Root, when set, limits the export to the entities within Depth relations of
+	// it, followed either way
+	Root  string
+	Depth int
+	// MaxNodes, when positive, fails exports of more entities with a
+	// *TooManyNodesError
+	MaxNodes int
+}
+
+// ErrRootNotFound is returned by ExportView when ViewOptions.Root doesn't exist
+var ErrRootNotFound = errors.New("root entity not found")
+
+// TooManyNodesError reports an export of more entities than ViewOptions.MaxNodes
+type TooManyNodesError struct {
+	Max int
+}
+
+func (e *TooManyNodesError) Error() string {
+	return fmt.Sprintf("more than %d entities to export: pick a root entity and depth, or raise maxNodes", e.Max)
+}
+
+// ExportView writes the graph, or the part opts select, in format, ViewDOT or
+// ViewGraphML, for graph viewers. Entity types and their metadata become node
+// attributes and relation types edge labels.
+func (db *DB) ExportView(ctx context.Context, w io.Writer, format string, opts ViewOptions) error {
+	var write func(io.Writer, *KnowledgeGraph, TypeMetadata) error
+	switch format {
+	case ViewDOT:
+		write = WriteDOT
+	case ViewGraphML:
+		write = WriteGraphML
+	default:
+		return fmt.Errorf("invalid view format %q: must be %q or %q", format, ViewDOT, ViewGraphML)
+	}
+
+	graph, err := db.viewGraph(ctx, opts)
+	if err != nil {
+		return err
+	}
+	meta, err := db.GetTypeMetadata(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return write(w, graph, meta)
+}
+
+// viewGraph returns the entities and relations opts select. The size is checked
+// before the graph is read, so an oversized export fails cheaply.
+func (db *DB) viewGraph(ctx context.Context, opts ViewOptions) (*KnowledgeGraph, error) {
+	if opts.Root == "" {
+		if opts.MaxNodes > 0 {
+			var n int
+			if err := db.reader.QueryRowContext(ctx, "SELECT COUNT(*) FROM entities").Scan(&n); err != nil {
+				return nil, err
+			}
+			if n > opts.MaxNodes {
+				return nil, &TooManyNodesError{Max: opts.MaxNodes}
+			}
+		}
+		return db.readGraph(ctx, 0)
+	}
+
+	// One node beyond the cap tells an oversized neighborhood from one that fits
+	maxNodes := opts.MaxNodes + 1
+	if opts.MaxNodes <= 0 {
+		maxNodes = int(^uint(0) >> 1)
+	}
+	neighborhood, err := db.GetNeighbors(ctx, []string{opts.Root}, opts.Depth, NeighborsBoth, maxNodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(neighborhood.NotFound) > 0 {
+		return nil, fmt.Errorf("%w: %q", ErrRootNotFound, opts.Root)
+	}
+	if opts.MaxNodes > 0 && len(neighborhood.Entities) > opts.MaxNodes {
+		return nil, &TooManyNodesError{Max: opts.MaxNodes}
+	}
+	return &neighborhood.KnowledgeGraph, nil
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dotGraph is what parseDOT reads from a digraph
+type dotGraph struct {
+	nodes map[string]map[string]string
+	edges [][3]string // from, to, label
+}
+
+// parseDOT parses the subset of DOT that WriteDOT writes: a digraph of node and edge
+// statements with attribute lists. IDs are returned unescaped.
+func parseDOT(dot string) (*dotGraph, error) {
+	tokens, err := dotTokens(dot)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	next := func() string {
+		if pos == len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	expect := func(want string) error {
+		if got := next(); got != want {
+			return fmt.Errorf("token %d: expected %q, found %q", pos, want, got)
+		}
+		return nil
+	}
+
+	if err := expect("digraph"); err != nil {
+		return nil, err
+	}
+	next() // graph name
+	if err := expect("{"); err != nil {
+		return nil, err
+	}
+	g := &dotGraph{nodes: map[string]map[string]string{}}
+	for {
+		id := next()
+		if id == "}" {
+			break
+		}
+		if id == "" {
+			return nil, errors.New("unterminated graph")
+		}
+		to := ""
+		if tokens[pos] == "->" {
+			next()
+			to = next()
+		}
+		attrs := map[string]string{}
+		if err := expect("["); err != nil {
+			return nil, err
+		}
+		for {
+			key := next()
+			if err := expect("="); err != nil {
+				return nil, err
+			}
+			attrs[key] = next()
+			if sep := next(); sep == "]" {
+				break
+			} else if sep != "," {
+				return nil, fmt.Errorf("token %d: expected , or ], found %q", pos, sep)
+			}
+		}
+		if err := expect(";"); err != nil {
+			return nil, err
+		}
+		if to == "" {
+			g.nodes[id] = attrs
+		} else {
+			g.edges = append(g.edges, [3]string{id, to, attrs["label"]})
+		}
+	}
+	if pos != len(tokens) {
+		return nil, errors.New("tokens after the graph")
+	}
+	return g, nil
+}
+
+// dotTokens splits dot into punctuation, plain IDs and quoted IDs, unescaping the
+// quoted ones
+func dotTokens(dot string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(dot); {
+		c := dot[i]
+		switch {
+		case c == ' ' || c == '\n' || c == '\t':
+			i++
+		case strings.HasPrefix(dot[i:], "->"):
+			tokens = append(tokens, "->")
+			i += 2
+		case strings.ContainsRune("{}[]=,;", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(dot) {
+					return nil, errors.New("unterminated string")
+				}
+				if dot[i] == '"' {
+					i++
+					break
+				}
+				if dot[i] == '\n' {
+					return nil, errors.New("raw newline in string")
+				}
+				if dot[i] == '\\' && i+1 < len(dot) {
+					i++
+					switch dot[i] {
+					case 'n':
+						b.WriteByte('\n')
+					default:
+						b.WriteByte(dot[i])
+					}
+					i++
+					continue
+				}
+				b.WriteByte(dot[i])
+				i++
+			}
+			tokens = append(tokens, b.String())
+		default:
+			start := i
+			for i < len(dot) && (dot[i] == '_' || dot[i] >= 'a' && dot[i] <= 'z' || dot[i] >= 'A' && dot[i] <= 'Z' || dot[i] >= '0' && dot[i] <= '9') {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, dot[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// viewTestDB returns a database with a chain A -> B -> C -> D and entities whose
+// names need escaping
+func viewTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "A", EntityType: "step"},
+		{Name: "B", EntityType: "step"},
+		{Name: "C", EntityType: "step"},
+		{Name: "D", EntityType: "step"},
+		{Name: `Say "hi" \ <now> & 'go'`, EntityType: "odd name"},
+		{Name: "line one\nline two", EntityType: "odd name"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "A", To: "B", RelationType: "next"},
+		{From: "B", To: "C", RelationType: "next"},
+		{From: "C", To: "D", RelationType: "next"},
+		{From: `Say "hi" \ <now> & 'go'`, To: "line one\nline two", RelationType: `is "before"`},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, db.SetTypeMetadata(ctx, "step", map[string]string{"color": "blue"}))
+	return db
+}
+
+func TestExportView_DOT(t *testing.T) {
+	db := viewTestDB(t)
+	var buf bytes.Buffer
+	assert.NoError(t, db.ExportView(context.Background(), &buf, ViewDOT, ViewOptions{}))
+
+	g, err := parseDOT(buf.String())
+	if !assert.NoError(t, err, buf.String()) {
+		return
+	}
+	assert.Len(t, g.nodes, 6)
+	assert.Equal(t, map[string]string{"label": "A", "entityType": "step", "color": "blue"}, g.nodes["A"])
+	odd := `Say "hi" \ <now> & 'go'`
+	assert.Equal(t, odd, g.nodes[odd]["label"], "quotes and backslashes are escaped")
+	assert.Equal(t, "odd name", g.nodes[odd]["entityType"])
+	assert.Contains(t, g.nodes, "line one\nline two", "newlines are escaped")
+	assert.Contains(t, g.edges, [3]string{"A", "B", "next"})
+	assert.Contains(t, g.edges, [3]string{odd, "line one\nline two", `is "before"`})
+}
+
+// graphML is what the test reads back from WriteGraphML
+type graphML struct {
+	Keys []struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+	} `xml:"key"`
+	Graph struct {
+		EdgeDefault string `xml:"edgedefault,attr"`
+		Nodes       []struct {
+			ID   string        `xml:"id,attr"`
+			Data []graphMLData `xml:"data"`
+		} `xml:"node"`
+		Edges []struct {
+			Source string        `xml:"source,attr"`
+			Target string        `xml:"target,attr"`
+			Data   []graphMLData `xml:"data"`
+		} `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func TestExportView_GraphML(t *testing.T) {
+	db := viewTestDB(t)
+	var buf bytes.Buffer
+	assert.NoError(t, db.ExportView(context.Background(), &buf, ViewGraphML, ViewOptions{}))
+
+	var doc graphML
+	if !assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc), buf.String()) {
+		return
+	}
+	assert.Equal(t, "directed", doc.Graph.EdgeDefault)
+	keyNames := map[string]string{}
+	for _, k := range doc.Keys {
+		keyNames[k.ID] = k.Name
+	}
+	data := func(items []graphMLData) map[string]string {
+		m := map[string]string{}
+		for _, d := range items {
+			m[keyNames[d.Key]] = d.Value
+		}
+		return m
+	}
+
+	nodes := map[string]map[string]string{}
+	for _, n := range doc.Graph.Nodes {
+		nodes[n.ID] = data(n.Data)
+	}
+	assert.Len(t, nodes, 6)
+	assert.Equal(t, map[string]string{"label": "A", "entityType": "step", "color": "blue"}, nodes["A"])
+	odd := `Say "hi" \ <now> & 'go'`
+	assert.Equal(t, map[string]string{"label": odd, "entityType": "odd name"}, nodes[odd], "markup characters are escaped")
+
+	var found bool
+	for _, e := range doc.Graph.Edges {
+		if e.Source == odd {
+			found = true
+			assert.Equal(t, "line one\nline two", e.Target)
+			assert.Equal(t, map[string]string{"label": `is "before"`, "relationType": `is "before"`}, data(e.Data))
+		}
+	}
+	assert.True(t, found)
+	assert.Len(t, doc.Graph.Edges, 4)
+}
+
+func TestExportView_Limits(t *testing.T) {
+	db := viewTestDB(t)
+	ctx := context.Background()
+
+	view := func(opts ViewOptions) (*dotGraph, error) {
+		var buf bytes.Buffer
+		if err := db.ExportView(ctx, &buf, ViewDOT, opts); err != nil {
+			return nil, err
+		}
+		return parseDOT(buf.String())
+	}
+
+	_, err := view(ViewOptions{MaxNodes: 5})
+	var tooMany *TooManyNodesError
+	assert.ErrorAs(t, err, &tooMany)
+	g, err := view(ViewOptions{MaxNodes: 6})
+	assert.NoError(t, err)
+	assert.Len(t, g.nodes, 6)
+
+	g, err = view(ViewOptions{Root: "B", Depth: 1})
+	assert.NoError(t, err)
+	assert.Len(t, g.nodes, 3, "B and the entities either side of it")
+	assert.Len(t, g.edges, 2)
+
+	g, err = view(ViewOptions{Root: "A", Depth: 2, MaxNodes: 3})
+	assert.NoError(t, err)
+	assert.Len(t, g.nodes, 3)
+	_, err = view(ViewOptions{Root: "A", Depth: 3, MaxNodes: 3})
+	assert.ErrorAs(t, err, &tooMany)
+
+	_, err = view(ViewOptions{Root: "Nobody", Depth: 1})
+	assert.ErrorIs(t, err, ErrRootNotFound)
+	assert.Error(t, db.ExportView(ctx, &bytes.Buffer{}, "svg", ViewOptions{}))
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+)
+
+// EntityTypeCount is an entity type and how many entities have it
+type EntityTypeCount struct {
+	EntityType string `json:"entityType"`
+	Count      int    `json:"count"`
+}
+
+// ListEntityTypes returns the entity types in use, most used first, optionally only
+// those starting with prefix (case-sensitive). It reads the entity type index
+// rather than the entities.
+func (db *DB) ListEntityTypes(ctx context.Context, prefix string) ([]EntityTypeCount, error) {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT entity_type, COUNT(*) FROM entities
+		WHERE substr(entity_type, 1, length(?1)) = ?1
+		GROUP BY entity_type
+		ORDER BY COUNT(*) DESC, entity_type`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []EntityTypeCount{}
+	for rows.Next() {
+		var t EntityTypeCount
+		if err := rows.Scan(&t.EntityType, &t.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// RelationTypeCount is a relation type and how many relations have it
+type RelationTypeCount struct {
+	RelationType string `json:"relationType"`
+	Count        int    `json:"count"`
+}
+
+// ListRelationTypes returns the relation types in use by at least minCount
+// relations, most used first. It reads the relation type index rather than the
+// relations.
+func (db *DB) ListRelationTypes(ctx context.Context, minCount int) ([]RelationTypeCount, error) {
+	rows, err := db.reader.QueryContext(ctx, `
+		SELECT relation_type, COUNT(*) FROM relations
+		GROUP BY relation_type
+		HAVING COUNT(*) >= ?
+		ORDER BY COUNT(*) DESC, relation_type`, minCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []RelationTypeCount{}
+	for rows.Next() {
+		var t RelationTypeCount
+		if err := rows.Scan(&t.RelationType, &t.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
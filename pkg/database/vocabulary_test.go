@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListEntityTypes(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Apollo", EntityType: "project"},
+		{Name: "Gemini", EntityType: "project"},
+		{Name: "Mercury", EntityType: "project_archived"},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Big Project", EntityType: "Project"},
+	})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		prefix string
+		want   []EntityTypeCount
+	}{
+		{"", []EntityTypeCount{
+			{"person", 2}, {"project", 2}, {"Project", 1}, {"company", 1}, {"project_archived", 1},
+		}},
+		{"project", []EntityTypeCount{{"project", 2}, {"project_archived", 1}}},
+		{"project_", []EntityTypeCount{{"project_archived", 1}}},
+		{"Proj", []EntityTypeCount{{"Project", 1}}},
+		{"%", []EntityTypeCount{}},
+		{"robot", []EntityTypeCount{}},
+	} {
+		types, err := db.ListEntityTypes(ctx, tc.prefix)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, types, "prefix %q", tc.prefix)
+	}
+}
+
+func TestListRelationTypes(t *testing.T) {
+	db := newImportTestDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "worksAt"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+		{From: "Bob", To: "Alice", RelationType: "knows"},
+		{From: "Acme", To: "Alice", RelationType: "employs"},
+	})
+	assert.NoError(t, err)
+
+	types, err := db.ListRelationTypes(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationTypeCount{{"knows", 2}, {"works_at", 2}, {"employs", 1}, {"worksAt", 1}}, types)
+
+	types, err = db.ListRelationTypes(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationTypeCount{{"knows", 2}, {"works_at", 2}}, types)
+
+	// Counts follow deletions, and unused types disappear
+	_, err = db.DeleteRelations(ctx, []RelationDTO{
+		{From: "Bob", To: "Acme", RelationType: "worksAt"},
+		{From: "Bob", To: "Alice", RelationType: "knows"},
+	})
+	assert.NoError(t, err)
+	_, err = db.DeleteEntities(ctx, []string{"Acme"})
+	assert.NoError(t, err)
+	types, err = db.ListRelationTypes(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationTypeCount{{"knows", 1}}, types)
+
+	types, err = db.ListRelationTypes(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []RelationTypeCount{}, types)
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package postgres
+
+// Registers DriverName
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// querier is what reads need of a *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// placeholders returns n numbered placeholders from $first, separated by commas
+func placeholders(first, n int) string {
+	marks := make([]string, n)
+	for i := range marks {
+		marks[i] = fmt.Sprintf("$%d", first+i)
+	}
+	return strings.Join(marks, ", ")
+}
+
+// entityID returns the id of the named entity, or 0 when there is none
+func entityID(ctx context.Context, q querier, name string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, "SELECT id FROM entities WHERE name = $1", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// touch sets the update time of the entities with ids to now
+func touch(ctx context.Context, q querier, ids ...int64) error {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := q.ExecContext(ctx, fmt.Sprintf("UPDATE entities SET updated_at = now() WHERE id IN (%s)", placeholders(1, len(ids))), args...)
+	return err
+}
+
+// readTx runs read in a read-only transaction, so that a graph is read from one
+// snapshot while other servers write
+func (db *DB) readTx(ctx context.Context, read func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := read(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadGraph returns the entities matching cond, a condition on entities e that
+// may use args, in name order with all their observations, and the relations among
+// them
+func loadGraph(ctx context.Context, q querier, cond string, args ...any) (*database.KnowledgeGraph, error) {
+	graph := &database.KnowledgeGraph{
+		Entities:  []database.EntityWithObservations{},
+		Relations: []database.RelationDTO{},
+	}
+	index := map[int64]int{}
+
+	rows, err := q.QueryContext(ctx, `SELECT e.id, e.name, e.entity_type FROM entities e
+		WHERE `+cond+` ORDER BY e.name COLLATE "C"`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		entity := database.EntityWithObservations{Observations: []string{}}
+		if err := rows.Scan(&id, &entity.Name, &entity.EntityType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[id] = len(graph.Entities)
+		graph.Entities = append(graph.Entities, entity)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(graph.Entities) == 0 {
+		return graph, nil
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT o.entity_id, o.content FROM observations o
+		JOIN entities e ON e.id = o.entity_id
+		WHERE `+cond+` ORDER BY o.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entity := &graph.Entities[index[id]]
+		entity.Observations = append(entity.Observations, content)
+		entity.TotalObservations++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT f.name, t.name, r.relation_type FROM relations r
+		JOIN entities f ON f.id = r.from_entity_id
+		JOIN entities t ON t.id = r.to_entity_id
+		WHERE r.from_entity_id IN (SELECT e.id FROM entities e WHERE `+cond+`)
+		AND r.to_entity_id IN (SELECT e.id FROM entities e WHERE `+cond+`)
+		ORDER BY f.name COLLATE "C", t.name COLLATE "C", r.relation_type COLLATE "C"`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rel database.RelationDTO
+		if err := rows.Scan(&rel.From, &rel.To, &rel.RelationType); err != nil {
+			return nil, err
+		}
+		graph.Relations = append(graph.Relations, rel)
+	}
+	return graph, rows.Err()
+}
+
+func (db *DB) ReadGraph(ctx context.Context) (*database.KnowledgeGraph, error) {
+	var graph *database.KnowledgeGraph
+	err := db.readTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		graph, err = loadGraph(ctx, tx, "TRUE")
+		return err
+	})
+	return graph, err
+}
+
+func (db *DB) OpenNodes(ctx context.Context, names []string) (*database.KnowledgeGraph, error) {
+	if len(names) == 0 {
+		return &database.KnowledgeGraph{Entities: []database.EntityWithObservations{}, Relations: []database.RelationDTO{}}, nil
+	}
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	var graph *database.KnowledgeGraph
+	err := db.readTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		graph, err = loadGraph(ctx, tx, "e.name IN ("+placeholders(1, len(names))+")", args...)
+		return err
+	})
+	return graph, err
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// relationTypeMatch is the condition that an entity s has a relation, to or from it,
+// whose type contains the ILIKE pattern in parameter %[1]d
+const relationTypeMatch = `EXISTS (SELECT 1 FROM relations r
+			WHERE (r.from_entity_id = s.id OR r.to_entity_id = s.id) AND r.relation_type ILIKE $%[1]d)`
+
+// SearchNodes matches every whitespace-separated term of query as a substring of
+// an entity's name, type, one of its observations or the type of one of its
+// relations, ignoring case. The terms may match different fields.
+func (db *DB) SearchNodes(ctx context.Context, query string, limit, offset int) (*database.SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		terms = []string{query}
+	}
+	conds := make([]string, len(terms))
+	args := make([]any, len(terms))
+	for i, term := range terms {
+		conds[i] = fmt.Sprintf(`(s.name ILIKE $%[1]d OR s.entity_type ILIKE $%[1]d
+			OR EXISTS (SELECT 1 FROM observations o WHERE o.entity_id = s.id AND o.content ILIKE $%[1]d)
+			OR `+relationTypeMatch+`)`, i+1)
+		args[i] = "%" + likeEscaper.Replace(term) + "%"
+	}
+	return db.search(ctx, strings.Join(conds, " AND "), args, limit, offset)
+}
+
+// SearchNodesFTS matches entities each word of whose query, in some form, is in their
+// name or type or one of their observations, using the tsvector columns, or is
+// contained in the type of one of their relations, as in SQLite's FTS search. The
+// words may match different fields. Stop words, which the index leaves out, are
+// ignored, and a query of only stop words matches nothing.
+func (db *DB) SearchNodesFTS(ctx context.Context, query string, limit, offset int) (*database.SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return db.search(ctx, "FALSE", nil, limit, offset)
+	}
+	conds := make([]string, len(terms)+1)
+	args := make([]any, 0, 2*len(terms)+1)
+	for i, term := range terms {
+		conds[i] = fmt.Sprintf(`(numnode(plainto_tsquery('english', $%[1]d)) = 0
+			OR s.search @@ plainto_tsquery('english', $%[1]d)
+			OR EXISTS (SELECT 1 FROM observations o WHERE o.entity_id = s.id AND o.search @@ plainto_tsquery('english', $%[1]d))
+			OR `+fmt.Sprintf(relationTypeMatch, 2*i+2)+`)`, 2*i+1)
+		args = append(args, term, "%"+likeEscaper.Replace(term)+"%")
+	}
+	conds[len(terms)] = fmt.Sprintf("numnode(plainto_tsquery('english', $%d)) > 0", len(args)+1)
+	args = append(args, query)
+	return db.search(ctx, strings.Join(conds, " AND "), args, limit, offset)
+}
+
+// search returns limit (0 = all) of the entities s matching cond, which may use args,
+// after skipping offset, in name order
+func (db *DB) search(ctx context.Context, cond string, args []any, limit, offset int) (*database.SearchResult, error) {
+	result := &database.SearchResult{Offset: offset}
+	err := db.readTx(ctx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM entities s WHERE "+cond, args...).Scan(&result.TotalMatches); err != nil {
+			return err
+		}
+		page := `e.id IN (SELECT s.id FROM entities s WHERE ` + cond + ` ORDER BY s.name COLLATE "C"`
+		if limit > 0 {
+			page += fmt.Sprintf(" LIMIT %d", limit)
+		}
+		page += fmt.Sprintf(" OFFSET %d)", offset)
+		graph, err := loadGraph(ctx, tx, page, args...)
+		if err != nil {
+			return err
+		}
+		result.KnowledgeGraph = *graph
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if next := offset + len(result.Entities); limit > 0 && next < result.TotalMatches {
+		result.NextOffset = &next
+	}
+	return result, nil
+}
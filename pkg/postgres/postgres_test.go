@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDB returns an empty store on the database at MEMORY_TEST_POSTGRES_DSN, which
+// the test may empty, skipping the test when it is unset
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	dsn := os.Getenv("MEMORY_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("MEMORY_TEST_POSTGRES_DSN is not set")
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := NewDB(context.Background(), dsn, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Truncate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPlaceholders(t *testing.T) {
+	assert.Equal(t, "$1", placeholders(1, 1))
+	assert.Equal(t, "$2, $3, $4", placeholders(2, 3))
+}
+
+func TestNewDB_WithoutDriver(t *testing.T) {
+	if slices.Contains(sql.Drivers(), DriverName) {
+		t.Skip("the driver is built in")
+	}
+	_, err := NewDB(context.Background(), "postgres://localhost/memory", nil)
+	assert.ErrorContains(t, err, "-tags postgres")
+}
+
+func TestMigrate_Idempotent(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	assert.NoError(t, db.migrate(ctx))
+	var versions int
+	assert.NoError(t, db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&versions))
+	assert.Equal(t, len(schemaMigrations)-1, versions)
+}
+
+func TestSearchNodesFTS(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"Went hiking in the Alps"}},
+		{Name: "Gateway", EntityType: "service", Observations: []string{"written in Go"}},
+	})
+	assert.NoError(t, err)
+
+	result, err := db.SearchNodesFTS(ctx, "HIKES alps", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TotalMatches)
+	if assert.Len(t, result.Entities, 1) {
+		assert.Equal(t, "Alice", result.Entities[0].Name)
+	}
+	result, err = db.SearchNodesFTS(ctx, "services", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TotalMatches, "entity types are searched, stemmed")
+	result, err = db.SearchNodesFTS(ctx, "hiking service", 0, 0)
+	assert.NoError(t, err)
+	assert.Zero(t, result.TotalMatches)
+	result, err = db.SearchNodesFTS(ctx, "the", 0, 0)
+	assert.NoError(t, err)
+	assert.Zero(t, result.TotalMatches, "a query of only stop words matches nothing")
+
+	_, err = db.CreateRelations(ctx, []database.RelationDTO{{From: "Alice", To: "Gateway", RelationType: "maintains"}})
+	assert.NoError(t, err)
+	result, err = db.SearchNodesFTS(ctx, "alice alps", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TotalMatches, "words may match different fields")
+	result, err = db.SearchNodesFTS(ctx, "maintains service", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Entities, 1) {
+		assert.Equal(t, "Gateway", result.Entities[0].Name, "relation types are searched")
+	}
+	result, err = db.SearchNodes(ctx, "maintains go", 0, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Entities, 1) {
+		assert.Equal(t, "Gateway", result.Entities[0].Name)
+	}
+}
+
+func TestLongObservations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	long := strings.Repeat("a long observation ", 600)
+	_, err := db.CreateEntities(ctx, []database.EntityWithObservations{{Name: "Alice", EntityType: "person"}})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		added, err := db.AddObservations(ctx, []database.ObservationAdditionInput{{EntityName: "Alice", Contents: []string{long}}})
+		assert.NoError(t, err)
+		assert.Len(t, added[0].AddedObservations, 1-i, "the second add is a duplicate")
+	}
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// writeTx runs write in a transaction, committing when it succeeds
+func (db *DB) writeTx(ctx context.Context, write func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := write(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addObservations stores the contents the entity with id doesn't have yet and
+// returns them, along with those it had before the call
+func addObservations(ctx context.Context, tx *sql.Tx, id int64, contents []string) ([]string, []string, error) {
+	added, existed := []string{}, []string{}
+	seen := map[string]bool{}
+	for _, content := range contents {
+		if seen[content] {
+			continue
+		}
+		seen[content] = true
+		var observationID int64
+		err := tx.QueryRowContext(ctx, `INSERT INTO observations (entity_id, content) VALUES ($1, $2)
+			ON CONFLICT (entity_id, md5(content)) DO NOTHING RETURNING id`, id, content).Scan(&observationID)
+		if err == sql.ErrNoRows {
+			existed = append(existed, content)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		added = append(added, content)
+	}
+	return added, existed, nil
+}
+
+func (db *DB) CreateEntities(ctx context.Context, entities []database.EntityWithObservations) ([]database.EntityWithObservations, error) {
+	created := []database.EntityWithObservations{}
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		created = created[:0]
+		for _, entity := range entities {
+			var id int64
+			err := tx.QueryRowContext(ctx, `INSERT INTO entities (name, entity_type) VALUES ($1, $2)
+				ON CONFLICT (name) DO NOTHING RETURNING id`, entity.Name, entity.EntityType).Scan(&id)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create entity %s: %w", entity.Name, err)
+			}
+			if _, _, err := addObservations(ctx, tx, id, entity.Observations); err != nil {
+				return fmt.Errorf("failed to add observations to %s: %w", entity.Name, err)
+			}
+			created = append(created, entity)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (db *DB) CreateRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationCreationResult, error) {
+	var result *database.RelationCreationResult
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		result = database.NewRelationCreationResult()
+		for i, rel := range relations {
+			var from, to int64
+			err := tx.QueryRowContext(ctx, `INSERT INTO relations (from_entity_id, to_entity_id, relation_type)
+				SELECT f.id, t.id, $3 FROM entities f, entities t WHERE f.name = $1 AND t.name = $2
+				ON CONFLICT DO NOTHING RETURNING from_entity_id, to_entity_id`,
+				rel.From, rel.To, rel.RelationType,
+			).Scan(&from, &to)
+			if err == sql.ErrNoRows {
+				// Nothing was inserted: find out which endpoint is missing, if either
+				if from, err = entityID(ctx, tx, rel.From); err != nil {
+					return err
+				}
+				if to, err = entityID(ctx, tx, rel.To); err != nil {
+					return err
+				}
+				reason := database.MissingEndpoint(from != 0, to != 0)
+				if reason == "" {
+					reason = database.SkipDuplicate
+				}
+				result.Skip(i, rel, reason)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create relation: %w", err)
+			}
+			if err := touch(ctx, tx, from, to); err != nil {
+				return err
+			}
+			result.Relations = append(result.Relations, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (db *DB) AddObservations(ctx context.Context, observations []database.ObservationAdditionInput) ([]database.ObservationAdditionResult, error) {
+	results := []database.ObservationAdditionResult{}
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		results = results[:0]
+		for _, obs := range observations {
+			id, err := entityID(ctx, tx, obs.EntityName)
+			if err != nil {
+				return err
+			}
+			if id == 0 {
+				return &database.EntityNotFoundError{Name: obs.EntityName}
+			}
+			added, existed, err := addObservations(ctx, tx, id, obs.Contents)
+			if err != nil {
+				return fmt.Errorf("failed to add observations to %s: %w", obs.EntityName, err)
+			}
+			if len(added) > 0 {
+				if err := touch(ctx, tx, id); err != nil {
+					return err
+				}
+			}
+			results = append(results, database.ObservationAdditionResult{
+				EntityName:          obs.EntityName,
+				AddedObservations:   added,
+				SkippedObservations: existed,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (db *DB) DeleteEntities(ctx context.Context, names []string) (*database.EntityDeletionResult, error) {
+	deleted := map[string]bool{}
+	if len(names) == 0 {
+		return database.NewEntityDeletionResult(nil, deleted), nil
+	}
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		// The other ends of deleted relations lose them
+		if _, err := tx.ExecContext(ctx, `UPDATE entities SET updated_at = now() WHERE id IN (
+			SELECT r.to_entity_id FROM relations r JOIN entities e ON e.id = r.from_entity_id WHERE e.name IN (`+placeholders(1, len(names))+`)
+			UNION SELECT r.from_entity_id FROM relations r JOIN entities e ON e.id = r.to_entity_id WHERE e.name IN (`+placeholders(1, len(names))+`)
+		)`, args...); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, "DELETE FROM entities WHERE name IN ("+placeholders(1, len(names))+") RETURNING name", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			deleted[name] = true
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return database.NewEntityDeletionResult(names, deleted), nil
+}
+
+func (db *DB) DeleteObservations(ctx context.Context, deletions []database.ObservationDeletionInput) (*database.ObservationDeletionResult, error) {
+	var result *database.ObservationDeletionResult
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		result = database.NewObservationDeletionResult()
+		seen := map[[2]string]bool{}
+		for _, del := range deletions {
+			id, err := entityID(ctx, tx, del.EntityName)
+			if err != nil {
+				return err
+			}
+			if id == 0 {
+				if !slices.Contains(result.NotFound, del.EntityName) {
+					result.NotFound = append(result.NotFound, del.EntityName)
+				}
+				continue
+			}
+			if len(del.Observations) == 0 {
+				continue
+			}
+			args := []any{id}
+			for _, content := range del.Observations {
+				args = append(args, content)
+			}
+			rows, err := tx.QueryContext(ctx, "DELETE FROM observations WHERE entity_id = $1 AND content IN ("+placeholders(2, len(del.Observations))+") RETURNING content", args...)
+			if err != nil {
+				return fmt.Errorf("failed to delete observations of %s: %w", del.EntityName, err)
+			}
+			deleted := map[string]bool{}
+			for rows.Next() {
+				var content string
+				if err := rows.Scan(&content); err != nil {
+					rows.Close()
+					return err
+				}
+				deleted[content] = true
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			result.DeletedObservations += len(deleted)
+			for _, content := range del.Observations {
+				key := [2]string{del.EntityName, content}
+				if !seen[key] && !deleted[content] {
+					result.AddMissing(del.EntityName, content)
+				}
+				seen[key] = true
+			}
+			if len(deleted) > 0 {
+				if err := touch(ctx, tx, id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (db *DB) DeleteRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationDeletionResult, error) {
+	var result *database.RelationDeletionResult
+	err := db.writeTx(ctx, func(tx *sql.Tx) error {
+		result = database.NewRelationDeletionResult()
+		seen := map[database.RelationDTO]bool{}
+		for _, rel := range relations {
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			var from, to int64
+			err := tx.QueryRowContext(ctx, `DELETE FROM relations r USING entities f, entities t
+				WHERE r.from_entity_id = f.id AND r.to_entity_id = t.id
+				AND f.name = $1 AND t.name = $2 AND r.relation_type = $3
+				RETURNING r.from_entity_id, r.to_entity_id`,
+				rel.From, rel.To, rel.RelationType,
+			).Scan(&from, &to)
+			if err == sql.ErrNoRows {
+				result.NotFound = append(result.NotFound, rel)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to delete relation: %w", err)
+			}
+			result.DeletedRelations++
+			if err := touch(ctx, tx, from, to); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// OPENAPI is the path of the generated OpenAPI document
+const OPENAPI = "/openapi.json"
+
+// bearerAuth is the security scheme name of authenticated operations
+const bearerAuth = "bearerAuth"
+
+// operation documents one method of a mounted route
+type operation struct {
+	method  string
+	summary string
+	// auth marks operations behind requireToken
+	auth  bool
+	query []queryParam
+	// request is the request body, if any
+	request   *content
+	responses []response
+}
+
+type response struct {
+	status      int
+	description string
+	// body is nil for responses without one
+	body *content
+}
+
+type queryParam struct {
+	name        string
+	schema      *jsonschema.Schema
+	description string
+}
+
+// content is a body of the given media type. For JSON bodies, body is a value of the
+// Go type that is encoded, from which the schema is inferred; other bodies are strings.
+type content struct {
+	mediaType string
+	body      any
+}
+
+func jsonContent(body any) *content {
+	return &content{mediaType: "application/json", body: body}
+}
+
+func textContent(mediaType string) *content {
+	return &content{mediaType: mediaType}
+}
+
+// plainError is the text/plain body written by http.Error
+var plainError = textContent("text/plain; charset=utf-8")
+
+type route struct {
+	path string
+	ops  []operation
+}
+
+// routeRegistry mounts handlers and records the metadata the OpenAPI document is
+// generated from. Every route must be mounted through it with at least one operation.
+type routeRegistry struct {
+	mux    *http.ServeMux
+	routes []route
+}
+
+func newRouteRegistry() *routeRegistry {
+	return &routeRegistry{mux: http.NewServeMux()}
+}
+
+func (r *routeRegistry) handle(path string, handler http.Handler, ops ...operation) {
+	r.mux.Handle(path, handler)
+	r.routes = append(r.routes, route{path: path, ops: ops})
+}
+
+// openAPIDocument builds an OpenAPI 3.1 document describing the registered routes
+func (r *routeRegistry) openAPIDocument(title, version string) (map[string]any, error) {
+	paths := map[string]any{}
+	usesAuth := false
+	for _, rt := range r.routes {
+		if len(rt.ops) == 0 {
+			return nil, fmt.Errorf("route %s has no OpenAPI metadata", rt.path)
+		}
+		item := map[string]any{}
+		for _, op := range rt.ops {
+			doc, err := op.document()
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.method, rt.path, err)
+			}
+			item[strings.ToLower(op.method)] = doc
+			usesAuth = usesAuth || op.auth
+		}
+		paths[rt.path] = item
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+	}
+	if usesAuth {
+		doc["components"] = map[string]any{
+			"securitySchemes": map[string]any{
+				bearerAuth: map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		}
+	}
+	return doc, nil
+}
+
+func (op operation) document() (map[string]any, error) {
+	doc := map[string]any{"summary": op.summary}
+	if op.auth {
+		doc["security"] = []map[string][]string{{bearerAuth: {}}}
+	}
+	if len(op.query) > 0 {
+		params := make([]map[string]any, len(op.query))
+		for i, p := range op.query {
+			params[i] = map[string]any{"name": p.name, "in": "query", "schema": p.schema, "description": p.description}
+		}
+		doc["parameters"] = params
+	}
+	if op.request != nil {
+		body, err := op.request.document()
+		if err != nil {
+			return nil, err
+		}
+		doc["requestBody"] = map[string]any{"required": true, "content": body}
+	}
+
+	responses := map[string]any{}
+	for _, r := range op.responses {
+		resp := map[string]any{"description": r.description}
+		if r.body != nil {
+			body, err := r.body.document()
+			if err != nil {
+				return nil, err
+			}
+			resp["content"] = body
+		}
+		responses[strconv.Itoa(r.status)] = resp
+	}
+	doc["responses"] = responses
+	return doc, nil
+}
+
+func (c *content) document() (map[string]any, error) {
+	schema := &jsonschema.Schema{Type: "string"}
+	if c.body != nil {
+		var err error
+		if schema, err = jsonschema.ForType(reflect.TypeOf(c.body), &jsonschema.ForOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]any{c.mediaType: map[string]any{"schema": schema}}, nil
+}
+
+// serveOpenAPI serves the document generated from routes. It is built per request,
+// so it always matches the mounted routes.
+func serveOpenAPI(routes *routeRegistry, title, version string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		doc, err := routes.openAPIDocument(title, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(doc)
+	})
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// PPROF is the path under which the net/http/pprof handlers are mounted
+const PPROF = "/debug/pprof/"
+
+// pprofHandler serves the net/http/pprof endpoints below basePath. The pprof index
+// resolves named profiles against the fixed /debug/pprof/ prefix, so the base path
+// is stripped before the request reaches it.
+func pprofHandler(basePath string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PPROF, pprof.Index)
+	mux.HandleFunc(PPROF+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PPROF+"profile", pprof.Profile)
+	mux.HandleFunc(PPROF+"symbol", pprof.Symbol)
+	mux.HandleFunc(PPROF+"trace", pprof.Trace)
+	if prefix := strings.TrimRight(basePath, "/"); prefix != "" {
+		return http.StripPrefix(prefix, mux)
+	}
+	return mux
+}
+
+// RuntimeStats is a summary of the Go runtime for the status endpoint
+type RuntimeStats struct {
+	Goroutines int `json:"goroutines"`
+	// HeapAllocBytes is the size of live and not yet collected heap objects
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	HeapSysBytes   uint64 `json:"heapSysBytes"`
+	HeapObjects    uint64 `json:"heapObjects"`
+	NumGC          uint32 `json:"numGC"`
+	// GCPauseTotal is the cumulative stop-the-world time of all collections
+	GCPauseTotal time.Duration `json:"gcPauseTotalNs"`
+	// LastGCPause is the pause of the most recent collection, and LastGC when it
+	// ended (both zero before the first one)
+	LastGCPause time.Duration `json:"lastGCPauseNs"`
+	LastGC      *time.Time    `json:"lastGC,omitempty"`
+}
+
+// ReadRuntimeStats samples the runtime. It briefly stops the world, like any
+// runtime.ReadMemStats call, so it suits status requests rather than hot paths.
+func ReadRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := RuntimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: m.HeapAlloc,
+		HeapSysBytes:   m.HeapSys,
+		HeapObjects:    m.HeapObjects,
+		NumGC:          m.NumGC,
+		GCPauseTotal:   time.Duration(m.PauseTotalNs),
+	}
+	if m.NumGC > 0 {
+		stats.LastGCPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+		last := time.Unix(0, int64(m.LastGC)).UTC()
+		stats.LastGC = &last
+	}
+	return stats
+}
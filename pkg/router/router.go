@@ -1,20 +1,32 @@
 package router
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
-	HEALTH = "/healthz"
-	READY  = "/readyz"
-	HTTP   = "/mcp/stream"
-	SSE    = "/mcp/sse"
+	HEALTH  = "/healthz"
+	READY   = "/readyz"
+	STATUS  = "/status"
+	COMPARE = "/compare"
+	DOT     = "/export.dot"
+	JSONL   = "/export.jsonl"
+	HTTP    = "/mcp/stream"
+	SSE     = "/mcp/sse"
 )
 
 // RouterConfig configures the HTTP router that wraps MCP handlers.
@@ -25,23 +37,99 @@ type RouterConfig struct {
 	StreamOptions *mcp.StreamableHTTPOptions
 	// EnableSSE registers the SSE endpoint at <BasePath>/mcp/sse.
 	EnableSSE bool
+	// SSEContext, if set, derives the context of each SSE request. The MCP session a
+	// GET opens, and every call it serves, inherit the GET's context.
+	SSEContext func(ctx context.Context) context.Context
 	// EnableStream registers the streamable HTTP endpoint at <BasePath>/mcp/stream.
 	EnableStream bool
 	McpName      string
 	McpVersion   string
+	// Ready, if set, is called by <BasePath>/readyz, which fails with 503 Service
+	// Unavailable when it returns an error. It should be cheap.
+	Ready func(ctx context.Context) error
+	// Status, if set, serves its result as JSON at <BasePath>/status.
+	Status func(ctx context.Context) (any, error)
+	// ReadOnly is reported in the root info as "readOnly": the MCP endpoints only
+	// serve tools that don't change the graph.
+	ReadOnly bool
+	// Capabilities, if set, is included in the root info as "capabilities".
+	Capabilities func(ctx context.Context) any
+	// Stats, if set, is included in the root info as "stats"; it is left out when
+	// Stats fails.
+	Stats func(ctx context.Context) (any, error)
+	// APIToken is the bearer token authenticated endpoints require. They are not
+	// registered when it is empty.
+	APIToken string
+	// Compare, if set, serves POST <BasePath>/compare: it compares the JSONL snapshot
+	// in the request body with the database and returns the verdict as JSON.
+	// Errors wrapping ErrInvalidSnapshot are reported as a bad request.
+	Compare func(ctx context.Context, snapshot io.Reader, opts CompareOptions) (any, error)
+	// MaxSnapshotBytes bounds the compare request body (0 = DefaultMaxSnapshotBytes).
+	MaxSnapshotBytes int64
+	// CompareResponse is a value of the type Compare returns, used to describe the
+	// response in the OpenAPI document (optional).
+	CompareResponse any
+	// ExportDOT, if set, serves GET <BasePath>/export.dot: the graph in Graphviz DOT
+	// format, written by ExportDOT. Requires APIToken.
+	ExportDOT func(ctx context.Context, w io.Writer) error
+	// ExportJSONL, if set, serves GET <BasePath>/export.jsonl: the default graph as
+	// versioned JSONL records, written by ExportJSONL. Requires APIToken.
+	ExportJSONL func(ctx context.Context, w io.Writer) error
+	// EnablePprof mounts the net/http/pprof handlers at <BasePath>/debug/pprof/.
+	// Requires APIToken.
+	EnablePprof bool
+	// Namespace, if set, derives the namespace of each MCP request, such as from a
+	// header (see HeaderNamespace) or the authenticated principal, and the router
+	// stores it in the request context with logging.WithNamespace. An MCP session
+	// keeps the namespace of the request that opened it. Requests it returns ""
+	// for have none.
+	Namespace func(r *http.Request) string
+}
+
+// NamespaceHeader is the header HeaderNamespace is usually given
+const NamespaceHeader = "X-Memory-Namespace"
+
+// HeaderNamespace returns a RouterConfig.Namespace that takes the namespace from
+// header
+func HeaderNamespace(header string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(header))
+	}
+}
+
+// DefaultMaxSnapshotBytes is the default limit on a compare request body
+const DefaultMaxSnapshotBytes = 64 << 20
+
+// ErrInvalidSnapshot marks a compare failure caused by the uploaded snapshot
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// CompareOptions are the assertions of a compare request, given as query parameters:
+// failOnMissingEntities and ignoreObservationOrder are booleans, ignoreEntityTypes
+// is comma-separated or repeated.
+type CompareOptions struct {
+	FailOnMissingEntities  bool
+	IgnoreObservationOrder bool
+	IgnoreEntityTypes      []string
 }
 
 // NewRouter returns an http.Handler that mounts health, info, and MCP endpoints.
 //
 // Endpoints (relative to cfg.BasePath):
 //
-//	GET  /                 - basic info and available endpoints
+//	GET  /                 - basic info, available endpoints, capabilities and stats (if Capabilities and Stats are set)
 //	GET  /healthz          - liveness probe ("ok")
-//	GET  /readyz           - readiness probe ("ok")
+//	GET  /readyz           - readiness probe ("ok", or 503 when Ready fails)
+//	GET  /status           - server status as JSON (if Status is set)
+//	POST /compare          - compare a JSONL snapshot with the database (if Compare and APIToken are set)
+//	GET  /export.dot       - the graph in Graphviz DOT format (if ExportDOT and APIToken are set)
+//	GET  /export.jsonl     - the default graph as versioned JSONL records (if ExportJSONL and APIToken are set)
+//	GET  /debug/pprof/     - Go runtime profiles (if EnablePprof and APIToken are set)
 //	GET  /mcp/sse          - MCP over Server-Sent Events (if EnableSSE)
 //	POST /mcp/stream       - MCP streamable HTTP (if EnableStream)
+//	GET  /openapi.json     - OpenAPI description of the mounted endpoints
 //
-// The MCP endpoints are provided by github.com/modelcontextprotocol/go-sdk/mcp.
+// Every route is mounted with metadata describing its operations, from which the
+// OpenAPI document is generated. The MCP endpoints are provided by github.com/modelcontextprotocol/go-sdk/mcp.
 func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) http.Handler {
 	if logger == nil {
 		logger = slog.Default()
@@ -50,7 +138,7 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 		cfg = &RouterConfig{EnableStream: true}
 	}
 
-	mux := http.NewServeMux()
+	routes := newRouteRegistry()
 
 	// Utility to join base and path cleanly.
 	join := func(base, path string) string {
@@ -63,7 +151,12 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 	}
 
 	// Health endpoints
-	mux.Handle(join(cfg.BasePath, HEALTH), requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	probe := func(summary string) operation {
+		return operation{method: http.MethodGet, summary: summary, responses: []response{
+			{status: http.StatusOK, description: `"ok"`, body: textContent("text/plain; charset=utf-8")},
+		}}
+	}
+	routes.handle(join(cfg.BasePath, HEALTH), requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 			return
@@ -71,21 +164,146 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
-	})))
-	mux.Handle(join(cfg.BasePath, READY), requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	})), probe("Liveness probe"))
+	readyProbe := probe("Readiness probe")
+	if cfg.Ready != nil {
+		readyProbe.responses = append(readyProbe.responses, response{status: http.StatusServiceUnavailable, description: "Not ready", body: plainError})
+	}
+	routes.handle(join(cfg.BasePath, READY), requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 			return
 		}
+		if cfg.Ready != nil {
+			if err := cfg.Ready(r.Context()); err != nil {
+				logger.Warn("readiness check failed", slog.String("error", err.Error()))
+				http.Error(w, "not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
-	})))
+	})), readyProbe)
+
+	// Status endpoint
+	if cfg.Status != nil {
+		routes.handle(join(cfg.BasePath, STATUS), requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			status, err := cfg.Status(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(status)
+		})), operation{method: http.MethodGet, summary: "Server status", responses: []response{
+			{status: http.StatusOK, description: "Status as JSON", body: jsonContent(map[string]any{})},
+			{status: http.StatusInternalServerError, description: "Status unavailable", body: plainError},
+		}})
+	}
+
+	// Snapshot comparison endpoint
+	if cfg.Compare != nil && cfg.APIToken != "" {
+		maxBytes := cfg.MaxSnapshotBytes
+		if maxBytes <= 0 {
+			maxBytes = DefaultMaxSnapshotBytes
+		}
+		compareResponse := cfg.CompareResponse
+		if compareResponse == nil {
+			compareResponse = map[string]any{}
+		}
+		routes.handle(join(cfg.BasePath, COMPARE), requestLogger(logger, requireToken(cfg.APIToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			opts, err := parseCompareOptions(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result, err := cfg.Compare(r.Context(), http.MaxBytesReader(w, r.Body, maxBytes), opts)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				switch {
+				case errors.As(err, &tooLarge):
+					http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				case errors.Is(err, ErrInvalidSnapshot):
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				default:
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(result)
+		}))), operation{
+			method:  http.MethodPost,
+			summary: "Compare a JSONL graph snapshot with the database",
+			auth:    true,
+			query: []queryParam{
+				{name: "failOnMissingEntities", schema: &jsonschema.Schema{Type: "boolean"}, description: "Fail when snapshot entities are absent"},
+				{name: "ignoreObservationOrder", schema: &jsonschema.Schema{Type: "boolean"}, description: "Compare observations as sets"},
+				{name: "ignoreEntityTypes", schema: &jsonschema.Schema{Type: "string"}, description: "Comma-separated entity types to leave out"},
+			},
+			request: textContent("application/jsonl"),
+			responses: []response{
+				{status: http.StatusOK, description: "Verdict and diff", body: jsonContent(compareResponse)},
+				{status: http.StatusBadRequest, description: "Invalid snapshot or option", body: plainError},
+				{status: http.StatusUnauthorized, description: "Missing or wrong bearer token", body: plainError},
+				{status: http.StatusRequestEntityTooLarge, description: "Snapshot exceeds the size limit", body: plainError},
+			},
+		})
+	}
+
+	// Export endpoints
+	if cfg.ExportDOT != nil && cfg.APIToken != "" {
+		routes.handle(join(cfg.BasePath, DOT), requestLogger(logger, requireToken(cfg.APIToken, exportHandler(cfg.ExportDOT, dotContentType))), operation{
+			method:  http.MethodGet,
+			summary: "Export the graph in Graphviz DOT format, with entity type metadata as node attributes",
+			auth:    true,
+			responses: []response{
+				{status: http.StatusOK, description: "DOT digraph", body: textContent(dotContentType)},
+				{status: http.StatusUnauthorized, description: "Missing or wrong bearer token", body: plainError},
+				{status: http.StatusInternalServerError, description: "Export failed", body: plainError},
+			},
+		})
+	}
+	if cfg.ExportJSONL != nil && cfg.APIToken != "" {
+		routes.handle(join(cfg.BasePath, JSONL), requestLogger(logger, requireToken(cfg.APIToken, exportHandler(cfg.ExportJSONL, jsonlContentType))), operation{
+			method:  http.MethodGet,
+			summary: "Export the database as versioned JSONL records, including observation writers and entity type metadata",
+			auth:    true,
+			responses: []response{
+				{status: http.StatusOK, description: "One record per line", body: textContent(jsonlContentType)},
+				{status: http.StatusUnauthorized, description: "Missing or wrong bearer token", body: plainError},
+				{status: http.StatusInternalServerError, description: "Export failed", body: plainError},
+			},
+		})
+	}
+
+	// Profiling endpoints
+	if cfg.EnablePprof && cfg.APIToken != "" {
+		routes.handle(join(cfg.BasePath, PPROF), requestLogger(logger, requireToken(cfg.APIToken, pprofHandler(cfg.BasePath))), operation{
+			method:  http.MethodGet,
+			summary: "Go runtime profiles from net/http/pprof: the index here, each profile below it by name",
+			auth:    true,
+			responses: []response{
+				{status: http.StatusOK, description: "Profile index, or the requested profile", body: textContent("application/octet-stream")},
+				{status: http.StatusUnauthorized, description: "Missing or wrong bearer token", body: plainError},
+				{status: http.StatusNotFound, description: "Unknown profile", body: plainError},
+			},
+		})
+	}
 
 	// Root info endpoint: advertises available endpoints.
 	// Only respond to exact match of the root path, not as a catch-all
 	rootPath := join(cfg.BasePath, "/")
-	mux.Handle(rootPath, requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	routes.handle(rootPath, requestLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Only handle exact path match
 		if r.URL.Path != rootPath {
 			http.NotFound(w, r)
@@ -95,28 +313,47 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 			return
 		}
-		type endpoints struct {
-			Health string `json:"health"`
-			Ready  string `json:"ready"`
-			SSE    string `json:"sse,omitempty"`
-			Stream string `json:"stream,omitempty"`
-		}
-		info := struct {
-			Name      string    `json:"name"`
-			Version   string    `json:"version"`
-			Timestamp time.Time `json:"timestamp"`
-			Endpoints endpoints `json:"endpoints"`
-		}{
+		info := rootInfo{
 			Name:      cfg.McpName,
 			Version:   cfg.McpVersion,
 			Timestamp: time.Now().UTC(),
-			Endpoints: endpoints{
-				Health: join(cfg.BasePath, HEALTH),
-				Ready:  join(cfg.BasePath, READY),
-				SSE:    "",
-				Stream: "",
+			ReadOnly:  cfg.ReadOnly,
+			Endpoints: rootEndpoints{
+				Health:  join(cfg.BasePath, HEALTH),
+				Ready:   join(cfg.BasePath, READY),
+				OpenAPI: join(cfg.BasePath, OPENAPI),
+				SSE:     "",
+				Stream:  "",
 			},
 		}
+		if cfg.Status != nil {
+			info.Endpoints.Status = join(cfg.BasePath, STATUS)
+		}
+		if cfg.Compare != nil && cfg.APIToken != "" {
+			info.Endpoints.Compare = join(cfg.BasePath, COMPARE)
+		}
+		if cfg.ExportDOT != nil && cfg.APIToken != "" {
+			info.Endpoints.DOT = join(cfg.BasePath, DOT)
+		}
+		if cfg.ExportJSONL != nil && cfg.APIToken != "" {
+			info.Endpoints.JSONL = join(cfg.BasePath, JSONL)
+		}
+		if cfg.EnablePprof && cfg.APIToken != "" {
+			info.Endpoints.Pprof = join(cfg.BasePath, PPROF)
+		}
+		if cfg.Capabilities != nil {
+			info.Capabilities = cfg.Capabilities(r.Context())
+		}
+		if cfg.Stats != nil {
+			stats, err := cfg.Stats(r.Context())
+			if err != nil {
+				logger.Warn("failed to read stats for root info",
+					slog.String("error", err.Error()),
+				)
+			} else {
+				info.Stats = stats
+			}
+		}
 		if cfg.EnableSSE {
 			info.Endpoints.SSE = join(cfg.BasePath, SSE)
 		}
@@ -125,13 +362,29 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(info)
-	})))
+	})), operation{method: http.MethodGet, summary: "Server info, endpoints, capabilities and stats", responses: []response{
+		{status: http.StatusOK, description: "Server info", body: jsonContent(rootInfo{})},
+	}})
 
 	// MCP handlers (mounted under /mcp/...)
 	if cfg.EnableSSE {
 		// SSE handler provided by the MCP SDK.
-		sseHandler := mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return mcpServer })
-		mux.Handle(join(cfg.BasePath, SSE), requestLogger(logger, sseHandler))
+		var sseHandler http.Handler = mcp.NewSSEHandler(func(*http.Request) *mcp.Server { return mcpServer })
+		if cfg.SSEContext != nil {
+			sdkHandler := sseHandler
+			sseHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sdkHandler.ServeHTTP(w, r.WithContext(cfg.SSEContext(r.Context())))
+			})
+		}
+		sseHandler = withNamespace(cfg.Namespace, sseHandler)
+		routes.handle(join(cfg.BasePath, SSE), requestLogger(logger, sseHandler),
+			operation{method: http.MethodGet, summary: "MCP over Server-Sent Events", responses: []response{
+				{status: http.StatusOK, description: "Event stream", body: textContent("text/event-stream")},
+			}},
+			operation{method: http.MethodPost, summary: "Send a JSON-RPC message to an SSE session", responses: []response{
+				{status: http.StatusAccepted, description: "Message accepted; the reply arrives on the event stream"},
+			}},
+		)
 	}
 	if cfg.EnableStream {
 		// Streamable HTTP handler provided by the MCP SDK.
@@ -139,11 +392,129 @@ func NewRouter(mcpServer *mcp.Server, logger *slog.Logger, cfg *RouterConfig) ht
 			func(*http.Request) *mcp.Server { return mcpServer },
 			cfg.StreamOptions,
 		)
-		mux.Handle(join(cfg.BasePath, HTTP), requestLogger(logger, streamHandler))
+		routes.handle(join(cfg.BasePath, HTTP), requestLogger(logger, withNamespace(cfg.Namespace, streamHandler)),
+			operation{method: http.MethodPost, summary: "MCP streamable HTTP", request: jsonContent(map[string]any{}), responses: []response{
+				{status: http.StatusOK, description: "JSON-RPC response", body: jsonContent(map[string]any{})},
+				{status: http.StatusAccepted, description: "Notification or response accepted"},
+			}},
+			operation{method: http.MethodGet, summary: "Stream server-initiated messages for a session", responses: []response{
+				{status: http.StatusOK, description: "Event stream", body: textContent("text/event-stream")},
+			}},
+			operation{method: http.MethodDelete, summary: "End a session", responses: []response{
+				{status: http.StatusNoContent, description: "Session ended"},
+			}},
+		)
 	}
 
+	// OpenAPI description of the routes above
+	routes.handle(join(cfg.BasePath, OPENAPI), requestLogger(logger, serveOpenAPI(routes, cfg.McpName, cfg.McpVersion)),
+		operation{method: http.MethodGet, summary: "OpenAPI description of these endpoints", responses: []response{
+			{status: http.StatusOK, description: "OpenAPI 3.1 document", body: jsonContent(map[string]any{})},
+		}})
+
 	// Return the mux directly - logging is already applied to individual handlers
-	return mux
+	return routes.mux
+}
+
+// rootInfo is the body of the root info endpoint
+type rootInfo struct {
+	Name         string        `json:"name"`
+	Version      string        `json:"version"`
+	Timestamp    time.Time     `json:"timestamp"`
+	ReadOnly     bool          `json:"readOnly"`
+	Endpoints    rootEndpoints `json:"endpoints"`
+	Capabilities any           `json:"capabilities,omitempty"`
+	Stats        any           `json:"stats,omitempty"`
+}
+
+type rootEndpoints struct {
+	Health  string `json:"health"`
+	Ready   string `json:"ready"`
+	OpenAPI string `json:"openapi"`
+	Status  string `json:"status,omitempty"`
+	Compare string `json:"compare,omitempty"`
+	DOT     string `json:"dot,omitempty"`
+	JSONL   string `json:"jsonl,omitempty"`
+	Pprof   string `json:"pprof,omitempty"`
+	SSE     string `json:"sse,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+}
+
+const (
+	dotContentType   = "text/vnd.graphviz; charset=utf-8"
+	jsonlContentType = "application/jsonl"
+)
+
+// exportHandler serves GET requests with the output of export. The output is
+// buffered so a failed export is reported with an error status.
+func exportHandler(export func(ctx context.Context, w io.Writer) error, contentType string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		if err := export(r.Context(), &buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = buf.WriteTo(w)
+	})
+}
+
+// withNamespace stores the namespace of each request, as derived by namespace, in
+// its context before next serves it
+func withNamespace(namespace func(r *http.Request) string, next http.Handler) http.Handler {
+	if namespace == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ns := namespace(r); ns != "" {
+			r = r.WithContext(logging.WithNamespace(r.Context(), ns))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken rejects requests without an "Authorization: Bearer <token>" header
+// carrying token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseCompareOptions reads the assertion options from the query string
+func parseCompareOptions(r *http.Request) (CompareOptions, error) {
+	query := r.URL.Query()
+	var opts CompareOptions
+	for key, dst := range map[string]*bool{
+		"failOnMissingEntities":  &opts.FailOnMissingEntities,
+		"ignoreObservationOrder": &opts.IgnoreObservationOrder,
+	} {
+		if v := query.Get(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, errors.New("invalid " + key + ": must be true or false")
+			}
+			*dst = b
+		}
+	}
+	for _, v := range query["ignoreEntityTypes"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				opts.IgnoreEntityTypes = append(opts.IgnoreEntityTypes, t)
+			}
+		}
+	}
+	return opts, nil
 }
 
 // requestLogger is a lightweight HTTP middleware that logs request/response details.
@@ -182,3 +553,16 @@ func (lw *loggingResponseWriter) Write(b []byte) (int, error) {
 	lw.bytes += int64(n)
 	return n, err
 }
+
+// Flush sends buffered data to the client; event streams such as the SSE endpoint
+// depend on it
+func (lw *loggingResponseWriter) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (lw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
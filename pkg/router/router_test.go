@@ -1,15 +1,23 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -213,3 +221,523 @@ func TestNewRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRouter_StatusEndpoint(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+
+	// Without a status provider the endpoint is not mounted
+	handler := NewRouter(mcpServer, logger, &RouterConfig{})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, STATUS, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status without provider: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	var fail bool
+	handler = NewRouter(mcpServer, logger, &RouterConfig{
+		Status: func(ctx context.Context) (any, error) {
+			if fail {
+				return nil, errors.New("store unavailable")
+			}
+			return map[string]any{"maintenance": map[string]any{"enabled": true}}, nil
+		},
+	})
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, STATUS, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+	var body struct {
+		Maintenance struct {
+			Enabled bool `json:"enabled"`
+		} `json:"maintenance"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || !body.Maintenance.Enabled {
+		t.Errorf("status: unexpected body (err %v)", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, STATUS, nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status: expected %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+
+	fail = true
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, STATUS, nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("failing status: expected %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestNewRouter_ReadyEndpoint(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+
+	var fail bool
+	handler := NewRouter(mcpServer, logger, &RouterConfig{
+		Ready: func(ctx context.Context) error {
+			if fail {
+				return errors.New("database is locked")
+			}
+			return nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, READY, nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Errorf("ready: expected %d ok, got %d %q", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	fail = true
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, READY, nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("not ready: expected %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "database is locked") {
+		t.Errorf("not ready: body %q doesn't name the error", rr.Body.String())
+	}
+
+	// Liveness doesn't depend on the database
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, HEALTH, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("health: expected %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestNewRouter_RootCapabilities(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+
+	decode := func(handler http.Handler) map[string]any {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("root: expected %d, got %d", http.StatusOK, rr.Code)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatalf("root: %v", err)
+		}
+		return body
+	}
+
+	if _, ok := decode(NewRouter(mcpServer, logger, &RouterConfig{}))["capabilities"]; ok {
+		t.Error("root: capabilities present without a provider")
+	}
+
+	body := decode(NewRouter(mcpServer, logger, &RouterConfig{
+		Capabilities: func(ctx context.Context) any { return map[string]any{"ftsEnabled": true} },
+	}))
+	caps, ok := body["capabilities"].(map[string]any)
+	if !ok || caps["ftsEnabled"] != true {
+		t.Errorf("root: unexpected capabilities %v", body["capabilities"])
+	}
+	if _, ok := body["stats"]; ok {
+		t.Error("root: stats present without a provider")
+	}
+
+	body = decode(NewRouter(mcpServer, logger, &RouterConfig{
+		Stats: func(ctx context.Context) (any, error) { return map[string]any{"entities": 3}, nil },
+	}))
+	stats, ok := body["stats"].(map[string]any)
+	if !ok || stats["entities"] != float64(3) {
+		t.Errorf("root: unexpected stats %v", body["stats"])
+	}
+
+	// A failing provider leaves stats out but still serves the root info
+	body = decode(NewRouter(mcpServer, logger, &RouterConfig{
+		Stats: func(ctx context.Context) (any, error) { return nil, errors.New("database closed") },
+	}))
+	if _, ok := body["stats"]; ok {
+		t.Error("root: stats present although the provider failed")
+	}
+
+	if body := decode(NewRouter(mcpServer, logger, &RouterConfig{})); body["readOnly"] != false {
+		t.Errorf("root: expected readOnly false, got %v", body["readOnly"])
+	}
+	if body := decode(NewRouter(mcpServer, logger, &RouterConfig{ReadOnly: true})); body["readOnly"] != true {
+		t.Errorf("root: expected readOnly true, got %v", body["readOnly"])
+	}
+}
+
+// compareFixture is the snapshot the seeded database in TestNewRouter_Compare matches,
+// apart from its scratch entity
+const compareFixture = `{"type":"entity","name":"Alice","entityType":"person","observations":["engineer","likes go"]}
+{"type":"entity","name":"Acme","entityType":"org","observations":["founded 1999"]}
+{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}
+`
+
+func TestNewRouter_Compare(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+	ctx := context.Background()
+
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	seed, err := database.DecodeGraphJSONL(strings.NewReader(compareFixture +
+		`{"type":"entity","name":"Draft","entityType":"scratch","observations":["todo"]}` + "\n" +
+		`{"type":"relation","from":"Draft","to":"Alice","relationType":"mentions"}`))
+	if err != nil {
+		t.Fatalf("decode seed: %v", err)
+	}
+	if _, err := db.MergeGraph(ctx, seed); err != nil {
+		t.Fatalf("seed database: %v", err)
+	}
+
+	cfg := &RouterConfig{
+		APIToken: "secret",
+		Compare: func(ctx context.Context, snapshot io.Reader, opts CompareOptions) (any, error) {
+			result, err := db.CompareSnapshot(ctx, snapshot, database.CompareOptions{
+				DiffOptions: database.DiffOptions{
+					IgnoreObservationOrder: opts.IgnoreObservationOrder,
+					IgnoreEntityTypes:      opts.IgnoreEntityTypes,
+				},
+				FailOnMissingEntities: opts.FailOnMissingEntities,
+			})
+			var lineErr *database.ImportLineError
+			if errors.As(err, &lineErr) {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+			}
+			return result, err
+		},
+	}
+	handler := NewRouter(mcpServer, logger, cfg)
+
+	post := func(query, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, COMPARE+query, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	verdict := func(query, body string) *database.CompareResult {
+		t.Helper()
+		rr := post(query, "secret", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("compare%s: expected %d, got %d: %s", query, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var result database.CompareResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatalf("compare%s: %v", query, err)
+		}
+		return &result
+	}
+
+	t.Run("authentication", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			if rr := post("", token, compareFixture); rr.Code != http.StatusUnauthorized {
+				t.Errorf("token %q: expected %d, got %d", token, http.StatusUnauthorized, rr.Code)
+			}
+		}
+		unauthenticated := NewRouter(mcpServer, logger, &RouterConfig{Compare: cfg.Compare})
+		rr := httptest.NewRecorder()
+		unauthenticated.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, COMPARE, strings.NewReader(compareFixture)))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("without a token configured: expected %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("matching snapshot", func(t *testing.T) {
+		result := verdict("?ignoreEntityTypes=scratch", compareFixture)
+		if !result.Pass || !result.Diff.Empty() {
+			t.Errorf("expected a pass with an empty diff, got %+v %+v", result.Failures, result.Diff)
+		}
+	})
+
+	t.Run("unexpected entity", func(t *testing.T) {
+		result := verdict("", compareFixture)
+		if result.Pass {
+			t.Fatal("expected the unignored scratch entity to fail the comparison")
+		}
+		if len(result.Diff.ExtraEntities) != 1 || result.Diff.ExtraEntities[0] != "Draft" ||
+			len(result.Diff.ExtraRelations) != 1 {
+			t.Errorf("unexpected diff %+v", result.Diff)
+		}
+	})
+
+	t.Run("observation order", func(t *testing.T) {
+		reordered := strings.Replace(compareFixture, `"engineer","likes go"`, `"likes go","engineer"`, 1)
+		result := verdict("?ignoreEntityTypes=scratch", reordered)
+		if result.Pass || len(result.Diff.ObservationChanges) != 1 || !result.Diff.ObservationChanges[0].OrderChanged {
+			t.Errorf("expected an order change to fail, got %+v", result.Diff)
+		}
+		if result := verdict("?ignoreEntityTypes=scratch&ignoreObservationOrder=true", reordered); !result.Pass {
+			t.Errorf("expected a pass ignoring order, got %v", result.Failures)
+		}
+	})
+
+	t.Run("diverging snapshot", func(t *testing.T) {
+		diverging := strings.Replace(compareFixture, `"entityType":"org","observations":["founded 1999"]`,
+			`"entityType":"company","observations":["founded 2001"]`, 1)
+		result := verdict("?ignoreEntityTypes=scratch", diverging)
+		if result.Pass {
+			t.Fatal("expected a diverging snapshot to fail")
+		}
+		if len(result.Diff.TypeChanges) != 1 || result.Diff.TypeChanges[0].Actual != "org" {
+			t.Errorf("unexpected type changes %+v", result.Diff.TypeChanges)
+		}
+		obs := result.Diff.ObservationChanges
+		if len(obs) != 1 || obs[0].Missing[0] != "founded 2001" || obs[0].Extra[0] != "founded 1999" {
+			t.Errorf("unexpected observation changes %+v", obs)
+		}
+	})
+
+	t.Run("missing entities", func(t *testing.T) {
+		snapshot := compareFixture + `{"type":"entity","name":"Bob","entityType":"person"}` + "\n" +
+			`{"type":"relation","from":"Bob","to":"Acme","relationType":"works_at"}` + "\n"
+		result := verdict("?ignoreEntityTypes=scratch", snapshot)
+		if !result.Pass || len(result.Diff.MissingEntities) != 1 || len(result.Diff.MissingRelations) != 1 {
+			t.Errorf("expected missing entities to be reported but allowed, got %v %+v", result.Failures, result.Diff)
+		}
+		if result := verdict("?ignoreEntityTypes=scratch&failOnMissingEntities=true", snapshot); result.Pass {
+			t.Error("expected missing entities to fail with failOnMissingEntities")
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		if rr := post("", "secret", compareFixture+`{"type":"entity","name":"Bob"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("invalid line: expected %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if rr := post("?failOnMissingEntities=maybe", "secret", compareFixture); rr.Code != http.StatusBadRequest {
+			t.Errorf("invalid option: expected %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		req := httptest.NewRequest(http.MethodGet, COMPARE, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("GET compare: expected %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+		}
+	})
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata golden files")
+
+func TestNewRouter_ExportDOT(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+	ctx := context.Background()
+
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.CreateEntities(ctx, []database.EntityWithObservations{{Name: "Outage", EntityType: "incident"}}); err != nil {
+		t.Fatalf("seed database: %v", err)
+	}
+	if err := db.SetTypeMetadata(ctx, "incident", map[string]string{"color": "red"}); err != nil {
+		t.Fatalf("set type metadata: %v", err)
+	}
+
+	handler := NewRouter(mcpServer, logger, &RouterConfig{APIToken: "secret", ExportDOT: db.ExportDOT})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, DOT, nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: expected %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, DOT, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: expected %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if want := `"Outage" [label="Outage", entityType="incident", color="red"];`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("export missing %s:\n%s", want, rr.Body.String())
+	}
+
+	// Not mounted without a token
+	handler = NewRouter(mcpServer, logger, &RouterConfig{ExportDOT: db.ExportDOT})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, DOT, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("without APIToken: expected %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestNewRouter_ExportJSONL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+	ctx := context.Background()
+
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.CreateEntities(ctx, []database.EntityWithObservations{{Name: "Outage", EntityType: "incident"}}); err != nil {
+		t.Fatalf("seed database: %v", err)
+	}
+
+	handler := NewRouter(mcpServer, logger, &RouterConfig{APIToken: "secret", ExportJSONL: db.ExportJSONL})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, JSONL, nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: expected %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, JSONL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: expected %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/jsonl" {
+		t.Errorf("expected Content-Type application/jsonl, got %q", got)
+	}
+	if want := `{"v":2,"kind":"entity","name":"Outage","entityType":"incident"}` + "\n"; rr.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, rr.Body.String())
+	}
+}
+
+func TestNewRouter_Pprof(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+
+	get := func(handler http.Handler, path, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Not mounted unless enabled, nor without a token to guard it
+	for _, cfg := range []*RouterConfig{{APIToken: "secret"}, {EnablePprof: true}} {
+		if rr := get(NewRouter(mcpServer, logger, cfg), PPROF, "secret"); rr.Code != http.StatusNotFound {
+			t.Errorf("EnablePprof %v, APIToken %q: expected %d, got %d", cfg.EnablePprof, cfg.APIToken, http.StatusNotFound, rr.Code)
+		}
+	}
+
+	for _, basePath := range []string{"", "/api"} {
+		handler := NewRouter(mcpServer, logger, &RouterConfig{
+			BasePath:    basePath,
+			APIToken:    "secret",
+			EnablePprof: true,
+			Status:      func(ctx context.Context) (any, error) { return map[string]any{}, nil },
+		})
+		prefix := strings.TrimRight(basePath, "/")
+
+		if rr := get(handler, prefix+PPROF, ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%q without token: expected %d, got %d", basePath, http.StatusUnauthorized, rr.Code)
+		}
+		if rr := get(handler, prefix+PPROF+"heap?debug=1", "wrong"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%q with wrong token: expected %d, got %d", basePath, http.StatusUnauthorized, rr.Code)
+		}
+
+		rr := get(handler, prefix+PPROF, "secret")
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+			t.Errorf("%q index: expected the profile list, got %d: %s", basePath, rr.Code, rr.Body.String())
+		}
+		// Named profiles resolve below the base path instead of falling back to the index
+		rr = get(handler, prefix+PPROF+"goroutine?debug=1", "secret")
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), "goroutine profile: total") {
+			t.Errorf("%q goroutine profile: got %d: %.200s", basePath, rr.Code, rr.Body.String())
+		}
+		rr = get(handler, prefix+PPROF+"heap", "secret")
+		if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+			t.Errorf("%q heap profile: got %d with %d bytes", basePath, rr.Code, rr.Body.Len())
+		}
+		if rr := get(handler, prefix+PPROF+"nonexistent", "secret"); rr.Code != http.StatusNotFound {
+			t.Errorf("%q unknown profile: expected %d, got %d", basePath, http.StatusNotFound, rr.Code)
+		}
+
+		// Neighbouring routes are not shadowed
+		if rr := get(handler, prefix+STATUS, ""); rr.Code != http.StatusOK {
+			t.Errorf("%q status: expected %d, got %d", basePath, http.StatusOK, rr.Code)
+		}
+		var info rootInfo
+		rr = get(handler, prefix+"/", "")
+		if err := json.NewDecoder(rr.Body).Decode(&info); err != nil || info.Endpoints.Pprof != prefix+PPROF {
+			t.Errorf("%q root info: expected pprof endpoint %q, got %q (err %v)", basePath, prefix+PPROF, info.Endpoints.Pprof, err)
+		}
+	}
+}
+
+func TestReadRuntimeStats(t *testing.T) {
+	runtime.GC()
+	stats := ReadRuntimeStats()
+	if stats.Goroutines < 1 || stats.HeapAllocBytes == 0 || stats.NumGC == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.LastGC == nil || stats.GCPauseTotal < stats.LastGCPause {
+		t.Errorf("unexpected GC stats: %+v", stats)
+	}
+}
+
+// TestNewRouter_OpenAPI regenerates the OpenAPI document with every route mounted and
+// compares it with testdata/openapi.golden.json. Run with -update after changing routes.
+func TestNewRouter_OpenAPI(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "v1.2.3"}, nil)
+
+	handler := NewRouter(mcpServer, logger, &RouterConfig{
+		BasePath:     "/api",
+		EnableSSE:    true,
+		EnableStream: true,
+		McpName:      "test-server",
+		McpVersion:   "v1.2.3",
+		Status:       func(ctx context.Context) (any, error) { return map[string]any{}, nil },
+		Capabilities: func(ctx context.Context) any { return map[string]any{} },
+		Stats:        func(ctx context.Context) (any, error) { return map[string]any{}, nil },
+		APIToken:     "secret",
+		Compare: func(ctx context.Context, snapshot io.Reader, opts CompareOptions) (any, error) {
+			return nil, nil
+		},
+		CompareResponse: database.CompareResult{},
+		ExportDOT:       func(ctx context.Context, w io.Writer) error { return nil },
+		ExportJSONL:     func(ctx context.Context, w io.Writer) error { return nil },
+		EnablePprof:     true,
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api"+OPENAPI, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("openapi: expected %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	golden := filepath.Join("testdata", "openapi.golden.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, rr.Body.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if rr.Body.String() != string(want) {
+		t.Errorf("OpenAPI document differs from %s; run go test ./pkg/router -run OpenAPI -update and review the diff", golden)
+	}
+}
+
+func TestRouteRegistry_RequiresMetadata(t *testing.T) {
+	routes := newRouteRegistry()
+	routes.handle("/documented", http.NotFoundHandler(), operation{method: http.MethodGet, summary: "documented"})
+	routes.handle("/undocumented", http.NotFoundHandler())
+
+	rr := httptest.NewRecorder()
+	serveOpenAPI(routes, "test", "v0").ServeHTTP(rr, httptest.NewRequest(http.MethodGet, OPENAPI, nil))
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "/undocumented") {
+		t.Errorf("expected an undocumented route to fail generation, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
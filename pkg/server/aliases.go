@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// AliasParams are the parameters of add_alias
+type AliasParams struct {
+	EntityName string   `json:"entityName" jsonschema:"description:Entity to give the aliases"`
+	Aliases    []string `json:"aliases" jsonschema:"description:Other names the entity is known by, such as 'Bob' for 'Robert Smith'"`
+}
+
+// registerAliasTools registers add_alias
+func (s *Server) registerAliasTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "add_alias",
+			Title:        "Add Alias",
+			Description:  "Give an entity other names it is known by, such as 'Bob' and 'Robert' for 'Robert Smith', without merging entities. create_relations, add_observations and open_nodes accept an alias wherever no entity has the name, and search_nodes matches aliases; an entity named like an alias wins over it. Aliases the entity already has are listed in unchanged; an alias that is the name of an entity or an alias of another entity is rejected, and nothing is added",
+			OutputSchema: outputSchema[database.AliasResult](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params AliasParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleAddAlias(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleAddAlias(ctx context.Context, params AliasParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateAliasParams(params); err != nil {
+		logger.Warn("invalid add_alias parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	result, err := s.db.AliasEntity(ctx, params.EntityName, params.Aliases)
+	if err != nil {
+		logger.Warn("failed to add aliases",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, aliasError(ctx, err)
+	}
+
+	return s.marshalResult(ctx, "add_alias", result)
+}
+
+// aliasError reports a failed add_alias, giving the client a specific code when an
+// alias is taken
+func aliasError(ctx context.Context, err error) error {
+	var conflict *database.AliasConflictError
+	if !errors.As(err, &conflict) {
+		return operationError(ctx, i18n.ErrAddAlias, err)
+	}
+	code, args := i18n.ErrAliasTaken, []any{conflict.Alias, conflict.Entity}
+	if conflict.IsName {
+		code, args = i18n.ErrAliasIsEntityName, []any{conflict.Alias}
+	}
+	details := map[string]any{"alias": conflict.Alias, "entityName": conflict.Entity}
+	return &ToolError{Code: code, Message: i18n.T(ctx, code, args...), Details: details, Err: err}
+}
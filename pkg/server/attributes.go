@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// UpdateEntitiesParams are the parameters of update_entities
+type UpdateEntitiesParams struct {
+	Entities []database.EntityUpdate `json:"entities" jsonschema:"description:Entities and the changes to make to each, e.g. [{name: 'Acme', attributes: {url: 'https://acme.example', score: 0.8, crmId: null}}]"`
+}
+
+// updatedEntities is the structured result of update_entities
+type updatedEntities struct {
+	Entities []database.UpdatedEntity `json:"entities"`
+}
+
+// registerUpdateTools registers update_entities
+func (s *Server) registerUpdateTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "update_entities",
+			Title:        "Update Entities",
+			Description:  "Set structured attributes on existing entities, such as URLs, numeric scores or external IDs, instead of writing them as observations. The attributes given are merged into the entity's: keys are set, nested objects are merged, and keys set to null are removed. Returns each entity's attributes as left; if any entity doesn't exist nothing is changed",
+			OutputSchema: outputSchema[updatedEntities](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params UpdateEntitiesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleUpdateEntities(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleUpdateEntities(ctx context.Context, params UpdateEntitiesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateUpdateEntitiesParams(params); err != nil {
+		logger.Warn("invalid update_entities parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	results, err := s.db.UpdateEntities(ctx, params.Entities)
+	if err != nil {
+		logger.Warn("failed to update entities",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrUpdateEntities, err)
+	}
+
+	return s.marshalResultAs(ctx, "update_entities", results, &updatedEntities{Entities: results})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"sync"
+)
+
+// Capabilities maps feature flags and limits of this deployment to their values,
+// so agents can discover what it supports
+type Capabilities map[string]any
+
+var (
+	capabilityMu    sync.Mutex
+	capabilityFlags = map[string]func(s *Server) any{}
+)
+
+// registerCapability adds a flag computed from the server's config and database
+// state. Features call it from an init func next to their implementation.
+func registerCapability(name string, fn func(s *Server) any) {
+	capabilityMu.Lock()
+	defer capabilityMu.Unlock()
+	capabilityFlags[name] = fn
+}
+
+func init() {
+	// Not implemented by this server; the features replace these when they land
+	registerCapability("semanticSearch", func(*Server) any { return false })
+}
+
+// Capabilities returns the current value of every registered flag
+func (s *Server) Capabilities() Capabilities {
+	capabilityMu.Lock()
+	defer capabilityMu.Unlock()
+	caps := make(Capabilities, len(capabilityFlags))
+	for name, fn := range capabilityFlags {
+		caps[name] = fn(s)
+	}
+	return caps
+}
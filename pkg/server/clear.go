@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *Server) handleClearGraph(ctx context.Context, params ClearGraphParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateClearGraphParams(params); err != nil {
+		logger.Warn("invalid clear_graph parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	report, err := s.db.ClearGraph(forceContext(ctx, params.Force))
+	if err != nil {
+		logger.Error("failed to clear graph",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrClearGraph, err)
+	}
+	// Linked results hold copies of the deleted graph
+	s.results.clear()
+
+	return s.marshalResult(ctx, "clear_graph", report)
+}
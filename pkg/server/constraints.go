@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// relationConstraintView is a relation constraint with its defaults filled in
+type relationConstraintView struct {
+	AllowSelf bool `json:"allowSelf"`
+	// 0 = unlimited
+	MaxOutgoingPerEntity int `json:"maxOutgoingPerEntity"`
+	MaxIncomingPerEntity int `json:"maxIncomingPerEntity"`
+}
+
+// relationConstraints is the result of get_relation_constraints, keyed by relation type
+type relationConstraints struct {
+	Constraints map[string]relationConstraintView `json:"constraints"`
+}
+
+func (s *Server) handleGetRelationConstraints(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	constraints := map[string]relationConstraintView{}
+	for relationType, c := range s.db.RelationConstraints() {
+		constraints[relationType] = relationConstraintView{
+			AllowSelf:            c.SelfAllowed(),
+			MaxOutgoingPerEntity: c.MaxOutgoingPerEntity,
+			MaxIncomingPerEntity: c.MaxIncomingPerEntity,
+		}
+	}
+	return s.marshalResult(ctx, "get_relation_constraints", &relationConstraints{Constraints: constraints})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// UnmarshalJSON accepts an item as a plain name or as an object
+func (d *EntityDeletion) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		*d = EntityDeletion{}
+		return json.Unmarshal(trimmed, &d.Name)
+	}
+	// A custom unmarshaler doesn't inherit the SDK's strictness about unknown fields
+	type plain EntityDeletion
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var item plain
+	if err := dec.Decode(&item); err != nil {
+		return err
+	}
+	*d = EntityDeletion(item)
+	return nil
+}
+
+// deleteEntitiesSchema is the input schema of delete_entities, which takes items as
+// names or objects; the inferred one only allows objects
+func deleteEntitiesSchema() *jsonschema.Schema {
+	schema, err := jsonschema.For[DeleteEntitiesParams](nil)
+	if err != nil {
+		panic(err)
+	}
+	names := schema.Properties["entityNames"]
+	names.Items = &jsonschema.Schema{AnyOf: []*jsonschema.Schema{
+		{Type: "string", Description: "Entity to delete"},
+		names.Items,
+	}}
+	return schema
+}
+
+// entityDeletions is the result of delete_entities
+type entityDeletions struct {
+	database.EntityDeletionResult
+	// Reassigned reports the items naming a successor, in order
+	Reassigned []*database.Reassignment `json:"reassigned,omitempty"`
+}
+
+// deletionResult returns the counts a delete tool reports as JSON, followed by the
+// localized confirmation msg
+func (s *Server) deletionResult(ctx context.Context, tool string, counts any, msg string) (*mcp.CallToolResult, any, error) {
+	res, out, err := s.marshalResult(ctx, tool, counts)
+	if err != nil {
+		return nil, nil, err
+	}
+	res.Content = append(res.Content, &mcp.TextContent{Text: i18n.T(ctx, msg)})
+	return res, out, nil
+}
+
+// deletionPreview is the result of a dry run of delete_entities, delete_observations
+// or delete_relations
+type deletionPreview struct {
+	DryRun bool `json:"dryRun"`
+	database.DeletionPreview
+	// Reassigned previews the delete_entities items naming a successor
+	Reassigned []*database.Reassignment `json:"reassigned,omitempty"`
+}
+
+// previewDeleteEntities serves delete_entities with dryRun. Every item is previewed
+// against the current graph, as if it were the only one.
+func (s *Server) previewDeleteEntities(ctx context.Context, params DeleteEntitiesParams) (*mcp.CallToolResult, any, error) {
+	preview := deletionPreview{DryRun: true}
+	var names []string
+	for _, item := range params.EntityNames {
+		if item.ReassignRelationsTo == "" {
+			names = append(names, item.Name)
+			continue
+		}
+		result, err := s.db.PreviewEntityReassigning(ctx, item.Name, item.ReassignRelationsTo)
+		if err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrDeleteEntities, err)
+		}
+		preview.Reassigned = append(preview.Reassigned, result)
+	}
+	deleted, err := s.db.PreviewDeleteEntities(ctx, names)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrDeleteEntities, err)
+	}
+	preview.DeletionPreview = *deleted
+	return s.marshalResult(ctx, "delete_entities", preview)
+}
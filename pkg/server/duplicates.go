@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// FindDuplicatesParams are the parameters of find_duplicates
+type FindDuplicatesParams struct {
+	EntityType string  `json:"entityType,omitempty" jsonschema:"description:Only compare entities of this type"`
+	Threshold  float64 `json:"threshold,omitempty" jsonschema:"description:Also cluster entities of one type whose names, ignoring case, spacing and punctuation, are at least this similar (0 to 1, e.g. 0.85; edit-distance ratio). Unset clusters only names that are equal once normalized"`
+	Limit      int     `json:"limit,omitempty" jsonschema:"description:Maximum clusters to return (default 50, max 500)"`
+}
+
+// registerDuplicateTools registers find_duplicates
+func (s *Server) registerDuplicateTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "find_duplicates",
+			Title:        "Find Duplicates",
+			Description:  "Find clusters of entities that are likely the same thing, such as 'VS Code' and 'VSCode', by comparing names ignoring case, spacing and punctuation, and with threshold, names that are merely similar. Each entity is listed with its type and observation and relation counts, to decide which to keep; fold the others into it with delete_entities and reassignRelationsTo",
+			OutputSchema: outputSchema[database.DuplicateReport](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params FindDuplicatesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleFindDuplicates(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleFindDuplicates(ctx context.Context, params FindDuplicatesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateFindDuplicatesParams(params); err != nil {
+		logger.Warn("invalid find_duplicates parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	report, err := s.db.FindDuplicates(ctx, database.DuplicateOptions{
+		EntityType: params.EntityType,
+		Threshold:  params.Threshold,
+		Limit:      params.Limit,
+	})
+	if err != nil {
+		logger.Error("failed to find duplicates",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrFindDuplicates, err)
+	}
+
+	return s.marshalResult(ctx, "find_duplicates", report)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultMaxEventBytes is the default size limit of one SSE event. Some EventSource
+// clients, including the SDK's own, drop or cut lines longer than 1 MiB.
+const DefaultMaxEventBytes = 1 << 20
+
+// eventEnvelopeBytes is reserved in each event for the SSE framing, the JSON-RPC
+// envelope and the snapshot note read tools append to results
+const eventEnvelopeBytes = 1024
+
+type eventLimitKey struct{}
+
+// WithEventLimit marks ctx as that of a connection delivering each message as a
+// single event of at most maxBytes bytes, like the SSE transport, whose sessions and
+// tool calls inherit the context of the request that opened them. Graph results
+// that wouldn't fit are linked, result pages shrink to fit, and other results too
+// large fail with result_exceeds_event_limit. 0 means no limit.
+func WithEventLimit(ctx context.Context, maxBytes int) context.Context {
+	return context.WithValue(ctx, eventLimitKey{}, maxBytes)
+}
+
+// eventLimit returns the payload budget of one event on ctx's connection, or 0
+func eventLimit(ctx context.Context) int {
+	maxBytes, _ := ctx.Value(eventLimitKey{}).(int)
+	if maxBytes <= 0 {
+		return 0
+	}
+	return max(maxBytes-eventEnvelopeBytes, 1)
+}
+
+// exceedsEventLimit reports the encoded size of v when it is over ctx's event
+// budget, or 0 when it fits or there is no limit
+func exceedsEventLimit(ctx context.Context, v any) (int, error) {
+	limit := eventLimit(ctx)
+	if limit == 0 {
+		return 0, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) <= limit {
+		return 0, nil
+	}
+	return len(data), nil
+}
+
+// checkEventLimit fails a result that doesn't fit in one event of ctx's connection
+func checkEventLimit(ctx context.Context, res *mcp.CallToolResult) (*mcp.CallToolResult, error) {
+	size, err := exceedsEventLimit(ctx, res)
+	if err != nil {
+		return nil, operationError(ctx, i18n.ErrEncodeResult, err)
+	}
+	if size > 0 {
+		return nil, &ToolError{
+			Code:    i18n.ErrEventTooLarge,
+			Message: i18n.T(ctx, i18n.ErrEventTooLarge, size, eventLimit(ctx)),
+		}
+	}
+	return res, nil
+}
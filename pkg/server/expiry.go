@@ -0,0 +1,21 @@
+package server
+
+import (
+	"time"
+)
+
+// MaxTTLSeconds is the longest ttlSeconds add_observations accepts: ten years
+const MaxTTLSeconds = 10 * 365 * 24 * 60 * 60
+
+// observationExpiry returns when the observations of obs expire, as of now: at its
+// expiresAt, ttlSeconds after now, or never, as the zero time
+func observationExpiry(obs ObservationInput, now time.Time) time.Time {
+	if obs.TTLSeconds > 0 {
+		return now.Add(time.Duration(obs.TTLSeconds) * time.Second)
+	}
+	if obs.ExpiresAt == "" {
+		return time.Time{}
+	}
+	expiresAt, _ := time.Parse(time.RFC3339, obs.ExpiresAt)
+	return expiresAt
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() {
+	registerCapability("namespaces", func(s *Server) any { return s.db != nil })
+}
+
+// graphTools are the tools that work on the graph named by their graph argument.
+// The others work on the whole database, or don't read it, and only take the
+// default graph.
+var graphTools = map[string]bool{
+	"create_entities":        true,
+	"create_relations":       true,
+	"add_observations":       true,
+	"add_tags":               true,
+	"remove_tags":            true,
+	"update_entities":        true,
+	"delete_entities":        true,
+	"delete_observations":    true,
+	"delete_relations":       true,
+	"list_deleted":           true,
+	"restore_entities":       true,
+	"purge_deleted":          true,
+	"read_graph":             true,
+	"search_nodes":           true,
+	"open_nodes":             true,
+	"get_entity":             true,
+	"recent_entities":        true,
+	"get_observations":       true,
+	"get_inbound_relations":  true,
+	"get_outbound_relations": true,
+	"get_relations":          true,
+	"find_path":              true,
+	"get_neighbors":          true,
+	"get_history":            true,
+	"create_snapshot":        true,
+	"list_snapshots":         true,
+	"diff_snapshot":          true,
+	"find_orphans":           true,
+	"graph_hotspots":         true,
+	"find_duplicates":        true,
+	"pin_entities":           true,
+	"unpin_entities":         true,
+	"add_alias":              true,
+	"list_graphs":            true,
+	"clear_graph":            true,
+	"graph_stats":            true,
+}
+
+// graphFreeTools are the tools that don't read or change what any graph holds, so
+// take every graph
+var graphFreeTools = map[string]bool{
+	"get_capabilities":         true,
+	"get_relation_constraints": true,
+	"sync_memory":              true,
+}
+
+// graphParameter describes the graph argument every tool takes
+var graphParameter = &jsonschema.Schema{
+	Type: "string",
+	Description: "The named graph to work on, created by the first create_entities call naming it. " +
+		"Entity names are unique within a graph. Defaults to \"" + database.DefaultGraph + "\"",
+}
+
+// withGraphParameter adds the graph argument to the input schema of tool, which
+// must be a copy of its own
+func withGraphParameter(tool *mcp.Tool) *mcp.Tool {
+	schema := *tool.InputSchema
+	schema.Properties = maps.Clone(schema.Properties)
+	if schema.Properties == nil {
+		schema.Properties = map[string]*jsonschema.Schema{}
+	}
+	schema.Properties["graph"] = graphParameter
+	tool.InputSchema = &schema
+	return tool
+}
+
+// graphHandler takes the graph argument out of a call to tool before handler, which
+// doesn't know it, decodes the arguments, and runs handler with the graph in its
+// context. A call without one, or with an empty one, uses the default graph, or the
+// namespace the transport bound the client to, which is then the only graph it may
+// name. The call is given a request ID, and the changes it makes are recorded in the
+// audit log under tool.
+func (s *Server) graphHandler(tool string, handler mcp.ToolHandler) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx = toolCallContext(ctx, tool)
+		namespace := logging.NamespaceFromContext(ctx)
+		args, _ := req.Params.Arguments.(json.RawMessage)
+		var fields map[string]json.RawMessage
+		if (json.Unmarshal(args, &fields) != nil || fields["graph"] == nil) && namespace == "" {
+			return handler(ctx, req)
+		}
+
+		graph := namespace
+		var err error
+		if fields["graph"] != nil {
+			var arg string
+			switch {
+			case json.Unmarshal(fields["graph"], &arg) != nil:
+				err = s.invalidParams(s.requestContext(ctx, req), i18n.NewError(i18n.ErrInvalidGraph, string(fields["graph"]), database.MaxGraphNameLength))
+			case namespace != "" && arg != "" && arg != namespace:
+				err = s.invalidParams(s.requestContext(ctx, req), i18n.NewError(i18n.ErrGraphNamespace, namespace, arg))
+			case namespace == "":
+				graph = arg
+			}
+			if err == nil {
+				delete(fields, "graph")
+				if args, err = json.Marshal(fields); err != nil {
+					return nil, err
+				}
+				params := *req.Params
+				params.Arguments = args
+				call := *req
+				call.Params = &params
+				req = &call
+			}
+		}
+		if err == nil {
+			err = s.checkGraph(s.requestContext(ctx, req), tool, graph)
+		}
+		if err != nil {
+			res, _, err := toolResult(nil, nil, err)
+			return res, err
+		}
+
+		if graph != "" {
+			ctx = database.WithGraph(ctx, graph)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// checkGraph returns an invalid-params error if tool can't be called with graph
+func (s *Server) checkGraph(ctx context.Context, tool, graph string) error {
+	if graph == "" || graph == database.DefaultGraph {
+		return nil
+	}
+	if !database.ValidGraphName(graph) {
+		return s.invalidParams(ctx, i18n.NewError(i18n.ErrInvalidGraph, graph, database.MaxGraphNameLength))
+	}
+	if graphFreeTools[tool] {
+		return nil
+	}
+	if !graphTools[tool] {
+		return s.invalidParams(ctx, i18n.NewError(i18n.ErrGraphUnsupported, tool, database.DefaultGraph))
+	}
+	return s.needsSQLite(ctx, sqliteOption{"graph", true})
+}
+
+// graphList is the result of list_graphs
+type graphList struct {
+	Graphs []database.GraphSummary `json:"graphs"`
+}
+
+func (s *Server) handleListGraphs(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	graphs, err := s.db.ListGraphs(ctx)
+	if err != nil {
+		logger.Error("failed to list graphs",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrListGraphs, err)
+	}
+	// A client bound to a namespace doesn't learn of the others
+	if namespace := logging.NamespaceFromContext(ctx); namespace != "" {
+		graphs = slices.DeleteFunc(graphs, func(graph database.GraphSummary) bool {
+			return graph.Name != namespace
+		})
+	}
+
+	return s.marshalResult(ctx, "list_graphs", &graphList{Graphs: graphs})
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() {
+	registerCapability("maxSnapshots", func(s *Server) any {
+		if s.db == nil {
+			return 0
+		}
+		maxCount, _ := s.db.SnapshotLimits()
+		return maxCount
+	})
+}
+
+// SnapshotParams are the parameters of create_snapshot and diff_snapshot
+type SnapshotParams struct {
+	Label string `json:"label" jsonschema:"description:Label of the snapshot, such as 'before refactor'"`
+}
+
+// snapshotList is the result of list_snapshots
+type snapshotList struct {
+	Snapshots []database.GraphSnapshot `json:"snapshots"`
+}
+
+// registerSnapshotTools registers create_snapshot, list_snapshots and diff_snapshot
+func (s *Server) registerSnapshotTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "create_snapshot",
+			Title:        "Create Snapshot",
+			Description:  "Save the graph as it is now under a label, such as 'before refactor', to compare it with later using diff_snapshot. When the graph has more snapshots than the server keeps, the oldest are deleted and listed in evicted",
+			OutputSchema: outputSchema[database.CreatedSnapshot](),
+			Annotations:  additiveTool(false),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params SnapshotParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleCreateSnapshot(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_snapshots",
+			Title:        "List Snapshots",
+			Description:  "List the snapshots of the graph, newest first, with when each was taken and how many entities, observations and relations it holds",
+			OutputSchema: outputSchema[snapshotList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListSnapshots(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "diff_snapshot",
+			Title:        "Diff Snapshot",
+			Description:  "Compare the graph with a snapshot of it: the entities added and removed since, entities whose type changed, the observations added and removed per entity, and the relations added and removed",
+			OutputSchema: outputSchema[database.SnapshotDiff](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params SnapshotParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleDiffSnapshot(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleCreateSnapshot(ctx context.Context, params SnapshotParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateSnapshotLabel(params.Label); err != nil {
+		logger.Warn("invalid create_snapshot parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	created, err := s.db.CreateSnapshot(ctx, params.Label)
+	if err != nil {
+		logger.Warn("failed to create snapshot",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, snapshotError(ctx, i18n.ErrCreateSnapshot, params.Label, err)
+	}
+
+	return s.marshalResult(ctx, "create_snapshot", created)
+}
+
+func (s *Server) handleListSnapshots(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	snapshots, err := s.db.ListSnapshots(ctx)
+	if err != nil {
+		logger.Error("failed to list snapshots",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrListSnapshots, err)
+	}
+
+	return s.marshalResultAs(ctx, "list_snapshots", snapshots, &snapshotList{Snapshots: snapshots})
+}
+
+func (s *Server) handleDiffSnapshot(ctx context.Context, params SnapshotParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateSnapshotLabel(params.Label); err != nil {
+		logger.Warn("invalid diff_snapshot parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	diff, err := s.db.DiffSnapshot(ctx, params.Label)
+	if err != nil {
+		logger.Warn("failed to diff snapshot",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, snapshotError(ctx, i18n.ErrDiffSnapshot, params.Label, err)
+	}
+
+	return s.marshalResult(ctx, "diff_snapshot", diff)
+}
+
+// snapshotError reports a failed snapshot operation on label, giving the client a
+// specific code for the failures it can act on
+func snapshotError(ctx context.Context, id, label string, err error) error {
+	var sizeErr *database.SnapshotTooLargeError
+	var code string
+	var args []any
+	var details map[string]any
+	switch {
+	case errors.Is(err, database.ErrSnapshotNotFound):
+		code, args = i18n.ErrSnapshotNotFound, []any{label}
+	case errors.Is(err, database.ErrSnapshotExists):
+		code, args = i18n.ErrSnapshotExists, []any{label}
+	case errors.As(err, &sizeErr):
+		code, args = i18n.ErrSnapshotTooLarge, []any{sizeErr.Bytes, sizeErr.Max}
+		details = map[string]any{"bytes": sizeErr.Bytes, "maxBytes": sizeErr.Max}
+	default:
+		return operationError(ctx, id, err)
+	}
+	return &ToolError{Code: code, Message: i18n.T(ctx, code, args...), Details: details, Err: err}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// GetHistoryParams are the parameters of get_history
+type GetHistoryParams struct {
+	EntityName string `json:"entityName,omitempty" jsonschema:"description:Only return the changes that affected this entity"`
+	Since      string `json:"since,omitempty" jsonschema:"description:Only return changes made at or after this time (RFC 3339, e.g. 2025-01-31T09:00:00Z)"`
+	Until      string `json:"until,omitempty" jsonschema:"description:Only return changes made before this time (RFC 3339)"`
+	AfterID    int64  `json:"afterId,omitempty" jsonschema:"description:Return the changes after the entry with this id. Pass the id of the last entry of a page with hasMore set to read the next"`
+	Limit      int    `json:"limit,omitempty" jsonschema:"description:Maximum entries to return (default 100, max 1000)"`
+}
+
+// registerHistoryTools registers get_history
+func (s *Server) registerHistoryTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_history",
+			Title:        "Get History",
+			Description:  "List the changes made to the graph, oldest first: which operation and tool call made each, when, which entities it affected and a summary of what it did. Filter by entity to see how one came to be, or by time range to see what changed in between",
+			OutputSchema: outputSchema[database.HistoryPage](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetHistoryParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetHistory(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleGetHistory(ctx context.Context, params GetHistoryParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateGetHistoryParams(params); err != nil {
+		logger.Warn("invalid get_history parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	filter := database.HistoryFilter{EntityName: params.EntityName, AfterID: params.AfterID, Limit: params.Limit}
+	filter.Since, _ = parseHistoryTime(params.Since)
+	filter.Until, _ = parseHistoryTime(params.Until)
+	page, err := s.db.History(ctx, filter)
+	if err != nil {
+		logger.Error("failed to get history",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrGetHistory, err)
+	}
+
+	return s.marshalResult(ctx, "get_history", page)
+}
+
+// parseHistoryTime parses an RFC 3339 time bounding get_history, or "" as the zero
+// time, which leaves that end open
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// toolCallContext gives ctx a request ID, unless the transport set one, and records
+// the tool call in it for the audit log
+func toolCallContext(ctx context.Context, tool string) context.Context {
+	requestID := logging.RequestIDFromContext(ctx)
+	if requestID == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err == nil {
+			requestID = hex.EncodeToString(b)
+			ctx = logging.WithRequestID(ctx, requestID)
+		}
+	}
+	return database.WithToolCall(ctx, tool, requestID)
+}
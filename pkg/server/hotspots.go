@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// GraphHotspotsParams are the parameters of graph_hotspots
+type GraphHotspotsParams struct {
+	By         string `json:"by,omitempty" jsonschema:"description:Rank by relations to the entity ('in'), from it ('out') or both ('total', the default)"`
+	EntityType string `json:"entityType,omitempty" jsonschema:"description:Only rank entities of this type"`
+	Limit      int    `json:"limit,omitempty" jsonschema:"description:Number of entities to return (default 10, max 100)"`
+}
+
+// hotspotList is the result of graph_hotspots
+type hotspotList struct {
+	Hotspots []database.Hotspot `json:"hotspots"`
+}
+
+// registerHotspotTools registers graph_hotspots
+func (s *Server) registerHotspotTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "graph_hotspots",
+			Title:        "Graph Hotspots",
+			Description:  "List the most connected entities of the graph, its hubs, with their type and the number of relations to them, from them and in total, ranked by the degree chosen with by. Useful to summarize a graph alongside graph_stats",
+			OutputSchema: outputSchema[hotspotList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GraphHotspotsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGraphHotspots(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleGraphHotspots(ctx context.Context, params GraphHotspotsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateGraphHotspotsParams(params); err != nil {
+		logger.Warn("invalid graph_hotspots parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	hotspots, err := s.db.Hotspots(ctx, database.HotspotOptions{
+		By:         params.By,
+		EntityType: params.EntityType,
+		Limit:      params.Limit,
+	})
+	if err != nil {
+		logger.Error("failed to rank hotspots",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrGraphHotspots, err)
+	}
+
+	return s.marshalResultAs(ctx, "graph_hotspots", hotspots, &hotspotList{Hotspots: hotspots})
+}
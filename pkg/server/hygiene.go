@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Staleness window of memory_hygiene_report, in days
+const (
+	DefaultHygieneStaleDays = 90
+	MaxHygieneStaleDays     = 3650
+)
+
+// MaxHygieneSample is the number of items and follow-up calls listed per finding
+const MaxHygieneSample = 20
+
+// Hygiene checks, in report order
+const (
+	CheckEmptyEntities     = "empty_entities"
+	CheckStaleEntities     = "stale_entities"
+	CheckDuplicateNames    = "duplicate_names"
+	CheckOversizedEntities = "oversized_entities"
+	CheckRareRelationTypes = "rare_relation_types"
+	CheckConstraints       = "relation_constraints"
+	CheckFTSDrift          = "fts_drift"
+)
+
+// hygieneReport is the result of memory_hygiene_report
+type hygieneReport struct {
+	StaleAfterDays int `json:"staleAfterDays"`
+	Totals         struct {
+		Entities     int `json:"entities"`
+		Observations int `json:"observations"`
+		Relations    int `json:"relations"`
+	} `json:"totals"`
+	// Checks lists every check run; those with something to act on are in Findings
+	Checks   []string         `json:"checks"`
+	Findings []hygieneFinding `json:"findings"`
+}
+
+type hygieneFinding struct {
+	Check       string `json:"check"`
+	Count       int    `json:"count"`
+	Description string `json:"description"`
+	// Items holds up to MaxHygieneSample of the affected items
+	Items       any              `json:"items"`
+	Truncated   bool             `json:"truncated,omitempty"`
+	Suggestions []toolSuggestion `json:"suggestions"`
+}
+
+// toolSuggestion is a follow-up call for a finding. Tool is empty when no tool can
+// fix it and the reason says who can.
+type toolSuggestion struct {
+	Tool      string         `json:"tool,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Reason    string         `json:"reason"`
+}
+
+// sample returns up to MaxHygieneSample items and whether any were left out
+func sample[T any](items []T) ([]T, bool) {
+	if len(items) > MaxHygieneSample {
+		return items[:MaxHygieneSample], true
+	}
+	return items, false
+}
+
+// buildHygieneReport turns the database report into findings with follow-up calls
+func buildHygieneReport(r *database.HygieneReport, staleAfterDays int, observationLimit int) *hygieneReport {
+	out := &hygieneReport{StaleAfterDays: staleAfterDays, Findings: []hygieneFinding{}}
+	out.Totals.Entities, out.Totals.Observations, out.Totals.Relations = r.Entities, r.Observations, r.Relations
+	add := func(check string, count int, description string, items any, truncated bool, suggestions ...toolSuggestion) {
+		out.Checks = append(out.Checks, check)
+		if count == 0 {
+			return
+		}
+		out.Findings = append(out.Findings, hygieneFinding{
+			Check: check, Count: count, Description: description,
+			Items: items, Truncated: truncated, Suggestions: suggestions,
+		})
+	}
+
+	empty, truncated := sample(r.EmptyEntities)
+	add(CheckEmptyEntities, len(r.EmptyEntities), "Entities without observations", empty, truncated,
+		toolSuggestion{Tool: "open_nodes", Arguments: map[string]any{"names": empty}, Reason: "Check whether these entities and their relations are still needed"},
+		toolSuggestion{Tool: "delete_entities", Arguments: map[string]any{"entityNames": empty}, Reason: "Delete the placeholders that are no longer needed"},
+	)
+
+	stale, truncated := sample(r.StaleEntities)
+	staleNames := make([]string, len(stale))
+	for i, e := range stale {
+		staleNames[i] = e.Name
+	}
+	add(CheckStaleEntities, len(r.StaleEntities), fmt.Sprintf("Entities without writes in %d days, oldest first", staleAfterDays), stale, truncated,
+		toolSuggestion{Tool: "open_nodes", Arguments: map[string]any{"names": staleNames}, Reason: "Review whether these are still accurate: add_observations records what changed, delete_entities removes what is obsolete"},
+	)
+
+	groups, truncated := sample(r.DuplicateNames)
+	var merges []toolSuggestion
+	for _, g := range groups {
+		var why string
+		switch g.Reason {
+		case database.DuplicateCase:
+			why = "differ only in case"
+		case database.DuplicatePunctuation:
+			why = "differ only in case, spacing or punctuation"
+		default:
+			why = fmt.Sprintf("are %.0f%% similar and of the same type", g.Similarity*100)
+		}
+		merges = append(merges, toolSuggestion{
+			Tool:      "open_nodes",
+			Arguments: map[string]any{"names": g.Names},
+			Reason:    fmt.Sprintf("%s %s. If they are the same, copy the observations to one with add_observations and delete the others with delete_entities", strings.Join(g.Names, ", "), why),
+		})
+	}
+	description := "Groups of entities that may be the same thing"
+	if len(r.FuzzySkippedTypes) > 0 {
+		description += fmt.Sprintf("; similar names were not compared within the types %s, which have too many entities", strings.Join(r.FuzzySkippedTypes, ", "))
+	}
+	add(CheckDuplicateNames, len(r.DuplicateNames), description, groups, truncated, merges...)
+
+	if r.OversizedAt > 0 {
+		oversized, truncated := sample(r.OversizedEntities)
+		var reviews []toolSuggestion
+		for _, e := range oversized {
+			reviews = append(reviews, toolSuggestion{
+				Tool:      "get_observations",
+				Arguments: map[string]any{"entityName": e.Name, "orderBy": database.ObservationOrderOldest},
+				Reason:    fmt.Sprintf("%s has %d observations; reads return at most %d. Remove outdated ones with delete_observations or split the entity", e.Name, e.Observations, observationLimit),
+			})
+		}
+		add(CheckOversizedEntities, len(r.OversizedEntities), fmt.Sprintf("Entities with at least %d observations, near or over the read limit", r.OversizedAt), oversized, truncated, reviews...)
+	}
+
+	rare, truncated := sample(r.RareRelationTypes)
+	var fixes []toolSuggestion
+	for _, rt := range rare {
+		fixes = append(fixes, toolSuggestion{
+			Tool:      "open_nodes",
+			Arguments: map[string]any{"names": []string{rt.Relation.From, rt.Relation.To}},
+			Reason:    fmt.Sprintf("Relation type %q is used once. If it is a misspelling, replace the relation with delete_relations and create_relations", rt.RelationType),
+		})
+	}
+	add(CheckRareRelationTypes, len(r.RareRelationTypes), "Relation types used by a single relation, often misspellings of another type", rare, truncated, fixes...)
+
+	breaches, truncated := sample(r.ConstraintBreaches)
+	var trims []toolSuggestion
+	for _, b := range breaches {
+		var why string
+		switch b.Rule {
+		case database.RuleAllowSelf:
+			why = fmt.Sprintf("%s relates to itself with %q, which the relation constraints forbid", b.Entity, b.RelationType)
+		case database.RuleMaxOutgoingPerEntity:
+			why = fmt.Sprintf("%s has %d outgoing %q relations; at most %d are allowed", b.Entity, b.Count, b.RelationType, b.Limit)
+		default:
+			why = fmt.Sprintf("%s has %d incoming %q relations; at most %d are allowed", b.Entity, b.Count, b.RelationType, b.Limit)
+		}
+		trims = append(trims, toolSuggestion{
+			Tool:      "open_nodes",
+			Arguments: map[string]any{"names": []string{b.Entity}},
+			Reason:    why + ". Remove the extra relations with delete_relations",
+		})
+	}
+	add(CheckConstraints, len(r.ConstraintBreaches), "Existing relations breaking the relation constraints, e.g. created before a constraint was added", breaches, truncated, trims...)
+
+	if r.FTS != nil {
+		count := 0
+		if r.FTS.Drifted() {
+			count = r.FTS.MissingEntities + r.FTS.OrphanedEntities + r.FTS.MissingObservations + r.FTS.OrphanedObservations
+		}
+		add(CheckFTSDrift, count, "Rows missing from or left behind in the full-text indexes", r.FTS, false,
+			toolSuggestion{Reason: "search_nodes may miss matches or return deleted ones. No tool rebuilds the index; report this to the server operator"},
+		)
+	}
+	return out
+}
+
+func (s *Server) handleHygieneReport(ctx context.Context, params HygieneReportParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	if err := ValidateHygieneReportParams(params); err != nil {
+		logger.Warn("invalid memory_hygiene_report parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	days := params.StaleAfterDays
+	if days == 0 {
+		days = DefaultHygieneStaleDays
+	}
+
+	report, err := s.db.Hygiene(ctx, database.HygieneOptions{StaleAfter: time.Duration(days) * 24 * time.Hour})
+	if err != nil {
+		logger.Error("failed to build hygiene report",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrHygieneReport, err)
+	}
+	out := buildHygieneReport(report, days, s.db.ObservationLimit())
+
+	logger.Info("hygiene report built",
+		slog.Int("findings", len(out.Findings)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResult(ctx, "memory_hygiene_report", out)
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrorCodeMetaKey is the result _meta key carrying a failed tool call's error code
+const ErrorCodeMetaKey = "errorCode"
+
+// ToolError is a tool failure with a locale-independent code and a message in the
+// caller's locale
+type ToolError struct {
+	// Code is a stable i18n message ID clients can match on
+	Code    string
+	Message string
+	// Details are values clients can act on, such as the entity that wasn't found
+	Details map[string]any
+	Err     error
+}
+
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// validationError reports invalid tool parameters. The code is the ID of the
+// failed check, e.g. entity_name_empty.
+func validationError(ctx context.Context, err error) error {
+	code := i18n.Code(err)
+	if code == "" {
+		code = i18n.ErrValidation
+	}
+	return &ToolError{
+		Code:    code,
+		Message: i18n.T(ctx, i18n.ErrValidation) + ": " + i18n.Localize(i18n.LocaleFrom(ctx), err),
+		Err:     err,
+	}
+}
+
+// operationError wraps a failed operation's error for the client. Cancellation is
+// reported as a distinct "operation cancelled" error, keeping any partial-progress
+// details from the database layer.
+func operationError(ctx context.Context, id string, err error) error {
+	var notFound *database.EntityNotFoundError
+	if errors.As(err, &notFound) {
+		code := i18n.ErrEntityNotFound
+		return &ToolError{
+			Code:    code,
+			Message: i18n.T(ctx, code, notFound.Name),
+			Details: map[string]any{"entityName": notFound.Name},
+			Err:     err,
+		}
+	}
+	var capErr *database.ObservationCapError
+	if errors.As(err, &capErr) {
+		code := i18n.ErrObservationCapExceeded
+		return &ToolError{
+			Code:    code,
+			Message: i18n.T(ctx, code, capErr.Entity, capErr.Count, capErr.Cap),
+			Details: map[string]any{"entityName": capErr.Entity, "maxStoredObservations": capErr.Cap},
+			Err:     err,
+		}
+	}
+	var sizeErr *database.AttributesSizeError
+	if errors.As(err, &sizeErr) {
+		code := i18n.ErrAttributesTooLarge
+		return &ToolError{
+			Code:    code,
+			Message: i18n.T(ctx, code, sizeErr.Size, database.MaxAttributesBytes),
+			Details: map[string]any{"entityName": sizeErr.Entity, "maxAttributesBytes": database.MaxAttributesBytes},
+			Err:     err,
+		}
+	}
+	if isCancellation(err) {
+		id = i18n.ErrOperationCancelled
+	}
+	return &ToolError{
+		Code:    id,
+		Message: i18n.T(ctx, id) + ": " + err.Error(),
+		Err:     err,
+	}
+}
+
+// importError reports a failed import operation, giving the client a specific code
+// for the failures it can act on
+func importError(ctx context.Context, id string, err error) error {
+	var seqErr *database.ImportSequenceError
+	var lineErr *database.ImportLineError
+	var code string
+	var args []any
+	var details map[string]any
+	switch {
+	case errors.Is(err, database.ErrImportNotFound):
+		code = i18n.ErrImportNotFound
+	case errors.As(err, &seqErr) && seqErr.Duplicate:
+		code, args = i18n.ErrImportDuplicateChunk, []any{seqErr.Got, seqErr.Expected}
+		details = map[string]any{"sequence": seqErr.Got, "expectedSequence": seqErr.Expected}
+	case errors.As(err, &seqErr):
+		code, args = i18n.ErrImportOutOfOrder, []any{seqErr.Got, seqErr.Expected}
+		details = map[string]any{"sequence": seqErr.Got, "expectedSequence": seqErr.Expected}
+	case errors.As(err, &lineErr):
+		code, args = i18n.ErrImportInvalidLine, []any{lineErr.Line, lineErr.Err}
+		details = map[string]any{"line": lineErr.Line}
+	default:
+		return operationError(ctx, id, err)
+	}
+	return &ToolError{Code: code, Message: i18n.T(ctx, code, args...), Details: details, Err: err}
+}
+
+// exportError reports a failed export_graph of the view around root, giving the
+// client a specific code for a view it can narrow
+func exportError(ctx context.Context, err error, root string) error {
+	var nodesErr *database.TooManyNodesError
+	var code string
+	var args []any
+	var details map[string]any
+	switch {
+	case errors.As(err, &nodesErr):
+		code, args = i18n.ErrViewTooLarge, []any{nodesErr.Max}
+		details = map[string]any{"maxNodes": nodesErr.Max}
+	case errors.Is(err, database.ErrRootNotFound):
+		code, args = i18n.ErrRootNotFound, []any{root}
+		details = map[string]any{"root": root}
+	default:
+		return operationError(ctx, i18n.ErrExportGraph, err)
+	}
+	return &ToolError{Code: code, Message: i18n.T(ctx, code, args...), Details: details, Err: err}
+}
+
+// constraintError reports the relations of a create_relations call that break
+// relation constraints, or of a strict one that name missing entities, one message
+// per relation
+func constraintError(ctx context.Context, err error) error {
+	var missingErr *database.MissingEndpointsError
+	if errors.As(err, &missingErr) {
+		return missingEndpointsError(ctx, missingErr)
+	}
+	var constraintErr *database.RelationConstraintError
+	if !errors.As(err, &constraintErr) {
+		return operationError(ctx, i18n.ErrCreateRelations, err)
+	}
+	messages := make([]string, len(constraintErr.Violations))
+	for i, v := range constraintErr.Violations {
+		switch v.Rule {
+		case database.RuleAllowSelf:
+			messages[i] = i18n.T(ctx, i18n.MsgConstraintAllowSelf, v.Index, v.Relation.From, v.Relation.RelationType)
+		case database.RuleMaxOutgoingPerEntity:
+			messages[i] = i18n.T(ctx, i18n.MsgConstraintMaxOutgoing, v.Index, v.Relation.From, v.Limit, v.Relation.RelationType)
+		default:
+			messages[i] = i18n.T(ctx, i18n.MsgConstraintMaxIncoming, v.Index, v.Relation.To, v.Limit, v.Relation.RelationType)
+		}
+	}
+	code := i18n.ErrRelationConstraint
+	return &ToolError{Code: code, Message: i18n.T(ctx, code, len(messages), strings.Join(messages, "; ")), Err: err}
+}
+
+// missingEndpointsError reports the relations of a strict create_relations call that
+// name entities that don't exist, one message per relation, and lists them in the
+// details as they would have been skipped
+func missingEndpointsError(ctx context.Context, err *database.MissingEndpointsError) error {
+	messages := make([]string, len(err.Skipped))
+	for i, s := range err.Skipped {
+		name := s.Relation.From
+		if s.Reason == database.SkipMissingTo {
+			name = s.Relation.To
+		}
+		messages[i] = i18n.T(ctx, i18n.MsgRelationEndpointMissing, s.Index, name)
+	}
+	code := i18n.ErrRelationEndpointsMissing
+	return &ToolError{
+		Code:    code,
+		Message: i18n.T(ctx, code, len(messages), strings.Join(messages, "; ")),
+		Details: map[string]any{"skipped": err.Skipped},
+		Err:     err,
+	}
+}
+
+// requestContext returns ctx carrying the caller's identity as the database writer
+// and the locale for a tool call: the "locale" or "acceptLanguage" _meta hint, then
+// the HTTP Accept-Language header, then the server's configured locale
+func (s *Server) requestContext(ctx context.Context, req *mcp.CallToolRequest) context.Context {
+	if writer := sessionWriter(req); writer != "" {
+		ctx = database.WithWriter(ctx, writer)
+	}
+
+	var hints []string
+	if req != nil && req.Params != nil {
+		meta := req.Params.GetMeta()
+		for _, key := range []string{"locale", "acceptLanguage"} {
+			if hint, ok := meta[key].(string); ok {
+				hints = append(hints, hint)
+			}
+		}
+	}
+	if req != nil && req.Extra != nil && req.Extra.Header != nil {
+		hints = append(hints, req.Extra.Header.Get("Accept-Language"))
+	}
+
+	for _, hint := range hints {
+		if locale := i18n.Match(hint); locale != "" {
+			return i18n.WithLocale(ctx, locale)
+		}
+	}
+	return i18n.WithLocale(ctx, s.opts.Locale)
+}
+
+// sessionWriter identifies the client behind a tool call by the name it gave when
+// initializing, falling back to the session ID
+func sessionWriter(req *mcp.CallToolRequest) string {
+	if req == nil || req.Session == nil {
+		return ""
+	}
+	if init := req.Session.InitializeParams(); init != nil && init.ClientInfo != nil && init.ClientInfo.Name != "" {
+		return init.ClientInfo.Name
+	}
+	return req.Session.ID()
+}
+
+// errorBody is the text of an error result
+type errorBody struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// toolResult reports a ToolError as an error result, so the model reads what went
+// wrong instead of the client seeing a failed call. Its text is the JSON object
+// {"code", "message", "details"}, with the localized message, and its _meta carries
+// the code too. Other errors are returned as they are.
+func toolResult(res *mcp.CallToolResult, out any, err error) (*mcp.CallToolResult, any, error) {
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) {
+		return res, out, err
+	}
+	var text strings.Builder
+	enc := json.NewEncoder(&text)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(errorBody{Code: toolErr.Code, Message: toolErr.Message, Details: toolErr.Details}); err != nil {
+		return nil, nil, err
+	}
+	return &mcp.CallToolResult{
+		Meta: mcp.Meta{ErrorCodeMetaKey: toolErr.Code},
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: strings.TrimSuffix(text.String(), "\n")},
+		},
+		IsError: true,
+	}, nil, nil
+}
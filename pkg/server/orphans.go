@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// FindOrphansParams are the parameters of find_orphans
+type FindOrphansParams struct {
+	NoObservations bool `json:"noObservations,omitempty" jsonschema:"description:Only return orphans without observations"`
+	OlderThanDays  int  `json:"olderThanDays,omitempty" jsonschema:"description:Only return orphans not updated for at least this many days"`
+	Limit          int  `json:"limit,omitempty" jsonschema:"description:Maximum orphans to return (default 100, max 1000)"`
+	DeleteOrphans  bool `json:"deleteOrphans,omitempty" jsonschema:"description:Delete the orphans returned, as delete_entities would. Call without it first to review them"`
+	Force          bool `json:"force,omitempty" jsonschema:"description:With deleteOrphans, delete pinned orphans too; without it they are kept and listed in protected"`
+}
+
+// registerOrphanTools registers find_orphans
+func (s *Server) registerOrphanTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "find_orphans",
+			Title:        "Find Orphans",
+			Description:  "List the entities with no relations from or to them, least recently updated first, optionally only those without observations or not updated for olderThanDays, to decide what to clean up. Set deleteOrphans to delete the orphans listed in the same call",
+			OutputSchema: outputSchema[database.OrphanReport](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params FindOrphansParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleFindOrphans(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleFindOrphans(ctx context.Context, params FindOrphansParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateFindOrphansParams(params); err != nil {
+		logger.Warn("invalid find_orphans parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	report, err := s.db.FindOrphans(forceContext(ctx, params.Force), database.OrphanOptions{
+		NoObservations: params.NoObservations,
+		OlderThanDays:  params.OlderThanDays,
+		Limit:          params.Limit,
+		Delete:         params.DeleteOrphans,
+	})
+	if err != nil {
+		logger.Warn("failed to find orphans",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrFindOrphans, err)
+	}
+
+	return s.marshalResult(ctx, "find_orphans", report)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// outputSchema is the output schema of a tool whose structured result is a T,
+// inferred as the SDK infers input schemas and adjusted to what encoding/json
+// writes for a T: the fields of embedded structs are promoted, nil slices and maps
+// are null, and types with their own JSON encoding take any value
+func outputSchema[T any]() *jsonschema.Schema {
+	schema, err := jsonschema.For[T](nil)
+	if err != nil {
+		panic(err)
+	}
+	adjustSchema(schema, reflect.TypeFor[T]())
+	// The top level is always an object, which is all the SDK accepts
+	schema.Type, schema.Types = "object", nil
+	return schema
+}
+
+// anyOfOutputSchema is the output schema of a tool whose structured result is any
+// of the given shapes
+func anyOfOutputSchema(schemas ...*jsonschema.Schema) *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "object", AnyOf: schemas}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	timeType          = reflect.TypeFor[time.Time]()
+)
+
+// adjustSchema corrects the schema jsonschema.For inferred for t in place
+func adjustSchema(schema *jsonschema.Schema, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != timeType && (t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)) {
+		*schema = jsonschema.Schema{Description: schema.Description}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		nullable(schema)
+		if schema.Items != nil {
+			adjustSchema(schema.Items, t.Elem())
+		}
+	case reflect.Map:
+		nullable(schema)
+		if schema.AdditionalProperties != nil {
+			adjustSchema(schema.AdditionalProperties, t.Elem())
+		}
+	case reflect.Struct:
+		for _, f := range reflect.VisibleFields(t) {
+			if len(f.Index) > 1 || (!f.IsExported() && !f.Anonymous) {
+				continue
+			}
+			tag := f.Tag.Get("json")
+			name, _, _ := strings.Cut(tag, ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			prop := schema.Properties[name]
+			if prop == nil {
+				continue
+			}
+			adjustSchema(prop, f.Type)
+			if f.Anonymous && tag == "" {
+				// encoding/json promotes the fields of an untagged embedded struct
+				delete(schema.Properties, name)
+				schema.Required = slices.DeleteFunc(schema.Required, func(r string) bool { return r == name })
+				for k, v := range prop.Properties {
+					schema.Properties[k] = v
+				}
+				schema.Required = append(schema.Required, prop.Required...)
+			}
+		}
+	}
+}
+
+// nullable lets schema also match null
+func nullable(schema *jsonschema.Schema) {
+	if schema.Type != "" {
+		schema.Types = []string{"null", schema.Type}
+		schema.Type = ""
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// pathResult is the result of find_path
+type pathResult struct {
+	Found bool `json:"found"`
+	// Entities lists the entities from the start to the target; empty when not found
+	Entities []string `json:"entities"`
+	// Path lists the relations from the start to the target; empty when not found
+	Path []database.RelationDTO `json:"path"`
+}
+
+// pathEntities returns the entities a path starting at from passes through, in order
+func pathEntities(from string, path []database.RelationDTO) []string {
+	entities := []string{from}
+	for _, rel := range path {
+		next := rel.To
+		if rel.From != entities[len(entities)-1] {
+			next = rel.From
+		}
+		entities = append(entities, next)
+	}
+	return entities
+}
+
+func (s *Server) handleFindPath(ctx context.Context, params FindPathParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	if err := ValidateFindPathParams(params); err != nil {
+		logger.Warn("invalid find_path parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	depth := params.MaxDepth
+	if depth == 0 {
+		depth = DefaultPathDepth
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	path, found, err := db.FindPath(ctx, params.From, params.To, depth, params.Directed)
+	if err != nil {
+		logger.Error("failed to find path",
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrFindPath, err)
+	}
+	result := pathResult{Found: found, Entities: []string{}, Path: []database.RelationDTO{}}
+	if found {
+		result.Entities, result.Path = pathEntities(params.From, path), path
+	}
+
+	logger.Debug("path search finished",
+		slog.Bool("found", found),
+		slog.Int("length", len(path)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	res, out, err := s.marshalResult(ctx, "find_path", result)
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleGetNeighbors(ctx context.Context, params GetNeighborsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	if err := ValidateGetNeighborsParams(params); err != nil {
+		logger.Warn("invalid get_neighbors parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	depth := params.Depth
+	if depth == 0 {
+		depth = DefaultNeighborDepth
+	}
+	direction := params.Direction
+	if direction == "" {
+		direction = database.NeighborsBoth
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	neighborhood, err := db.GetNeighbors(ctx, params.Names, depth, direction, MaxNeighborNodes)
+	if err != nil {
+		logger.Error("failed to get neighbors",
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrGetNeighbors, err)
+	}
+
+	logger.Debug("neighbors traversed",
+		slog.Int("entities", len(neighborhood.Entities)),
+		slog.Int("relations", len(neighborhood.Relations)),
+		slog.Bool("truncated", neighborhood.Truncated),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	res, out, err := s.marshalResult(ctx, "get_neighbors", neighborhood)
+	return markSnapshot(ctx, res, takenAt), out, err
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// PinParams are the parameters of pin_entities and unpin_entities
+type PinParams struct {
+	EntityNames []string `json:"entityNames" jsonschema:"description:Entities to pin or unpin"`
+}
+
+// registerPinTools registers pin_entities and unpin_entities
+func (s *Server) registerPinTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "pin_entities",
+			Title:        "Pin Entities",
+			Description:  "Pin foundation entities, such as 'User Preferences', to protect them from deletion: delete_entities, find_orphans and clear_graph keep pinned entities and list them in protected unless called with force. Entities already pinned are listed in unchanged; if any entity doesn't exist nothing is pinned",
+			OutputSchema: outputSchema[database.PinResult](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params PinParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handlePin(ctx, "pin_entities", params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "unpin_entities",
+			Title:        "Unpin Entities",
+			Description:  "Unpin entities so they can be deleted again. Entities that aren't pinned are listed in unchanged; if any entity doesn't exist nothing is unpinned",
+			OutputSchema: outputSchema[database.PinResult](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params PinParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handlePin(ctx, "unpin_entities", params))
+		},
+	)
+}
+
+// handlePin serves pin_entities and unpin_entities, named by tool
+func (s *Server) handlePin(ctx context.Context, tool string, params PinParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidatePinParams(params); err != nil {
+		logger.Warn("invalid "+tool+" parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	change, code := s.db.PinEntities, i18n.ErrPinEntities
+	if tool == "unpin_entities" {
+		change, code = s.db.UnpinEntities, i18n.ErrUnpinEntities
+	}
+	result, err := change(ctx, params.EntityNames)
+	if err != nil {
+		logger.Warn("failed to change pins",
+			slog.String("tool", tool),
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, code, err)
+	}
+
+	return s.marshalResult(ctx, tool, result)
+}
+
+// forceContext returns ctx under which pinned entities are deleted when force is set
+func forceContext(ctx context.Context, force bool) context.Context {
+	if force {
+		return database.WithForceDelete(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Limits are the byte lengths validation allows and the most items one request may
+// carry. Operators may lower them; only the observation length and the batch size
+// may be raised above DefaultLimits, up to MaxObservationLengthCeiling and
+// MaxBatchSizeCeiling.
+type Limits struct {
+	EntityName   int `json:"entityName"`
+	EntityType   int `json:"entityType"`
+	RelationType int `json:"relationType"`
+	Observation  int `json:"observation"`
+	// BatchSize caps the entities, relations or names of one request
+	BatchSize int `json:"batchSize"`
+}
+
+// DefaultLimits returns the built-in limits
+func DefaultLimits() Limits {
+	return Limits{
+		EntityName:   MaxEntityNameLength,
+		EntityType:   MaxEntityTypeLength,
+		RelationType: MaxRelationTypeLength,
+		Observation:  MaxObservationLength,
+		BatchSize:    MaxEntitiesPerRequest,
+	}
+}
+
+var activeLimits atomic.Pointer[Limits]
+
+func init() {
+	limits := DefaultLimits()
+	activeLimits.Store(&limits)
+	registerCapability("limits", func(*Server) any { return ActiveLimits() })
+}
+
+// ActiveLimits returns the limits validation currently enforces
+func ActiveLimits() Limits {
+	return *activeLimits.Load()
+}
+
+// SetLimits replaces the limits validation enforces and returns them. Zero fields
+// keep their default; a field above its ceiling is an error.
+func SetLimits(limits Limits) (Limits, error) {
+	defaults := DefaultLimits()
+	for _, f := range []struct {
+		name    string
+		value   *int
+		def     int
+		ceiling int
+	}{
+		{"entity name length", &limits.EntityName, defaults.EntityName, defaults.EntityName},
+		{"entity type length", &limits.EntityType, defaults.EntityType, defaults.EntityType},
+		{"relation type length", &limits.RelationType, defaults.RelationType, defaults.RelationType},
+		{"observation length", &limits.Observation, defaults.Observation, MaxObservationLengthCeiling},
+		{"batch size", &limits.BatchSize, defaults.BatchSize, MaxBatchSizeCeiling},
+	} {
+		switch {
+		case *f.value == 0:
+			*f.value = f.def
+		case *f.value < 0 || *f.value > f.ceiling:
+			return Limits{}, fmt.Errorf("%s limit %d must be between 1 and %d", f.name, *f.value, f.ceiling)
+		}
+	}
+	activeLimits.Store(&limits)
+	return limits, nil
+}
+
+// What the server does at startup when stored data breaks the active limits or
+// validation rules
+const (
+	PolicyCheckWarn   = "warn"
+	PolicyCheckRefuse = "refuse"
+	PolicyCheckOff    = "off"
+)
+
+// MaxPolicySamples is the number of offending values listed per finding
+const MaxPolicySamples = 10
+
+// maxPolicyValueSample is the number of bytes of an offending value shown in reports
+const maxPolicyValueSample = 80
+
+// PolicySample is a stored value breaking a rule. Value is cut to 80 bytes; Length
+// is the full length in bytes.
+type PolicySample struct {
+	Entity string `json:"entity,omitempty"`
+	Value  string `json:"value"`
+	Length int    `json:"length"`
+}
+
+// PolicyFinding counts the stored values of a kind breaking a rule
+type PolicyFinding struct {
+	Kind    string         `json:"kind"`
+	Rule    string         `json:"rule"`
+	Count   int            `json:"count"`
+	Samples []PolicySample `json:"samples"`
+}
+
+// PolicyReport compares the stored data with the active limits and validation rules
+type PolicyReport struct {
+	Limits     Limits `json:"limits"`
+	Compatible bool   `json:"compatible"`
+	// Scanned counts the names, distinct types and observations checked
+	Scanned  int             `json:"scanned"`
+	Findings []PolicyFinding `json:"findings"`
+}
+
+// Summary describes the findings in one line for logs
+func (r *PolicyReport) Summary() string {
+	parts := make([]string, len(r.Findings))
+	for i, f := range r.Findings {
+		parts[i] = fmt.Sprintf("%d %s (%s)", f.Count, f.Kind, f.Rule)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// policyViolation is a stored value breaking a rule
+type policyViolation struct {
+	database.StoredText
+	Rule string
+}
+
+// validateStoredText returns the rule a stored value breaks, or "" if none
+func validateStoredText(text database.StoredText) string {
+	var err error
+	switch text.Kind {
+	case database.TextEntityName:
+		err = ValidateEntityName(text.Value)
+	case database.TextEntityType:
+		err = ValidateEntityType(text.Value)
+	case database.TextRelationType:
+		err = ValidateRelationType(text.Value)
+	default:
+		err = ValidateObservation(text.Value)
+	}
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		return ruleErr.Rule
+	}
+	return ""
+}
+
+// scanStoredData checks every stored value and returns the report, the violations
+// and the names and types in use, keyed by kind and value
+func scanStoredData(ctx context.Context, db *database.DB) (*PolicyReport, []policyViolation, map[string]bool, error) {
+	report := &PolicyReport{Limits: ActiveLimits(), Findings: []PolicyFinding{}}
+	var violations []policyViolation
+	inUse := map[string]bool{}
+	findings := map[[2]string]*PolicyFinding{}
+
+	err := db.EachStoredText(ctx, func(text database.StoredText) error {
+		report.Scanned++
+		if text.Kind != database.TextObservation {
+			inUse[text.Kind+"\x00"+text.Value] = true
+		}
+		rule := validateStoredText(text)
+		if rule == "" {
+			return nil
+		}
+		violations = append(violations, policyViolation{StoredText: text, Rule: rule})
+
+		key := [2]string{text.Kind, rule}
+		f := findings[key]
+		if f == nil {
+			f = &PolicyFinding{Kind: text.Kind, Rule: rule, Samples: []PolicySample{}}
+			findings[key] = f
+		}
+		f.Count++
+		if len(f.Samples) < MaxPolicySamples {
+			f.Samples = append(f.Samples, PolicySample{Entity: text.Entity, Value: clipValue(text.Value), Length: len(text.Value)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, f := range findings {
+		report.Findings = append(report.Findings, *f)
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Rule < b.Rule
+	})
+	report.Compatible = len(violations) == 0
+	return report, violations, inUse, nil
+}
+
+// CheckStoredData compares the stored data with the active limits and validation
+// rules, e.g. at startup after an operator lowered a limit
+func CheckStoredData(ctx context.Context, db *database.DB) (*PolicyReport, error) {
+	report, _, _, err := scanStoredData(ctx, db)
+	return report, err
+}
+
+// clipValue cuts a value to maxPolicyValueSample bytes for reports
+func clipValue(value string) string {
+	value = strings.ToValidUTF8(value, "�")
+	if len(value) <= maxPolicyValueSample {
+		return value
+	}
+	return truncateUTF8(value, maxPolicyValueSample) + "…"
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// chunkText splits s into parts of at most limit bytes, breaking after whitespace
+// where that keeps a part at least half full, and never inside a character
+func chunkText(s string, limit int) []string {
+	var parts []string
+	for len(s) > 0 {
+		part := truncateUTF8(s, limit)
+		if part == "" {
+			// A character longer than the limit goes whole
+			_, size := utf8.DecodeRuneInString(s)
+			part = s[:size]
+		} else if len(part) < len(s) {
+			if i := strings.LastIndexFunc(part, unicode.IsSpace); i >= limit/2 {
+				_, size := utf8.DecodeRuneInString(part[i:])
+				part = part[:i+size]
+			}
+		}
+		s = s[len(part):]
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+	return parts
+}
+
+// Actions migrate_to_policy takes on an offending value
+const (
+	PolicyActionChunked   = "chunked"
+	PolicyActionTruncated = "truncated"
+	PolicyActionRenamed   = "renamed"
+	PolicyActionDeleted   = "deleted"
+)
+
+// policyChange is a rewrite planned by migrate_to_policy. From is cut to 80 bytes.
+type policyChange struct {
+	Kind   string   `json:"kind"`
+	Rule   string   `json:"rule"`
+	Action string   `json:"action"`
+	Entity string   `json:"entity,omitempty"`
+	From   string   `json:"from"`
+	To     []string `json:"to"`
+	// Merged is set when a renamed type joins an existing one
+	Merged bool `json:"merged,omitempty"`
+}
+
+// unresolvedViolation is an offending value migrate_to_policy cannot fix, such as a
+// name made only of control characters, which needs a rename by hand
+type unresolvedViolation struct {
+	Kind   string `json:"kind"`
+	Rule   string `json:"rule"`
+	Entity string `json:"entity,omitempty"`
+	Value  string `json:"value"`
+}
+
+// policyMigration is the result of migrate_to_policy
+type policyMigration struct {
+	DryRun     bool                  `json:"dryRun"`
+	Limits     Limits                `json:"limits"`
+	Changes    []policyChange        `json:"changes"`
+	Unresolved []unresolvedViolation `json:"unresolved"`
+	// Compatible reports whether the stored data passes validation after the changes;
+	// on a dry run, whether it would
+	Compatible bool `json:"compatible"`
+}
+
+// planPolicyMigration turns violations into rewrites: observations are split into
+// parts within the limit, names and types lose control characters and are cut to
+// the limit, and entity names get a numeric suffix where the result is taken.
+// Observation rewrites come first, as they find their entity by its current name.
+func planPolicyMigration(violations []policyViolation, inUse map[string]bool) (*policyMigration, []database.TextRewrite) {
+	limits := ActiveLimits()
+	plan := &policyMigration{Limits: limits, Changes: []policyChange{}, Unresolved: []unresolvedViolation{}}
+	var observations, renames []database.TextRewrite
+
+	for _, v := range violations {
+		change := policyChange{Kind: v.Kind, Rule: v.Rule, Entity: v.Entity, From: clipValue(v.Value)}
+
+		if v.Kind == database.TextObservation {
+			change.To = chunkText(strings.ToValidUTF8(v.Value, "�"), limits.Observation)
+			switch len(change.To) {
+			case 0:
+				change.Action = PolicyActionDeleted
+			case 1:
+				change.Action = PolicyActionTruncated
+			default:
+				change.Action = PolicyActionChunked
+			}
+			plan.Changes = append(plan.Changes, change)
+			observations = append(observations, database.TextRewrite{Kind: v.Kind, Entity: v.Entity, From: v.Value, To: change.To})
+			continue
+		}
+
+		limit := map[string]int{
+			database.TextEntityName:   limits.EntityName,
+			database.TextEntityType:   limits.EntityType,
+			database.TextRelationType: limits.RelationType,
+		}[v.Kind]
+		name := sanitizeName(v.Value, limit)
+		if v.Kind == database.TextEntityName {
+			name = freeName(name, limit, func(n string) bool { return inUse[v.Kind+"\x00"+n] })
+		}
+		if name == "" || validateStoredText(database.StoredText{Kind: v.Kind, Value: name}) != "" {
+			plan.Unresolved = append(plan.Unresolved, unresolvedViolation{Kind: v.Kind, Rule: v.Rule, Entity: v.Entity, Value: clipValue(v.Value)})
+			continue
+		}
+
+		change.Action = PolicyActionRenamed
+		change.To = []string{name}
+		change.Merged = inUse[v.Kind+"\x00"+name]
+		delete(inUse, v.Kind+"\x00"+v.Value)
+		inUse[v.Kind+"\x00"+name] = true
+		plan.Changes = append(plan.Changes, change)
+		renames = append(renames, database.TextRewrite{Kind: v.Kind, From: v.Value, To: []string{name}})
+	}
+
+	plan.Compatible = len(plan.Unresolved) == 0
+	return plan, append(observations, renames...)
+}
+
+// sanitizeName replaces invalid UTF-8 and control characters with spaces, trims the
+// result and cuts it to limit bytes
+func sanitizeName(value string, limit int) string {
+	value = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, strings.ToValidUTF8(value, " "))
+	return strings.TrimSpace(truncateUTF8(strings.TrimSpace(value), limit))
+}
+
+// freeName returns name, or name with the lowest suffix " 2", " 3"... that is not
+// taken, cut so it stays within limit bytes
+func freeName(name string, limit int, taken func(string) bool) string {
+	if name == "" || !taken(name) {
+		return name
+	}
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf(" %d", n)
+		candidate := strings.TrimSpace(truncateUTF8(name, limit-len(suffix))) + suffix
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+func (s *Server) handleMigrateToPolicy(ctx context.Context, params MigrateToPolicyParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	_, violations, inUse, err := scanStoredData(ctx, s.db)
+	if err != nil {
+		logger.Error("failed to scan stored data",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrMigrateToPolicy, err)
+	}
+	plan, rewrites := planPolicyMigration(violations, inUse)
+	plan.DryRun = params.DryRun
+
+	if !params.DryRun && len(rewrites) > 0 {
+		if err := s.db.RewriteTexts(ctx, rewrites); err != nil {
+			logger.Error("failed to migrate stored data to policy",
+				slog.String("error", err.Error()),
+				slog.Duration("duration", time.Since(start)),
+			)
+			return nil, nil, operationError(ctx, i18n.ErrMigrateToPolicy, err)
+		}
+		report, err := CheckStoredData(ctx, s.db)
+		if err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrMigrateToPolicy, err)
+		}
+		plan.Compatible = report.Compatible
+		// Linked results may show the old values
+		s.results.clear()
+	}
+
+	logger.Info("stored data migrated to policy",
+		slog.Bool("dry_run", params.DryRun),
+		slog.Int("changes", len(plan.Changes)),
+		slog.Int("unresolved", len(plan.Unresolved)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResult(ctx, "migrate_to_policy", plan)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MaxRejectedValueSample is the number of bytes of a rejected value logged at debug level
+const MaxRejectedValueSample = 64
+
+// RuleError is a value rejected by a validation rule. Rule is the message ID of the
+// wrapped i18n error, which is also the tool error code.
+type RuleError struct {
+	Rule  string
+	Value string
+	err   *i18n.Error
+}
+
+// reject returns a RuleError for value breaking rule, with args for the message
+func reject(value, rule string, args ...any) error {
+	return &RuleError{Rule: rule, Value: value, err: i18n.NewError(rule, args...)}
+}
+
+func (e *RuleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.err
+}
+
+// ValidationStats counts the tool calls rejected by validation since the server started
+type ValidationStats struct {
+	Since time.Time `json:"since"`
+	Total int64     `json:"total"`
+	// ByRule maps the rule, the error code of the rejection, to its count
+	ByRule map[string]int64 `json:"byRule"`
+}
+
+// rejectionCounter counts validation failures by rule
+type rejectionCounter struct {
+	mu     sync.Mutex
+	since  time.Time
+	counts map[string]int64
+}
+
+func newRejectionCounter() *rejectionCounter {
+	return &rejectionCounter{since: time.Now().UTC(), counts: map[string]int64{}}
+}
+
+func (c *rejectionCounter) record(rule string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[rule]++
+}
+
+func (c *rejectionCounter) stats() ValidationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := ValidationStats{Since: c.since, ByRule: make(map[string]int64, len(c.counts))}
+	for rule, n := range c.counts {
+		stats.ByRule[rule] = n
+		stats.Total += n
+	}
+	return stats
+}
+
+// ValidationStats returns the validation failures counted so far
+func (s *Server) ValidationStats() ValidationStats {
+	return s.rejections.stats()
+}
+
+// invalidParams counts a validation failure by rule, logs a truncated sample of the
+// rejected value at debug level, and returns the error for the client
+func (s *Server) invalidParams(ctx context.Context, err error) error {
+	rule := s.countRejection(err)
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) && ruleErr.Value != "" {
+		logging.LoggerWithContext(ctx, s.logger).Debug("rejected value",
+			slog.String("rule", rule),
+			slog.String("sample", truncateSample(ruleErr.Value, MaxRejectedValueSample)),
+			slog.Int("bytes", len(ruleErr.Value)),
+		)
+	}
+	return validationError(ctx, err)
+}
+
+// countRejection counts a validation failure without logging the value, returning its rule
+func (s *Server) countRejection(err error) string {
+	rule := i18n.Code(err)
+	if rule == "" {
+		rule = i18n.ErrValidation
+	}
+	s.rejections.record(rule)
+	return rule
+}
+
+// truncateSample cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateSample(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+func (s *Server) handleGetValidationStats(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	return s.marshalResult(ctx, "get_validation_stats", s.ValidationStats())
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// ResultURIPrefix is the resource URI space for results too large to inline
+	ResultURIPrefix = "memory://results/"
+	// DefaultResultTTL is how long an over-threshold result stays readable
+	DefaultResultTTL = 10 * time.Minute
+	// DefaultResultPageSize is the number of items returned per resource read
+	DefaultResultPageSize = 200
+)
+
+func init() {
+	// Largest read_graph or search_nodes result returned inline; larger ones are linked (0 = no limit)
+	registerCapability("maxResultBytes", func(s *Server) any { return s.opts.ResultLinkThreshold })
+}
+
+// storedResult is a cached tool result awaiting paged reads
+type storedResult struct {
+	graph   *database.KnowledgeGraph
+	expires time.Time
+}
+
+// resultStore keeps large tool results for a limited time so clients can read them in pages
+type resultStore struct {
+	mu      sync.Mutex
+	results map[string]*storedResult
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func newResultStore(ttl time.Duration) *resultStore {
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+	return &resultStore{
+		results: make(map[string]*storedResult),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// put stores a graph and returns its id
+func (rs *resultStore) put(graph *database.KnowledgeGraph) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.evictLocked()
+	rs.results[id] = &storedResult{graph: graph, expires: rs.now().Add(rs.ttl)}
+	return id, nil
+}
+
+// get returns the graph stored under id if it has not expired
+func (rs *resultStore) get(id string) (*database.KnowledgeGraph, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.evictLocked()
+	res, ok := rs.results[id]
+	if !ok {
+		return nil, false
+	}
+	return res.graph, true
+}
+
+// clear drops every stored result
+func (rs *resultStore) clear() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	clear(rs.results)
+}
+
+func (rs *resultStore) evictLocked() {
+	now := rs.now()
+	for id, res := range rs.results {
+		if !now.Before(res.expires) {
+			delete(rs.results, id)
+		}
+	}
+}
+
+// ResultPage is one page of a stored result. Items are numbered across the
+// entities first and then the relations, so offset/nextOffset walk both lists.
+type ResultPage struct {
+	Offset     int                               `json:"offset"`
+	NextOffset *int                              `json:"nextOffset,omitempty"`
+	Total      int                               `json:"total"`
+	Entities   []database.EntityWithObservations `json:"entities"`
+	Relations  []database.RelationDTO            `json:"relations"`
+}
+
+// page slices a graph into a ResultPage starting at offset
+func page(graph *database.KnowledgeGraph, offset, limit int) ResultPage {
+	nEntities := len(graph.Entities)
+	total := nEntities + len(graph.Relations)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	p := ResultPage{
+		Offset:    offset,
+		Total:     total,
+		Entities:  []database.EntityWithObservations{},
+		Relations: []database.RelationDTO{},
+	}
+	if offset < nEntities {
+		p.Entities = graph.Entities[offset:min(end, nEntities)]
+	}
+	if end > nEntities {
+		p.Relations = graph.Relations[max(offset-nEntities, 0) : end-nEntities]
+	}
+	if end < total {
+		p.NextOffset = &end
+	}
+	return p
+}
+
+// linkedGraph is the structured result of a graph too large to return inline
+type linkedGraph struct {
+	ResultURI     string `json:"resultUri"`
+	Bytes         int    `json:"bytes"`
+	EntityCount   int    `json:"entityCount"`
+	RelationCount int    `json:"relationCount"`
+}
+
+// linkedResult replaces an oversized graph result with a short summary and a
+// resource link to the stored result
+func (s *Server) linkedResult(ctx context.Context, graph *database.KnowledgeGraph, size int) (*mcp.CallToolResult, any, error) {
+	id, err := s.results.put(graph)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrStoreResult, err)
+	}
+	uri := ResultURIPrefix + id
+	byteSize := int64(size)
+
+	summary := i18n.T(ctx, i18n.MsgResultTooLarge,
+		size, len(graph.Entities), len(graph.Relations), uri, s.opts.ResultPageSize, s.results.ttl,
+	)
+	out := &linkedGraph{ResultURI: uri, Bytes: size, EntityCount: len(graph.Entities), RelationCount: len(graph.Relations)}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: summary},
+			&mcp.ResourceLink{
+				URI:         uri,
+				Name:        "result-" + id,
+				Description: "Paged tool result",
+				MIMEType:    "application/json",
+				Size:        &byteSize,
+			},
+		},
+		StructuredContent: out,
+	}, out, nil
+}
+
+// graphResult encodes a graph as the text and structured content of the tool
+// result, or stores it and returns a resource link when it exceeds the configured
+// inline threshold or wouldn't fit in one event of the connection
+func (s *Server) graphResult(ctx context.Context, tool string, graph *database.KnowledgeGraph) (*mcp.CallToolResult, any, error) {
+	jsonData, err := encodeJSON(graph, s.compactJSON(ctx))
+	if err != nil {
+		return nil, nil, s.encodeError(ctx, tool, graph, err)
+	}
+	if s.opts.ResultLinkThreshold > 0 && len(jsonData) > s.opts.ResultLinkThreshold {
+		return s.linkedResult(ctx, graph, len(jsonData))
+	}
+	res := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: jsonData},
+		},
+		StructuredContent: graph,
+	}
+	size, err := exceedsEventLimit(ctx, res)
+	if err != nil {
+		return nil, nil, s.encodeError(ctx, tool, graph, err)
+	}
+	if size > 0 {
+		return s.linkedResult(ctx, graph, len(jsonData))
+	}
+	return res, graph, nil
+}
+
+// handleReadResult serves pages of stored results at memory://results/{id}?offset=N&limit=M
+func (s *Server) handleReadResult(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	u, err := url.Parse(uri)
+	if err != nil || !strings.HasPrefix(uri, ResultURIPrefix) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	id := strings.TrimPrefix(u.Path, "/")
+
+	graph, ok := s.results.get(id)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	offset, limit := 0, s.opts.ResultPageSize
+	query := u.Query()
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if limit > s.opts.ResultPageSize {
+		limit = s.opts.ResultPageSize
+	}
+
+	// Pages shrink until they fit in one event of the connection; nextOffset
+	// continues where the shorter page ends
+	for {
+		jsonData, err := encodeJSON(page(graph, offset, limit), s.opts.CompactJSON)
+		if err != nil {
+			return nil, err
+		}
+		res := &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{URI: uri, MIMEType: "application/json", Text: jsonData},
+			},
+		}
+		size, err := exceedsEventLimit(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return res, nil
+		}
+		if limit == 1 {
+			return nil, fmt.Errorf("item %d alone (%d bytes) exceeds the %d bytes this connection delivers in one event", offset, size, eventLimit(ctx))
+		}
+		limit /= 2
+	}
+}
+
+// registerResultResources exposes stored results under the memory://results/ URI space
+func (s *Server) registerResultResources(mcpServer *mcp.Server) {
+	mcpServer.AddResourceTemplate(
+		&mcp.ResourceTemplate{
+			Name:        "results",
+			Description: "Large tool results stored for paged reading. Use ?offset=N&limit=M to page.",
+			MIMEType:    "application/json",
+			URITemplate: ResultURIPrefix + "{id}{?offset,limit}",
+		},
+		s.handleReadResult,
+	)
+}
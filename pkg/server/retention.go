@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() {
+	registerCapability("retentionPolicy", func(s *Server) any { return s.db != nil && s.db.RetentionPolicy() != nil })
+}
+
+// retentionPreview is the result of preview_retention
+type retentionPreview struct {
+	// Policy is nil when no retention policy is configured
+	Policy  *database.RetentionPolicy `json:"policy"`
+	Preview *database.RetentionReport `json:"preview"`
+	// LastRun is the summary of the last maintenance run that applied the policy
+	LastRun *database.RetentionReport `json:"lastRun,omitempty"`
+}
+
+func (s *Server) handlePreviewRetention(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	preview, err := s.db.ApplyRetention(ctx, time.Now(), true)
+	if err != nil {
+		logger.Error("failed to preview retention",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrPreviewRetention, err)
+	}
+	lastRun, err := s.db.LastRetentionRun(ctx)
+	if err != nil {
+		logger.Error("failed to read the last retention run",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrPreviewRetention, err)
+	}
+
+	return s.marshalResult(ctx, "preview_retention", retentionPreview{
+		Policy:  s.db.RetentionPolicy(),
+		Preview: preview,
+		LastRun: lastRun,
+	})
+}
@@ -1,45 +1,158 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
 	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/maintenance"
 	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/store"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type Server struct {
-	db     *database.DB
-	logger *slog.Logger
+	store   store.Store
+	db      *database.DB // store when it is SQLite, which every tool beyond storeTools needs
+	logger  *slog.Logger
+	opts    Options
+	results *resultStore
+
+	toolsMu sync.Mutex
+	tools   []string // names of the tools added by RegisterTools
+
+	syncMu   sync.Mutex
+	lastSync time.Time // start of the last sync_memory call
+
+	rejections *rejectionCounter
+}
+
+func init() {
+	registerCapability("ftsEnabled", func(s *Server) any {
+		if s.db == nil {
+			_, ok := s.store.(store.FullTextSearcher)
+			return ok
+		}
+		return s.db.IsFTSEnabled()
+	})
+	registerCapability("maxStoredObservationsPerEntity", func(s *Server) any {
+		if s.db == nil {
+			return 0
+		}
+		limit, _ := s.db.ObservationCap()
+		return limit
+	})
+	registerCapability("readOnly", func(s *Server) any { return s.opts.ReadOnly || s.db != nil && s.db.IsReadOnly() })
+	registerCapability("enabledTools", func(s *Server) any {
+		s.toolsMu.Lock()
+		defer s.toolsMu.Unlock()
+		return append([]string{}, s.tools...)
+	})
+}
+
+// Options configures optional server behavior
+type Options struct {
+	// ResultLinkThreshold is the encoded size in bytes above which read_graph and
+	// search_nodes return a resource link instead of inline content (0 disables)
+	ResultLinkThreshold int
+	// ResultTTL is how long linked results remain readable (default DefaultResultTTL)
+	ResultTTL time.Duration
+	// ResultPageSize is the maximum number of items per result resource read (default DefaultResultPageSize)
+	ResultPageSize int
+	// Maintenance is reported by get_maintenance_status (nil reports maintenance as disabled)
+	Maintenance *maintenance.Scheduler
+	// Locale renders messages for requests that don't ask for a locale (default i18n.DefaultLocale)
+	Locale string
+	// SyncInterval is the minimum time between sync_memory calls (default DefaultSyncInterval)
+	SyncInterval time.Duration
+	// SnapshotReads, if set, serves read tools from a snapshot while import_commit and
+	// other long operations run under it
+	SnapshotReads *database.SnapshotReads
+	// ReadOnly registers only the tools annotated read-only, so clients can search and
+	// read the graph but not change it
+	ReadOnly bool
+	// CompactJSON encodes every tool result without indentation, which makes large
+	// results noticeably smaller for clients that count tokens
+	CompactJSON bool
 }
 
 type CreateEntitiesParams struct {
-	Entities []database.EntityWithObservations `json:"entities" jsonschema:"description:Array of entities to create"`
+	Entities    []database.EntityWithObservations `json:"entities" jsonschema:"description:Array of entities to create"`
+	OnDuplicate string                            `json:"onDuplicate,omitempty" jsonschema:"description:What to do when an entity already exists: 'skip' (default), 'appendObservations' (add new observations to it) or 'error' (fail the whole batch). When set, the result lists the outcome for every entity"`
+	OnConflict  string                            `json:"onConflict,omitempty" jsonschema:"description:What to do when an entity already exists, instead of onDuplicate: 'skip' (default) leaves it unchanged and 'mergeObservations' adds the observations it doesn't already have. When set, the result lists the created entities, the merged ones with the observations added, and the names of those skipped"`
+	Session     string                            `json:"session,omitempty" jsonschema:"description:Label recorded on everything this call creates, so rollback_session can undo it, e.g. a task or conversation ID"`
 }
 
 type CreateRelationsParams struct {
 	Relations []database.RelationDTO `json:"relations" jsonschema:"description:Array of relations to create"`
+	Session   string                 `json:"session,omitempty" jsonschema:"description:Label recorded on the created relations, so rollback_session can undo them"`
+	Strict    bool                   `json:"strict,omitempty" jsonschema:"description:Fail the whole call, creating nothing, when any relation names an entity that doesn't exist. Unset skips such relations and lists them in skipped"`
+	// UpdateExisting sets the confidence and note of relations that already exist
+	UpdateExisting bool `json:"updateExisting,omitempty" jsonschema:"description:For a relation that already exists, set the confidence and note given, keeping any not given, and list it in updated instead of skipped"`
 }
 
 type AddObservationsParams struct {
-	Observations []ObservationInput `json:"observations" jsonschema:"description:Array of observations to add"`
+	Observations    []ObservationInput `json:"observations" jsonschema:"description:Array of observations to add"`
+	IfAbsentSimilar float64            `json:"ifAbsentSimilar,omitempty" jsonschema:"description:Skip an observation when the entity already has one at least this similar (0 to 1, e.g. 0.9; word-set similarity ignoring case, punctuation and word order). Skipped observations are reported in skippedAsSimilar with the existing match. Unset adds every new observation"`
+	Session         string             `json:"session,omitempty" jsonschema:"description:Label recorded on the added observations, so rollback_session can undo them"`
 }
 
 type ObservationInput struct {
 	EntityName string   `json:"entityName" jsonschema:"description:Name of the entity"`
 	Contents   []string `json:"contents" jsonschema:"description:Array of observations to add"`
+	ExpiresAt  string   `json:"expiresAt,omitempty" jsonschema:"description:When the observations added expire (RFC 3339, e.g. 2025-01-31T09:00:00Z), for short-lived facts; expired observations are hidden from reads and later deleted"`
+	TTLSeconds int      `json:"ttlSeconds,omitempty" jsonschema:"description:Seconds until the observations added expire, instead of expiresAt"`
+}
+
+// createdEntities is the structured result of create_entities. Structured results
+// are objects, so it wraps the array the text holds, as do entityOutcomes and
+// observationAdditions.
+type createdEntities struct {
+	Entities []database.EntityWithObservations `json:"entities"`
+}
+
+// entityOutcomes is the structured result of create_entities with onDuplicate set
+type entityOutcomes struct {
+	Results []database.EntityCreateResult `json:"results"`
+}
+
+// entityConflicts is the result of create_entities with onConflict set. Merged
+// lists, for each existing entity merged into, the observations actually added.
+type entityConflicts struct {
+	Created []database.EntityCreateResult `json:"created"`
+	Merged  []database.EntityCreateResult `json:"merged"`
+	Skipped []string                      `json:"skipped"`
+}
+
+// observationAdditions is the structured result of add_observations
+type observationAdditions struct {
+	Results []database.ObservationAdditionResult `json:"results"`
 }
 
 type DeleteEntitiesParams struct {
-	EntityNames []string `json:"entityNames" jsonschema:"description:Array of entity names to delete"`
+	EntityNames []EntityDeletion `json:"entityNames" jsonschema:"description:Entities to delete: names, or objects {name, reassignRelationsTo} to hand the entity's relations to a successor first"`
+	DryRun      bool             `json:"dryRun,omitempty" jsonschema:"description:Return the entities, number of observations and relations that would be deleted, and the reassignments that would be made, without changing anything"`
+	Force       bool             `json:"force,omitempty" jsonschema:"description:Delete pinned entities too; without it they are kept and listed in protected"`
+}
+
+// EntityDeletion is an item of delete_entities, given as a plain name or as an
+// object naming a successor
+type EntityDeletion struct {
+	Name                string `json:"name" jsonschema:"description:Entity to delete"`
+	ReassignRelationsTo string `json:"reassignRelationsTo,omitempty" jsonschema:"description:Existing entity that takes over the relations, e.g. the replacement of an obsolete entity. Relations duplicating one it already has are dropped"`
 }
 
 type DeleteObservationsParams struct {
 	Deletions []DeletionInput `json:"deletions" jsonschema:"description:Array of deletions to perform"`
+	DryRun    bool            `json:"dryRun,omitempty" jsonschema:"description:Return the number of observations that would be deleted without changing anything"`
 }
 
 type DeletionInput struct {
@@ -49,271 +162,1438 @@ type DeletionInput struct {
 
 type DeleteRelationsParams struct {
 	Relations []database.RelationDTO `json:"relations" jsonschema:"description:Array of relations to delete"`
+	DryRun    bool                   `json:"dryRun,omitempty" jsonschema:"description:Return the relations that exist and would be deleted without changing anything"`
+}
+
+type ReadGraphParams struct {
+	IncludeTimestamps bool   `json:"includeTimestamps,omitempty" jsonschema:"description:Add createdAt and updatedAt to each entity, observationsCreatedAt aligned with its observations, and createdAt to each relation (RFC 3339)"`
+	Limit             int    `json:"limit,omitempty" jsonschema:"description:Return one page of at most this many entities, by name, with only the relations among them (max 1000; default 100 when cursor is set). Omit both limit and cursor for the whole graph"`
+	Cursor            string `json:"cursor,omitempty" jsonschema:"description:nextCursor from the previous page"`
+	IncludeAliases    bool   `json:"includeAliases,omitempty" jsonschema:"description:Add aliases to each entity: the other names it is known by, see add_alias"`
+	Detail            string `json:"detail,omitempty" jsonschema:"description:'full' (default) returns each entity with its observations; 'summary' returns only its name, entityType and observationCount, with the relations, for a much smaller result"`
+	Compact           bool   `json:"compact,omitempty" jsonschema:"description:Return the JSON without indentation, which is smaller to read; the server may be configured to always do so"`
 }
 
 type SearchNodesParams struct {
-	Query string `json:"query" jsonschema:"description:Search query. Examples: 'word1 word2' (finds any), '\"exact phrase\"' (phrase match), 'word1 AND word2' (requires both), '+must -not' (include/exclude)"`
+	Query             string   `json:"query" jsonschema:"description:Search query. Examples: 'word1 word2' (finds any), '\"exact phrase\"' (phrase match), 'word1 AND word2' (requires both), '+must -not' (include/exclude)"`
+	IncludeTimestamps bool     `json:"includeTimestamps,omitempty" jsonschema:"description:Add createdAt and updatedAt to each entity, observationsCreatedAt aligned with its observations, and createdAt to each relation (RFC 3339)"`
+	Limit             int      `json:"limit,omitempty" jsonschema:"description:Return at most this many matching entities, by name, with totalMatches and nextOffset (max 200; default 50 when offset is set). Omit both limit and offset for every match"`
+	Offset            int      `json:"offset,omitempty" jsonschema:"description:Number of matching entities to skip"`
+	Mode              string   `json:"mode,omitempty" jsonschema:"description:'substring' (default) searches names, types and observations for the query's words; 'exact' finds entities whose name or type is exactly the query (case sensitive); 'prefix' finds entities whose name or type starts with the query"`
+	Syntax            string   `json:"syntax,omitempty" jsonschema:"description:'plain' (default) escapes the query as described; 'fts5' passes it to SQLite FTS5 as written, e.g. 'project AND (golang OR rust) NOT archived', and rejects expressions FTS5 can't parse. Needs FTS5 and the substring mode"`
+	Ranked            bool     `json:"ranked,omitempty" jsonschema:"description:Order plain substring searches by relevance and give each entity a score: 1 when its name or type matches, 0.5 when only an observation does. Without FTS5 the ordinary search runs and scores are left out"`
+	IncludeSnippets   bool     `json:"includeSnippets,omitempty" jsonschema:"description:Add matches to each entity: with FTS5, fragments of the observations that matched, with the matched terms in **bold**; otherwise the observations containing a query word. Left out for entities that only matched by name or type, and in exact and prefix modes"`
+	Tags              []string `json:"tags,omitempty" jsonschema:"description:Only return entities carrying every one of these tags"`
+	SearchAttributes  bool     `json:"searchAttributes,omitempty" jsonschema:"description:Also match entities whose attributes hold every word of the query in a string or number value, e.g. an external ID. Needs the substring mode and plain syntax"`
+	IncludeAliases    bool     `json:"includeAliases,omitempty" jsonschema:"description:Add aliases to each entity: the other names it is known by, see add_alias"`
+	Detail            string   `json:"detail,omitempty" jsonschema:"description:'full' (default) returns each entity with its observations; 'summary' returns only its name, entityType and observationCount, with the relations, for a much smaller result"`
+	Compact           bool     `json:"compact,omitempty" jsonschema:"description:Return the JSON without indentation, which is smaller to read; the server may be configured to always do so"`
 }
 
 type OpenNodesParams struct {
-	Names []string `json:"names" jsonschema:"description:Array of entity names to retrieve"`
+	Names                    []string `json:"names" jsonschema:"description:Array of entity names to retrieve"`
+	IncludeMetadata          bool     `json:"includeMetadata,omitempty" jsonschema:"description:Add each entity's writer metadata: the number of distinct clients that wrote its observations, the last writer and the last write time"`
+	IncludeTimestamps        bool     `json:"includeTimestamps,omitempty" jsonschema:"description:Add createdAt and updatedAt to each entity, observationsCreatedAt aligned with its observations, and createdAt to each relation (RFC 3339)"`
+	Tags                     []string `json:"tags,omitempty" jsonschema:"description:Only return the named entities carrying every one of these tags"`
+	IncludeExternalRelations string   `json:"includeExternalRelations,omitempty" jsonschema:"description:Also return the relations between the named entities and others: 'incoming' (pointing to them), 'outgoing' (from them) or 'both'. Default 'none', only the relations among them"`
+	IncludeAliases           bool     `json:"includeAliases,omitempty" jsonschema:"description:Add aliases to each entity: the other names it is known by, see add_alias"`
+	Detail                   string   `json:"detail,omitempty" jsonschema:"description:'full' (default) returns each entity with its observations; 'summary' returns only its name, entityType and observationCount, with the relations, for a much smaller result"`
+	Compact                  bool     `json:"compact,omitempty" jsonschema:"description:Return the JSON without indentation, which is smaller to read; the server may be configured to always do so"`
+}
+
+type GetEntityParams struct {
+	Name string `json:"name" jsonschema:"description:Name of the entity"`
+}
+
+// entityLookup is the result of get_entity; Entity is nil when Found is false
+type entityLookup struct {
+	Name   string                 `json:"name"`
+	Found  bool                   `json:"found"`
+	Entity *database.EntityDetail `json:"entity,omitempty"`
+}
+
+type RecentEntitiesParams struct {
+	Limit int `json:"limit,omitempty" jsonschema:"description:Number of entities to return (default 10, max 100)"`
+}
+
+// entityWithMetadata is an open_nodes entity with includeMetadata set
+type entityWithMetadata struct {
+	database.EntityWithObservations
+	Metadata database.EntityMetadata `json:"metadata"`
+}
+
+// nodesWithMetadata is the result of open_nodes with includeMetadata set
+type nodesWithMetadata struct {
+	Entities  []entityWithMetadata   `json:"entities"`
+	Relations []database.RelationDTO `json:"relations"`
+}
+
+// recentEntities is the result of recent_entities
+type recentEntities struct {
+	Entities []database.EntityWithObservations `json:"entities"`
+}
+
+type GetObservationsParams struct {
+	EntityName string `json:"entityName" jsonschema:"description:Name of the entity"`
+	Limit      int    `json:"limit,omitempty" jsonschema:"description:Maximum observations to return (default 100, max 1000)"`
+	Offset     int    `json:"offset,omitempty" jsonschema:"description:Number of observations to skip"`
+	OrderBy    string `json:"orderBy,omitempty" jsonschema:"description:'oldest' (default) or 'newest'"`
+}
+
+type GetRelationsParams struct {
+	EntityName   string `json:"entityName" jsonschema:"description:Name of the entity"`
+	RelationType string `json:"relationType,omitempty" jsonschema:"description:Only return relations of this type"`
+	Limit        int    `json:"limit,omitempty" jsonschema:"description:Maximum relations to return (default 100, max 1000)"`
+	Offset       int    `json:"offset,omitempty" jsonschema:"description:Number of relations to skip"`
+}
+
+// FindRelationsParams are the parameters of get_relations; empty filters match any relation
+type FindRelationsParams struct {
+	From         string `json:"from,omitempty" jsonschema:"description:Only return relations from this entity"`
+	To           string `json:"to,omitempty" jsonschema:"description:Only return relations to this entity"`
+	RelationType string `json:"relationType,omitempty" jsonschema:"description:Only return relations of this type"`
+	Limit        int    `json:"limit,omitempty" jsonschema:"description:Maximum relations to return (default 100, max 1000)"`
+	Offset       int    `json:"offset,omitempty" jsonschema:"description:Number of relations to skip"`
+}
+
+type FindPathParams struct {
+	From     string `json:"from" jsonschema:"description:Entity to start from"`
+	To       string `json:"to" jsonschema:"description:Entity to reach"`
+	MaxDepth int    `json:"maxDepth,omitempty" jsonschema:"description:Most relations the path may have (default 6, max 10)"`
+	Directed bool   `json:"directed,omitempty" jsonschema:"description:Only follow relations from their from entity to their to entity (default false: either way)"`
+}
+
+type GetNeighborsParams struct {
+	Names     []string `json:"names" jsonschema:"description:Entities to start from"`
+	Depth     int      `json:"depth,omitempty" jsonschema:"description:Most relations to follow from a start entity (default 1, max 3)"`
+	Direction string   `json:"direction,omitempty" jsonschema:"description:Follow relations out of (out), into (in) or either way from (both, the default) each entity"`
+}
+
+type EraseSubjectParams struct {
+	Names  []string `json:"names" jsonschema:"description:Names and aliases of the subject. Every entity whose name or type contains one, and every observation mentioning one, is erased"`
+	DryRun bool     `json:"dryRun,omitempty" jsonschema:"description:Report what would be erased without changing anything. Run this first"`
+}
+
+type ClearGraphParams struct {
+	Confirm string `json:"confirm" jsonschema:"description:Must be exactly DELETE EVERYTHING"`
+	Force   bool   `json:"force,omitempty" jsonschema:"description:Delete pinned entities too; without it they are kept, with their observations and the relations between them, and listed in protected"`
+}
+
+type MigrateToPolicyParams struct {
+	DryRun bool `json:"dryRun,omitempty" jsonschema:"description:Report the changes without making them. Run this first"`
+}
+
+type ImportChunkParams struct {
+	ImportID string `json:"importId" jsonschema:"description:Import id returned by import_begin"`
+	Sequence int    `json:"sequence" jsonschema:"description:Chunk number, starting at 1 and increasing by one per chunk. Resending the last chunk after a lost response is safe"`
+	Data     string `json:"data" jsonschema:"description:The next bytes of the JSONL file, at most 1 MiB. Chunks may split lines anywhere"`
+	Encoding string `json:"encoding,omitempty" jsonschema:"description:'text' (default) or 'base64'"`
+}
+
+type ImportCommitParams struct {
+	ImportID string `json:"importId" jsonschema:"description:Import id returned by import_begin"`
+}
+
+type ImportAbortParams struct {
+	ImportID string `json:"importId" jsonschema:"description:Import id returned by import_begin"`
+}
+
+type ExportGraphParams struct {
+	Format   string `json:"format,omitempty" jsonschema:"description:'json' (default) for the portable document import_graph reads, or 'dot' (Graphviz) or 'graphml' to view the graph, with entity types as node attributes and relation types as edge labels"`
+	Root     string `json:"root,omitempty" jsonschema:"description:dot and graphml only: export just the entities within depth relations of this one, followed either way"`
+	Depth    int    `json:"depth,omitempty" jsonschema:"description:dot and graphml only: relations to follow from root (default 1, max 3)"`
+	MaxNodes int    `json:"maxNodes,omitempty" jsonschema:"description:dot and graphml only: fail rather than export more entities than this (default 500, max 5000)"`
+}
+
+type ImportGraphParams struct {
+	Document string `json:"document" jsonschema:"description:JSON text of a document export_graph returned, or of a part of it such as some of its entities and relations. At most maxEntitiesPerRequest (see get_capabilities, 1000 by default) entities and as many relations per call; split larger documents"`
+	Strategy string `json:"strategy,omitempty" jsonschema:"description:What to do with entities that already exist: 'merge' (default; add missing observations), 'skip' (leave them untouched) or 'replace' (delete them with their observations and relations and create them from the document)"`
+}
+
+type SetTypeMetadataParams struct {
+	EntityType string            `json:"entityType" jsonschema:"description:Entity type the metadata applies to, e.g. 'incident'. It need not have entities yet"`
+	Metadata   map[string]string `json:"metadata" jsonschema:"description:Keys to set, e.g. {'color': 'red', 'group': 'ops'}. Exporters apply the keys as presentation hints; keys they don't know are kept for other consumers. An empty value removes the key"`
+}
+
+type GetTypeMetadataParams struct {
+	EntityTypes []string `json:"entityTypes,omitempty" jsonschema:"description:Entity types to read. Omit to read every type with metadata"`
+}
+
+type ListEntityTypesParams struct {
+	Prefix string `json:"prefix,omitempty" jsonschema:"description:Only list types starting with this (case-sensitive)"`
+}
+
+// ListRelationTypesParams represents parameters for listing relation types
+type ListRelationTypesParams struct {
+	MinCount int `json:"minCount,omitempty" jsonschema:"description:Only list types used by at least this many relations (default 0 = all)"`
+}
+
+type HygieneReportParams struct {
+	StaleAfterDays int `json:"staleAfterDays,omitempty" jsonschema:"description:Days without writes after which an entity is reported as stale (default 90, max 3650)"`
+}
+
+type RollbackSessionParams struct {
+	Session string `json:"session" jsonschema:"description:Session label to roll back, as passed to the write tools"`
+}
+
+// typeMetadataResult is the metadata of one entity type after set_type_metadata
+type typeMetadataResult struct {
+	EntityType string            `json:"entityType"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// ImportBeginResult tells the client how to send an import
+type ImportBeginResult struct {
+	ImportID      string   `json:"importId"`
+	Format        string   `json:"format"`
+	FormatHelp    string   `json:"formatHelp"`
+	FirstSequence int      `json:"firstSequence"`
+	MaxChunkBytes int      `json:"maxChunkBytes"`
+	Encodings     []string `json:"encodings"`
+	ExpiresAfter  string   `json:"expiresAfter"`
+}
+
+// maxPooledBufferSize bounds the buffers kept for reuse so one huge graph doesn't pin memory
+const maxPooledBufferSize = 1 << 20
+
+// jsonEncoder pairs a reusable buffer with an encoder writing into it
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// newEncoderPool returns a pool of encoders indenting by indent, or writing compact
+// JSON when indent is empty. Encoding into a pooled buffer avoids the separate
+// marshal and indent buffers json.MarshalIndent allocates per call.
+func newEncoderPool(indent string) *sync.Pool {
+	return &sync.Pool{
+		New: func() any {
+			e := &jsonEncoder{}
+			e.enc = json.NewEncoder(&e.buf)
+			if indent != "" {
+				e.enc.SetIndent("", indent)
+			}
+			return e
+		},
+	}
+}
+
+// Encoders reused across tool results
+var (
+	indentedEncoders = newEncoderPool("  ")
+	compactEncoders  = newEncoderPool("")
+)
+
+type compactKey struct{}
+
+// withCompactJSON returns ctx under which tool results are encoded without
+// indentation
+func withCompactJSON(ctx context.Context) context.Context {
+	return context.WithValue(ctx, compactKey{}, true)
+}
+
+// compactContext returns ctx under which tool results are compact when compact is set
+func compactContext(ctx context.Context, compact bool) context.Context {
+	if compact {
+		return withCompactJSON(ctx)
+	}
+	return ctx
+}
+
+// compactJSON reports whether results under ctx are encoded without indentation,
+// because the server is configured so or the request asked for it
+func (s *Server) compactJSON(ctx context.Context) bool {
+	compact, _ := ctx.Value(compactKey{}).(bool)
+	return compact || s.opts.CompactJSON
+}
+
+// encodeJSON returns the indented JSON encoding of v, byte-identical to
+// json.MarshalIndent(v, "", "  "), or with compact its encoding by json.Marshal
+func encodeJSON(v any, compact bool) (string, error) {
+	pool := indentedEncoders
+	if compact {
+		pool = compactEncoders
+	}
+	e := pool.Get().(*jsonEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBufferSize {
+			pool.Put(e)
+		}
+	}()
+
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return "", err
+	}
+	// Encoder terminates each value with a newline; Marshal and MarshalIndent do not
+	return string(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))), nil
+}
+
+// marshalResult encodes v as the text of a successful tool result and returns it
+// as the structured result too. An encoding failure is logged with the tool and
+// value type and reported as an encode_result tool error instead of an empty
+// success, and a result too large for one event of the connection as
+// result_exceeds_event_limit.
+func (s *Server) marshalResult(ctx context.Context, tool string, v any) (*mcp.CallToolResult, any, error) {
+	return s.marshalResultAs(ctx, tool, v, v)
+}
+
+// marshalResultAs is marshalResult for a result whose text is v but whose
+// structured result is out. Structured results are objects, so a v that encodes as
+// an array is wrapped in out.
+func (s *Server) marshalResultAs(ctx context.Context, tool string, v, out any) (*mcp.CallToolResult, any, error) {
+	jsonData, err := encodeJSON(v, s.compactJSON(ctx))
+	if err != nil {
+		return nil, nil, s.encodeError(ctx, tool, v, err)
+	}
+	// The SDK sets the structured content from out; it is set here so the event
+	// limit counts it
+	res, err := checkEventLimit(ctx, &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: jsonData},
+		},
+		StructuredContent: out,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, out, nil
+}
+
+// encodeError logs a failure to encode a tool result and returns its tool error
+func (s *Server) encodeError(ctx context.Context, tool string, v any, err error) error {
+	logging.LoggerWithContext(ctx, s.logger).Error("failed to encode tool result",
+		slog.String("tool", tool),
+		slog.String("type", fmt.Sprintf("%T", v)),
+		slog.String("error", err.Error()),
+	)
+	return operationError(ctx, i18n.ErrEncodeResult, err)
+}
+
+// isCancellation reports whether err was caused by the request context ending,
+// e.g. because the client sent notifications/cancelled
+func isCancellation(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
 // NewServerWithLogger creates a new MCP memory server with a logger
-func NewServerWithLogger(db *database.DB, logger *slog.Logger) *Server {
+func NewServerWithLogger(st store.Store, logger *slog.Logger) *Server {
+	return NewServerWithOptions(st, logger, Options{})
+}
+
+// NewServerWithOptions creates a new MCP memory server with a logger and options.
+// On a store other than *database.DB it registers only the tools in storeTools.
+func NewServerWithOptions(st store.Store, logger *slog.Logger, opts Options) *Server {
 	if logger == nil {
 		logger = slog.Default()
 	}
+	if opts.ResultTTL <= 0 {
+		opts.ResultTTL = DefaultResultTTL
+	}
+	if opts.ResultPageSize <= 0 {
+		opts.ResultPageSize = DefaultResultPageSize
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = DefaultSyncInterval
+	}
+	if opts.Locale = i18n.Match(opts.Locale); opts.Locale == "" {
+		opts.Locale = i18n.DefaultLocale
+	}
+	db, _ := st.(*database.DB)
 	return &Server{
-		db:     db,
-		logger: logger,
+		store:   st,
+		db:      db,
+		logger:  logger,
+		opts:    opts,
+		results: newResultStore(opts.ResultTTL),
+
+		rejections: newRejectionCounter(),
 	}
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.db.Close()
+	return s.store.Close()
 }
 
 // RegisterTools registers all MCP tools with the server
 func (s *Server) RegisterTools(mcpServer *mcp.Server) {
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "create_entities",
-			Description: "Create multiple new entities in the knowledge graph",
+			Name:         "create_entities",
+			Title:        "Create Entities",
+			Description:  "Create multiple new entities in the knowledge graph",
+			OutputSchema: anyOfOutputSchema(outputSchema[createdEntities](), outputSchema[entityOutcomes](), outputSchema[entityConflicts]()),
+			Annotations:  additiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params CreateEntitiesParams) (*mcp.CallToolResult, any, error) {
-			return s.handleCreateEntities(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleCreateEntities(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "create_relations",
-			Description: "Create multiple new relations between entities in the knowledge graph. Relations should be in active voice",
+			Name:         "create_relations",
+			Title:        "Create Relations",
+			Description:  "Create multiple new relations between entities in the knowledge graph. Relations should be in active voice. Relations that already exist or name an entity that doesn't exist are skipped and listed in skipped with the reason (duplicate, missing_from or missing_to); with strict set, a missing entity fails the whole call instead. If any relation breaks a rule listed by get_relation_constraints, none are created and each violation is reported",
+			OutputSchema: outputSchema[database.RelationCreationResult](),
+			Annotations:  additiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params CreateRelationsParams) (*mcp.CallToolResult, any, error) {
-			return s.handleCreateRelations(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleCreateRelations(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "add_observations",
-			Description: "Add new observations to existing entities in the knowledge graph",
+			Name:         "add_observations",
+			Title:        "Add Observations",
+			Description:  "Add new observations to existing entities in the knowledge graph",
+			OutputSchema: outputSchema[observationAdditions](),
+			Annotations:  additiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params AddObservationsParams) (*mcp.CallToolResult, any, error) {
-			return s.handleAddObservations(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleAddObservations(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	s.registerTagTools(mcpServer)
+	s.registerUpdateTools(mcpServer)
+
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "delete_entities",
-			Description: "Delete multiple entities and their associated relations from the knowledge graph",
+			Name:         "delete_entities",
+			Title:        "Delete Entities",
+			Description:  "Delete multiple entities and their associated relations from the knowledge graph. Give an item as {name, reassignRelationsTo} to move the entity's relations to its replacement instead of deleting them. Pinned entities are kept and listed in protected unless force is set. Set dryRun to see what would be deleted first",
+			InputSchema:  deleteEntitiesSchema(),
+			OutputSchema: anyOfOutputSchema(outputSchema[entityDeletions](), outputSchema[deletionPreview]()),
+			Annotations:  destructiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params DeleteEntitiesParams) (*mcp.CallToolResult, any, error) {
-			return s.handleDeleteEntities(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleDeleteEntities(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "delete_observations",
-			Description: "Delete specific observations from entities in the knowledge graph",
+			Name:         "delete_observations",
+			Title:        "Delete Observations",
+			Description:  "Delete specific observations from entities in the knowledge graph. Set dryRun to see how many would be deleted first",
+			OutputSchema: anyOfOutputSchema(outputSchema[database.ObservationDeletionResult](), outputSchema[deletionPreview]()),
+			Annotations:  destructiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params DeleteObservationsParams) (*mcp.CallToolResult, any, error) {
-			return s.handleDeleteObservations(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleDeleteObservations(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "delete_relations",
-			Description: "Delete multiple relations from the knowledge graph",
+			Name:         "delete_relations",
+			Title:        "Delete Relations",
+			Description:  "Delete multiple relations from the knowledge graph. Set dryRun to see which exist and would be deleted first",
+			OutputSchema: anyOfOutputSchema(outputSchema[database.RelationDeletionResult](), outputSchema[deletionPreview]()),
+			Annotations:  destructiveTool(true),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params DeleteRelationsParams) (*mcp.CallToolResult, any, error) {
-			return s.handleDeleteRelations(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleDeleteRelations(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	s.registerTrashTools(mcpServer)
+	s.registerHistoryTools(mcpServer)
+	s.registerSnapshotTools(mcpServer)
+	s.registerOrphanTools(mcpServer)
+	s.registerHotspotTools(mcpServer)
+	s.registerDuplicateTools(mcpServer)
+	s.registerPinTools(mcpServer)
+	s.registerAliasTools(mcpServer)
+
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "read_graph",
-			Description: "Read the entire knowledge graph",
+			Name:         "read_graph",
+			Title:        "Read Graph",
+			Description:  "Read the entire knowledge graph, or one page of it by entity name with limit and cursor. Set detail to 'summary' to orient yourself cheaply: only each entity's name, type and observation count, with the relations",
+			OutputSchema: anyOfOutputSchema(outputSchema[database.GraphPage](), outputSchema[linkedGraph](), outputSchema[summaryGraphPage]()),
+			Annotations:  readOnlyTool(),
 		},
-		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
-			return s.handleReadGraph(ctx)
+		func(ctx context.Context, req *mcp.CallToolRequest, params ReadGraphParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleReadGraph(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "search_nodes",
-			Description: "Search for nodes in the knowledge graph. Default: OR logic (matches any word). Syntax: 'word1 word2' (OR), '\"exact phrase\"' (phrase), 'word1 AND word2' (all words), '+required -excluded' (must have/must not have)",
+			Name:         "search_nodes",
+			Title:        "Search Nodes",
+			Description:  "Search for nodes in the knowledge graph by name, type, observations, aliases and relation types. Default: AND logic (every word matches somewhere on the entity). Syntax: 'word1 word2' (both), '\"exact phrase\"' (phrase), 'word1 OR word2' (either word), '+required -excluded' (must have/must not have). Set mode to 'exact' to look up an entity by its exact name or type, or 'prefix' for names or types starting with the query; those modes take the query literally and don't search observations. Set syntax to 'fts5' to write the query as an FTS5 expression",
+			OutputSchema: anyOfOutputSchema(outputSchema[database.SearchResult](), outputSchema[database.KnowledgeGraph](), outputSchema[linkedGraph](), outputSchema[summarySearchResult](), outputSchema[database.KnowledgeGraphSummary]()),
+			Annotations:  readOnlyTool(),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params SearchNodesParams) (*mcp.CallToolResult, any, error) {
-			return s.handleSearchNodes(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleSearchNodes(ctx, params))
 		},
 	)
 
-	mcp.AddTool(mcpServer,
+	addTool(s, mcpServer,
 		&mcp.Tool{
-			Name:        "open_nodes",
-			Description: "Open specific nodes in the knowledge graph by their names",
+			Name:         "open_nodes",
+			Title:        "Open Nodes",
+			Description:  "Open specific nodes in the knowledge graph by their names",
+			OutputSchema: anyOfOutputSchema(outputSchema[database.KnowledgeGraph](), outputSchema[nodesWithMetadata](), outputSchema[database.KnowledgeGraphSummary]()),
+			Annotations:  readOnlyTool(),
 		},
 		func(ctx context.Context, req *mcp.CallToolRequest, params OpenNodesParams) (*mcp.CallToolResult, any, error) {
-			return s.handleOpenNodes(ctx, params)
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleOpenNodes(ctx, params))
 		},
 	)
-}
 
-func (s *Server) handleCreateEntities(ctx context.Context, params CreateEntitiesParams) (*mcp.CallToolResult, any, error) {
-	logger := logging.LoggerWithContext(ctx, s.logger)
-	start := time.Now()
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_entity",
+			Title:        "Get Entity",
+			Description:  "Get one entity by name with its observations and its incoming and outgoing relations. Reports found: false instead of failing when no entity has the name, so it also checks whether an entity exists",
+			OutputSchema: outputSchema[entityLookup](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetEntityParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetEntity(ctx, params))
+		},
+	)
 
-	logger.Info("handling create_entities request",
-		slog.Int("entity_count", len(params.Entities)),
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "recent_entities",
+			Title:        "Recent Entities",
+			Description:  "List the entities updated most recently, newest first, with their observations and createdAt and updatedAt. An entity is updated when it is created, renamed or retyped, or one of its observations or a relation from or to it is added or deleted, so this answers what was learned or changed lately",
+			OutputSchema: outputSchema[recentEntities](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params RecentEntitiesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleRecentEntities(ctx, params))
+		},
 	)
 
-	// Validate input parameters
-	if err := ValidateCreateEntitiesParams(params); err != nil {
-		logger.Warn("invalid create_entities parameters",
-			slog.String("error", err.Error()),
-		)
-		return nil, nil, fmt.Errorf("validation error: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_observations",
+			Title:        "Get Observations",
+			Description:  "Page through an entity's observations. Use when a read result's totalObservations exceeds the observations returned",
+			OutputSchema: outputSchema[database.ObservationPage](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetObservationsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetObservations(ctx, params))
+		},
+	)
 
-	created, err := s.db.CreateEntities(ctx, params.Entities)
-	if err != nil {
-		logger.Error("failed to create entities",
-			slog.String("error", err.Error()),
-			slog.Duration("duration", time.Since(start)),
-		)
-		return nil, nil, fmt.Errorf("failed to create entities: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_inbound_relations",
+			Title:        "Get Inbound Relations",
+			Description:  "Page through the relations pointing to an entity, optionally of one type, with the name and type of each source entity. Answers \"who relates to X\" without reading the graph",
+			OutputSchema: outputSchema[database.RelationPage](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetRelationsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetRelations(ctx, "get_inbound_relations", database.RelationsInbound, params))
+		},
+	)
 
-	logger.Info("entities created successfully",
-		slog.Int("created", len(created)),
-		slog.Duration("duration", time.Since(start)),
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_outbound_relations",
+			Title:        "Get Outbound Relations",
+			Description:  "Page through the relations from an entity, optionally of one type, with the name and type of each target entity",
+			OutputSchema: outputSchema[database.RelationPage](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetRelationsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetRelations(ctx, "get_outbound_relations", database.RelationsOutbound, params))
+		},
 	)
 
-	jsonData, _ := json.MarshalIndent(created, "", "  ")
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_relations",
+			Title:        "Get Relations",
+			Description:  "Page through the relations of the graph matching any of from, to and relationType, such as every works_at relation or those from one entity to another, ordered by source, target and type",
+			OutputSchema: outputSchema[database.RelationMatches](),
+			Annotations:  readOnlyTool(),
 		},
-	}, nil, nil
-}
+		func(ctx context.Context, req *mcp.CallToolRequest, params FindRelationsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleFindRelations(ctx, params))
+		},
+	)
 
-func (s *Server) handleCreateRelations(ctx context.Context, params CreateRelationsParams) (*mcp.CallToolResult, any, error) {
-	logger := logging.LoggerWithContext(ctx, s.logger)
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "find_path",
+			Title:        "Find Path",
+			Description:  "Find the shortest chain of relations connecting two entities, with the entities along it in order. Relations are followed in either direction, each keeping its own in the path, or only forwards when directed is set",
+			OutputSchema: outputSchema[pathResult](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params FindPathParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleFindPath(ctx, params))
+		},
+	)
 
-	// Validate input parameters
-	if err := ValidateCreateRelationsParams(params); err != nil {
-		logger.Warn("invalid create_relations parameters",
-			slog.String("error", err.Error()),
-		)
-		return nil, nil, fmt.Errorf("validation error: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_neighbors",
+			Title:        "Get Neighbors",
+			Description:  "Get the subgraph around some entities: everything reachable within depth relations (1 to 3), following relations out of, into or either way from each entity, with the relations among them. Use it to explore outward from an entity, which open_nodes doesn't do. Results stop at 500 entities, nearest first, and say when they were truncated",
+			OutputSchema: outputSchema[database.Neighborhood](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetNeighborsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetNeighbors(ctx, params))
+		},
+	)
 
-	created, err := s.db.CreateRelations(ctx, params.Relations)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create relations: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_maintenance_status",
+			Title:        "Get Maintenance Status",
+			Description:  "Show the background maintenance schedule and the last result of each maintenance job",
+			OutputSchema: outputSchema[maintenance.Status](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetMaintenanceStatus(ctx))
+		},
+	)
 
-	jsonData, _ := json.MarshalIndent(created, "", "  ")
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "erase_subject",
+			Title:        "Erase Subject",
+			Description:  "Permanently erase everything mentioning a person: matching entities with their observations and relations, and matching observations on other entities. Deleted data is overwritten on disk and the result includes a scan of every table proving nothing remains. Call with dryRun first to review what will be erased",
+			OutputSchema: outputSchema[database.ErasureReport](),
+			Annotations:  destructiveTool(true),
 		},
-	}, nil, nil
-}
+		func(ctx context.Context, req *mcp.CallToolRequest, params EraseSubjectParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleEraseSubject(ctx, params))
+		},
+	)
 
-func (s *Server) handleAddObservations(ctx context.Context, params AddObservationsParams) (*mcp.CallToolResult, any, error) {
-	logger := logging.LoggerWithContext(ctx, s.logger)
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "clear_graph",
+			Title:        "Clear Graph",
+			Description:  "Permanently delete every entity, observation and relation of the graph, and its trash, to start over with an empty memory. Other graphs are kept. Only runs when confirm is exactly \"DELETE EVERYTHING\"; never call it unless the user explicitly asked to wipe the whole memory. Pinned entities are kept unless force is set",
+			OutputSchema: outputSchema[database.ClearReport](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ClearGraphParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleClearGraph(ctx, params))
+		},
+	)
 
-	// Validate input parameters
-	if err := ValidateAddObservationsParams(params); err != nil {
-		logger.Warn("invalid add_observations parameters",
-			slog.String("error", err.Error()),
-		)
-		return nil, nil, fmt.Errorf("validation error: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "migrate_to_policy",
+			Title:        "Migrate to Policy",
+			Description:  "Rewrite stored data that breaks the active length limits or validation rules: long observations are split into several, and names and types are cleaned and cut to the limit, with a numeric suffix where the name is taken. Values that cannot be fixed automatically are listed as unresolved. Call with dryRun first to review the changes",
+			OutputSchema: outputSchema[policyMigration](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params MigrateToPolicyParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleMigrateToPolicy(ctx, params))
+		},
+	)
 
-	// Convert to the format expected by the database (named type)
-	dbParams := make([]database.ObservationAdditionInput, len(params.Observations))
-	for i, obs := range params.Observations {
-		dbParams[i] = database.ObservationAdditionInput{EntityName: obs.EntityName, Contents: obs.Contents}
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "preview_retention",
+			Title:        "Preview Retention",
+			Description:  "Show the retention policy and what it would remove if maintenance applied it now: per rule, how many expired observations would be purged or archived, on how many entities, and how many are kept because their entity is pinned. Also returns the summary of the last run. Nothing is changed",
+			OutputSchema: outputSchema[retentionPreview](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handlePreviewRetention(ctx))
+		},
+	)
 
-	results, err := s.db.AddObservations(ctx, dbParams)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to add observations: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "import_begin",
+			Title:        "Begin Import",
+			Description:  "Start a chunked import of a JSONL graph too large for one request. Send the file with import_chunk, then finish with import_commit or discard it with import_abort",
+			OutputSchema: outputSchema[ImportBeginResult](),
+			Annotations:  additiveTool(false),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleImportBegin(ctx))
+		},
+	)
 
-	jsonData, _ := json.MarshalIndent(results, "", "  ")
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "import_chunk",
+			Title:        "Send Import Chunk",
+			Description:  "Send the next chunk of an import started with import_begin. Chunks must be sent in sequence order; lines are staged and nothing is visible until import_commit",
+			OutputSchema: outputSchema[database.ImportChunkResult](),
+			Annotations:  additiveTool(true),
 		},
-	}, nil, nil
-}
+		func(ctx context.Context, req *mcp.CallToolRequest, params ImportChunkParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleImportChunk(ctx, params))
+		},
+	)
 
-func (s *Server) handleDeleteEntities(ctx context.Context, params DeleteEntitiesParams) (*mcp.CallToolResult, any, error) {
-	if err := s.db.DeleteEntities(ctx, params.EntityNames); err != nil {
-		return nil, nil, fmt.Errorf("failed to delete entities: %w", err)
-	}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "import_commit",
+			Title:        "Commit Import",
+			Description:  "Merge all staged chunks of an import into the knowledge graph in one transaction and return a summary",
+			OutputSchema: outputSchema[database.ImportSummary](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ImportCommitParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleImportCommit(ctx, params))
+		},
+	)
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: "Entities deleted successfully"},
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:        "import_abort",
+			Title:       "Abort Import",
+			Description: "Discard an import and everything staged for it",
+			Annotations: destructiveTool(true),
 		},
-	}, nil, nil
-}
+		func(ctx context.Context, req *mcp.CallToolRequest, params ImportAbortParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleImportAbort(ctx, params))
+		},
+	)
 
-func (s *Server) handleDeleteObservations(ctx context.Context, params DeleteObservationsParams) (*mcp.CallToolResult, any, error) {
-	// Convert to the format expected by the database (named type)
-	dbParams := make([]database.ObservationDeletionInput, len(params.Deletions))
-	for i, del := range params.Deletions {
-		dbParams[i] = database.ObservationDeletionInput{EntityName: del.EntityName, Observations: del.Observations}
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:        "export_graph",
+			Title:       "Export Graph",
+			Description: "Export the whole graph as one portable JSON document, {version, exportedAt, entities: [{name, type, observations, createdAt}], relations: [{from, to, relationType}]}, e.g. to back it up or move it to another server. The result is as large as the graph; check graph_stats first. With format 'dot' or 'graphml', export it, or the part around a root entity, for a graph viewer instead",
+			Annotations: readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ExportGraphParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleExportGraph(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "import_graph",
+			Title:        "Import Graph",
+			Description:  "Import a document export_graph returned, or part of one, in one transaction. strategy decides what happens to entities that already exist. Relations are added once and may name entities created later in the same document. Returns counts of entities created, merged, skipped and replaced and of observations and relations added",
+			OutputSchema: outputSchema[database.ImportReport](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ImportGraphParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleImportGraph(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "set_type_metadata",
+			Title:        "Set Type Metadata",
+			Description:  "Set key-value metadata for an entity type, such as presentation hints ('color', 'group') that graph exports apply to every entity of the type. Keys not given are kept; an empty value removes a key",
+			OutputSchema: outputSchema[typeMetadataResult](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params SetTypeMetadataParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleSetTypeMetadata(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_entity_types",
+			Title:        "List Entity Types",
+			Description:  "List the entity types in use with how many entities have each, most used first, optionally only those starting with a prefix. Use it to see what kinds of things are stored, and to reuse existing types, without reading the graph",
+			OutputSchema: outputSchema[entityTypeList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ListEntityTypesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListEntityTypes(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_relation_types",
+			Title:        "List Relation Types",
+			Description:  "List the relation types in use with how many relations have each, most used first, optionally only those used at least minCount times. Use it to reuse existing relation types instead of inventing near-duplicates",
+			OutputSchema: outputSchema[relationTypeList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params ListRelationTypesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListRelationTypes(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_type_metadata",
+			Title:        "Get Type Metadata",
+			Description:  "Read the metadata set for entity types with set_type_metadata",
+			OutputSchema: outputSchema[database.TypeMetadata](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params GetTypeMetadataParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetTypeMetadata(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_relation_constraints",
+			Title:        "Get Relation Constraints",
+			Description:  "List the structural rules create_relations enforces per relation type: whether an entity may relate to itself, and the most relations of the type per entity in each direction. Types not listed are unrestricted",
+			OutputSchema: outputSchema[relationConstraints](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetRelationConstraints(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_sessions",
+			Title:        "List Sessions",
+			Description:  "List the session labels passed to create_entities, create_relations and add_observations, with how many entities, observations and relations each still has and when they were written, most recent first",
+			OutputSchema: outputSchema[sessionList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListSessions(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_graphs",
+			Title:        "List Graphs",
+			Description:  "List the named graphs, with how many entities, observations and relations each holds. Pass a graph's name as the graph argument of the other tools to work on it",
+			OutputSchema: outputSchema[graphList](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListGraphs(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "rollback_session",
+			Title:        "Roll Back Session",
+			Description:  "Undo a session: delete the entities created under the label with all their observations and relations, and the observations and relations it added to other entities, which are kept. Cannot be undone",
+			OutputSchema: outputSchema[database.SessionRollback](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params RollbackSessionParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleRollbackSession(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "sync_memory",
+			Title:        "Sync Memory to Disk",
+			Description:  "Make every write so far durable against power loss: checkpoint the write-ahead log into the database file and flush both to disk. Call it right before persisting state of your own that depends on memory writes. Rate-limited",
+			OutputSchema: outputSchema[database.SyncResult](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleSyncMemory(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "memory_hygiene_report",
+			Title:        "Memory Hygiene Report",
+			Description:  "Check the graph for problems to clean up: entities without observations or recent writes, likely duplicate names, entities near the observation limit, relation types used once and full-text index drift. Read-only; each finding suggests follow-up tool calls",
+			OutputSchema: outputSchema[hygieneReport](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params HygieneReportParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleHygieneReport(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_validation_stats",
+			Title:        "Get Validation Stats",
+			Description:  "Count the tool calls rejected by input validation since the server started, by rule, to show which limits requests run into",
+			OutputSchema: outputSchema[ValidationStats](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetValidationStats(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "check_integrity",
+			Title:        "Check Integrity",
+			Description:  "Check that the database is healthy, e.g. after an unclean shutdown: runs SQLite's integrity and foreign key checks and compares the full-text indexes with the data. Reads the whole database, so it is slow on a large one",
+			OutputSchema: outputSchema[database.IntegrityReport](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleCheckIntegrity(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "graph_stats",
+			Title:        "Graph Stats",
+			Description:  "Count the entities, relations, observations and distinct entity and relation types of the graph, and report whether full-text search is enabled, the size in bytes of the database all graphs share, and the tool calls validation has rejected since the server started, by rule. Cheap to call; use it to judge whether read_graph is small enough to call",
+			OutputSchema: outputSchema[graphStats](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGraphStats(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "get_capabilities",
+			Title:        "Get Capabilities",
+			Description:  "Show which optional features and limits this server supports, such as full-text search and the maximum entities per request",
+			OutputSchema: outputSchema[Capabilities](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleGetCapabilities(ctx))
+		},
+	)
+
+	s.registerResultResources(mcpServer)
+}
+
+// readOnlyTool annotates a tool that doesn't change the graph
+func readOnlyTool() *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: hint(false)}
+}
+
+// additiveTool annotates a tool that adds to the graph without deleting or
+// overwriting anything
+func additiveTool(idempotent bool) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{DestructiveHint: hint(false), IdempotentHint: idempotent, OpenWorldHint: hint(false)}
+}
+
+// destructiveTool annotates a tool that may delete or overwrite data. Clients may
+// ask the user to confirm calls to it.
+func destructiveTool(idempotent bool) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{DestructiveHint: hint(true), IdempotentHint: idempotent, OpenWorldHint: hint(false)}
+}
+
+// hint returns a pointer to an optional annotation value
+func hint(v bool) *bool {
+	return &v
+}
+
+// addTool adds a tool to mcpServer and records it for the enabledTools capability,
+// skipping tools beyond storeTools when the server doesn't run on SQLite and tools
+// that aren't read-only in read-only mode
+func addTool[In any](s *Server, mcpServer *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, any]) {
+	if s.db == nil && !storeTools[tool.Name] {
+		return
+	}
+	if s.opts.ReadOnly && !tool.Annotations.ReadOnlyHint {
+		return
+	}
+	s.toolsMu.Lock()
+	s.tools = append(s.tools, tool.Name)
+	s.toolsMu.Unlock()
+	tool, raw := mcp.ToolFor(tool, handler)
+	mcpServer.AddTool(withGraphParameter(tool), s.graphHandler(tool.Name, raw))
+}
+
+func (s *Server) handleCreateEntities(ctx context.Context, params CreateEntitiesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	logger.Info("handling create_entities request",
+		slog.Int("entity_count", len(params.Entities)),
+	)
+
+	// Validate input parameters
+	if err := ValidateCreateEntitiesParams(params); err != nil {
+		logger.Warn("invalid create_entities parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	ctx = withSession(ctx, params.Session)
+	tagged, attributed := false, false
+	for _, entity := range params.Entities {
+		tagged = tagged || len(entity.Tags) > 0
+		attributed = attributed || len(entity.Attributes) > 0
+	}
+	if err := s.needsSQLite(ctx, sqliteOption{"tags", tagged}, sqliteOption{"attributes", attributed}); err != nil {
+		return nil, nil, err
+	}
+
+	if params.OnDuplicate != "" {
+		if err := s.needsSQLite(ctx, sqliteOption{"onDuplicate", true}); err != nil {
+			return nil, nil, err
+		}
+		return s.createEntitiesWithMode(ctx, logger, start, params)
+	}
+	if params.OnConflict != "" {
+		if err := s.needsSQLite(ctx, sqliteOption{"onConflict", true}); err != nil {
+			return nil, nil, err
+		}
+		return s.createEntitiesOnConflict(ctx, logger, start, params)
+	}
+
+	created, err := s.store.CreateEntities(ctx, params.Entities)
+	if err != nil && isCancellation(err) {
+		logger.Info("create_entities cancelled",
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrCreateEntities, err)
+	}
+	if err != nil {
+		logger.Error("failed to create entities",
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrCreateEntities, err)
+	}
+
+	logger.Info("entities created successfully",
+		slog.Int("created", len(created)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResultAs(ctx, "create_entities", created, &createdEntities{Entities: created})
+}
+
+// createEntitiesWithMode handles create_entities with an explicit onDuplicate mode,
+// returning the outcome for every entity rather than just the created ones
+func (s *Server) createEntitiesWithMode(ctx context.Context, logger *slog.Logger, start time.Time, params CreateEntitiesParams) (*mcp.CallToolResult, any, error) {
+	results, err := s.db.CreateEntitiesWithMode(ctx, params.Entities, params.OnDuplicate)
+	if err != nil {
+		logger.Warn("failed to create entities",
+			slog.String("on_duplicate", params.OnDuplicate),
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrCreateEntities, err)
+	}
+
+	logger.Info("entities created successfully",
+		slog.Int("results", len(results)),
+		slog.String("on_duplicate", params.OnDuplicate),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResultAs(ctx, "create_entities", results, &entityOutcomes{Results: results})
+}
+
+// createEntitiesOnConflict handles create_entities with onConflict set, sorting the
+// outcomes into the entities created, merged into and skipped
+func (s *Server) createEntitiesOnConflict(ctx context.Context, logger *slog.Logger, start time.Time, params CreateEntitiesParams) (*mcp.CallToolResult, any, error) {
+	results, err := s.db.CreateEntitiesWithMode(ctx, params.Entities, params.OnConflict)
+	if err != nil {
+		logger.Warn("failed to create entities",
+			slog.String("on_conflict", params.OnConflict),
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrCreateEntities, err)
+	}
+
+	out := &entityConflicts{
+		Created: []database.EntityCreateResult{},
+		Merged:  []database.EntityCreateResult{},
+		Skipped: []string{},
+	}
+	for _, result := range results {
+		switch result.Outcome {
+		case database.OutcomeCreated:
+			out.Created = append(out.Created, result)
+		case database.OutcomeObservationsAppended:
+			out.Merged = append(out.Merged, result)
+		default:
+			out.Skipped = append(out.Skipped, result.Name)
+		}
+	}
+
+	logger.Info("entities created successfully",
+		slog.Int("created", len(out.Created)),
+		slog.Int("merged", len(out.Merged)),
+		slog.Int("skipped", len(out.Skipped)),
+		slog.String("on_conflict", params.OnConflict),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResult(ctx, "create_entities", out)
+}
+
+func (s *Server) handleCreateRelations(ctx context.Context, params CreateRelationsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	// Validate input parameters
+	if err := ValidateCreateRelationsParams(params); err != nil {
+		logger.Warn("invalid create_relations parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	confident, noted := false, false
+	for _, rel := range params.Relations {
+		confident = confident || rel.Confidence != 0
+		noted = noted || rel.Note != ""
+	}
+	if err := s.needsSQLite(ctx,
+		sqliteOption{"strict", params.Strict},
+		sqliteOption{"updateExisting", params.UpdateExisting},
+		sqliteOption{"confidence", confident},
+		sqliteOption{"note", noted},
+	); err != nil {
+		return nil, nil, err
+	}
+	ctx = withSession(ctx, params.Session)
+
+	var result *database.RelationCreationResult
+	var err error
+	if params.Strict || params.UpdateExisting {
+		result, err = s.db.CreateRelationsWithOptions(ctx, params.Relations, database.RelationOptions{
+			Strict:         params.Strict,
+			UpdateExisting: params.UpdateExisting,
+		})
+	} else {
+		result, err = s.store.CreateRelations(ctx, params.Relations)
+	}
+	if err != nil {
+		return nil, nil, constraintError(ctx, err)
+	}
+	if len(result.Skipped) > 0 {
+		logger.Info("relations skipped",
+			slog.Int("created", len(result.Relations)),
+			slog.Int("skipped", len(result.Skipped)),
+		)
+	}
+
+	return s.marshalResult(ctx, "create_relations", result)
+}
+
+func (s *Server) handleAddObservations(ctx context.Context, params AddObservationsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	logger.Debug("handling add_observations request",
+		slog.Int("entity_count", len(params.Observations)),
+		slog.Float64("if_absent_similar", params.IfAbsentSimilar),
+	)
+	for _, obs := range params.Observations {
+		logger.Debug("adding observations",
+			slog.String("entity", obs.EntityName),
+			slog.Int("count", len(obs.Contents)),
+		)
+	}
+
+	// Validate input parameters
+	if err := ValidateAddObservationsParams(params); err != nil {
+		logger.Warn("invalid add_observations parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	if err := s.needsSQLite(ctx, sqliteOption{"ifAbsentSimilar", params.IfAbsentSimilar > 0}); err != nil {
+		return nil, nil, err
+	}
+	for _, obs := range params.Observations {
+		if err := s.needsSQLite(ctx, sqliteOption{"expiresAt", obs.ExpiresAt != ""}, sqliteOption{"ttlSeconds", obs.TTLSeconds > 0}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Convert to the format expected by the database (named type)
+	now := time.Now()
+	dbParams := make([]database.ObservationAdditionInput, len(params.Observations))
+	for i, obs := range params.Observations {
+		dbParams[i] = database.ObservationAdditionInput{EntityName: obs.EntityName, Contents: obs.Contents, ExpiresAt: observationExpiry(obs, now)}
+	}
+
+	var results []database.ObservationAdditionResult
+	var err error
+	if s.db != nil {
+		results, err = s.db.AddObservationsIfAbsentSimilar(withSession(ctx, params.Session), dbParams, params.IfAbsentSimilar)
+	} else {
+		results, err = s.store.AddObservations(ctx, dbParams)
+	}
+	if err != nil && isCancellation(err) {
+		logger.Info("add_observations cancelled",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrAddObservations, err)
+	}
+	var notFound *database.EntityNotFoundError
+	if errors.As(err, &notFound) {
+		logger.Warn("add_observations names a missing entity",
+			slog.String("entity", notFound.Name),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrAddObservations, err)
+	}
+	var capErr *database.ObservationCapError
+	if errors.As(err, &capErr) {
+		logger.Warn("add_observations exceeds the observation cap",
+			slog.String("entity", capErr.Entity),
+			slog.Int("count", capErr.Count),
+			slog.Int("cap", capErr.Cap),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrAddObservations, err)
+	}
+	if err != nil {
+		logger.Error("failed to add observations",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrAddObservations, err)
+	}
+
+	return s.marshalResultAs(ctx, "add_observations", results, &observationAdditions{Results: results})
+}
+
+func (s *Server) handleDeleteEntities(ctx context.Context, params DeleteEntitiesParams) (*mcp.CallToolResult, any, error) {
+	if err := ValidateDeleteEntitiesParams(params); err != nil {
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	for _, item := range params.EntityNames {
+		if err := s.needsSQLite(ctx, sqliteOption{"reassignRelationsTo", item.ReassignRelationsTo != ""}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.needsSQLite(ctx, sqliteOption{"force", params.Force}); err != nil {
+		return nil, nil, err
+	}
+	ctx = forceContext(ctx, params.Force)
+	if params.DryRun {
+		if err := s.needsSQLite(ctx, sqliteOption{"dryRun", true}); err != nil {
+			return nil, nil, err
+		}
+		return s.previewDeleteEntities(ctx, params)
+	}
+
+	// Items run in order, each reassignment in its own transaction; runs of plain
+	// names are deleted together
+	result := entityDeletions{EntityDeletionResult: *database.NewEntityDeletionResult(nil, nil)}
+	var names []string
+	deleteNames := func() error {
+		if len(names) == 0 {
+			return nil
+		}
+		deleted, err := s.store.DeleteEntities(ctx, names)
+		names = nil
+		if err != nil {
+			return err
+		}
+		result.DeletedEntities += deleted.DeletedEntities
+		result.NotFound = append(result.NotFound, deleted.NotFound...)
+		result.Protected = append(result.Protected, deleted.Protected...)
+		return nil
+	}
+	for _, item := range params.EntityNames {
+		if item.ReassignRelationsTo == "" {
+			names = append(names, item.Name)
+			continue
+		}
+		if err := deleteNames(); err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrDeleteEntities, err)
+		}
+		reassignment, err := s.db.DeleteEntityReassigning(ctx, item.Name, item.ReassignRelationsTo)
+		if err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrDeleteEntities, err)
+		}
+		result.Reassigned = append(result.Reassigned, reassignment)
+		switch {
+		case reassignment.Deleted:
+			result.DeletedEntities++
+		case reassignment.Protected:
+			result.Protected = append(result.Protected, item.Name)
+		default:
+			result.NotFound = append(result.NotFound, item.Name)
+		}
+	}
+	if err := deleteNames(); err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrDeleteEntities, err)
+	}
+
+	return s.deletionResult(ctx, "delete_entities", result, i18n.MsgEntitiesDeleted)
+}
+
+func (s *Server) handleDeleteObservations(ctx context.Context, params DeleteObservationsParams) (*mcp.CallToolResult, any, error) {
+	// Convert to the format expected by the database (named type)
+	dbParams := make([]database.ObservationDeletionInput, len(params.Deletions))
+	for i, del := range params.Deletions {
+		dbParams[i] = database.ObservationDeletionInput{EntityName: del.EntityName, Observations: del.Observations}
+	}
+	if params.DryRun {
+		if err := s.needsSQLite(ctx, sqliteOption{"dryRun", true}); err != nil {
+			return nil, nil, err
+		}
+		preview, err := s.db.PreviewDeleteObservations(ctx, dbParams)
+		if err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrDeleteObservations, err)
+		}
+		return s.marshalResult(ctx, "delete_observations", deletionPreview{DryRun: true, DeletionPreview: *preview})
+	}
+
+	result, err := s.store.DeleteObservations(ctx, dbParams)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrDeleteObservations, err)
+	}
+
+	return s.deletionResult(ctx, "delete_observations", result, i18n.MsgObservationsDeleted)
+}
+
+func (s *Server) handleDeleteRelations(ctx context.Context, params DeleteRelationsParams) (*mcp.CallToolResult, any, error) {
+	if params.DryRun {
+		if err := s.needsSQLite(ctx, sqliteOption{"dryRun", true}); err != nil {
+			return nil, nil, err
+		}
+		preview, err := s.db.PreviewDeleteRelations(ctx, params.Relations)
+		if err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrDeleteRelations, err)
+		}
+		return s.marshalResult(ctx, "delete_relations", deletionPreview{DryRun: true, DeletionPreview: *preview})
+	}
+	result, err := s.store.DeleteRelations(ctx, params.Relations)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrDeleteRelations, err)
+	}
+
+	return s.deletionResult(ctx, "delete_relations", result, i18n.MsgRelationsDeleted)
+}
+
+func (s *Server) handleReadGraph(ctx context.Context, params ReadGraphParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateReadGraphParams(params); err != nil {
+		logger.Warn("invalid read_graph parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	if err := s.needsSQLite(ctx,
+		sqliteOption{"limit", params.Limit != 0},
+		sqliteOption{"cursor", params.Cursor != ""},
+		sqliteOption{"includeTimestamps", params.IncludeTimestamps},
+		sqliteOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return nil, nil, err
 	}
+	ctx = compactContext(detailContext(ctx, params.Detail), params.Compact)
+	if params.Limit != 0 || params.Cursor != "" {
+		return s.handleReadGraphPage(ctx, params)
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
 
-	if err := s.db.DeleteObservations(ctx, dbParams); err != nil {
-		return nil, nil, fmt.Errorf("failed to delete observations: %w", err)
+	graph, err := s.readStore(db).ReadGraph(ctx)
+	if err == nil && params.IncludeTimestamps {
+		err = db.AddTimestamps(ctx, graph)
+	}
+	if err == nil && params.IncludeAliases {
+		err = db.AddAliases(ctx, graph)
+	}
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrReadGraph, err)
+	}
+	if params.Detail == DetailSummary {
+		res, out, err := s.marshalResult(ctx, "read_graph", database.Summarize(graph))
+		return markSnapshot(ctx, res, takenAt), out, err
 	}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: "Observations deleted successfully"},
-		},
-	}, nil, nil
+	res, out, err := s.graphResult(ctx, "read_graph", graph)
+	if err != nil {
+		return nil, nil, err
+	}
+	return markSnapshot(ctx, res, takenAt), out, nil
 }
 
-func (s *Server) handleDeleteRelations(ctx context.Context, params DeleteRelationsParams) (*mcp.CallToolResult, any, error) {
-	if err := s.db.DeleteRelations(ctx, params.Relations); err != nil {
-		return nil, nil, fmt.Errorf("failed to delete relations: %w", err)
+// handleReadGraphPage serves read_graph when it is paged
+func (s *Server) handleReadGraphPage(ctx context.Context, params ReadGraphParams) (*mcp.CallToolResult, any, error) {
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultGraphPageSize
 	}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: "Relations deleted successfully"},
-		},
-	}, nil, nil
-}
+	db, takenAt, release := s.reader()
+	defer release()
 
-func (s *Server) handleReadGraph(ctx context.Context) (*mcp.CallToolResult, any, error) {
-	graph, err := s.db.ReadGraph(ctx)
+	page, err := db.ReadGraphPage(ctx, limit, params.Cursor)
+	if err == nil && params.IncludeTimestamps {
+		err = db.AddTimestamps(ctx, &page.KnowledgeGraph)
+	}
+	if err == nil && params.IncludeAliases {
+		err = db.AddAliases(ctx, &page.KnowledgeGraph)
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read graph: %w", err)
+		return nil, nil, operationError(ctx, i18n.ErrReadGraph, err)
+	}
+	if params.Detail == DetailSummary {
+		summary := &summaryGraphPage{KnowledgeGraphSummary: *database.Summarize(&page.KnowledgeGraph), NextCursor: page.NextCursor}
+		res, out, err := s.marshalResult(ctx, "read_graph", summary)
+		return markSnapshot(ctx, res, takenAt), out, err
 	}
 
-	jsonData, _ := json.MarshalIndent(graph, "", "  ")
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
-		},
-	}, nil, nil
+	res, out, err := s.marshalResult(ctx, "read_graph", page)
+	return markSnapshot(ctx, res, takenAt), out, err
 }
 
 func (s *Server) handleSearchNodes(ctx context.Context, params SearchNodesParams) (*mcp.CallToolResult, any, error) {
@@ -323,6 +1603,9 @@ func (s *Server) handleSearchNodes(ctx context.Context, params SearchNodesParams
 	// Use Debug level for high-frequency operations like search
 	logger.Debug("handling search_nodes request",
 		slog.String("query", params.Query),
+		slog.String("mode", params.Mode),
+		slog.String("syntax", params.Syntax),
+		slog.Bool("ranked", params.Ranked),
 	)
 
 	// Validate input parameters
@@ -330,48 +1613,144 @@ func (s *Server) handleSearchNodes(ctx context.Context, params SearchNodesParams
 		logger.Warn("invalid search_nodes parameters",
 			slog.String("error", err.Error()),
 		)
-		return nil, nil, fmt.Errorf("validation error: %w", err)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	if err := s.needsSQLite(ctx,
+		sqliteOption{"mode", params.Mode == database.SearchExact || params.Mode == database.SearchPrefix},
+		sqliteOption{"syntax", params.Syntax == SearchSyntaxFTS5},
+		sqliteOption{"ranked", params.Ranked},
+		sqliteOption{"includeSnippets", params.IncludeSnippets},
+		sqliteOption{"includeTimestamps", params.IncludeTimestamps},
+		sqliteOption{"tags", len(params.Tags) > 0},
+		sqliteOption{"searchAttributes", params.SearchAttributes},
+		sqliteOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return nil, nil, err
+	}
+	if len(params.Tags) > 0 {
+		ctx = database.WithTags(ctx, params.Tags)
+	}
+	if params.SearchAttributes {
+		ctx = database.WithAttributeSearch(ctx, params.Query)
+	}
+	ctx = compactContext(detailContext(ctx, params.Detail), params.Compact)
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	paged := params.Limit != 0 || params.Offset != 0
+	limit := params.Limit
+	if paged && limit == 0 {
+		limit = DefaultSearchPageSize
 	}
 
 	// Try FTS5 search if available, otherwise use LIKE search
-	var graph *database.KnowledgeGraph
+	var result *database.SearchResult
 	var err error
 
-	if s.db.IsFTSEnabled() {
-		graph, err = s.db.SearchNodesFTS(ctx, params.Query)
-		if err != nil {
+	if fts, ok := s.store.(store.FullTextSearcher); db == nil && ok {
+		result, err = fts.SearchNodesFTS(ctx, params.Query, limit, params.Offset)
+		if err != nil && ctx.Err() == nil {
+			logger.Debug("full-text search failed, falling back to substring search",
+				slog.String("error", err.Error()),
+			)
+			result, err = s.store.SearchNodes(ctx, params.Query, limit, params.Offset)
+		}
+	} else if db == nil {
+		result, err = s.store.SearchNodes(ctx, params.Query, limit, params.Offset)
+	} else if params.Mode == database.SearchExact || params.Mode == database.SearchPrefix {
+		// Whole-name lookups bypass FTS, which would stem and tokenize the query
+		result, err = db.MatchNodes(ctx, params.Query, params.Mode, limit, params.Offset)
+	} else if params.Syntax == SearchSyntaxFTS5 {
+		// Raw expressions don't fall back to LIKE, which would read them as words
+		if !db.IsFTSEnabled() {
+			err := i18n.NewError(i18n.ErrFTSUnavailable, SearchSyntaxFTS5)
+			logger.Warn("invalid search_nodes parameters",
+				slog.String("error", err.Error()),
+			)
+			return nil, nil, s.invalidParams(ctx, err)
+		}
+		result, err = db.SearchNodesFTSQuery(ctx, params.Query, limit, params.Offset)
+		var queryErr *database.FTSQueryError
+		if errors.As(err, &queryErr) {
+			logger.Warn("invalid search_nodes parameters",
+				slog.String("error", err.Error()),
+			)
+			return nil, nil, s.invalidParams(ctx, i18n.NewError(i18n.ErrInvalidFTSQuery, queryErr.Err))
+		}
+	} else if db.IsFTSEnabled() {
+		if params.Ranked {
+			result, err = db.SearchNodesRanked(ctx, params.Query, limit, params.Offset)
+		} else {
+			result, err = db.SearchNodesFTS(ctx, params.Query, limit, params.Offset)
+		}
+		if err != nil && ctx.Err() == nil {
 			logger.Debug("FTS5 search failed, falling back to LIKE search",
 				slog.String("error", err.Error()),
 			)
 			// Fallback to regular LIKE-based search
-			graph, err = s.db.SearchNodes(ctx, params.Query)
+			result, err = db.SearchNodes(ctx, params.Query, limit, params.Offset)
 		}
 	} else {
 		// FTS not available, use LIKE search
-		graph, err = s.db.SearchNodes(ctx, params.Query)
+		result, err = db.SearchNodes(ctx, params.Query, limit, params.Offset)
 	}
 
+	if err == nil && params.IncludeTimestamps {
+		err = db.AddTimestamps(ctx, &result.KnowledgeGraph)
+	}
+	if err == nil && params.IncludeAliases {
+		err = db.AddAliases(ctx, &result.KnowledgeGraph)
+	}
+	// Exact and prefix searches don't look at observations, so nothing in them matched
+	if err == nil && params.IncludeSnippets && params.Mode != database.SearchExact && params.Mode != database.SearchPrefix {
+		if db.IsFTSEnabled() {
+			err = db.AddSnippets(ctx, &result.KnowledgeGraph, params.Query, params.Syntax == SearchSyntaxFTS5)
+		} else {
+			err = db.AddMatches(ctx, &result.KnowledgeGraph, params.Query)
+		}
+	}
 	if err != nil {
 		logger.Error("failed to search nodes",
 			slog.String("error", err.Error()),
 			slog.Duration("duration", time.Since(start)),
 		)
-		return nil, nil, fmt.Errorf("failed to search nodes: %w", err)
+		return nil, nil, operationError(ctx, i18n.ErrSearchNodes, err)
 	}
 
 	// Only log at debug level for high-frequency operations
 	logger.Debug("search completed successfully",
-		slog.Int("entities_found", len(graph.Entities)),
-		slog.Int("relations_found", len(graph.Relations)),
+		slog.Int("entities_found", len(result.Entities)),
+		slog.Int("total_matches", result.TotalMatches),
+		slog.Int("relations_found", len(result.Relations)),
 		slog.Duration("duration", time.Since(start)),
 	)
 
-	jsonData, _ := json.MarshalIndent(graph, "", "  ")
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
-		},
-	}, nil, nil
+	if params.Detail == DetailSummary {
+		summary := database.Summarize(&result.KnowledgeGraph)
+		if !paged {
+			res, out, err := s.marshalResult(ctx, "search_nodes", summary)
+			return markSnapshot(ctx, res, takenAt), out, err
+		}
+		res, out, err := s.marshalResult(ctx, "search_nodes", &summarySearchResult{
+			KnowledgeGraphSummary: *summary,
+			TotalMatches:          result.TotalMatches,
+			Offset:                result.Offset,
+			NextOffset:            result.NextOffset,
+		})
+		return markSnapshot(ctx, res, takenAt), out, err
+	}
+
+	// Unpaged searches keep returning the plain graph, linked when it is too large
+	if paged {
+		res, out, err := s.marshalResult(ctx, "search_nodes", result)
+		return markSnapshot(ctx, res, takenAt), out, err
+	}
+	res, out, err := s.graphResult(ctx, "search_nodes", &result.KnowledgeGraph)
+	if err != nil {
+		return nil, nil, err
+	}
+	return markSnapshot(ctx, res, takenAt), out, nil
 }
 
 func (s *Server) handleOpenNodes(ctx context.Context, params OpenNodesParams) (*mcp.CallToolResult, any, error) {
@@ -382,18 +1761,443 @@ func (s *Server) handleOpenNodes(ctx context.Context, params OpenNodesParams) (*
 		logger.Warn("invalid open_nodes parameters",
 			slog.String("error", err.Error()),
 		)
-		return nil, nil, fmt.Errorf("validation error: %w", err)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	if err := s.needsSQLite(ctx,
+		sqliteOption{"includeMetadata", params.IncludeMetadata},
+		sqliteOption{"includeTimestamps", params.IncludeTimestamps},
+		sqliteOption{"tags", len(params.Tags) > 0},
+		sqliteOption{"includeExternalRelations", params.IncludeExternalRelations != "" && params.IncludeExternalRelations != database.ExternalRelationsNone},
+		sqliteOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return nil, nil, err
+	}
+	if len(params.Tags) > 0 {
+		ctx = database.WithTags(ctx, params.Tags)
+	}
+	if params.IncludeExternalRelations != "" {
+		ctx = database.WithExternalRelations(ctx, params.IncludeExternalRelations)
+	}
+	ctx = compactContext(detailContext(ctx, params.Detail), params.Compact)
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	graph, err := s.readStore(db).OpenNodes(ctx, params.Names)
+	if err == nil && params.IncludeTimestamps {
+		err = db.AddTimestamps(ctx, graph)
+	}
+	if err == nil && params.IncludeAliases {
+		err = db.AddAliases(ctx, graph)
+	}
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrOpenNodes, err)
+	}
+	if params.Detail == DetailSummary {
+		res, out, err := s.marshalResult(ctx, "open_nodes", database.Summarize(graph))
+		return markSnapshot(ctx, res, takenAt), out, err
+	}
+	if !params.IncludeMetadata {
+		res, out, err := s.marshalResult(ctx, "open_nodes", graph)
+		return markSnapshot(ctx, res, takenAt), out, err
+	}
+
+	// Names given as aliases are looked up by the names of the entities opened
+	names := make([]string, len(graph.Entities))
+	for i, entity := range graph.Entities {
+		names[i] = entity.Name
+	}
+	metadata, err := db.EntityMetadata(ctx, names)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrOpenNodes, err)
+	}
+	entities := make([]entityWithMetadata, len(graph.Entities))
+	for i, entity := range graph.Entities {
+		entities[i] = entityWithMetadata{EntityWithObservations: entity, Metadata: metadata[entity.Name]}
+	}
+	res, out, err := s.marshalResult(ctx, "open_nodes", &nodesWithMetadata{Entities: entities, Relations: graph.Relations})
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleGetObservations(ctx context.Context, params GetObservationsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	// Validate input parameters
+	if err := ValidateGetObservationsParams(params); err != nil {
+		logger.Warn("invalid get_observations parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultObservationPageSize
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	page, err := db.GetObservations(ctx, params.EntityName, limit, params.Offset, params.OrderBy)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetObservations, err)
+	}
+
+	res, out, err := s.marshalResult(ctx, "get_observations", page)
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleGetEntity(ctx context.Context, params GetEntityParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateEntityName(params.Name); err != nil {
+		logger.Warn("invalid get_entity parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, fmt.Errorf("name: %w", err))
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	entity, err := db.GetEntity(ctx, params.Name)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetEntity, err)
+	}
+
+	res, out, err := s.marshalResult(ctx, "get_entity", entityLookup{Name: params.Name, Found: entity != nil, Entity: entity})
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleRecentEntities(ctx context.Context, params RecentEntitiesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateRecentEntitiesParams(params); err != nil {
+		logger.Warn("invalid recent_entities parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultRecentEntities
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	entities, err := db.RecentEntities(ctx, limit)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrRecentEntities, err)
+	}
+
+	res, out, err := s.marshalResult(ctx, "recent_entities", &recentEntities{Entities: entities})
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleGetRelations(ctx context.Context, tool, direction string, params GetRelationsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateGetRelationsParams(params); err != nil {
+		logger.Warn("invalid "+tool+" parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultRelationPageSize
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	page, err := db.GetRelations(ctx, params.EntityName, direction, params.RelationType, limit, params.Offset)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetRelations, err)
+	}
+
+	res, out, err := s.marshalResult(ctx, tool, page)
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleFindRelations(ctx context.Context, params FindRelationsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateFindRelationsParams(params); err != nil {
+		logger.Warn("invalid get_relations parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultRelationPageSize
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	filter := database.RelationFilter{From: params.From, To: params.To, RelationType: params.RelationType}
+	page, err := db.FindRelations(ctx, filter, limit, params.Offset)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetRelations, err)
+	}
+
+	res, out, err := s.marshalResult(ctx, "get_relations", page)
+	return markSnapshot(ctx, res, takenAt), out, err
+}
+
+func (s *Server) handleGetMaintenanceStatus(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	status, err := s.opts.Maintenance.Status(ctx)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetMaintenanceStatus, err)
+	}
+
+	return s.marshalResult(ctx, "get_maintenance_status", status)
+}
+
+func (s *Server) handleEraseSubject(ctx context.Context, params EraseSubjectParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	// Validate input parameters; the names themselves are never logged
+	if err := ValidateEraseSubjectParams(params); err != nil {
+		logger.Warn("invalid erase_subject parameters",
+			slog.Int("names", len(params.Names)),
+		)
+		s.countRejection(err)
+		return nil, nil, validationError(ctx, err)
+	}
+
+	report, err := s.db.EraseSubject(ctx, params.Names, params.DryRun)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrEraseSubject, err)
+	}
+	if !params.DryRun {
+		// Linked results may hold copies of the erased data
+		s.results.clear()
+	}
+
+	return s.marshalResult(ctx, "erase_subject", report)
+}
+
+func (s *Server) handleGetCapabilities(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	return s.marshalResult(ctx, "get_capabilities", s.Capabilities())
+}
+
+// graphStats is the result of graph_stats
+type graphStats struct {
+	database.GraphStats
+	// ValidationRejections counts the rejected tool calls of every graph, as
+	// get_validation_stats does
+	ValidationRejections ValidationStats `json:"validationRejections"`
+}
+
+func (s *Server) handleGraphStats(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	stats, err := s.db.Stats(ctx)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGraphStats, err)
+	}
+	return s.marshalResult(ctx, "graph_stats", &graphStats{GraphStats: *stats, ValidationRejections: s.ValidationStats()})
+}
+
+func (s *Server) handleCheckIntegrity(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	report, err := s.db.CheckIntegrity(ctx)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrCheckIntegrity, err)
+	}
+	if !report.OK {
+		logging.LoggerWithContext(ctx, s.logger).Warn("database integrity check found problems",
+			slog.Int("errors", len(report.Errors)),
+			slog.Int("foreign_key_violations", len(report.ForeignKeyViolations)),
+			slog.Bool("fts_consistent", report.FTS == nil || report.FTS.Consistent),
+		)
+	}
+	return s.marshalResult(ctx, "check_integrity", report)
+}
+
+func (s *Server) handleImportBegin(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	id, err := s.db.BeginImport(ctx)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrImportBegin, err)
+	}
+
+	return s.marshalResult(ctx, "import_begin", ImportBeginResult{
+		ImportID:      id,
+		Format:        database.ImportFormat,
+		FormatHelp:    `One JSON object per line: {"type":"entity","name":"...","entityType":"...","observations":["..."]} or {"type":"relation","from":"...","to":"...","relationType":"..."}`,
+		FirstSequence: database.ImportFirstSequence,
+		MaxChunkBytes: MaxImportChunkBytes,
+		Encodings:     []string{ImportEncodingText, ImportEncodingBase64},
+		ExpiresAfter:  database.DefaultImportTTL.String(),
+	})
+}
+
+func (s *Server) handleImportChunk(ctx context.Context, params ImportChunkParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	// Validate input parameters
+	if err := ValidateImportChunkParams(params); err != nil {
+		logger.Warn("invalid import_chunk parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	data := []byte(params.Data)
+	if params.Encoding == ImportEncodingBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(params.Data)
+		if err != nil {
+			return nil, nil, s.invalidParams(ctx, i18n.NewError(i18n.ErrInvalidBase64))
+		}
+		data = decoded
+	}
+
+	result, err := s.db.ApplyImportChunk(ctx, params.ImportID, params.Sequence, data)
+	if err != nil {
+		logger.Warn("failed to apply import chunk",
+			slog.String("import_id", params.ImportID),
+			slog.Int("sequence", params.Sequence),
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, importError(ctx, i18n.ErrImportChunk, err)
+	}
+
+	return s.marshalResult(ctx, "import_chunk", result)
+}
+
+func (s *Server) handleImportCommit(ctx context.Context, params ImportCommitParams) (*mcp.CallToolResult, any, error) {
+	if params.ImportID == "" {
+		return nil, nil, s.invalidParams(ctx, i18n.NewError(i18n.ErrImportIDEmpty))
 	}
 
-	graph, err := s.db.OpenNodes(ctx, params.Names)
+	var summary *database.ImportSummary
+	err := s.opts.SnapshotReads.Run(ctx, "import_commit", func(ctx context.Context) error {
+		var err error
+		summary, err = s.db.CommitImport(ctx, params.ImportID)
+		return err
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open nodes: %w", err)
+		return nil, nil, importError(ctx, i18n.ErrImportCommit, err)
+	}
+
+	return s.marshalResult(ctx, "import_commit", summary)
+}
+
+func (s *Server) handleImportAbort(ctx context.Context, params ImportAbortParams) (*mcp.CallToolResult, any, error) {
+	if params.ImportID == "" {
+		return nil, nil, s.invalidParams(ctx, i18n.NewError(i18n.ErrImportIDEmpty))
+	}
+
+	if err := s.db.AbortImport(ctx, params.ImportID); err != nil {
+		return nil, nil, importError(ctx, i18n.ErrImportAbort, err)
 	}
 
-	jsonData, _ := json.MarshalIndent(graph, "", "  ")
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
-			&mcp.TextContent{Text: string(jsonData)},
+			&mcp.TextContent{Text: i18n.T(ctx, i18n.MsgImportAborted)},
 		},
 	}, nil, nil
 }
+
+func (s *Server) handleExportGraph(ctx context.Context, params ExportGraphParams) (*mcp.CallToolResult, any, error) {
+	if err := ValidateExportGraphParams(params); err != nil {
+		logging.LoggerWithContext(ctx, s.logger).Warn("invalid export_graph parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	db, takenAt, release := s.reader()
+	defer release()
+
+	var buf bytes.Buffer
+	if params.Format == "" || params.Format == ExportFormatJSON {
+		if err := db.Export(ctx, &buf); err != nil {
+			return nil, nil, operationError(ctx, i18n.ErrExportGraph, err)
+		}
+	} else {
+		opts := database.ViewOptions{Root: params.Root, Depth: params.Depth, MaxNodes: params.MaxNodes}
+		if opts.Depth == 0 {
+			opts.Depth = DefaultNeighborDepth
+		}
+		if opts.MaxNodes == 0 {
+			opts.MaxNodes = DefaultViewMaxNodes
+		}
+		if err := db.ExportView(ctx, &buf, params.Format, opts); err != nil {
+			return nil, nil, exportError(ctx, err, params.Root)
+		}
+	}
+
+	res, err := checkEventLimit(ctx, &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: buf.String()}},
+	})
+	return markSnapshot(ctx, res, takenAt), nil, err
+}
+
+func (s *Server) handleImportGraph(ctx context.Context, params ImportGraphParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateImportGraphParams(params); err != nil {
+		logger.Warn("invalid import_graph parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+	var doc database.GraphDocument
+	if err := json.Unmarshal([]byte(params.Document), &doc); err != nil {
+		return nil, nil, s.invalidParams(ctx, i18n.NewError(i18n.ErrInvalidDocument, err.Error()))
+	}
+	if err := ValidateGraphDocument(doc); err != nil {
+		logger.Warn("invalid import_graph document",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	report, err := s.db.ImportDocument(ctx, &doc, params.Strategy)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrImportGraph, err)
+	}
+
+	return s.marshalResult(ctx, "import_graph", report)
+}
+
+func (s *Server) handleSetTypeMetadata(ctx context.Context, params SetTypeMetadataParams) (*mcp.CallToolResult, any, error) {
+	if err := ValidateSetTypeMetadataParams(params); err != nil {
+		logging.LoggerWithContext(ctx, s.logger).Warn("invalid set_type_metadata parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	if err := s.db.SetTypeMetadata(ctx, params.EntityType, params.Metadata); err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrSetTypeMetadata, err)
+	}
+	meta, err := s.db.GetTypeMetadata(ctx, []string{params.EntityType})
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrSetTypeMetadata, err)
+	}
+
+	result := typeMetadataResult{EntityType: params.EntityType, Metadata: meta[params.EntityType]}
+	if result.Metadata == nil {
+		result.Metadata = map[string]string{}
+	}
+	return s.marshalResult(ctx, "set_type_metadata", result)
+}
+
+func (s *Server) handleGetTypeMetadata(ctx context.Context, params GetTypeMetadataParams) (*mcp.CallToolResult, any, error) {
+	if err := ValidateGetTypeMetadataParams(params); err != nil {
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	meta, err := s.db.GetTypeMetadata(ctx, params.EntityTypes)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrGetTypeMetadata, err)
+	}
+
+	return s.marshalResult(ctx, "get_type_metadata", meta)
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// setupBenchServer creates a server backed by an in-memory database seeded with entityCount entities
+func setupBenchServer(tb testing.TB, entityCount int) (*Server, *database.DB) {
+	tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := database.NewDBWithLogger("file::memory:?cache=shared", logger)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	ctx := context.Background()
+	batchSize := 100
+	for i := 0; i < entityCount; i += batchSize {
+		end := i + batchSize
+		if end > entityCount {
+			end = entityCount
+		}
+		batch := make([]database.EntityWithObservations, 0, end-i)
+		for j := i; j < end; j++ {
+			batch = append(batch, database.EntityWithObservations{
+				Name:       fmt.Sprintf("entity_%d", j),
+				EntityType: fmt.Sprintf("type_%d", j%10),
+				Observations: []string{
+					fmt.Sprintf("observation_1_for_entity_%d", j),
+					fmt.Sprintf("observation_2_for_entity_%d", j),
+					fmt.Sprintf("test data with searchable content %d", j),
+				},
+			})
+		}
+		if _, err := db.CreateEntities(ctx, batch); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	relations := make([]database.RelationDTO, 0, entityCount/2)
+	for i := 0; i < entityCount/2; i++ {
+		relations = append(relations, database.RelationDTO{
+			From:         fmt.Sprintf("entity_%d", i),
+			To:           fmt.Sprintf("entity_%d", (i+1)%entityCount),
+			RelationType: "connects_to",
+		})
+	}
+	for i := 0; i < len(relations); i += batchSize {
+		end := i + batchSize
+		if end > len(relations) {
+			end = len(relations)
+		}
+		if _, err := db.CreateRelations(ctx, relations[i:end]); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return NewServerWithLogger(db, logger), db
+}
+
+// BenchmarkHandleReadGraph measures read_graph end to end, including JSON encoding of the result
+func BenchmarkHandleReadGraph(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("size_%d", size), func(b *testing.B) {
+			s, db := setupBenchServer(b, size)
+			defer db.Close()
+
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, _, err := s.handleReadGraph(ctx, ReadGraphParams{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHandleSearchNodes measures search_nodes including validation and encoding
+func BenchmarkHandleSearchNodes(b *testing.B) {
+	queries := []string{"test", "entity", "observation", "content"}
+
+	for _, size := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("size_%d", size), func(b *testing.B) {
+			s, db := setupBenchServer(b, size)
+			defer db.Close()
+
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				params := SearchNodesParams{Query: queries[i%len(queries)]}
+				if _, _, err := s.handleSearchNodes(ctx, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHandleCreateEntities measures create_entities including validation and encoding
+func BenchmarkHandleCreateEntities(b *testing.B) {
+	for _, batchSize := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("batch_%d", batchSize), func(b *testing.B) {
+			s, db := setupBenchServer(b, 0)
+			defer db.Close()
+
+			ctx := context.Background()
+			entities := make([]database.EntityWithObservations, batchSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for j := range entities {
+					entities[j] = database.EntityWithObservations{
+						Name:         fmt.Sprintf("entity_%d_%d", i, j),
+						EntityType:   "benchmark_type",
+						Observations: []string{"observation_1", "observation_2"},
+					}
+				}
+				if _, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: entities}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkEncodeResultComparison compares json.MarshalIndent with the pooled encoder used by handlers.
+// Measured on the 1000-entity fixture (read_graph payload):
+//
+//	MarshalIndent   ~778 KB/op, 3 allocs/op, ~2.4 ms/op
+//	encodeJSON      ~287 KB/op, 2 allocs/op, ~1.5 ms/op
+//
+// End to end, handleReadGraph/size_1000 dropped from ~1.54 MB/op to ~1.16 MB/op; allocation
+// counts and latency are dominated by row scanning in the database layer, not by encoding.
+func BenchmarkEncodeResultComparison(b *testing.B) {
+	s, db := setupBenchServer(b, 1000)
+	defer db.Close()
+
+	graph, err := s.db.ReadGraph(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("MarshalIndent", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.MarshalIndent(graph, "", "  ")
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = string(data)
+		}
+	})
+
+	b.Run("encodeJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := encodeJSON(graph, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// readGraphAllocBudget is the maximum allocations allowed for one read_graph call on the
+// 1000-entity fixture. Raise it deliberately when a change legitimately needs more.
+const readGraphAllocBudget = 16000
+
+func TestHandleReadGraph_AllocationBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation guard seeds a 1000-entity fixture")
+	}
+
+	s, db := setupBenchServer(t, 1000)
+	defer db.Close()
+
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(5, func() {
+		if _, _, err := s.handleReadGraph(ctx, ReadGraphParams{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > readGraphAllocBudget {
+		t.Fatalf("read_graph on 1000 entities allocated %.0f times per call, budget is %d", allocs, readGraphAllocBudget)
+	}
+}
+
+func TestEncodeJSON_MatchesMarshal(t *testing.T) {
+	values := []any{
+		database.KnowledgeGraph{
+			Entities:  []database.EntityWithObservations{{Name: "<A&B>", EntityType: "t", Observations: []string{"line\nbreak", "ünïcode"}}},
+			Relations: []database.RelationDTO{{From: "<A&B>", To: "x", RelationType: "r"}},
+		},
+		[]database.EntityWithObservations{},
+		map[string]int{"b": 2, "a": 1},
+	}
+	for _, v := range values {
+		want, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := encodeJSON(v, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Fatalf("encodeJSON mismatch:\n got: %s\nwant: %s", got, want)
+		}
+
+		want, err = json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = encodeJSON(v, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Fatalf("compact encodeJSON mismatch:\n got: %s\nwant: %s", got, want)
+		}
+	}
+}
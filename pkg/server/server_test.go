@@ -1,13 +1,30 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/maintenance"
 	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/postgres"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/router"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/store"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,6 +40,37 @@ func newTestServer(t *testing.T) (*Server, *database.DB) {
 	return srv, db
 }
 
+// testBackends returns, by store backend, a function creating a test server over an
+// empty store of it, for the tests of the core tools every backend serves. Postgres
+// is included when MEMORY_TEST_POSTGRES_DSN names a database the test may empty, and
+// needs the postgres build tag.
+func testBackends() map[string]func(t *testing.T) *Server {
+	backends := map[string]func(t *testing.T) *Server{
+		"sqlite": func(t *testing.T) *Server {
+			s, _ := newTestServer(t)
+			return s
+		},
+		"memory": func(t *testing.T) *Server {
+			return NewServerWithLogger(store.NewMemory(), nil)
+		},
+	}
+	if dsn := os.Getenv("MEMORY_TEST_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) *Server {
+			logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+			db, err := postgres.NewDB(context.Background(), dsn, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = db.Close() })
+			if err := db.Truncate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			return NewServerWithLogger(db, logger)
+		}
+	}
+	return backends
+}
+
 func jsonText(t *testing.T, res *mcp.CallToolResult) string {
 	assert.NotNil(t, res)
 	assert.NotEmpty(t, res.Content)
@@ -32,31 +80,57 @@ func jsonText(t *testing.T, res *mcp.CallToolResult) string {
 }
 
 // unmarshalJSON unmarshals the first TextContent JSON payload into T and fails the test on error.
+// The text must match the structured result, which wraps it when it is an array.
 func unmarshalJSON[T any](t *testing.T, res *mcp.CallToolResult) T {
 	t.Helper()
+	text := jsonText(t, res)
 	var out T
-	err := json.Unmarshal([]byte(jsonText(t, res)), &out)
+	err := json.Unmarshal([]byte(text), &out)
 	assert.NoError(t, err)
+	if res.StructuredContent != nil {
+		structured, err := json.Marshal(res.StructuredContent)
+		assert.NoError(t, err)
+		if strings.HasPrefix(text, "[") {
+			var wrapper map[string]json.RawMessage
+			assert.NoError(t, json.Unmarshal(structured, &wrapper))
+			assert.Len(t, wrapper, 1)
+			for _, v := range wrapper {
+				structured = v
+			}
+		}
+		assert.JSONEq(t, text, string(structured))
+	}
 	return out
 }
 
+// structuredAs returns a handler's structured result and fails the test when it isn't a T.
+func structuredAs[T any](t *testing.T, out any) T {
+	t.Helper()
+	v, ok := out.(T)
+	assert.True(t, ok, "structured result is %T", out)
+	return v
+}
+
 func TestServer_CreateEntities_AndReadGraph(t *testing.T) {
 	s, _ := newTestServer(t)
 
 	// create two entities with observations
-	res, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{
+	res, out, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{
 		{Name: "E1", EntityType: "T1", Observations: []string{"o1", "o2"}},
 		{Name: "E2", EntityType: "T2"},
 	}})
 	assert.NoError(t, err)
 
-	created := unmarshalJSON[[]database.EntityWithObservations](t, res)
-	assert.Len(t, created, 2)
+	created := structuredAs[*createdEntities](t, out)
+	assert.Len(t, created.Entities, 2)
+	assert.Equal(t, []string{"o1", "o2"}, created.Entities[0].Observations)
+	// The text keeps the plain array for clients without structured output
+	assert.Len(t, unmarshalJSON[[]database.EntityWithObservations](t, res), 2)
 
 	// read graph
-	res, _, err = s.handleReadGraph(context.Background())
+	_, out, err = s.handleReadGraph(context.Background(), ReadGraphParams{})
 	assert.NoError(t, err)
-	g := unmarshalJSON[database.KnowledgeGraph](t, res)
+	g := structuredAs[*database.KnowledgeGraph](t, out)
 	assert.Len(t, g.Entities, 2)
 }
 
@@ -87,19 +161,19 @@ func TestServer_CreateEntities_Table(t *testing.T) {
 		},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			if len(tc.seed) > 0 {
-				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				if len(tc.seed) > 0 {
+					_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
+					assert.NoError(t, err)
+				}
+				_, out, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.input})
 				assert.NoError(t, err)
-			}
-			res, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.input})
-			assert.NoError(t, err)
-			var created []database.EntityWithObservations
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &created))
-			assert.Len(t, created, tc.wantLen)
-		})
+				assert.Len(t, structuredAs[*createdEntities](t, out).Entities, tc.wantLen)
+			})
+		}
 	}
 }
 
@@ -111,14 +185,15 @@ func TestServer_AddObservations_MixedAndError(t *testing.T) {
 	assert.NoError(t, err)
 
 	// add mixture: existing and duplicates within the same call
-	res, _, err := s.handleAddObservations(context.Background(), AddObservationsParams{Observations: []ObservationInput{{
+	_, out, err := s.handleAddObservations(context.Background(), AddObservationsParams{Observations: []ObservationInput{{
 		EntityName: "E1",
 		Contents:   []string{"o1", "o2", "o2"},
 	}}})
 	assert.NoError(t, err)
-	added := unmarshalJSON[[]database.ObservationAdditionResult](t, res)
+	added := structuredAs[*observationAdditions](t, out).Results
 	assert.Len(t, added, 1)
 	assert.Equal(t, []string{"o2"}, added[0].AddedObservations)
+	assert.Equal(t, []string{"o1"}, added[0].SkippedObservations)
 
 	// error for unknown entity
 	_, _, err = s.handleAddObservations(context.Background(), AddObservationsParams{Observations: []ObservationInput{{
@@ -155,23 +230,25 @@ func TestServer_AddObservations_Table(t *testing.T) {
 		},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			if len(tc.seed) > 0 {
-				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				if len(tc.seed) > 0 {
+					_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
+					assert.NoError(t, err)
+				}
+				res, _, err := s.handleAddObservations(context.Background(), AddObservationsParams{Observations: tc.input})
+				if tc.wantErr {
+					assert.Error(t, err)
+					return
+				}
 				assert.NoError(t, err)
-			}
-			res, _, err := s.handleAddObservations(context.Background(), AddObservationsParams{Observations: tc.input})
-			if tc.wantErr {
-				assert.Error(t, err)
-				return
-			}
-			assert.NoError(t, err)
-			var got []obsRes
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &got))
-			assert.Equal(t, tc.want, got)
-		})
+				var got []obsRes
+				assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &got))
+				assert.Equal(t, tc.want, got)
+			})
+		}
 	}
 }
 
@@ -182,22 +259,29 @@ func TestServer_CreateRelations_Edges(t *testing.T) {
 	assert.NoError(t, err)
 
 	// self relation allowed
-	res, _, err := s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "A", RelationType: "self"}}})
+	_, out, err := s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "A", RelationType: "self"}}})
 	assert.NoError(t, err)
-	created := unmarshalJSON[[]database.RelationDTO](t, res)
-	assert.Len(t, created, 1)
+	created := structuredAs[*database.RelationCreationResult](t, out)
+	assert.Len(t, created.Relations, 1)
+	assert.Empty(t, created.Skipped)
 
 	// duplicate no-op
-	res, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "A", RelationType: "self"}}})
+	_, out, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "A", RelationType: "self"}}})
 	assert.NoError(t, err)
-	created = unmarshalJSON[[]database.RelationDTO](t, res)
-	assert.Len(t, created, 0)
+	created = structuredAs[*database.RelationCreationResult](t, out)
+	assert.Len(t, created.Relations, 0)
+	if assert.Len(t, created.Skipped, 1) {
+		assert.Equal(t, database.SkipDuplicate, created.Skipped[0].Reason)
+	}
 
 	// missing endpoint no-op
-	res, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "C", RelationType: "rel"}}})
+	_, out, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "C", RelationType: "rel"}}})
 	assert.NoError(t, err)
-	created = unmarshalJSON[[]database.RelationDTO](t, res)
-	assert.Len(t, created, 0)
+	created = structuredAs[*database.RelationCreationResult](t, out)
+	assert.Len(t, created.Relations, 0)
+	if assert.Len(t, created.Skipped, 1) {
+		assert.Equal(t, database.SkipMissingTo, created.Skipped[0].Reason)
+	}
 }
 
 func TestServer_CreateRelations_Table(t *testing.T) {
@@ -235,22 +319,83 @@ func TestServer_CreateRelations_Table(t *testing.T) {
 		},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
-			assert.NoError(t, err)
-			if len(tc.preRels) > 0 {
-				_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: tc.preRels})
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: tc.seed})
 				assert.NoError(t, err)
-			}
-			res, _, err := s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: tc.input})
-			assert.NoError(t, err)
-			var created []database.RelationDTO
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &created))
-			assert.Len(t, created, tc.wantLen)
-		})
+				if len(tc.preRels) > 0 {
+					_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: tc.preRels})
+					assert.NoError(t, err)
+				}
+				res, _, err := s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: tc.input})
+				assert.NoError(t, err)
+				var created database.RelationCreationResult
+				assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &created))
+				assert.Len(t, created.Relations, tc.wantLen)
+				assert.Len(t, created.Skipped, len(tc.input)-tc.wantLen)
+			})
+		}
+	}
+}
+
+func TestServer_ObservationCap(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Log", EntityType: "log", Observations: []string{"a", "b"}},
+	}})
+	assert.NoError(t, err)
+
+	db.SetObservationCap(2, database.EvictReject)
+	assert.Equal(t, 2, s.Capabilities()["maxStoredObservationsPerEntity"])
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{{EntityName: "Log", Contents: []string{"c"}}}})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrObservationCapExceeded, toolErr.Code)
+		assert.Equal(t, map[string]any{"entityName": "Log", "maxStoredObservations": 2}, toolErr.Details)
+	}
+
+	db.SetObservationCap(2, database.EvictOldest)
+	res, _, err := s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{{EntityName: "Log", Contents: []string{"c"}}}})
+	assert.NoError(t, err)
+	added := unmarshalJSON[[]database.ObservationAdditionResult](t, res)
+	assert.Equal(t, []string{"a"}, added[0].EvictedObservations)
+}
+
+func TestServer_CreateRelationsStrict(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T"}, {Name: "B", EntityType: "T"}}})
+	assert.NoError(t, err)
+
+	relations := []database.RelationDTO{
+		{From: "A", To: "B", RelationType: "rel"},
+		{From: "A", To: "C", RelationType: "rel"},
+		{From: "D", To: "B", RelationType: "rel"},
+	}
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: relations, Strict: true})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrRelationEndpointsMissing, toolErr.Code)
+		assert.Equal(t, "2 relations name entities that don't exist, so none were created: "+
+			"relations[1]: entity C does not exist; relations[2]: entity D does not exist", toolErr.Message)
+		assert.Equal(t, []database.SkippedRelation{
+			{Index: 1, Relation: relations[1], Reason: database.SkipMissingTo},
+			{Index: 2, Relation: relations[2], Reason: database.SkipMissingFrom},
+		}, toolErr.Details["skipped"])
 	}
+	res, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.KnowledgeGraph](t, res).Relations)
+
+	// Without strict the same call creates what it can and reports the rest
+	res, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: relations})
+	assert.NoError(t, err)
+	created := unmarshalJSON[database.RelationCreationResult](t, res)
+	assert.Equal(t, relations[:1], created.Relations)
+	assert.Len(t, created.Skipped, 2)
 }
 
 func TestServer_DeleteEntities_Cascade(t *testing.T) {
@@ -265,12 +410,12 @@ func TestServer_DeleteEntities_Cascade(t *testing.T) {
 	assert.NoError(t, err)
 
 	// delete A
-	res, _, err := s.handleDeleteEntities(context.Background(), DeleteEntitiesParams{EntityNames: []string{"A"}})
+	res, _, err := s.handleDeleteEntities(context.Background(), DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "A"}}})
 	assert.NoError(t, err)
-	assert.Contains(t, jsonText(t, res), "successfully")
+	assert.Equal(t, 1, unmarshalJSON[entityDeletions](t, res).DeletedEntities)
 
 	// read graph
-	res, _, err = s.handleReadGraph(context.Background())
+	res, _, err = s.handleReadGraph(context.Background(), ReadGraphParams{})
 	assert.NoError(t, err)
 	g := unmarshalJSON[database.KnowledgeGraph](t, res)
 	assert.Len(t, g.Entities, 1)
@@ -278,51 +423,235 @@ func TestServer_DeleteEntities_Cascade(t *testing.T) {
 	assert.Len(t, g.Relations, 0)
 }
 
+func TestServer_DeleteDryRun(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "T", Observations: []string{"x", "y"}},
+		{Name: "B", EntityType: "T", Observations: []string{"z"}},
+		{Name: "C", EntityType: "T"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "A", To: "B", RelationType: "rel"},
+		{From: "C", To: "A", RelationType: "rel"},
+		{From: "B", To: "C", RelationType: "rel"},
+	}})
+	assert.NoError(t, err)
+	before, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	unchanged := func() {
+		t.Helper()
+		graph, err := db.ReadGraph(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, before, graph, "a dry run changes nothing")
+	}
+
+	// delete_observations
+	obsParams := DeleteObservationsParams{Deletions: []DeletionInput{{EntityName: "A", Observations: []string{"x", "missing"}}}, DryRun: true}
+	_, out, err := s.handleDeleteObservations(ctx, obsParams)
+	assert.NoError(t, err)
+	preview := structuredAs[deletionPreview](t, out)
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, 1, preview.Observations)
+	unchanged()
+
+	// delete_relations
+	relParams := DeleteRelationsParams{Relations: []database.RelationDTO{{From: "B", To: "C", RelationType: "rel"}, {From: "C", To: "B", RelationType: "rel"}}, DryRun: true}
+	_, out, err = s.handleDeleteRelations(ctx, relParams)
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RelationDTO{{From: "B", To: "C", RelationType: "rel"}}, structuredAs[deletionPreview](t, out).Relations)
+	unchanged()
+
+	// delete_entities, with one item reassigning its relations
+	entParams := DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "A"}, {Name: "B", ReassignRelationsTo: "C"}}, DryRun: true}
+	res, out, err := s.handleDeleteEntities(ctx, entParams)
+	assert.NoError(t, err)
+	preview = structuredAs[deletionPreview](t, out)
+	assert.Equal(t, unmarshalJSON[deletionPreview](t, res), preview)
+	assert.Equal(t, []string{"A"}, preview.Entities)
+	assert.Equal(t, 2, preview.Observations)
+	assert.ElementsMatch(t, []database.RelationDTO{
+		{From: "A", To: "B", RelationType: "rel"},
+		{From: "C", To: "A", RelationType: "rel"},
+	}, preview.Relations)
+	assert.Equal(t, []*database.Reassignment{{Entity: "B", Successor: "C", Moved: 1, Dropped: 1, Deleted: true}}, preview.Reassigned)
+	unchanged()
+
+	// The real deletions remove what the previews reported
+	_, _, err = s.handleDeleteObservations(ctx, DeleteObservationsParams{Deletions: obsParams.Deletions})
+	assert.NoError(t, err)
+	_, _, err = s.handleDeleteRelations(ctx, DeleteRelationsParams{Relations: relParams.Relations})
+	assert.NoError(t, err)
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "A"}}})
+	assert.NoError(t, err)
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, graph.Entities, 2) {
+		assert.Equal(t, []string{"z"}, graph.Entities[0].Observations, "y went with A, x before it")
+	}
+	assert.Empty(t, graph.Relations)
+
+	// dryRun needs SQLite
+	mem := NewServerWithLogger(store.NewMemory(), nil)
+	_, _, err = mem.handleDeleteRelations(ctx, relParams)
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+}
+
+func TestServer_UnicodeEntityNames(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	names := []string{"café", "東京オフィス", "🚀 launch", "repo:main", "docs/guide"}
+
+	var entities []database.EntityWithObservations
+	for _, name := range names {
+		entities = append(entities, database.EntityWithObservations{Name: name, EntityType: "place", Observations: []string{"visited by " + name}})
+	}
+	res, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: entities})
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[[]database.EntityWithObservations](t, res), len(names))
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "café", To: "東京オフィス", RelationType: "near"},
+	}})
+	assert.NoError(t, err)
+
+	for _, name := range names {
+		res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: name})
+		assert.NoError(t, err)
+		g := unmarshalJSON[database.KnowledgeGraph](t, res)
+		found := false
+		for _, e := range g.Entities {
+			found = found || e.Name == name
+		}
+		assert.True(t, found, name)
+	}
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"café", "東京オフィス"}})
+	assert.NoError(t, err)
+	g := unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, g.Entities, 2)
+	assert.Equal(t, []database.RelationDTO{{From: "café", To: "東京オフィス", RelationType: "near"}}, g.Relations)
+
+	var deletions []EntityDeletion
+	for _, name := range names {
+		deletions = append(deletions, EntityDeletion{Name: name})
+	}
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: deletions})
+	assert.NoError(t, err)
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	g = unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Empty(t, g.Entities)
+	assert.Empty(t, g.Relations)
+
+	// Control characters are still rejected, C1 controls included
+	for _, name := range []string{"tab\there", "next\u0085line"} {
+		_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: name, EntityType: "place"}}})
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, name) {
+			assert.Equal(t, i18n.ErrEntityNameControlChars, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_SQLKeywordNames(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	// Words that contain SQL keywords are ordinary names; every query is parameterized
+	entities := []database.EntityWithObservations{
+		{Name: "Select Committee", EntityType: "committee", Observations: []string{"scrutinizes the budget"}},
+		{Name: "Software Update", EntityType: "release", Observations: []string{"DROP TABLE entities; --"}},
+		{Name: "Creative Director", EntityType: "role"},
+		{Name: "Union Station", EntityType: "drop-off point"},
+		{Name: "Robert'); DELETE FROM entities;--", EntityType: "student"},
+	}
+	res, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: entities})
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[[]database.EntityWithObservations](t, res), len(entities))
+
+	relations := []database.RelationDTO{
+		{From: "Creative Director", To: "Software Update", RelationType: "executes"},
+		{From: "Select Committee", To: "Union Station", RelationType: "alters schedule of"},
+	}
+	res, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: relations})
+	assert.NoError(t, err)
+	assert.Equal(t, relations, unmarshalJSON[database.RelationCreationResult](t, res).Relations)
+
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	g := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, g.Entities, len(entities)) {
+		stored := map[string]string{}
+		for _, e := range g.Entities {
+			stored[e.Name] = e.EntityType
+		}
+		for _, e := range entities {
+			assert.Equal(t, e.EntityType, stored[e.Name], e.Name)
+		}
+	}
+	assert.ElementsMatch(t, relations, g.Relations)
+}
+
 func TestServer_DeleteEntities_Table(t *testing.T) {
 	cases := []struct {
 		name       string
-		delete     []string
+		delete     []EntityDeletion
 		wantNames  []string
 		wantRelLen int
+		want       database.EntityDeletionResult
 	}{
 		{
 			name:       "delete existing cascades",
-			delete:     []string{"A"},
+			delete:     []EntityDeletion{{Name: "A"}},
 			wantNames:  []string{"B"},
 			wantRelLen: 0,
+			want:       database.EntityDeletionResult{DeletedEntities: 1, NotFound: []string{}},
 		},
 		{
 			name:       "delete missing is noop",
-			delete:     []string{"C"},
+			delete:     []EntityDeletion{{Name: "C"}},
 			wantNames:  []string{"A", "B"},
 			wantRelLen: 1,
+			want:       database.EntityDeletionResult{NotFound: []string{"C"}},
+		},
+		{
+			name:       "typo is reported",
+			delete:     []EntityDeletion{{Name: "A"}, {Name: "Foo"}},
+			wantNames:  []string{"B"},
+			wantRelLen: 0,
+			want:       database.EntityDeletionResult{DeletedEntities: 1, NotFound: []string{"Foo"}},
 		},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			// seed
-			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"x"}}, {Name: "B", EntityType: "T"}}})
-			assert.NoError(t, err)
-			_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}})
-			assert.NoError(t, err)
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				// seed
+				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"x"}}, {Name: "B", EntityType: "T"}}})
+				assert.NoError(t, err)
+				_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}})
+				assert.NoError(t, err)
 
-			_, _, err = s.handleDeleteEntities(context.Background(), DeleteEntitiesParams{EntityNames: tc.delete})
-			assert.NoError(t, err)
+				res, out, err := s.handleDeleteEntities(context.Background(), DeleteEntitiesParams{EntityNames: tc.delete})
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, structuredAs[entityDeletions](t, out).EntityDeletionResult)
+				assert.Equal(t, tc.want, unmarshalJSON[database.EntityDeletionResult](t, res))
 
-			res, _, err := s.handleReadGraph(context.Background())
-			assert.NoError(t, err)
-			var g database.KnowledgeGraph
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
-			// collect names
-			gotNames := make([]string, 0, len(g.Entities))
-			for _, e := range g.Entities {
-				gotNames = append(gotNames, e.Name)
-			}
-			assert.ElementsMatch(t, tc.wantNames, gotNames)
-			assert.Len(t, g.Relations, tc.wantRelLen)
-		})
+				res, _, err = s.handleReadGraph(context.Background(), ReadGraphParams{})
+				assert.NoError(t, err)
+				var g database.KnowledgeGraph
+				assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
+				// collect names
+				gotNames := make([]string, 0, len(g.Entities))
+				for _, e := range g.Entities {
+					gotNames = append(gotNames, e.Name)
+				}
+				assert.ElementsMatch(t, tc.wantNames, gotNames)
+				assert.Len(t, g.Relations, tc.wantRelLen)
+			})
+		}
 	}
 }
 
@@ -335,12 +664,19 @@ func TestServer_DeleteObservations_Scenarios(t *testing.T) {
 	// delete existing and a missing one
 	res, _, err := s.handleDeleteObservations(context.Background(), DeleteObservationsParams{Deletions: []DeletionInput{{EntityName: "A", Observations: []string{"o1", "nope"}}}})
 	assert.NoError(t, err)
-	assert.Contains(t, jsonText(t, res), "successfully")
+	assert.Equal(t, database.ObservationDeletionResult{
+		DeletedObservations: 1,
+		NotFound:            []string{},
+		MissingObservations: []database.ObservationDeletionInput{{EntityName: "A", Observations: []string{"nope"}}},
+	}, unmarshalJSON[database.ObservationDeletionResult](t, res))
+	assert.Equal(t, "Observations deleted successfully", res.Content[1].(*mcp.TextContent).Text)
 
 	// unknown entity should be a no-op
 	res, _, err = s.handleDeleteObservations(context.Background(), DeleteObservationsParams{Deletions: []DeletionInput{{EntityName: "UNKNOWN", Observations: []string{"x"}}}})
 	assert.NoError(t, err)
-	assert.Contains(t, jsonText(t, res), "successfully")
+	got := unmarshalJSON[database.ObservationDeletionResult](t, res)
+	assert.Zero(t, got.DeletedObservations)
+	assert.Equal(t, []string{"UNKNOWN"}, got.NotFound)
 }
 
 func TestServer_DeleteObservations_Table(t *testing.T) {
@@ -348,27 +684,34 @@ func TestServer_DeleteObservations_Table(t *testing.T) {
 		name      string
 		deletions []DeletionInput
 		wantObs   []string
+		deleted   int
+		notFound  []string
 	}{
-		{name: "delete existing", deletions: []DeletionInput{{EntityName: "A", Observations: []string{"o1"}}}, wantObs: []string{"o2"}},
-		{name: "delete unknown observation", deletions: []DeletionInput{{EntityName: "A", Observations: []string{"nope"}}}, wantObs: []string{"o1", "o2"}},
-		{name: "unknown entity noop", deletions: []DeletionInput{{EntityName: "UNKNOWN", Observations: []string{"x"}}}, wantObs: []string{"o1", "o2"}},
+		{name: "delete existing", deletions: []DeletionInput{{EntityName: "A", Observations: []string{"o1"}}}, wantObs: []string{"o2"}, deleted: 1, notFound: []string{}},
+		{name: "delete unknown observation", deletions: []DeletionInput{{EntityName: "A", Observations: []string{"nope"}}}, wantObs: []string{"o1", "o2"}, notFound: []string{}},
+		{name: "unknown entity noop", deletions: []DeletionInput{{EntityName: "UNKNOWN", Observations: []string{"x"}}}, wantObs: []string{"o1", "o2"}, notFound: []string{"UNKNOWN"}},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"o1", "o2"}}}})
-			assert.NoError(t, err)
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"o1", "o2"}}}})
+				assert.NoError(t, err)
 
-			_, _, err = s.handleDeleteObservations(context.Background(), DeleteObservationsParams{Deletions: tc.deletions})
-			assert.NoError(t, err)
+				_, out, err := s.handleDeleteObservations(context.Background(), DeleteObservationsParams{Deletions: tc.deletions})
+				assert.NoError(t, err)
+				result := structuredAs[*database.ObservationDeletionResult](t, out)
+				assert.Equal(t, tc.deleted, result.DeletedObservations)
+				assert.Equal(t, tc.notFound, result.NotFound)
 
-			res, _, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"A"}})
-			assert.NoError(t, err)
-			var g database.KnowledgeGraph
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
-			assert.ElementsMatch(t, tc.wantObs, g.Entities[0].Observations)
-		})
+				res, _, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"A"}})
+				assert.NoError(t, err)
+				var g database.KnowledgeGraph
+				assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
+				assert.ElementsMatch(t, tc.wantObs, g.Entities[0].Observations)
+			})
+		}
 	}
 }
 
@@ -383,12 +726,18 @@ func TestServer_DeleteRelations_NoopsAndDelete(t *testing.T) {
 	// delete missing relation (no-op)
 	res, _, err := s.handleDeleteRelations(context.Background(), DeleteRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "other"}}})
 	assert.NoError(t, err)
-	assert.Contains(t, jsonText(t, res), "successfully")
+	assert.Equal(t, database.RelationDeletionResult{
+		NotFound: []database.RelationDTO{{From: "A", To: "B", RelationType: "other"}},
+	}, unmarshalJSON[database.RelationDeletionResult](t, res))
 
 	// delete existing relation
 	res, _, err = s.handleDeleteRelations(context.Background(), DeleteRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}})
 	assert.NoError(t, err)
-	assert.Contains(t, jsonText(t, res), "successfully")
+	assert.Equal(t, database.RelationDeletionResult{
+		DeletedRelations: 1,
+		NotFound:         []database.RelationDTO{},
+	}, unmarshalJSON[database.RelationDeletionResult](t, res))
+	assert.Equal(t, "Relations deleted successfully", res.Content[1].(*mcp.TextContent).Text)
 }
 
 func TestServer_DeleteRelations_Table(t *testing.T) {
@@ -396,29 +745,35 @@ func TestServer_DeleteRelations_Table(t *testing.T) {
 		name      string
 		deletions []database.RelationDTO
 		wantRel   int
+		deleted   int
 	}{
 		{name: "delete missing type noop", deletions: []database.RelationDTO{{From: "A", To: "B", RelationType: "other"}}, wantRel: 1},
-		{name: "delete existing", deletions: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantRel: 0},
+		{name: "delete existing", deletions: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}, wantRel: 0, deleted: 1},
 		{name: "missing endpoint noop", deletions: []database.RelationDTO{{From: "A", To: "C", RelationType: "rel"}}, wantRel: 1},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			s, _ := newTestServer(t)
-			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T"}, {Name: "B", EntityType: "T"}}})
-			assert.NoError(t, err)
-			_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}})
-			assert.NoError(t, err)
+	for backend, newServer := range testBackends() {
+		for _, tc := range cases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				s := newServer(t)
+				_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "A", EntityType: "T"}, {Name: "B", EntityType: "T"}}})
+				assert.NoError(t, err)
+				_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "rel"}}})
+				assert.NoError(t, err)
 
-			_, _, err = s.handleDeleteRelations(context.Background(), DeleteRelationsParams{Relations: tc.deletions})
-			assert.NoError(t, err)
+				_, out, err := s.handleDeleteRelations(context.Background(), DeleteRelationsParams{Relations: tc.deletions})
+				assert.NoError(t, err)
+				result := structuredAs[*database.RelationDeletionResult](t, out)
+				assert.Equal(t, tc.deleted, result.DeletedRelations)
+				assert.Len(t, result.NotFound, len(tc.deletions)-tc.deleted)
 
-			res, _, err := s.handleReadGraph(context.Background())
-			assert.NoError(t, err)
-			var g database.KnowledgeGraph
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
-			assert.Len(t, g.Relations, tc.wantRel)
-		})
+				res, _, err := s.handleReadGraph(context.Background(), ReadGraphParams{})
+				assert.NoError(t, err)
+				var g database.KnowledgeGraph
+				assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
+				assert.Len(t, g.Relations, tc.wantRel)
+			})
+		}
 	}
 }
 
@@ -431,27 +786,20 @@ func TestServer_SearchNodes_Edges(t *testing.T) {
 	assert.NoError(t, err)
 
 	// case-insensitive search
-	res, _, err := s.handleSearchNodes(context.Background(), SearchNodesParams{Query: "apple"})
+	_, out, err := s.handleSearchNodes(context.Background(), SearchNodesParams{Query: "apple"})
 	assert.NoError(t, err)
-	g := unmarshalJSON[database.KnowledgeGraph](t, res)
+	g := structuredAs[*database.KnowledgeGraph](t, out)
 	assert.Len(t, g.Entities, 1)
 	assert.Equal(t, "Apple", g.Entities[0].Name)
 
 	// empty query returns all
-	res, _, err = s.handleSearchNodes(context.Background(), SearchNodesParams{Query: ""})
+	_, out, err = s.handleSearchNodes(context.Background(), SearchNodesParams{Query: ""})
 	assert.NoError(t, err)
-	g = unmarshalJSON[database.KnowledgeGraph](t, res)
+	g = structuredAs[*database.KnowledgeGraph](t, out)
 	assert.GreaterOrEqual(t, len(g.Entities), 2)
 }
 
 func TestServer_SearchNodes_Table(t *testing.T) {
-	s, _ := newTestServer(t)
-	_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{
-		{Name: "Apple", EntityType: "Fruit", Observations: []string{"Red and tasty"}},
-		{Name: "Banana", EntityType: "Fruit", Observations: []string{"Yellow and sweet"}},
-	}})
-	assert.NoError(t, err)
-
 	cases := []struct {
 		name   string
 		query  string
@@ -462,13 +810,23 @@ func TestServer_SearchNodes_Table(t *testing.T) {
 		{name: "unmatched", query: "zebra", wantCt: 0},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			res, _, err := s.handleSearchNodes(context.Background(), SearchNodesParams{Query: tc.query})
+	for backend, newServer := range testBackends() {
+		t.Run(backend, func(t *testing.T) {
+			s := newServer(t)
+			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{
+				{Name: "Apple", EntityType: "Fruit", Observations: []string{"Red and tasty"}},
+				{Name: "Banana", EntityType: "Fruit", Observations: []string{"Yellow and sweet"}},
+			}})
 			assert.NoError(t, err)
-			var g database.KnowledgeGraph
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
-			assert.Len(t, g.Entities, tc.wantCt)
+			for _, tc := range cases {
+				t.Run(tc.name, func(t *testing.T) {
+					res, _, err := s.handleSearchNodes(context.Background(), SearchNodesParams{Query: tc.query})
+					assert.NoError(t, err)
+					var g database.KnowledgeGraph
+					assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
+					assert.Len(t, g.Entities, tc.wantCt)
+				})
+			}
 		})
 	}
 }
@@ -481,34 +839,28 @@ func TestServer_OpenNodes_Edges(t *testing.T) {
 	assert.NoError(t, err)
 
 	// open two with no relation between them
-	res, _, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"E1", "E3"}})
+	_, out, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"E1", "E3"}})
 	assert.NoError(t, err)
-	g := unmarshalJSON[database.KnowledgeGraph](t, res)
+	g := structuredAs[*database.KnowledgeGraph](t, out)
 	assert.Len(t, g.Entities, 2)
 	assert.Len(t, g.Relations, 0)
 
 	// duplicates and unknown filtered
-	res, _, err = s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"E1", "E1", "unknown"}})
+	_, out, err = s.handleOpenNodes(context.Background(), OpenNodesParams{Names: []string{"E1", "E1", "unknown"}})
 	assert.NoError(t, err)
-	g = unmarshalJSON[database.KnowledgeGraph](t, res)
+	g = structuredAs[*database.KnowledgeGraph](t, out)
 	assert.Len(t, g.Entities, 1)
 	assert.Equal(t, "E1", g.Entities[0].Name)
 
 	// empty input
-	res, _, err = s.handleOpenNodes(context.Background(), OpenNodesParams{Names: nil})
+	_, out, err = s.handleOpenNodes(context.Background(), OpenNodesParams{Names: nil})
 	assert.NoError(t, err)
-	g = unmarshalJSON[database.KnowledgeGraph](t, res)
+	g = structuredAs[*database.KnowledgeGraph](t, out)
 	assert.Len(t, g.Entities, 0)
 	assert.Len(t, g.Relations, 0)
 }
 
 func TestServer_OpenNodes_Table(t *testing.T) {
-	s, _ := newTestServer(t)
-	_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "E1", EntityType: "T"}, {Name: "E2", EntityType: "T"}, {Name: "E3", EntityType: "T"}}})
-	assert.NoError(t, err)
-	_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "E1", To: "E2", RelationType: "rel"}}})
-	assert.NoError(t, err)
-
 	cases := []struct {
 		name    string
 		names   []string
@@ -520,14 +872,23 @@ func TestServer_OpenNodes_Table(t *testing.T) {
 		{name: "empty", names: nil, wantEnt: 0, wantRel: 0},
 	}
 
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			res, _, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: tc.names})
+	for backend, newServer := range testBackends() {
+		t.Run(backend, func(t *testing.T) {
+			s := newServer(t)
+			_, _, err := s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "E1", EntityType: "T"}, {Name: "E2", EntityType: "T"}, {Name: "E3", EntityType: "T"}}})
 			assert.NoError(t, err)
-			var g database.KnowledgeGraph
-			assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
-			assert.Len(t, g.Entities, tc.wantEnt)
-			assert.Len(t, g.Relations, tc.wantRel)
+			_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{{From: "E1", To: "E2", RelationType: "rel"}}})
+			assert.NoError(t, err)
+			for _, tc := range cases {
+				t.Run(tc.name, func(t *testing.T) {
+					res, _, err := s.handleOpenNodes(context.Background(), OpenNodesParams{Names: tc.names})
+					assert.NoError(t, err)
+					var g database.KnowledgeGraph
+					assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &g))
+					assert.Len(t, g.Entities, tc.wantEnt)
+					assert.Len(t, g.Relations, tc.wantRel)
+				})
+			}
 		})
 	}
 }
@@ -546,3 +907,3737 @@ func TestServer_RegisterTools_Smoke(t *testing.T) {
 	// should not panic or error when registering tools
 	s.RegisterTools(m)
 }
+
+func TestServer_LogRedaction_MasksSecretsButKeepsData(t *testing.T) {
+	const secret = "sk-live0123456789abcdefghij"
+
+	var buf bytes.Buffer
+	redactor, err := logging.NewRedactor([]string{`corp-[0-9]{6}`})
+	assert.NoError(t, err)
+	logger := logging.WithRedaction(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), redactor)
+
+	db, err := database.NewDBWithLogger("file::memory:?cache=shared", logger)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	s := NewServerWithLogger(db, logger)
+
+	_, _, err = s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Deploy", EntityType: "service", Observations: []string{"uses key " + secret}},
+	}})
+	assert.NoError(t, err)
+
+	// debug logging names the entity and counts the observations, without their contents
+	_, _, err = s.handleAddObservations(context.Background(), AddObservationsParams{Observations: []ObservationInput{{
+		EntityName: "Deploy",
+		Contents:   []string{"rotated to " + secret + " and corp-123456"},
+	}}})
+	assert.NoError(t, err)
+
+	// error path: missing entity whose name carries the secret
+	_, _, err = s.handleAddObservations(context.Background(), AddObservationsParams{Observations: []ObservationInput{{
+		EntityName: "key " + secret,
+		Contents:   []string{"x"},
+	}}})
+	assert.Error(t, err)
+
+	logs := buf.String()
+	assert.Contains(t, logs, "add_observations names a missing entity")
+	assert.Contains(t, logs, logging.RedactedPlaceholder)
+	assert.NotContains(t, logs, secret)
+	assert.NotContains(t, logs, "corp-123456")
+	assert.Contains(t, logs, `msg="adding observations" entity=Deploy count=1`)
+	assert.NotContains(t, logs, "rotated to")
+
+	// stored data is untouched
+	g, err := db.OpenNodes(context.Background(), []string{"Deploy"})
+	assert.NoError(t, err)
+	assert.Len(t, g.Entities, 1)
+	assert.Contains(t, g.Entities[0].Observations, "uses key "+secret)
+	assert.Contains(t, g.Entities[0].Observations, "rotated to "+secret+" and corp-123456")
+}
+
+func TestServer_LargeResults_ReturnResourceLink(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := database.NewDBWithLogger("file::memory:?cache=shared", logger)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	s := NewServerWithOptions(db, logger, Options{ResultLinkThreshold: 512, ResultPageSize: 7})
+
+	entities := make([]database.EntityWithObservations, 25)
+	for i := range entities {
+		entities[i] = database.EntityWithObservations{Name: fmt.Sprintf("Match_%02d", i), EntityType: "thing", Observations: []string{"match me"}}
+	}
+	_, _, err = s.handleCreateEntities(context.Background(), CreateEntitiesParams{Entities: entities})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(context.Background(), CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Match_00", To: "Match_01", RelationType: "next"},
+		{From: "Match_01", To: "Match_02", RelationType: "next"},
+	}})
+	assert.NoError(t, err)
+
+	// Under the threshold, results stay inline
+	res, _, err := s.handleSearchNodes(context.Background(), SearchNodesParams{Query: "Match_03"})
+	assert.NoError(t, err)
+	assert.Len(t, res.Content, 1)
+	assert.Len(t, unmarshalJSON[database.KnowledgeGraph](t, res).Entities, 1)
+
+	// Over the threshold, a summary and a resource link are returned
+	res, _, err = s.handleSearchNodes(context.Background(), SearchNodesParams{Query: "match"})
+	assert.NoError(t, err)
+	assert.Len(t, res.Content, 2)
+	assert.Contains(t, jsonText(t, res), "25 entities, 2 relations")
+	link, ok := res.Content[1].(*mcp.ResourceLink)
+	assert.True(t, ok)
+	assert.Contains(t, link.URI, ResultURIPrefix)
+
+	// Follow the link through a real client session, walking every page
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	_, err = m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	var got database.KnowledgeGraph
+	offset := 0
+	for pages := 0; ; pages++ {
+		assert.Less(t, pages, 10)
+		rr, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: fmt.Sprintf("%s?offset=%d", link.URI, offset)})
+		assert.NoError(t, err)
+		var p ResultPage
+		assert.NoError(t, json.Unmarshal([]byte(rr.Contents[0].Text), &p))
+		assert.LessOrEqual(t, len(p.Entities)+len(p.Relations), 7)
+		assert.Equal(t, 27, p.Total)
+		got.Entities = append(got.Entities, p.Entities...)
+		got.Relations = append(got.Relations, p.Relations...)
+		if p.NextOffset == nil {
+			break
+		}
+		offset = *p.NextOffset
+	}
+	full, err := db.SearchNodes(ctx, "match", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, full.Entities, got.Entities)
+	assert.Equal(t, full.Relations, got.Relations)
+
+	// Results expire after the TTL
+	now := time.Now()
+	s.results.now = func() time.Time { return now.Add(DefaultResultTTL + time.Second) }
+	_, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: link.URI})
+	assert.Error(t, err)
+	assert.Empty(t, s.results.results)
+}
+
+func TestServer_CancelledToolCall_StopsAndCommitsNothing(t *testing.T) {
+	s, db := newTestServer(t)
+
+	entities := make([]database.EntityWithObservations, MaxEntitiesPerRequest)
+	for i := range entities {
+		obs := make([]string, MaxObservationsPerEntity)
+		for j := range obs {
+			obs[j] = fmt.Sprintf("observation %d of entity %d", j, i)
+		}
+		entities[i] = database.EntityWithObservations{Name: fmt.Sprintf("Bulk_%04d", i), EntityType: "thing", Observations: obs}
+	}
+
+	// Hold the tool call until the client's cancellation reaches it, then capture the
+	// server-side outcome, which the cancelled client never sees
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	m.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if method == "tools/call" {
+				close(started)
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+					t.Error("cancellation did not reach the tool call")
+				}
+			}
+			res, err := next(ctx, method, req)
+			if method == "tools/call" {
+				if r, ok := res.(*mcp.CallToolResult); ok && err == nil && r.IsError {
+					err = fmt.Errorf("%v", r.Content[0].(*mcp.TextContent).Text)
+				}
+				done <- err
+			}
+			return res, err
+		}
+	})
+	s.RegisterTools(m)
+
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	_, err := m.Connect(context.Background(), serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(context.Background(), clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "create_entities",
+		Arguments: CreateEntitiesParams{Entities: entities},
+	})
+	assert.Error(t, err)
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not stop after cancellation")
+	}
+
+	graph, err := db.ReadGraph(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+}
+
+func TestServer_OperationError_Cancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, _ := newTestServer(t)
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "t", Observations: []string{"x"}},
+	}})
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "operation cancelled")
+}
+
+func TestServer_ReadGraph_Paging(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "t"}, {Name: "B", EntityType: "t"}, {Name: "C", EntityType: "t"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "A", To: "B", RelationType: "knows"},
+		{From: "B", To: "C", RelationType: "knows"},
+	}})
+	assert.NoError(t, err)
+
+	// Without paging parameters the whole graph comes back, with no cursor
+	res, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.NotContains(t, jsonText(t, res), "nextCursor")
+	assert.Len(t, unmarshalJSON[database.KnowledgeGraph](t, res).Entities, 3)
+
+	_, out, err := s.handleReadGraph(ctx, ReadGraphParams{Limit: 2})
+	assert.NoError(t, err)
+	page := structuredAs[*database.GraphPage](t, out)
+	assert.Len(t, page.Entities, 2)
+	assert.Equal(t, []database.RelationDTO{{From: "A", To: "B", RelationType: "knows"}}, page.Relations)
+	assert.Equal(t, "B", page.NextCursor)
+
+	_, out, err = s.handleReadGraph(ctx, ReadGraphParams{Cursor: page.NextCursor, IncludeTimestamps: true})
+	assert.NoError(t, err)
+	page = structuredAs[*database.GraphPage](t, out)
+	if assert.Len(t, page.Entities, 1) {
+		assert.Equal(t, "C", page.Entities[0].Name)
+		assert.NotEmpty(t, page.Entities[0].CreatedAt)
+	}
+	assert.Empty(t, page.Relations)
+	assert.Empty(t, page.NextCursor)
+
+	for _, limit := range []int{-1, MaxGraphPageSize + 1} {
+		_, _, err = s.handleReadGraph(ctx, ReadGraphParams{Limit: limit})
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr) {
+			assert.Equal(t, i18n.ErrInvalidPageLimit, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_SearchNodes_Paging(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "P1", EntityType: "project"}, {Name: "P2", EntityType: "project"}, {Name: "P3", EntityType: "project"},
+	}})
+	assert.NoError(t, err)
+
+	// Unpaged results are the plain graph
+	res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "project"})
+	assert.NoError(t, err)
+	assert.NotContains(t, jsonText(t, res), "totalMatches")
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "project", Limit: 2})
+	assert.NoError(t, err)
+	page := unmarshalJSON[database.SearchResult](t, res)
+	assert.Len(t, page.Entities, 2)
+	assert.Equal(t, 3, page.TotalMatches)
+	if assert.NotNil(t, page.NextOffset) {
+		assert.Equal(t, 2, *page.NextOffset)
+	}
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "project", Offset: 2})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.SearchResult](t, res)
+	if assert.Len(t, page.Entities, 1) {
+		assert.Equal(t, "P3", page.Entities[0].Name)
+	}
+	assert.Nil(t, page.NextOffset)
+
+	for _, params := range []SearchNodesParams{
+		{Query: "project", Limit: -1},
+		{Query: "project", Limit: MaxSearchPageSize + 1},
+		{Query: "project", Offset: -1},
+	} {
+		_, _, err := s.handleSearchNodes(ctx, params)
+		var toolErr *ToolError
+		assert.ErrorAs(t, err, &toolErr, "%+v", params)
+	}
+}
+
+func TestServer_SearchNodes_Modes(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"Works with Alice Cooper"}},
+		{Name: "Alice Cooper", EntityType: "person"},
+	}})
+	assert.NoError(t, err)
+
+	names := func(params SearchNodesParams) []string {
+		t.Helper()
+		res, _, err := s.handleSearchNodes(ctx, params)
+		assert.NoError(t, err)
+		var out []string
+		for _, e := range unmarshalJSON[database.KnowledgeGraph](t, res).Entities {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"Alice", "Alice Cooper"}, names(SearchNodesParams{Query: "Alice"}))
+	assert.Equal(t, []string{"Alice"}, names(SearchNodesParams{Query: "Alice", Mode: database.SearchExact}))
+	assert.Equal(t, []string{"Alice Cooper"}, names(SearchNodesParams{Query: "Alice C", Mode: database.SearchPrefix}))
+
+	_, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "Alice", Mode: "fuzzy"})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrInvalidSearchMode, toolErr.Code)
+	}
+}
+
+func TestServer_SearchNodes_FTS5Syntax(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"golang project"}},
+		{Name: "Engine", EntityType: "service", Observations: []string{"rust project"}},
+	}})
+	assert.NoError(t, err)
+
+	code := func(params SearchNodesParams) string {
+		t.Helper()
+		_, _, err := s.handleSearchNodes(ctx, params)
+		var toolErr *ToolError
+		if !assert.ErrorAs(t, err, &toolErr, "%+v", params) {
+			return ""
+		}
+		return toolErr.Code
+	}
+	assert.Equal(t, i18n.ErrInvalidSearchSyntax, code(SearchNodesParams{Query: "x", Syntax: "regex"}))
+	assert.Equal(t, i18n.ErrSearchSyntaxMode, code(SearchNodesParams{Query: "x", Syntax: SearchSyntaxFTS5, Mode: database.SearchExact}))
+
+	if !db.IsFTSEnabled() {
+		assert.Equal(t, i18n.ErrFTSUnavailable, code(SearchNodesParams{Query: "golang", Syntax: SearchSyntaxFTS5}))
+		return
+	}
+
+	res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "project AND (golang OR python)", Syntax: SearchSyntaxFTS5})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Gateway", graph.Entities[0].Name)
+	}
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: `"rust project"`, Syntax: SearchSyntaxFTS5})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Engine", graph.Entities[0].Name)
+	}
+
+	// Malformed expressions are reported rather than searched with LIKE
+	assert.Equal(t, i18n.ErrInvalidFTSQuery, code(SearchNodesParams{Query: "golang AND (", Syntax: SearchSyntaxFTS5}))
+}
+
+func TestServer_SearchNodes_Ranked(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Aardvark", EntityType: "animal", Observations: []string{"eats termites"}},
+		{Name: "Termites", EntityType: "insect"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "termites", Ranked: true})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if !assert.Len(t, graph.Entities, 2) {
+		return
+	}
+	if !db.IsFTSEnabled() {
+		// The ordinary search runs, in name order and without scores
+		assert.Equal(t, "Aardvark", graph.Entities[0].Name)
+		assert.NotContains(t, jsonText(t, res), `"score"`)
+		return
+	}
+	assert.Equal(t, "Termites", graph.Entities[0].Name, "name matches rank above observation matches")
+	assert.Equal(t, database.ScoreNameMatch, graph.Entities[0].Score)
+	assert.Equal(t, "Aardvark", graph.Entities[1].Name)
+	assert.Equal(t, database.ScoreObservationMatch, graph.Entities[1].Score)
+}
+
+func TestServer_SearchNodes_IncludeSnippets(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Billing", EntityType: "service", Observations: []string{"written in go", "invoices are emailed nightly", "owned by finance"}},
+		{Name: "Invoices", EntityType: "table"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "invoices", IncludeSnippets: true})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	matches := map[string][]string{}
+	for _, e := range graph.Entities {
+		if e.Matches != nil {
+			matches[e.Name] = e.Matches
+		}
+		if e.Name == "Billing" {
+			assert.Len(t, e.Observations, 3, "the observations are all still there")
+		}
+	}
+	want := "invoices are emailed nightly"
+	if db.IsFTSEnabled() {
+		want = "**invoices** are emailed nightly"
+	}
+	assert.Equal(t, map[string][]string{"Billing": {want}}, matches)
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "invoices"})
+	assert.NoError(t, err)
+	assert.NotContains(t, jsonText(t, res), `"matches"`)
+}
+
+func TestServer_GetObservations_Paging(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "t", Observations: []string{"o1", "o2", "o3"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGetObservations(ctx, GetObservationsParams{EntityName: "A", Limit: 2})
+	assert.NoError(t, err)
+	page := unmarshalJSON[database.ObservationPage](t, res)
+	assert.Equal(t, []string{"o1", "o2"}, page.Observations)
+	assert.Equal(t, 3, page.TotalObservations)
+	if assert.NotNil(t, page.NextOffset) {
+		assert.Equal(t, 2, *page.NextOffset)
+	}
+
+	res, _, err = s.handleGetObservations(ctx, GetObservationsParams{EntityName: "A", Offset: 2})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.ObservationPage](t, res)
+	assert.Equal(t, []string{"o3"}, page.Observations)
+	assert.Nil(t, page.NextOffset)
+
+	for _, params := range []GetObservationsParams{
+		{EntityName: ""},
+		{EntityName: "A", Limit: MaxObservationPageSize + 1},
+		{EntityName: "A", Offset: -1},
+		{EntityName: "A", OrderBy: "sideways"},
+		{EntityName: "Missing"},
+	} {
+		_, _, err := s.handleGetObservations(ctx, params)
+		assert.Error(t, err, "%+v", params)
+	}
+}
+
+func TestServer_GetEntity(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "Acme", EntityType: "company", Observations: []string{"founded 1999"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGetEntity(ctx, GetEntityParams{Name: "Alice"})
+	assert.NoError(t, err)
+	found := unmarshalJSON[entityLookup](t, res)
+	assert.True(t, found.Found)
+	if assert.NotNil(t, found.Entity) {
+		assert.Equal(t, []string{"engineer"}, found.Entity.Observations)
+		assert.Len(t, found.Entity.Outgoing, 1)
+		assert.Empty(t, found.Entity.Incoming)
+	}
+
+	// An entity with only incoming relations
+	res, _, err = s.handleGetEntity(ctx, GetEntityParams{Name: "Acme"})
+	assert.NoError(t, err)
+	found = unmarshalJSON[entityLookup](t, res)
+	if assert.NotNil(t, found.Entity) {
+		assert.Equal(t, "company", found.Entity.EntityType)
+		assert.Equal(t, []database.EntityRelation{
+			{From: "Alice", FromEntityType: "person", To: "Acme", ToEntityType: "company", RelationType: "works_at"},
+		}, found.Entity.Incoming)
+		assert.Empty(t, found.Entity.Outgoing)
+	}
+
+	_, _, err = s.handleGetEntity(ctx, GetEntityParams{Name: ""})
+	assert.Error(t, err)
+
+	// Not found is a regular result, not a tool or transport error
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	_, err = m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "get_entity", Arguments: GetEntityParams{Name: "Nobody"}})
+	assert.NoError(t, err)
+	assert.False(t, res.IsError)
+	assert.JSONEq(t, `{"name": "Nobody", "found": false}`, jsonText(t, res))
+}
+
+func TestServer_RecentEntities(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"designer"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleRecentEntities(ctx, RecentEntitiesParams{Limit: 1})
+	assert.NoError(t, err)
+	recent := unmarshalJSON[struct {
+		Entities []database.EntityWithObservations `json:"entities"`
+	}](t, res)
+	if assert.Len(t, recent.Entities, 1) {
+		assert.Equal(t, "Bob", recent.Entities[0].Name, "the newest entity first")
+		assert.Equal(t, []string{"designer"}, recent.Entities[0].Observations)
+		assert.NotEmpty(t, recent.Entities[0].UpdatedAt)
+	}
+
+	res, _, err = s.handleRecentEntities(ctx, RecentEntitiesParams{})
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[struct {
+		Entities []database.EntityWithObservations `json:"entities"`
+	}](t, res).Entities, 2)
+
+	for _, limit := range []int{-1, MaxRecentEntities + 1} {
+		_, _, err := s.handleRecentEntities(ctx, RecentEntitiesParams{Limit: limit})
+		assert.Error(t, err, limit)
+	}
+}
+
+func TestServer_ListEntityTypes(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Apollo", EntityType: "project"},
+		{Name: "Mercury", EntityType: "project_archived"},
+	}})
+	assert.NoError(t, err)
+
+	type listing struct {
+		EntityTypes []database.EntityTypeCount `json:"entityTypes"`
+	}
+	tests := []struct {
+		name    string
+		params  ListEntityTypesParams
+		want    []database.EntityTypeCount
+		wantErr string
+	}{
+		{
+			name:   "all types, most used first",
+			params: ListEntityTypesParams{},
+			want:   []database.EntityTypeCount{{EntityType: "person", Count: 2}, {EntityType: "project", Count: 1}, {EntityType: "project_archived", Count: 1}},
+		},
+		{
+			name:   "prefix",
+			params: ListEntityTypesParams{Prefix: "proj"},
+			want:   []database.EntityTypeCount{{EntityType: "project", Count: 1}, {EntityType: "project_archived", Count: 1}},
+		},
+		{
+			name:   "no match",
+			params: ListEntityTypesParams{Prefix: "robot"},
+			want:   []database.EntityTypeCount{},
+		},
+		{
+			name:    "prefix too long",
+			params:  ListEntityTypesParams{Prefix: strings.Repeat("p", MaxEntityTypeLength+1)},
+			wantErr: i18n.ErrEntityTypeTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, _, err := s.handleListEntityTypes(ctx, tt.params)
+			if tt.wantErr != "" {
+				var toolErr *ToolError
+				if assert.ErrorAs(t, err, &toolErr) {
+					assert.Equal(t, tt.wantErr, toolErr.Code)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, unmarshalJSON[listing](t, res).EntityTypes)
+		})
+	}
+}
+
+func TestServer_ListRelationTypes(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	}})
+	assert.NoError(t, err)
+
+	type listing struct {
+		RelationTypes []database.RelationTypeCount `json:"relationTypes"`
+	}
+	list := func(minCount int) []database.RelationTypeCount {
+		res, _, err := s.handleListRelationTypes(ctx, ListRelationTypesParams{MinCount: minCount})
+		assert.NoError(t, err)
+		return unmarshalJSON[listing](t, res).RelationTypes
+	}
+	assert.Equal(t, []database.RelationTypeCount{{RelationType: "works_at", Count: 2}, {RelationType: "knows", Count: 1}}, list(0))
+	assert.Equal(t, []database.RelationTypeCount{{RelationType: "works_at", Count: 2}}, list(2))
+
+	_, _, err = s.handleDeleteRelations(ctx, DeleteRelationsParams{Relations: []database.RelationDTO{
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RelationTypeCount{{RelationType: "works_at", Count: 1}}, list(0))
+	assert.Equal(t, []database.RelationTypeCount{}, list(2))
+
+	_, _, err = s.handleListRelationTypes(ctx, ListRelationTypesParams{MinCount: -1})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNegativeMinCount, toolErr.Code)
+	}
+}
+
+func TestServer_GetRelations(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	const followers = 1500
+	entities := []database.EntityWithObservations{{Name: "Hub", EntityType: "account"}, {Name: "Quiet", EntityType: "account"}}
+	relations := []database.RelationDTO{{From: "Hub", To: "Quiet", RelationType: "follows"}}
+	for i := range followers {
+		name := fmt.Sprintf("Follower %04d", i)
+		entities = append(entities, database.EntityWithObservations{Name: name, EntityType: "person"})
+		relationType := "follows"
+		if i%5 == 0 {
+			relationType = "blocks"
+		}
+		relations = append(relations, database.RelationDTO{From: name, To: "Hub", RelationType: relationType})
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+
+	// The hub's inbound edges stay within the default page size
+	res, _, err := s.handleGetRelations(ctx, "get_inbound_relations", database.RelationsInbound, GetRelationsParams{EntityName: "Hub"})
+	assert.NoError(t, err)
+	page := unmarshalJSON[database.RelationPage](t, res)
+	assert.Len(t, page.Relations, DefaultRelationPageSize)
+	assert.Equal(t, followers, page.TotalRelations)
+	assert.Equal(t, database.EntityRelation{
+		From: "Follower 0000", FromEntityType: "person", To: "Hub", ToEntityType: "account", RelationType: "blocks",
+	}, page.Relations[0])
+
+	res, _, err = s.handleGetRelations(ctx, "get_inbound_relations", database.RelationsInbound, GetRelationsParams{
+		EntityName: "Hub", RelationType: "blocks", Limit: MaxRelationPageSize, Offset: 250,
+	})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.RelationPage](t, res)
+	assert.Len(t, page.Relations, followers/5-250)
+	assert.Nil(t, page.NextOffset)
+
+	res, _, err = s.handleGetRelations(ctx, "get_outbound_relations", database.RelationsOutbound, GetRelationsParams{EntityName: "Hub"})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.RelationPage](t, res)
+	assert.Equal(t, []database.EntityRelation{
+		{From: "Hub", FromEntityType: "account", To: "Quiet", ToEntityType: "account", RelationType: "follows"},
+	}, page.Relations)
+
+	res, _, err = s.handleGetRelations(ctx, "get_outbound_relations", database.RelationsOutbound, GetRelationsParams{EntityName: "Quiet"})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.RelationPage](t, res)
+	assert.Empty(t, page.Relations)
+	assert.Zero(t, page.TotalRelations)
+
+	for _, params := range []GetRelationsParams{
+		{EntityName: ""},
+		{EntityName: "Hub", RelationType: strings.Repeat("r", MaxRelationTypeLength+1)},
+		{EntityName: "Hub", Limit: MaxRelationPageSize + 1},
+		{EntityName: "Hub", Offset: -1},
+	} {
+		_, _, err := s.handleGetRelations(ctx, "get_inbound_relations", database.RelationsInbound, params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, "%+v", params) {
+			assert.NotEqual(t, i18n.ErrGetRelations, toolErr.Code)
+		}
+	}
+	_, _, err = s.handleGetRelations(ctx, "get_inbound_relations", database.RelationsInbound, GetRelationsParams{EntityName: "Missing"})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrEntityNotFound, toolErr.Code)
+		assert.Equal(t, map[string]any{"entityName": "Missing"}, toolErr.Details)
+	}
+}
+
+func TestServer_GetMaintenanceStatus(t *testing.T) {
+	s, db := newTestServer(t)
+
+	res, _, err := s.handleGetMaintenanceStatus(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, unmarshalJSON[maintenance.Status](t, res).Enabled)
+
+	schedule, err := maintenance.ParseSchedule("03:00")
+	assert.NoError(t, err)
+	scheduler := maintenance.NewScheduler(schedule, db, nil)
+	scheduler.Register(maintenance.Job{Name: "optimize", Run: db.Optimize})
+	s.opts.Maintenance = scheduler
+
+	res, _, err = s.handleGetMaintenanceStatus(context.Background())
+	assert.NoError(t, err)
+	status := unmarshalJSON[maintenance.Status](t, res)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "daily at 03:00", status.Schedule)
+	if assert.Len(t, status.Jobs, 1) {
+		assert.Equal(t, "optimize", status.Jobs[0].Name)
+		assert.Zero(t, status.Jobs[0].Runs)
+	}
+}
+
+func TestServer_CreateEntities_OnDuplicate(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "T", Observations: []string{"old"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnDuplicate: database.DuplicateAppendObservations,
+		Entities: []database.EntityWithObservations{
+			{Name: "A", EntityType: "T", Observations: []string{"old", "new"}},
+			{Name: "B", EntityType: "T"},
+		},
+	})
+	assert.NoError(t, err)
+	results := unmarshalJSON[[]database.EntityCreateResult](t, res)
+	assert.Equal(t, []database.EntityCreateResult{
+		{Name: "A", EntityType: "T", Outcome: database.OutcomeObservationsAppended, Observations: []string{"new"}, SkippedObservations: []string{"old"}},
+		{Name: "B", EntityType: "T", Outcome: database.OutcomeCreated, Observations: []string{}},
+	}, results)
+
+	res, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnDuplicate: database.DuplicateSkip,
+		Entities:    []database.EntityWithObservations{{Name: "B", EntityType: "T", Observations: []string{"lost"}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, database.OutcomeSkipped, unmarshalJSON[[]database.EntityCreateResult](t, res)[0].Outcome)
+
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnDuplicate: database.DuplicateError,
+		Entities:    []database.EntityWithObservations{{Name: "C", EntityType: "T"}, {Name: "A", EntityType: "T"}},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnDuplicate: "overwrite",
+		Entities:    []database.EntityWithObservations{{Name: "A", EntityType: "T"}},
+	})
+	assert.ErrorContains(t, err, "validation error")
+
+	g, err := s.db.OpenNodes(ctx, []string{"A", "C"})
+	assert.NoError(t, err)
+	assert.Len(t, g.Entities, 1)
+	assert.Equal(t, []string{"old", "new"}, g.Entities[0].Observations)
+}
+
+func TestServer_CreateEntities_OnConflict(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "A", EntityType: "T", Observations: []string{"old"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnConflict: database.DuplicateSkip,
+		Entities: []database.EntityWithObservations{
+			{Name: "A", EntityType: "T", Observations: []string{"old", "lost"}},
+			{Name: "B", EntityType: "T", Observations: []string{"first"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, entityConflicts{
+		Created: []database.EntityCreateResult{
+			{Name: "B", EntityType: "T", Outcome: database.OutcomeCreated, Observations: []string{"first"}},
+		},
+		Merged:  []database.EntityCreateResult{},
+		Skipped: []string{"A"},
+	}, unmarshalJSON[entityConflicts](t, res))
+
+	res, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnConflict: database.DuplicateMergeObservations,
+		Entities: []database.EntityWithObservations{
+			{Name: "A", EntityType: "T", Observations: []string{"old", "new", "new"}},
+			{Name: "C", EntityType: "T"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, entityConflicts{
+		Created: []database.EntityCreateResult{
+			{Name: "C", EntityType: "T", Outcome: database.OutcomeCreated, Observations: []string{}},
+		},
+		Merged: []database.EntityCreateResult{
+			{Name: "A", EntityType: "T", Outcome: database.OutcomeObservationsAppended, Observations: []string{"new"}, SkippedObservations: []string{"old"}},
+		},
+		Skipped: []string{},
+	}, unmarshalJSON[entityConflicts](t, res))
+
+	// Merging again adds nothing
+	res, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		OnConflict: database.DuplicateMergeObservations,
+		Entities:   []database.EntityWithObservations{{Name: "A", EntityType: "T", Observations: []string{"new", "old"}}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[entityConflicts](t, res).Merged[0].Observations)
+
+	g, err := s.db.OpenNodes(ctx, []string{"A", "B"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old", "new"}, g.Entities[0].Observations)
+	assert.Equal(t, []string{"first"}, g.Entities[1].Observations)
+
+	for code, params := range map[string]CreateEntitiesParams{
+		i18n.ErrInvalidOnConflict:      {OnConflict: database.DuplicateAppendObservations},
+		i18n.ErrConflictingOnDuplicate: {OnConflict: database.DuplicateSkip, OnDuplicate: database.DuplicateSkip},
+	} {
+		params.Entities = []database.EntityWithObservations{{Name: "D", EntityType: "T"}}
+		_, _, err := s.handleCreateEntities(ctx, params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_AddObservations_IfAbsentSimilar(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "CI", EntityType: "service", Observations: []string{"build 123 failed"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleAddObservations(ctx, AddObservationsParams{
+		IfAbsentSimilar: 0.9,
+		Observations:    []ObservationInput{{EntityName: "CI", Contents: []string{"Build #123 failed", "flaky test quarantined"}}},
+	})
+	assert.NoError(t, err)
+	results := unmarshalJSON[[]database.ObservationAdditionResult](t, res)
+	assert.Equal(t, []string{"flaky test quarantined"}, results[0].AddedObservations)
+	assert.Equal(t, []database.SimilarObservation{
+		{Content: "Build #123 failed", Existing: "build 123 failed", Similarity: 1},
+	}, results[0].SkippedAsSimilar)
+
+	// Opt-in: without ifAbsentSimilar the near-duplicate is added
+	res, _, err = s.handleAddObservations(ctx, AddObservationsParams{
+		Observations: []ObservationInput{{EntityName: "CI", Contents: []string{"Build #123 failed"}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Build #123 failed"}, unmarshalJSON[[]database.ObservationAdditionResult](t, res)[0].AddedObservations)
+
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{
+		IfAbsentSimilar: 1.5,
+		Observations:    []ObservationInput{{EntityName: "CI", Contents: []string{"x"}}},
+	})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrInvalidSimilarity, toolErr.Code)
+	}
+}
+
+func TestServer_OpenNodes_IncludeMetadata(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(database.WithWriter(ctx, "planner-agent"), CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Plan", EntityType: "doc", Observations: []string{"drafted"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(database.WithWriter(ctx, "reviewer-agent"), AddObservationsParams{
+		Observations: []ObservationInput{{EntityName: "Plan", Contents: []string{"reviewed"}}},
+	})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Plan"}})
+	assert.NoError(t, err)
+	assert.NotContains(t, res.Content[0].(*mcp.TextContent).Text, "metadata")
+
+	_, out, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Plan"}, IncludeMetadata: true})
+	assert.NoError(t, err)
+	graph := structuredAs[*nodesWithMetadata](t, out)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, []string{"drafted", "reviewed"}, graph.Entities[0].Observations)
+		assert.Equal(t, 2, graph.Entities[0].Metadata.Contributors)
+		assert.Equal(t, "reviewer-agent", graph.Entities[0].Metadata.LastWriter)
+		assert.NotEmpty(t, graph.Entities[0].Metadata.LastWriteAt)
+	}
+}
+
+func TestServer_IncludeTimestamps(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := db.ImportJSONL(ctx, strings.NewReader(
+		`{"v":1,"kind":"entity","name":"Plan","entityType":"doc","observations":[{"content":"drafted","createdAt":"2024-03-01T09:00:00Z"}]}`+"\n"+
+			`{"v":1,"kind":"entity","name":"Review","entityType":"doc","observations":[]}`+"\n"+
+			`{"v":1,"kind":"relation","from":"Review","to":"Plan","relationType":"covers"}`+"\n",
+	))
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{
+		Observations: []ObservationInput{{EntityName: "Plan", Contents: []string{"reviewed"}}},
+	})
+	assert.NoError(t, err)
+
+	parse := func(value string) time.Time {
+		ts, err := time.Parse(time.RFC3339, value)
+		assert.NoError(t, err, value)
+		return ts
+	}
+	check := func(graph database.KnowledgeGraph) {
+		if !assert.Len(t, graph.Entities, 2) || !assert.Len(t, graph.Relations, 1) {
+			return
+		}
+		plan := graph.Entities[0]
+		assert.Equal(t, []string{"drafted", "reviewed"}, plan.Observations)
+		if assert.Len(t, plan.ObservationsCreatedAt, 2) {
+			assert.Equal(t, "2024-03-01T09:00:00Z", plan.ObservationsCreatedAt[0])
+			// Adding an observation moved the entity's update time with it
+			assert.False(t, parse(plan.UpdatedAt).Before(parse(plan.ObservationsCreatedAt[1])))
+		}
+		assert.False(t, parse(plan.UpdatedAt).Before(parse(plan.CreatedAt)))
+		parse(graph.Entities[1].CreatedAt)
+		parse(graph.Relations[0].CreatedAt)
+	}
+
+	res, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.NotContains(t, jsonText(t, res), "createdAt")
+
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{IncludeTimestamps: true})
+	assert.NoError(t, err)
+	check(unmarshalJSON[database.KnowledgeGraph](t, res))
+
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Plan", "Review"}, IncludeTimestamps: true})
+	assert.NoError(t, err)
+	check(unmarshalJSON[database.KnowledgeGraph](t, res))
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "doc", IncludeTimestamps: true})
+	assert.NoError(t, err)
+	check(unmarshalJSON[database.KnowledgeGraph](t, res))
+}
+
+func TestServer_LocalizedMessages(t *testing.T) {
+	s, _ := newTestServer(t)
+	en := i18n.WithLocale(context.Background(), "en")
+	es := i18n.WithLocale(context.Background(), "es")
+	invalid := CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "", EntityType: "t"}}}
+
+	_, _, errEn := s.handleCreateEntities(en, invalid)
+	_, _, errEs := s.handleCreateEntities(es, invalid)
+	var toolEn, toolEs *ToolError
+	assert.ErrorAs(t, errEn, &toolEn)
+	assert.ErrorAs(t, errEs, &toolEs)
+	assert.Equal(t, "validation error: entity[0].name: entity name cannot be empty", toolEn.Message)
+	assert.Equal(t, "error de validación: entity[0].name: el nombre de la entidad no puede estar vacío", toolEs.Message)
+	assert.Equal(t, i18n.ErrEntityNameEmpty, toolEn.Code)
+	assert.Equal(t, toolEn.Code, toolEs.Code)
+
+	res, _, err := s.handleDeleteEntities(en, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "missing"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Entities deleted successfully", res.Content[1].(*mcp.TextContent).Text)
+	res, _, err = s.handleDeleteEntities(es, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "missing"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Entidades eliminadas correctamente", res.Content[1].(*mcp.TextContent).Text)
+}
+
+func TestServer_LocaleFromRequest(t *testing.T) {
+	s, _ := newTestServer(t)
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	_, err := m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	call := func(meta mcp.Meta, name string, args any) *mcp.CallToolResult {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Meta: meta, Name: name, Arguments: args})
+		assert.NoError(t, err)
+		return res
+	}
+	invalid := CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "", EntityType: "t"}}}
+
+	res := call(mcp.Meta{"acceptLanguage": "es-MX,es;q=0.9,en;q=0.8"}, "create_entities", invalid)
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEntityNameEmpty, res.Meta[ErrorCodeMetaKey])
+	assert.Contains(t, res.Content[0].(*mcp.TextContent).Text, "error de validación")
+
+	res = call(nil, "create_entities", invalid)
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEntityNameEmpty, res.Meta[ErrorCodeMetaKey])
+	assert.Contains(t, res.Content[0].(*mcp.TextContent).Text, "validation error")
+
+	res = call(mcp.Meta{"locale": "es"}, "delete_entities", DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "missing"}}})
+	assert.False(t, res.IsError)
+	assert.Equal(t, "Entidades eliminadas correctamente", res.Content[1].(*mcp.TextContent).Text)
+}
+
+func TestServer_ErrorResults(t *testing.T) {
+	s, _ := newTestServer(t)
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	_, err := m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	call := func(name string, args any) *mcp.CallToolResult {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		assert.NoError(t, err, "a failed tool call is a result, not a protocol error")
+		return res
+	}
+	type body struct {
+		Code    string         `json:"code"`
+		Message string         `json:"message"`
+		Details map[string]any `json:"details"`
+	}
+
+	// Invalid input
+	res := call("create_entities", CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "", EntityType: "t"}}})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEntityNameEmpty, res.Meta[ErrorCodeMetaKey])
+	got := unmarshalJSON[body](t, res)
+	assert.Equal(t, i18n.ErrEntityNameEmpty, got.Code)
+	assert.Contains(t, got.Message, "validation error")
+	assert.Nil(t, got.Details)
+	assert.NotContains(t, jsonText(t, res), `"details"`)
+
+	// An observation for an entity that doesn't exist is an expected failure
+	res = call("add_observations", AddObservationsParams{Observations: []ObservationInput{{EntityName: "Ghost <1>", Contents: []string{"x"}}}})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEntityNotFound, res.Meta[ErrorCodeMetaKey])
+	got = unmarshalJSON[body](t, res)
+	assert.Equal(t, body{
+		Code:    i18n.ErrEntityNotFound,
+		Message: `entity "Ghost <1>" not found`,
+		Details: map[string]any{"entityName": "Ghost <1>"},
+	}, got)
+	assert.Contains(t, jsonText(t, res), "Ghost <1>", "markup characters aren't escaped")
+}
+
+func TestServer_StructuredOutput(t *testing.T) {
+	s, _ := newTestServer(t)
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	_, err := m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	tools, err := session.ListTools(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	schemas := map[string]*jsonschema.Resolved{}
+	for _, tool := range tools.Tools {
+		if tool.OutputSchema != nil {
+			resolved, err := tool.OutputSchema.Resolve(nil)
+			if assert.NoError(t, err, tool.Name) {
+				schemas[tool.Name] = resolved
+			}
+		}
+	}
+	assert.NotContains(t, schemas, "import_abort", "tools that only report success have no structured result")
+
+	called := map[string]bool{}
+	call := func(name string, args map[string]any) map[string]any {
+		if args == nil {
+			args = map[string]any{}
+		}
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		if !assert.NoError(t, err, name) || !assert.False(t, res.IsError, "%s: %s", name, jsonText(t, res)) {
+			return nil
+		}
+		called[name] = true
+		out, ok := res.StructuredContent.(map[string]any)
+		if !assert.True(t, ok, "%s has no structured result", name) {
+			return nil
+		}
+		if schema := schemas[name]; assert.NotNil(t, schema, name) {
+			assert.NoError(t, schema.Validate(out), name)
+		}
+		return out
+	}
+
+	created := call("create_entities", map[string]any{"session": "s1", "entities": []any{
+		map[string]any{"name": "Alice", "entityType": "person", "observations": []any{"likes Go"}},
+		map[string]any{"name": "Bob", "entityType": "person"},
+	}})
+	assert.Len(t, created["entities"], 2)
+	outcomes := call("create_entities", map[string]any{"onDuplicate": "skip", "entities": []any{
+		map[string]any{"name": "Alice", "entityType": "person"},
+	}})
+	assert.Len(t, outcomes["results"], 1)
+	call("create_relations", map[string]any{"relations": []any{map[string]any{"from": "Alice", "to": "Bob", "relationType": "knows"}}})
+	call("add_observations", map[string]any{"observations": []any{map[string]any{"entityName": "Alice", "contents": []any{"writes docs"}}}})
+	call("add_tags", map[string]any{"entities": []any{map[string]any{"entityName": "Alice", "tags": []any{"important"}}}})
+	call("update_entities", map[string]any{"entities": []any{map[string]any{"name": "Alice", "attributes": map[string]any{"score": 0.8}}}})
+	call("list_deleted", nil)
+	call("restore_entities", map[string]any{"entityNames": []any{"Nobody"}})
+	call("purge_deleted", nil)
+	history := call("get_history", map[string]any{"entityName": "Alice"})
+	if entries, _ := history["entries"].([]any); assert.NotEmpty(t, entries) {
+		first := entries[0].(map[string]any)
+		assert.Equal(t, "create_entities", first["tool"], "changes are recorded with the tool call making them")
+		assert.NotEmpty(t, first["requestId"])
+	}
+	call("create_snapshot", map[string]any{"label": "milestone"})
+	call("list_snapshots", nil)
+	call("diff_snapshot", map[string]any{"label": "milestone"})
+	call("find_orphans", nil)
+	hotspots := call("graph_hotspots", nil)
+	assert.NotEmpty(t, hotspots["hotspots"])
+	call("find_duplicates", map[string]any{"threshold": 0.85})
+	call("pin_entities", map[string]any{"entityNames": []any{"Alice"}})
+	call("unpin_entities", map[string]any{"entityNames": []any{"Alice"}})
+	call("add_alias", map[string]any{"entityName": "Alice", "aliases": []any{"Ally"}})
+
+	graph := call("read_graph", nil)
+	assert.Len(t, graph["entities"], 2)
+	assert.Len(t, graph["relations"], 1)
+	page := call("read_graph", map[string]any{"limit": 1})
+	assert.NotEmpty(t, page["nextCursor"])
+	call("search_nodes", map[string]any{"query": "Go"})
+	search := call("search_nodes", map[string]any{"query": "Go", "limit": 1})
+	assert.EqualValues(t, 1, search["totalMatches"])
+	call("open_nodes", map[string]any{"names": []any{"Alice"}})
+	call("open_nodes", map[string]any{"names": []any{"Alice"}, "includeMetadata": true})
+	call("read_graph", map[string]any{"detail": "summary"})
+	call("read_graph", map[string]any{"detail": "summary", "limit": 1})
+	call("search_nodes", map[string]any{"query": "Go", "detail": "summary"})
+	call("search_nodes", map[string]any{"query": "Go", "detail": "summary", "limit": 1})
+	call("open_nodes", map[string]any{"names": []any{"Alice"}, "detail": "summary"})
+	call("get_entity", map[string]any{"name": "Alice"})
+	call("get_entity", map[string]any{"name": "Nobody"})
+	call("recent_entities", nil)
+	call("get_observations", map[string]any{"entityName": "Alice"})
+	call("get_inbound_relations", map[string]any{"entityName": "Bob"})
+	call("get_outbound_relations", map[string]any{"entityName": "Alice"})
+	call("get_relations", map[string]any{"from": "Alice"})
+	call("find_path", map[string]any{"from": "Alice", "to": "Bob"})
+	call("get_neighbors", map[string]any{"names": []any{"Alice"}})
+	call("get_maintenance_status", nil)
+	call("erase_subject", map[string]any{"names": []any{"Nobody"}, "dryRun": true})
+	call("migrate_to_policy", map[string]any{"dryRun": true})
+	call("preview_retention", nil)
+	begin := call("import_begin", nil)
+	call("import_chunk", map[string]any{"importId": begin["importId"], "sequence": 1, "data": `{"type":"entity","name":"Carol","entityType":"person","observations":[]}` + "\n"})
+	call("import_commit", map[string]any{"importId": begin["importId"]})
+	call("import_graph", map[string]any{"document": `{"version":1,"entities":[{"name":"Dave","type":"person"}]}`})
+	call("set_type_metadata", map[string]any{"entityType": "person", "metadata": map[string]any{"color": "blue"}})
+	call("get_type_metadata", nil)
+	call("list_entity_types", nil)
+	call("list_relation_types", nil)
+	call("get_relation_constraints", nil)
+	call("list_sessions", nil)
+	call("sync_memory", nil)
+	call("memory_hygiene_report", nil)
+	call("get_validation_stats", nil)
+	call("check_integrity", nil)
+	call("graph_stats", nil)
+	call("get_capabilities", nil)
+	call("list_graphs", nil)
+	call("remove_tags", map[string]any{"entities": []any{map[string]any{"entityName": "Alice", "tags": []any{"important"}}}})
+
+	// A graph too large to inline is linked, and its structured result says where
+	s.opts.ResultLinkThreshold = 16
+	linked := call("read_graph", nil)
+	assert.Contains(t, linked["resultUri"], ResultURIPrefix)
+	assert.EqualValues(t, 4, linked["entityCount"])
+	s.opts.ResultLinkThreshold = 0
+
+	for _, dryRun := range []bool{true, false} {
+		call("delete_observations", map[string]any{"dryRun": dryRun, "deletions": []any{
+			map[string]any{"entityName": "Alice", "observations": []any{"writes docs"}},
+		}})
+		call("delete_relations", map[string]any{"dryRun": dryRun, "relations": []any{
+			map[string]any{"from": "Alice", "to": "Bob", "relationType": "knows"},
+		}})
+		deleted := call("delete_entities", map[string]any{"dryRun": dryRun, "entityNames": []any{
+			"Dave", map[string]any{"name": "Carol", "reassignRelationsTo": "Alice"},
+		}})
+		assert.Len(t, deleted["reassigned"], 1)
+	}
+
+	call("rollback_session", map[string]any{"session": "s1"})
+	call("clear_graph", map[string]any{"confirm": "DELETE EVERYTHING"})
+
+	for name := range schemas {
+		assert.True(t, called[name], "%s declares an output schema but wasn't checked", name)
+	}
+}
+
+func TestServer_ToolAnnotations(t *testing.T) {
+	s, _ := newTestServer(t)
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ctx := context.Background()
+	_, err := m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	const (
+		readOnly = iota
+		additive
+		destructive
+	)
+	type kind struct {
+		effect     int
+		idempotent bool
+	}
+	want := map[string]kind{
+		"create_entities":          {additive, true},
+		"create_relations":         {additive, true},
+		"add_observations":         {additive, true},
+		"add_tags":                 {additive, true},
+		"remove_tags":              {destructive, true},
+		"update_entities":          {destructive, true},
+		"delete_entities":          {destructive, true},
+		"delete_observations":      {destructive, true},
+		"delete_relations":         {destructive, true},
+		"list_deleted":             {readOnly, false},
+		"restore_entities":         {additive, true},
+		"purge_deleted":            {destructive, true},
+		"get_history":              {readOnly, false},
+		"create_snapshot":          {additive, false},
+		"list_snapshots":           {readOnly, false},
+		"diff_snapshot":            {readOnly, false},
+		"find_orphans":             {destructive, true},
+		"graph_hotspots":           {readOnly, false},
+		"find_duplicates":          {readOnly, false},
+		"pin_entities":             {additive, true},
+		"unpin_entities":           {destructive, true},
+		"add_alias":                {additive, true},
+		"read_graph":               {readOnly, false},
+		"search_nodes":             {readOnly, false},
+		"open_nodes":               {readOnly, false},
+		"get_entity":               {readOnly, false},
+		"recent_entities":          {readOnly, false},
+		"get_observations":         {readOnly, false},
+		"get_inbound_relations":    {readOnly, false},
+		"get_outbound_relations":   {readOnly, false},
+		"get_relations":            {readOnly, false},
+		"find_path":                {readOnly, false},
+		"get_neighbors":            {readOnly, false},
+		"get_maintenance_status":   {readOnly, false},
+		"erase_subject":            {destructive, true},
+		"clear_graph":              {destructive, true},
+		"migrate_to_policy":        {destructive, true},
+		"preview_retention":        {readOnly, false},
+		"import_begin":             {additive, false},
+		"import_chunk":             {additive, true},
+		"import_commit":            {additive, true},
+		"import_abort":             {destructive, true},
+		"export_graph":             {readOnly, false},
+		"import_graph":             {destructive, true},
+		"set_type_metadata":        {destructive, true},
+		"list_entity_types":        {readOnly, false},
+		"list_relation_types":      {readOnly, false},
+		"get_type_metadata":        {readOnly, false},
+		"get_relation_constraints": {readOnly, false},
+		"list_sessions":            {readOnly, false},
+		"rollback_session":         {destructive, true},
+		"sync_memory":              {additive, true},
+		"memory_hygiene_report":    {readOnly, false},
+		"get_validation_stats":     {readOnly, false},
+		"check_integrity":          {readOnly, false},
+		"graph_stats":              {readOnly, false},
+		"get_capabilities":         {readOnly, false},
+		"list_graphs":              {readOnly, false},
+	}
+
+	tools, err := session.ListTools(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, tools.Tools, len(want))
+	for _, tool := range tools.Tools {
+		k, ok := want[tool.Name]
+		if !assert.True(t, ok, "%s has no expected annotations", tool.Name) || !assert.NotNil(t, tool.Annotations, tool.Name) {
+			continue
+		}
+		a := tool.Annotations
+		assert.NotEmpty(t, tool.Title, tool.Name)
+		assert.Equal(t, k.effect == readOnly, a.ReadOnlyHint, tool.Name)
+		if k.effect != readOnly {
+			if assert.NotNil(t, a.DestructiveHint, tool.Name) {
+				assert.Equal(t, k.effect == destructive, *a.DestructiveHint, tool.Name)
+			}
+			assert.Equal(t, k.idempotent, a.IdempotentHint, tool.Name)
+		}
+		if assert.NotNil(t, a.OpenWorldHint, tool.Name) {
+			assert.False(t, *a.OpenWorldHint, tool.Name)
+		}
+	}
+}
+
+func TestServer_ReadOnlyMode(t *testing.T) {
+	_, db := newTestServer(t)
+	ctx := context.Background()
+	_, err := db.CreateEntities(ctx, []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking"}},
+	})
+	assert.NoError(t, err)
+
+	s := NewServerWithOptions(db, nil, Options{ReadOnly: true})
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	_, err = m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	// Only read-only tools are listed
+	tools, err := session.ListTools(ctx, nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tools.Tools)
+	for _, tool := range tools.Tools {
+		assert.True(t, tool.Annotations.ReadOnlyHint, tool.Name)
+	}
+	assert.Equal(t, true, s.Capabilities()["readOnly"])
+	assert.NotContains(t, s.Capabilities()["enabledTools"], "create_entities")
+
+	_, err = session.CallTool(ctx, &mcp.CallToolParams{
+		Name: "create_entities",
+		Arguments: map[string]any{"entities": []map[string]any{
+			{"name": "Bob", "entityType": "person", "observations": []string{}},
+		}},
+	})
+	assert.Error(t, err, "create_entities is not registered")
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "search_nodes",
+		Arguments: map[string]any{"query": "Alice"},
+	})
+	if assert.NoError(t, err) {
+		assert.False(t, res.IsError)
+		assert.Len(t, unmarshalJSON[database.KnowledgeGraph](t, res).Entities, 1)
+	}
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+}
+
+func TestServer_EraseSubject(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Zelda Quartermain", EntityType: "person", Observations: []string{"likes chess"}},
+		{Name: "Acme", EntityType: "org", Observations: []string{"CEO is Zelda Quartermain", "founded 1999"}},
+	}})
+	assert.NoError(t, err)
+	_, err = s.results.put(&database.KnowledgeGraph{})
+	assert.NoError(t, err)
+
+	_, _, err = s.handleEraseSubject(ctx, EraseSubjectParams{Names: []string{"Z"}})
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrEraseNameTooShort, toolErr.Code)
+
+	res, _, err := s.handleEraseSubject(ctx, EraseSubjectParams{Names: []string{"Zelda Quartermain"}, DryRun: true})
+	assert.NoError(t, err)
+	report := unmarshalJSON[database.ErasureReport](t, res)
+	assert.Equal(t, []string{"Zelda Quartermain"}, report.Entities)
+	assert.Len(t, report.Observations, 1)
+	assert.False(t, report.Verification.Clean)
+	assert.Len(t, s.results.results, 1)
+
+	res, _, err = s.handleEraseSubject(ctx, EraseSubjectParams{Names: []string{"Zelda Quartermain"}})
+	assert.NoError(t, err)
+	report = unmarshalJSON[database.ErasureReport](t, res)
+	assert.True(t, report.Verification.Clean)
+	assert.Empty(t, s.results.results)
+
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 1)
+	assert.Equal(t, []string{"founded 1999"}, graph.Entities[0].Observations)
+}
+
+func TestServer_Capabilities(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	res, _, err := s.handleGetCapabilities(ctx)
+	assert.NoError(t, err)
+	caps := unmarshalJSON[map[string]any](t, res)
+	assert.Equal(t, db.IsFTSEnabled(), caps["ftsEnabled"])
+	assert.Equal(t, false, caps["readOnly"])
+	assert.Equal(t, false, caps["semanticSearch"])
+	assert.Equal(t, true, caps["namespaces"])
+	assert.Equal(t, float64(MaxEntitiesPerRequest), caps["maxEntitiesPerRequest"])
+	assert.Equal(t, float64(0), caps["maxResultBytes"])
+	assert.Empty(t, caps["enabledTools"])
+
+	s.RegisterTools(mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil))
+	tools := s.Capabilities()["enabledTools"].([]string)
+	assert.Contains(t, tools, "create_entities")
+	assert.Contains(t, tools, "get_capabilities")
+
+	// Config and database state flip the flags
+	linked := NewServerWithOptions(db, nil, Options{ResultLinkThreshold: 4096})
+	assert.Equal(t, 4096, linked.Capabilities()["maxResultBytes"])
+
+	path := filepath.Join(t.TempDir(), "memory.db")
+	rw, err := database.NewDBWithLogger(path, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, rw.Close())
+	ro, err := database.NewReadOnlyDB(path, nil)
+	assert.NoError(t, err)
+	defer ro.Close()
+	assert.Equal(t, true, NewServerWithLogger(ro, nil).Capabilities()["readOnly"])
+}
+
+func TestServer_MemoryStore(t *testing.T) {
+	s := NewServerWithLogger(store.NewMemory(), nil)
+	ctx := context.Background()
+
+	s.RegisterTools(mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil))
+	tools := s.Capabilities()["enabledTools"].([]string)
+	assert.Len(t, tools, len(storeTools))
+	for _, tool := range tools {
+		assert.True(t, storeTools[tool], tool)
+	}
+	assert.Equal(t, false, s.Capabilities()["ftsEnabled"])
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking"}},
+		{Name: "Acme", EntityType: "company"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Acme", RelationType: "works_at"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{
+		{EntityName: "Acme", Contents: []string{"founded 1999"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "hiking"})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 1)
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice", "Acme"}})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 2)
+	assert.Len(t, graph.Relations, 1)
+	// Summaries are made from what the store returns
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{Detail: DetailSummary})
+	assert.NoError(t, err)
+	assert.Equal(t, []database.EntitySummary{
+		{Name: "Acme", EntityType: "company", ObservationCount: 1},
+		{Name: "Alice", EntityType: "person", ObservationCount: 1},
+	}, unmarshalJSON[database.KnowledgeGraphSummary](t, res).Entities)
+
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "Alice"}}})
+	assert.NoError(t, err)
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 1)
+	assert.Empty(t, graph.Relations)
+
+	// Options only SQLite supports are rejected rather than ignored
+	for name, call := range map[string]func() error{
+		"onDuplicate": func() error {
+			_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{
+				Entities:    []database.EntityWithObservations{{Name: "Acme", EntityType: "company"}},
+				OnDuplicate: database.DuplicateSkip,
+			})
+			return err
+		},
+		"strict": func() error {
+			_, _, err := s.handleCreateRelations(ctx, CreateRelationsParams{
+				Relations: []database.RelationDTO{{From: "Acme", To: "Acme", RelationType: "owns"}},
+				Strict:    true,
+			})
+			return err
+		},
+		"ranked": func() error {
+			_, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "acme", Ranked: true})
+			return err
+		},
+		"limit": func() error {
+			_, _, err := s.handleReadGraph(ctx, ReadGraphParams{Limit: 10})
+			return err
+		},
+		"includeTimestamps": func() error {
+			_, _, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Acme"}, IncludeTimestamps: true})
+			return err
+		},
+		"ttlSeconds": func() error {
+			_, _, err := s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{
+				{EntityName: "Acme", Contents: []string{"hiring"}, TTLSeconds: 60},
+			}})
+			return err
+		},
+		"includeAliases": func() error {
+			_, _, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Acme"}, IncludeAliases: true})
+			return err
+		},
+	} {
+		var toolErr *ToolError
+		if assert.ErrorAs(t, call(), &toolErr, name) {
+			assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code, name)
+		}
+	}
+
+	assert.NoError(t, s.Shutdown(ctx))
+}
+
+func TestServer_EncodeFailure(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	registerCapability("unencodable", func(*Server) any { return make(chan int) })
+	t.Cleanup(func() {
+		capabilityMu.Lock()
+		defer capabilityMu.Unlock()
+		delete(capabilityFlags, "unencodable")
+	})
+
+	res, out, err := s.handleGetCapabilities(ctx)
+	assert.Nil(t, res)
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrEncodeResult, toolErr.Code)
+	assert.Contains(t, toolErr.Message, "failed to encode result")
+
+	// The client sees an error result, not an empty success
+	res, _, err = toolResult(res, out, err)
+	assert.NoError(t, err)
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEncodeResult, res.Meta[ErrorCodeMetaKey])
+	assert.NotEmpty(t, jsonText(t, res))
+}
+
+func TestServer_ChunkedImport(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	res, _, err := s.handleImportBegin(ctx)
+	assert.NoError(t, err)
+	begin := unmarshalJSON[ImportBeginResult](t, res)
+	assert.Equal(t, "jsonl", begin.Format)
+	assert.Equal(t, 1, begin.FirstSequence)
+
+	lines := []string{
+		`{"type":"entity","name":"Alice","entityType":"person","observations":["engineer"]}` + "\n",
+		`{"type":"entity","name":"Acme","entityType":"org"}` + "\n",
+		`{"type":"relation","from":"Alice","to":"Acme","relationType":"works_at"}`,
+	}
+
+	_, _, err = s.handleImportChunk(ctx, ImportChunkParams{ImportID: begin.ImportID, Sequence: 2, Data: lines[0]})
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrImportOutOfOrder, toolErr.Code)
+	assert.Contains(t, toolErr.Message, "expected chunk 1")
+
+	_, _, err = s.handleImportChunk(ctx, ImportChunkParams{ImportID: begin.ImportID, Sequence: 1, Data: "!!", Encoding: "base64"})
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrInvalidBase64, toolErr.Code)
+
+	// The first line is split across two chunks, one of them base64
+	_, _, err = s.handleImportChunk(ctx, ImportChunkParams{ImportID: begin.ImportID, Sequence: 1, Data: lines[0][:20]})
+	assert.NoError(t, err)
+	res, _, err = s.handleImportChunk(ctx, ImportChunkParams{
+		ImportID: begin.ImportID,
+		Sequence: 2,
+		Data:     base64.StdEncoding.EncodeToString([]byte(lines[0][20:] + lines[1])),
+		Encoding: ImportEncodingBase64,
+	})
+	assert.NoError(t, err)
+	ack := unmarshalJSON[database.ImportChunkResult](t, res)
+	assert.Equal(t, 2, ack.Lines)
+	assert.Equal(t, 3, ack.NextSequence)
+	_, _, err = s.handleImportChunk(ctx, ImportChunkParams{ImportID: begin.ImportID, Sequence: 3, Data: lines[2]})
+	assert.NoError(t, err)
+
+	// Nothing is visible before the commit
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+
+	res, _, err = s.handleImportCommit(ctx, ImportCommitParams{ImportID: begin.ImportID})
+	assert.NoError(t, err)
+	summary := unmarshalJSON[database.ImportSummary](t, res)
+	assert.Equal(t, 3, summary.Chunks)
+	assert.Equal(t, 2, summary.EntitiesCreated)
+	assert.Equal(t, 1, summary.RelationsCreated)
+
+	_, _, err = s.handleImportAbort(ctx, ImportAbortParams{ImportID: begin.ImportID})
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrImportNotFound, toolErr.Code)
+}
+
+func TestServer_TypeMetadata(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	res, _, err := s.handleSetTypeMetadata(ctx, SetTypeMetadataParams{
+		EntityType: "incident",
+		Metadata:   map[string]string{"color": "red", "x-runbook": "ops/incidents"},
+	})
+	assert.NoError(t, err)
+	set := unmarshalJSON[typeMetadataResult](t, res)
+	assert.Equal(t, "incident", set.EntityType)
+	assert.Equal(t, map[string]string{"color": "red", "x-runbook": "ops/incidents"}, set.Metadata)
+
+	// Unlisted keys are kept and an empty value removes a key
+	res, _, err = s.handleSetTypeMetadata(ctx, SetTypeMetadataParams{
+		EntityType: "incident",
+		Metadata:   map[string]string{"x-runbook": "", "group": "ops"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"color": "red", "group": "ops"}, unmarshalJSON[typeMetadataResult](t, res).Metadata)
+
+	res, _, err = s.handleGetTypeMetadata(ctx, GetTypeMetadataParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, database.TypeMetadata{"incident": {"color": "red", "group": "ops"}}, unmarshalJSON[database.TypeMetadata](t, res))
+
+	res, _, err = s.handleGetTypeMetadata(ctx, GetTypeMetadataParams{EntityTypes: []string{"person"}})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.TypeMetadata](t, res))
+
+	for name, tc := range map[string]struct {
+		metadata map[string]string
+		code     string
+	}{
+		"no keys":        {map[string]string{}, i18n.ErrNoTypeMetadata},
+		"empty key":      {map[string]string{"": "red"}, i18n.ErrTypeMetadataKeyEmpty},
+		"long key":       {map[string]string{strings.Repeat("k", MaxTypeMetadataKeyLength+1): "red"}, i18n.ErrTypeMetadataKeyTooLong},
+		"control in key": {map[string]string{"co\nlor": "red"}, i18n.ErrTypeMetadataKeyInvalid},
+		"long value":     {map[string]string{"color": strings.Repeat("v", MaxTypeMetadataValueLength+1)}, i18n.ErrTypeMetadataValueTooLong},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := s.handleSetTypeMetadata(ctx, SetTypeMetadataParams{EntityType: "incident", Metadata: tc.metadata})
+			var toolErr *ToolError
+			assert.ErrorAs(t, err, &toolErr)
+			assert.Equal(t, tc.code, toolErr.Code)
+		})
+	}
+}
+
+func TestServer_SyncMemory(t *testing.T) {
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	s := NewServerWithOptions(db, nil, Options{SyncInterval: time.Hour})
+	ctx := context.Background()
+
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{
+		Entities: []database.EntityWithObservations{{Name: "Plan", EntityType: "doc", Observations: []string{"step 1"}}},
+	})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleSyncMemory(ctx)
+	assert.NoError(t, err)
+	result := unmarshalJSON[map[string]any](t, res)
+	assert.Equal(t, false, result["busy"])
+	assert.Positive(t, result["logFrames"])
+	assert.Equal(t, result["logFrames"], result["checkpointedFrames"])
+	assert.Equal(t, true, result["filesSynced"])
+
+	// A second call within the interval is rejected
+	_, _, err = s.handleSyncMemory(ctx)
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrSyncRateLimited, toolErr.Code)
+	assert.Contains(t, toolErr.Message, "retry in 1h0m0s")
+}
+
+func TestServer_ValidationStats(t *testing.T) {
+	const secret = "sk-live0123456789abcdefghij"
+
+	var buf bytes.Buffer
+	redactor, err := logging.NewRedactor(nil)
+	assert.NoError(t, err)
+	logger := logging.WithRedaction(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), redactor)
+	db, err := database.NewDBWithLogger("file::memory:?cache=shared", logger)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	s := NewServerWithLogger(db, logger)
+	ctx := context.Background()
+
+	entity := func(name, entityType string, observations ...string) CreateEntitiesParams {
+		return CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: name, EntityType: entityType, Observations: observations}}}
+	}
+	tooMany := CreateEntitiesParams{Entities: make([]database.EntityWithObservations, MaxEntitiesPerRequest+1)}
+	calls := []struct {
+		rule string
+		call func() error
+	}{
+		{i18n.ErrNoEntities, func() error { _, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{}); return err }},
+		{i18n.ErrTooManyEntities, func() error { _, _, err := s.handleCreateEntities(ctx, tooMany); return err }},
+		{i18n.ErrEntityNameTooLong, func() error {
+			_, _, err := s.handleCreateEntities(ctx, entity(strings.Repeat("n", MaxEntityNameLength+1), "t"))
+			return err
+		}},
+		{i18n.ErrEntityNameControlChars, func() error {
+			_, _, err := s.handleCreateEntities(ctx, entity("bell\a "+secret, "t"))
+			return err
+		}},
+		{i18n.ErrEntityTypeEmpty, func() error { _, _, err := s.handleCreateEntities(ctx, entity("A", "")); return err }},
+		{i18n.ErrObservationTooLong, func() error {
+			_, _, err := s.handleCreateEntities(ctx, entity("A", "t", strings.Repeat("o", MaxObservationLength+1)))
+			return err
+		}},
+		{i18n.ErrRelationTypeEmpty, func() error {
+			_, _, err := s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B"}}})
+			return err
+		}},
+		{i18n.ErrInvalidSimilarity, func() error {
+			_, _, err := s.handleAddObservations(ctx, AddObservationsParams{IfAbsentSimilar: 2, Observations: []ObservationInput{{EntityName: "A", Contents: []string{"x"}}}})
+			return err
+		}},
+		{i18n.ErrSearchQueryTooLong, func() error {
+			_, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: strings.Repeat("q", MaxSearchQueryLength+1)})
+			return err
+		}},
+		{i18n.ErrInvalidOrderBy, func() error {
+			_, _, err := s.handleGetObservations(ctx, GetObservationsParams{EntityName: "A", OrderBy: "sideways"})
+			return err
+		}},
+		{i18n.ErrEraseNameTooShort, func() error {
+			_, _, err := s.handleEraseSubject(ctx, EraseSubjectParams{Names: []string{"Z"}})
+			return err
+		}},
+		{i18n.ErrTypeMetadataKeyTooLong, func() error {
+			_, _, err := s.handleSetTypeMetadata(ctx, SetTypeMetadataParams{EntityType: "t", Metadata: map[string]string{strings.Repeat("k", MaxTypeMetadataKeyLength+1): "v"}})
+			return err
+		}},
+	}
+	for _, c := range calls {
+		var toolErr *ToolError
+		if assert.ErrorAs(t, c.call(), &toolErr, c.rule) {
+			assert.Equal(t, c.rule, toolErr.Code)
+		}
+	}
+
+	stats := s.ValidationStats()
+	assert.Equal(t, int64(len(calls)), stats.Total)
+	for _, c := range calls {
+		assert.Equal(t, int64(1), stats.ByRule[c.rule], c.rule)
+	}
+
+	res, _, err := s.handleGetValidationStats(ctx)
+	assert.NoError(t, err)
+	out := unmarshalJSON[ValidationStats](t, res)
+	assert.Equal(t, stats.ByRule, out.ByRule)
+	res, _, err = s.handleGraphStats(ctx)
+	assert.NoError(t, err)
+	graph := unmarshalJSON[graphStats](t, res)
+	assert.Equal(t, stats.ByRule, graph.ValidationRejections.ByRule, "graph_stats reports the rejections too")
+	assert.Equal(t, stats.Total, graph.ValidationRejections.Total)
+
+	// Debug samples are truncated and redacted; erase_subject names are never logged
+	logs := buf.String()
+	assert.Contains(t, logs, "rule=entity_name_too_long sample="+strings.Repeat("n", MaxRejectedValueSample)+"… bytes=256")
+	assert.Contains(t, logs, `rule=entity_name_control_chars sample="bell\a `+logging.RedactedPlaceholder+`"`)
+	assert.NotContains(t, logs, secret)
+	assert.NotContains(t, logs, "rule=erase_name_too_short")
+}
+
+func TestTruncateSample(t *testing.T) {
+	assert.Equal(t, "short", truncateSample("short", 8))
+	assert.Equal(t, "ab…", truncateSample("abcdef", 2))
+	// "é" is two bytes and is not split
+	assert.Equal(t, "a…", truncateSample("aéb", 2))
+}
+
+func TestServer_MemoryHygieneReport(t *testing.T) {
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetObservationLimit(4)
+	s := NewServerWithLogger(db, nil)
+	ctx := context.Background()
+
+	entities := []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "alice", EntityType: "person", Observations: []string{"likes go"}},
+		{Name: "Notes", EntityType: "log", Observations: []string{"a", "b", "c", "d"}},
+	}
+	for i := 0; i < MaxHygieneSample+5; i++ {
+		entities = append(entities, database.EntityWithObservations{Name: fmt.Sprintf("Empty %02d", i), EntityType: "stub"})
+	}
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: entities})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Notes", RelationType: "wrote"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleHygieneReport(ctx, HygieneReportParams{})
+	assert.NoError(t, err)
+	report := unmarshalJSON[hygieneReport](t, res)
+	assert.Equal(t, DefaultHygieneStaleDays, report.StaleAfterDays)
+	assert.Equal(t, 28, report.Totals.Entities)
+	assert.Contains(t, report.Checks, CheckStaleEntities)
+
+	findings := map[string]hygieneFinding{}
+	for _, f := range report.Findings {
+		findings[f.Check] = f
+	}
+	assert.NotContains(t, findings, CheckStaleEntities)
+
+	empty := findings[CheckEmptyEntities]
+	assert.Equal(t, MaxHygieneSample+5, empty.Count)
+	assert.Len(t, empty.Items, MaxHygieneSample)
+	assert.True(t, empty.Truncated)
+	assert.Equal(t, "open_nodes", empty.Suggestions[0].Tool)
+	assert.Equal(t, "delete_entities", empty.Suggestions[1].Tool)
+	assert.Len(t, empty.Suggestions[1].Arguments["entityNames"], MaxHygieneSample)
+
+	duplicates := findings[CheckDuplicateNames]
+	assert.Equal(t, 1, duplicates.Count)
+	assert.Equal(t, map[string]any{"names": []any{"Alice", "alice"}}, duplicates.Suggestions[0].Arguments)
+	assert.Contains(t, duplicates.Suggestions[0].Reason, "differ only in case")
+
+	oversized := findings[CheckOversizedEntities]
+	assert.Equal(t, 1, oversized.Count)
+	assert.Equal(t, map[string]any{"entityName": "Notes", "orderBy": "oldest"}, oversized.Suggestions[0].Arguments)
+
+	rare := findings[CheckRareRelationTypes]
+	assert.Equal(t, 1, rare.Count)
+	assert.Equal(t, map[string]any{"names": []any{"Alice", "Notes"}}, rare.Suggestions[0].Arguments)
+
+	for _, days := range []int{-1, MaxHygieneStaleDays + 1} {
+		_, _, err := s.handleHygieneReport(ctx, HygieneReportParams{StaleAfterDays: days})
+		var toolErr *ToolError
+		assert.ErrorAs(t, err, &toolErr)
+		assert.Equal(t, i18n.ErrInvalidStaleAfterDays, toolErr.Code)
+	}
+}
+
+func TestServer_SnapshotReads(t *testing.T) {
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	snapshots := database.NewSnapshotReads(db, "")
+	s := NewServerWithOptions(db, nil, Options{SnapshotReads: snapshots})
+	ctx := context.Background()
+
+	entity := func(name string) CreateEntitiesParams {
+		return CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: name, EntityType: "person", Observations: []string{"engineer"}}}}
+	}
+	_, _, err = s.handleCreateEntities(ctx, entity("Alice"))
+	assert.NoError(t, err)
+
+	err = snapshots.Run(ctx, "slow_operation", func(ctx context.Context) error {
+		// Writes during the operation are not visible to reads until it ends
+		_, _, err := s.handleCreateEntities(ctx, entity("Bob"))
+		assert.NoError(t, err)
+
+		res, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+		assert.NoError(t, err)
+		graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+		assert.Len(t, graph.Entities, 1)
+		assert.Len(t, res.Content, 2)
+		assert.Contains(t, res.Content[1].(*mcp.TextContent).Text, "Served from a snapshot taken at")
+
+		for _, call := range []func() (*mcp.CallToolResult, any, error){
+			func() (*mcp.CallToolResult, any, error) {
+				return s.handleSearchNodes(ctx, SearchNodesParams{Query: "Bob"})
+			},
+			func() (*mcp.CallToolResult, any, error) {
+				return s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Bob"}})
+			},
+			func() (*mcp.CallToolResult, any, error) {
+				return s.handleGetObservations(ctx, GetObservationsParams{EntityName: "Alice"})
+			},
+		} {
+			res, _, err := call()
+			assert.NoError(t, err)
+			assert.Len(t, res.Content, 2)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[database.KnowledgeGraph](t, res).Entities, 2)
+	assert.Len(t, res.Content, 1)
+}
+
+func TestServer_RelationConstraints(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	no := false
+	db.SetRelationConstraints(database.RelationConstraints{
+		"parent_of":  {AllowSelf: &no},
+		"reports_to": {MaxOutgoingPerEntity: 1},
+	})
+	defer db.SetRelationConstraints(nil)
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"engineer"}},
+		{Name: "Bob", EntityType: "person", Observations: []string{"manager"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGetRelationConstraints(ctx)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"constraints": {
+		"parent_of": {"allowSelf": false, "maxOutgoingPerEntity": 0, "maxIncomingPerEntity": 0},
+		"reports_to": {"allowSelf": true, "maxOutgoingPerEntity": 1, "maxIncomingPerEntity": 0}
+	}}`, jsonText(t, res))
+
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Alice", RelationType: "parent_of"},
+		{From: "Alice", To: "Bob", RelationType: "reports_to"},
+		{From: "Alice", To: "Alice", RelationType: "reports_to"},
+	}})
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrRelationConstraint, toolErr.Code)
+	assert.Equal(t, "2 relations break relation constraints, so none were created: "+
+		"relations[0]: Alice cannot have a parent_of relation to itself; "+
+		"relations[2]: Alice already has the maximum of 1 outgoing reports_to relations", toolErr.Message)
+
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.KnowledgeGraph](t, res).Relations)
+}
+
+func TestServer_Sessions(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Roadmap", EntityType: "doc", Observations: []string{"q3 plan"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Session: "task-1", Entities: []database.EntityWithObservations{
+		{Name: "Draft", EntityType: "doc", Observations: []string{"first cut"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Session: "task-2", Observations: []ObservationInput{
+		{EntityName: "Roadmap", Contents: []string{"q4 plan"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Session: "task-1", Observations: []ObservationInput{
+		{EntityName: "Roadmap", Contents: []string{"wrong plan"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Session: "task-1", Relations: []database.RelationDTO{
+		{From: "Draft", To: "Roadmap", RelationType: "revises"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleListSessions(ctx)
+	assert.NoError(t, err)
+	listed := unmarshalJSON[struct {
+		Sessions []database.SessionSummary `json:"sessions"`
+	}](t, res).Sessions
+	counts := map[string][3]int{}
+	for _, session := range listed {
+		counts[session.Session] = [3]int{session.Entities, session.Observations, session.Relations}
+	}
+	assert.Equal(t, [3]int{1, 2, 1}, counts["task-1"])
+	assert.Equal(t, [3]int{0, 1, 0}, counts["task-2"])
+
+	res, _, err = s.handleRollbackSession(ctx, RollbackSessionParams{Session: "task-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, database.SessionRollback{Session: "task-1", Entities: 1, Observations: 2, Relations: 1},
+		unmarshalJSON[database.SessionRollback](t, res))
+
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Roadmap", "Draft"}})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 1)
+	assert.Equal(t, []string{"q3 plan", "q4 plan"}, graph.Entities[0].Observations)
+	assert.Empty(t, graph.Relations)
+
+	for _, session := range []string{"", "   ", strings.Repeat("x", MaxSessionLabelLength+1), "bad\nlabel"} {
+		_, _, err = s.handleRollbackSession(ctx, RollbackSessionParams{Session: session})
+		var toolErr *ToolError
+		assert.ErrorAs(t, err, &toolErr, session)
+	}
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Session: " ", Relations: []database.RelationDTO{
+		{From: "Roadmap", To: "Roadmap", RelationType: "links"},
+	}})
+	assert.Error(t, err)
+}
+
+func TestServer_FindPath(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	db.SetAdjacencyCache(1 << 20)
+	defer db.SetAdjacencyCache(0)
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Billing", EntityType: "service"},
+		{Name: "Ledger", EntityType: "database"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Billing", RelationType: "calls"},
+		{From: "Billing", To: "Ledger", RelationType: "writes_to"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleFindPath(ctx, FindPathParams{From: "Ledger", To: "Gateway"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"found": true, "entities": ["Ledger", "Billing", "Gateway"], "path": [
+		{"from": "Billing", "to": "Ledger", "relationType": "writes_to"},
+		{"from": "Gateway", "to": "Billing", "relationType": "calls"}
+	]}`, jsonText(t, res))
+
+	res, _, err = s.handleFindPath(ctx, FindPathParams{From: "Ledger", To: "Gateway", MaxDepth: 1})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"found": false, "entities": [], "path": []}`, jsonText(t, res))
+
+	res, _, err = s.handleFindPath(ctx, FindPathParams{From: "Ledger", To: "Gateway", Directed: true})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"found": false, "entities": [], "path": []}`, jsonText(t, res))
+
+	res, _, err = s.handleFindPath(ctx, FindPathParams{From: "Gateway", To: "Billing", Directed: true})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"found": true, "entities": ["Gateway", "Billing"], "path": [
+		{"from": "Gateway", "to": "Billing", "relationType": "calls"}
+	]}`, jsonText(t, res))
+
+	res, _, err = s.handleFindPath(ctx, FindPathParams{From: "Billing", To: "Billing"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"found": true, "entities": ["Billing"], "path": []}`, jsonText(t, res))
+
+	_, _, err = s.handleFindPath(ctx, FindPathParams{From: "Ledger", To: "Gateway", MaxDepth: MaxPathDepth + 1})
+	var toolErr *ToolError
+	assert.ErrorAs(t, err, &toolErr)
+	assert.Equal(t, i18n.ErrInvalidPathDepth, toolErr.Code)
+}
+
+func TestServer_GetNeighbors(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Billing", EntityType: "service"},
+		{Name: "Ledger", EntityType: "database"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Billing", RelationType: "calls"},
+		{From: "Billing", To: "Gateway", RelationType: "calls_back"},
+		{From: "Billing", To: "Ledger", RelationType: "writes_to"},
+	}})
+	assert.NoError(t, err)
+
+	names := func(params GetNeighborsParams) []string {
+		res, _, err := s.handleGetNeighbors(ctx, params)
+		assert.NoError(t, err)
+		neighborhood := unmarshalJSON[database.Neighborhood](t, res)
+		assert.False(t, neighborhood.Truncated)
+		names := []string{}
+		for _, e := range neighborhood.Entities {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+	// The Gateway <-> Billing cycle doesn't stop the walk from ending
+	assert.Equal(t, []string{"Billing", "Gateway"}, names(GetNeighborsParams{Names: []string{"Gateway"}}))
+	assert.Equal(t, []string{"Billing", "Gateway", "Ledger"}, names(GetNeighborsParams{Names: []string{"Gateway"}, Depth: 2, Direction: "out"}))
+	assert.Equal(t, []string{"Ledger"}, names(GetNeighborsParams{Names: []string{"Ledger"}, Depth: 3, Direction: "out"}))
+	assert.Equal(t, []string{"Billing", "Gateway", "Ledger"}, names(GetNeighborsParams{Names: []string{"Ledger"}, Depth: 2, Direction: "in"}))
+
+	for _, tc := range []struct {
+		params GetNeighborsParams
+		code   string
+	}{
+		{GetNeighborsParams{}, i18n.ErrNoNames},
+		{GetNeighborsParams{Names: []string{"Gateway"}, Depth: MaxNeighborDepth + 1}, i18n.ErrInvalidNeighborDepth},
+		{GetNeighborsParams{Names: []string{"Gateway"}, Direction: "up"}, i18n.ErrInvalidDirection},
+	} {
+		_, _, err := s.handleGetNeighbors(ctx, tc.params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr) {
+			assert.Equal(t, tc.code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_ClearGraph(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"fronts every request"}},
+		{Name: "Billing", EntityType: "service"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Billing", RelationType: "calls"},
+	}})
+	assert.NoError(t, err)
+
+	for _, confirm := range []string{"", "yes", "delete everything", "DELETE EVERYTHING "} {
+		_, _, err := s.handleClearGraph(ctx, ClearGraphParams{Confirm: confirm})
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, confirm) {
+			assert.Equal(t, i18n.ErrClearNotConfirmed, toolErr.Code)
+		}
+	}
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2, "nothing is deleted without confirmation")
+
+	res, _, err := s.handleClearGraph(ctx, ClearGraphParams{Confirm: ClearGraphConfirmation})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"entities": 2, "observations": 1, "relations": 1, "archivedObservations": 0}`, jsonText(t, res))
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, graph.Entities)
+	found, err := db.SearchNodes(ctx, "request", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, found.Entities)
+}
+
+func TestServer_GraphStats(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"fronts every request"}},
+		{Name: "Ledger", EntityType: "database"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Ledger", RelationType: "writes_to"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGraphStats(ctx)
+	assert.NoError(t, err)
+	stats := unmarshalJSON[database.GraphStats](t, res)
+	assert.Equal(t, 2, stats.Entities)
+	assert.Equal(t, 1, stats.Relations)
+	assert.Equal(t, 1, stats.Observations)
+	assert.Equal(t, 2, stats.EntityTypes)
+	assert.Equal(t, 1, stats.RelationTypes)
+	assert.Equal(t, db.IsFTSEnabled(), stats.FTSEnabled)
+	assert.Positive(t, stats.SizeBytes)
+}
+
+func TestServer_CheckIntegrity(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"fronts every request"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleCheckIntegrity(ctx)
+	assert.NoError(t, err)
+	report := unmarshalJSON[database.IntegrityReport](t, res)
+	assert.True(t, report.OK)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.ForeignKeyViolations)
+	assert.Equal(t, db.IsFTSEnabled(), report.FTS != nil)
+}
+
+func TestServer_ExportGraph(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"fronts every request"}},
+		{Name: "Ledger", EntityType: "database"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Ledger", RelationType: "writes_to"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleExportGraph(ctx, ExportGraphParams{})
+	assert.NoError(t, err)
+	doc := unmarshalJSON[database.GraphDocument](t, res)
+	assert.Equal(t, database.GraphDocumentVersion, doc.Version)
+	if assert.Len(t, doc.Entities, 2) {
+		assert.Equal(t, "Gateway", doc.Entities[0].Name)
+		assert.Equal(t, "service", doc.Entities[0].Type)
+		assert.Equal(t, []string{"fronts every request"}, doc.Entities[0].Observations)
+		assert.NotEmpty(t, doc.Entities[0].CreatedAt)
+	}
+	assert.Equal(t, []database.RelationDTO{{From: "Gateway", To: "Ledger", RelationType: "writes_to"}}, doc.Relations)
+
+	// The document imports back into an empty graph
+	before, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	_, err = db.ClearGraph(ctx)
+	assert.NoError(t, err)
+	_, _, err = s.handleImportGraph(ctx, ImportGraphParams{Document: res.Content[0].(*mcp.TextContent).Text})
+	assert.NoError(t, err)
+	after, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestServer_ExportGraph_View(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Ledger", EntityType: "database"},
+		{Name: "Backup", EntityType: "job"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "Ledger", RelationType: "writes_to"},
+		{From: "Backup", To: "Ledger", RelationType: "copies"},
+	}})
+	assert.NoError(t, err)
+
+	text := func(params ExportGraphParams) string {
+		t.Helper()
+		res, _, err := s.handleExportGraph(ctx, params)
+		if !assert.NoError(t, err, "%+v", params) {
+			return ""
+		}
+		return res.Content[0].(*mcp.TextContent).Text
+	}
+	dot := text(ExportGraphParams{Format: database.ViewDOT})
+	assert.True(t, strings.HasPrefix(dot, "digraph memory {"))
+	assert.Contains(t, dot, `"Gateway" -> "Ledger" [label="writes_to"];`)
+	dot = text(ExportGraphParams{Format: database.ViewDOT, Root: "Gateway"})
+	assert.Contains(t, dot, `"Ledger" [`)
+	assert.NotContains(t, dot, `"Backup" [`, "Backup is two relations from Gateway")
+	graphml := text(ExportGraphParams{Format: database.ViewGraphML, Root: "Gateway", Depth: 2})
+	assert.Contains(t, graphml, `<node id="Backup">`)
+
+	code := func(params ExportGraphParams) string {
+		t.Helper()
+		_, _, err := s.handleExportGraph(ctx, params)
+		var toolErr *ToolError
+		if !assert.ErrorAs(t, err, &toolErr, "%+v", params) {
+			return ""
+		}
+		return toolErr.Code
+	}
+	assert.Equal(t, i18n.ErrViewTooLarge, code(ExportGraphParams{Format: database.ViewDOT, MaxNodes: 2}))
+	assert.Equal(t, i18n.ErrRootNotFound, code(ExportGraphParams{Format: database.ViewGraphML, Root: "Nobody"}))
+	assert.Equal(t, i18n.ErrInvalidExportFormat, code(ExportGraphParams{Format: "svg"}))
+	assert.Equal(t, i18n.ErrViewOptionsFormat, code(ExportGraphParams{Root: "Gateway"}))
+	assert.Equal(t, i18n.ErrInvalidNeighborDepth, code(ExportGraphParams{Format: database.ViewDOT, Depth: MaxNeighborDepth + 1}))
+	assert.Equal(t, i18n.ErrInvalidMaxNodes, code(ExportGraphParams{Format: database.ViewDOT, MaxNodes: MaxViewNodes + 1}))
+}
+
+func TestServer_RaisedAndLoweredLimits(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	defer SetLimits(Limits{})
+
+	limits, err := SetLimits(Limits{Observation: 20000, BatchSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 20000, limits.Observation)
+	assert.Equal(t, 2, s.Capabilities()["maxEntitiesPerRequest"])
+
+	code := func(err error) string {
+		var toolErr *ToolError
+		if !assert.ErrorAs(t, err, &toolErr) {
+			return ""
+		}
+		return toolErr.Code
+	}
+
+	// A stack trace longer than the default limit is accepted up to the new one
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Crash", EntityType: "incident", Observations: []string{strings.Repeat("x", 20000)}},
+		{Name: "Outage", EntityType: "incident"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{
+		{EntityName: "Crash", Contents: []string{strings.Repeat("y", 20001)}},
+	}})
+	assert.Equal(t, i18n.ErrObservationTooLong, code(err))
+
+	// The batch size applies to every list a request carries
+	three := []database.EntityWithObservations{{Name: "A", EntityType: "t"}, {Name: "B", EntityType: "t"}, {Name: "C", EntityType: "t"}}
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: three})
+	assert.Equal(t, i18n.ErrTooManyEntities, code(err))
+	_, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"A", "B", "C"}})
+	assert.Equal(t, i18n.ErrTooManyNodes, code(err))
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: three[:2]})
+	assert.NoError(t, err)
+
+	_, err = SetLimits(Limits{BatchSize: MaxBatchSizeCeiling + 1})
+	assert.Error(t, err)
+}
+
+func TestServer_ImportGraph(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	defer SetLimits(Limits{})
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Gateway", EntityType: "service", Observations: []string{"fronts every request"}},
+	}})
+	assert.NoError(t, err)
+
+	document := `{"relations":[{"from":"Gateway","to":"Ledger","relationType":"writes_to"}],
+		"entities":[{"name":"Gateway","type":"service","observations":["fronts every request","written in Go"]},{"name":"Ledger","type":"database"}]}`
+	res, _, err := s.handleImportGraph(ctx, ImportGraphParams{Document: document, Strategy: database.ImportSkip})
+	assert.NoError(t, err)
+	report := unmarshalJSON[database.ImportReport](t, res)
+	assert.Equal(t, database.ImportSkip, report.Strategy)
+	assert.Equal(t, 1, report.EntitiesCreated)
+	assert.Equal(t, 1, report.EntitiesSkipped)
+	assert.Equal(t, 1, report.RelationsCreated)
+
+	res, _, err = s.handleImportGraph(ctx, ImportGraphParams{Document: document})
+	assert.NoError(t, err)
+	report = unmarshalJSON[database.ImportReport](t, res)
+	assert.Equal(t, database.ImportMerge, report.Strategy, "the default")
+	assert.Equal(t, 2, report.EntitiesMerged)
+	assert.Equal(t, 1, report.ObservationsAdded)
+	assert.Equal(t, 1, report.RelationsSkipped)
+
+	graph, err := db.OpenNodes(ctx, []string{"Gateway"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fronts every request", "written in Go"}, graph.Entities[0].Observations)
+
+	SetLimits(Limits{Observation: 10})
+	for _, params := range []ImportGraphParams{
+		{Document: document, Strategy: "overwrite"},
+		{Document: "not json"},
+		{Document: `{"version":3}`},
+		{Document: `{"entities":[{"name":"","type":"person"}]}`},
+		{Document: `{"entities":[{"name":"Alice","type":"person","observations":["longer than ten bytes"]}]}`},
+		{Document: `{"relations":[{"from":"Alice","to":"Bob","relationType":""}]}`},
+	} {
+		_, _, err := s.handleImportGraph(ctx, params)
+		assert.Error(t, err, params.Document)
+	}
+}
+
+func TestServer_MigrateToPolicy(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	defer SetLimits(Limits{})
+
+	// Fixtures written under the default limits
+	words := make([]string, 30)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%02d", i+1)
+	}
+	long := strings.Join(words, " ") // 119 bytes
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Quarterly planning meeting notes", EntityType: "meeting", Observations: []string{long, "short"}},
+		{Name: "Quarterly planning meeting agenda", EntityType: "meeting"},
+		{Name: "Quarterly", EntityType: "meeting"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Quarterly planning meeting agenda", To: "Quarterly planning meeting notes", RelationType: "precedes"},
+	}})
+	assert.NoError(t, err)
+
+	report, err := CheckStoredData(ctx, db)
+	assert.NoError(t, err)
+	assert.True(t, report.Compatible)
+	assert.Equal(t, 7, report.Scanned)
+
+	// The operator tightens the policy; startup warns or refuses on the report
+	limits, err := SetLimits(Limits{EntityName: 20, Observation: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, Limits{EntityName: 20, EntityType: MaxEntityTypeLength, RelationType: MaxRelationTypeLength, Observation: 60, BatchSize: MaxEntitiesPerRequest}, limits)
+	_, err = SetLimits(Limits{Observation: MaxObservationLengthCeiling + 1})
+	assert.Error(t, err)
+	_, err = SetLimits(Limits{EntityName: MaxEntityNameLength + 1})
+	assert.Error(t, err)
+	assert.Equal(t, limits, ActiveLimits())
+
+	report, err = CheckStoredData(ctx, db)
+	assert.NoError(t, err)
+	assert.False(t, report.Compatible)
+	assert.Equal(t, limits, report.Limits)
+	assert.Len(t, report.Findings, 2)
+	assert.Equal(t, PolicyFinding{Kind: database.TextEntityName, Rule: i18n.ErrEntityNameTooLong, Count: 2, Samples: []PolicySample{
+		{Value: "Quarterly planning meeting notes", Length: 32},
+		{Value: "Quarterly planning meeting agenda", Length: 33},
+	}}, report.Findings[0])
+	assert.Equal(t, database.TextObservation, report.Findings[1].Kind)
+	assert.Equal(t, 1, report.Findings[1].Count)
+	assert.Equal(t, "Quarterly planning meeting notes", report.Findings[1].Samples[0].Entity)
+	assert.Equal(t, "2 entityName (entity_name_too_long), 1 observation (observation_too_long)", report.Summary())
+
+	// Tools reject the stored values until they are migrated
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{
+		{EntityName: "Quarterly planning meeting notes", Contents: []string{"follow-up"}},
+	}})
+	assert.Error(t, err)
+
+	// A dry run reports the changes without making them
+	res, _, err := s.handleMigrateToPolicy(ctx, MigrateToPolicyParams{DryRun: true})
+	assert.NoError(t, err)
+	dryRun := unmarshalJSON[policyMigration](t, res)
+	assert.True(t, dryRun.DryRun)
+	assert.True(t, dryRun.Compatible)
+	assert.Empty(t, dryRun.Unresolved)
+	assert.Len(t, dryRun.Changes, 3)
+	report, err = CheckStoredData(ctx, db)
+	assert.NoError(t, err)
+	assert.False(t, report.Compatible)
+
+	res, _, err = s.handleMigrateToPolicy(ctx, MigrateToPolicyParams{})
+	assert.NoError(t, err)
+	applied := unmarshalJSON[policyMigration](t, res)
+	assert.False(t, applied.DryRun)
+	assert.True(t, applied.Compatible)
+	assert.Equal(t, dryRun.Changes, applied.Changes)
+	changes := map[string]policyChange{}
+	for _, change := range applied.Changes {
+		changes[change.From] = change
+	}
+	assert.Equal(t, PolicyActionChunked, changes[clipValue(long)].Action)
+	assert.Equal(t, []string{strings.Join(words[:15], " "), strings.Join(words[15:], " ")}, changes[clipValue(long)].To)
+	assert.Equal(t, policyChange{Kind: database.TextEntityName, Rule: i18n.ErrEntityNameTooLong, Action: PolicyActionRenamed,
+		From: "Quarterly planning meeting notes", To: []string{"Quarterly planning m"}}, changes["Quarterly planning meeting notes"])
+	assert.Equal(t, []string{"Quarterly planning 2"}, changes["Quarterly planning meeting agenda"].To)
+
+	report, err = CheckStoredData(ctx, db)
+	assert.NoError(t, err)
+	assert.True(t, report.Compatible)
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	observations := map[string][]string{}
+	for _, entity := range graph.Entities {
+		observations[entity.Name] = entity.Observations
+	}
+	assert.Len(t, observations, 3)
+	assert.ElementsMatch(t, append(changes[clipValue(long)].To, "short"), observations["Quarterly planning m"])
+	assert.Equal(t, []database.RelationDTO{{From: "Quarterly planning 2", To: "Quarterly planning m", RelationType: "precedes"}}, graph.Relations)
+
+	// Nothing is left to change
+	res, _, err = s.handleMigrateToPolicy(ctx, MigrateToPolicyParams{})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[policyMigration](t, res).Changes)
+}
+
+func TestServer_PreviewRetention(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	// Without a policy nothing would go
+	res, _, err := s.handlePreviewRetention(ctx)
+	assert.NoError(t, err)
+	empty := unmarshalJSON[retentionPreview](t, res)
+	assert.Nil(t, empty.Policy)
+	assert.Empty(t, empty.Preview.Rules)
+	assert.Nil(t, empty.LastRun)
+	assert.Equal(t, false, s.Capabilities()["retentionPolicy"])
+
+	old := time.Now().AddDate(-2, 0, 0).UTC().Format(time.RFC3339)
+	_, err = db.ImportJSONL(ctx, strings.NewReader(
+		`{"v":1,"kind":"entity","name":"Standup","entityType":"conversation","observations":[{"content":"old note","createdAt":"`+old+`"},"new note"]}`+"\n"+
+			`{"v":1,"kind":"entity","name":"Handbook","entityType":"conversation","observations":[{"content":"old rule","createdAt":"`+old+`"}]}`+"\n"))
+	assert.NoError(t, err)
+	db.SetRetentionPolicy(&database.RetentionPolicy{
+		Rules:  []database.RetentionRule{{EntityType: "conversation", MaxAge: database.RetentionAge(365 * 24 * time.Hour), Action: database.RetentionPurge}},
+		Pinned: []string{"Handbook"},
+	})
+	defer db.SetRetentionPolicy(nil)
+	assert.Equal(t, true, s.Capabilities()["retentionPolicy"])
+
+	res, _, err = s.handlePreviewRetention(ctx)
+	assert.NoError(t, err)
+	preview := unmarshalJSON[retentionPreview](t, res)
+	assert.Equal(t, db.RetentionPolicy(), preview.Policy)
+	assert.True(t, preview.Preview.DryRun)
+	assert.Equal(t, 1, preview.Preview.Purged)
+	assert.Equal(t, 1, preview.Preview.Rules[0].Entities)
+	assert.Equal(t, 1, preview.Preview.Rules[0].PinnedObservations)
+	assert.Nil(t, preview.LastRun)
+
+	// The maintenance job applies it; the preview then reports the run
+	_, err = db.ApplyRetention(ctx, time.Now(), false)
+	assert.NoError(t, err)
+	res, _, err = s.handlePreviewRetention(ctx)
+	assert.NoError(t, err)
+	preview = unmarshalJSON[retentionPreview](t, res)
+	assert.Zero(t, preview.Preview.Purged)
+	if assert.NotNil(t, preview.LastRun) {
+		assert.Equal(t, 1, preview.LastRun.Purged)
+		assert.False(t, preview.LastRun.DryRun)
+	}
+	graph, err := db.OpenNodes(ctx, []string{"Standup", "Handbook"})
+	assert.NoError(t, err)
+	for _, e := range graph.Entities {
+		if e.Name == "Standup" {
+			assert.Equal(t, []string{"new note"}, e.Observations)
+		} else {
+			assert.Equal(t, []string{"old rule"}, e.Observations)
+		}
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	assert.Equal(t, []string{"abc", "def"}, chunkText("abcdef", 3))
+	assert.Equal(t, []string{"one two", "three"}, chunkText("one two three", 9))
+	assert.Equal(t, []string{"é", "é"}, chunkText("éé", 3))
+	// A character longer than the limit is kept whole
+	assert.Equal(t, []string{"😀", "a"}, chunkText("😀a", 2))
+	assert.Empty(t, chunkText("   ", 2))
+}
+
+// recordingTransport keeps a copy of every response body read through it
+type recordingTransport struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(resp.Body, rt), resp.Body}
+	return resp, nil
+}
+
+func (rt *recordingTransport) Write(p []byte) (int, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.buf.Write(p)
+}
+
+func TestServer_SSE_EventLimit(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	const eventLimit = 32 << 10
+
+	// About 300 KB of graph, ten times the event limit
+	entities := make([]database.EntityWithObservations, 200)
+	relations := make([]database.RelationDTO, 0, len(entities))
+	for i := range entities {
+		observations := make([]string, 4)
+		for j := range observations {
+			observations[j] = fmt.Sprintf("observation %d of entity %d: %s", j, i, strings.Repeat("x", 300))
+		}
+		entities[i] = database.EntityWithObservations{Name: fmt.Sprintf("Entity_%03d", i), EntityType: "thing", Observations: observations}
+		if i > 0 {
+			relations = append(relations, database.RelationDTO{From: entities[i-1].Name, To: entities[i].Name, RelationType: "next"})
+		}
+	}
+	_, err := db.CreateEntities(ctx, entities)
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, relations)
+	assert.NoError(t, err)
+
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ts := httptest.NewServer(router.NewRouter(m, logger, &router.RouterConfig{
+		EnableSSE: true,
+		SSEContext: func(ctx context.Context) context.Context {
+			return WithEventLimit(ctx, eventLimit)
+		},
+	}))
+	defer ts.Close()
+
+	recorder := &recordingTransport{}
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, &mcp.SSEClientTransport{
+		Endpoint:   ts.URL + router.SSE,
+		HTTPClient: &http.Client{Transport: recorder},
+	}, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+
+	// The graph is linked rather than sent in one event, and its pages shrink to fit
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "read_graph", Arguments: map[string]any{}})
+	assert.NoError(t, err)
+	assert.False(t, res.IsError)
+	var link *mcp.ResourceLink
+	for _, content := range res.Content {
+		if l, ok := content.(*mcp.ResourceLink); ok {
+			link = l
+		}
+	}
+	if !assert.NotNil(t, link) {
+		return
+	}
+
+	var got database.KnowledgeGraph
+	offset := 0
+	for pages := 0; ; pages++ {
+		assert.Less(t, pages, 100)
+		rr, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: fmt.Sprintf("%s?offset=%d", link.URI, offset)})
+		assert.NoError(t, err)
+		var p ResultPage
+		assert.NoError(t, json.Unmarshal([]byte(rr.Contents[0].Text), &p))
+		assert.NotZero(t, len(p.Entities)+len(p.Relations))
+		got.Entities = append(got.Entities, p.Entities...)
+		got.Relations = append(got.Relations, p.Relations...)
+		if p.NextOffset == nil {
+			break
+		}
+		assert.Greater(t, *p.NextOffset, offset)
+		offset = *p.NextOffset
+	}
+	full, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, full.Entities, got.Entities)
+	assert.Equal(t, full.Relations, got.Relations)
+
+	// Other results too large for an event fail instead of being cut
+	names := make([]string, 50)
+	for i := range names {
+		names[i] = entities[i].Name
+	}
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "open_nodes", Arguments: map[string]any{"names": names}})
+	assert.NoError(t, err)
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrEventTooLarge, res.Meta[ErrorCodeMetaKey])
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "open_nodes", Arguments: map[string]any{"names": names[:5]}})
+	assert.NoError(t, err)
+	assert.False(t, res.IsError)
+	assert.NoError(t, session.Close())
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	events := 0
+	for _, line := range strings.Split(recorder.buf.String(), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			events++
+			assert.LessOrEqual(t, len(line), eventLimit)
+		}
+	}
+	assert.Greater(t, events, 10)
+}
+
+func TestServer_DeleteEntities_Reassign(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "OldAuthService", EntityType: "service"},
+		{Name: "AuthService", EntityType: "service"},
+		{Name: "Gateway", EntityType: "service"},
+		{Name: "Scratch", EntityType: "note"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Gateway", To: "OldAuthService", RelationType: "calls"},
+		{From: "Gateway", To: "AuthService", RelationType: "calls"},
+		{From: "Scratch", To: "OldAuthService", RelationType: "mentions"},
+	}})
+	assert.NoError(t, err)
+
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	_, err = m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+	call := func(items ...any) *mcp.CallToolResult {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "delete_entities", Arguments: map[string]any{"entityNames": items}})
+		assert.NoError(t, err)
+		return res
+	}
+
+	// Self-reassignment and unknown fields are rejected before anything is deleted
+	res := call("Scratch", map[string]any{"name": "OldAuthService", "reassignRelationsTo": "OldAuthService"})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrReassignToSelf, res.Meta[ErrorCodeMetaKey])
+	_, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "delete_entities", Arguments: map[string]any{
+		"entityNames": []any{map[string]any{"name": "OldAuthService", "successor": "AuthService"}},
+	}})
+	assert.ErrorContains(t, err, "unknown field")
+	res = call(map[string]any{"name": "OldAuthService", "reassignRelationsTo": "Missing"})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrDeleteEntities, res.Meta[ErrorCodeMetaKey])
+	graph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 4)
+
+	// Plain names and objects mix; the duplicate calls relation is dropped
+	res = call("Scratch", map[string]any{"name": "OldAuthService", "reassignRelationsTo": "AuthService"})
+	assert.False(t, res.IsError)
+	var out struct {
+		Reassigned []database.Reassignment `json:"reassigned"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(jsonText(t, res)), &out))
+	assert.Equal(t, []database.Reassignment{{Entity: "OldAuthService", Successor: "AuthService", Moved: 0, Dropped: 1, Deleted: true}}, out.Reassigned)
+	graph, err = db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graph.Entities, 2)
+	assert.Equal(t, []database.RelationDTO{{From: "Gateway", To: "AuthService", RelationType: "calls"}}, graph.Relations)
+}
+
+func TestServer_Graphs(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	_, err := m.Connect(ctx, serverTransport, nil)
+	assert.NoError(t, err)
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, clientTransport, nil)
+	assert.NoError(t, err)
+	defer session.Close()
+	call := func(name string, args map[string]any) *mcp.CallToolResult {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		assert.NoError(t, err, name)
+		return res
+	}
+
+	tools, err := session.ListTools(ctx, nil)
+	if assert.NoError(t, err) {
+		for _, tool := range tools.Tools {
+			schema, _ := json.Marshal(tool.InputSchema)
+			assert.Contains(t, string(schema), `"graph"`, tool.Name)
+		}
+	}
+
+	// The same name in two graphs names two entities
+	for _, graph := range []string{"project-a", ""} {
+		res := call("create_entities", map[string]any{"graph": graph, "entities": []any{
+			map[string]any{"name": "Alice", "entityType": "person", "observations": []any{"in " + graph}},
+		}})
+		assert.False(t, res.IsError, jsonText(t, res))
+	}
+	res := call("read_graph", map[string]any{"graph": "project-a"})
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, []string{"in project-a"}, graph.Entities[0].Observations)
+	}
+	defaultGraph, err := db.ReadGraph(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, defaultGraph.Entities, 1) {
+		assert.Equal(t, []string{"in "}, defaultGraph.Entities[0].Observations)
+	}
+
+	res = call("list_graphs", map[string]any{})
+	list := unmarshalJSON[graphList](t, res)
+	if assert.Len(t, list.Graphs, 2) {
+		assert.Equal(t, "default", list.Graphs[0].Name)
+		assert.Equal(t, "project-a", list.Graphs[1].Name)
+		assert.Equal(t, 1, list.Graphs[1].Entities)
+	}
+
+	// Bad names, and tools that work on the whole database, are rejected
+	for _, tc := range []struct {
+		tool  string
+		graph any
+		code  string
+	}{
+		{"read_graph", "../etc", i18n.ErrInvalidGraph},
+		{"read_graph", 7, i18n.ErrInvalidGraph},
+		{"check_integrity", "project-a", i18n.ErrGraphUnsupported},
+	} {
+		res := call(tc.tool, map[string]any{"graph": tc.graph})
+		assert.True(t, res.IsError, tc.tool)
+		assert.Equal(t, tc.code, res.Meta[ErrorCodeMetaKey], tc.tool)
+	}
+	res = call("check_integrity", map[string]any{"graph": "default"})
+	assert.False(t, res.IsError, "the default graph is always accepted")
+	stats := unmarshalJSON[database.GraphStats](t, call("graph_stats", map[string]any{"graph": "project-a"}))
+	assert.Equal(t, 1, stats.Entities, "graph_stats counts the graph named")
+
+	// Stores other than SQLite have only the default graph
+	memory := NewServerWithLogger(store.NewMemory(), nil)
+	var toolErr *ToolError
+	if assert.ErrorAs(t, memory.checkGraph(ctx, "read_graph", "project-a"), &toolErr) {
+		assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code)
+	}
+	assert.NoError(t, memory.checkGraph(ctx, "read_graph", ""))
+}
+
+// headerTransport sets header on every request sent through it
+type headerTransport struct {
+	header http.Header
+}
+
+func (ht headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range ht.header {
+		req.Header[key] = values
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestServer_Namespaces(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	m := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "0"}, nil)
+	s.RegisterTools(m)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ts := httptest.NewServer(router.NewRouter(m, logger, &router.RouterConfig{
+		EnableStream: true,
+		Namespace:    router.HeaderNamespace(router.NamespaceHeader),
+	}))
+	t.Cleanup(ts.Close) // after the sessions, whose streams it waits for
+
+	connect := func(namespace string) *mcp.ClientSession {
+		header := http.Header{}
+		if namespace != "" {
+			header.Set(router.NamespaceHeader, namespace)
+		}
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "0"}, nil).Connect(ctx, &mcp.StreamableClientTransport{
+			Endpoint:   ts.URL + router.HTTP,
+			HTTPClient: &http.Client{Transport: headerTransport{header}},
+		}, nil)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = session.Close() })
+		return session
+	}
+	call := func(session *mcp.ClientSession, name string, args map[string]any) *mcp.CallToolResult {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		assert.NoError(t, err, name)
+		return res
+	}
+
+	teamA, teamB, unbound := connect("team-a"), connect("team-b"), connect("")
+	for session, name := range map[*mcp.ClientSession]string{teamA: "Alice", teamB: "Bob", unbound: "Carol"} {
+		res := call(session, "create_entities", map[string]any{"entities": []any{
+			map[string]any{"name": name, "entityType": "person"},
+			map[string]any{"name": "Acme", "entityType": "company"},
+		}})
+		assert.False(t, res.IsError, jsonText(t, res))
+	}
+
+	// Each namespace reads only what it wrote
+	for session, want := range map[*mcp.ClientSession][]string{teamA: {"Acme", "Alice"}, teamB: {"Acme", "Bob"}, unbound: {"Acme", "Carol"}} {
+		graph := unmarshalJSON[database.KnowledgeGraph](t, call(session, "read_graph", map[string]any{}))
+		var names []string
+		for _, entity := range graph.Entities {
+			names = append(names, entity.Name)
+		}
+		assert.ElementsMatch(t, want, names)
+	}
+	graphs, err := db.ListGraphs(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, graphs, 3)
+
+	// A bound client can't reach past its graph
+	res := call(teamA, "read_graph", map[string]any{"graph": "team-b"})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrGraphNamespace, res.Meta[ErrorCodeMetaKey])
+	res = call(teamA, "check_integrity", map[string]any{})
+	assert.True(t, res.IsError)
+	assert.Equal(t, i18n.ErrGraphUnsupported, res.Meta[ErrorCodeMetaKey])
+	stats := unmarshalJSON[database.GraphStats](t, call(teamA, "graph_stats", map[string]any{}))
+	assert.Equal(t, 2, stats.Entities, "graph_stats counts the client's graph")
+	res = call(teamA, "read_graph", map[string]any{"graph": "team-a"})
+	assert.False(t, res.IsError, "naming its own graph is allowed")
+	res = call(teamA, "get_capabilities", map[string]any{})
+	assert.False(t, res.IsError, "tools that don't read the graph are allowed")
+	list := unmarshalJSON[graphList](t, call(teamA, "list_graphs", map[string]any{}))
+	if assert.Len(t, list.Graphs, 1) {
+		assert.Equal(t, "team-a", list.Graphs[0].Name)
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Standup", EntityType: "meeting", Observations: []string{"discussed the release"}, Tags: []string{"source:slack"}},
+		{Name: "Retro", EntityType: "meeting", Observations: []string{"discussed the release"}},
+		{Name: "Launch", EntityType: "event", Observations: []string{"the release shipped"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleChangeTags(ctx, "add_tags", TagsParams{Entities: []database.EntityTags{
+		{EntityName: "Standup", Tags: []string{"important", "source:slack"}},
+		{EntityName: "Launch", Tags: []string{"important"}},
+	}})
+	assert.NoError(t, err)
+	changes := unmarshalJSON[[]database.TagChangeResult](t, res)
+	assert.Equal(t, []database.TagChangeResult{
+		{EntityName: "Standup", Changed: []string{"important"}, Unchanged: []string{"source:slack"}},
+		{EntityName: "Launch", Changed: []string{"important"}, Unchanged: []string{}},
+	}, changes)
+
+	// Tags narrow a text query, and every tag must match
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "discussed", Tags: []string{"important"}})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Standup", graph.Entities[0].Name)
+		assert.Equal(t, []string{"important", "source:slack"}, graph.Entities[0].Tags)
+	}
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Standup", "Retro", "Launch"}, Tags: []string{"important", "source:slack"}})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 1)
+
+	res, _, err = s.handleChangeTags(ctx, "remove_tags", TagsParams{Entities: []database.EntityTags{{EntityName: "Standup", Tags: []string{"important"}}}})
+	assert.NoError(t, err)
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "release", Tags: []string{"important"}})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Launch", graph.Entities[0].Name)
+	}
+
+	for name, params := range map[string]TagsParams{
+		i18n.ErrNoEntities:  {},
+		i18n.ErrNoTags:      {Entities: []database.EntityTags{{EntityName: "Launch"}}},
+		i18n.ErrTagEmpty:    {Entities: []database.EntityTags{{EntityName: "Launch", Tags: []string{" "}}}},
+		i18n.ErrTagInvalid:  {Entities: []database.EntityTags{{EntityName: "Launch", Tags: []string{" padded"}}}},
+		i18n.ErrTagTooLong:  {Entities: []database.EntityTags{{EntityName: "Launch", Tags: []string{strings.Repeat("x", database.MaxTagLength+1)}}}},
+		i18n.ErrTooManyTags: {Entities: []database.EntityTags{{EntityName: "Launch", Tags: make([]string, MaxTagsPerEntity+1)}}},
+	} {
+		_, _, err := s.handleChangeTags(ctx, "add_tags", params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, name) {
+			assert.Equal(t, name, toolErr.Code)
+		}
+	}
+	_, _, err = s.handleChangeTags(ctx, "add_tags", TagsParams{Entities: []database.EntityTags{{EntityName: "Nobody", Tags: []string{"x"}}}})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrEntityNotFound, toolErr.Code)
+	}
+
+	// Stores other than SQLite keep no tags
+	memory := NewServerWithLogger(store.NewMemory(), nil)
+	_, _, err = memory.handleSearchNodes(ctx, SearchNodesParams{Query: "release", Tags: []string{"important"}})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code)
+	}
+}
+
+func TestServer_Attributes(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Acme", EntityType: "company", Attributes: map[string]any{"url": "https://acme.example", "ids": map[string]any{"crm": "CRM-4711"}}},
+		{Name: "Globex", EntityType: "company"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleUpdateEntities(ctx, UpdateEntitiesParams{Entities: []database.EntityUpdate{
+		{Name: "Globex", Attributes: map[string]any{"score": 0.5}},
+	}})
+	assert.NoError(t, err)
+	updated := unmarshalJSON[[]database.UpdatedEntity](t, res)
+	assert.Equal(t, []database.UpdatedEntity{{Name: "Globex", EntityType: "company", Attributes: map[string]any{"score": 0.5}}}, updated)
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "CRM-4711", SearchAttributes: true})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Acme", graph.Entities[0].Name)
+		assert.Equal(t, map[string]any{"url": "https://acme.example", "ids": map[string]any{"crm": "CRM-4711"}}, graph.Entities[0].Attributes)
+	}
+
+	tooLarge := map[string]any{"notes": strings.Repeat("x", database.MaxAttributesBytes)}
+	for code, call := range map[string]func() error{
+		i18n.ErrAttributesTooLarge: func() error {
+			_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "Initech", EntityType: "company", Attributes: tooLarge}}})
+			return err
+		},
+		i18n.ErrNoEntityChanges: func() error {
+			_, _, err := s.handleUpdateEntities(ctx, UpdateEntitiesParams{Entities: []database.EntityUpdate{{Name: "Acme"}}})
+			return err
+		},
+		i18n.ErrAttributeSearchMode: func() error {
+			_, _, err := s.handleSearchNodes(ctx, SearchNodesParams{Query: "Acme", Mode: database.SearchExact, SearchAttributes: true})
+			return err
+		},
+		i18n.ErrEntityNotFound: func() error {
+			_, _, err := s.handleUpdateEntities(ctx, UpdateEntitiesParams{Entities: []database.EntityUpdate{{Name: "Nobody", Attributes: map[string]any{"x": 1}}}})
+			return err
+		},
+	} {
+		var toolErr *ToolError
+		if assert.ErrorAs(t, call(), &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+
+	// Growing past the limit by merging fails with the same code
+	_, _, err = s.handleUpdateEntities(ctx, UpdateEntitiesParams{Entities: []database.EntityUpdate{
+		{Name: "Acme", Attributes: map[string]any{"notes": strings.Repeat("x", database.MaxAttributesBytes-20)}},
+	}})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrAttributesTooLarge, toolErr.Code)
+		assert.Equal(t, "Acme", toolErr.Details["entityName"])
+	}
+
+	// Stores other than SQLite keep no attributes
+	memory := NewServerWithLogger(store.NewMemory(), nil)
+	_, _, err = memory.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "Acme", EntityType: "company", Attributes: map[string]any{"x": 1}}}})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code)
+	}
+}
+
+func TestServer_RelationProperties(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+	}})
+	assert.NoError(t, err)
+	rel := database.RelationDTO{From: "Alice", To: "Bob", RelationType: "knows", Confidence: 0.6, Note: "source: standup"}
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{rel}})
+	assert.NoError(t, err)
+
+	rel.Confidence = 0.95
+	res, _, err := s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{rel}, UpdateExisting: true})
+	assert.NoError(t, err)
+	result := unmarshalJSON[database.RelationCreationResult](t, res)
+	assert.Empty(t, result.Skipped)
+	assert.Equal(t, []database.RelationDTO{rel}, result.Updated)
+
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Equal(t, []database.RelationDTO{rel}, graph.Relations)
+
+	for code, bad := range map[string]database.RelationDTO{
+		i18n.ErrInvalidConfidence:   {From: "Alice", To: "Bob", RelationType: "knows", Confidence: 1.5},
+		i18n.ErrRelationNoteTooLong: {From: "Alice", To: "Bob", RelationType: "knows", Note: strings.Repeat("x", MaxRelationNoteLength+1)},
+	} {
+		_, _, err := s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{bad}})
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+
+	// Stores other than SQLite keep no relation properties
+	memory := NewServerWithLogger(store.NewMemory(), nil)
+	_, _, err = memory.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{{From: "A", To: "B", RelationType: "knows", Note: "x"}}})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code)
+	}
+}
+
+func TestServer_SoftDelete(t *testing.T) {
+	s, db := newTestServer(t)
+	db.SetSoftDelete(true)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Bob", EntityType: "person"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}}})
+	assert.NoError(t, err)
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "Alice"}}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleListDeleted(ctx)
+	assert.NoError(t, err)
+	deleted := unmarshalJSON[[]database.DeletedEntity](t, res)
+	if assert.Len(t, deleted, 1) {
+		assert.Equal(t, "Alice", deleted[0].Name)
+		assert.Equal(t, 1, deleted[0].Observations)
+		assert.Equal(t, 1, deleted[0].Relations)
+	}
+
+	res, _, err = s.handleRestoreEntities(ctx, RestoreEntitiesParams{EntityNames: []string{"Alice"}})
+	assert.NoError(t, err)
+	restored := unmarshalJSON[database.RestoreResult](t, res)
+	assert.Equal(t, []string{"Alice"}, restored.Restored)
+	assert.Equal(t, 1, restored.RestoredRelations)
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	assert.Len(t, graph.Entities, 2)
+	assert.Len(t, graph.Relations, 1)
+
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "Bob"}}})
+	assert.NoError(t, err)
+	res, _, err = s.handlePurgeDeleted(ctx, PurgeDeletedParams{})
+	assert.NoError(t, err)
+	purged := unmarshalJSON[[]string](t, res)
+	assert.Equal(t, []string{"Bob"}, purged)
+
+	for code, call := range map[string]func() error{
+		i18n.ErrNoEntityNames: func() error {
+			_, _, err := s.handleRestoreEntities(ctx, RestoreEntitiesParams{})
+			return err
+		},
+		i18n.ErrInvalidPurgeAge: func() error {
+			_, _, err := s.handlePurgeDeleted(ctx, PurgeDeletedParams{OlderThanDays: -1})
+			return err
+		},
+	} {
+		var toolErr *ToolError
+		if assert.ErrorAs(t, call(), &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_GetHistory(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	before := time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{{EntityName: "Bob", Contents: []string{"likes tea"}}}})
+	assert.NoError(t, err)
+	_, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "Alice"}}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGetHistory(ctx, GetHistoryParams{EntityName: "Bob", Since: before, Limit: 1})
+	assert.NoError(t, err)
+	page := unmarshalJSON[database.HistoryPage](t, res)
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, "create_entities", page.Entries[0].Operation)
+		assert.True(t, page.HasMore)
+	}
+	res, _, err = s.handleGetHistory(ctx, GetHistoryParams{EntityName: "Bob", AfterID: page.Entries[0].ID})
+	assert.NoError(t, err)
+	page = unmarshalJSON[database.HistoryPage](t, res)
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, "add_observations", page.Entries[0].Operation)
+		assert.False(t, page.HasMore)
+	}
+	res, _, err = s.handleGetHistory(ctx, GetHistoryParams{Until: before})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.HistoryPage](t, res).Entries)
+
+	for code, params := range map[string]GetHistoryParams{
+		i18n.ErrInvalidTime:      {Since: "yesterday"},
+		i18n.ErrNegativeAfterID:  {AfterID: -1},
+		i18n.ErrInvalidPageLimit: {Limit: database.MaxHistoryLimit + 1},
+	} {
+		_, _, err := s.handleGetHistory(ctx, params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_Snapshots(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "Alice", EntityType: "person"}}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateSnapshot(ctx, SnapshotParams{Label: "v1"})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{{Name: "Bob", EntityType: "person"}}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleDiffSnapshot(ctx, SnapshotParams{Label: "v1"})
+	assert.NoError(t, err)
+	diff := unmarshalJSON[database.SnapshotDiff](t, res)
+	assert.Equal(t, []string{"Bob"}, diff.EntitiesAdded)
+	assert.Empty(t, diff.EntitiesRemoved)
+	res, _, err = s.handleListSnapshots(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[[]database.GraphSnapshot](t, res), 1)
+
+	for code, call := range map[string]func() error{
+		i18n.ErrInvalidSnapshotLabel: func() error {
+			_, _, err := s.handleCreateSnapshot(ctx, SnapshotParams{})
+			return err
+		},
+		i18n.ErrSnapshotExists: func() error {
+			_, _, err := s.handleCreateSnapshot(ctx, SnapshotParams{Label: "v1"})
+			return err
+		},
+		i18n.ErrSnapshotTooLarge: func() error {
+			db.SetSnapshotLimits(0, 1)
+			defer db.SetSnapshotLimits(0, 0)
+			_, _, err := s.handleCreateSnapshot(ctx, SnapshotParams{Label: "v2"})
+			return err
+		},
+		i18n.ErrSnapshotNotFound: func() error {
+			_, _, err := s.handleDiffSnapshot(ctx, SnapshotParams{Label: "v2"})
+			return err
+		},
+	} {
+		var toolErr *ToolError
+		if assert.ErrorAs(t, call(), &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_FindOrphans(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+		{Name: "Loner", EntityType: "person"},
+		{Name: "Notes", EntityType: "topic", Observations: []string{"draft"}},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleFindOrphans(ctx, FindOrphansParams{NoObservations: true})
+	assert.NoError(t, err)
+	report := unmarshalJSON[database.OrphanReport](t, res)
+	if assert.Len(t, report.Orphans, 1) {
+		assert.Equal(t, "Loner", report.Orphans[0].Name)
+	}
+	assert.False(t, report.Deleted)
+
+	res, _, err = s.handleFindOrphans(ctx, FindOrphansParams{DeleteOrphans: true})
+	assert.NoError(t, err)
+	report = unmarshalJSON[database.OrphanReport](t, res)
+	assert.Len(t, report.Orphans, 2)
+	assert.True(t, report.Deleted)
+	res, _, err = s.handleFindOrphans(ctx, FindOrphansParams{})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.OrphanReport](t, res).Orphans)
+
+	for _, params := range []FindOrphansParams{{OlderThanDays: -1}, {Limit: -1}, {Limit: database.MaxOrphanLimit + 1}} {
+		_, _, err := s.handleFindOrphans(ctx, params)
+		assert.Error(t, err, params)
+	}
+}
+
+func TestServer_GraphHotspots(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Hub", EntityType: "project"},
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Hub", RelationType: "works_on"},
+		{From: "Bob", To: "Hub", RelationType: "works_on"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleGraphHotspots(ctx, GraphHotspotsParams{By: database.DegreeIn})
+	assert.NoError(t, err)
+	hotspots := unmarshalJSON[[]database.Hotspot](t, res)
+	if assert.NotEmpty(t, hotspots) {
+		assert.Equal(t, database.Hotspot{Name: "Hub", EntityType: "project", In: 2, Total: 2}, hotspots[0])
+	}
+
+	for _, params := range []GraphHotspotsParams{{By: "degree"}, {EntityType: strings.Repeat("x", 1000)}, {Limit: database.MaxHotspotLimit + 1}} {
+		_, _, err := s.handleGraphHotspots(ctx, params)
+		assert.Error(t, err, params)
+	}
+}
+
+func TestServer_ExternalRelations(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person"},
+		{Name: "Bob", EntityType: "person"},
+		{Name: "Target", EntityType: "project"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Target", RelationType: "works_on"},
+		{From: "Bob", To: "Target", RelationType: "works_on"},
+		{From: "Alice", To: "Bob", RelationType: "knows"},
+	}})
+	assert.NoError(t, err)
+
+	// Target only has incoming relations, shown when asked for
+	res, _, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Target"}})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.KnowledgeGraph](t, res).Relations)
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Target"}, IncludeExternalRelations: database.ExternalRelationsIncoming})
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RelationDTO{
+		{From: "Alice", To: "Target", RelationType: "works_on"},
+		{From: "Bob", To: "Target", RelationType: "works_on"},
+	}, unmarshalJSON[database.KnowledgeGraph](t, res).Relations)
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Target"}, IncludeExternalRelations: database.ExternalRelationsOutgoing})
+	assert.NoError(t, err)
+	assert.Empty(t, unmarshalJSON[database.KnowledgeGraph](t, res).Relations)
+
+	res, _, err = s.handleFindRelations(ctx, FindRelationsParams{To: "Target", Limit: 1})
+	assert.NoError(t, err)
+	page := unmarshalJSON[database.RelationMatches](t, res)
+	assert.Equal(t, []database.RelationDTO{{From: "Alice", To: "Target", RelationType: "works_on"}}, page.Relations)
+	assert.Equal(t, 2, page.TotalRelations)
+	if assert.NotNil(t, page.NextOffset) {
+		assert.Equal(t, 1, *page.NextOffset)
+	}
+	res, _, err = s.handleFindRelations(ctx, FindRelationsParams{From: "Alice", RelationType: "knows"})
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}}, unmarshalJSON[database.RelationMatches](t, res).Relations)
+
+	var toolErr *ToolError
+	_, _, err = s.handleOpenNodes(ctx, OpenNodesParams{IncludeExternalRelations: "sideways"})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrInvalidExternalRelations, toolErr.Code)
+	}
+	for _, params := range []FindRelationsParams{
+		{From: strings.Repeat("x", MaxEntityNameLength+1)},
+		{RelationType: strings.Repeat("r", MaxRelationTypeLength+1)},
+		{Limit: MaxRelationPageSize + 1},
+		{Offset: -1},
+	} {
+		_, _, err := s.handleFindRelations(ctx, params)
+		assert.Error(t, err, params)
+	}
+
+	// Relations to other entities need SQLite
+	memory := NewServerWithLogger(store.NewMemory(), nil)
+	_, _, err = memory.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Target"}, IncludeExternalRelations: database.ExternalRelationsBoth})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNeedsSQLite, toolErr.Code)
+	}
+}
+
+func TestServer_FindDuplicates(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "VS Code", EntityType: "tool"},
+		{Name: "VSCode", EntityType: "tool"},
+		{Name: "Kubernetes", EntityType: "tool"},
+		{Name: "Kubernets", EntityType: "tool"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleFindDuplicates(ctx, FindDuplicatesParams{})
+	assert.NoError(t, err)
+	report := unmarshalJSON[database.DuplicateReport](t, res)
+	if assert.Len(t, report.Clusters, 1) {
+		assert.Equal(t, database.DuplicatePunctuation, report.Clusters[0].Reason)
+	}
+	res, _, err = s.handleFindDuplicates(ctx, FindDuplicatesParams{Threshold: 0.9, EntityType: "tool"})
+	assert.NoError(t, err)
+	assert.Len(t, unmarshalJSON[database.DuplicateReport](t, res).Clusters, 2)
+
+	for code, params := range map[string]FindDuplicatesParams{
+		i18n.ErrInvalidDuplicateThreshold: {Threshold: 1.5},
+		i18n.ErrInvalidPageLimit:          {Limit: database.MaxDuplicateLimit + 1},
+	} {
+		_, _, err := s.handleFindDuplicates(ctx, params)
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_PinEntities(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "User Preferences", EntityType: "profile"},
+		{Name: "Scratch", EntityType: "note"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handlePin(ctx, "pin_entities", PinParams{EntityNames: []string{"User Preferences"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User Preferences"}, unmarshalJSON[database.PinResult](t, res).Changed)
+
+	res, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "User Preferences"}, {Name: "Scratch"}}})
+	assert.NoError(t, err)
+	deleted := unmarshalJSON[database.EntityDeletionResult](t, res)
+	assert.Equal(t, 1, deleted.DeletedEntities)
+	assert.Equal(t, []string{"User Preferences"}, deleted.Protected)
+
+	res, _, err = s.handleClearGraph(ctx, ClearGraphParams{Confirm: ClearGraphConfirmation})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User Preferences"}, unmarshalJSON[database.ClearReport](t, res).Protected)
+
+	res, _, err = s.handleDeleteEntities(ctx, DeleteEntitiesParams{EntityNames: []EntityDeletion{{Name: "User Preferences"}}, Force: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, unmarshalJSON[database.EntityDeletionResult](t, res).DeletedEntities)
+
+	_, _, err = s.handlePin(ctx, "unpin_entities", PinParams{EntityNames: []string{"User Preferences"}})
+	var toolErr *ToolError
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrEntityNotFound, toolErr.Code)
+	}
+	_, _, err = s.handlePin(ctx, "pin_entities", PinParams{})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNoEntityNames, toolErr.Code)
+	}
+}
+
+func TestServer_ObservationExpiry(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "User", EntityType: "person", Observations: []string{"prefers Go"}},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{
+		{EntityName: "User", Contents: []string{"debugging issue 42"}, TTLSeconds: 3600},
+	}})
+	assert.NoError(t, err)
+	added := unmarshalJSON[[]database.ObservationAdditionResult](t, res)
+	if expiresAt, err := time.Parse(time.RFC3339, added[0].ExpiresAt); assert.NoError(t, err) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+	}
+
+	// An observation added already expired stands in for a short TTL that has run out
+	_, err = db.AddObservations(ctx, []database.ObservationAdditionInput{
+		{EntityName: "User", Contents: []string{"in a meeting"}, ExpiresAt: time.Now().Add(-time.Second)},
+	})
+	assert.NoError(t, err)
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"User"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prefers Go", "debugging issue 42"}, unmarshalJSON[database.KnowledgeGraph](t, res).Entities[0].Observations)
+
+	res, _, err = s.handleGraphStats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, unmarshalJSON[database.GraphStats](t, res).ExpiredObservations)
+	purged, err := db.PurgeExpiredObservations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	res, _, err = s.handleGraphStats(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, unmarshalJSON[database.GraphStats](t, res).ExpiredObservations)
+
+	for code, obs := range map[string]ObservationInput{
+		i18n.ErrConflictingExpiry: {ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339), TTLSeconds: 60},
+		i18n.ErrInvalidTTL:        {TTLSeconds: -1},
+		i18n.ErrInvalidTime:       {ExpiresAt: "tomorrow"},
+		i18n.ErrExpiryInPast:      {ExpiresAt: "2020-01-01T00:00:00Z"},
+	} {
+		obs.EntityName, obs.Contents = "User", []string{"short-lived"}
+		_, _, err := s.handleAddObservations(ctx, AddObservationsParams{Observations: []ObservationInput{obs}})
+		var toolErr *ToolError
+		if assert.ErrorAs(t, err, &toolErr, code) {
+			assert.Equal(t, code, toolErr.Code)
+		}
+	}
+}
+
+func TestServer_AddAlias(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Robert Smith", EntityType: "person"},
+		{Name: "Acme", EntityType: "company"},
+	}})
+	assert.NoError(t, err)
+
+	res, _, err := s.handleAddAlias(ctx, AliasParams{EntityName: "Robert Smith", Aliases: []string{"Bob", "Robert"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bob", "Robert"}, unmarshalJSON[database.AliasResult](t, res).Added)
+
+	// A relation from an alias attaches to the entity
+	res, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Bob", To: "Acme", RelationType: "works_at"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []database.RelationDTO{{From: "Robert Smith", To: "Acme", RelationType: "works_at"}},
+		unmarshalJSON[database.RelationCreationResult](t, res).Relations)
+
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Robert"}, IncludeAliases: true})
+	assert.NoError(t, err)
+	graph := unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, "Robert Smith", graph.Entities[0].Name)
+		assert.Equal(t, []string{"Bob", "Robert"}, graph.Entities[0].Aliases)
+	}
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "bob", IncludeAliases: true})
+	assert.NoError(t, err)
+	graph = unmarshalJSON[database.KnowledgeGraph](t, res)
+	if assert.Len(t, graph.Entities, 1) {
+		assert.Equal(t, []string{"Bob", "Robert"}, graph.Entities[0].Aliases)
+	}
+
+	var toolErr *ToolError
+	_, _, err = s.handleAddAlias(ctx, AliasParams{EntityName: "Robert Smith", Aliases: []string{"Acme"}})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrAliasIsEntityName, toolErr.Code)
+		assert.Equal(t, map[string]any{"alias": "Acme", "entityName": "Acme"}, toolErr.Details)
+	}
+	_, _, err = s.handleAddAlias(ctx, AliasParams{EntityName: "Acme", Aliases: []string{"Bob"}})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrAliasTaken, toolErr.Code)
+		assert.Equal(t, `alias "Bob" already names entity "Robert Smith"`, toolErr.Message)
+	}
+	_, _, err = s.handleAddAlias(ctx, AliasParams{EntityName: "Acme"})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrNoAliases, toolErr.Code)
+	}
+}
+
+func TestServer_SummaryDetail(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	observations := make([]string, 50)
+	for i := range observations {
+		observations[i] = fmt.Sprintf("observation %d about the project's long history", i)
+	}
+	_, _, err := s.handleCreateEntities(ctx, CreateEntitiesParams{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: observations},
+		{Name: "Project", EntityType: "project", Observations: []string{"uses Go"}},
+		{Name: "Empty", EntityType: "note"},
+	}})
+	assert.NoError(t, err)
+	_, _, err = s.handleCreateRelations(ctx, CreateRelationsParams{Relations: []database.RelationDTO{
+		{From: "Alice", To: "Project", RelationType: "works_on"},
+	}})
+	assert.NoError(t, err)
+	// Counts are of every observation, not only those a full read would list
+	db.SetObservationLimit(10)
+
+	counts := map[string]int{"Alice": 50, "Project": 1, "Empty": 0}
+	assertSummary := func(name string, summary database.KnowledgeGraphSummary, entities ...string) {
+		t.Helper()
+		var names []string
+		for _, entity := range summary.Entities {
+			names = append(names, entity.Name)
+			assert.Equal(t, counts[entity.Name], entity.ObservationCount, "%s: %s", name, entity.Name)
+		}
+		assert.Equal(t, entities, names, name)
+	}
+
+	// Opened rather than read, as reading the whole graph could return it as a link
+	full, _, err := s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice", "Project", "Empty"}})
+	assert.NoError(t, err)
+	res, _, err := s.handleReadGraph(ctx, ReadGraphParams{Detail: DetailSummary})
+	assert.NoError(t, err)
+	summary := unmarshalJSON[database.KnowledgeGraphSummary](t, res)
+	assertSummary("read_graph", summary, "Alice", "Empty", "Project")
+	assert.Equal(t, []database.RelationDTO{{From: "Alice", To: "Project", RelationType: "works_on"}}, summary.Relations)
+	assert.Less(t, len(jsonText(t, res)), len(jsonText(t, full))/2)
+	assert.NotContains(t, jsonText(t, res), "observation 0")
+
+	res, _, err = s.handleReadGraph(ctx, ReadGraphParams{Detail: DetailSummary, Limit: 2})
+	assert.NoError(t, err)
+	page := unmarshalJSON[summaryGraphPage](t, res)
+	assertSummary("read_graph page", page.KnowledgeGraphSummary, "Alice", "Empty")
+	assert.Equal(t, "Empty", page.NextCursor)
+
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "project", Detail: DetailSummary})
+	assert.NoError(t, err)
+	assertSummary("search_nodes", unmarshalJSON[database.KnowledgeGraphSummary](t, res), "Alice", "Project")
+	res, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "project", Detail: DetailSummary, Limit: 1})
+	assert.NoError(t, err)
+	found := unmarshalJSON[summarySearchResult](t, res)
+	assertSummary("search_nodes page", found.KnowledgeGraphSummary, "Alice")
+	assert.Equal(t, 2, found.TotalMatches)
+
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice", "Empty"}, Detail: DetailSummary})
+	assert.NoError(t, err)
+	assertSummary("open_nodes", unmarshalJSON[database.KnowledgeGraphSummary](t, res), "Alice", "Empty")
+
+	// The full detail stays the default
+	res, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Project"}, Detail: DetailFull})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"uses Go"}, unmarshalJSON[database.KnowledgeGraph](t, res).Entities[0].Observations)
+
+	var toolErr *ToolError
+	_, _, err = s.handleReadGraph(ctx, ReadGraphParams{Detail: "brief"})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrInvalidDetail, toolErr.Code)
+	}
+	_, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice"}, Detail: DetailSummary, IncludeMetadata: true})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrSummaryOption, toolErr.Code)
+	}
+	_, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "Go", Detail: DetailSummary, IncludeSnippets: true})
+	if assert.ErrorAs(t, err, &toolErr) {
+		assert.Equal(t, i18n.ErrSummaryOption, toolErr.Code)
+	}
+}
+
+func TestServer_CompactJSON(t *testing.T) {
+	s, db := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := db.CreateEntities(ctx, []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Observations: []string{"likes Go"}},
+		{Name: "Bob", EntityType: "person"},
+	})
+	assert.NoError(t, err)
+	_, err = db.CreateRelations(ctx, []database.RelationDTO{{From: "Alice", To: "Bob", RelationType: "knows"}})
+	assert.NoError(t, err)
+
+	assertFormats := func(name string, indented, compact *mcp.CallToolResult) {
+		t.Helper()
+		assert.Contains(t, jsonText(t, indented), "\n  ", name)
+		assert.NotContains(t, jsonText(t, compact), "\n", name)
+		assert.Less(t, len(jsonText(t, compact)), len(jsonText(t, indented)), name)
+		assert.JSONEq(t, jsonText(t, indented), jsonText(t, compact), name)
+		assert.Equal(t, indented.StructuredContent, compact.StructuredContent, name)
+	}
+
+	indented, _, err := s.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	compact, _, err := s.handleReadGraph(ctx, ReadGraphParams{Compact: true})
+	assert.NoError(t, err)
+	assertFormats("read_graph", indented, compact)
+
+	indented, _, err = s.handleReadGraph(ctx, ReadGraphParams{Limit: 1, Detail: DetailSummary})
+	assert.NoError(t, err)
+	compact, _, err = s.handleReadGraph(ctx, ReadGraphParams{Limit: 1, Detail: DetailSummary, Compact: true})
+	assert.NoError(t, err)
+	assertFormats("read_graph summary page", indented, compact)
+
+	indented, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "person"})
+	assert.NoError(t, err)
+	compact, _, err = s.handleSearchNodes(ctx, SearchNodesParams{Query: "person", Compact: true})
+	assert.NoError(t, err)
+	assertFormats("search_nodes", indented, compact)
+
+	indented, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice"}})
+	assert.NoError(t, err)
+	compact, _, err = s.handleOpenNodes(ctx, OpenNodesParams{Names: []string{"Alice"}, Compact: true})
+	assert.NoError(t, err)
+	assertFormats("open_nodes", indented, compact)
+
+	// The server option makes every tool's result compact
+	compactServer := NewServerWithOptions(db, nil, Options{CompactJSON: true})
+	indented, _, err = s.handleGetEntity(ctx, GetEntityParams{Name: "Alice"})
+	assert.NoError(t, err)
+	compact, _, err = compactServer.handleGetEntity(ctx, GetEntityParams{Name: "Alice"})
+	assert.NoError(t, err)
+	assertFormats("get_entity", indented, compact)
+	compact, _, err = compactServer.handleReadGraph(ctx, ReadGraphParams{})
+	assert.NoError(t, err)
+	assert.NotContains(t, jsonText(t, compact), "\n")
+
+	// A value that can't be encoded fails the call in either format instead of
+	// returning empty text
+	graph := &database.KnowledgeGraph{Entities: []database.EntityWithObservations{
+		{Name: "Alice", EntityType: "person", Attributes: map[string]any{"bad": make(chan int)}},
+	}}
+	for _, ctx := range []context.Context{ctx, withCompactJSON(ctx)} {
+		var toolErr *ToolError
+		res, out, err := s.graphResult(ctx, "read_graph", graph)
+		assert.Nil(t, res)
+		assert.Nil(t, out)
+		if assert.ErrorAs(t, err, &toolErr) {
+			assert.Equal(t, i18n.ErrEncodeResult, toolErr.Code)
+		}
+		res, _, err = s.marshalResult(ctx, "read_graph", graph)
+		assert.Nil(t, res)
+		if assert.ErrorAs(t, err, &toolErr) {
+			assert.Equal(t, i18n.ErrEncodeResult, toolErr.Code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// withSession returns ctx labeling the rows a write creates with session, if set
+func withSession(ctx context.Context, session string) context.Context {
+	if session == "" {
+		return ctx
+	}
+	return database.WithSession(ctx, session)
+}
+
+// sessionList is the result of list_sessions
+type sessionList struct {
+	Sessions []database.SessionSummary `json:"sessions"`
+}
+
+func (s *Server) handleListSessions(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	sessions, err := s.db.ListSessions(ctx)
+	if err != nil {
+		logger.Error("failed to list sessions",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrListSessions, err)
+	}
+
+	return s.marshalResult(ctx, "list_sessions", &sessionList{Sessions: sessions})
+}
+
+func (s *Server) handleRollbackSession(ctx context.Context, params RollbackSessionParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+	start := time.Now()
+
+	if err := ValidateRollbackSessionParams(params); err != nil {
+		logger.Warn("invalid rollback_session parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	report, err := s.db.RollbackSession(ctx, params.Session)
+	if err != nil {
+		logger.Error("failed to roll back session",
+			slog.String("session", params.Session),
+			slog.String("error", err.Error()),
+			slog.Duration("duration", time.Since(start)),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrRollbackSession, err)
+	}
+
+	logger.Info("session rolled back",
+		slog.String("session", params.Session),
+		slog.Int("entities", report.Entities),
+		slog.Int("observations", report.Observations),
+		slog.Int("relations", report.Relations),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResult(ctx, "rollback_session", report)
+}
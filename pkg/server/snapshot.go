@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// reader returns the database read tools use: a snapshot of Options.SnapshotReads
+// while a long operation holds the primary, or the primary. takenAt is zero for the
+// primary; release must be called once the read is done.
+func (s *Server) reader() (db *database.DB, takenAt time.Time, release func()) {
+	if s.opts.SnapshotReads == nil {
+		return s.db, time.Time{}, func() {}
+	}
+	return s.opts.SnapshotReads.Reader()
+}
+
+// markSnapshot appends a note to a result read from a snapshot taken at takenAt,
+// as writes made since are missing from it
+func markSnapshot(ctx context.Context, res *mcp.CallToolResult, takenAt time.Time) *mcp.CallToolResult {
+	if res != nil && !takenAt.IsZero() {
+		res.Content = append(res.Content, &mcp.TextContent{
+			Text: i18n.T(ctx, i18n.MsgServedFromSnapshot, takenAt.Format(time.RFC3339)),
+		})
+	}
+	return res
+}
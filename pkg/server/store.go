@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/store"
+)
+
+// storeTools are the tools that only need a store.Store; the rest are registered
+// only on the SQLite store
+var storeTools = map[string]bool{
+	"create_entities":      true,
+	"create_relations":     true,
+	"add_observations":     true,
+	"delete_entities":      true,
+	"delete_observations":  true,
+	"delete_relations":     true,
+	"read_graph":           true,
+	"search_nodes":         true,
+	"open_nodes":           true,
+	"get_validation_stats": true,
+	"get_capabilities":     true,
+}
+
+// sqliteOption is a tool option only the SQLite store supports and whether a call
+// uses it
+type sqliteOption struct {
+	name string
+	used bool
+}
+
+// needsSQLite returns an invalid-params error naming the first of options a call
+// uses when the server doesn't run on the SQLite store, and nil otherwise
+func (s *Server) needsSQLite(ctx context.Context, options ...sqliteOption) error {
+	if s.db != nil {
+		return nil
+	}
+	for _, option := range options {
+		if option.used {
+			return s.invalidParams(ctx, i18n.NewError(i18n.ErrNeedsSQLite, option.name))
+		}
+	}
+	return nil
+}
+
+// readStore returns db, from reader, as the store to read from, or the server's
+// store when it isn't SQLite
+func (s *Server) readStore(db *database.DB) store.Store {
+	if db == nil {
+		return s.store
+	}
+	return db
+}
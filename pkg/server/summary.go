@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// Levels of detail of read_graph, search_nodes and open_nodes
+const (
+	DetailFull    = "full"
+	DetailSummary = "summary"
+)
+
+// summaryGraphPage is a page of read_graph with detail "summary"
+type summaryGraphPage struct {
+	database.KnowledgeGraphSummary
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// summarySearchResult is a page of search_nodes with detail "summary"
+type summarySearchResult struct {
+	database.KnowledgeGraphSummary
+	TotalMatches int  `json:"totalMatches"`
+	Offset       int  `json:"offset"`
+	NextOffset   *int `json:"nextOffset,omitempty"`
+}
+
+// entityOption is an option adding fields to the entities a read returns, which a
+// summary leaves out, and whether a call uses it
+type entityOption struct {
+	name string
+	used bool
+}
+
+// detailContext returns ctx under which the SQLite store reads summaries when detail
+// asks for one
+func detailContext(ctx context.Context, detail string) context.Context {
+	if detail == DetailSummary {
+		return database.WithSummary(ctx)
+	}
+	return ctx
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultSyncInterval is the default minimum time between sync_memory calls
+const DefaultSyncInterval = time.Second
+
+// reserveSync claims the next sync_memory slot, returning how long the caller must
+// wait instead when the previous call was less than SyncInterval ago
+func (s *Server) reserveSync(now time.Time) time.Duration {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if wait := s.lastSync.Add(s.opts.SyncInterval).Sub(now); !s.lastSync.IsZero() && wait > 0 {
+		return wait
+	}
+	s.lastSync = now
+	return 0
+}
+
+func (s *Server) handleSyncMemory(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	if wait := s.reserveSync(time.Now()); wait > 0 {
+		// Round up so retrying after the reported time succeeds
+		retry := (wait + time.Second - 1).Truncate(time.Second)
+		return nil, nil, &ToolError{
+			Code:    i18n.ErrSyncRateLimited,
+			Message: i18n.T(ctx, i18n.ErrSyncRateLimited, retry),
+		}
+	}
+
+	start := time.Now()
+	result, err := s.db.Sync(ctx)
+	if err != nil {
+		logging.LoggerWithContext(ctx, s.logger).Error("failed to sync database",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrSyncMemory, err)
+	}
+	logging.LoggerWithContext(ctx, s.logger).Info("database synced",
+		slog.Bool("busy", result.Busy),
+		slog.Int("log_frames", result.LogFrames),
+		slog.Int("checkpointed_frames", result.CheckpointedFrames),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return s.marshalResult(ctx, "sync_memory", result)
+}
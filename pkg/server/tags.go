@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TagsParams are the parameters of add_tags and remove_tags
+type TagsParams struct {
+	Entities []database.EntityTags `json:"entities" jsonschema:"description:Entities and the tags to add to or remove from each, e.g. [{entityName: 'Alice', tags: ['important', 'source:slack']}]"`
+}
+
+// tagChanges is the structured result of add_tags and remove_tags
+type tagChanges struct {
+	Results []database.TagChangeResult `json:"results"`
+}
+
+// registerTagTools registers add_tags and remove_tags
+func (s *Server) registerTagTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "add_tags",
+			Title:        "Add Tags",
+			Description:  "Tag existing entities with free-form labels, such as 'important', 'archived' or 'source:slack', to filter search_nodes and open_nodes by. Tags an entity already has are listed in unchanged; if any entity doesn't exist nothing is tagged",
+			OutputSchema: outputSchema[tagChanges](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params TagsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleChangeTags(ctx, "add_tags", params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "remove_tags",
+			Title:        "Remove Tags",
+			Description:  "Remove tags from entities. Tags an entity doesn't have are listed in unchanged; if any entity doesn't exist nothing is removed",
+			OutputSchema: outputSchema[tagChanges](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params TagsParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleChangeTags(ctx, "remove_tags", params))
+		},
+	)
+}
+
+// handleChangeTags serves add_tags and remove_tags, named by tool
+func (s *Server) handleChangeTags(ctx context.Context, tool string, params TagsParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateTagsParams(params); err != nil {
+		logger.Warn("invalid "+tool+" parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	change, code := s.db.AddTags, i18n.ErrAddTags
+	if tool == "remove_tags" {
+		change, code = s.db.RemoveTags, i18n.ErrRemoveTags
+	}
+	results, err := change(ctx, params.Entities)
+	if err != nil {
+		logger.Warn("failed to change tags",
+			slog.String("tool", tool),
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, code, err)
+	}
+
+	return s.marshalResultAs(ctx, tool, results, &tagChanges{Results: results})
+}
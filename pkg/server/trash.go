@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() {
+	registerCapability("softDelete", func(s *Server) any { return s.db != nil && s.db.SoftDelete() })
+}
+
+// RestoreEntitiesParams are the parameters of restore_entities
+type RestoreEntitiesParams struct {
+	EntityNames []string `json:"entityNames" jsonschema:"description:Names of the deleted entities to restore, as list_deleted lists them"`
+}
+
+// PurgeDeletedParams are the parameters of purge_deleted
+type PurgeDeletedParams struct {
+	EntityNames   []string `json:"entityNames,omitempty" jsonschema:"description:Names of the deleted entities to purge. Omit to purge the whole trash"`
+	OlderThanDays int      `json:"olderThanDays,omitempty" jsonschema:"description:Only purge entities deleted at least this many days ago (default 0: all)"`
+}
+
+// deletedEntities is the result of list_deleted
+type deletedEntities struct {
+	Entities []database.DeletedEntity `json:"entities"`
+}
+
+// purgedEntities is the result of purge_deleted
+type purgedEntities struct {
+	Purged []string `json:"purged"`
+}
+
+// registerTrashTools registers list_deleted, restore_entities and purge_deleted
+func (s *Server) registerTrashTools(mcpServer *mcp.Server) {
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "list_deleted",
+			Title:        "List Deleted Entities",
+			Description:  "List the entities in the trash, most recently deleted first, with how many observations and relations each would bring back. Entities go to the trash when the server keeps deleted entities; restore them with restore_entities",
+			OutputSchema: outputSchema[deletedEntities](),
+			Annotations:  readOnlyTool(),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, _ any) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleListDeleted(ctx))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "restore_entities",
+			Title:        "Restore Entities",
+			Description:  "Bring deleted entities back from the trash with their observations, and their relations to entities that exist. An entity whose name has been taken since it was deleted is left in the trash and listed in conflicts",
+			OutputSchema: outputSchema[database.RestoreResult](),
+			Annotations:  additiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params RestoreEntitiesParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handleRestoreEntities(ctx, params))
+		},
+	)
+
+	addTool(s, mcpServer,
+		&mcp.Tool{
+			Name:         "purge_deleted",
+			Title:        "Purge Deleted Entities",
+			Description:  "Delete entities in the trash for good, with their observations and relations: those named, or all of them, optionally only those deleted at least olderThanDays ago. This cannot be undone",
+			OutputSchema: outputSchema[purgedEntities](),
+			Annotations:  destructiveTool(true),
+		},
+		func(ctx context.Context, req *mcp.CallToolRequest, params PurgeDeletedParams) (*mcp.CallToolResult, any, error) {
+			ctx = s.requestContext(ctx, req)
+			return toolResult(s.handlePurgeDeleted(ctx, params))
+		},
+	)
+}
+
+func (s *Server) handleListDeleted(ctx context.Context) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	entities, err := s.db.ListDeleted(ctx)
+	if err != nil {
+		logger.Error("failed to list deleted entities",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrListDeleted, err)
+	}
+
+	return s.marshalResultAs(ctx, "list_deleted", entities, &deletedEntities{Entities: entities})
+}
+
+func (s *Server) handleRestoreEntities(ctx context.Context, params RestoreEntitiesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateRestoreEntitiesParams(params); err != nil {
+		logger.Warn("invalid restore_entities parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	result, err := s.db.RestoreEntities(ctx, params.EntityNames)
+	if err != nil {
+		logger.Warn("failed to restore entities",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrRestoreEntities, err)
+	}
+
+	return s.marshalResult(ctx, "restore_entities", result)
+}
+
+func (s *Server) handlePurgeDeleted(ctx context.Context, params PurgeDeletedParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidatePurgeDeletedParams(params); err != nil {
+		logger.Warn("invalid purge_deleted parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	purged, err := s.db.PurgeDeleted(ctx, params.EntityNames, params.OlderThanDays)
+	if err != nil {
+		logger.Warn("failed to purge deleted entities",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, operationError(ctx, i18n.ErrPurgeDeleted, err)
+	}
+
+	return s.marshalResultAs(ctx, "purge_deleted", purged, &purgedEntities{Purged: purged})
+}
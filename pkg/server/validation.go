@@ -1,12 +1,20 @@
 package server
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
 )
 
+// Default limits; see Limits for the active ones
 const (
 	MaxEntityNameLength      = 255
 	MaxEntityTypeLength      = 100
@@ -15,61 +23,120 @@ const (
 	MaxEntitiesPerRequest    = 1000
 	MaxObservationsPerEntity = 100
 	MaxSearchQueryLength     = 500
+	MaxSessionLabelLength    = 100
+	MaxRelationNoteLength    = 500
 )
 
-var (
-	// Valid entity name pattern: alphanumeric, spaces, hyphens, underscores, dots
-	entityNamePattern = regexp.MustCompile(`^[a-zA-Z0-9\s\-_.]+$`)
-	
-	// SQL injection patterns to block
-	sqlInjectionPatterns = []string{
-		"--;",
-		"/*",
-		"*/",
-		"xp_",
-		"sp_",
-		"exec",
-		"execute",
-		"select",
-		"insert",
-		"update",
-		"delete",
-		"drop",
-		"create",
-		"alter",
-		"union",
-		"'--",
-		"\"--",
-	}
+// MaxTagsPerEntity is the most tags one call may give an entity or filter by
+const MaxTagsPerEntity = 32
+
+// Highest values the observation length and batch size limits may be raised to. An
+// observation up to the ceiling still fits one SSE event at the default size.
+const (
+	MaxObservationLengthCeiling = 256 << 10
+	MaxBatchSizeCeiling         = 10000
+)
+
+func init() {
+	registerCapability("maxEntitiesPerRequest", func(*Server) any { return ActiveLimits().BatchSize })
+}
+
+// Entity type metadata limits
+const (
+	MaxTypeMetadataKeys        = 32
+	MaxTypeMetadataKeyLength   = 64
+	MaxTypeMetadataValueLength = 256
+)
+
+// MinEraseTermLength keeps erase_subject from matching most of the graph with a short substring
+const MinEraseTermLength = 2
+
+// ClearGraphConfirmation is the confirm value clear_graph requires
+const ClearGraphConfirmation = "DELETE EVERYTHING"
+
+// Chunked import limits and encodings
+const (
+	MaxImportChunkBytes  = 1 << 20
+	ImportEncodingText   = "text"
+	ImportEncodingBase64 = "base64"
+)
+
+// Page sizes for get_observations
+const (
+	DefaultObservationPageSize = 100
+	MaxObservationPageSize     = 1000
+)
+
+// Sizes of recent_entities results
+const (
+	DefaultRecentEntities = 10
+	MaxRecentEntities     = 100
+)
+
+// Page sizes for get_inbound_relations and get_outbound_relations
+const (
+	DefaultRelationPageSize = 100
+	MaxRelationPageSize     = 1000
+)
+
+// Query syntaxes for search_nodes: plain queries are escaped into FTS5 expressions,
+// fts5 queries are passed through as written
+const (
+	SearchSyntaxPlain = "plain"
+	SearchSyntaxFTS5  = "fts5"
+)
+
+// Page sizes for paged search_nodes calls
+const (
+	DefaultSearchPageSize = 50
+	MaxSearchPageSize     = 200
+)
+
+// Page sizes for paged read_graph calls
+const (
+	DefaultGraphPageSize = 100
+	MaxGraphPageSize     = 1000
+)
+
+// Path lengths for find_path
+const (
+	DefaultPathDepth = 6
+	MaxPathDepth     = 10
+)
+
+// Traversal limits for get_neighbors
+const (
+	DefaultNeighborDepth = 1
+	MaxNeighborDepth     = 3
+	MaxNeighborNodes     = 500
+)
+
+// Formats and view limits for export_graph. The dot and graphml formats are
+// database.ViewDOT and database.ViewGraphML.
+const (
+	ExportFormatJSON    = "json"
+	DefaultViewMaxNodes = 500
+	MaxViewNodes        = 5000
 )
 
 // ValidateEntityName validates an entity name
 func ValidateEntityName(name string) error {
 	if name == "" {
-		return fmt.Errorf("entity name cannot be empty")
+		return reject(name, i18n.ErrEntityNameEmpty)
 	}
 	
 	if !utf8.ValidString(name) {
-		return fmt.Errorf("entity name contains invalid UTF-8 characters")
+		return reject(name, i18n.ErrEntityNameInvalidUTF8)
 	}
 	
-	if len(name) > MaxEntityNameLength {
-		return fmt.Errorf("entity name exceeds maximum length of %d characters", MaxEntityNameLength)
+	if limit := ActiveLimits().EntityName; len(name) > limit {
+		return reject(name, i18n.ErrEntityNameTooLong, limit)
 	}
 	
-	// Check for SQL injection patterns
-	nameLower := strings.ToLower(name)
-	for _, pattern := range sqlInjectionPatterns {
-		if strings.Contains(nameLower, pattern) {
-			return fmt.Errorf("entity name contains invalid pattern: %s", pattern)
-		}
-	}
-	
-	// Allow more flexible naming but still prevent control characters
-	for _, r := range name {
-		if r < 32 || r == 127 { // Control characters
-			return fmt.Errorf("entity name contains control characters")
-		}
+	// Any other UTF-8 is allowed, such as accents, CJK, emoji, slashes and
+	// colons, but not control characters, C1 controls included
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return reject(name, i18n.ErrEntityNameControlChars)
 	}
 	
 	return nil
@@ -78,23 +145,15 @@ func ValidateEntityName(name string) error {
 // ValidateEntityType validates an entity type
 func ValidateEntityType(entityType string) error {
 	if entityType == "" {
-		return fmt.Errorf("entity type cannot be empty")
+		return reject(entityType, i18n.ErrEntityTypeEmpty)
 	}
 	
 	if !utf8.ValidString(entityType) {
-		return fmt.Errorf("entity type contains invalid UTF-8 characters")
+		return reject(entityType, i18n.ErrEntityTypeInvalidUTF8)
 	}
 	
-	if len(entityType) > MaxEntityTypeLength {
-		return fmt.Errorf("entity type exceeds maximum length of %d characters", MaxEntityTypeLength)
-	}
-	
-	// Check for SQL injection patterns
-	typeLower := strings.ToLower(entityType)
-	for _, pattern := range sqlInjectionPatterns {
-		if strings.Contains(typeLower, pattern) {
-			return fmt.Errorf("entity type contains invalid pattern: %s", pattern)
-		}
+	if limit := ActiveLimits().EntityType; len(entityType) > limit {
+		return reject(entityType, i18n.ErrEntityTypeTooLong, limit)
 	}
 	
 	return nil
@@ -103,23 +162,15 @@ func ValidateEntityType(entityType string) error {
 // ValidateRelationType validates a relation type
 func ValidateRelationType(relationType string) error {
 	if relationType == "" {
-		return fmt.Errorf("relation type cannot be empty")
+		return reject(relationType, i18n.ErrRelationTypeEmpty)
 	}
 	
 	if !utf8.ValidString(relationType) {
-		return fmt.Errorf("relation type contains invalid UTF-8 characters")
+		return reject(relationType, i18n.ErrRelationTypeInvalidUTF8)
 	}
 	
-	if len(relationType) > MaxRelationTypeLength {
-		return fmt.Errorf("relation type exceeds maximum length of %d characters", MaxRelationTypeLength)
-	}
-	
-	// Check for SQL injection patterns
-	typeLower := strings.ToLower(relationType)
-	for _, pattern := range sqlInjectionPatterns {
-		if strings.Contains(typeLower, pattern) {
-			return fmt.Errorf("relation type contains invalid pattern: %s", pattern)
-		}
+	if limit := ActiveLimits().RelationType; len(relationType) > limit {
+		return reject(relationType, i18n.ErrRelationTypeTooLong, limit)
 	}
 	
 	return nil
@@ -128,15 +179,15 @@ func ValidateRelationType(relationType string) error {
 // ValidateObservation validates an observation
 func ValidateObservation(observation string) error {
 	if observation == "" {
-		return fmt.Errorf("observation cannot be empty")
+		return reject(observation, i18n.ErrObservationEmpty)
 	}
 	
 	if !utf8.ValidString(observation) {
-		return fmt.Errorf("observation contains invalid UTF-8 characters")
+		return reject(observation, i18n.ErrObservationInvalidUTF8)
 	}
 	
-	if len(observation) > MaxObservationLength {
-		return fmt.Errorf("observation exceeds maximum length of %d characters", MaxObservationLength)
+	if limit := ActiveLimits().Observation; len(observation) > limit {
+		return reject(observation, i18n.ErrObservationTooLong, limit)
 	}
 	
 	return nil
@@ -150,24 +201,115 @@ func ValidateSearchQuery(query string) error {
 	}
 	
 	if !utf8.ValidString(query) {
-		return fmt.Errorf("search query contains invalid UTF-8 characters")
+		return reject(query, i18n.ErrSearchQueryInvalidUTF8)
 	}
 	
 	if len(query) > MaxSearchQueryLength {
-		return fmt.Errorf("search query exceeds maximum length of %d characters", MaxSearchQueryLength)
+		return reject(query, i18n.ErrSearchQueryTooLong, MaxSearchQueryLength)
 	}
 	
 	return nil
 }
 
+// ValidateSessionLabel validates a session label
+func ValidateSessionLabel(session string) error {
+	if strings.TrimSpace(session) == "" {
+		return reject(session, i18n.ErrSessionEmpty)
+	}
+	
+	if len(session) > MaxSessionLabelLength {
+		return reject(session, i18n.ErrSessionTooLong, MaxSessionLabelLength)
+	}
+	
+	if !utf8.ValidString(session) || strings.IndexFunc(session, unicode.IsControl) >= 0 {
+		return reject(session, i18n.ErrSessionInvalid)
+	}
+	
+	return nil
+}
+
+// validateOptionalSession validates the session label of a write, which may be omitted
+func validateOptionalSession(session string) error {
+	if session == "" {
+		return nil
+	}
+	if err := ValidateSessionLabel(session); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	return nil
+}
+
+// ValidateTag validates a tag
+func ValidateTag(tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return reject(tag, i18n.ErrTagEmpty)
+	}
+
+	if len(tag) > database.MaxTagLength {
+		return reject(tag, i18n.ErrTagTooLong, database.MaxTagLength)
+	}
+
+	if !utf8.ValidString(tag) || strings.IndexFunc(tag, unicode.IsControl) >= 0 || strings.TrimSpace(tag) != tag {
+		return reject(tag, i18n.ErrTagInvalid)
+	}
+
+	return nil
+}
+
+// validateTags validates the tags given for an entity or to filter by
+func validateTags(tags []string) error {
+	if len(tags) > MaxTagsPerEntity {
+		return i18n.NewError(i18n.ErrTooManyTags, len(tags), MaxTagsPerEntity)
+	}
+	for i, tag := range tags {
+		if err := ValidateTag(tag); err != nil {
+			return fmt.Errorf("tags[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateAttributes validates the attributes given for an entity
+func validateAttributes(attributes map[string]any) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > database.MaxAttributesBytes {
+		return i18n.NewError(i18n.ErrAttributesTooLarge, len(encoded), database.MaxAttributesBytes)
+	}
+	return nil
+}
+
 // ValidateCreateEntitiesParams validates parameters for creating entities
 func ValidateCreateEntitiesParams(params CreateEntitiesParams) error {
 	if len(params.Entities) == 0 {
-		return fmt.Errorf("no entities provided")
+		return i18n.NewError(i18n.ErrNoEntities)
 	}
 	
-	if len(params.Entities) > MaxEntitiesPerRequest {
-		return fmt.Errorf("too many entities in request: %d (max %d)", len(params.Entities), MaxEntitiesPerRequest)
+	if limit := ActiveLimits().BatchSize; len(params.Entities) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntities, len(params.Entities), limit)
+	}
+	
+	switch params.OnDuplicate {
+	case "", database.DuplicateSkip, database.DuplicateAppendObservations, database.DuplicateError:
+	default:
+		return reject(params.OnDuplicate, i18n.ErrInvalidOnDuplicate, database.DuplicateSkip, database.DuplicateAppendObservations, database.DuplicateError)
+	}
+	switch params.OnConflict {
+	case "", database.DuplicateSkip, database.DuplicateMergeObservations:
+	default:
+		return reject(params.OnConflict, i18n.ErrInvalidOnConflict, database.DuplicateSkip, database.DuplicateMergeObservations)
+	}
+	if params.OnConflict != "" && params.OnDuplicate != "" {
+		return i18n.NewError(i18n.ErrConflictingOnDuplicate)
+	}
+	
+	if err := validateOptionalSession(params.Session); err != nil {
+		return err
 	}
 	
 	for i, entity := range params.Entities {
@@ -180,7 +322,7 @@ func ValidateCreateEntitiesParams(params CreateEntitiesParams) error {
 		}
 		
 		if len(entity.Observations) > MaxObservationsPerEntity {
-			return fmt.Errorf("entity[%d]: too many observations: %d (max %d)", i, len(entity.Observations), MaxObservationsPerEntity)
+			return fmt.Errorf("entity[%d]: %w", i, i18n.NewError(i18n.ErrTooManyObservations, len(entity.Observations), MaxObservationsPerEntity))
 		}
 		
 		for j, obs := range entity.Observations {
@@ -188,6 +330,14 @@ func ValidateCreateEntitiesParams(params CreateEntitiesParams) error {
 				return fmt.Errorf("entity[%d].observations[%d]: %w", i, j, err)
 			}
 		}
+
+		if err := validateTags(entity.Tags); err != nil {
+			return fmt.Errorf("entity[%d]: %w", i, err)
+		}
+
+		if err := validateAttributes(entity.Attributes); err != nil {
+			return fmt.Errorf("entity[%d].attributes: %w", i, err)
+		}
 	}
 	
 	return nil
@@ -196,11 +346,15 @@ func ValidateCreateEntitiesParams(params CreateEntitiesParams) error {
 // ValidateCreateRelationsParams validates parameters for creating relations
 func ValidateCreateRelationsParams(params CreateRelationsParams) error {
 	if len(params.Relations) == 0 {
-		return fmt.Errorf("no relations provided")
+		return i18n.NewError(i18n.ErrNoRelations)
+	}
+	
+	if limit := ActiveLimits().BatchSize; len(params.Relations) > limit {
+		return i18n.NewError(i18n.ErrTooManyRelations, len(params.Relations), limit)
 	}
 	
-	if len(params.Relations) > MaxEntitiesPerRequest {
-		return fmt.Errorf("too many relations in request: %d (max %d)", len(params.Relations), MaxEntitiesPerRequest)
+	if err := validateOptionalSession(params.Session); err != nil {
+		return err
 	}
 	
 	for i, rel := range params.Relations {
@@ -215,6 +369,18 @@ func ValidateCreateRelationsParams(params CreateRelationsParams) error {
 		if err := ValidateRelationType(rel.RelationType); err != nil {
 			return fmt.Errorf("relation[%d].relationType: %w", i, err)
 		}
+
+		if rel.Confidence < 0 || rel.Confidence > 1 {
+			return fmt.Errorf("relation[%d].confidence: %w", i, reject(fmt.Sprint(rel.Confidence), i18n.ErrInvalidConfidence))
+		}
+
+		if len(rel.Note) > MaxRelationNoteLength {
+			return fmt.Errorf("relation[%d].note: %w", i, reject(rel.Note, i18n.ErrRelationNoteTooLong, MaxRelationNoteLength))
+		}
+
+		if !utf8.ValidString(rel.Note) {
+			return fmt.Errorf("relation[%d].note: %w", i, reject(rel.Note, i18n.ErrRelationNoteInvalid))
+		}
 	}
 	
 	return nil
@@ -223,7 +389,15 @@ func ValidateCreateRelationsParams(params CreateRelationsParams) error {
 // ValidateAddObservationsParams validates parameters for adding observations
 func ValidateAddObservationsParams(params AddObservationsParams) error {
 	if len(params.Observations) == 0 {
-		return fmt.Errorf("no observations provided")
+		return i18n.NewError(i18n.ErrNoObservations)
+	}
+
+	if params.IfAbsentSimilar < 0 || params.IfAbsentSimilar > 1 {
+		return fmt.Errorf("ifAbsentSimilar: %w", reject(fmt.Sprint(params.IfAbsentSimilar), i18n.ErrInvalidSimilarity))
+	}
+	
+	if err := validateOptionalSession(params.Session); err != nil {
+		return err
 	}
 	
 	for i, obs := range params.Observations {
@@ -232,11 +406,11 @@ func ValidateAddObservationsParams(params AddObservationsParams) error {
 		}
 		
 		if len(obs.Contents) == 0 {
-			return fmt.Errorf("observations[%d]: no contents provided", i)
+			return fmt.Errorf("observations[%d]: %w", i, i18n.NewError(i18n.ErrNoContents))
 		}
 		
 		if len(obs.Contents) > MaxObservationsPerEntity {
-			return fmt.Errorf("observations[%d]: too many observations: %d (max %d)", i, len(obs.Contents), MaxObservationsPerEntity)
+			return fmt.Errorf("observations[%d]: %w", i, i18n.NewError(i18n.ErrTooManyObservations, len(obs.Contents), MaxObservationsPerEntity))
 		}
 		
 		for j, content := range obs.Contents {
@@ -244,51 +418,765 @@ func ValidateAddObservationsParams(params AddObservationsParams) error {
 				return fmt.Errorf("observations[%d].contents[%d]: %w", i, j, err)
 			}
 		}
+
+		if err := validateExpiry(obs); err != nil {
+			return fmt.Errorf("observations[%d]: %w", i, err)
+		}
 	}
 	
 	return nil
 }
 
+// validateExpiry validates the expiresAt or ttlSeconds of an add_observations item
+func validateExpiry(obs ObservationInput) error {
+	if obs.ExpiresAt != "" && obs.TTLSeconds != 0 {
+		return i18n.NewError(i18n.ErrConflictingExpiry)
+	}
+	if obs.TTLSeconds < 0 || obs.TTLSeconds > MaxTTLSeconds {
+		return reject(strconv.Itoa(obs.TTLSeconds), i18n.ErrInvalidTTL, MaxTTLSeconds)
+	}
+	if obs.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, obs.ExpiresAt)
+		if err != nil {
+			return reject(obs.ExpiresAt, i18n.ErrInvalidTime, "expiresAt")
+		}
+		if !expiresAt.After(time.Now()) {
+			return reject(obs.ExpiresAt, i18n.ErrExpiryInPast)
+		}
+	}
+	return nil
+}
+
+// ValidateUpdateEntitiesParams validates parameters for updating entities
+func ValidateUpdateEntitiesParams(params UpdateEntitiesParams) error {
+	if len(params.Entities) == 0 {
+		return i18n.NewError(i18n.ErrNoEntities)
+	}
+
+	if limit := ActiveLimits().BatchSize; len(params.Entities) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntities, len(params.Entities), limit)
+	}
+
+	for i, entity := range params.Entities {
+		if err := ValidateEntityName(entity.Name); err != nil {
+			return fmt.Errorf("entities[%d].name: %w", i, err)
+		}
+
+		if entity.Attributes == nil {
+			return fmt.Errorf("entities[%d]: %w", i, i18n.NewError(i18n.ErrNoEntityChanges))
+		}
+
+		if err := validateAttributes(entity.Attributes); err != nil {
+			return fmt.Errorf("entities[%d].attributes: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTagsParams validates parameters for adding or removing tags
+func ValidateTagsParams(params TagsParams) error {
+	if len(params.Entities) == 0 {
+		return i18n.NewError(i18n.ErrNoEntities)
+	}
+
+	if limit := ActiveLimits().BatchSize; len(params.Entities) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntities, len(params.Entities), limit)
+	}
+
+	for i, entity := range params.Entities {
+		if err := ValidateEntityName(entity.EntityName); err != nil {
+			return fmt.Errorf("entities[%d].entityName: %w", i, err)
+		}
+
+		if len(entity.Tags) == 0 {
+			return fmt.Errorf("entities[%d]: %w", i, i18n.NewError(i18n.ErrNoTags))
+		}
+
+		if err := validateTags(entity.Tags); err != nil {
+			return fmt.Errorf("entities[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
 // ValidateDeleteEntitiesParams validates parameters for deleting entities
 func ValidateDeleteEntitiesParams(params DeleteEntitiesParams) error {
 	if len(params.EntityNames) == 0 {
-		return fmt.Errorf("no entity names provided")
+		return i18n.NewError(i18n.ErrNoEntityNames)
 	}
 	
-	if len(params.EntityNames) > MaxEntitiesPerRequest {
-		return fmt.Errorf("too many entities to delete: %d (max %d)", len(params.EntityNames), MaxEntitiesPerRequest)
+	if limit := ActiveLimits().BatchSize; len(params.EntityNames) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntitiesToDelete, len(params.EntityNames), limit)
 	}
 	
-	for i, name := range params.EntityNames {
-		if err := ValidateEntityName(name); err != nil {
+	for i, item := range params.EntityNames {
+		if err := ValidateEntityName(item.Name); err != nil {
 			return fmt.Errorf("entityNames[%d]: %w", i, err)
 		}
+		if item.ReassignRelationsTo == "" {
+			continue
+		}
+		if err := ValidateEntityName(item.ReassignRelationsTo); err != nil {
+			return fmt.Errorf("entityNames[%d].reassignRelationsTo: %w", i, err)
+		}
+		if item.ReassignRelationsTo == item.Name {
+			return fmt.Errorf("entityNames[%d].reassignRelationsTo: %w", i, reject(item.Name, i18n.ErrReassignToSelf))
+		}
 	}
 	
 	return nil
 }
 
+// ValidateRestoreEntitiesParams validates parameters for restoring entities from the
+// trash
+func ValidateRestoreEntitiesParams(params RestoreEntitiesParams) error {
+	if len(params.EntityNames) == 0 {
+		return i18n.NewError(i18n.ErrNoEntityNames)
+	}
+	return validateNameList(params.EntityNames)
+}
+
+// ValidatePurgeDeletedParams validates parameters for purging the trash
+func ValidatePurgeDeletedParams(params PurgeDeletedParams) error {
+	if params.OlderThanDays < 0 {
+		return reject(strconv.Itoa(params.OlderThanDays), i18n.ErrInvalidPurgeAge)
+	}
+	return validateNameList(params.EntityNames)
+}
+
+// validateNameList validates a list of entity names, such as those in the trash
+func validateNameList(names []string) error {
+	if limit := ActiveLimits().BatchSize; len(names) > limit {
+		return i18n.NewError(i18n.ErrTooManyNames, len(names), limit)
+	}
+	for i, name := range names {
+		if err := ValidateEntityName(name); err != nil {
+			return fmt.Errorf("entityNames[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateGetHistoryParams validates parameters for reading the change history
+func ValidateGetHistoryParams(params GetHistoryParams) error {
+	if params.EntityName != "" {
+		if err := ValidateEntityName(params.EntityName); err != nil {
+			return fmt.Errorf("entityName: %w", err)
+		}
+	}
+	for _, field := range []struct{ name, value string }{{"since", params.Since}, {"until", params.Until}} {
+		if _, err := parseHistoryTime(field.value); err != nil {
+			return reject(field.value, i18n.ErrInvalidTime, field.name)
+		}
+	}
+	if params.AfterID < 0 {
+		return reject(strconv.FormatInt(params.AfterID, 10), i18n.ErrNegativeAfterID)
+	}
+	if params.Limit < 0 || params.Limit > database.MaxHistoryLimit {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, database.MaxHistoryLimit)
+	}
+	return nil
+}
+
+// ValidateFindOrphansParams validates parameters for finding orphan entities
+func ValidateFindOrphansParams(params FindOrphansParams) error {
+	if params.OlderThanDays < 0 {
+		return reject(strconv.Itoa(params.OlderThanDays), i18n.ErrInvalidPurgeAge)
+	}
+	if params.Limit < 0 || params.Limit > database.MaxOrphanLimit {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, database.MaxOrphanLimit)
+	}
+	return nil
+}
+
+// ValidateGraphHotspotsParams validates parameters for ranking the most connected entities
+func ValidateGraphHotspotsParams(params GraphHotspotsParams) error {
+	switch params.By {
+	case "", database.DegreeTotal, database.DegreeIn, database.DegreeOut:
+	default:
+		return reject(params.By, i18n.ErrInvalidHotspotOrder, database.DegreeTotal, database.DegreeIn, database.DegreeOut)
+	}
+	if params.EntityType != "" {
+		if err := ValidateEntityType(params.EntityType); err != nil {
+			return err
+		}
+	}
+	if params.Limit < 0 || params.Limit > database.MaxHotspotLimit {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, database.MaxHotspotLimit)
+	}
+	return nil
+}
+
+// ValidateFindDuplicatesParams validates parameters for finding duplicate entities
+func ValidateFindDuplicatesParams(params FindDuplicatesParams) error {
+	if params.EntityType != "" {
+		if err := ValidateEntityType(params.EntityType); err != nil {
+			return err
+		}
+	}
+	if params.Threshold < 0 || params.Threshold > 1 {
+		return reject(fmt.Sprint(params.Threshold), i18n.ErrInvalidDuplicateThreshold)
+	}
+	if params.Limit < 0 || params.Limit > database.MaxDuplicateLimit {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, database.MaxDuplicateLimit)
+	}
+	return nil
+}
+
+// ValidatePinParams validates parameters for pinning or unpinning entities
+func ValidatePinParams(params PinParams) error {
+	if len(params.EntityNames) == 0 {
+		return i18n.NewError(i18n.ErrNoEntityNames)
+	}
+	return validateNameList(params.EntityNames)
+}
+
+// ValidateAliasParams validates the parameters of add_alias
+func ValidateAliasParams(params AliasParams) error {
+	if err := ValidateEntityName(params.EntityName); err != nil {
+		return fmt.Errorf("entityName: %w", err)
+	}
+	if len(params.Aliases) == 0 {
+		return i18n.NewError(i18n.ErrNoAliases)
+	}
+	if limit := ActiveLimits().BatchSize; len(params.Aliases) > limit {
+		return i18n.NewError(i18n.ErrTooManyNames, len(params.Aliases), limit)
+	}
+	for i, alias := range params.Aliases {
+		if err := ValidateEntityName(alias); err != nil {
+			return fmt.Errorf("aliases[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateSnapshotLabel validates the label of a graph snapshot
+func ValidateSnapshotLabel(label string) error {
+	if label == "" || len(label) > database.MaxSnapshotLabelLength || !utf8.ValidString(label) ||
+		strings.IndexFunc(label, unicode.IsControl) >= 0 {
+		return reject(label, i18n.ErrInvalidSnapshotLabel, database.MaxSnapshotLabelLength)
+	}
+	return nil
+}
+
 // ValidateSearchNodesParams validates parameters for searching nodes
 func ValidateSearchNodesParams(params SearchNodesParams) error {
-	return ValidateSearchQuery(params.Query)
+	if err := ValidateSearchQuery(params.Query); err != nil {
+		return err
+	}
+	
+	if params.Limit < 0 || params.Limit > MaxSearchPageSize {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxSearchPageSize)
+	}
+	
+	if params.Offset < 0 {
+		return reject(strconv.Itoa(params.Offset), i18n.ErrNegativeOffset)
+	}
+	
+	switch params.Mode {
+	case "", database.SearchSubstring, database.SearchExact, database.SearchPrefix:
+	default:
+		return reject(params.Mode, i18n.ErrInvalidSearchMode, database.SearchSubstring, database.SearchExact, database.SearchPrefix)
+	}
+	
+	switch params.Syntax {
+	case "", SearchSyntaxPlain:
+	case SearchSyntaxFTS5:
+		if params.Mode != "" && params.Mode != database.SearchSubstring {
+			return reject(params.Mode, i18n.ErrSearchSyntaxMode, SearchSyntaxFTS5, database.SearchSubstring)
+		}
+	default:
+		return reject(params.Syntax, i18n.ErrInvalidSearchSyntax, SearchSyntaxPlain, SearchSyntaxFTS5)
+	}
+
+	if err := validateTags(params.Tags); err != nil {
+		return err
+	}
+
+	if params.SearchAttributes && (params.Mode == database.SearchExact || params.Mode == database.SearchPrefix || params.Syntax == SearchSyntaxFTS5) {
+		return i18n.NewError(i18n.ErrAttributeSearchMode, database.SearchSubstring, SearchSyntaxPlain)
+	}
+
+	if err := validateDetail(params.Detail,
+		entityOption{"includeTimestamps", params.IncludeTimestamps},
+		entityOption{"includeSnippets", params.IncludeSnippets},
+		entityOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return err
+	}
+	
+	return nil
 }
 
 // ValidateOpenNodesParams validates parameters for opening nodes
 func ValidateOpenNodesParams(params OpenNodesParams) error {
+	if err := validateExternalRelations(params.IncludeExternalRelations); err != nil {
+		return err
+	}
+	if err := validateDetail(params.Detail,
+		entityOption{"includeMetadata", params.IncludeMetadata},
+		entityOption{"includeTimestamps", params.IncludeTimestamps},
+		entityOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return err
+	}
+	
 	// Empty list is allowed - returns empty graph
 	if len(params.Names) == 0 {
 		return nil
 	}
 	
-	if len(params.Names) > MaxEntitiesPerRequest {
-		return fmt.Errorf("too many nodes to open: %d (max %d)", len(params.Names), MaxEntitiesPerRequest)
+	if limit := ActiveLimits().BatchSize; len(params.Names) > limit {
+		return i18n.NewError(i18n.ErrTooManyNodes, len(params.Names), limit)
+	}
+	
+	for i, name := range params.Names {
+		if err := ValidateEntityName(name); err != nil {
+			return fmt.Errorf("names[%d]: %w", i, err)
+		}
+	}
+	
+	return validateTags(params.Tags)
+}
+
+// validateExternalRelations validates the includeExternalRelations mode of open_nodes
+func validateExternalRelations(mode string) error {
+	switch mode {
+	case "", database.ExternalRelationsNone, database.ExternalRelationsIncoming, database.ExternalRelationsOutgoing, database.ExternalRelationsBoth:
+		return nil
+	}
+	return reject(mode, i18n.ErrInvalidExternalRelations,
+		database.ExternalRelationsNone, database.ExternalRelationsIncoming, database.ExternalRelationsOutgoing, database.ExternalRelationsBoth)
+}
+
+// validateDetail validates the detail of a read, and that a summary uses none of
+// options
+func validateDetail(detail string, options ...entityOption) error {
+	switch detail {
+	case "", DetailFull:
+		return nil
+	case DetailSummary:
+	default:
+		return reject(detail, i18n.ErrInvalidDetail, DetailFull, DetailSummary)
+	}
+	for _, option := range options {
+		if option.used {
+			return i18n.NewError(i18n.ErrSummaryOption, option.name, DetailSummary)
+		}
+	}
+	return nil
+}
+
+// ValidateReadGraphParams validates parameters for reading the graph, or a page of it
+func ValidateReadGraphParams(params ReadGraphParams) error {
+	if params.Limit < 0 || params.Limit > MaxGraphPageSize {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxGraphPageSize)
+	}
+	if err := validateDetail(params.Detail,
+		entityOption{"includeTimestamps", params.IncludeTimestamps},
+		entityOption{"includeAliases", params.IncludeAliases},
+	); err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// ValidateGetObservationsParams validates parameters for paging observations
+func ValidateGetObservationsParams(params GetObservationsParams) error {
+	if err := ValidateEntityName(params.EntityName); err != nil {
+		return fmt.Errorf("entityName: %w", err)
+	}
+	
+	if params.Limit < 0 || params.Limit > MaxObservationPageSize {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxObservationPageSize)
+	}
+	
+	if params.Offset < 0 {
+		return reject(strconv.Itoa(params.Offset), i18n.ErrNegativeOffset)
+	}
+	
+	switch params.OrderBy {
+	case "", database.ObservationOrderOldest, database.ObservationOrderNewest:
+	default:
+		return reject(params.OrderBy, i18n.ErrInvalidOrderBy, database.ObservationOrderOldest, database.ObservationOrderNewest)
+	}
+	
+	return nil
+}
+
+// ValidateGetRelationsParams validates parameters for paging an entity's relations
+func ValidateGetRelationsParams(params GetRelationsParams) error {
+	if err := ValidateEntityName(params.EntityName); err != nil {
+		return fmt.Errorf("entityName: %w", err)
+	}
+	
+	if params.RelationType != "" {
+		if err := ValidateRelationType(params.RelationType); err != nil {
+			return fmt.Errorf("relationType: %w", err)
+		}
+	}
+	
+	if params.Limit < 0 || params.Limit > MaxRelationPageSize {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxRelationPageSize)
+	}
+	
+	if params.Offset < 0 {
+		return reject(strconv.Itoa(params.Offset), i18n.ErrNegativeOffset)
+	}
+	
+	return nil
+}
+
+// ValidateFindRelationsParams validates parameters for paging the relations matching a filter
+func ValidateFindRelationsParams(params FindRelationsParams) error {
+	if params.From != "" {
+		if err := ValidateEntityName(params.From); err != nil {
+			return fmt.Errorf("from: %w", err)
+		}
+	}
+	
+	if params.To != "" {
+		if err := ValidateEntityName(params.To); err != nil {
+			return fmt.Errorf("to: %w", err)
+		}
+	}
+	
+	if params.RelationType != "" {
+		if err := ValidateRelationType(params.RelationType); err != nil {
+			return fmt.Errorf("relationType: %w", err)
+		}
+	}
+	
+	if params.Limit < 0 || params.Limit > MaxRelationPageSize {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxRelationPageSize)
+	}
+	
+	if params.Offset < 0 {
+		return reject(strconv.Itoa(params.Offset), i18n.ErrNegativeOffset)
+	}
+	
+	return nil
+}
+
+// ValidateRecentEntitiesParams validates parameters for listing recently updated entities
+func ValidateRecentEntitiesParams(params RecentEntitiesParams) error {
+	if params.Limit < 0 || params.Limit > MaxRecentEntities {
+		return reject(strconv.Itoa(params.Limit), i18n.ErrInvalidPageLimit, MaxRecentEntities)
+	}
+	
+	return nil
+}
+
+// ValidateListEntityTypesParams validates parameters for listing entity types
+func ValidateListEntityTypesParams(params ListEntityTypesParams) error {
+	if params.Prefix != "" {
+		if err := ValidateEntityType(params.Prefix); err != nil {
+			return fmt.Errorf("prefix: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// ValidateListRelationTypesParams validates parameters for listing relation types
+func ValidateListRelationTypesParams(params ListRelationTypesParams) error {
+	if params.MinCount < 0 {
+		return reject(strconv.Itoa(params.MinCount), i18n.ErrNegativeMinCount)
+	}
+	
+	return nil
+}
+
+// ValidateFindPathParams validates parameters for finding a path between entities
+func ValidateFindPathParams(params FindPathParams) error {
+	if err := ValidateEntityName(params.From); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	
+	if err := ValidateEntityName(params.To); err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	
+	if params.MaxDepth < 0 || params.MaxDepth > MaxPathDepth {
+		return fmt.Errorf("maxDepth: %w", reject(strconv.Itoa(params.MaxDepth), i18n.ErrInvalidPathDepth, MaxPathDepth))
+	}
+	
+	return nil
+}
+
+// ValidateGetNeighborsParams validates parameters for getting neighbors
+func ValidateGetNeighborsParams(params GetNeighborsParams) error {
+	if len(params.Names) == 0 {
+		return i18n.NewError(i18n.ErrNoNames)
+	}
+	
+	if len(params.Names) > MaxNeighborNodes {
+		return i18n.NewError(i18n.ErrTooManyNames, len(params.Names), MaxNeighborNodes)
+	}
+	
+	for i, name := range params.Names {
+		if err := ValidateEntityName(name); err != nil {
+			return fmt.Errorf("names[%d]: %w", i, err)
+		}
+	}
+	
+	if params.Depth < 0 || params.Depth > MaxNeighborDepth {
+		return reject(strconv.Itoa(params.Depth), i18n.ErrInvalidNeighborDepth, MaxNeighborDepth)
+	}
+	
+	switch params.Direction {
+	case "", database.NeighborsOut, database.NeighborsIn, database.NeighborsBoth:
+	default:
+		return reject(params.Direction, i18n.ErrInvalidDirection, database.NeighborsOut, database.NeighborsIn, database.NeighborsBoth)
+	}
+	
+	return nil
+}
+
+// ValidateEraseSubjectParams validates parameters for erasing a subject
+func ValidateEraseSubjectParams(params EraseSubjectParams) error {
+	if len(params.Names) == 0 {
+		return i18n.NewError(i18n.ErrNoNames)
+	}
+	
+	if limit := ActiveLimits().BatchSize; len(params.Names) > limit {
+		return i18n.NewError(i18n.ErrTooManyNames, len(params.Names), limit)
 	}
 	
 	for i, name := range params.Names {
 		if err := ValidateEntityName(name); err != nil {
 			return fmt.Errorf("names[%d]: %w", i, err)
 		}
+		if utf8.RuneCountInString(strings.TrimSpace(name)) < MinEraseTermLength {
+			return fmt.Errorf("names[%d]: %w", i, reject(name, i18n.ErrEraseNameTooShort, MinEraseTermLength))
+		}
+	}
+	
+	return nil
+}
+
+// ValidateClearGraphParams validates parameters for clearing the graph
+func ValidateClearGraphParams(params ClearGraphParams) error {
+	if params.Confirm != ClearGraphConfirmation {
+		return reject(params.Confirm, i18n.ErrClearNotConfirmed, ClearGraphConfirmation)
+	}
+	
+	return nil
+}
+
+// ValidateImportChunkParams validates parameters for an import chunk. The decoded
+// size is checked again once base64 data is decoded.
+func ValidateImportChunkParams(params ImportChunkParams) error {
+	if params.ImportID == "" {
+		return i18n.NewError(i18n.ErrImportIDEmpty)
+	}
+	
+	if params.Sequence < database.ImportFirstSequence {
+		return reject(strconv.Itoa(params.Sequence), i18n.ErrInvalidSequence, database.ImportFirstSequence)
+	}
+	
+	maxLen := MaxImportChunkBytes
+	switch params.Encoding {
+	case "", ImportEncodingText:
+	case ImportEncodingBase64:
+		maxLen = base64.StdEncoding.EncodedLen(MaxImportChunkBytes)
+	default:
+		return reject(params.Encoding, i18n.ErrInvalidEncoding, ImportEncodingText, ImportEncodingBase64)
+	}
+	if len(params.Data) > maxLen {
+		return i18n.NewError(i18n.ErrImportChunkTooLarge, MaxImportChunkBytes)
+	}
+	
+	return nil
+}
+
+// ValidateSetTypeMetadataParams validates parameters for setting entity type metadata
+func ValidateSetTypeMetadataParams(params SetTypeMetadataParams) error {
+	if err := ValidateEntityType(params.EntityType); err != nil {
+		return fmt.Errorf("entityType: %w", err)
+	}
+	
+	if len(params.Metadata) == 0 {
+		return i18n.NewError(i18n.ErrNoTypeMetadata)
+	}
+	
+	if len(params.Metadata) > MaxTypeMetadataKeys {
+		return i18n.NewError(i18n.ErrTooManyTypeMetadataKeys, len(params.Metadata), MaxTypeMetadataKeys)
+	}
+	
+	for key, value := range params.Metadata {
+		if key == "" {
+			return fmt.Errorf("metadata: %w", i18n.NewError(i18n.ErrTypeMetadataKeyEmpty))
+		}
+		if len(key) > MaxTypeMetadataKeyLength {
+			return fmt.Errorf("metadata: %w", reject(key, i18n.ErrTypeMetadataKeyTooLong, MaxTypeMetadataKeyLength))
+		}
+		if !utf8.ValidString(key) || strings.IndexFunc(key, unicode.IsControl) >= 0 {
+			return fmt.Errorf("metadata: %w", reject(key, i18n.ErrTypeMetadataKeyInvalid))
+		}
+		if len(value) > MaxTypeMetadataValueLength {
+			return fmt.Errorf("metadata[%q]: %w", key, reject(value, i18n.ErrTypeMetadataValueTooLong, MaxTypeMetadataValueLength))
+		}
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("metadata[%q]: %w", key, reject(value, i18n.ErrTypeMetadataValueInvalid))
+		}
+	}
+	
+	return nil
+}
+
+// ValidateGetTypeMetadataParams validates parameters for reading entity type metadata
+func ValidateGetTypeMetadataParams(params GetTypeMetadataParams) error {
+	if limit := ActiveLimits().BatchSize; len(params.EntityTypes) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntityTypes, len(params.EntityTypes), limit)
+	}
+	
+	for i, entityType := range params.EntityTypes {
+		if err := ValidateEntityType(entityType); err != nil {
+			return fmt.Errorf("entityTypes[%d]: %w", i, err)
+		}
+	}
+	
+	return nil
+}
+
+// ValidateHygieneReportParams validates parameters for the hygiene report
+func ValidateHygieneReportParams(params HygieneReportParams) error {
+	if params.StaleAfterDays < 0 || params.StaleAfterDays > MaxHygieneStaleDays {
+		return fmt.Errorf("staleAfterDays: %w", reject(strconv.Itoa(params.StaleAfterDays), i18n.ErrInvalidStaleAfterDays, MaxHygieneStaleDays))
+	}
+	
+	return nil
+}
+
+// ValidateRollbackSessionParams validates parameters for rolling back a session
+func ValidateRollbackSessionParams(params RollbackSessionParams) error {
+	if err := ValidateSessionLabel(params.Session); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	
+	return nil
+}
+
+// ValidateImportGraphParams validates parameters for importing a graph document. The
+// document itself is validated by ValidateGraphDocument once decoded.
+func ValidateImportGraphParams(params ImportGraphParams) error {
+	switch params.Strategy {
+	case "", database.ImportSkip, database.ImportMerge, database.ImportReplace:
+	default:
+		return reject(params.Strategy, i18n.ErrInvalidImportStrategy, database.ImportSkip, database.ImportMerge, database.ImportReplace)
+	}
+	
+	return nil
+}
+
+// ValidateGraphDocument validates a graph document to import, each entity and
+// relation under the limits of create_entities and create_relations
+func ValidateGraphDocument(doc database.GraphDocument) error {
+	if doc.Version > database.GraphDocumentVersion {
+		return i18n.NewError(i18n.ErrDocumentVersion, doc.Version, database.GraphDocumentVersion)
+	}
+	
+	if limit := ActiveLimits().BatchSize; len(doc.Entities) > limit {
+		return i18n.NewError(i18n.ErrTooManyEntities, len(doc.Entities), limit)
+	}
+	
+	if limit := ActiveLimits().BatchSize; len(doc.Relations) > limit {
+		return i18n.NewError(i18n.ErrTooManyRelations, len(doc.Relations), limit)
+	}
+	
+	for i, entity := range doc.Entities {
+		if err := ValidateEntityName(entity.Name); err != nil {
+			return fmt.Errorf("entities[%d].name: %w", i, err)
+		}
+		
+		if err := ValidateEntityType(entity.Type); err != nil {
+			return fmt.Errorf("entities[%d].type: %w", i, err)
+		}
+		
+		if len(entity.Observations) > MaxObservationsPerEntity {
+			return fmt.Errorf("entities[%d]: %w", i, i18n.NewError(i18n.ErrTooManyObservations, len(entity.Observations), MaxObservationsPerEntity))
+		}
+		
+		for j, obs := range entity.Observations {
+			if err := ValidateObservation(obs); err != nil {
+				return fmt.Errorf("entities[%d].observations[%d]: %w", i, j, err)
+			}
+		}
+
+		if err := validateTags(entity.Tags); err != nil {
+			return fmt.Errorf("entities[%d]: %w", i, err)
+		}
+
+		if err := validateAttributes(entity.Attributes); err != nil {
+			return fmt.Errorf("entities[%d].attributes: %w", i, err)
+		}
+
+		for j, alias := range entity.Aliases {
+			if err := ValidateEntityName(alias); err != nil {
+				return fmt.Errorf("entities[%d].aliases[%d]: %w", i, j, err)
+			}
+		}
+	}
+	
+	for i, rel := range doc.Relations {
+		if err := ValidateEntityName(rel.From); err != nil {
+			return fmt.Errorf("relations[%d].from: %w", i, err)
+		}
+		
+		if err := ValidateEntityName(rel.To); err != nil {
+			return fmt.Errorf("relations[%d].to: %w", i, err)
+		}
+		
+		if err := ValidateRelationType(rel.RelationType); err != nil {
+			return fmt.Errorf("relations[%d].relationType: %w", i, err)
+		}
+
+		if rel.Confidence < 0 || rel.Confidence > 1 {
+			return fmt.Errorf("relations[%d].confidence: %w", i, reject(fmt.Sprint(rel.Confidence), i18n.ErrInvalidConfidence))
+		}
+
+		if len(rel.Note) > MaxRelationNoteLength {
+			return fmt.Errorf("relations[%d].note: %w", i, reject(rel.Note, i18n.ErrRelationNoteTooLong, MaxRelationNoteLength))
+		}
+
+		if !utf8.ValidString(rel.Note) {
+			return fmt.Errorf("relations[%d].note: %w", i, reject(rel.Note, i18n.ErrRelationNoteInvalid))
+		}
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// ValidateExportGraphParams validates parameters for exporting the graph
+func ValidateExportGraphParams(params ExportGraphParams) error {
+	switch params.Format {
+	case "", ExportFormatJSON:
+		if params.Root != "" || params.Depth != 0 || params.MaxNodes != 0 {
+			return i18n.NewError(i18n.ErrViewOptionsFormat, database.ViewDOT, database.ViewGraphML)
+		}
+		return nil
+	case database.ViewDOT, database.ViewGraphML:
+	default:
+		return reject(params.Format, i18n.ErrInvalidExportFormat, ExportFormatJSON, database.ViewDOT, database.ViewGraphML)
+	}
+	
+	if params.Root != "" {
+		if err := ValidateEntityName(params.Root); err != nil {
+			return fmt.Errorf("root: %w", err)
+		}
+	}
+	
+	if params.Depth < 0 || params.Depth > MaxNeighborDepth {
+		return reject(strconv.Itoa(params.Depth), i18n.ErrInvalidNeighborDepth, MaxNeighborDepth)
+	}
+	
+	if params.MaxNodes < 0 || params.MaxNodes > MaxViewNodes {
+		return reject(strconv.Itoa(params.MaxNodes), i18n.ErrInvalidMaxNodes, MaxViewNodes)
+	}
+	
+	return nil
+}
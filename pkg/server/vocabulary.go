@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jamesprial/mcp-memory-rewrite/internal/i18n"
+	"github.com/jamesprial/mcp-memory-rewrite/internal/logging"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// entityTypeList is the result of list_entity_types
+type entityTypeList struct {
+	EntityTypes []database.EntityTypeCount `json:"entityTypes"`
+}
+
+// relationTypeList is the result of list_relation_types
+type relationTypeList struct {
+	RelationTypes []database.RelationTypeCount `json:"relationTypes"`
+}
+
+func (s *Server) handleListEntityTypes(ctx context.Context, params ListEntityTypesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateListEntityTypesParams(params); err != nil {
+		logger.Warn("invalid list_entity_types parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	types, err := s.db.ListEntityTypes(ctx, params.Prefix)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrListEntityTypes, err)
+	}
+
+	return s.marshalResult(ctx, "list_entity_types", &entityTypeList{EntityTypes: types})
+}
+
+func (s *Server) handleListRelationTypes(ctx context.Context, params ListRelationTypesParams) (*mcp.CallToolResult, any, error) {
+	logger := logging.LoggerWithContext(ctx, s.logger)
+
+	if err := ValidateListRelationTypesParams(params); err != nil {
+		logger.Warn("invalid list_relation_types parameters",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, s.invalidParams(ctx, err)
+	}
+
+	types, err := s.db.ListRelationTypes(ctx, params.MinCount)
+	if err != nil {
+		return nil, nil, operationError(ctx, i18n.ErrListRelationTypes, err)
+	}
+
+	return s.marshalResult(ctx, "list_relation_types", &relationTypeList{RelationTypes: types})
+}
@@ -0,0 +1,310 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// Memory is a Store that keeps the graph in process memory, for tests and for
+// scratch memory that needn't outlive the server. Reads return every observation.
+type Memory struct {
+	mu        sync.RWMutex
+	entities  map[string]*memoryEntity
+	relations map[database.RelationDTO]bool
+}
+
+// memoryEntity is one entity of a Memory
+type memoryEntity struct {
+	entityType   string
+	observations []string // In the order they were added
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty Memory
+func NewMemory() *Memory {
+	return &Memory{
+		entities:  map[string]*memoryEntity{},
+		relations: map[database.RelationDTO]bool{},
+	}
+}
+
+// add appends the contents e doesn't have yet and returns them, along with those it
+// had before
+func (e *memoryEntity) add(contents []string) (added, existed []string) {
+	added, existed = []string{}, []string{}
+	for _, content := range contents {
+		switch {
+		case slices.Contains(added, content), slices.Contains(existed, content):
+		case slices.Contains(e.observations, content):
+			existed = append(existed, content)
+		default:
+			e.observations = append(e.observations, content)
+			added = append(added, content)
+		}
+	}
+	return added, existed
+}
+
+func (m *Memory) CreateEntities(ctx context.Context, entities []database.EntityWithObservations) ([]database.EntityWithObservations, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := []database.EntityWithObservations{}
+	for _, entity := range entities {
+		if _, ok := m.entities[entity.Name]; ok {
+			continue
+		}
+		stored := &memoryEntity{entityType: entity.EntityType}
+		stored.add(entity.Observations)
+		m.entities[entity.Name] = stored
+		created = append(created, entity)
+	}
+	return created, nil
+}
+
+func (m *Memory) CreateRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationCreationResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := database.NewRelationCreationResult()
+	for i, rel := range relations {
+		key := database.RelationDTO{From: rel.From, To: rel.To, RelationType: rel.RelationType}
+		if reason := database.MissingEndpoint(m.entities[key.From] != nil, m.entities[key.To] != nil); reason != "" {
+			result.Skip(i, rel, reason)
+			continue
+		}
+		if m.relations[key] {
+			result.Skip(i, rel, database.SkipDuplicate)
+			continue
+		}
+		m.relations[key] = true
+		result.Relations = append(result.Relations, rel)
+	}
+	return result, nil
+}
+
+func (m *Memory) AddObservations(ctx context.Context, observations []database.ObservationAdditionInput) ([]database.ObservationAdditionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the SQLite store, a missing entity fails the whole call
+	for _, obs := range observations {
+		if m.entities[obs.EntityName] == nil {
+			return nil, &database.EntityNotFoundError{Name: obs.EntityName}
+		}
+	}
+	results := []database.ObservationAdditionResult{}
+	for _, obs := range observations {
+		added, existed := m.entities[obs.EntityName].add(obs.Contents)
+		results = append(results, database.ObservationAdditionResult{
+			EntityName:          obs.EntityName,
+			AddedObservations:   added,
+			SkippedObservations: existed,
+		})
+	}
+	return results, nil
+}
+
+func (m *Memory) DeleteEntities(ctx context.Context, names []string) (*database.EntityDeletionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := map[string]bool{}
+	for _, name := range names {
+		if m.entities[name] != nil {
+			deleted[name] = true
+			delete(m.entities, name)
+		}
+	}
+	for rel := range m.relations {
+		if m.entities[rel.From] == nil || m.entities[rel.To] == nil {
+			delete(m.relations, rel)
+		}
+	}
+	return database.NewEntityDeletionResult(names, deleted), nil
+}
+
+func (m *Memory) DeleteObservations(ctx context.Context, deletions []database.ObservationDeletionInput) (*database.ObservationDeletionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := database.NewObservationDeletionResult()
+	seen := map[[2]string]bool{}
+	for _, del := range deletions {
+		entity := m.entities[del.EntityName]
+		if entity == nil {
+			if !slices.Contains(result.NotFound, del.EntityName) {
+				result.NotFound = append(result.NotFound, del.EntityName)
+			}
+			continue
+		}
+		for _, content := range del.Observations {
+			key := [2]string{del.EntityName, content}
+			if !seen[key] && !slices.Contains(entity.observations, content) {
+				result.AddMissing(del.EntityName, content)
+			}
+			seen[key] = true
+		}
+		kept := entity.observations[:0]
+		for _, content := range entity.observations {
+			if !slices.Contains(del.Observations, content) {
+				kept = append(kept, content)
+			}
+		}
+		result.DeletedObservations += len(entity.observations) - len(kept)
+		entity.observations = kept
+	}
+	return result, nil
+}
+
+func (m *Memory) DeleteRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationDeletionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := database.NewRelationDeletionResult()
+	for _, rel := range relations {
+		key := database.RelationDTO{From: rel.From, To: rel.To, RelationType: rel.RelationType}
+		if _, ok := m.relations[key]; ok {
+			delete(m.relations, key)
+			result.DeletedRelations++
+		} else if !slices.Contains(result.NotFound, key) {
+			result.NotFound = append(result.NotFound, key)
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) ReadGraph(ctx context.Context) (*database.KnowledgeGraph, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.entities))
+	for name := range m.entities {
+		names = append(names, name)
+	}
+	return m.graph(names), nil
+}
+
+func (m *Memory) SearchNodes(ctx context.Context, query string, limit, offset int) (*database.SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		terms = []string{strings.ToLower(query)}
+	}
+	relationTypes := map[string][]string{}
+	for relation := range m.relations {
+		relationType := strings.ToLower(relation.RelationType)
+		relationTypes[relation.From] = append(relationTypes[relation.From], relationType)
+		relationTypes[relation.To] = append(relationTypes[relation.To], relationType)
+	}
+	var matched []string
+	for name, entity := range m.entities {
+		if entity.matches(name, relationTypes[name], terms) {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+
+	result := &database.SearchResult{TotalMatches: len(matched), Offset: offset}
+	page := matched[min(offset, len(matched)):]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+	if next := offset + len(page); limit > 0 && next < len(matched) {
+		result.NextOffset = &next
+	}
+	result.KnowledgeGraph = *m.graph(page)
+	return result, nil
+}
+
+// matches reports whether every term appears in the entity's name, type, one of
+// its observations or one of relationTypes, the types of its relations; terms and
+// relationTypes are lower case
+func (e *memoryEntity) matches(name string, relationTypes, terms []string) bool {
+	for _, term := range terms {
+		found := strings.Contains(strings.ToLower(name), term) || strings.Contains(strings.ToLower(e.entityType), term)
+		for _, content := range e.observations {
+			if found {
+				break
+			}
+			found = strings.Contains(strings.ToLower(content), term)
+		}
+		for _, relationType := range relationTypes {
+			if found {
+				break
+			}
+			found = strings.Contains(relationType, term)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Memory) OpenNodes(ctx context.Context, names []string) (*database.KnowledgeGraph, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if m.entities[name] != nil && !seen[name] {
+			seen[name] = true
+			found = append(found, name)
+		}
+	}
+	return m.graph(found), nil
+}
+
+// graph returns the named entities, which must exist, in name order, with the
+// relations among them ordered by from and to name, then relation type. m.mu must
+// be held.
+func (m *Memory) graph(names []string) *database.KnowledgeGraph {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	graph := &database.KnowledgeGraph{
+		Entities:  make([]database.EntityWithObservations, len(names)),
+		Relations: []database.RelationDTO{},
+	}
+	included := make(map[string]bool, len(names))
+	for i, name := range names {
+		entity := m.entities[name]
+		graph.Entities[i] = database.EntityWithObservations{
+			Name:              name,
+			EntityType:        entity.entityType,
+			Observations:      append([]string{}, entity.observations...),
+			TotalObservations: len(entity.observations),
+		}
+		included[name] = true
+	}
+	for rel := range m.relations {
+		if included[rel.From] && included[rel.To] {
+			graph.Relations = append(graph.Relations, rel)
+		}
+	}
+	sort.Slice(graph.Relations, func(i, j int) bool {
+		a, b := graph.Relations[i], graph.Relations[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.RelationType < b.RelationType
+	})
+	return graph
+}
+
+// Close does nothing; the graph is dropped with the Memory
+func (m *Memory) Close() error {
+	return nil
+}
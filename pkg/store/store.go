@@ -0,0 +1,51 @@
+// Package store defines the storage the memory server keeps its knowledge graph in
+package store
+
+import (
+	"context"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+)
+
+// Store holds a knowledge graph of entities with observations and the relations
+// between them. *database.DB, the SQLite store, implements it along with the rest of
+// what the server's other tools use; Memory keeps the graph in process memory.
+type Store interface {
+	// CreateEntities creates the entities whose names are new and returns them
+	CreateEntities(ctx context.Context, entities []database.EntityWithObservations) ([]database.EntityWithObservations, error)
+	// CreateRelations creates the relations that are new and whose entities both
+	// exist, and reports them along with the others, skipped, and why
+	CreateRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationCreationResult, error)
+	// AddObservations adds the contents each entity doesn't have yet, failing when
+	// an entity doesn't exist
+	AddObservations(ctx context.Context, observations []database.ObservationAdditionInput) ([]database.ObservationAdditionResult, error)
+	// DeleteEntities deletes entities with their observations and relations, and
+	// reports how many existed
+	DeleteEntities(ctx context.Context, names []string) (*database.EntityDeletionResult, error)
+	// DeleteObservations deletes the given contents of each entity, and reports which
+	// existed
+	DeleteObservations(ctx context.Context, deletions []database.ObservationDeletionInput) (*database.ObservationDeletionResult, error)
+	// DeleteRelations deletes the given relations, and reports which existed
+	DeleteRelations(ctx context.Context, relations []database.RelationDTO) (*database.RelationDeletionResult, error)
+	// ReadGraph returns every entity in name order and every relation
+	ReadGraph(ctx context.Context) (*database.KnowledgeGraph, error)
+	// SearchNodes returns limit (0 = all) of the entities whose name, type, an
+	// observation or the type of a relation to or from them contains each
+	// whitespace-separated term of query, ignoring case, after skipping offset, in
+	// name order, with the relations among them
+	SearchNodes(ctx context.Context, query string, limit, offset int) (*database.SearchResult, error)
+	// OpenNodes returns the named entities that exist, in name order, with the
+	// relations among them
+	OpenNodes(ctx context.Context, names []string) (*database.KnowledgeGraph, error)
+	Close() error
+}
+
+// FullTextSearcher is a Store with full-text search, which search_nodes prefers to
+// SearchNodes: it matches whole words in any form, such as "hike" for "hiking",
+// rather than substrings
+type FullTextSearcher interface {
+	Store
+	SearchNodesFTS(ctx context.Context, query string, limit, offset int) (*database.SearchResult, error)
+}
+
+var _ Store = (*database.DB)(nil)
@@ -0,0 +1,148 @@
+package store_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/database"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/postgres"
+	"github.com/jamesprial/mcp-memory-rewrite/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// stores returns a new empty store of each implementation, by name. Postgres is
+// included when MEMORY_TEST_POSTGRES_DSN names a database the test may empty, and
+// needs the postgres build tag.
+func stores(t *testing.T) map[string]store.Store {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	db, err := database.NewDBWithLogger(filepath.Join(t.TempDir(), "memory.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	stores := map[string]store.Store{"sqlite": db, "memory": store.NewMemory()}
+
+	if dsn := os.Getenv("MEMORY_TEST_POSTGRES_DSN"); dsn != "" {
+		pg, err := postgres.NewDB(context.Background(), dsn, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { pg.Close() })
+		if err := pg.Truncate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		stores["postgres"] = pg
+	}
+	return stores
+}
+
+// names returns the names of entities
+func names(entities []database.EntityWithObservations) []string {
+	out := []string{}
+	for _, entity := range entities {
+		out = append(out, entity.Name)
+	}
+	return out
+}
+
+// The stores behave alike for everything Store promises
+func TestStores(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			created, err := st.CreateEntities(ctx, []database.EntityWithObservations{
+				{Name: "Bob", EntityType: "person", Observations: []string{"likes chess"}},
+				{Name: "Alice", EntityType: "person", Observations: []string{"likes hiking", "speaks French"}},
+				{Name: "Acme", EntityType: "company"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Bob", "Alice", "Acme"}, names(created))
+			created, err = st.CreateEntities(ctx, []database.EntityWithObservations{
+				{Name: "Alice", EntityType: "robot"},
+				{Name: "Carol", EntityType: "person"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Carol"}, names(created), "existing entities are skipped")
+
+			rels, err := st.CreateRelations(ctx, []database.RelationDTO{
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Bob", To: "Acme", RelationType: "works_at"},
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Alice", To: "Acme", RelationType: "works_at"},
+				{From: "Alice", To: "Nobody", RelationType: "knows"},
+			})
+			assert.NoError(t, err)
+			assert.Len(t, rels.Relations, 3)
+			assert.Equal(t, []database.SkippedRelation{
+				{Index: 3, Relation: database.RelationDTO{From: "Alice", To: "Acme", RelationType: "works_at"}, Reason: database.SkipDuplicate},
+				{Index: 4, Relation: database.RelationDTO{From: "Alice", To: "Nobody", RelationType: "knows"}, Reason: database.SkipMissingTo},
+			}, rels.Skipped, "duplicates and relations to missing entities are skipped")
+
+			added, err := st.AddObservations(ctx, []database.ObservationAdditionInput{
+				{EntityName: "Alice", Contents: []string{"likes hiking", "plays piano", "plays piano", "likes hiking"}},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []database.ObservationAdditionResult{
+				{EntityName: "Alice", AddedObservations: []string{"plays piano"}, SkippedObservations: []string{"likes hiking"}},
+			}, added, "each content is reported once")
+			_, err = st.AddObservations(ctx, []database.ObservationAdditionInput{
+				{EntityName: "Nobody", Contents: []string{"exists"}},
+			})
+			assert.Error(t, err)
+
+			graph, err := st.OpenNodes(ctx, []string{"Alice", "Acme", "Nobody"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Acme", "Alice"}, names(graph.Entities))
+			assert.Equal(t, []database.RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}}, graph.Relations)
+			assert.ElementsMatch(t, []string{"likes hiking", "speaks French", "plays piano"}, graph.Entities[1].Observations)
+
+			result, err := st.SearchNodes(ctx, "LIKES", 0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Alice", "Bob"}, names(result.Entities))
+			result, err = st.SearchNodes(ctx, "person", 1, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, result.TotalMatches)
+			assert.Len(t, result.Entities, 1)
+			if assert.NotNil(t, result.NextOffset) {
+				assert.Equal(t, 2, *result.NextOffset)
+			}
+			result, err = st.SearchNodes(ctx, "works chess", 0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Bob"}, names(result.Entities), "terms match relation types and different fields")
+
+			deletedObs, err := st.DeleteObservations(ctx, []database.ObservationDeletionInput{
+				{EntityName: "Alice", Observations: []string{"speaks French", "never said"}},
+				{EntityName: "Nobody", Observations: []string{"exists"}},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, &database.ObservationDeletionResult{
+				DeletedObservations: 1,
+				NotFound:            []string{"Nobody"},
+				MissingObservations: []database.ObservationDeletionInput{{EntityName: "Alice", Observations: []string{"never said"}}},
+			}, deletedObs)
+			deletedRels, err := st.DeleteRelations(ctx, []database.RelationDTO{
+				{From: "Alice", To: "Bob", RelationType: "knows"},
+				{From: "Bob", To: "Alice", RelationType: "knows"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, &database.RelationDeletionResult{
+				DeletedRelations: 1,
+				NotFound:         []database.RelationDTO{{From: "Bob", To: "Alice", RelationType: "knows"}},
+			}, deletedRels)
+			deleted, err := st.DeleteEntities(ctx, []string{"Bob", "Nobody"})
+			assert.NoError(t, err)
+			assert.Equal(t, &database.EntityDeletionResult{DeletedEntities: 1, NotFound: []string{"Nobody"}}, deleted)
+
+			graph, err = st.ReadGraph(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"Acme", "Alice", "Carol"}, names(graph.Entities))
+			assert.Equal(t, []database.RelationDTO{{From: "Alice", To: "Acme", RelationType: "works_at"}}, graph.Relations)
+			assert.ElementsMatch(t, []string{"likes hiking", "plays piano"}, graph.Entities[1].Observations)
+		})
+	}
+}